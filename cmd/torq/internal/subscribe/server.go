@@ -2,39 +2,26 @@ package subscribe
 
 import (
 	"context"
+	"sync"
 
 	"github.com/cockroachdb/errors"
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
-	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
 
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/lnd"
-
-	// "github.com/rs/zerolog/log"
-	"golang.org/x/sync/errgroup"
-	"google.golang.org/grpc"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 // Start runs the background server. It subscribes to events, gossip and
 // fetches data as needed and stores it in the database.
 // It is meant to run as a background task / daemon and is the bases for all
-// of Torqs data collection
-func Start(ctx context.Context, conn *grpc.ClientConn, db *sqlx.DB, nodeId int, eventChannel chan interface{}) error {
+// of Torqs data collection. It returns when the context is done.
+func Start(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, nodeId int,
+	eventChannel chan interface{}) error {
 
 	_, monitorCancel := context.WithCancel(context.Background())
 
-	router := routerrpc.NewRouterClient(conn)
-	client := lnrpc.NewLightningClient(conn)
-
-	// Create an error group to catch errors from go routines.
-	// TODO: Improve this by using the context to propogate the error,
-	//   shutting down the if one of the subscribe go routines fail.
-	//   https://www.fullstory.com/blog/why-errgroup-withcontext-in-golang-server-handlers/
-	// TODO: Also consider using the same context used by the gRPC connection from Golang and the
-	//   gRPC server of Torq
-	errs, ctx := errgroup.WithContext(ctx)
-
 	nodeSettings := commons.GetNodeSettingsByNodeId(nodeId)
 
 	//Import Open channels
@@ -65,99 +52,73 @@ func Start(ctx context.Context, conn *grpc.ClientConn, db *sqlx.DB, nodeId int,
 		return errors.Wrap(err, "LND import node info")
 	}
 
-	// TODO FIXME channels with short_channel_id = null and status IN (1,2,100,101,102,103) should be fixed somehow???
-	//  Open                   = 1
-	//  Closing                = 2
-	//	CooperativeClosed      = 100
-	//	LocalForceClosed       = 101
-	//	RemoteForceClosed      = 102
-	//	BreachClosed           = 103
-
-	// Transactions
-	errs.Go(func() error {
-		err := lnd.SubscribeAndStoreTransactions(ctx, client, db, nodeSettings, eventChannel)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and store transactions")
-		}
-		return nil
-	})
-
-	// // HTLC events
-	errs.Go(func() error {
-		err := lnd.SubscribeAndStoreHtlcEvents(ctx, router, db, nodeSettings, eventChannel)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and store HTLC events")
-		}
-		return nil
-	})
-
-	// // Channel Events
-	errs.Go(func() error {
-		err := lnd.SubscribeAndStoreChannelEvents(ctx, client, db, nodeSettings, eventChannel)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and store channel events")
-		}
-		return nil
-	})
-
-	// Graph (Node updates, fee updates etc.)
-	errs.Go(func() error {
-		err := lnd.SubscribeAndStoreChannelGraph(ctx, client, db, nodeSettings, eventChannel)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and store channel graph")
-		}
-		return nil
-	})
-
-	// Forwarding history
-	errs.Go(func() error {
-		err := lnd.SubscribeForwardingEvents(ctx, client, db, nodeSettings, eventChannel, nil)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe forwarding events")
-		}
-		return nil
-	})
-
-	// Invoices
-	errs.Go(func() error {
-		err := lnd.SubscribeAndStoreInvoices(ctx, client, db, nodeSettings, eventChannel)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and store invoices")
-		}
-		return nil
-	})
-
-	// Payments
-	errs.Go(func() error {
-		err := lnd.SubscribeAndStorePayments(ctx, client, db, nodeSettings, eventChannel, nil)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and store payments")
-		}
-		return nil
-	})
-
-	// Update in flight payments
-	errs.Go(func() error {
-		err := lnd.UpdateInFlightPayments(ctx, client, db, nodeSettings, eventChannel, nil)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe and update payments")
-		}
-		return nil
-	})
-
-	// Peer Events
-	errs.Go(func() error {
-		err := lnd.SubscribePeerEvents(ctx, client, nodeSettings, eventChannel)
-		if err != nil {
-			return errors.Wrap(err, "LND subscribe peer events")
-		}
-		return nil
-	})
+	// Channels that are stored without short_channel_id or with an outdated status are fixed by the reconciliation.
+
+	streams := []stream{
+		{name: lnd.StreamTransactions, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreTransactions(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store transactions")
+		}},
+		{name: lnd.StreamHtlcEvents, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreHtlcEvents(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store HTLC events")
+		}},
+		{name: lnd.StreamChannelEvents, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreChannelEvents(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store channel events")
+		}},
+		// Graph (Node updates, fee updates etc.)
+		{name: lnd.StreamChannelGraph, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreChannelGraph(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store channel graph")
+		}},
+		{name: lnd.StreamForwards, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeForwardingEvents(ctx, client, db, nodeSettings, eventChannel, nil),
+				"LND subscribe forwarding events")
+		}},
+		{name: lnd.StreamInvoices, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreInvoices(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store invoices")
+		}},
+		{name: lnd.StreamPayments, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStorePayments(ctx, client, db, nodeSettings, eventChannel, nil),
+				"LND subscribe and store payments")
+		}},
+		{name: lnd.StreamInFlightPayments, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.UpdateInFlightPayments(ctx, client, db, nodeSettings, eventChannel, nil),
+				"LND subscribe and update payments")
+		}},
+		{name: lnd.StreamPeerEvents, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribePeerEvents(ctx, client, nodeSettings, eventChannel),
+				"LND subscribe peer events")
+		}},
+		{name: lnd.StreamBlocks, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreBlocks(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store blocks")
+		}},
+		{name: lnd.StreamPendingChannels, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.UpdatePendingChannels(ctx, client, db, nodeSettings, eventChannel),
+				"LND update pending channels")
+		}},
+		{name: lnd.StreamChannelBackups, run: func(ctx context.Context) error {
+			return errors.Wrap(lnd.SubscribeAndStoreChannelBackups(ctx, client, db, nodeSettings, eventChannel),
+				"LND subscribe and store channel backups")
+		}},
+	}
 
-	err = errs.Wait()
+	// Every stream is supervised on its own, a failing stream is restarted without touching the others.
+	var wg sync.WaitGroup
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s stream) {
+			defer wg.Done()
+			supervise(ctx, nodeId, s, eventChannel)
+		}(s)
+	}
+	wg.Wait()
 
 	// Everything that will write to the PeerPubKeyList and ChanPointList has finised so we can cancel the monitor functions
 	monitorCancel()
 
-	return err
+	return nil
 }
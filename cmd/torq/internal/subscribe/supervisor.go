@@ -0,0 +1,91 @@
+package subscribe
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+const (
+	restartBackoffMin = time.Second
+	restartBackoffMax = 5 * time.Minute
+	// healthyRunTime resets the backoff, a stream that ran this long before failing is restarted quickly again.
+	healthyRunTime     = time.Minute
+	statusEventTimeout = time.Second
+)
+
+// stream is a single subscription of a node, run blocks until the stream fails or the context is done.
+type stream struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// supervise runs the stream and restarts it with backoff when it fails until the context is done.
+// A failing stream doesn't affect the other streams of the node.
+func supervise(ctx context.Context, nodeId int, s stream, eventChannel chan interface{}) {
+	backoff := restartBackoffMin
+	restarts := 0
+	for {
+		setStreamState(nodeId, s.name, nodes.StreamRunning, restarts, nil, eventChannel)
+		started := time.Now()
+		err := s.run(ctx)
+		if ctx.Err() != nil {
+			setStreamState(nodeId, s.name, nodes.StreamStopped, restarts, nil, eventChannel)
+			return
+		}
+		if err == nil {
+			err = errors.New("Stream ended unexpectedly")
+		}
+		if time.Since(started) >= healthyRunTime {
+			backoff = restartBackoffMin
+		}
+		log.Error().Err(err).Msgf("Stream %v failed for node id: %v, restarting in %v", s.name, nodeId, backoff)
+		setStreamState(nodeId, s.name, nodes.StreamBackoff, restarts, err, eventChannel)
+
+		select {
+		case <-ctx.Done():
+			setStreamState(nodeId, s.name, nodes.StreamStopped, restarts, nil, eventChannel)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = nextBackoff(backoff)
+		restarts++
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > restartBackoffMax {
+		return restartBackoffMax
+	}
+	return backoff
+}
+
+func setStreamState(nodeId int, streamName string, state nodes.StreamState, restarts int, err error,
+	eventChannel chan interface{}) {
+
+	status := nodes.SetStreamState(nodeId, streamName, state, restarts, err)
+	if eventChannel == nil {
+		return
+	}
+	event := broadcast.StreamStatusEvent{
+		EventData: broadcast.EventData{EventTime: time.Now().UTC(), NodeId: nodeId},
+		Stream:    status.Stream,
+		State:     string(status.State),
+		Restarts:  status.Restarts,
+	}
+	if status.LastError != nil {
+		event.LastError = *status.LastError
+	}
+	// The event channel is shared by all nodes, a status event is not worth stalling the stream for.
+	select {
+	case eventChannel <- event:
+	case <-time.After(statusEventTimeout):
+		log.Debug().Msgf("Dropped %v stream status event for node id: %v", streamName, nodeId)
+	}
+}
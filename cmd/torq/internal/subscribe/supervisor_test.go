@@ -0,0 +1,72 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/internal/nodes"
+)
+
+func TestNextBackoff(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		want    time.Duration
+	}{
+		{time.Second, 2 * time.Second},
+		{2 * time.Minute, 4 * time.Minute},
+		{4 * time.Minute, restartBackoffMax},
+		{restartBackoffMax, restartBackoffMax},
+	}
+	for i, test := range tests {
+		if got := nextBackoff(test.backoff); got != test.want {
+			t.Errorf("%d: nextBackoff()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+		}
+	}
+}
+
+func TestSupervise(t *testing.T) {
+	const nodeId = -1
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan struct{}, 2)
+	count := 0
+	failing := stream{name: "failing", run: func(ctx context.Context) error {
+		count++
+		runs <- struct{}{}
+		if count == 1 {
+			return errors.New("stream broke")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	eventChannel := make(chan interface{}, 10)
+
+	done := make(chan struct{})
+	go func() {
+		supervise(ctx, nodeId, failing, eventChannel)
+		close(done)
+	}()
+	<-runs
+	select {
+	case <-runs:
+	case <-time.After(restartBackoffMin + time.Second):
+		t.Fatalf("supervise() didn't restart the failed stream")
+	}
+
+	statuses := nodes.GetStreamStatuses(nodeId)
+	if len(statuses) != 1 || statuses[0].State != nodes.StreamRunning || statuses[0].Restarts != 1 ||
+		statuses[0].LastError == nil {
+		t.Errorf("GetStreamStatuses() after restart\nGot:\n%+v\nWant:\n%v\n", statuses, "running with 1 restart")
+	}
+
+	cancel()
+	<-done
+	if statuses := nodes.GetStreamStatuses(nodeId); statuses[0].State != nodes.StreamStopped {
+		t.Errorf("GetStreamStatuses() after cancel\nGot:\n%v\nWant:\n%v\n", statuses[0].State, nodes.StreamStopped)
+	}
+	// running, backoff, running and stopped
+	if len(eventChannel) != 4 {
+		t.Errorf("stream status events\nGot:\n%v\nWant:\n%v\n", len(eventChannel), 4)
+	}
+}
@@ -0,0 +1,217 @@
+package torqsrv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/internal/event_log"
+	"github.com/lncapital/torq/internal/users"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/testutil"
+)
+
+const testApiPassword = "password"
+
+type apiTestClient struct {
+	t       *testing.T
+	baseURL string
+	client  *http.Client
+}
+
+func (api apiTestClient) do(method string, path string, body interface{}, response interface{}) int {
+	api.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			api.t.Fatalf("Marshal() error: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, api.baseURL+path, reader)
+	if err != nil {
+		api.t.Fatalf("NewRequest() error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := api.client.Do(req)
+	if err != nil {
+		api.t.Fatalf("%v %v error: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if response != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			api.t.Fatalf("%v %v decode error: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// newApiTestClient serves the API with the nodes of the test database replaced by fake nodes and logs in as the
+// configured administrator.
+func newApiTestClient(t *testing.T, fakeNodes map[int]*node_client.FakeNode) apiTestClient {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		t.Fatalf("InitTestDBConn() error: %v", err)
+	}
+	db, cancel, err := srv.NewTestDatabase(true)
+	if err != nil {
+		t.Fatalf("NewTestDatabase() error: %v", err)
+	}
+	for nodeId, fakeNode := range fakeNodes {
+		node_client.Register(nodeId, fakeNode)
+	}
+	t.Cleanup(func() {
+		for nodeId := range fakeNodes {
+			node_client.Unregister(nodeId)
+		}
+		cancel()
+		if err := srv.Cleanup(); err != nil {
+			t.Errorf("Cleanup() error: %v", err)
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	auth.CreateSession(r, testApiPassword)
+	eventChannel := make(chan interface{}, 100)
+	registerRoutes(r, db, testApiPassword, time.Minute, eventChannel, broadcast.NewBroadcastServer(context.Background(),
+		event_log.Start(context.Background(), db, eventChannel, event_log.DefaultSize)), broadcast.SubscriptionOptions{},
+		func() error { return nil })
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New() error: %v", err)
+	}
+	client := &http.Client{Jar: jar}
+	resp, err := client.PostForm(server.URL+"/api/login",
+		url.Values{"username": {users.ConfigAdminUsername}, "password": {testApiPassword}})
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Login status\nGot:\n%v\nWant:\n%v\n", resp.StatusCode, http.StatusOK)
+	}
+	return apiTestClient{t: t, baseURL: server.URL, client: client}
+}
+
+func TestApiWithFakeNodes(t *testing.T) {
+	alice := node_client.NewFakeNode("alice")
+	bob := node_client.NewFakeNode("bob")
+	channel := alice.AddChannel(bob.PublicKey(), 1000000, 600000)
+	bob.AddChannel(alice.PublicKey(), 1000000, 400000)
+	api := newApiTestClient(t, map[int]*node_client.FakeNode{1: alice, 2: bob})
+
+	var invoice struct {
+		PaymentRequest string `json:"paymentRequest"`
+	}
+	if status := api.do(http.MethodPost, "/api/invoices/newinvoice",
+		map[string]interface{}{"nodeId": 2, "memo": "coffee", "valueMsat": 150000}, &invoice); status != http.StatusOK {
+		t.Fatalf("POST /api/invoices/newinvoice\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+
+	var decoded struct {
+		DestinationPubKey string `json:"destinationPubKey"`
+		NodeAlias         string `json:"nodeAlias"`
+		ValueMsat         int64  `json:"valueMsat"`
+		Memo              string `json:"memo"`
+	}
+	if status := api.do(http.MethodGet, "/api/invoices/decode/?nodeId=1&invoice="+invoice.PaymentRequest, nil,
+		&decoded); status != http.StatusOK {
+		t.Fatalf("GET /api/invoices/decode/\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+	if decoded.DestinationPubKey != bob.PublicKey() || decoded.NodeAlias != "bob" || decoded.ValueMsat != 150000 ||
+		decoded.Memo != "coffee" {
+		t.Errorf("GET /api/invoices/decode/\nGot:\n%v\nWant:\n%v\n", decoded, "bob, 150000 msat, coffee")
+	}
+
+	var peers []struct {
+		PubKey string `json:"pubKey"`
+	}
+	if status := api.do(http.MethodGet, "/api/peers?nodeId=1", nil, &peers); status != http.StatusOK {
+		t.Fatalf("GET /api/peers\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+	if len(peers) != 1 || peers[0].PubKey != bob.PublicKey() {
+		t.Errorf("GET /api/peers\nGot:\n%v\nWant:\n%v\n", peers, bob.PublicKey())
+	}
+
+	var channelList []struct {
+		NodeId            int    `json:"nodeId"`
+		LNDShortChannelId uint64 `json:"lndShortChannelId"`
+		LocalBalance      int64  `json:"localBalance"`
+		PeerAlias         string `json:"peerAlias"`
+	}
+	if status := api.do(http.MethodGet, "/api/channels", nil, &channelList); status != http.StatusOK {
+		t.Fatalf("GET /api/channels\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+	localBalances := make(map[int]int64)
+	for _, listed := range channelList {
+		localBalances[listed.NodeId] = listed.LocalBalance
+		if listed.NodeId == 1 && (listed.LNDShortChannelId != channel.ChanId || listed.PeerAlias != "bob") {
+			t.Errorf("GET /api/channels\nGot:\n%v\nWant:\n%v\n", listed, "the channel of alice with bob")
+		}
+	}
+	if len(channelList) != 2 || localBalances[1] != 600000 || localBalances[2] != 400000 {
+		t.Errorf("GET /api/channels\nGot:\n%v\nWant:\n%v\n", channelList, "a channel for each node")
+	}
+
+	var signed struct {
+		Signature string `json:"signature"`
+	}
+	if status := api.do(http.MethodGet, "/api/messages/sign", map[string]interface{}{"nodeId": 1, "message": "hello"},
+		&signed); status != http.StatusOK {
+		t.Fatalf("GET /api/messages/sign\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+	var verified struct {
+		Valid  bool   `json:"valid"`
+		PubKey string `json:"pubKey"`
+	}
+	if status := api.do(http.MethodGet, "/api/messages/verify", map[string]interface{}{"nodeId": 2,
+		"message": "hello", "signature": signed.Signature}, &verified); status != http.StatusOK {
+		t.Fatalf("GET /api/messages/verify\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+	if !verified.Valid || verified.PubKey != alice.PublicKey() {
+		t.Errorf("GET /api/messages/verify\nGot:\n%v\nWant:\n%v\n", verified, "valid, "+alice.PublicKey())
+	}
+
+	// Paying the invoice of bob from alice moves the balances of both nodes.
+	stream, err := alice.SendPaymentV2(context.Background(),
+		&routerrpc.SendPaymentRequest{PaymentRequest: invoice.PaymentRequest})
+	if err != nil {
+		t.Fatalf("SendPaymentV2() error: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+	}
+	if status := api.do(http.MethodGet, "/api/channels", nil, &channelList); status != http.StatusOK {
+		t.Fatalf("GET /api/channels\nGot:\n%v\nWant:\n%v\n", status, http.StatusOK)
+	}
+	for _, listed := range channelList {
+		localBalances[listed.NodeId] = listed.LocalBalance
+	}
+	if localBalances[1] != 599850 || localBalances[2] != 400150 {
+		t.Errorf("GET /api/channels local balances\nGot:\n%v\nWant:\n%v\n", localBalances,
+			map[int]int64{1: 599850, 2: 400150})
+	}
+}
@@ -0,0 +1,54 @@
+package torqsrv
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/approvals"
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/on_chain_tx"
+	"github.com/lncapital/torq/internal/payments"
+)
+
+// executeApproval runs an approved request through the same path as the websocket and REST requests.
+// Responses of payments and channel closes are sent over the event channel using the reqId of the original request.
+func executeApproval(db *sqlx.DB) approvals.Executor {
+	return func(c *gin.Context, eventChannel chan interface{}, approval approvals.Approval) (interface{}, error) {
+		reqId := fmt.Sprintf("approval-%v", approval.ApprovalId)
+		if approval.ReqId != nil {
+			reqId = *approval.ReqId
+		}
+		switch approval.RequestType {
+		case approvals.NewPayment:
+			var npReq payments.NewPaymentRequest
+			if err := approval.Request.Unmarshal(&npReq); err != nil {
+				return nil, errors.Wrap(err, "Unmarshalling new payment request")
+			}
+			response, err := payments.SendNewPayment(eventChannel, db, c, npReq, reqId)
+			audit.Record(db, c, audit.SendNewPayment, npReq, response, err)
+			return response, err
+		case approvals.CloseChannel:
+			var ccReq channels.CloseChannelRequest
+			if err := approval.Request.Unmarshal(&ccReq); err != nil {
+				return nil, errors.Wrap(err, "Unmarshalling close channel request")
+			}
+			response, err := channels.CloseChannel(eventChannel, db, c, ccReq, reqId)
+			audit.Record(db, c, audit.CloseChannel, ccReq, response, err)
+			return response, err
+		case approvals.PayOnChain:
+			var pocReq on_chain_tx.PayOnChainRequest
+			if err := approval.Request.Unmarshal(&pocReq); err != nil {
+				return nil, errors.Wrap(err, "Unmarshalling pay on-chain request")
+			}
+			txId, err := on_chain_tx.PayOnChain(db, pocReq)
+			response := on_chain_tx.PayOnChainResponse{TxId: txId}
+			audit.Record(db, c, audit.PayOnChain, pocReq, response, err)
+			return response, err
+		}
+		return nil, errors.Newf("Unknown request type: %v", approval.RequestType)
+	}
+}
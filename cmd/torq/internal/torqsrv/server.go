@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/cockroachdb/errors"
@@ -16,7 +17,14 @@ import (
 	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
 	"github.com/ulule/limiter/v3/drivers/store/memory"
 
+	"github.com/lncapital/torq/build"
+	"github.com/lncapital/torq/internal/approvals"
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/internal/auto_fee"
+	"github.com/lncapital/torq/internal/backfill"
+	"github.com/lncapital/torq/internal/blocks"
+	"github.com/lncapital/torq/internal/channel_backups"
 	"github.com/lncapital/torq/internal/channel_history"
 	"github.com/lncapital/torq/internal/channel_tags"
 	"github.com/lncapital/torq/internal/channels"
@@ -29,13 +37,21 @@ import (
 	"github.com/lncapital/torq/internal/on_chain_tx"
 	"github.com/lncapital/torq/internal/payments"
 	"github.com/lncapital/torq/internal/peers"
+	"github.com/lncapital/torq/internal/rebalances"
+	"github.com/lncapital/torq/internal/reconciliation"
 	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/internal/spending_policies"
 	"github.com/lncapital/torq/internal/tags"
+	"github.com/lncapital/torq/internal/tokens"
+	"github.com/lncapital/torq/internal/users"
 	"github.com/lncapital/torq/internal/views"
+	"github.com/lncapital/torq/internal/webhooks"
 	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/metrics"
+	"github.com/lncapital/torq/pkg/openapi"
 )
 
-func Start(port int, apiPswd string, db *sqlx.DB, eventChannel chan interface{}, broadcaster broadcast.BroadcastServer, restartLNDSub func() error) error {
+func Start(port int, apiPswd string, totpWindow time.Duration, db *sqlx.DB, eventChannel chan interface{}, broadcaster broadcast.BroadcastServer, wsOptions broadcast.SubscriptionOptions, restartLNDSub func() error) error {
 	r := gin.Default()
 
 	log.Debug().Msg("Loading caches in memory.")
@@ -47,7 +63,7 @@ func Start(port int, apiPswd string, db *sqlx.DB, eventChannel chan interface{},
 
 	auth.CreateSession(r, apiPswd)
 
-	registerRoutes(r, db, apiPswd, eventChannel, broadcaster, restartLNDSub)
+	registerRoutes(r, db, apiPswd, totpWindow, eventChannel, broadcaster, wsOptions, restartLNDSub)
 
 	fmt.Println("Listening on port " + strconv.Itoa(port))
 
@@ -106,113 +122,225 @@ func equalASCIIFold(s, t string) bool {
 	return s == t
 }
 
-func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, eventChannel chan interface{}, broadcaster broadcast.BroadcastServer, restartLNDSub func() error) {
+func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, totpWindow time.Duration, eventChannel chan interface{}, broadcaster broadcast.BroadcastServer, wsOptions broadcast.SubscriptionOptions, restartLNDSub func() error) {
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 	applyCors(r)
 	// Websocket
 	ws := r.Group("/ws")
-	ws.Use(auth.AuthRequired)
+	ws.Use(auth.AuthRequired(db, users.Viewer, users.Viewer))
 	ws.GET("", func(c *gin.Context) {
-		err := WebsocketHandler(c, db, eventChannel, broadcaster)
+		err := WebsocketHandler(c, db, eventChannel, broadcaster, wsOptions, totpWindow)
 		log.Debug().Msgf("WebsocketHandler: %v", err)
 	})
 
+	// Prometheus metrics, scrapers authenticate with an API token.
+	r.GET("/metrics", auth.AuthRequired(db, users.Viewer, users.Viewer), metrics.Handler())
+
 	registerStaticRoutes(r)
 
 	api := r.Group("/api")
 
+	openApi := openapi.NewSpec("Torq API", build.Version())
+	validateRequest := openApi.ValidateRequest()
+
 	api.POST("/logout", auth.Logout)
 
 	// Limit login attempts to 10 per minute.
 	rl := NewLoginRateLimitMiddleware()
-	api.POST("/login", rl, auth.Login(apiPwd))
+	api.POST("/login", rl, auth.Login(db, apiPwd))
 
 	unauthorisedSettingRoutes := api.Group("settings")
 	{
 		settings.RegisterUnauthenticatedRoutes(unauthorisedSettingRoutes, db)
+		openApi.AddOperations(unauthorisedSettingRoutes.BasePath(), "settings", settings.UnauthenticatedOpenApiOperations())
 	}
 
-	api.Use(auth.AuthRequired)
 	{
-
-		tableViewRoutes := api.Group("/table-views")
+		tableViewRoutes := api.Group("/table-views", auth.AuthRequired(db, users.Viewer, users.Viewer), validateRequest)
 		{
 			views.RegisterTableViewRoutes(tableViewRoutes, db)
+			openApi.AddOperations(tableViewRoutes.BasePath(), "table-views", views.OpenApiOperations())
 		}
 
-		tagRoutes := api.Group("/tags")
+		tagRoutes := api.Group("/tags", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			tags.RegisterTagRoutes(tagRoutes, db)
+			openApi.AddOperations(tagRoutes.BasePath(), "tags", tags.OpenApiOperations())
 		}
 
-		channelTagRoutes := api.Group("/channelTags")
+		channelTagRoutes := api.Group("/channelTags", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			channel_tags.RegisterChannelTagRoutes(channelTagRoutes, db)
+			openApi.AddOperations(channelTagRoutes.BasePath(), "channelTags", channel_tags.OpenApiOperations())
 		}
 
-		corridorRoutes := api.Group("/corridors")
+		corridorRoutes := api.Group("/corridors", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			corridors.RegisterCorridorRoutes(corridorRoutes, db)
+			openApi.AddOperations(corridorRoutes.BasePath(), "corridors", corridors.OpenApiOperations())
 		}
 
-		paymentRoutes := api.Group("/payments")
+		paymentRoutes := api.Group("/payments", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			payments.RegisterPaymentsRoutes(paymentRoutes, db)
+			openApi.AddOperations(paymentRoutes.BasePath(), "payments", payments.OpenApiOperations())
 		}
 
-		invoiceRoutes := api.Group("/invoices")
+		invoiceRoutes := api.Group("/invoices", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			invoices.RegisterInvoicesRoutes(invoiceRoutes, db)
+			openApi.AddOperations(invoiceRoutes.BasePath(), "invoices", invoices.OpenApiOperations())
 		}
 
-		onChainTx := api.Group("/on-chain-tx")
+		onChainTx := api.Group("/on-chain-tx", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
-			on_chain_tx.RegisterOnChainTxsRoutes(onChainTx, db)
+			on_chain_tx.RegisterOnChainTxsRoutes(onChainTx, db, auth.TotpRequired(db, totpWindow))
+			openApi.AddOperations(onChainTx.BasePath(), "on-chain-tx", on_chain_tx.OpenApiOperations())
 		}
 
-		peerRoutes := api.Group("/peers")
+		peerRoutes := api.Group("/peers", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			peers.RegisterPeerRoutes(peerRoutes, db)
+			openApi.AddOperations(peerRoutes.BasePath(), "peers", peers.OpenApiOperations())
 		}
 
-		nodeRoutes := api.Group("/nodes")
+		nodeRoutes := api.Group("/nodes", auth.AuthRequired(db, users.Viewer, users.Admin), validateRequest)
 		{
 			nodes.RegisterNodeRoutes(nodeRoutes, db)
+			openApi.AddOperations(nodeRoutes.BasePath(), "nodes", nodes.OpenApiOperations())
 		}
 
-		channelRoutes := api.Group("/channels")
+		channelRoutes := api.Group("/channels", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			channel_history.RegisterChannelHistoryRoutes(channelRoutes, db)
+			openApi.AddOperations(channelRoutes.BasePath(), "channels", channel_history.OpenApiOperations())
 			channels.RegisterChannelRoutes(channelRoutes, db)
+			openApi.AddOperations(channelRoutes.BasePath(), "channels", channels.OpenApiOperations())
 		}
 
-		forwardRoutes := api.Group("/forwards")
+		forwardRoutes := api.Group("/forwards", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			forwards.RegisterForwardsRoutes(forwardRoutes, db)
+			openApi.AddOperations(forwardRoutes.BasePath(), "forwards", forwards.OpenApiOperations())
 		}
 
-		flowRoutes := api.Group("/flow")
+		flowRoutes := api.Group("/flow", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			flow.RegisterFlowRoutes(flowRoutes, db)
+			openApi.AddOperations(flowRoutes.BasePath(), "flow", flow.OpenApiOperations())
 		}
 
-		messageRoutes := api.Group("messages")
+		messageRoutes := api.Group("messages", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
 		{
 			messages.RegisterMessagesRoutes(messageRoutes, db)
+			openApi.AddOperations(messageRoutes.BasePath(), "messages", messages.OpenApiOperations())
+		}
+
+		approvalRoutes := api.Group("/approvals", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
+		{
+			approvals.RegisterApprovalRoutes(approvalRoutes, db, eventChannel, auth.TotpRequired(db, totpWindow),
+				executeApproval(db))
+			openApi.AddOperations(approvalRoutes.BasePath(), "approvals", approvals.OpenApiOperations())
+		}
+
+		spendingPolicyRoutes := api.Group("/spending-policies", auth.AuthRequired(db, users.Viewer, users.Admin), validateRequest)
+		{
+			spending_policies.RegisterSpendingPolicyRoutes(spendingPolicyRoutes, db)
+			openApi.AddOperations(spendingPolicyRoutes.BasePath(), "spending-policies", spending_policies.OpenApiOperations())
+		}
+
+		webhookRoutes := api.Group("/webhooks", auth.AuthRequired(db, users.Admin, users.Admin), validateRequest)
+		{
+			webhooks.RegisterWebhookRoutes(webhookRoutes, db)
+			openApi.AddOperations(webhookRoutes.BasePath(), "webhooks", webhooks.OpenApiOperations())
+		}
+
+		backfillRoutes := api.Group("/backfill", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
+		{
+			backfill.RegisterBackfillRoutes(backfillRoutes, db)
+			openApi.AddOperations(backfillRoutes.BasePath(), "backfill", backfill.OpenApiOperations())
+		}
+
+		reconciliationRoutes := api.Group("/reconciliation", auth.AuthRequired(db, users.Viewer, users.Operator),
+			validateRequest)
+		{
+			reconciliation.RegisterReconciliationRoutes(reconciliationRoutes, db)
+			openApi.AddOperations(reconciliationRoutes.BasePath(), "reconciliation", reconciliation.OpenApiOperations())
+		}
+
+		blockRoutes := api.Group("/blocks", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
+		{
+			blocks.RegisterBlockRoutes(blockRoutes, db)
+			openApi.AddOperations(blockRoutes.BasePath(), "blocks", blocks.OpenApiOperations())
+		}
+
+		channelBackupRoutes := api.Group("/channel-backups", auth.AuthRequired(db, users.Operator, users.Admin),
+			validateRequest)
+		{
+			channel_backups.RegisterChannelBackupRoutes(channelBackupRoutes, db, auth.RoleRequired(users.Admin),
+				auth.TotpRequired(db, totpWindow))
+			openApi.AddOperations(channelBackupRoutes.BasePath(), "channel-backups", channel_backups.OpenApiOperations())
 		}
 
-		settingRoutes := api.Group("settings")
+		autoFeeRoutes := api.Group("/auto-fee", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
+		{
+			auto_fee.RegisterAutoFeeRoutes(autoFeeRoutes, db, eventChannel)
+			openApi.AddOperations(autoFeeRoutes.BasePath(), "auto-fee", auto_fee.OpenApiOperations())
+		}
+
+		rebalanceRoutes := api.Group("/rebalances", auth.AuthRequired(db, users.Viewer, users.Operator), validateRequest)
+		{
+			rebalances.RegisterRebalanceRoutes(rebalanceRoutes, db, auth.TotpRequired(db, totpWindow))
+			openApi.AddOperations(rebalanceRoutes.BasePath(), "rebalances", rebalances.OpenApiOperations())
+		}
+
+		// Budgets limit what operators spend on rebalancing so only admins change them.
+		rebalanceBudgetRoutes := api.Group("/rebalance-budgets", auth.AuthRequired(db, users.Viewer, users.Admin),
+			validateRequest)
+		{
+			rebalances.RegisterRebalanceBudgetRoutes(rebalanceBudgetRoutes, db)
+			openApi.AddOperations(rebalanceBudgetRoutes.BasePath(), "rebalance-budgets",
+				rebalances.BudgetOpenApiOperations())
+		}
+
+		settingRoutes := api.Group("settings", auth.AuthRequired(db, users.Viewer, users.Admin), validateRequest)
 		{
 			settings.RegisterSettingRoutes(settingRoutes, db, restartLNDSub)
+			openApi.AddOperations(settingRoutes.BasePath(), "settings", settings.OpenApiOperations())
+		}
+
+		totpRoutes := api.Group("totp", auth.AuthRequired(db, users.Viewer, users.Viewer))
+		{
+			auth.RegisterTotpRoutes(totpRoutes, db)
 		}
 
-		api.GET("/ping", func(c *gin.Context) {
+		userRoutes := api.Group("users", auth.AuthRequired(db, users.Admin, users.Admin))
+		{
+			users.RegisterUserRoutes(userRoutes, db)
+		}
+
+		auditRoutes := api.Group("audit", auth.AuthRequired(db, users.Admin, users.Admin))
+		{
+			audit.RegisterAuditRoutes(auditRoutes, db)
+		}
+
+		apiTokenRoutes := api.Group("tokens", auth.AuthRequired(db, users.Admin, users.Admin))
+		{
+			tokens.RegisterApiTokenRoutes(apiTokenRoutes, db, auth.GetUserId)
+		}
+
+		api.GET("/ping", auth.AuthRequired(db, users.Viewer, users.Viewer), func(c *gin.Context) {
 			c.JSON(200, gin.H{
 				"message": "pong",
 			})
 		})
+
+		api.GET("/openapi.json", auth.AuthRequired(db, users.Viewer, users.Viewer), openApi.Handler)
 	}
 
+	// Routes without documentation are still listed with their path parameters.
+	openApi.AddRoutes(r.Routes())
+
 }
 
 func registerStaticRoutes(r *gin.Engine) {
@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/lncapital/torq/internal/approvals"
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/auth"
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/on_chain_tx"
 	"github.com/lncapital/torq/internal/payments"
+	"github.com/lncapital/torq/internal/users"
 	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/server_errors"
 
 	"github.com/rs/zerolog/log"
 )
@@ -26,7 +33,23 @@ type wsRequest struct {
 	OpenChannelRequest  *channels.OpenChannelRequest   `json:"openChannelRequest"`
 	CloseChannelRequest *channels.CloseChannelRequest  `json:"closeChannelRequest"`
 	Password            *string                        `json:"password"`
+	Totp                *string                        `json:"totp"`
 	NewAddressRequest   *on_chain_tx.NewAddressRequest `json:"newAddressRequest"`
+	Subscription        *wsSubscription                `json:"subscription"`
+	// SubscriptionId of the subscription to remove, all subscriptions are removed when it's empty.
+	SubscriptionId *string `json:"subscriptionId"`
+	// FromSeq is the seq of the last event the client received before it needs to resume.
+	FromSeq *int64 `json:"fromSeq"`
+}
+
+// wsClient is the state of a single websocket connection.
+type wsClient struct {
+	subscriptions *wsSubscriptions
+	resumes       chan wsResume
+}
+
+func newWsClient() *wsClient {
+	return &wsClient{subscriptions: newWsSubscriptions(), resumes: make(chan wsResume, 1)}
 }
 
 type Pong struct {
@@ -38,12 +61,28 @@ type AuthSuccess struct {
 }
 
 type wsError struct {
-	ReqId string `json:"id"`
-	Type  string `json:"type"`
-	Error string `json:"error"`
+	ReqId       string                     `json:"id"`
+	Type        string                     `json:"type"`
+	Error       string                     `json:"error"`
+	ServerError *server_errors.ServerError `json:"serverError,omitempty"`
+}
+
+// newWsError keeps the structure of a ServerError (e.g. a spending policy violation) for the front end.
+func newWsError(reqId string, err error) wsError {
+	wsErr := wsError{
+		ReqId: reqId,
+		Type:  "Error",
+		Error: err.Error(),
+	}
+	var serverError *server_errors.ServerError
+	if errors.As(err, &serverError) {
+		wsErr.ServerError = serverError
+	}
+	return wsErr
 }
 
-func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel chan interface{}, req wsRequest) {
+func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel chan interface{}, req wsRequest,
+	totpWindow time.Duration, client *wsClient) {
 	if req.Type == "ping" {
 		webSocketChannel <- Pong{Message: "pong"}
 		return
@@ -59,7 +98,76 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 		return
 	}
 
+	if !auth.GetRole(c).HasRole(requiredWsRole(req.Type)) {
+		webSocketChannel <- wsError{
+			ReqId: req.ReqId,
+			Type:  "Error",
+			Error: fmt.Sprintf("Not allowed to send request type: %s", req.Type),
+		}
+		return
+	}
+
+	if requiresTotp(req.Type) {
+		totpCode := ""
+		if req.Totp != nil {
+			totpCode = *req.Totp
+		}
+		if err := auth.CheckTotp(c, db, totpWindow, totpCode); err != nil {
+			webSocketChannel <- wsError{
+				ReqId: req.ReqId,
+				Type:  "Error",
+				Error: err.Error(),
+			}
+			return
+		}
+	}
+
 	switch req.Type {
+	case "subscribe":
+		if req.Subscription == nil {
+			webSocketChannel <- wsError{
+				ReqId: req.ReqId,
+				Type:  "Error",
+				Error: "subscription cannot be empty",
+			}
+			break
+		}
+		if serverError := req.Subscription.validate(); serverError != nil {
+			webSocketChannel <- newWsError(req.ReqId, serverError)
+			break
+		}
+		subscription := *req.Subscription
+		// The reqId of the subscribe request identifies the subscription.
+		subscription.SubscriptionId = req.ReqId
+		client.subscriptions.subscribe(subscription)
+		webSocketChannel <- wsSubscriptionsResponse{ReqId: req.ReqId, Type: req.Type, Subscriptions: client.subscriptions.list()}
+	case "unsubscribe":
+		subscriptionId := ""
+		if req.SubscriptionId != nil {
+			subscriptionId = *req.SubscriptionId
+		}
+		client.subscriptions.unsubscribe(subscriptionId)
+		webSocketChannel <- wsSubscriptionsResponse{ReqId: req.ReqId, Type: req.Type, Subscriptions: client.subscriptions.list()}
+	case "listSubscriptions":
+		webSocketChannel <- wsSubscriptionsResponse{ReqId: req.ReqId, Type: req.Type, Subscriptions: client.subscriptions.list()}
+	case "resume":
+		if req.FromSeq == nil {
+			webSocketChannel <- wsError{
+				ReqId: req.ReqId,
+				Type:  "Error",
+				Error: "fromSeq cannot be empty",
+			}
+			break
+		}
+		select {
+		case client.resumes <- wsResume{ReqId: req.ReqId, FromSeq: *req.FromSeq}:
+		default:
+			webSocketChannel <- wsError{
+				ReqId: req.ReqId,
+				Type:  "Error",
+				Error: "A resume is already in progress",
+			}
+		}
 	case "newPayment":
 		if req.NewPaymentRequest == nil {
 			webSocketChannel <- wsError{
@@ -69,14 +177,17 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 			}
 			break
 		}
+		if requestApproval(db, c, webSocketChannel, req.ReqId, approvals.NewPayment, req.NewPaymentRequest.NodeId,
+			req.NewPaymentRequest, func() (int64, error) {
+				return payments.GetPaymentAmountMsat(db, *req.NewPaymentRequest)
+			}) {
+			break
+		}
 		// Process a valid payment request
-		err := payments.SendNewPayment(eventChannel, db, c, *req.NewPaymentRequest, req.ReqId)
+		response, err := payments.SendNewPayment(eventChannel, db, c, *req.NewPaymentRequest, req.ReqId)
+		audit.Record(db, c, audit.SendNewPayment, req.NewPaymentRequest, response, err)
 		if err != nil {
-			webSocketChannel <- wsError{
-				ReqId: req.ReqId,
-				Type:  "Error",
-				Error: err.Error(),
-			}
+			webSocketChannel <- newWsError(req.ReqId, err)
 		}
 	case "newAddress":
 		if req.NewAddressRequest == nil {
@@ -105,8 +216,16 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 			}
 			break
 		}
+		if requestApproval(db, c, webSocketChannel, req.ReqId, approvals.CloseChannel, req.CloseChannelRequest.NodeId,
+			req.CloseChannelRequest, func() (int64, error) {
+				capacity, err := channels.GetCapacityByChannelPoint(db, req.CloseChannelRequest.ChannelPoint)
+				return capacity * 1000, err
+			}) {
+			break
+		}
 		// Process a valid payment request
-		err := channels.CloseChannel(eventChannel, db, c, *req.CloseChannelRequest, req.ReqId)
+		response, err := channels.CloseChannel(eventChannel, db, c, *req.CloseChannelRequest, req.ReqId)
+		audit.Record(db, c, audit.CloseChannel, req.CloseChannelRequest, response, err)
 		if err != nil {
 			webSocketChannel <- wsError{
 				ReqId: req.ReqId,
@@ -123,13 +242,10 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 			}
 			break
 		}
-		err := channels.OpenChannel(db, eventChannel, *req.OpenChannelRequest, req.ReqId)
+		response, err := channels.OpenChannel(db, eventChannel, *req.OpenChannelRequest, req.ReqId)
+		audit.Record(db, c, audit.OpenChannel, req.OpenChannelRequest, response, err)
 		if err != nil {
-			webSocketChannel <- wsError{
-				ReqId: req.ReqId,
-				Type:  "Error",
-				Error: err.Error(),
-			}
+			webSocketChannel <- newWsError(req.ReqId, err)
 		}
 	default:
 		err := fmt.Errorf("Unknown request type: %s", req.Type)
@@ -141,7 +257,52 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 	}
 }
 
-func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}, broadcaster broadcast.BroadcastServer) error {
+// requiredWsRole returns the role required to process a websocket request type.
+// Unknown request types require an admin so new request types are never accidentally open to viewers.
+func requiredWsRole(reqType string) users.Role {
+	switch reqType {
+	case "ping", "subscribe", "unsubscribe", "listSubscriptions", "resume":
+		return users.Viewer
+	case "newPayment", "newAddress", "closeChannel", "openChannel":
+		return users.Operator
+	}
+	return users.Admin
+}
+
+// requestApproval queues a request above the approval threshold of the node until a second user approves it.
+// Returns true when the request must not be processed now.
+func requestApproval(db *sqlx.DB, c *gin.Context, webSocketChannel chan interface{}, reqId string,
+	requestType approvals.RequestType, nodeId int, request interface{}, getAmountMsat func() (int64, error)) bool {
+
+	requiresApproval, amountMsat, err := approvals.RequiresApproval(db, requestType, nodeId, getAmountMsat)
+	if err != nil {
+		webSocketChannel <- newWsError(reqId, err)
+		return true
+	}
+	if !requiresApproval {
+		return false
+	}
+	approval, err := approvals.AddApproval(db, c, requestType, nodeId, amountMsat, request, reqId)
+	audit.Record(db, c, audit.RequestApproval, request, approval, err)
+	if err != nil {
+		webSocketChannel <- newWsError(reqId, err)
+		return true
+	}
+	webSocketChannel <- approval.Event()
+	return true
+}
+
+// requiresTotp returns true for request types that move funds.
+func requiresTotp(reqType string) bool {
+	switch reqType {
+	case "newPayment", "closeChannel", "openChannel":
+		return true
+	}
+	return false
+}
+
+func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}, broadcaster broadcast.BroadcastServer,
+	subscriptionOptions broadcast.SubscriptionOptions, totpWindow time.Duration) error {
 	var wsUpgrade = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
@@ -162,6 +323,17 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 		},
 	}
 
+	// Resuming when connecting makes sure the missed events are sent before any live event.
+	var connectResume *wsResume
+	if c.Query("resumeFrom") != "" {
+		fromSeq, err := strconv.ParseInt(c.Query("resumeFrom"), 10, 64)
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse resumeFrom in the request.")
+			return errors.Wrap(err, "Parsing resumeFrom")
+		}
+		connectResume = &wsResume{FromSeq: fromSeq}
+	}
+
 	conn, err := wsUpgrade.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return errors.Wrap(err, "WebSocket upgrade.")
@@ -169,6 +341,12 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 	defer conn.Close()
 
 	webSocketChannel := make(chan interface{})
+	client := newWsClient()
+	// The broadcaster never waits for this client, events are dropped or the client is disconnected instead.
+	subscription := broadcaster.Subscribe(subscriptionOptions)
+	defer broadcaster.CancelSubscription(subscription)
+	// slowConsumer is closed when the broadcaster disconnected the client for not keeping up.
+	slowConsumer := make(chan struct{})
 
 	done := make(chan struct{})
 	go func() {
@@ -184,7 +362,7 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 				log.Debug().Err(err).Msg("WebSocket Handshake Error.")
 				return
 			case nil:
-				go processWsReq(db, c, eventChannel, webSocketChannel, req)
+				go processWsReq(db, c, eventChannel, webSocketChannel, req, totpWindow, client)
 			default:
 				wsr := wsError{
 					ReqId: req.ReqId,
@@ -197,32 +375,43 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 	}()
 
 	go func() {
-		for event := range broadcaster.Subscribe() {
+		send := func(message interface{}) bool {
+			select {
+			case <-done:
+				return false
+			case webSocketChannel <- message:
+				return true
+			}
+		}
+		// lastSeq is the highest seq sent, live events that were already replayed are skipped.
+		var lastSeq int64
+		if connectResume != nil {
+			lastSeq = replayEvents(db, *connectResume, client.subscriptions, lastSeq, send)
+		}
+		for {
 			select {
 			case <-done:
 				return
-			default:
-				// TODO FIXME FILTER OUT ONLY THE EVENTS THE USER ACTUALLY WANTS!!!
-				if nodeGraphEvent, ok := event.(broadcast.NodeGraphEvent); ok {
-					webSocketChannel <- nodeGraphEvent
-				} else if channelGraphEvent, ok := event.(broadcast.ChannelGraphEvent); ok {
-					webSocketChannel <- channelGraphEvent
-				} else if transactionEvent, ok := event.(broadcast.TransactionEvent); ok {
-					webSocketChannel <- transactionEvent
-				} else if channelEvent, ok := event.(broadcast.ChannelEvent); ok {
-					webSocketChannel <- channelEvent
-				} else if invoiceEvent, ok := event.(broadcast.InvoiceEvent); ok {
-					webSocketChannel <- invoiceEvent
-				} else if peerEvent, ok := event.(broadcast.PeerEvent); ok {
-					webSocketChannel <- peerEvent
-				} else if openChannelEvent, ok := event.(channels.OpenChannelResponse); ok {
-					webSocketChannel <- openChannelEvent
-				} else if closeChannelEvent, ok := event.(channels.CloseChannelResponse); ok {
-					webSocketChannel <- closeChannelEvent
-				} else if newAddressEvent, ok := event.(on_chain_tx.NewAddressResponse); ok {
-					webSocketChannel <- newAddressEvent
-				} else if newPaymentEvent, ok := event.(payments.NewPaymentResponse); ok {
-					webSocketChannel <- newPaymentEvent
+			case resume := <-client.resumes:
+				lastSeq = replayEvents(db, resume, client.subscriptions, lastSeq, send)
+			case event, ok := <-subscription.Events():
+				if !ok {
+					if subscription.Disconnected() {
+						close(slowConsumer)
+					}
+					return
+				}
+				if event.Seq != 0 && event.Seq <= lastSeq {
+					continue
+				}
+				if !client.subscriptions.wants(event) {
+					continue
+				}
+				if !send(event) {
+					return
+				}
+				if event.Seq != 0 {
+					lastSeq = event.Seq
 				}
 			}
 		}
@@ -232,6 +421,15 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 		select {
 		case <-done:
 			return errors.New("WebSocket Terminated.")
+		case <-slowConsumer:
+			log.Info().Msgf("Disconnecting slow WebSocket client after %v dropped events.", subscription.Dropped())
+			closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation,
+				"Too slow to receive events, reconnect with resumeFrom to receive the missed events.")
+			err := conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+			if err != nil {
+				log.Debug().Err(err).Msg("Writing close message to WebSocket.")
+			}
+			return errors.New("WebSocket client too slow.")
 		case data := <-webSocketChannel:
 			err := conn.WriteJSON(data)
 			if err != nil {
@@ -0,0 +1,81 @@
+package torqsrv
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/event_log"
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+// wsResume asks to send the events after FromSeq before any new live event.
+type wsResume struct {
+	ReqId   string
+	FromSeq int64
+}
+
+// wsGap tells the client events were lost because they are no longer in the event log.
+type wsGap struct {
+	ReqId string `json:"reqId"`
+	Type  string `json:"type"`
+	event_log.Gap
+}
+
+// wsResumed marks the end of the replayed events, LastSeq is the seq of the last replayed event.
+type wsResumed struct {
+	ReqId   string `json:"reqId"`
+	Type    string `json:"type"`
+	LastSeq int64  `json:"lastSeq"`
+}
+
+// replayEvents sends the logged events after the requested seq that match the subscriptions of the client, every gap
+// is sent before the first event after it. It returns the highest seq sent so live events that were also replayed can
+// be skipped.
+func replayEvents(db *sqlx.DB, resume wsResume, subscriptions *wsSubscriptions, lastSeq int64,
+	send func(interface{}) bool) int64 {
+
+	events, gaps, err := event_log.GetEventsAfter(db, resume.FromSeq)
+	if err != nil {
+		log.Error().Err(err).Msgf("Getting events after seq %v", resume.FromSeq)
+		send(wsError{ReqId: resume.ReqId, Type: "Error", Error: "Could not get the events to resume from."})
+		return lastSeq
+	}
+	resumedSeq := resume.FromSeq
+	// sendGapsBefore sends the gaps starting before seq that weren't sent yet.
+	sendGapsBefore := func(seq int64) bool {
+		for len(gaps) != 0 && gaps[0].FromSeq < seq {
+			if !send(wsGap{ReqId: resume.ReqId, Type: "gap", Gap: gaps[0]}) {
+				return false
+			}
+			resumedSeq = 0
+			if gaps[0].FirstAvailableSeq > 0 {
+				resumedSeq = gaps[0].FirstAvailableSeq - 1
+			}
+			gaps = gaps[1:]
+		}
+		return true
+	}
+	for _, event := range events {
+		if !sendGapsBefore(event.Seq) {
+			return lastSeq
+		}
+		resumedSeq = event.Seq
+		if !subscriptions.wantsDescription(event.Description()) {
+			continue
+		}
+		if !send(json.RawMessage(broadcast.WithSeq(event.Seq, event.Payload))) {
+			return lastSeq
+		}
+	}
+	if !sendGapsBefore(math.MaxInt64) {
+		return lastSeq
+	}
+	send(wsResumed{ReqId: resume.ReqId, Type: "resumed", LastSeq: resumedSeq})
+	if resumedSeq > lastSeq {
+		return resumedSeq
+	}
+	return lastSeq
+}
@@ -0,0 +1,132 @@
+package torqsrv
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lncapital/torq/internal/event_log"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+// wsSubscription selects the broadcast events sent to a websocket client.
+// Empty filters match everything, events without a node or channel don't match a node or channel filter.
+type wsSubscription struct {
+	SubscriptionId string   `json:"subscriptionId"`
+	EventTypes     []string `json:"eventTypes"`
+	NodeIds        []int    `json:"nodeIds"`
+	ChannelIds     []int    `json:"channelIds"`
+}
+
+type wsSubscriptionsResponse struct {
+	ReqId         string           `json:"reqId"`
+	Type          string           `json:"type"`
+	Subscriptions []wsSubscription `json:"subscriptions"`
+}
+
+func (subscription wsSubscription) matches(description event_log.Description) bool {
+	return containsString(subscription.EventTypes, string(description.EventType)) &&
+		containsInt(subscription.NodeIds, description.NodeId) &&
+		containsInt(subscription.ChannelIds, description.ChannelId)
+}
+
+func (subscription wsSubscription) validate() *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	for _, eventType := range subscription.EventTypes {
+		if !event_log.EventType(eventType).IsValid() {
+			serverError.AddFieldError("eventTypes", fmt.Sprintf("Unknown event type: %v", eventType))
+		}
+	}
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	return serverError
+}
+
+// wsSubscriptions are the subscriptions of a single websocket client.
+// A client without subscriptions receives every event, as before subscriptions existed.
+type wsSubscriptions struct {
+	mu            sync.RWMutex
+	subscriptions map[string]wsSubscription
+}
+
+func newWsSubscriptions() *wsSubscriptions {
+	return &wsSubscriptions{subscriptions: make(map[string]wsSubscription)}
+}
+
+func (s *wsSubscriptions) subscribe(subscription wsSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[subscription.SubscriptionId] = subscription
+}
+
+// unsubscribe removes a single subscription or all of them when subscriptionId is empty.
+func (s *wsSubscriptions) unsubscribe(subscriptionId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if subscriptionId == "" {
+		s.subscriptions = make(map[string]wsSubscription)
+		return
+	}
+	delete(s.subscriptions, subscriptionId)
+}
+
+func (s *wsSubscriptions) list() []wsSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subscriptions := make([]wsSubscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].SubscriptionId < subscriptions[j].SubscriptionId
+	})
+	return subscriptions
+}
+
+// wants returns true when the broadcast event has to be sent to the client.
+func (s *wsSubscriptions) wants(event interface{}) bool {
+	description, ok := event_log.Describe(event)
+	if !ok {
+		return false
+	}
+	return s.wantsDescription(description)
+}
+
+func (s *wsSubscriptions) wantsDescription(description event_log.Description) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.subscriptions) == 0 {
+		return true
+	}
+	for _, subscription := range s.subscriptions {
+		if subscription.matches(description) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(filter []int, value int) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package torqsrv
+
+import (
+	"testing"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+func TestWsSubscriptionsWants(t *testing.T) {
+	channelId := 7
+	invoiceNode1 := broadcast.InvoiceEvent{EventData: broadcast.EventData{NodeId: 1}}
+	invoiceNode2 := broadcast.InvoiceEvent{EventData: broadcast.EventData{NodeId: 2}}
+	channelEvent := broadcast.ChannelEvent{EventData: broadcast.EventData{NodeId: 1}, ChannelId: channelId}
+	channelGraphEvent := broadcast.ChannelGraphEvent{GraphEventData: broadcast.GraphEventData{
+		EventData: broadcast.EventData{NodeId: 2}, ChannelId: &channelId}}
+	openChannelResponse := channels.OpenChannelResponse{ReqId: "1"}
+
+	tests := []struct {
+		name          string
+		subscriptions []wsSubscription
+		event         interface{}
+		want          bool
+	}{
+		{"No subscriptions receive everything", nil, invoiceNode2, true},
+		{"Unknown events are never sent", nil, "unknown", false},
+		{"Event type and node match",
+			[]wsSubscription{{SubscriptionId: "a", EventTypes: []string{"invoice"}, NodeIds: []int{1}}},
+			invoiceNode1, true},
+		{"Node doesn't match",
+			[]wsSubscription{{SubscriptionId: "a", EventTypes: []string{"invoice"}, NodeIds: []int{1}}},
+			invoiceNode2, false},
+		{"Event type doesn't match",
+			[]wsSubscription{{SubscriptionId: "a", EventTypes: []string{"invoice"}}},
+			channelEvent, false},
+		{"Channel matches any event type",
+			[]wsSubscription{{SubscriptionId: "a", ChannelIds: []int{channelId}}},
+			channelGraphEvent, true},
+		{"Events without channel don't match a channel filter",
+			[]wsSubscription{{SubscriptionId: "a", ChannelIds: []int{channelId}}},
+			invoiceNode1, false},
+		{"Second subscription matches",
+			[]wsSubscription{
+				{SubscriptionId: "a", EventTypes: []string{"invoice"}},
+				{SubscriptionId: "b", EventTypes: []string{"openChannel"}},
+			},
+			openChannelResponse, true},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscriptions := newWsSubscriptions()
+			for _, subscription := range test.subscriptions {
+				subscriptions.subscribe(subscription)
+			}
+			got := subscriptions.wants(test.event)
+			if got != test.want {
+				t.Errorf("%d: wants()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWsSubscriptionsUnsubscribe(t *testing.T) {
+	subscriptions := newWsSubscriptions()
+	subscriptions.subscribe(wsSubscription{SubscriptionId: "b", EventTypes: []string{"invoice"}})
+	subscriptions.subscribe(wsSubscription{SubscriptionId: "a", EventTypes: []string{"peer"}})
+
+	got := subscriptions.list()
+	if len(got) != 2 || got[0].SubscriptionId != "a" || got[1].SubscriptionId != "b" {
+		t.Errorf("list()\nGot:\n%v\nWant:\n%v\n", got, "subscriptions a and b")
+	}
+
+	subscriptions.unsubscribe("a")
+	got = subscriptions.list()
+	if len(got) != 1 || got[0].SubscriptionId != "b" {
+		t.Errorf("unsubscribe(a)\nGot:\n%v\nWant:\n%v\n", got, "subscription b")
+	}
+
+	subscriptions.unsubscribe("")
+	got = subscriptions.list()
+	if len(got) != 0 {
+		t.Errorf("unsubscribe()\nGot:\n%v\nWant:\n%v\n", got, "no subscriptions")
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
@@ -17,12 +21,24 @@ import (
 	"github.com/lncapital/torq/build"
 	"github.com/lncapital/torq/cmd/torq/internal/subscribe"
 	"github.com/lncapital/torq/cmd/torq/internal/torqsrv"
+	"github.com/lncapital/torq/internal/approvals"
+	"github.com/lncapital/torq/internal/auto_fee"
+	"github.com/lncapital/torq/internal/backfill"
+	"github.com/lncapital/torq/internal/channel_backups"
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/internal/event_log"
+	"github.com/lncapital/torq/internal/rebalances"
+	"github.com/lncapital/torq/internal/reconciliation"
 	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/internal/webhooks"
 	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/cln"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
 	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/metrics"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 var eventChannel = make(chan interface{}) //nolint:gochecknoglobals
@@ -120,6 +136,63 @@ func main() {
 			Value: false,
 			Usage: "Start the server without subscribing to node data.",
 		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  "torq.totp-window",
+			Value: 5 * time.Minute,
+			Usage: "How long a TOTP verification allows payments, channel opens and closes without a new code.",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:  "torq.event-log-size",
+			Value: event_log.DefaultSize,
+			Usage: "Number of recent events kept for websocket clients that resume after a disconnect.",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:  "torq.ws-buffer-size",
+			Value: broadcast.DefaultBufferSize,
+			Usage: "Number of events buffered for each websocket client before the overflow policy applies.",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  "torq.ws-overflow-policy",
+			Value: string(broadcast.Disconnect),
+			Usage: "What to do when the buffer of a slow websocket client is full: dropOldest, dropNewest or disconnect.",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:  "torq.backfill-pages-per-second",
+			Value: lnd.DefaultBackfillPagesPerSecond,
+			Usage: "Maximum number of requests per second a backfill job sends to a node",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  "torq.reconcile-interval",
+			Value: lnd.DefaultReconcileInterval,
+			Usage: "How often the database is compared with each node and fixed, 0 only reconciles on request",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  "torq.auto-fee-interval",
+			Value: auto_fee.DefaultInterval,
+			Usage: "How often the fees of channels with an enabled AutoFee corridor are updated, 0 disables it",
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:  "torq.auto-fee-dry-run",
+			Usage: "Record the decisions of the fee automation without updating any channel policy",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  "torq.reconcile-window",
+			Value: reconciliation.DefaultWindow,
+			Usage: "How far back payments, invoices and forwards are compared with the node",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  "torq.metrics-interval",
+			Value: channels.DefaultMetricsInterval,
+			Usage: "How often the channel balances exported on /metrics are refreshed from the nodes, 0 disables them",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  "torq.backup-key",
+			Usage: "Secret that encrypts the stored channel backups, channel backups are not stored without it",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  "torq.record-dir",
+			Usage: "Record everything the node subscriptions receive to a file per node in this directory, see the replay command.",
+		}),
 
 		// Torq database
 		altsrc.NewStringFlag(&cli.StringFlag{
@@ -218,7 +291,37 @@ func main() {
 
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
-			broadcaster := broadcast.NewBroadcastServer(ctx, eventChannel)
+			broadcaster := broadcast.NewBroadcastServer(ctx,
+				event_log.Start(ctx, db, eventChannel, c.Int("torq.event-log-size")))
+			go webhooks.Start(ctx, db, broadcaster)
+			go backfill.Start(ctx, db, eventChannel,
+				backfillRunner(db, eventChannel, c.Int("torq.backfill-pages-per-second")))
+			go reconciliation.Start(ctx, db, eventChannel, c.Duration("torq.reconcile-interval"),
+				c.Duration("torq.reconcile-window"), reconcileRunner(db))
+			go auto_fee.Start(ctx, db, eventChannel, c.Duration("torq.auto-fee-interval"),
+				c.Bool("torq.auto-fee-dry-run"))
+			go rebalances.Start(ctx, db, eventChannel)
+			if err = approvals.FailInterruptedApprovals(db); err != nil {
+				log.Error().Err(err).Msg("Failing interrupted approvals")
+			}
+			channelMetrics := channels.NewMetricsCollector(db)
+			metrics.Default.MustRegister(channelMetrics)
+			go channelMetrics.Start(ctx, c.Duration("torq.metrics-interval"))
+
+			if c.String("torq.backup-key") == "" {
+				log.Warn().Msg("Channel backups are not stored, set torq.backup-key to store them encrypted.")
+			}
+			channel_backups.SetEncryptionKey(c.String("torq.backup-key"))
+
+			wsOverflowPolicy, err := broadcast.ParseOverflowPolicy(c.String("torq.ws-overflow-policy"))
+			if err != nil {
+				return errors.Wrap(err, "Parsing torq.ws-overflow-policy")
+			}
+			wsSubscriptionOptions := broadcast.SubscriptionOptions{
+				Name:       "websocket",
+				BufferSize: c.Int("torq.ws-buffer-size"),
+				Policy:     wsOverflowPolicy,
+			}
 
 			// if node specified on cmd flags then check if we already know about it
 			if c.String("lnd.url") != "" && c.String("lnd.macaroon-path") != "" && c.String("lnd.tls-path") != "" {
@@ -279,25 +382,59 @@ func main() {
 								ctx := context.Background()
 								ctx, cancel := context.WithCancel(ctx)
 
-								log.Info().Msgf("Subscribing to LND for node id: %v", node.NodeId)
 								runningSubscriptions.AddSubscription(node.NodeId, cancel)
-								conn, err := lnd_connect.Connect(
-									node.GRPCAddress,
-									node.TLSFileBytes,
-									node.MacaroonFileBytes,
-								)
-								if err != nil {
-									log.Error().Err(err).Msgf("Failed to connect to lnd for node id: %v", node.NodeId)
-									runningSubscriptions.RemoveSubscription(node.NodeId)
-									return
+								var client node_client.NodeClient
+								if node.Implementation == commons.CLN {
+									log.Info().Msgf("Subscribing to CLN for node id: %v", node.NodeId)
+									clnClient, err := settings.GetClnClient(db, node.NodeId)
+									if err != nil {
+										log.Error().Err(err).Msgf("Failed to connect to cln for node id: %v", node.NodeId)
+										runningSubscriptions.RemoveSubscription(node.NodeId)
+										return
+									}
+									client = cln.NewLightning(clnClient)
+								} else {
+									log.Info().Msgf("Subscribing to LND for node id: %v", node.NodeId)
+									conn, err := lnd_connect.Connect(
+										node.GRPCAddress,
+										node.TLSFileBytes,
+										node.MacaroonFileBytes,
+									)
+									if err != nil {
+										log.Error().Err(err).Msgf("Failed to connect to lnd for node id: %v", node.NodeId)
+										runningSubscriptions.RemoveSubscription(node.NodeId)
+										return
+									}
+									client = node_client.NewLndClient(conn)
 								}
 
-								err = subscribe.Start(ctx, conn, db, node.NodeId, eventChannel)
+								if recordDir := c.String("torq.record-dir"); recordDir != "" {
+									path := filepath.Join(recordDir, fmt.Sprintf("node-%d-%s.jsonl.gz", node.NodeId,
+										time.Now().UTC().Format("20060102T150405Z")))
+									recording, err := node_client.CreateRecordingFile(path)
+									if err != nil {
+										log.Error().Err(err).Msgf("Failed to record the subscriptions of node id: %v", node.NodeId)
+									} else {
+										log.Info().Msgf("Recording the subscriptions of node id: %v to %v", node.NodeId, path)
+										recorder := node_client.NewRecorder(client, recording)
+										client = recorder
+										defer func() {
+											if err := recorder.Err(); err != nil {
+												log.Error().Err(err).Msgf("Recording to %v", path)
+											}
+											if err := recording.Close(); err != nil {
+												log.Error().Err(err).Msgf("Closing recording %v", path)
+											}
+										}()
+									}
+								}
+
+								err := subscribe.Start(ctx, client, db, node.NodeId, eventChannel)
 								if err != nil {
 									log.Error().Err(err).Send()
 									// only log the error, don't return
 								}
-								log.Info().Msgf("LND Subscription stopped for node id: %v", node.NodeId)
+								log.Info().Msgf("Subscription stopped for node id: %v", node.NodeId)
 								runningSubscriptions.RemoveSubscription(node.NodeId)
 							})(node)
 						}
@@ -320,7 +457,7 @@ func main() {
 
 			}
 
-			if err = torqsrv.Start(c.Int("torq.port"), c.String("torq.password"), db, eventChannel, broadcaster, RestartLNDSubscription); err != nil {
+			if err = torqsrv.Start(c.Int("torq.port"), c.String("torq.password"), c.Duration("torq.totp-window"), db, eventChannel, broadcaster, wsSubscriptionOptions, RestartLNDSubscription); err != nil {
 				return errors.Wrap(err, "Starting torq webserver")
 			}
 
@@ -354,6 +491,179 @@ func main() {
 		},
 	}
 
+	replay := &cli.Command{
+		Name:  "replay",
+		Usage: "Store a recording made with torq.record-dir in the database like the node subscriptions would",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Required: true,
+				Usage:    "Path of the recording",
+			},
+			&cli.IntFlag{
+				Name:  "node-id",
+				Value: 1,
+				Usage: "Node id the recording is stored for",
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Value: 0,
+				Usage: "1 replays with the recorded delays, 2 twice as fast and 0 as fast as possible",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			db, err := database.PgConnect(c.String("db.name"), c.String("db.user"),
+				c.String("db.password"), c.String("db.host"), c.String("db.port"))
+			if err != nil {
+				return errors.Wrap(err, "replay cmd")
+			}
+
+			defer func() {
+				cerr := db.Close()
+				if err == nil {
+					err = cerr
+				}
+			}()
+
+			err = database.MigrateUp(db)
+			if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+				return err
+			}
+
+			if err = initializeManagedCaches(db); err != nil {
+				return err
+			}
+
+			recording, err := node_client.OpenRecordingFile(c.String("file"))
+			if err != nil {
+				return err
+			}
+			defer recording.Close()
+			replayer, err := node_client.NewReplayer(recording, c.Float64("speed"))
+			if err != nil {
+				return err
+			}
+
+			// Polled responses (i.e. ForwardingHistory and ListPayments) are requested at the interval of their
+			// polling loop so the replay can be stopped early, what wasn't consumed is reported.
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			defer func() {
+				for method, count := range replayer.Unconsumed() {
+					log.Warn().Msgf("%v recorded %v responses were not consumed", count, method)
+				}
+			}()
+			done := make(chan error, 1)
+			go func() { done <- subscribe.Start(ctx, replayer, db, c.Int("node-id"), nil) }()
+			select {
+			case <-replayer.Done():
+				fmt.Println("Every recorded response is stored")
+				cancel()
+				return <-done
+			case <-ctx.Done():
+				fmt.Printf("Replay interrupted with %v recorded responses remaining\n", replayer.Remaining())
+				return <-done
+			case err = <-done:
+				return errors.Wrap(err, "Replaying recording")
+			}
+		},
+	}
+
+	backfillCmd := &cli.Command{
+		Name:  "backfill",
+		Usage: "Import the forwards, payments, invoices and on-chain transactions of a node for a date range",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "node-id",
+				Usage: "Node id to import the history of",
+			},
+			&cli.StringFlag{
+				Name:  "from",
+				Usage: "Start of the date range as 2006-01-02 or RFC 3339 time",
+			},
+			&cli.StringFlag{
+				Name:  "to",
+				Usage: "End of the date range (exclusive) as 2006-01-02 or RFC 3339 time, defaults to now",
+			},
+			&cli.StringSliceFlag{
+				Name:  "kinds",
+				Usage: "What to import: forwards, payments, invoices and/or transactions, defaults to all",
+			},
+			&cli.IntFlag{
+				Name:  "job-id",
+				Usage: "Continue an interrupted or failed backfill job instead of starting a new one",
+			},
+			&cli.IntFlag{
+				Name:  "pages-per-second",
+				Value: lnd.DefaultBackfillPagesPerSecond,
+				Usage: "Maximum number of requests to the node per second",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			db, err := database.PgConnect(c.String("db.name"), c.String("db.user"),
+				c.String("db.password"), c.String("db.host"), c.String("db.port"))
+			if err != nil {
+				return errors.Wrap(err, "backfill cmd")
+			}
+
+			defer func() {
+				cerr := db.Close()
+				if err == nil {
+					err = cerr
+				}
+			}()
+
+			err = database.MigrateUp(db)
+			if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+				return err
+			}
+
+			if err = initializeManagedCaches(db); err != nil {
+				return err
+			}
+
+			backfillJobId := c.Int("job-id")
+			if backfillJobId == 0 {
+				jr, err := backfillJobRequest(c)
+				if err != nil {
+					return err
+				}
+				if serverError := backfill.ValidateJobRequest(jr, time.Now()); serverError != nil {
+					return serverError
+				}
+				job, err := backfill.AddJob(db, jr)
+				if err != nil {
+					return errors.Wrap(err, "Adding backfill job")
+				}
+				backfillJobId = job.BackfillJobId
+				fmt.Printf("Created backfill job %v, continue it with --job-id %v when interrupted\n",
+					backfillJobId, backfillJobId)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			events := make(chan interface{})
+			printed := make(chan struct{})
+			go func() {
+				defer close(printed)
+				for event := range events {
+					if e, ok := event.(broadcast.BackfillEvent); ok {
+						if e.Kind == "" {
+							fmt.Printf("Backfill job %v is %v, %v stored\n", e.BackfillJobId, e.Status, e.Stored)
+						} else {
+							fmt.Printf("%v: %v stored\n", e.Kind, e.Stored)
+						}
+					}
+				}
+			}()
+			err = backfill.RunJob(ctx, db, backfillJobId, events,
+				backfillRunner(db, events, c.Int("pages-per-second")))
+			close(events)
+			<-printed
+			return err
+		},
+	}
+
 	app.Flags = cmdFlags
 
 	app.Before = altsrc.InitInputSourceWithContext(cmdFlags, loadFlags())
@@ -361,6 +671,8 @@ func main() {
 	app.Commands = cli.Commands{
 		start,
 		migrateUp,
+		replay,
+		backfillCmd,
 	}
 
 	err = app.Run(os.Args)
@@ -396,6 +708,76 @@ func RestartLNDSubscription() error {
 	return nil
 }
 
+// initializeManagedCaches starts the caches that the storage code of the subscriptions depends on.
+func initializeManagedCaches(db *sqlx.DB) error {
+	go commons.ManagedSettingsCache(commons.ManagedSettingsChannel, nil)
+	if err := settings.InitializeManagedSettingsCache(db); err != nil {
+		return errors.Wrap(err, "Initializing ManagedSettings cache")
+	}
+	go commons.ManagedNodeCache(commons.ManagedNodeChannel, nil)
+	if err := settings.InitializeManagedNodeCache(db); err != nil {
+		return errors.Wrap(err, "Initializing ManagedNode cache")
+	}
+	go commons.ManagedChannelCache(commons.ManagedChannelChannel, nil)
+	if err := channels.InitializeManagedChannelCache(db); err != nil {
+		return errors.Wrap(err, "Initializing ManagedChannel cache")
+	}
+	return nil
+}
+
+// backfillRunner imports the history of a backfill job through the client of its node.
+func backfillRunner(db *sqlx.DB, eventChannel chan interface{}, pagesPerSecond int) backfill.Runner {
+	return func(ctx context.Context, job backfill.Job) error {
+		client, err := settings.GetNodeClient(db, job.NodeId)
+		if err != nil {
+			return errors.Wrapf(err, "Getting the client of node id: %v", job.NodeId)
+		}
+		return lnd.Backfill(ctx, client, db, job, eventChannel, lnd.BackfillOptions{PagesPerSecond: pagesPerSecond})
+	}
+}
+
+// reconcileRunner compares the database with the node of a reconciliation report through its client.
+func reconcileRunner(db *sqlx.DB) reconciliation.Runner {
+	return func(ctx context.Context, report *reconciliation.Report) error {
+		client, err := settings.GetNodeClient(db, report.NodeId)
+		if err != nil {
+			return errors.Wrapf(err, "Getting the client of node id: %v", report.NodeId)
+		}
+		return lnd.Reconcile(ctx, client, db, report)
+	}
+}
+
+// backfillJobRequest reads the job of the backfill command from its flags.
+func backfillJobRequest(c *cli.Context) (backfill.JobRequest, error) {
+	jr := backfill.JobRequest{NodeId: c.Int("node-id")}
+	if jr.NodeId == 0 || c.String("from") == "" {
+		return backfill.JobRequest{}, errors.New("A node-id and from date are required to start a backfill job")
+	}
+	from, err := parseBackfillTime(c.String("from"))
+	if err != nil {
+		return backfill.JobRequest{}, errors.Wrap(err, "Parsing from")
+	}
+	jr.FromTime = from
+	if c.String("to") != "" {
+		to, err := parseBackfillTime(c.String("to"))
+		if err != nil {
+			return backfill.JobRequest{}, errors.Wrap(err, "Parsing to")
+		}
+		jr.ToTime = &to
+	}
+	for _, kind := range c.StringSlice("kinds") {
+		jr.Kinds = append(jr.Kinds, backfill.Kind(kind))
+	}
+	return jr, nil
+}
+
+func parseBackfillTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 func loadFlags() func(context *cli.Context) (altsrc.InputSourceContext, error) {
 	return func(context *cli.Context) (altsrc.InputSourceContext, error) {
 		if _, err := os.Stat(context.String("config")); err == nil {
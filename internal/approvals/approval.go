@@ -0,0 +1,198 @@
+package approvals
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/internal/spending_policies"
+)
+
+type RequestType string
+
+const (
+	NewPayment   = RequestType("newPayment")
+	PayOnChain   = RequestType("payOnChain")
+	CloseChannel = RequestType("closeChannel")
+)
+
+type Status string
+
+const (
+	Pending  = Status("pending")
+	Approved = Status("approved")
+	Rejected = Status("rejected")
+	Executed = Status("executed")
+	Failed   = Status("failed")
+)
+
+// interruptedError is the error of approved requests of which the execution was interrupted by a restart,
+// the request might have been executed by the node.
+const interruptedError = "Interrupted by a restart of Torq, check the node whether the request was executed"
+
+type Approval struct {
+	ApprovalId  int            `json:"approvalId" db:"approval_id"`
+	NodeId      int            `json:"nodeId" db:"node_id"`
+	RequestType RequestType    `json:"requestType" db:"request_type"`
+	ReqId       *string        `json:"reqId" db:"req_id"`
+	Request     types.JSONText `json:"request" db:"request"`
+	AmountMsat  int64          `json:"amountMsat" db:"amount_msat"`
+	Status      Status         `json:"status" db:"status"`
+	RequestedBy string         `json:"requestedBy" db:"requested_by"`
+	RequestedOn time.Time      `json:"requestedOn" db:"requested_on"`
+	DecidedBy   *string        `json:"decidedBy" db:"decided_by"`
+	DecidedOn   *time.Time     `json:"decidedOn" db:"decided_on"`
+	Error       *string        `json:"error" db:"error"`
+	UpdatedOn   time.Time      `json:"updatedOn" db:"updated_on"`
+	// RequestedByUserId and DecidedByUserId are the users behind RequestedBy and DecidedBy,
+	// for api tokens it's the user that created the token.
+	RequestedByUserId *int `json:"requestedByUserId" db:"requested_by_user_id"`
+	DecidedByUserId   *int `json:"decidedByUserId" db:"decided_by_user_id"`
+}
+
+// principal is who requests or decides an approval.
+type principal struct {
+	name     string
+	userId   *int
+	apiToken bool
+}
+
+func getPrincipal(c *gin.Context) principal {
+	p := principal{name: c.GetString(auth.Userkey), apiToken: auth.IsApiToken(c)}
+	if userId, ok := auth.GetUserId(c); ok {
+		p.userId = &userId
+	}
+	return p
+}
+
+// ApprovalEvent is sent over the broadcast channel when a request is queued, decided or executed.
+type ApprovalEvent struct {
+	ReqId    string      `json:"reqId"`
+	Type     string      `json:"type"`
+	Approval Approval    `json:"approval"`
+	Result   interface{} `json:"result,omitempty"`
+}
+
+// Executor runs an approved request through the regular path of its request type.
+type Executor func(c *gin.Context, eventChannel chan interface{}, approval Approval) (interface{}, error)
+
+// RequiresApproval checks the approval threshold in the spending policy of the node.
+// getAmountMsat is only called when the node has a threshold for the request type.
+func RequiresApproval(db *sqlx.DB, requestType RequestType, nodeId int,
+	getAmountMsat func() (int64, error)) (bool, int64, error) {
+
+	policy, err := spending_policies.GetSpendingPolicy(db, nodeId)
+	if err != nil {
+		return false, 0, errors.Wrap(err, "Getting spending policy")
+	}
+	thresholdMsat := getThresholdMsat(policy, requestType)
+	if thresholdMsat == nil {
+		return false, 0, nil
+	}
+	amountMsat, err := getAmountMsat()
+	if err != nil {
+		return false, 0, errors.Wrap(err, "Getting amount")
+	}
+	return exceedsThreshold(requestType, amountMsat, *thresholdMsat), amountMsat, nil
+}
+
+func getThresholdMsat(policy spending_policies.SpendingPolicy, requestType RequestType) *int64 {
+	var thresholdMsat int64
+	switch {
+	case requestType == NewPayment && policy.ApprovalPaymentAmountMsat != nil:
+		thresholdMsat = *policy.ApprovalPaymentAmountMsat
+	case requestType == PayOnChain && policy.ApprovalOnChainAmountSat != nil:
+		thresholdMsat = *policy.ApprovalOnChainAmountSat * 1000
+	case requestType == CloseChannel && policy.ApprovalCloseCapacitySat != nil:
+		thresholdMsat = *policy.ApprovalCloseCapacitySat * 1000
+	default:
+		return nil
+	}
+	return &thresholdMsat
+}
+
+func exceedsThreshold(requestType RequestType, amountMsat int64, thresholdMsat int64) bool {
+	// When the capacity of a channel is unknown the close is treated as being above the threshold.
+	if requestType == CloseChannel && amountMsat == 0 {
+		return true
+	}
+	return amountMsat > thresholdMsat
+}
+
+// AddApproval queues the request until a second user approves or rejects it.
+// Api tokens without an owner can't request approvals as there is no user to compare the approver with.
+func AddApproval(db *sqlx.DB, c *gin.Context, requestType RequestType, nodeId int, amountMsat int64,
+	request interface{}, reqId string) (Approval, error) {
+
+	requestedBy := getPrincipal(c)
+	if requestedBy.userId == nil {
+		return Approval{}, errors.New("The api token has no owner, create a new token to request approvals")
+	}
+	requestJson, err := json.Marshal(request)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, "Marshalling request")
+	}
+	approval := Approval{
+		NodeId:            nodeId,
+		RequestType:       requestType,
+		Request:           requestJson,
+		AmountMsat:        amountMsat,
+		Status:            Pending,
+		RequestedBy:       requestedBy.name,
+		RequestedOn:       time.Now().UTC(),
+		RequestedByUserId: requestedBy.userId,
+	}
+	if reqId != "" {
+		approval.ReqId = &reqId
+	}
+	return addApproval(db, approval)
+}
+
+// mayDecide checks that an approval is decided by a second user. The same user can't approve via a session and
+// a token, approving via api tokens isn't allowed at all. Rejecting is allowed for everyone.
+func mayDecide(a Approval, status Status, decidedBy principal) bool {
+	if status != Approved {
+		return true
+	}
+	if decidedBy.apiToken || decidedBy.userId == nil {
+		return false
+	}
+	// Approvals requested before the user id was stored only have the name of the requester.
+	if a.RequestedByUserId == nil {
+		return a.RequestedBy != decidedBy.name
+	}
+	return *a.RequestedByUserId != *decidedBy.userId
+}
+
+func (a Approval) event(result interface{}) ApprovalEvent {
+	event := ApprovalEvent{Type: "approval", Approval: a, Result: result}
+	if a.ReqId != nil {
+		event.ReqId = *a.ReqId
+	}
+	return event
+}
+
+// Event returns the event that is sent to clients for the approval.
+func (a Approval) Event() ApprovalEvent {
+	return a.event(nil)
+}
+
+// FailInterruptedApprovals fails the approved requests without a result. They are executed right after they are
+// approved so when Torq starts the ones still approved were interrupted and would otherwise stay approved forever.
+func FailInterruptedApprovals(db *sqlx.DB) error {
+	failed, err := failInterruptedApprovals(db, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if failed != 0 {
+		log.Warn().Msgf("Failed %v approved requests that were interrupted, check whether their node executed them",
+			failed)
+	}
+	return nil
+}
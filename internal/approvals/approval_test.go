@@ -0,0 +1,108 @@
+package approvals
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/internal/spending_policies"
+)
+
+func threshold(value int64) *int64 {
+	return &value
+}
+
+func Test_requiresApproval(t *testing.T) {
+	policy := spending_policies.SpendingPolicy{
+		NodeId:                    1,
+		ApprovalPaymentAmountMsat: threshold(1_000_000),
+		ApprovalOnChainAmountSat:  threshold(100_000),
+		ApprovalCloseCapacitySat:  threshold(5_000_000),
+	}
+
+	tests := []struct {
+		name        string
+		policy      spending_policies.SpendingPolicy
+		requestType RequestType
+		amountMsat  int64
+		want        bool
+	}{
+		{"No threshold", spending_policies.SpendingPolicy{NodeId: 1}, NewPayment, 10_000_000, false},
+		{"Payment at threshold", policy, NewPayment, 1_000_000, false},
+		{"Payment above threshold", policy, NewPayment, 1_000_001, true},
+		{"On-chain threshold is in sat", policy, PayOnChain, 100_000_000, false},
+		{"On-chain above threshold", policy, PayOnChain, 100_001_000, true},
+		{"Small channel close", policy, CloseChannel, 1_000_000_000, false},
+		{"Large channel close", policy, CloseChannel, 6_000_000_000, true},
+		{"Unknown capacity close", policy, CloseChannel, 0, true},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := false
+			if thresholdMsat := getThresholdMsat(test.policy, test.requestType); thresholdMsat != nil {
+				got = exceedsThreshold(test.requestType, test.amountMsat, *thresholdMsat)
+			}
+			if got != test.want {
+				t.Errorf("%d: requiresApproval()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func userId(value int) *int {
+	return &value
+}
+
+func Test_mayDecide(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	session := func(name string, id int) principal {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(auth.Userkey, name)
+		c.Set(auth.UserIdKey, id)
+		return getPrincipal(c)
+	}
+	apiToken := func(name string, ownerId *int) principal {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(auth.Userkey, "token:"+name)
+		if ownerId != nil {
+			c.Set(auth.UserIdKey, *ownerId)
+		}
+		c.Set(auth.ApiTokenIdKey, 1)
+		return getPrincipal(c)
+	}
+	bySession := Approval{Status: Pending, RequestedBy: "alice", RequestedByUserId: userId(5)}
+	byToken := Approval{Status: Pending, RequestedBy: "token:ci", RequestedByUserId: userId(5)}
+	beforeUserIds := Approval{Status: Pending, RequestedBy: "alice"}
+
+	tests := []struct {
+		name      string
+		approval  Approval
+		status    Status
+		decidedBy principal
+		want      bool
+	}{
+		{"Second user approves", bySession, Approved, session("bob", 6), true},
+		{"Same user approves", bySession, Approved, session("alice", 5), false},
+		{"Same user approves with their token", bySession, Approved, apiToken("ci", userId(5)), false},
+		{"Token of a second user approves", bySession, Approved, apiToken("ci", userId(6)), false},
+		{"Same user approves the request of their token", byToken, Approved, session("alice", 5), false},
+		{"Renamed user approves", bySession, Approved, session("alice2", 5), false},
+		{"Token without owner approves", bySession, Approved, apiToken("old", nil), false},
+		{"Second user approves an old request", beforeUserIds, Approved, session("bob", 6), true},
+		{"Same user approves an old request", beforeUserIds, Approved, session("alice", 5), false},
+		{"Same user rejects", bySession, Rejected, session("alice", 5), true},
+		{"Token rejects", bySession, Rejected, apiToken("ci", userId(5)), true},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := mayDecide(test.approval, test.status, test.decidedBy)
+			if got != test.want {
+				t.Errorf("%d: mayDecide()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package approvals
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getApproval(db *sqlx.DB, approvalId int) (Approval, error) {
+	var a Approval
+	err := db.Get(&a, `SELECT * FROM approval WHERE approval_id=$1;`, approvalId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Approval{}, nil
+		}
+		return Approval{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return a, nil
+}
+
+func getApprovals(db *sqlx.DB, status *Status) ([]Approval, error) {
+	var approvals []Approval
+	var err error
+	if status == nil {
+		err = db.Select(&approvals, `SELECT * FROM approval ORDER BY requested_on DESC;`)
+	} else {
+		err = db.Select(&approvals, `SELECT * FROM approval WHERE status=$1 ORDER BY requested_on DESC;`, *status)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Approval{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return approvals, nil
+}
+
+func addApproval(db *sqlx.DB, a Approval) (Approval, error) {
+	a.UpdatedOn = a.RequestedOn
+	err := db.QueryRowx(`
+		INSERT INTO approval (node_id, request_type, req_id, request, amount_msat, status, requested_by, requested_on,
+			updated_on, requested_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING approval_id;`,
+		a.NodeId, a.RequestType, a.ReqId, a.Request, a.AmountMsat, a.Status, a.RequestedBy, a.RequestedOn,
+		a.UpdatedOn, a.RequestedByUserId).Scan(&a.ApprovalId)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return a, nil
+}
+
+// decideApproval moves a pending approval to the new status.
+// When approving the user deciding can't be the user that requested it.
+// ApprovalId is 0 when the approval wasn't pending (anymore) or the user isn't allowed to decide.
+func decideApproval(db *sqlx.DB, approvalId int, status Status, decidedBy principal) (Approval, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return Approval{}, errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() { _ = tx.Rollback() }()
+	var a Approval
+	err = tx.Get(&a, `SELECT * FROM approval WHERE approval_id=$1 FOR UPDATE;`, approvalId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Approval{}, nil
+		}
+		return Approval{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	if a.Status != Pending || !mayDecide(a, status, decidedBy) {
+		return Approval{}, nil
+	}
+	err = tx.Get(&a, `
+		UPDATE approval SET status=$1, decided_by=$2, decided_by_user_id=$3, decided_on=$4, updated_on=$4
+		WHERE approval_id=$5
+		RETURNING *;`,
+		status, decidedBy.name, decidedBy.userId, time.Now().UTC(), approvalId)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	if err = tx.Commit(); err != nil {
+		return Approval{}, errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return a, nil
+}
+
+func setApprovalResult(db *sqlx.DB, approvalId int, status Status, errorMessage *string) (Approval, error) {
+	var a Approval
+	err := db.Get(&a, `
+		UPDATE approval SET status=$1, error=$2, updated_on=$3
+		WHERE approval_id=$4
+		RETURNING *;`,
+		status, errorMessage, time.Now().UTC(), approvalId)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return a, nil
+}
+
+// failInterruptedApprovals fails the requests that were approved before decidedBefore but have no result.
+func failInterruptedApprovals(db *sqlx.DB, decidedBefore time.Time) (int64, error) {
+	res, err := db.Exec(`
+		UPDATE approval SET status=$1, error=$2, updated_on=$3
+		WHERE status=$4 AND decided_on < $5;`,
+		Failed, interruptedError, time.Now().UTC(), Approved, decidedBefore)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
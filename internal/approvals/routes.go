@@ -0,0 +1,138 @@
+package approvals
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterApprovalRoutes(r *gin.RouterGroup, db *sqlx.DB, eventChannel chan interface{},
+	totpRequired gin.HandlerFunc, execute Executor) {
+
+	r.GET("", func(c *gin.Context) { getApprovalsHandler(c, db) })
+	r.GET(":approvalId", func(c *gin.Context) { getApprovalHandler(c, db) })
+	r.POST(":approvalId/approve", totpRequired, func(c *gin.Context) { approveHandler(c, db, eventChannel, execute) })
+	r.POST(":approvalId/reject", func(c *gin.Context) { rejectHandler(c, db, eventChannel) })
+}
+
+func getApprovalsHandler(c *gin.Context, db *sqlx.DB) {
+	var status *Status
+	if c.Query("status") != "" {
+		s := Status(c.Query("status"))
+		status = &s
+	}
+	approvals, err := getApprovals(db, status)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting approvals.")
+		return
+	}
+	c.JSON(http.StatusOK, approvals)
+}
+
+func getApprovalHandler(c *gin.Context, db *sqlx.DB) {
+	approvalId, err := strconv.Atoi(c.Param("approvalId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse approvalId in the request.")
+		return
+	}
+	approval, err := getApproval(db, approvalId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting approval for approvalId: %v", approvalId))
+		return
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+func approveHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}, execute Executor) {
+	approvalId, err := strconv.Atoi(c.Param("approvalId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse approvalId in the request.")
+		return
+	}
+	if auth.IsApiToken(c) {
+		server_errors.SendUnprocessableEntity(c, "Requests can't be approved with an api token, log in to approve it.")
+		return
+	}
+	approval, err := decideApproval(db, approvalId, Approved, getPrincipal(c))
+	audit.Record(db, c, audit.ApproveRequest, map[string]interface{}{"approvalId": approvalId}, approval, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Approving approvalId: %v", approvalId))
+		return
+	}
+	if approval.ApprovalId == 0 {
+		server_errors.SendUnprocessableEntity(c,
+			"The request is not pending or was requested by you, a second user needs to approve it.")
+		return
+	}
+	if eventChannel != nil {
+		eventChannel <- approval.Event()
+	}
+
+	// The request can take a long time (e.g. payments) so the result is sent over the broadcast channel.
+	go executeApproval(c.Copy(), db, eventChannel, execute, approval)
+
+	c.JSON(http.StatusOK, approval)
+}
+
+func executeApproval(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}, execute Executor, approval Approval) {
+	result, err := execute(c, eventChannel, approval)
+	status := Executed
+	var errorMessage *string
+	if err != nil {
+		status = Failed
+		message := err.Error()
+		errorMessage = &message
+	}
+	storedApproval, err := setApprovalResult(db, approval.ApprovalId, status, errorMessage)
+	if err != nil {
+		log.Error().Err(err).Msgf("Storing the result of approvalId: %v", approval.ApprovalId)
+		return
+	}
+	if eventChannel != nil {
+		eventChannel <- storedApproval.event(result)
+	}
+}
+
+func rejectHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}) {
+	approvalId, err := strconv.Atoi(c.Param("approvalId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse approvalId in the request.")
+		return
+	}
+	approval, err := decideApproval(db, approvalId, Rejected, getPrincipal(c))
+	audit.Record(db, c, audit.RejectRequest, map[string]interface{}{"approvalId": approvalId}, approval, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Rejecting approvalId: %v", approvalId))
+		return
+	}
+	if approval.ApprovalId == 0 {
+		server_errors.SendUnprocessableEntity(c, "The request is not pending.")
+		return
+	}
+	if eventChannel != nil {
+		eventChannel <- approval.Event()
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the approval requests",
+			Response:        []Approval{},
+			QueryParameters: []string{"status"},
+		},
+		"GET :approvalId":          {Summary: "Get an approval request", Response: Approval{}},
+		"POST :approvalId/approve": {Summary: "Approve and execute a request of another user", Response: Approval{}},
+		"POST :approvalId/reject":  {Summary: "Reject a request", Response: Approval{}},
+	}
+}
@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/rs/zerolog/log"
+)
+
+// ActorKey is the gin context key holding the authenticated user or api token, it's set by auth.AuthRequired.
+// It's defined here because the packages that record audit entries include the ones auth depends on.
+const ActorKey = "user"
+
+type Action string
+
+const (
+	UpdateChannels                 = Action("updateChannels")
+	BatchOpenChannels              = Action("batchOpenChannels")
+	OpenChannel                    = Action("openChannel")
+	CloseChannel                   = Action("closeChannel")
+	PayOnChain                     = Action("payOnChain")
+	SendNewPayment                 = Action("sendNewPayment")
+	AddTag                         = Action("addTag")
+	SetTag                         = Action("setTag")
+	RemoveTag                      = Action("removeTag")
+	AddChannelTag                  = Action("addChannelTag")
+	RemoveChannelTag               = Action("removeChannelTag")
+	UpdateSettings                 = Action("updateSettings")
+	AddNodeConnectionDetails       = Action("addNodeConnectionDetails")
+	SetNodeConnectionDetails       = Action("setNodeConnectionDetails")
+	SetNodeConnectionDetailsStatus = Action("setNodeConnectionDetailsStatus")
+	SetSpendingPolicy              = Action("setSpendingPolicy")
+	RemoveSpendingPolicy           = Action("removeSpendingPolicy")
+	RequestApproval                = Action("requestApproval")
+	ApproveRequest                 = Action("approveRequest")
+	RejectRequest                  = Action("rejectRequest")
+	AddWebhook                     = Action("addWebhook")
+	SetWebhook                     = Action("setWebhook")
+	RemoveWebhook                  = Action("removeWebhook")
+	RetryWebhookDelivery           = Action("retryWebhookDelivery")
+	AddBackfillJob                 = Action("addBackfillJob")
+	CancelBackfillJob              = Action("cancelBackfillJob")
+	ResumeBackfillJob              = Action("resumeBackfillJob")
+	RequestReconciliation          = Action("requestReconciliation")
+	RestoreChannelBackup           = Action("restoreChannelBackup")
+	AddAutoFee                     = Action("addAutoFee")
+	SetAutoFee                     = Action("setAutoFee")
+	RemoveAutoFee                  = Action("removeAutoFee")
+	RunAutoFee                     = Action("runAutoFee")
+	RequestRebalance               = Action("requestRebalance")
+	CancelRebalance                = Action("cancelRebalance")
+	SetRebalanceBudget             = Action("setRebalanceBudget")
+	RemoveRebalanceBudget          = Action("removeRebalanceBudget")
+	AddUser                        = Action("addUser")
+	SetUser                        = Action("setUser")
+	RemoveUser                     = Action("removeUser")
+	AddApiToken                    = Action("addApiToken")
+	RevokeApiToken                 = Action("revokeApiToken")
+	EnrollTotp                     = Action("enrollTotp")
+	ConfirmTotp                    = Action("confirmTotp")
+	VerifyTotp                     = Action("verifyTotp")
+	DisableTotp                    = Action("disableTotp")
+	RemoveNode                     = Action("removeNode")
+	ConnectPeer                    = Action("connectPeer")
+)
+
+type Outcome string
+
+const (
+	Success = Outcome("success")
+	Failure = Outcome("failure")
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are (parts of) JSON keys of which the value is never stored.
+var sensitiveKeys = []string{ //nolint:gochecknoglobals
+	"password",
+	"passphrase",
+	"macaroon",
+	"tlsfile",
+	"tlsdata",
+	"secret",
+	"token",
+	"totp",
+	"seed",
+	"preimage",
+	"provisioninguri",
+	"recoverycode",
+}
+
+type Entry struct {
+	AuditId   int64           `json:"auditId" db:"audit_id"`
+	CreatedOn time.Time       `json:"createdOn" db:"created_on"`
+	Actor     string          `json:"actor" db:"actor"`
+	SourceIp  string          `json:"sourceIp" db:"source_ip"`
+	Action    Action          `json:"action" db:"action"`
+	Payload   *types.JSONText `json:"payload" db:"payload"`
+	Outcome   Outcome         `json:"outcome" db:"outcome"`
+	Error     *string         `json:"error" db:"error"`
+	Response  *types.JSONText `json:"response" db:"response"`
+}
+
+// Record stores an audit entry for a state changing operation.
+// Failing to store the entry is logged but doesn't fail the operation itself.
+func Record(db *sqlx.DB, c *gin.Context, action Action, payload interface{}, response interface{}, err error) {
+	entry := Entry{
+		CreatedOn: time.Now().UTC(),
+		Actor:     c.GetString(ActorKey),
+		SourceIp:  c.ClientIP(),
+		Action:    action,
+		Outcome:   Success,
+		Payload:   redactJson(payload),
+		Response:  redactJson(response),
+	}
+	if err != nil {
+		entry.Outcome = Failure
+		errorMessage := err.Error()
+		entry.Error = &errorMessage
+	}
+	if addErr := addEntry(db, entry); addErr != nil {
+		log.Error().Err(addErr).Msgf("Storing audit entry for action: %v by %v", action, entry.Actor)
+	}
+}
+
+func redactJson(value interface{}) *types.JSONText {
+	if value == nil {
+		return nil
+	}
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("Marshalling audit value")
+		return nil
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(valueJson, &decoded); err != nil {
+		log.Error().Err(err).Msg("Unmarshalling audit value")
+		return nil
+	}
+	if decoded == nil {
+		return nil
+	}
+	redactedJson, err := json.Marshal(redact(decoded))
+	if err != nil {
+		log.Error().Err(err).Msg("Marshalling redacted audit value")
+		return nil
+	}
+	jsonText := types.JSONText(redactedJson)
+	return &jsonText
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redact(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redact(child)
+		}
+		return v
+	}
+	return value
+}
+
+// isSensitiveKey returns true for keys containing a sensitive part, ids (i.e. apiTokenId) are never sensitive.
+func isSensitiveKey(key string) bool {
+	if strings.HasSuffix(key, "Id") {
+		return false
+	}
+	lowerKey := strings.ToLower(key)
+	for _, sensitiveKey := range sensitiveKeys {
+		if strings.Contains(lowerKey, sensitiveKey) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"testing"
+)
+
+func Test_redactJson(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  string
+	}{
+		{
+			"Nil value",
+			nil,
+			"",
+		},
+		{
+			"Nothing to redact",
+			map[string]interface{}{"nodeId": 1, "amountSat": 1000},
+			`{"amountSat":1000,"nodeId":1}`,
+		},
+		{
+			"Secrets are redacted",
+			map[string]interface{}{"nodeId": 1, "password": "hunter2", "totp": "123456"},
+			`{"nodeId":1,"password":"[REDACTED]","totp":"[REDACTED]"}`,
+		},
+		{
+			"Nested secrets are redacted",
+			struct {
+				Channels []map[string]interface{} `json:"channels"`
+			}{Channels: []map[string]interface{}{{"macaroonHex": "0201", "chanId": 1}}},
+			`{"channels":[{"chanId":1,"macaroonHex":"[REDACTED]"}]}`,
+		},
+		{
+			"Token and TOTP secrets are redacted but not their ids",
+			map[string]interface{}{"apiTokenId": 1, "token": "torq_abc", "provisioningUri": "otpauth://totp/Torq:alice",
+				"recoveryCodes": []string{"abc"}},
+			`{"apiTokenId":1,"provisioningUri":"[REDACTED]","recoveryCodes":"[REDACTED]","token":"[REDACTED]"}`,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ""
+			if jsonText := redactJson(test.input); jsonText != nil {
+				got = jsonText.String()
+			}
+			if got != test.want {
+				t.Errorf("%d: redactJson()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
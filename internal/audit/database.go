@@ -0,0 +1,70 @@
+package audit
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func addEntry(db *sqlx.DB, entry Entry) error {
+	_, err := db.Exec(`
+		INSERT INTO audit (created_on, actor, source_ip, action, payload, outcome, error, response)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`,
+		entry.CreatedOn, entry.Actor, entry.SourceIp, entry.Action, entry.Payload, entry.Outcome, entry.Error,
+		entry.Response)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func getEntries(db *sqlx.DB, filter sq.Sqlizer, order []string, limit uint64, offset uint64) (r []Entry,
+	total uint64, err error) {
+
+	if len(order) == 0 {
+		order = []string{"created_on DESC"}
+	}
+
+	//language=PostgreSQL
+	qb := sq.Select("*").
+		PlaceholderFormat(sq.Dollar).
+		From("audit").
+		Where(filter).
+		OrderBy(order...)
+
+	if limit > 0 {
+		qb = qb.Limit(limit).Offset(offset)
+	}
+
+	qs, args, err := qb.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Log for debugging
+	log.Debug().Msgf("Query: %s, \n Args: %v", qs, args)
+
+	err = db.Select(&r, qs, args...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+
+	totalQs, args, err := sq.Select("count(*) as total").
+		PlaceholderFormat(sq.Dollar).
+		From("audit").
+		Where(filter).
+		ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = db.QueryRowx(totalQs, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+
+	return r, total, nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	qp "github.com/lncapital/torq/internal/query_parser"
+	ah "github.com/lncapital/torq/pkg/api_helpers"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterAuditRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getAuditHandler(c, db) })
+}
+
+func getAuditHandler(c *gin.Context, db *sqlx.DB) {
+
+	// Filter parser with whitelisted columns
+	var filter sq.Sqlizer
+	filterParam := c.Query("filter")
+	var err error
+	if filterParam != "" {
+		filter, err = qp.ParseFilterParam(filterParam, []string{
+			"audit_id",
+			"created_on",
+			"actor",
+			"source_ip",
+			"action",
+			"outcome",
+			"error",
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+			return
+		}
+	}
+
+	var sort []string
+	sortParam := c.Query("order")
+	if sortParam != "" {
+		// Order parser with whitelisted columns
+		sort, err = qp.ParseOrderParams(
+			sortParam,
+			[]string{
+				"audit_id",
+				"created_on",
+				"actor",
+				"source_ip",
+				"action",
+				"outcome",
+			})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+			return
+		}
+	}
+
+	var limit uint64
+	if c.Query("limit") != "" {
+		limit, err = strconv.ParseUint(c.Query("limit"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": "Limit must be a positive number"})
+			return
+		}
+	}
+
+	var offset uint64
+	if c.Query("offset") != "" {
+		offset, err = strconv.ParseUint(c.Query("offset"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": "Offset must be a positive number"})
+			return
+		}
+	}
+
+	r, total, err := getEntries(db, filter, sort, limit, offset)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting audit entries")
+		return
+	}
+
+	c.JSON(http.StatusOK, ah.ApiResponse{
+		Data: r, Pagination: ah.Pagination{
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		}})
+}
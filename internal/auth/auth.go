@@ -3,12 +3,31 @@ package auth
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/tokens"
+	"github.com/lncapital/torq/internal/totp"
+	"github.com/lncapital/torq/internal/users"
+	"github.com/lncapital/torq/pkg/commons"
 )
 
-const Userkey = "user"
+// Userkey is the gin context and session key holding the username, audit entries store it as the actor.
+const Userkey = audit.ActorKey
+const UserIdKey = "userId"
+
+// RoleKey is the gin context key holding the role of the authenticated user.
+const RoleKey = "role"
+
+// ApiTokenIdKey is the gin context key holding the api token used to authenticate the request.
+const ApiTokenIdKey = "apiTokenId"
+
+const bearerPrefix = "Bearer "
 
 func CreateSession(r *gin.Engine, apiPwd string) {
 	store := sessions.NewCookieStore([]byte(apiPwd))
@@ -16,21 +35,142 @@ func CreateSession(r *gin.Engine, apiPwd string) {
 	r.Use(sessions.Sessions("torq_session", store))
 }
 
-// AuthRequired is a simple middleware to check the session
-func AuthRequired(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	if user == nil {
-		// Abort the request with the appropriate error code
+// AuthRequired is a middleware to check the session or api token and the role of the user.
+// Read only requests (GET) require readRole every other request requires writeRole.
+func AuthRequired(db *sqlx.DB, readRole users.Role, writeRole users.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requiredRole := RequiredRole(c.Request.Method, readRole, writeRole)
+		if bearerToken := getBearerToken(c); bearerToken != "" {
+			apiTokenRequired(c, db, bearerToken, requiredRole)
+			return
+		}
+		session := sessions.Default(c)
+		user := session.Get(Userkey)
+		if user == nil {
+			// Abort the request with the appropriate error code
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		role, authenticated := getSessionRole(db, session)
+		if !authenticated {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if !role.HasRole(requiredRole) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Set(Userkey, user)
+		c.Set(UserIdKey, session.Get(UserIdKey))
+		c.Set(RoleKey, role)
+		// Continue down the chain to handler etc
+		c.Next()
+	}
+}
+
+// RoleRequired is a middleware for routes that need a higher role than the rest of their group.
+// It uses the role set by AuthRequired so it needs to run after it.
+func RoleRequired(requiredRole users.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !GetRole(c).HasRole(requiredRole) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func apiTokenRequired(c *gin.Context, db *sqlx.DB, bearerToken string, requiredRole users.Role) {
+	apiToken, err := tokens.Authenticate(db, bearerToken)
+	if err != nil {
+		log.Error().Err(err).Msg("Authenticating api token")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate api token"})
+		return
+	}
+	if apiToken.ApiTokenId == 0 {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
-	// Continue down the chain to handler etc
+	if !apiToken.IsAllowed(c.FullPath(), requiredRole) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	c.Set(Userkey, "token:"+apiToken.Name)
+	if apiToken.CreatedByUserId != nil {
+		c.Set(UserIdKey, *apiToken.CreatedByUserId)
+	}
+	c.Set(ApiTokenIdKey, apiToken.ApiTokenId)
+	c.Set(RoleKey, apiToken.Role())
 	c.Next()
 }
 
+// GetUserId returns the id of the user authenticated by AuthRequired, for api tokens it's the user that created the
+// token. The configured administrator has user id 0, api tokens created before tokens had an owner have no user id.
+func GetUserId(c *gin.Context) (int, bool) {
+	userId, ok := c.Get(UserIdKey)
+	if !ok {
+		return 0, false
+	}
+	id, ok := userId.(int)
+	return id, ok
+}
+
+// IsApiToken returns true when the request was authenticated with an api token.
+func IsApiToken(c *gin.Context) bool {
+	_, isApiToken := c.Get(ApiTokenIdKey)
+	return isApiToken
+}
+
+// getBearerToken returns the token from the "Authorization: Bearer <token>" header.
+func getBearerToken(c *gin.Context) string {
+	authorization := c.GetHeader("Authorization")
+	if len(authorization) <= len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(authorization[len(bearerPrefix):])
+}
+
+// RequiredRole returns the role required for the HTTP method.
+func RequiredRole(method string, readRole users.Role, writeRole users.Role) users.Role {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return readRole
+	}
+	return writeRole
+}
+
+// GetRole returns the role of the user authenticated by AuthRequired.
+func GetRole(c *gin.Context) users.Role {
+	role, exists := c.Get(RoleKey)
+	if !exists {
+		return users.Viewer
+	}
+	return role.(users.Role)
+}
+
+// getSessionRole obtains the role from the database so that role changes and removed users
+// take effect immediately instead of when the session expires.
+func getSessionRole(db *sqlx.DB, session sessions.Session) (users.Role, bool) {
+	userId, ok := session.Get(UserIdKey).(int)
+	if !ok {
+		return users.Viewer, false
+	}
+	if userId == 0 {
+		return users.Admin, session.Get(Userkey) == users.ConfigAdminUsername
+	}
+	user, err := users.GetUser(db, userId)
+	if err != nil {
+		log.Error().Err(err).Msgf("Obtaining user for userId: %v", userId)
+		return users.Viewer, false
+	}
+	if user.UserId == 0 || user.Status != commons.Active {
+		return users.Viewer, false
+	}
+	return user.Role, true
+}
+
 // Login creates a user session, logging them in given the right username and password
-func Login(apiPwd string) gin.HandlerFunc {
+func Login(db *sqlx.DB, apiPwd string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := c.PostForm("username")
@@ -42,15 +182,49 @@ func Login(apiPwd string) gin.HandlerFunc {
 			return
 		}
 
-		// Check for username and password match, usually from a database
-		if username != "admin" || password != apiPwd {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
+		// The configured administrator is not stored in the database
+		userId := 0
+		if username != users.ConfigAdminUsername || password != apiPwd {
+			user, err := users.Authenticate(db, username, password)
+			if err != nil {
+				log.Error().Err(err).Msg("Authenticating user")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate user"})
+				return
+			}
+			if user.UserId == 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
+				return
+			}
+			userId = user.UserId
+		}
+
+		totpEnabled, err := totp.IsEnabled(db, userId)
+		if err != nil {
+			log.Error().Err(err).Msg("Checking TOTP enrollment")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate user"})
 			return
 		}
+		if totpEnabled {
+			code := c.PostForm("totp")
+			if strings.TrimSpace(code) == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "TOTP code required", "totpRequired": true})
+				return
+			}
+			valid, err := totp.Verify(db, userId, code)
+			if err != nil {
+				log.Error().Err(err).Msg("Verifying TOTP code")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate user"})
+				return
+			}
+			if !valid {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed", "totpRequired": true})
+				return
+			}
+			session.Set(TotpVerifiedKey, time.Now().Unix())
+		}
 
-		// Save the username in the session
-		// set this to the users ID when moving to multi users setup
 		session.Set(Userkey, username)
+		session.Set(UserIdKey, userId)
 		if err := session.Save(); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session"})
 			return
@@ -63,6 +237,11 @@ func Logout(c *gin.Context) {
 	session := sessions.Default(c)
 
 	session.Delete(Userkey)
+	session.Delete(UserIdKey)
+	session.Delete(TotpVerifiedKey)
+	if err := session.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save session")
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 }
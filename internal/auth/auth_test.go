@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lncapital/torq/internal/users"
+)
+
+func Test_RequiredRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		userRole users.Role
+		want     bool
+	}{
+		{"Viewer can read", http.MethodGet, users.Viewer, true},
+		{"Viewer can't write", http.MethodPost, users.Viewer, false},
+		{"Viewer can't delete", http.MethodDelete, users.Viewer, false},
+		{"Operator can write", http.MethodPut, users.Operator, true},
+		{"Operator can patch", http.MethodPatch, users.Operator, true},
+		{"Admin can write", http.MethodPost, users.Admin, true},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.userRole.HasRole(RequiredRole(test.method, users.Viewer, users.Operator))
+			if got != test.want {
+				t.Errorf("%d: HasRole()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+
+	t.Run("Operator can't change admin routes", func(t *testing.T) {
+		if users.Operator.HasRole(RequiredRole(http.MethodPut, users.Viewer, users.Admin)) {
+			t.Errorf("Operator should not have the admin role")
+		}
+	})
+}
+
+func Test_RoleRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		userRole users.Role
+		want     int
+	}{
+		{"Operator can't download admin resources", users.Operator, http.StatusForbidden},
+		{"Admin can download admin resources", users.Admin, http.StatusOK},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/download", func(c *gin.Context) { c.Set(RoleKey, test.userRole) }, RoleRequired(users.Admin),
+				func(c *gin.Context) { c.Status(http.StatusOK) })
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download", nil))
+			if w.Code != test.want {
+				t.Errorf("%d: GET /download status\nGot:\n%v\nWant:\n%v\n", i, w.Code, test.want)
+			}
+		})
+	}
+}
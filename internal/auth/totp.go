@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/totp"
+	"github.com/lncapital/torq/internal/users"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+// TotpVerifiedKey is the session key holding the unix time of the last successful TOTP verification.
+const TotpVerifiedKey = "totpVerifiedOn"
+
+// TotpHeader can hold a TOTP code for REST requests that move funds, api tokens need it with every such request.
+const TotpHeader = "X-Torq-Totp"
+
+type totpCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type totpConfirmResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func RegisterTotpRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getTotpStatusHandler(c, db) })
+	r.POST("enroll", func(c *gin.Context) { enrollTotpHandler(c, db) })
+	r.POST("confirm", func(c *gin.Context) { confirmTotpHandler(c, db) })
+	r.POST("verify", func(c *gin.Context) { verifyTotpHandler(c, db) })
+	r.DELETE("", func(c *gin.Context) { disableTotpHandler(c, db) })
+}
+
+// TotpRequired is a middleware for REST requests that move funds.
+// When the user has TOTP enabled it needs to be verified within the window or the request needs to contain a valid code.
+func TotpRequired(db *sqlx.DB, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := CheckTotp(c, db, window, c.GetHeader(TotpHeader))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, server_errors.SingleFieldError("totp", err.Error()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckTotp returns an error when the user has TOTP enabled, didn't verify it within the window
+// and the code is not a valid TOTP or recovery code.
+// Requests authenticated with an api token are protected by the TOTP of the user that created the token, they have no
+// session so they need to contain a valid code every time. Api tokens without an owner can't move funds.
+func CheckTotp(c *gin.Context, db *sqlx.DB, window time.Duration, code string) error {
+	userId, err := getTotpUser(c, db)
+	if err != nil {
+		return err
+	}
+	enabled, err := totp.IsEnabled(db, userId)
+	if err != nil {
+		return errors.Wrap(err, "Checking TOTP enrollment")
+	}
+	if !enabled {
+		return nil
+	}
+	if !IsApiToken(c) {
+		session := sessions.Default(c)
+		if verifiedOn, ok := session.Get(TotpVerifiedKey).(int64); ok && time.Since(time.Unix(verifiedOn, 0)) <= window {
+			return nil
+		}
+	}
+	if strings.TrimSpace(code) == "" {
+		return errors.New("TOTP code required")
+	}
+	valid, err := totp.Verify(db, userId, code)
+	if err != nil {
+		return errors.Wrap(err, "Verifying TOTP code")
+	}
+	if !valid {
+		return errors.New("Invalid TOTP code")
+	}
+	return nil
+}
+
+// getTotpUser returns the id of the user whose TOTP protects the request, for api tokens it's the user that created
+// the token. The configured administrator has user id 0.
+func getTotpUser(c *gin.Context, db *sqlx.DB) (int, error) {
+	userId, ok := GetUserId(c)
+	if !IsApiToken(c) {
+		if !ok {
+			return 0, errors.New("The session has no user")
+		}
+		return userId, nil
+	}
+	if !ok {
+		return 0, errors.New("The api token has no owner, create a new token to move funds")
+	}
+	if userId == 0 {
+		return 0, nil
+	}
+	user, err := users.GetUser(db, userId)
+	if err != nil {
+		return 0, errors.Wrap(err, "Obtaining the owner of the api token")
+	}
+	if user.UserId == 0 || user.Status != commons.Active {
+		return 0, errors.New("The owner of the api token is not active")
+	}
+	return user.UserId, nil
+}
+
+func getTotpUserId(c *gin.Context) (int, bool) {
+	if IsApiToken(c) {
+		server_errors.SendBadRequest(c, "TOTP is not available for api tokens.")
+		return 0, false
+	}
+	userId, ok := GetUserId(c)
+	if !ok {
+		server_errors.SendBadRequest(c, "The session has no user.")
+		return 0, false
+	}
+	return userId, true
+}
+
+func getTotpStatusHandler(c *gin.Context, db *sqlx.DB) {
+	userId, ok := getTotpUserId(c)
+	if !ok {
+		return
+	}
+	status, err := totp.GetStatus(db, userId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting TOTP status.")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func enrollTotpHandler(c *gin.Context, db *sqlx.DB) {
+	userId, ok := getTotpUserId(c)
+	if !ok {
+		return
+	}
+	enrollResponse, err := totp.Enroll(db, userId, c.GetString(Userkey))
+	audit.Record(db, c, audit.EnrollTotp, nil, enrollResponse, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Enrolling TOTP.")
+		return
+	}
+	c.JSON(http.StatusOK, enrollResponse)
+}
+
+func confirmTotpHandler(c *gin.Context, db *sqlx.DB) {
+	userId, ok := getTotpUserId(c)
+	if !ok {
+		return
+	}
+	var tcr totpCodeRequest
+	if err := c.BindJSON(&tcr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	recoveryCodes, err := totp.Confirm(db, userId, tcr.Code)
+	// The codes are only valid once so they are not stored, invalid codes are recorded as failures.
+	audit.Record(db, c, audit.ConfirmTotp, nil, totpConfirmResponse{RecoveryCodes: recoveryCodes},
+		invalidTotpCodeError(err, len(recoveryCodes) != 0))
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Confirming TOTP.")
+		return
+	}
+	if len(recoveryCodes) == 0 {
+		c.JSON(http.StatusBadRequest, server_errors.SingleFieldError("code", "Invalid TOTP code"))
+		return
+	}
+	setTotpVerified(c)
+	c.JSON(http.StatusOK, totpConfirmResponse{RecoveryCodes: recoveryCodes})
+}
+
+func verifyTotpHandler(c *gin.Context, db *sqlx.DB) {
+	userId, ok := getTotpUserId(c)
+	if !ok {
+		return
+	}
+	var tcr totpCodeRequest
+	if err := c.BindJSON(&tcr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	valid, err := totp.Verify(db, userId, tcr.Code)
+	audit.Record(db, c, audit.VerifyTotp, nil, nil, invalidTotpCodeError(err, valid))
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Verifying TOTP.")
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, server_errors.SingleFieldError("code", "Invalid TOTP code"))
+		return
+	}
+	setTotpVerified(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully verified TOTP code"})
+}
+
+func disableTotpHandler(c *gin.Context, db *sqlx.DB) {
+	userId, ok := getTotpUserId(c)
+	if !ok {
+		return
+	}
+	var tcr totpCodeRequest
+	if err := c.BindJSON(&tcr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	valid, err := totp.Verify(db, userId, tcr.Code)
+	if err == nil && valid {
+		err = totp.Disable(db, userId)
+	}
+	audit.Record(db, c, audit.DisableTotp, nil, nil, invalidTotpCodeError(err, valid))
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Disabling TOTP.")
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, server_errors.SingleFieldError("code", "Invalid TOTP code"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully disabled TOTP"})
+}
+
+// invalidTotpCodeError returns the error to record for a TOTP action, an invalid code is recorded as a failure.
+func invalidTotpCodeError(err error, valid bool) error {
+	if err == nil && !valid {
+		return errors.New("Invalid TOTP code")
+	}
+	return err
+}
+
+func setTotpVerified(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Set(TotpVerifiedKey, time.Now().Unix())
+	if err := session.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save session")
+	}
+}
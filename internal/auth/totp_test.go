@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_getTotpUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newContext := func(username string, userId *int, apiTokenId *int) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(Userkey, username)
+		if userId != nil {
+			c.Set(UserIdKey, *userId)
+		}
+		if apiTokenId != nil {
+			c.Set(ApiTokenIdKey, *apiTokenId)
+		}
+		return c
+	}
+	configAdminId := 0
+	aliceId := 2
+	apiTokenId := 1
+
+	t.Run("Session uses the TOTP of the user", func(t *testing.T) {
+		userId, err := getTotpUser(newContext("alice", &aliceId, nil), nil)
+		if err != nil || userId != aliceId {
+			t.Errorf("getTotpUser()\nGot:\n%v %v\nWant:\n%v\n", userId, err, aliceId)
+		}
+	})
+
+	t.Run("Api token uses the TOTP of its owner", func(t *testing.T) {
+		userId, err := getTotpUser(newContext("token:ci", &configAdminId, &apiTokenId), nil)
+		if err != nil || userId != configAdminId {
+			t.Errorf("getTotpUser()\nGot:\n%v %v\nWant:\n%v\n", userId, err, configAdminId)
+		}
+	})
+
+	t.Run("Api token without owner can't move funds", func(t *testing.T) {
+		err := CheckTotp(newContext("token:old", nil, &apiTokenId), nil, time.Minute, "123456")
+		if err == nil {
+			t.Errorf("CheckTotp()\nGot:\n%v\nWant:\n%v\n", err, "an error")
+		}
+	})
+}
@@ -0,0 +1,498 @@
+package auto_fee
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/corridors"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+type Status string
+
+const (
+	Applied = Status("applied")
+	// DryRun decisions would have been applied without the dry-run mode.
+	DryRun    = Status("dryRun")
+	Unchanged = Status("unchanged")
+	// RateLimited decisions were not applied because the policy of the channel was updated too recently.
+	RateLimited = Status("rateLimited")
+	Failed      = Status("failed")
+)
+
+// DefaultInterval is how often the fees of all nodes are automated.
+const DefaultInterval = 30 * time.Minute
+
+const (
+	// flowFactor is how much the fee rate moves up when all forwards leave the channel, and down when they all enter.
+	flowFactor = 0.25
+	// maxHtlcTolerance is the relative change of the max HTLC that is ignored so the policy doesn't follow every
+	// forward.
+	maxHtlcTolerance = 0.1
+	minMaxHtlcMsat   = 1000
+	// minTimeLockDelta is the minimum supported by LND, it's used when the node doesn't report the current one.
+	minTimeLockDelta = 18
+)
+
+// Parameters of an AutoFee corridor, the defaults are used for an enabled corridor without parameters.
+type Parameters struct {
+	CorridorId    int      `json:"corridorId" db:"corridor_id"`
+	MinFeeRatePpm int64    `json:"minFeeRatePpm" db:"min_fee_rate_ppm"`
+	MaxFeeRatePpm int64    `json:"maxFeeRatePpm" db:"max_fee_rate_ppm"`
+	BaseFeeMsat   *int64   `json:"baseFeeMsat" db:"base_fee_msat"`
+	MaxHtlcRatio  *float64 `json:"maxHtlcRatio" db:"max_htlc_ratio"`
+	MaxStepPpm    int64    `json:"maxStepPpm" db:"max_step_ppm"`
+	MinChangePpm  int64    `json:"minChangePpm" db:"min_change_ppm"`
+	// MinUpdateIntervalSeconds rate limits the updates of the policy of a channel, manual updates included.
+	MinUpdateIntervalSeconds int       `json:"minUpdateIntervalSeconds" db:"min_update_interval_seconds"`
+	FlowWindowSeconds        int       `json:"flowWindowSeconds" db:"flow_window_seconds"`
+	DryRun                   bool      `json:"dryRun" db:"dry_run"`
+	CreatedOn                time.Time `json:"createdOn" db:"created_on"`
+	UpdatedOn                time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+func DefaultParameters() Parameters {
+	return Parameters{
+		MinFeeRatePpm:            1,
+		MaxFeeRatePpm:            2500,
+		MaxStepPpm:               100,
+		MinChangePpm:             5,
+		MinUpdateIntervalSeconds: int(time.Hour.Seconds()),
+		FlowWindowSeconds:        int((7 * 24 * time.Hour).Seconds()),
+	}
+}
+
+// Inputs are everything a decision is based on.
+type Inputs struct {
+	Capacity         int64  `json:"capacity" db:"capacity"`
+	LocalBalance     int64  `json:"localBalance" db:"local_balance"`
+	OutgoingFlowMsat int64  `json:"outgoingFlowMsat" db:"outgoing_flow_msat"`
+	IncomingFlowMsat int64  `json:"incomingFlowMsat" db:"incoming_flow_msat"`
+	PeerFeeRatePpm   *int64 `json:"peerFeeRatePpm" db:"peer_fee_rate_ppm"`
+	PeerBaseFeeMsat  *int64 `json:"peerBaseFeeMsat" db:"peer_base_fee_msat"`
+	FeeRatePpm       int64  `json:"feeRatePpm" db:"fee_rate_ppm"`
+	BaseFeeMsat      int64  `json:"baseFeeMsat" db:"base_fee_msat"`
+	MaxHtlcMsat      uint64 `json:"maxHtlcMsat" db:"max_htlc_msat"`
+}
+
+type Decision struct {
+	AutoFeeDecisionId int    `json:"autoFeeDecisionId" db:"auto_fee_decision_id"`
+	NodeId            int    `json:"nodeId" db:"node_id"`
+	ChannelId         int    `json:"channelId" db:"channel_id"`
+	CorridorId        *int   `json:"corridorId" db:"corridor_id"`
+	Status            Status `json:"status" db:"status"`
+	Reason            string `json:"reason" db:"reason"`
+	Inputs
+	NewFeeRatePpm  int64     `json:"newFeeRatePpm" db:"new_fee_rate_ppm"`
+	NewBaseFeeMsat int64     `json:"newBaseFeeMsat" db:"new_base_fee_msat"`
+	NewMaxHtlcMsat uint64    `json:"newMaxHtlcMsat" db:"new_max_htlc_msat"`
+	Error          *string   `json:"error" db:"error"`
+	CreatedOn      time.Time `json:"createdOn" db:"created_on"`
+}
+
+// Setting is an AutoFee corridor with its parameters.
+type Setting struct {
+	Corridor   corridors.Corridor `json:"corridor"`
+	Parameters Parameters         `json:"parameters"`
+}
+
+// SettingRequest automates the fees of the channels of a node, narrowed down to a peer, a tag of the channel or a
+// single channel. Parameters default to DefaultParameters.
+type SettingRequest struct {
+	NodeId     int         `json:"nodeId" binding:"required"`
+	ToNodeId   *int        `json:"toNodeId"`
+	ToTagId    *int        `json:"toTagId"`
+	ChannelId  *int        `json:"channelId"`
+	Enabled    bool        `json:"enabled"`
+	Parameters *Parameters `json:"parameters"`
+}
+
+type UpdateSettingRequest struct {
+	Enabled    bool       `json:"enabled"`
+	Parameters Parameters `json:"parameters"`
+}
+
+type RunRequest struct {
+	NodeId int  `json:"nodeId" binding:"required"`
+	DryRun bool `json:"dryRun"`
+}
+
+// AddSetting adds the AutoFee corridor with its parameters and refreshes the corridor cache.
+func AddSetting(db *sqlx.DB, sr SettingRequest) (Setting, error) {
+	corridor := corridors.Corridor{
+		CorridorTypeId: corridors.AutoFee().CorridorTypeId,
+		ReferenceId:    &sr.NodeId,
+		FromNodeId:     &sr.NodeId,
+		ToNodeId:       sr.ToNodeId,
+		ToTagId:        sr.ToTagId,
+		ChannelId:      sr.ChannelId,
+	}
+	if sr.Enabled {
+		corridor.Flag = 1
+	}
+	added, err := corridors.AddCorridor(db, corridor)
+	if err != nil {
+		return Setting{}, errors.Wrap(err, "Add AutoFee corridor")
+	}
+	parameters := DefaultParameters()
+	if sr.Parameters != nil {
+		parameters = *sr.Parameters
+	}
+	parameters.CorridorId = added.CorridorId
+	parameters, err = setParameters(db, parameters)
+	if err != nil {
+		return Setting{}, err
+	}
+	if err := corridors.RefreshCorridorCacheByType(db, corridors.AutoFee()); err != nil {
+		return Setting{}, errors.Wrap(err, "Refresh AutoFee corridors")
+	}
+	return Setting{Corridor: *added, Parameters: parameters}, nil
+}
+
+// UpdateSetting enables or disables the AutoFee corridor and replaces its parameters, CorridorId is 0 when the
+// corridor doesn't exist.
+func UpdateSetting(db *sqlx.DB, corridorId int, usr UpdateSettingRequest) (Setting, error) {
+	setting, err := getSetting(db, corridorId)
+	if err != nil || setting.Corridor.CorridorId == 0 {
+		return Setting{}, err
+	}
+	flag := 0
+	if usr.Enabled {
+		flag = 1
+	}
+	if err := setCorridorFlag(db, corridorId, flag); err != nil {
+		return Setting{}, err
+	}
+	usr.Parameters.CorridorId = corridorId
+	if _, err := setParameters(db, usr.Parameters); err != nil {
+		return Setting{}, err
+	}
+	if err := corridors.RefreshCorridorCacheByType(db, corridors.AutoFee()); err != nil {
+		return Setting{}, errors.Wrap(err, "Refresh AutoFee corridors")
+	}
+	return getSetting(db, corridorId)
+}
+
+// RemoveSetting removes the AutoFee corridor with its parameters, the decisions are kept.
+func RemoveSetting(db *sqlx.DB, corridorId int) (int64, error) {
+	setting, err := getSetting(db, corridorId)
+	if err != nil || setting.Corridor.CorridorId == 0 {
+		return 0, err
+	}
+	removed, err := corridors.RemoveCorridor(db, corridorId)
+	if err != nil {
+		return 0, err
+	}
+	if err := corridors.RefreshCorridorCacheByType(db, corridors.AutoFee()); err != nil {
+		return removed, errors.Wrap(err, "Refresh AutoFee corridors")
+	}
+	return removed, nil
+}
+
+// decide computes the policy of a channel from its inputs. The fee rate moves from the maximum for an empty channel
+// to the minimum for a full one, goes up when the forwards drain the channel and doesn't undercut the peer while
+// less than half of the channel is ours. lastUpdate is when the policy of the channel was last updated.
+func decide(parameters Parameters, inputs Inputs, lastUpdate time.Time, now time.Time) Decision {
+	decision := Decision{
+		Inputs:         inputs,
+		NewFeeRatePpm:  inputs.FeeRatePpm,
+		NewBaseFeeMsat: inputs.BaseFeeMsat,
+		NewMaxHtlcMsat: inputs.MaxHtlcMsat,
+	}
+	if inputs.Capacity <= 0 {
+		decision.Status = Unchanged
+		decision.Reason = "The channel has no capacity."
+		return decision
+	}
+	localRatio := float64(inputs.LocalBalance) / float64(inputs.Capacity)
+	var reasons []string
+
+	target := float64(parameters.MaxFeeRatePpm) - float64(parameters.MaxFeeRatePpm-parameters.MinFeeRatePpm)*localRatio
+	reasons = append(reasons, fmt.Sprintf("local balance %.0f%% targets %.0f ppm", localRatio*100, target))
+
+	flow := inputs.OutgoingFlowMsat + inputs.IncomingFlowMsat
+	if flow > 0 {
+		netOutflow := float64(inputs.OutgoingFlowMsat-inputs.IncomingFlowMsat) / float64(flow)
+		target *= 1 + flowFactor*netOutflow
+		reasons = append(reasons, fmt.Sprintf("net outflow %.0f%% adjusts it to %.0f ppm", netOutflow*100, target))
+	}
+
+	if inputs.PeerFeeRatePpm != nil && localRatio < 0.5 && float64(*inputs.PeerFeeRatePpm) > target {
+		target = float64(*inputs.PeerFeeRatePpm)
+		reasons = append(reasons, fmt.Sprintf("raised to the peer's %d ppm", *inputs.PeerFeeRatePpm))
+	}
+
+	newFeeRate := int64(math.Round(target))
+	if newFeeRate < parameters.MinFeeRatePpm {
+		newFeeRate = parameters.MinFeeRatePpm
+	}
+	if newFeeRate > parameters.MaxFeeRatePpm {
+		newFeeRate = parameters.MaxFeeRatePpm
+	}
+	if parameters.MaxStepPpm > 0 {
+		if newFeeRate > inputs.FeeRatePpm+parameters.MaxStepPpm {
+			newFeeRate = inputs.FeeRatePpm + parameters.MaxStepPpm
+			reasons = append(reasons, fmt.Sprintf("limited to a step of %d ppm", parameters.MaxStepPpm))
+		}
+		if newFeeRate < inputs.FeeRatePpm-parameters.MaxStepPpm {
+			newFeeRate = inputs.FeeRatePpm - parameters.MaxStepPpm
+			reasons = append(reasons, fmt.Sprintf("limited to a step of %d ppm", parameters.MaxStepPpm))
+		}
+	}
+	if abs(newFeeRate-inputs.FeeRatePpm) >= parameters.MinChangePpm && newFeeRate != inputs.FeeRatePpm {
+		decision.NewFeeRatePpm = newFeeRate
+	}
+
+	if parameters.BaseFeeMsat != nil {
+		decision.NewBaseFeeMsat = *parameters.BaseFeeMsat
+	}
+
+	if parameters.MaxHtlcRatio != nil {
+		maxHtlc := uint64(float64(inputs.LocalBalance)**parameters.MaxHtlcRatio) * 1000
+		if maxHtlc < minMaxHtlcMsat {
+			maxHtlc = minMaxHtlcMsat
+		}
+		if math.Abs(float64(maxHtlc)-float64(inputs.MaxHtlcMsat)) > maxHtlcTolerance*float64(inputs.MaxHtlcMsat) {
+			decision.NewMaxHtlcMsat = maxHtlc
+		}
+	}
+
+	decision.Reason = strings.Join(reasons, ", ") + "."
+	switch {
+	case !decision.changed():
+		decision.Status = Unchanged
+	case now.Sub(lastUpdate) < time.Duration(parameters.MinUpdateIntervalSeconds)*time.Second:
+		decision.Status = RateLimited
+	case parameters.DryRun:
+		decision.Status = DryRun
+	default:
+		decision.Status = Applied
+	}
+	return decision
+}
+
+func (decision Decision) changed() bool {
+	return decision.NewFeeRatePpm != decision.FeeRatePpm ||
+		decision.NewBaseFeeMsat != decision.BaseFeeMsat ||
+		decision.NewMaxHtlcMsat != decision.MaxHtlcMsat
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// Start automates the fees of every active node each interval until the context is done, an interval of 0 disables
+// the automation. With dryRun no policy is ever updated.
+func Start(ctx context.Context, db *sqlx.DB, eventChannel chan interface{}, interval time.Duration, dryRun bool) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		nodes, err := settings.GetActiveNodesConnectionDetails(db)
+		if err != nil {
+			log.Error().Err(err).Msg("Obtaining the nodes for the fee automation")
+			continue
+		}
+		for _, node := range nodes {
+			client, err := settings.GetNodeClient(db, node.NodeId)
+			if err != nil {
+				log.Error().Err(err).Msgf("Connecting to node %v for the fee automation", node.NodeId)
+				continue
+			}
+			_, err = RunNode(ctx, db, client, commons.GetNodeSettingsByNodeId(node.NodeId), dryRun, eventChannel)
+			if err != nil {
+				log.Error().Err(err).Msgf("Automating the fees of node %v", node.NodeId)
+			}
+		}
+	}
+}
+
+// RunNode decides the policy of every open channel of the node of which the best AutoFee corridor is enabled and
+// applies the changes unless dryRun is set or the corridor is in dry-run mode. Every decision is stored.
+func RunNode(ctx context.Context, db *sqlx.DB, client node_client.NodeClient, nodeSettings commons.ManagedNodeSettings,
+	dryRun bool, eventChannel chan interface{}) ([]Decision, error) {
+
+	resp, err := client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "List channels")
+	}
+	parametersByCorridor, err := getParametersByCorridor(db)
+	if err != nil {
+		return nil, err
+	}
+	tagIds, err := getChannelTagIds(db, nodeSettings.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	decisions := []Decision{}
+	for _, channel := range resp.Channels {
+		channelId := commons.GetChannelIdFromShortChannelId(channels.ConvertLNDShortChannelID(channel.ChanId))
+		if channelId == 0 {
+			continue
+		}
+		peerNodeId := commons.GetNodeIdFromPublicKey(channel.RemotePubkey, nodeSettings.Chain, nodeSettings.Network)
+		corridor := bestCorridor(nodeSettings.NodeId, peerNodeId, channelId, tagIds[channelId])
+		if corridor.Flag != 1 {
+			continue
+		}
+		parameters, exists := parametersByCorridor[corridor.CorridorId]
+		if !exists {
+			parameters = DefaultParameters()
+		}
+		parameters.DryRun = parameters.DryRun || dryRun
+
+		decision, err := runChannel(ctx, db, client, nodeSettings, channel, channelId, parameters)
+		if err != nil {
+			log.Error().Err(err).Msgf("Automating the fees of channel %v", channelId)
+			continue
+		}
+		decision.CorridorId = &corridor.CorridorId
+		decision, err = addDecision(db, decision)
+		if err != nil {
+			return decisions, err
+		}
+		decisions = append(decisions, decision)
+		sendDecisionEvent(eventChannel, decision)
+	}
+	return decisions, nil
+}
+
+func runChannel(ctx context.Context, db *sqlx.DB, client node_client.NodeClient,
+	nodeSettings commons.ManagedNodeSettings, channel *lnrpc.Channel, channelId int,
+	parameters Parameters) (Decision, error) {
+
+	edge, err := client.GetChanInfo(ctx, &lnrpc.ChanInfoRequest{ChanId: channel.ChanId})
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "Get channel info")
+	}
+	policy, peerPolicy := edge.Node1Policy, edge.Node2Policy
+	if edge.Node2Pub == nodeSettings.PublicKey {
+		policy, peerPolicy = edge.Node2Policy, edge.Node1Policy
+	}
+	if policy == nil {
+		return Decision{}, errors.New("The node has no policy for the channel")
+	}
+	now := time.Now().UTC()
+	outgoing, incoming, err := getChannelFlow(db, nodeSettings.NodeId, channelId,
+		now.Add(-time.Duration(parameters.FlowWindowSeconds)*time.Second))
+	if err != nil {
+		return Decision{}, err
+	}
+	inputs := Inputs{
+		Capacity:         channel.Capacity,
+		LocalBalance:     channel.LocalBalance,
+		OutgoingFlowMsat: outgoing,
+		IncomingFlowMsat: incoming,
+		FeeRatePpm:       policy.FeeRateMilliMsat,
+		BaseFeeMsat:      policy.FeeBaseMsat,
+		MaxHtlcMsat:      policy.MaxHtlcMsat,
+	}
+	if peerPolicy != nil {
+		inputs.PeerFeeRatePpm = &peerPolicy.FeeRateMilliMsat
+		inputs.PeerBaseFeeMsat = &peerPolicy.FeeBaseMsat
+	}
+
+	decision := decide(parameters, inputs, time.Unix(int64(policy.LastUpdate), 0), now)
+	decision.NodeId = nodeSettings.NodeId
+	decision.ChannelId = channelId
+	decision.CreatedOn = now
+	if decision.Status != Applied {
+		return decision, nil
+	}
+
+	policyReq, err := policyRequest(channel.ChannelPoint, policy.TimeLockDelta, decision)
+	if err == nil {
+		var policyResp *lnrpc.PolicyUpdateResponse
+		policyResp, err = client.UpdateChannelPolicy(ctx, policyReq)
+		if err == nil && len(policyResp.GetFailedUpdates()) != 0 {
+			err = errors.New(policyResp.GetFailedUpdates()[0].UpdateError)
+		}
+	}
+	if err != nil {
+		decision.Status = Failed
+		message := err.Error()
+		decision.Error = &message
+	}
+	return decision, nil
+}
+
+func policyRequest(channelPoint string, timeLockDelta uint32, decision Decision) (*lnrpc.PolicyUpdateRequest, error) {
+	fundingTransactionHash, outputIndex := channels.ParseChannelPoint(channelPoint)
+	if fundingTransactionHash == "" {
+		return nil, errors.Newf("Invalid channel point %v", channelPoint)
+	}
+	if timeLockDelta < minTimeLockDelta {
+		timeLockDelta = minTimeLockDelta
+	}
+	return &lnrpc.PolicyUpdateRequest{
+		Scope: &lnrpc.PolicyUpdateRequest_ChanPoint{ChanPoint: &lnrpc.ChannelPoint{
+			FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{FundingTxidStr: fundingTransactionHash},
+			OutputIndex: uint32(outputIndex),
+		}},
+		BaseFeeMsat:   decision.NewBaseFeeMsat,
+		FeeRatePpm:    uint32(decision.NewFeeRatePpm),
+		TimeLockDelta: timeLockDelta,
+		MaxHtlcMsat:   decision.NewMaxHtlcMsat,
+	}, nil
+}
+
+// bestCorridor returns the AutoFee corridor with the highest priority for the channel, the tags of the channel are
+// tried as the destination of the corridor. The reference of an AutoFee corridor is the node of which the fees are
+// automated.
+func bestCorridor(nodeId int, peerNodeId int, channelId int, tagIds []int) corridors.Corridor {
+	key := corridors.CorridorKey{CorridorType: corridors.AutoFee(), ReferenceId: nodeId, FromNodeId: nodeId,
+		ToNodeId: peerNodeId, ChannelId: channelId}
+	best := corridors.GetBestCorridor(key)
+	for _, tagId := range tagIds {
+		key.ToTagId = tagId
+		corridor := corridors.GetBestCorridor(key)
+		if corridor.CorridorId != 0 && (best.CorridorId == 0 || corridor.Priority > best.Priority) {
+			best = corridor
+		}
+	}
+	return best
+}
+
+func sendDecisionEvent(eventChannel chan interface{}, decision Decision) {
+	if eventChannel == nil || decision.Status == Unchanged {
+		return
+	}
+	event := broadcast.AutoFeeEvent{
+		EventData:         broadcast.EventData{EventTime: decision.CreatedOn, NodeId: decision.NodeId},
+		AutoFeeDecisionId: decision.AutoFeeDecisionId,
+		ChannelId:         decision.ChannelId,
+		Status:            string(decision.Status),
+		FeeRatePpm:        decision.FeeRatePpm,
+		NewFeeRatePpm:     decision.NewFeeRatePpm,
+		BaseFeeMsat:       decision.BaseFeeMsat,
+		NewBaseFeeMsat:    decision.NewBaseFeeMsat,
+		MaxHtlcMsat:       decision.MaxHtlcMsat,
+		NewMaxHtlcMsat:    decision.NewMaxHtlcMsat,
+	}
+	if decision.Error != nil {
+		event.Error = *decision.Error
+	}
+	eventChannel <- event
+}
@@ -0,0 +1,85 @@
+package auto_fee
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func TestDecide(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-2 * time.Hour)
+	peerFeeRate := int64(2050)
+	maxHtlcRatio := 0.5
+	dryRun := DefaultParameters()
+	dryRun.DryRun = true
+	withMaxHtlc := DefaultParameters()
+	withMaxHtlc.MaxHtlcRatio = &maxHtlcRatio
+
+	tests := []struct {
+		name        string
+		parameters  Parameters
+		inputs      Inputs
+		lastUpdate  time.Time
+		status      Status
+		feeRatePpm  int64
+		maxHtlcMsat uint64
+	}{
+		{"Full channel lowers the fee by one step", DefaultParameters(),
+			Inputs{Capacity: 1000000, LocalBalance: 1000000, FeeRatePpm: 500}, longAgo, Applied, 400, 0},
+		{"Empty channel raises the fee to the maximum", DefaultParameters(),
+			Inputs{Capacity: 1000000, LocalBalance: 0, FeeRatePpm: 2450}, longAgo, Applied, 2500, 0},
+		{"Small changes are skipped", DefaultParameters(),
+			Inputs{Capacity: 1000000, LocalBalance: 500000, FeeRatePpm: 1248}, longAgo, Unchanged, 1248, 0},
+		{"Draining forwards raise the fee", DefaultParameters(),
+			Inputs{Capacity: 1000000, LocalBalance: 500000, OutgoingFlowMsat: 3000000, IncomingFlowMsat: 1000000,
+				FeeRatePpm: 1350}, longAgo, Applied, 1407, 0},
+		{"The peer isn't undercut", DefaultParameters(),
+			Inputs{Capacity: 1000000, LocalBalance: 200000, PeerFeeRatePpm: &peerFeeRate, FeeRatePpm: 2000}, longAgo,
+			Applied, 2050, 0},
+		{"Recent updates are rate limited", DefaultParameters(),
+			Inputs{Capacity: 1000000, LocalBalance: 1000000, FeeRatePpm: 500}, now.Add(-10 * time.Minute),
+			RateLimited, 400, 0},
+		{"Dry run", dryRun,
+			Inputs{Capacity: 1000000, LocalBalance: 1000000, FeeRatePpm: 500}, longAgo, DryRun, 400, 0},
+		{"Max HTLC follows the local balance", withMaxHtlc,
+			Inputs{Capacity: 1000000, LocalBalance: 500000, FeeRatePpm: 1251, MaxHtlcMsat: 1000000000}, longAgo,
+			Applied, 1251, 250000000},
+		{"Max HTLC within the tolerance", withMaxHtlc,
+			Inputs{Capacity: 1000000, LocalBalance: 500000, FeeRatePpm: 1251, MaxHtlcMsat: 260000000}, longAgo,
+			Unchanged, 1251, 260000000},
+		{"No capacity", DefaultParameters(),
+			Inputs{FeeRatePpm: 500}, longAgo, Unchanged, 500, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := decide(test.parameters, test.inputs, test.lastUpdate, now)
+			if decision.Status != test.status || decision.NewFeeRatePpm != test.feeRatePpm ||
+				decision.NewMaxHtlcMsat != test.maxHtlcMsat {
+				t.Errorf("decide()\nGot:\n%v %v %v\nWant:\n%v %v %v\n",
+					decision.Status, decision.NewFeeRatePpm, decision.NewMaxHtlcMsat,
+					test.status, test.feeRatePpm, test.maxHtlcMsat)
+			}
+		})
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	serverError := &server_errors.ServerError{}
+	validateParameters(DefaultParameters(), serverError)
+	if serverError.Errors.Fields != nil {
+		t.Errorf("validateParameters() defaults\nGot:\n%v\nWant:\n%v\n", serverError.Errors.Fields, nil)
+	}
+
+	invalid := DefaultParameters()
+	invalid.MaxFeeRatePpm = 0
+	ratio := 1.5
+	invalid.MaxHtlcRatio = &ratio
+	serverError = &server_errors.ServerError{}
+	validateParameters(invalid, serverError)
+	if len(serverError.Errors.Fields) != 2 {
+		t.Errorf("validateParameters() invalid\nGot:\n%v\nWant:\n%v\n", serverError.Errors.Fields,
+			"maxFeeRatePpm and maxHtlcRatio")
+	}
+}
@@ -0,0 +1,174 @@
+package auto_fee
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/corridors"
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getParametersByCorridor(db *sqlx.DB) (map[int]Parameters, error) {
+	var rows []Parameters
+	err := db.Select(&rows, `SELECT * FROM auto_fee_parameters;`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	parametersByCorridor := make(map[int]Parameters, len(rows))
+	for _, parameters := range rows {
+		parametersByCorridor[parameters.CorridorId] = parameters
+	}
+	return parametersByCorridor, nil
+}
+
+// getSettings returns every AutoFee corridor with its parameters, a nodeId of 0 returns them for all nodes.
+func getSettings(db *sqlx.DB, nodeId int) ([]Setting, error) {
+	var corridorRows []corridors.Corridor
+	err := db.Select(&corridorRows, `
+		SELECT * FROM corridor
+		WHERE corridor_type_id=$1 AND ($2=0 OR reference_id=$2)
+		ORDER BY corridor_id;`, corridors.AutoFee().CorridorTypeId, nodeId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	parametersByCorridor, err := getParametersByCorridor(db)
+	if err != nil {
+		return nil, err
+	}
+	settings := []Setting{}
+	for _, corridor := range corridorRows {
+		settings = append(settings, newSetting(corridor, parametersByCorridor))
+	}
+	return settings, nil
+}
+
+// getSetting returns the AutoFee corridor with its parameters, CorridorId is 0 when it doesn't exist.
+func getSetting(db *sqlx.DB, corridorId int) (Setting, error) {
+	var corridor corridors.Corridor
+	err := db.Get(&corridor, `SELECT * FROM corridor WHERE corridor_id=$1 AND corridor_type_id=$2;`,
+		corridorId, corridors.AutoFee().CorridorTypeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Setting{}, nil
+		}
+		return Setting{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	parametersByCorridor, err := getParametersByCorridor(db)
+	if err != nil {
+		return Setting{}, err
+	}
+	return newSetting(corridor, parametersByCorridor), nil
+}
+
+func newSetting(corridor corridors.Corridor, parametersByCorridor map[int]Parameters) Setting {
+	parameters, exists := parametersByCorridor[corridor.CorridorId]
+	if !exists {
+		parameters = DefaultParameters()
+		parameters.CorridorId = corridor.CorridorId
+	}
+	return Setting{Corridor: corridor, Parameters: parameters}
+}
+
+func setParameters(db *sqlx.DB, parameters Parameters) (Parameters, error) {
+	now := time.Now().UTC()
+	parameters.CreatedOn = now
+	parameters.UpdatedOn = now
+	err := db.QueryRowx(`
+		INSERT INTO auto_fee_parameters (corridor_id, min_fee_rate_ppm, max_fee_rate_ppm, base_fee_msat,
+			max_htlc_ratio, max_step_ppm, min_change_ppm, min_update_interval_seconds, flow_window_seconds, dry_run,
+			created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (corridor_id) DO UPDATE SET min_fee_rate_ppm=EXCLUDED.min_fee_rate_ppm,
+			max_fee_rate_ppm=EXCLUDED.max_fee_rate_ppm, base_fee_msat=EXCLUDED.base_fee_msat,
+			max_htlc_ratio=EXCLUDED.max_htlc_ratio, max_step_ppm=EXCLUDED.max_step_ppm,
+			min_change_ppm=EXCLUDED.min_change_ppm, min_update_interval_seconds=EXCLUDED.min_update_interval_seconds,
+			flow_window_seconds=EXCLUDED.flow_window_seconds, dry_run=EXCLUDED.dry_run, updated_on=EXCLUDED.updated_on
+		RETURNING created_on;`,
+		parameters.CorridorId, parameters.MinFeeRatePpm, parameters.MaxFeeRatePpm, parameters.BaseFeeMsat,
+		parameters.MaxHtlcRatio, parameters.MaxStepPpm, parameters.MinChangePpm, parameters.MinUpdateIntervalSeconds,
+		parameters.FlowWindowSeconds, parameters.DryRun, parameters.CreatedOn, parameters.UpdatedOn).
+		Scan(&parameters.CreatedOn)
+	if err != nil {
+		return Parameters{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return parameters, nil
+}
+
+func setCorridorFlag(db *sqlx.DB, corridorId int, flag int) error {
+	_, err := db.Exec(`UPDATE corridor SET flag=$1, updated_on=$2 WHERE corridor_id=$3;`,
+		flag, time.Now().UTC(), corridorId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func getChannelTagIds(db *sqlx.DB, nodeId int) (map[int][]int, error) {
+	var rows []struct {
+		ChannelId int `db:"channel_id"`
+		TagId     int `db:"tag_id"`
+	}
+	err := db.Select(&rows, `SELECT channel_id, tag_id FROM channel_tag WHERE from_node_id=$1;`, nodeId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	tagIds := make(map[int][]int)
+	for _, row := range rows {
+		tagIds[row.ChannelId] = append(tagIds[row.ChannelId], row.TagId)
+	}
+	return tagIds, nil
+}
+
+// getChannelFlow returns the amounts forwarded out of and into the channel since the given time.
+func getChannelFlow(db *sqlx.DB, nodeId int, channelId int, since time.Time) (int64, int64, error) {
+	var flow struct {
+		Outgoing int64 `db:"outgoing"`
+		Incoming int64 `db:"incoming"`
+	}
+	err := db.Get(&flow, `
+		SELECT
+			COALESCE(SUM(outgoing_amount_msat) FILTER (WHERE outgoing_channel_id=$2), 0) AS outgoing,
+			COALESCE(SUM(incoming_amount_msat) FILTER (WHERE incoming_channel_id=$2), 0) AS incoming
+		FROM forward
+		WHERE node_id=$1 AND time>=$3 AND (outgoing_channel_id=$2 OR incoming_channel_id=$2);`,
+		nodeId, channelId, since)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return flow.Outgoing, flow.Incoming, nil
+}
+
+func addDecision(db *sqlx.DB, decision Decision) (Decision, error) {
+	err := db.QueryRowx(`
+		INSERT INTO auto_fee_decision (node_id, channel_id, corridor_id, status, reason, capacity, local_balance,
+			outgoing_flow_msat, incoming_flow_msat, peer_fee_rate_ppm, peer_base_fee_msat, fee_rate_ppm, base_fee_msat,
+			max_htlc_msat, new_fee_rate_ppm, new_base_fee_msat, new_max_htlc_msat, error, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		RETURNING auto_fee_decision_id;`,
+		decision.NodeId, decision.ChannelId, decision.CorridorId, decision.Status, decision.Reason, decision.Capacity,
+		decision.LocalBalance, decision.OutgoingFlowMsat, decision.IncomingFlowMsat, decision.PeerFeeRatePpm,
+		decision.PeerBaseFeeMsat, decision.FeeRatePpm, decision.BaseFeeMsat, decision.MaxHtlcMsat,
+		decision.NewFeeRatePpm, decision.NewBaseFeeMsat, decision.NewMaxHtlcMsat, decision.Error, decision.CreatedOn).
+		Scan(&decision.AutoFeeDecisionId)
+	if err != nil {
+		return Decision{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return decision, nil
+}
+
+// getDecisions returns the most recent decisions, a nodeId or channelId of 0 doesn't filter on it.
+func getDecisions(db *sqlx.DB, nodeId int, channelId int, limit int) ([]Decision, error) {
+	decisions := []Decision{}
+	err := db.Select(&decisions, `
+		SELECT * FROM auto_fee_decision
+		WHERE ($1=0 OR node_id=$1) AND ($2=0 OR channel_id=$2)
+		ORDER BY auto_fee_decision_id DESC
+		LIMIT $3;`, nodeId, channelId, limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return decisions, nil
+}
@@ -0,0 +1,247 @@
+package auto_fee
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultDecisionLimit = 100
+	maxDecisionLimit     = 1000
+)
+
+func RegisterAutoFeeRoutes(r *gin.RouterGroup, db *sqlx.DB, eventChannel chan interface{}) {
+	r.GET("", func(c *gin.Context) { getSettingsHandler(c, db) })
+	r.POST("", func(c *gin.Context) { addSettingHandler(c, db) })
+	r.PUT(":corridorId", func(c *gin.Context) { updateSettingHandler(c, db) })
+	r.DELETE(":corridorId", func(c *gin.Context) { removeSettingHandler(c, db) })
+	r.GET("decisions", func(c *gin.Context) { getDecisionsHandler(c, db) })
+	r.POST("run", func(c *gin.Context) { runHandler(c, db, eventChannel) })
+}
+
+func getSettingsHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId := 0
+	if c.Query("nodeId") != "" {
+		var err error
+		nodeId, err = strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+	}
+	autoFeeSettings, err := getSettings(db, nodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting AutoFee settings.")
+		return
+	}
+	c.JSON(http.StatusOK, autoFeeSettings)
+}
+
+func addSettingHandler(c *gin.Context, db *sqlx.DB) {
+	var sr SettingRequest
+	if err := c.BindJSON(&sr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	serverError := &server_errors.ServerError{}
+	if commons.GetNodeSettingsByNodeId(sr.NodeId).NodeId == 0 {
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+	}
+	if sr.Parameters != nil {
+		validateParameters(*sr.Parameters, serverError)
+	}
+	if serverError.Errors.Fields != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	setting, err := AddSetting(db, sr)
+	audit.Record(db, c, audit.AddAutoFee, sr, setting, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding AutoFee setting.")
+		return
+	}
+	c.JSON(http.StatusOK, setting)
+}
+
+func updateSettingHandler(c *gin.Context, db *sqlx.DB) {
+	corridorId, err := strconv.Atoi(c.Param("corridorId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse corridorId in the request.")
+		return
+	}
+	var usr UpdateSettingRequest
+	if err := c.BindJSON(&usr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	serverError := &server_errors.ServerError{}
+	validateParameters(usr.Parameters, serverError)
+	if serverError.Errors.Fields != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	setting, err := UpdateSetting(db, corridorId, usr)
+	audit.Record(db, c, audit.SetAutoFee, usr, setting, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Updating AutoFee corridorId: %v", corridorId))
+		return
+	}
+	if setting.Corridor.CorridorId == 0 {
+		server_errors.SendUnprocessableEntity(c, "AutoFee setting not found.")
+		return
+	}
+	c.JSON(http.StatusOK, setting)
+}
+
+func removeSettingHandler(c *gin.Context, db *sqlx.DB) {
+	corridorId, err := strconv.Atoi(c.Param("corridorId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse corridorId in the request.")
+		return
+	}
+	count, err := RemoveSetting(db, corridorId)
+	audit.Record(db, c, audit.RemoveAutoFee, corridorId, count, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing AutoFee corridorId: %v", corridorId))
+		return
+	}
+	if count == 0 {
+		server_errors.SendUnprocessableEntity(c, "AutoFee setting not found.")
+		return
+	}
+	c.JSON(http.StatusOK,
+		map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v AutoFee setting(s).", count)})
+}
+
+func getDecisionsHandler(c *gin.Context, db *sqlx.DB) {
+	limit := defaultDecisionLimit
+	var err error
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxDecisionLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxDecisionLimit))
+			return
+		}
+	}
+	nodeId := 0
+	if c.Query("nodeId") != "" {
+		nodeId, err = strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+	}
+	channelId := 0
+	if c.Query("channelId") != "" {
+		channelId, err = strconv.Atoi(c.Query("channelId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse channelId in the request.")
+			return
+		}
+	}
+	decisions, err := getDecisions(db, nodeId, channelId, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting AutoFee decisions.")
+		return
+	}
+	c.JSON(http.StatusOK, decisions)
+}
+
+func runHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}) {
+	var rr RunRequest
+	if err := c.BindJSON(&rr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	nodeSettings := commons.GetNodeSettingsByNodeId(rr.NodeId)
+	if nodeSettings.NodeId == 0 {
+		serverError := &server_errors.ServerError{}
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	client, err := settings.GetNodeClient(db, rr.NodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Connecting to node.")
+		return
+	}
+	decisions, err := RunNode(c.Request.Context(), db, client, nodeSettings, rr.DryRun, eventChannel)
+	audit.Record(db, c, audit.RunAutoFee, rr, decisions, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Automating the fees of nodeId: %v", rr.NodeId))
+		return
+	}
+	c.JSON(http.StatusOK, decisions)
+}
+
+// validateParameters adds an error for every parameter that's out of range.
+func validateParameters(parameters Parameters, serverError *server_errors.ServerError) {
+	if parameters.MinFeeRatePpm < 0 {
+		serverError.AddFieldError("minFeeRatePpm", "The minimum fee rate can't be negative.")
+	}
+	if parameters.MaxFeeRatePpm < parameters.MinFeeRatePpm {
+		serverError.AddFieldError("maxFeeRatePpm", "The maximum fee rate can't be below the minimum fee rate.")
+	}
+	if parameters.BaseFeeMsat != nil && *parameters.BaseFeeMsat < 0 {
+		serverError.AddFieldError("baseFeeMsat", "The base fee can't be negative.")
+	}
+	if parameters.MaxHtlcRatio != nil && (*parameters.MaxHtlcRatio <= 0 || *parameters.MaxHtlcRatio > 1) {
+		serverError.AddFieldError("maxHtlcRatio", "The max HTLC ratio needs to be above 0 and at most 1.")
+	}
+	if parameters.MaxStepPpm < 0 {
+		serverError.AddFieldError("maxStepPpm", "The maximum step can't be negative.")
+	}
+	if parameters.MinChangePpm < 0 {
+		serverError.AddFieldError("minChangePpm", "The minimum change can't be negative.")
+	}
+	if parameters.MinUpdateIntervalSeconds < 0 {
+		serverError.AddFieldError("minUpdateIntervalSeconds", "The minimum update interval can't be negative.")
+	}
+	if parameters.FlowWindowSeconds <= 0 {
+		serverError.AddFieldError("flowWindowSeconds", "The flow window needs to be positive.")
+	}
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the AutoFee corridors with their parameters",
+			QueryParameters: []string{"nodeId"},
+			Response:        []Setting{},
+		},
+		"POST ": {
+			Summary:     "Automate the fees of the channels of a node, a peer, a tag or a single channel",
+			RequestBody: SettingRequest{},
+			Response:    Setting{},
+		},
+		"PUT :corridorId": {
+			Summary:     "Enable or disable an AutoFee corridor and replace its parameters",
+			RequestBody: UpdateSettingRequest{},
+			Response:    Setting{},
+		},
+		"DELETE :corridorId": {
+			Summary: "Remove an AutoFee corridor, its decisions are kept",
+		},
+		"GET decisions": {
+			Summary:         "List the most recent fee decisions with their inputs",
+			QueryParameters: []string{"nodeId", "channelId", "limit"},
+			Response:        []Decision{},
+		},
+		"POST run": {
+			Summary:     "Automate the fees of a node now, with dryRun nothing is applied",
+			RequestBody: RunRequest{},
+			Response:    []Decision{},
+		},
+	}
+}
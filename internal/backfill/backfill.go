@@ -0,0 +1,260 @@
+package backfill
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+// Kind is the history that a job imports.
+type Kind string
+
+const (
+	Forwards     = Kind("forwards")
+	Payments     = Kind("payments")
+	Invoices     = Kind("invoices")
+	Transactions = Kind("transactions")
+)
+
+// AllKinds is the order in which a job imports the kinds.
+//
+//nolint:gochecknoglobals
+var AllKinds = []Kind{Forwards, Payments, Invoices, Transactions}
+
+func (kind Kind) IsValid() bool {
+	switch kind {
+	case Forwards, Payments, Invoices, Transactions:
+		return true
+	}
+	return false
+}
+
+type JobStatus string
+
+const (
+	// Pending jobs are waiting to be claimed, interrupted jobs are pending again.
+	Pending   = JobStatus("pending")
+	Running   = JobStatus("running")
+	Completed = JobStatus("completed")
+	Failed    = JobStatus("failed")
+	Cancelled = JobStatus("cancelled")
+)
+
+const (
+	pollInterval      = 30 * time.Second
+	heartbeatInterval = 15 * time.Second
+	// staleHeartbeat is how long a running job can go without heartbeat before another worker claims it.
+	staleHeartbeat = time.Minute
+)
+
+type Job struct {
+	BackfillJobId int            `json:"backfillJobId" db:"backfill_job_id"`
+	NodeId        int            `json:"nodeId" db:"node_id"`
+	FromTime      time.Time      `json:"fromTime" db:"from_time"`
+	ToTime        time.Time      `json:"toTime" db:"to_time"`
+	Kinds         pq.StringArray `json:"kinds" db:"kinds"`
+	Status        JobStatus      `json:"status" db:"status"`
+	Error         *string        `json:"error" db:"error"`
+	HeartbeatOn   *time.Time     `json:"heartbeatOn" db:"heartbeat_on"`
+	CreatedOn     time.Time      `json:"createdOn" db:"created_on"`
+	UpdatedOn     time.Time      `json:"updatedOn" db:"updated_on"`
+	Checkpoints   []Checkpoint   `json:"checkpoints" db:"-"`
+}
+
+// Checkpoint is the progress of a job for a kind, IndexOffset is where the import continues.
+type Checkpoint struct {
+	BackfillJobId int       `json:"backfillJobId" db:"backfill_job_id"`
+	Kind          Kind      `json:"kind" db:"kind"`
+	IndexOffset   uint64    `json:"indexOffset" db:"index_offset"`
+	Stored        int64     `json:"stored" db:"stored"`
+	Done          bool      `json:"done" db:"done"`
+	UpdatedOn     time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+type JobRequest struct {
+	NodeId   int       `json:"nodeId" binding:"required"`
+	FromTime time.Time `json:"fromTime" binding:"required"`
+	// ToTime defaults to now.
+	ToTime *time.Time `json:"toTime"`
+	// Kinds defaults to all kinds.
+	Kinds []Kind `json:"kinds"`
+}
+
+// Runner imports the history of the job, it saves a checkpoint after each page with SaveCheckpoint and continues
+// from the checkpoints of the job when it was interrupted before.
+type Runner func(ctx context.Context, job Job) error
+
+//nolint:gochecknoglobals
+var jobAdded = make(chan struct{}, 1)
+
+// AddJob stores a pending job with an empty checkpoint per kind.
+func AddJob(db *sqlx.DB, jr JobRequest) (Job, error) {
+	job := Job{
+		NodeId:   jr.NodeId,
+		FromTime: jr.FromTime.UTC(),
+		ToTime:   time.Now().UTC(),
+		Kinds:    pq.StringArray{},
+		Status:   Pending,
+	}
+	if jr.ToTime != nil {
+		job.ToTime = jr.ToTime.UTC()
+	}
+	kinds := jr.Kinds
+	if len(kinds) == 0 {
+		kinds = AllKinds
+	}
+	for _, kind := range kinds {
+		job.Kinds = append(job.Kinds, string(kind))
+	}
+	job, err := addJob(db, job)
+	if err != nil {
+		return Job{}, err
+	}
+	select {
+	case jobAdded <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Start runs the pending jobs one at a time with run until the context is done. Jobs that were running when Torq
+// stopped are continued from their checkpoints.
+func Start(ctx context.Context, db *sqlx.DB, eventChannel chan interface{}, run Runner) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			job, err := claimJob(db, 0)
+			if err != nil {
+				log.Error().Err(err).Msg("Claiming backfill job")
+				break
+			}
+			if job.BackfillJobId == 0 {
+				break
+			}
+			if err := runJob(ctx, db, job, eventChannel, run); err != nil {
+				log.Error().Err(err).Msgf("Backfill job %v failed", job.BackfillJobId)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-jobAdded:
+		}
+	}
+}
+
+// RunJob claims the job and runs it in the foreground, it fails when the job is done or claimed elsewhere.
+func RunJob(ctx context.Context, db *sqlx.DB, backfillJobId int, eventChannel chan interface{}, run Runner) error {
+	job, err := claimJob(db, backfillJobId)
+	if err != nil {
+		return err
+	}
+	if job.BackfillJobId == 0 {
+		return errors.Newf("Backfill job %v is not pending or is running elsewhere", backfillJobId)
+	}
+	return runJob(ctx, db, job, eventChannel, run)
+}
+
+// runJob keeps the heartbeat of the job going while it runs and stops it when the job is cancelled.
+// A job that is interrupted by the context is pending again so it continues later.
+func runJob(ctx context.Context, db *sqlx.DB, job Job, eventChannel chan interface{}, run Runner) error {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			running, err := heartbeat(db, job.BackfillJobId)
+			if err != nil {
+				log.Error().Err(err).Msgf("Backfill job %v heartbeat", job.BackfillJobId)
+				continue
+			}
+			if !running {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	checkpoints, err := getCheckpoints(db, job.BackfillJobId)
+	if err != nil {
+		return err
+	}
+	job.Checkpoints = checkpoints
+	sendJobEvent(eventChannel, job)
+	log.Info().Msgf("Running backfill job %v for node id: %v", job.BackfillJobId, job.NodeId)
+	err = run(jobCtx, job)
+	switch {
+	case ctx.Err() != nil:
+		job.Status = Pending
+	case jobCtx.Err() != nil:
+		job.Status = Cancelled
+	case err != nil:
+		job.Status = Failed
+		message := err.Error()
+		job.Error = &message
+	default:
+		job.Status = Completed
+	}
+	if serr := finishJob(db, job); serr != nil {
+		log.Error().Err(serr).Msgf("Storing the status of backfill job %v", job.BackfillJobId)
+	}
+	if checkpoints, serr := getCheckpoints(db, job.BackfillJobId); serr == nil {
+		job.Checkpoints = checkpoints
+	}
+	sendJobEvent(eventChannel, job)
+	if job.Status == Failed {
+		return err
+	}
+	return nil
+}
+
+// SaveCheckpoint stores the progress of a kind and sends it as event.
+func SaveCheckpoint(db *sqlx.DB, job Job, checkpoint Checkpoint, eventChannel chan interface{}) error {
+	checkpoint.BackfillJobId = job.BackfillJobId
+	if err := setCheckpoint(db, checkpoint); err != nil {
+		return err
+	}
+	if eventChannel != nil {
+		eventChannel <- broadcast.BackfillEvent{
+			EventData:     broadcast.EventData{EventTime: time.Now().UTC(), NodeId: job.NodeId},
+			BackfillJobId: job.BackfillJobId,
+			Status:        string(Running),
+			Kind:          string(checkpoint.Kind),
+			Stored:        checkpoint.Stored,
+			Done:          checkpoint.Done,
+		}
+	}
+	return nil
+}
+
+func sendJobEvent(eventChannel chan interface{}, job Job) {
+	if eventChannel == nil {
+		return
+	}
+	event := broadcast.BackfillEvent{
+		EventData:     broadcast.EventData{EventTime: time.Now().UTC(), NodeId: job.NodeId},
+		BackfillJobId: job.BackfillJobId,
+		Status:        string(job.Status),
+	}
+	for _, checkpoint := range job.Checkpoints {
+		event.Stored += checkpoint.Stored
+	}
+	if job.Error != nil {
+		event.Error = *job.Error
+	}
+	eventChannel <- event
+}
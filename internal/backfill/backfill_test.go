@@ -0,0 +1,42 @@
+package backfill
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestValidateJobRequest(t *testing.T) {
+	go commons.ManagedNodeCache(commons.ManagedNodeChannel, nil)
+	commons.SetTorqNode(1, commons.Active, "PublicKey1", commons.Bitcoin, commons.SigNet)
+
+	now := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	from := now.AddDate(0, 0, -7)
+	later := now.AddDate(0, 0, -8)
+	tests := []struct {
+		name   string
+		jr     JobRequest
+		fields []string
+	}{
+		{"Valid", JobRequest{NodeId: 1, FromTime: from, Kinds: []Kind{Forwards, Invoices}}, nil},
+		{"Unknown node", JobRequest{NodeId: 2, FromTime: from}, []string{"nodeId"}},
+		{"From after to", JobRequest{NodeId: 1, FromTime: from, ToTime: &later}, []string{"fromTime"}},
+		{"From in the future", JobRequest{NodeId: 1, FromTime: now.Add(time.Hour)}, []string{"fromTime"}},
+		{"Unknown kind", JobRequest{NodeId: 1, FromTime: from, Kinds: []Kind{"channels"}}, []string{"kinds"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serverError := ValidateJobRequest(test.jr, now)
+			var fields []string
+			if serverError != nil {
+				for field := range serverError.Errors.Fields {
+					fields = append(fields, field)
+				}
+			}
+			if len(fields) != len(test.fields) || (len(fields) == 1 && fields[0] != test.fields[0]) {
+				t.Errorf("ValidateJobRequest()\nGot:\n%v\nWant:\n%v\n", fields, test.fields)
+			}
+		})
+	}
+}
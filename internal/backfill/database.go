@@ -0,0 +1,185 @@
+package backfill
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func addJob(db *sqlx.DB, job Job) (Job, error) {
+	job.CreatedOn = time.Now().UTC()
+	job.UpdatedOn = job.CreatedOn
+	tx, err := db.Beginx()
+	if err != nil {
+		return Job{}, errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() { _ = tx.Rollback() }()
+	err = tx.QueryRowx(`
+		INSERT INTO backfill_job (node_id, from_time, to_time, kinds, status, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING backfill_job_id;`,
+		job.NodeId, job.FromTime, job.ToTime, job.Kinds, job.Status, job.CreatedOn, job.UpdatedOn).
+		Scan(&job.BackfillJobId)
+	if err != nil {
+		return Job{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	job.Checkpoints = []Checkpoint{}
+	for _, kind := range job.Kinds {
+		checkpoint := Checkpoint{BackfillJobId: job.BackfillJobId, Kind: Kind(kind), UpdatedOn: job.CreatedOn}
+		_, err = tx.Exec(`
+			INSERT INTO backfill_checkpoint (backfill_job_id, kind, index_offset, stored, done, updated_on)
+			VALUES ($1, $2, 0, 0, false, $3);`,
+			checkpoint.BackfillJobId, checkpoint.Kind, checkpoint.UpdatedOn)
+		if err != nil {
+			return Job{}, errors.Wrap(err, database.SqlExecutionError)
+		}
+		job.Checkpoints = append(job.Checkpoints, checkpoint)
+	}
+	if err = tx.Commit(); err != nil {
+		return Job{}, errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return job, nil
+}
+
+// GetJob returns the job with its checkpoints, BackfillJobId is 0 when the job doesn't exist.
+func GetJob(db *sqlx.DB, backfillJobId int) (Job, error) {
+	var job Job
+	err := db.Get(&job, `SELECT * FROM backfill_job WHERE backfill_job_id=$1;`, backfillJobId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, nil
+		}
+		return Job{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	job.Checkpoints, err = getCheckpoints(db, backfillJobId)
+	if err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+func getJobs(db *sqlx.DB, limit int) ([]Job, error) {
+	jobs := []Job{}
+	err := db.Select(&jobs, `SELECT * FROM backfill_job ORDER BY backfill_job_id DESC LIMIT $1;`, limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	for i := range jobs {
+		jobs[i].Checkpoints, err = getCheckpoints(db, jobs[i].BackfillJobId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+func getCheckpoints(db *sqlx.DB, backfillJobId int) ([]Checkpoint, error) {
+	checkpoints := []Checkpoint{}
+	err := db.Select(&checkpoints, `
+		SELECT c.*
+		FROM backfill_checkpoint c
+		JOIN backfill_job j ON j.backfill_job_id = c.backfill_job_id
+		WHERE c.backfill_job_id=$1
+		ORDER BY array_position(j.kinds, c.kind);`, backfillJobId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return checkpoints, nil
+}
+
+// claimJob marks the job as running when it's pending or its heartbeat is stale, 0 claims the oldest such job.
+// BackfillJobId is 0 when there was nothing to claim.
+func claimJob(db *sqlx.DB, backfillJobId int) (Job, error) {
+	now := time.Now().UTC()
+	var job Job
+	err := db.Get(&job, `
+		UPDATE backfill_job SET status=$1, error=NULL, heartbeat_on=$2, updated_on=$2
+		WHERE backfill_job_id = (
+			SELECT backfill_job_id FROM backfill_job
+			WHERE ($3=0 OR backfill_job_id=$3) AND (status=$4 OR (status=$1 AND heartbeat_on < $5))
+			ORDER BY backfill_job_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING *;`,
+		Running, now, backfillJobId, Pending, now.Add(-staleHeartbeat))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, nil
+		}
+		return Job{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return job, nil
+}
+
+// heartbeat keeps the claim of a running job, running is false when the job was cancelled.
+func heartbeat(db *sqlx.DB, backfillJobId int) (running bool, err error) {
+	res, err := db.Exec(`UPDATE backfill_job SET heartbeat_on=$1 WHERE backfill_job_id=$2 AND status=$3;`,
+		time.Now().UTC(), backfillJobId, Running)
+	if err != nil {
+		return false, errors.Wrap(err, database.SqlExecutionError)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return count != 0, nil
+}
+
+// finishJob stores the status of a job that stopped running, a job that was cancelled meanwhile stays cancelled.
+func finishJob(db *sqlx.DB, job Job) error {
+	_, err := db.Exec(`
+		UPDATE backfill_job SET status=$1, error=$2, updated_on=$3
+		WHERE backfill_job_id=$4 AND status=$5;`,
+		job.Status, job.Error, time.Now().UTC(), job.BackfillJobId, Running)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func setCheckpoint(db *sqlx.DB, checkpoint Checkpoint) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(`
+		UPDATE backfill_checkpoint SET index_offset=$1, stored=$2, done=$3, updated_on=$4
+		WHERE backfill_job_id=$5 AND kind=$6;`,
+		checkpoint.IndexOffset, checkpoint.Stored, checkpoint.Done, now, checkpoint.BackfillJobId, checkpoint.Kind)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = db.Exec(`UPDATE backfill_job SET heartbeat_on=$1, updated_on=$1 WHERE backfill_job_id=$2;`,
+		now, checkpoint.BackfillJobId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// setJobStatus changes the status of a job that has one of the from statuses, BackfillJobId is 0 when it hasn't.
+func setJobStatus(db *sqlx.DB, backfillJobId int, status JobStatus, from ...JobStatus) (Job, error) {
+	statuses := make([]string, len(from))
+	for i, fromStatus := range from {
+		statuses[i] = string(fromStatus)
+	}
+	var job Job
+	err := db.Get(&job, `
+		UPDATE backfill_job SET status=$1, updated_on=$2
+		WHERE backfill_job_id=$3 AND status=ANY($4)
+		RETURNING *;`,
+		status, time.Now().UTC(), backfillJobId, pq.StringArray(statuses))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, nil
+		}
+		return Job{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	job.Checkpoints, err = getCheckpoints(db, backfillJobId)
+	if err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
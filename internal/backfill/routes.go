@@ -0,0 +1,177 @@
+package backfill
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultJobLimit = 50
+	maxJobLimit     = 500
+)
+
+func RegisterBackfillRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getJobsHandler(c, db) })
+	r.POST("", func(c *gin.Context) { addJobHandler(c, db) })
+	r.GET(":backfillJobId", func(c *gin.Context) { getJobHandler(c, db) })
+	r.POST(":backfillJobId/cancel", func(c *gin.Context) { cancelJobHandler(c, db) })
+	r.POST(":backfillJobId/resume", func(c *gin.Context) { resumeJobHandler(c, db) })
+}
+
+func getJobsHandler(c *gin.Context, db *sqlx.DB) {
+	limit := defaultJobLimit
+	if c.Query("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxJobLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxJobLimit))
+			return
+		}
+	}
+	jobs, err := getJobs(db, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting backfill jobs.")
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+func addJobHandler(c *gin.Context, db *sqlx.DB) {
+	var jr JobRequest
+	if err := c.BindJSON(&jr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if serverError := ValidateJobRequest(jr, time.Now()); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	job, err := AddJob(db, jr)
+	audit.Record(db, c, audit.AddBackfillJob, jr, job, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding backfill job.")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func getJobHandler(c *gin.Context, db *sqlx.DB) {
+	backfillJobId, err := strconv.Atoi(c.Param("backfillJobId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse backfillJobId in the request.")
+		return
+	}
+	job, err := GetJob(db, backfillJobId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting backfillJobId: %v", backfillJobId))
+		return
+	}
+	if job.BackfillJobId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Backfill job not found.")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// cancelJobHandler stops a pending or running job, a running job stops at its next heartbeat.
+func cancelJobHandler(c *gin.Context, db *sqlx.DB) {
+	backfillJobId, err := strconv.Atoi(c.Param("backfillJobId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse backfillJobId in the request.")
+		return
+	}
+	job, err := setJobStatus(db, backfillJobId, Cancelled, Pending, Running)
+	audit.Record(db, c, audit.CancelBackfillJob, map[string]interface{}{"backfillJobId": backfillJobId}, job, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Cancelling backfillJobId: %v", backfillJobId))
+		return
+	}
+	if job.BackfillJobId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Only pending or running backfill jobs can be cancelled.")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// resumeJobHandler queues a failed or cancelled job again, it continues from its checkpoints.
+func resumeJobHandler(c *gin.Context, db *sqlx.DB) {
+	backfillJobId, err := strconv.Atoi(c.Param("backfillJobId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse backfillJobId in the request.")
+		return
+	}
+	job, err := setJobStatus(db, backfillJobId, Pending, Failed, Cancelled)
+	audit.Record(db, c, audit.ResumeBackfillJob, map[string]interface{}{"backfillJobId": backfillJobId}, job, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Resuming backfillJobId: %v", backfillJobId))
+		return
+	}
+	if job.BackfillJobId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Only failed or cancelled backfill jobs can be resumed.")
+		return
+	}
+	select {
+	case jobAdded <- struct{}{}:
+	default:
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ValidateJobRequest checks the node, the date range and the kinds of a new job.
+func ValidateJobRequest(jr JobRequest, now time.Time) *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	if jr.NodeId == 0 || commons.GetNodeSettingsByNodeId(jr.NodeId).NodeId == 0 {
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+	}
+	toTime := now
+	if jr.ToTime != nil {
+		toTime = *jr.ToTime
+	}
+	if !jr.FromTime.Before(toTime) {
+		serverError.AddFieldError("fromTime", "The from time needs to be before the to time.")
+	}
+	for _, kind := range jr.Kinds {
+		if !kind.IsValid() {
+			serverError.AddFieldError("kinds", fmt.Sprintf("Unknown kind: %v", kind))
+		}
+	}
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	return serverError
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the most recent backfill jobs with their progress",
+			QueryParameters: []string{"limit"},
+			Response:        []Job{},
+		},
+		"POST ": {
+			Summary:     "Import the history of a node for a date range in the background",
+			RequestBody: JobRequest{},
+			Response:    Job{},
+		},
+		"GET :backfillJobId": {Summary: "Get a backfill job with its progress", Response: Job{}},
+		"POST :backfillJobId/cancel": {
+			Summary:  "Cancel a pending or running backfill job",
+			Response: Job{},
+		},
+		"POST :backfillJobId/resume": {
+			Summary:  "Queue a failed or cancelled backfill job again, it continues where it stopped",
+			Response: Job{},
+		},
+	}
+}
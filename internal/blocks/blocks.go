@@ -0,0 +1,113 @@
+package blocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+const (
+	// MaxLag is the number of blocks a node can be behind the other nodes on its chain before it's considered behind,
+	// nodes don't receive a block at the same moment so a lag of one is normal.
+	MaxLag = 1
+	// ConfirmationDepth is how deep the confirmations of transactions are kept up to date, deep enough for the
+	// longest delay before the funds of a force close can be spent.
+	ConfirmationDepth = 2016
+)
+
+type Block struct {
+	NodeId    int       `json:"nodeId" db:"node_id"`
+	Height    uint32    `json:"height" db:"height"`
+	Hash      *string   `json:"hash" db:"hash"`
+	Time      time.Time `json:"time" db:"time"`
+	CreatedOn time.Time `json:"createdOn" db:"created_on"`
+}
+
+// Status is the block-lag indicator of a node, ChainHeight is the highest block any node on the same chain has seen.
+type Status struct {
+	NodeId        int       `json:"nodeId" db:"node_id"`
+	Height        uint32    `json:"height" db:"height"`
+	Hash          *string   `json:"hash" db:"hash"`
+	Time          time.Time `json:"time" db:"time"`
+	ChainHeight   uint32    `json:"chainHeight" db:"chain_height"`
+	Lag           uint32    `json:"lag" db:"-"`
+	SyncedToChain bool      `json:"syncedToChain" db:"-"`
+	// Behind is true when the node lags more than MaxLag blocks or reports that it isn't synced to the chain.
+	Behind bool `json:"behind" db:"-"`
+}
+
+//nolint:gochecknoglobals
+var (
+	syncedToChain   = make(map[int]bool)
+	syncedToChainMu sync.RWMutex
+)
+
+func setSyncedToChain(nodeId int, synced bool) {
+	syncedToChainMu.Lock()
+	defer syncedToChainMu.Unlock()
+	syncedToChain[nodeId] = synced
+}
+
+func isSyncedToChain(nodeId int) bool {
+	syncedToChainMu.RLock()
+	defer syncedToChainMu.RUnlock()
+	synced, exists := syncedToChain[nodeId]
+	return synced || !exists
+}
+
+func (status *Status) setLag(synced bool) {
+	status.SyncedToChain = synced
+	if status.ChainHeight > status.Height {
+		status.Lag = status.ChainHeight - status.Height
+	}
+	status.Behind = status.Lag > MaxLag || !synced
+}
+
+// StoreBlock stores the new best block of the node, blocks above it are removed because they were reorganized away.
+// The confirmations of the transactions of the node are updated and a BlockEvent is sent.
+func StoreBlock(db *sqlx.DB, block Block, synced bool, eventChannel chan interface{}) (Status, error) {
+	block.CreatedOn = time.Now().UTC()
+	if err := addBlock(db, block); err != nil {
+		return Status{}, err
+	}
+	if err := updateTransactionConfirmations(db, block.NodeId, block.Height); err != nil {
+		return Status{}, err
+	}
+	setSyncedToChain(block.NodeId, synced)
+	status, err := getStatus(db, block.NodeId)
+	if err != nil {
+		return Status{}, err
+	}
+	status.setLag(synced)
+	if eventChannel != nil {
+		hash := ""
+		if block.Hash != nil {
+			hash = *block.Hash
+		}
+		eventChannel <- broadcast.BlockEvent{
+			EventData:   broadcast.EventData{EventTime: block.CreatedOn, NodeId: block.NodeId},
+			Height:      block.Height,
+			Hash:        hash,
+			BlockTime:   block.Time,
+			ChainHeight: status.ChainHeight,
+			Lag:         status.Lag,
+			Behind:      status.Behind,
+		}
+	}
+	return status, nil
+}
+
+// GetStatuses returns the block-lag indicator of every node that received a block.
+func GetStatuses(db *sqlx.DB) ([]Status, error) {
+	statuses, err := getStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		statuses[i].setLag(isSyncedToChain(statuses[i].NodeId))
+	}
+	return statuses, nil
+}
@@ -0,0 +1,40 @@
+package blocks
+
+import (
+	"testing"
+)
+
+func TestStatusSetLag(t *testing.T) {
+	tests := []struct {
+		name        string
+		height      uint32
+		chainHeight uint32
+		synced      bool
+		lag         uint32
+		behind      bool
+	}{
+		{"At the tip", 100, 100, true, 0, false},
+		{"One block behind", 99, 100, true, 1, false},
+		{"Behind", 97, 100, true, 3, true},
+		{"Not synced", 100, 100, false, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := Status{Height: test.height, ChainHeight: test.chainHeight}
+			status.setLag(test.synced)
+			if status.Lag != test.lag || status.Behind != test.behind {
+				t.Errorf("setLag()\nGot:\n%v %v\nWant:\n%v %v\n", status.Lag, status.Behind, test.lag, test.behind)
+			}
+		})
+	}
+}
+
+func TestIsSyncedToChain(t *testing.T) {
+	if !isSyncedToChain(1) {
+		t.Errorf("isSyncedToChain() unknown node\nGot:\n%v\nWant:\n%v\n", false, true)
+	}
+	setSyncedToChain(1, false)
+	if isSyncedToChain(1) {
+		t.Errorf("isSyncedToChain()\nGot:\n%v\nWant:\n%v\n", true, false)
+	}
+}
@@ -0,0 +1,90 @@
+package blocks
+
+import (
+	"database/sql"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func addBlock(db *sqlx.DB, block Block) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.Exec(`DELETE FROM block WHERE node_id=$1 AND height>$2;`, block.NodeId, block.Height)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO block (node_id, height, hash, time, created_on)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (node_id, height) DO UPDATE SET hash=EXCLUDED.hash, time=EXCLUDED.time,
+			created_on=EXCLUDED.created_on;`,
+		block.NodeId, block.Height, block.Hash, block.Time, block.CreatedOn)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return nil
+}
+
+// updateTransactionConfirmations sets the confirmations of the confirmed transactions up to ConfirmationDepth deep.
+func updateTransactionConfirmations(db *sqlx.DB, nodeId int, height uint32) error {
+	_, err := db.Exec(`
+		UPDATE tx SET num_confirmations=$2-block_height+1
+		WHERE node_id=$1 AND block_height>0 AND block_height<=$2 AND block_height>$2-$3
+			AND num_confirmations IS DISTINCT FROM $2-block_height+1;`, nodeId, height, ConfirmationDepth)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// GetBlocks returns the most recent blocks of the node.
+func GetBlocks(db *sqlx.DB, nodeId int, limit int) ([]Block, error) {
+	blocks := []Block{}
+	err := db.Select(&blocks, `
+		SELECT * FROM block
+		WHERE node_id=$1
+		ORDER BY height DESC
+		LIMIT $2;`, nodeId, limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return blocks, nil
+}
+
+const statusQuery = `
+	WITH latest AS (
+		SELECT DISTINCT ON (b.node_id) b.node_id, b.height, b.hash, b.time, n.chain, n.network
+		FROM block b
+		JOIN node n ON n.node_id=b.node_id
+		ORDER BY b.node_id, b.height DESC
+	)
+	SELECT l.node_id, l.height, l.hash, l.time,
+		MAX(l.height) OVER (PARTITION BY l.chain, l.network) AS chain_height
+	FROM latest l`
+
+func getStatuses(db *sqlx.DB) ([]Status, error) {
+	statuses := []Status{}
+	err := db.Select(&statuses, statusQuery+` ORDER BY l.node_id;`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return statuses, nil
+}
+
+func getStatus(db *sqlx.DB, nodeId int) (Status, error) {
+	var status Status
+	err := db.Get(&status, `SELECT * FROM (`+statusQuery+`) statuses WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return Status{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return status, nil
+}
@@ -0,0 +1,68 @@
+package blocks
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultBlockLimit = 10
+	maxBlockLimit     = 1000
+)
+
+func RegisterBlockRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getStatusesHandler(c, db) })
+	r.GET(":nodeId", func(c *gin.Context) { getBlocksHandler(c, db) })
+}
+
+func getStatusesHandler(c *gin.Context, db *sqlx.DB) {
+	statuses, err := GetStatuses(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting block statuses.")
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+func getBlocksHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	limit := defaultBlockLimit
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxBlockLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxBlockLimit))
+			return
+		}
+	}
+	blocks, err := GetBlocks(db, nodeId, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting blocks for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:  "Get the best block of every node and whether the node is behind the chain",
+			Response: []Status{},
+		},
+		"GET :nodeId": {
+			Summary:         "List the most recent blocks of a node",
+			QueryParameters: []string{"limit"},
+			Response:        []Block{},
+		},
+	}
+}
@@ -0,0 +1,328 @@
+package channel_backups
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+// Backup is a version of the multi channel backup of a node, the backup itself is only returned by Download.
+type Backup struct {
+	ChannelBackupId     int            `json:"channelBackupId" db:"channel_backup_id"`
+	NodeId              int            `json:"nodeId" db:"node_id"`
+	Version             int            `json:"version" db:"version"`
+	ChannelPoints       pq.StringArray `json:"channelPoints" db:"channel_points"`
+	OpenedChannelPoints pq.StringArray `json:"openedChannelPoints" db:"opened_channel_points"`
+	ClosedChannelPoints pq.StringArray `json:"closedChannelPoints" db:"closed_channel_points"`
+	ChannelIds          pq.Int64Array  `json:"channelIds" db:"channel_ids"`
+	EncryptedBackup     []byte         `json:"-" db:"encrypted_backup"`
+	Checksum            string         `json:"checksum" db:"checksum"`
+	Size                int            `json:"size" db:"size"`
+	CreatedOn           time.Time      `json:"createdOn" db:"created_on"`
+	// ChannelEvents are the open and close events of the channels that changed since the previous version.
+	ChannelEvents []ChannelEvent `json:"channelEvents,omitempty" db:"-"`
+	// KeyId is only set for backups encrypted before the key was derived with a salt, it identifies the key.
+	KeyId string `json:"-" db:"key_id"`
+}
+
+type ChannelEvent struct {
+	Time      time.Time                           `json:"time" db:"time"`
+	EventType lnrpc.ChannelEventUpdate_UpdateType `json:"eventType" db:"event_type"`
+	ChannelId int                                 `json:"channelId" db:"channel_id"`
+}
+
+type VerifyResult struct {
+	ChannelBackupId int  `json:"channelBackupId"`
+	Valid           bool `json:"valid"`
+	// ChecksumValid is true when the backup could be decrypted with the current key and matches its checksum.
+	ChecksumValid bool `json:"checksumValid"`
+	// NodeValid is true when the node accepted the backup as one it can restore.
+	NodeValid bool   `json:"nodeValid"`
+	Error     string `json:"error,omitempty"`
+}
+
+type RestoreRequest struct {
+	// NodeId is the node that restores the channels, it needs the seed of the node that made the backup.
+	NodeId int `json:"nodeId" binding:"required"`
+}
+
+// The key of every backup is derived from the secret with scrypt and a random salt stored in front of the backup.
+const (
+	saltSize     = 16
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+//nolint:gochecknoglobals
+var (
+	encryptionSecret []byte
+	secretMu         sync.RWMutex
+)
+
+// SetEncryptionKey sets the secret that encrypts the backups. Backups are not stored without a secret.
+func SetEncryptionKey(secret string) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	if secret == "" {
+		encryptionSecret = nil
+		return
+	}
+	encryptionSecret = []byte(secret)
+}
+
+// EncryptionEnabled returns true when a secret was set with SetEncryptionKey.
+func EncryptionEnabled() bool {
+	secretMu.RLock()
+	defer secretMu.RUnlock()
+	return encryptionSecret != nil
+}
+
+func getSecret() ([]byte, error) {
+	secretMu.RLock()
+	defer secretMu.RUnlock()
+	if encryptionSecret == nil {
+		return nil, errors.New("No channel backup encryption key is configured")
+	}
+	return encryptionSecret, nil
+}
+
+func newCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating GCM")
+	}
+	return aead, nil
+}
+
+func deriveKey(secret []byte, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key(secret, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "Deriving key")
+	}
+	return key, nil
+}
+
+// legacyKey is how the key was derived before it was salted, it's only used to decrypt those backups.
+func legacyKey(secret []byte) ([]byte, string) {
+	key := sha256.Sum256(append([]byte("torq channel backup:"), secret...))
+	id := sha256.Sum256(key[:])
+	return key[:], hex.EncodeToString(id[:8])
+}
+
+// encrypt returns the salt, nonce and encrypted backup.
+func encrypt(plain []byte) ([]byte, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "Creating salt")
+	}
+	key, err := deriveKey(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "Creating nonce")
+	}
+	return aead.Seal(append(salt, nonce...), nonce, plain, nil), nil
+}
+
+// Decrypt returns the multi channel backup after checking it against its checksum.
+func Decrypt(backup Backup) ([]byte, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted := backup.EncryptedBackup
+	var key []byte
+	if backup.KeyId != "" {
+		var id string
+		key, id = legacyKey(secret)
+		if backup.KeyId != id {
+			return nil, errors.New("The backup was encrypted with another key")
+		}
+	} else {
+		if len(encrypted) < saltSize {
+			return nil, errors.New("The encrypted backup is too short")
+		}
+		key, err = deriveKey(secret, encrypted[:saltSize])
+		if err != nil {
+			return nil, err
+		}
+		encrypted = encrypted[saltSize:]
+	}
+	aead, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errors.New("The encrypted backup is too short")
+	}
+	nonce := encrypted[:aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, encrypted[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decrypting backup, it was encrypted with another key or it was modified")
+	}
+	if checksum(plain) != backup.Checksum {
+		return nil, errors.New("The backup doesn't match its checksum")
+	}
+	return plain, nil
+}
+
+func checksum(plain []byte) string {
+	sum := sha256.Sum256(plain)
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreSnapshot stores the multi channel backup as the next version of the node when it differs from the latest
+// version. The returned backup has ChannelBackupId 0 when nothing changed.
+func StoreSnapshot(db *sqlx.DB, nodeId int, multi *lnrpc.MultiChanBackup,
+	eventChannel chan interface{}) (Backup, error) {
+
+	if multi == nil || len(multi.MultiChanBackup) == 0 {
+		return Backup{}, nil
+	}
+	latest, err := getLatestBackup(db, nodeId)
+	if err != nil {
+		return Backup{}, err
+	}
+	sum := checksum(multi.MultiChanBackup)
+	if latest.Checksum == sum {
+		return Backup{}, nil
+	}
+	encrypted, err := encrypt(multi.MultiChanBackup)
+	if err != nil {
+		return Backup{}, err
+	}
+	backup := Backup{
+		NodeId:              nodeId,
+		Version:             latest.Version + 1,
+		ChannelPoints:       channelPoints(multi.ChanPoints),
+		OpenedChannelPoints: pq.StringArray{},
+		ClosedChannelPoints: pq.StringArray{},
+		ChannelIds:          pq.Int64Array{},
+		EncryptedBackup:     encrypted,
+		Checksum:            sum,
+		Size:                len(multi.MultiChanBackup),
+		CreatedOn:           time.Now().UTC(),
+	}
+	backup.OpenedChannelPoints = difference(backup.ChannelPoints, latest.ChannelPoints)
+	backup.ClosedChannelPoints = difference(latest.ChannelPoints, backup.ChannelPoints)
+	for _, channelPoint := range append(append([]string{}, backup.OpenedChannelPoints...),
+		backup.ClosedChannelPoints...) {
+		channelId := commons.GetChannelIdFromFundingTransaction(channels.ParseChannelPoint(channelPoint))
+		if channelId != 0 {
+			backup.ChannelIds = append(backup.ChannelIds, int64(channelId))
+		}
+	}
+	backup, err = addBackup(db, backup)
+	if err != nil {
+		return Backup{}, err
+	}
+	if eventChannel != nil {
+		eventChannel <- broadcast.ChannelBackupEvent{
+			EventData:       broadcast.EventData{EventTime: backup.CreatedOn, NodeId: nodeId},
+			ChannelBackupId: backup.ChannelBackupId,
+			Version:         backup.Version,
+			Channels:        len(backup.ChannelPoints),
+		}
+	}
+	return backup, nil
+}
+
+func channelPoints(chanPoints []*lnrpc.ChannelPoint) pq.StringArray {
+	result := pq.StringArray{}
+	for _, chanPoint := range chanPoints {
+		txid := chanPoint.GetFundingTxidStr()
+		if txidBytes := chanPoint.GetFundingTxidBytes(); txidBytes != nil {
+			hash, err := chainhash.NewHash(txidBytes)
+			if err != nil {
+				continue
+			}
+			txid = hash.String()
+		}
+		result = append(result, channels.CreateChannelPoint(txid, int(chanPoint.OutputIndex)))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// difference returns the channel points of a that are not in b.
+func difference(a []string, b []string) pq.StringArray {
+	inB := make(map[string]bool, len(b))
+	for _, channelPoint := range b {
+		inB[channelPoint] = true
+	}
+	result := pq.StringArray{}
+	for _, channelPoint := range a {
+		if !inB[channelPoint] {
+			result = append(result, channelPoint)
+		}
+	}
+	return result
+}
+
+// Verify decrypts the backup and has the node check that it can restore it.
+func Verify(ctx context.Context, client node_client.NodeClient, backup Backup) VerifyResult {
+	result := VerifyResult{ChannelBackupId: backup.ChannelBackupId}
+	plain, err := Decrypt(backup)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.ChecksumValid = true
+	_, err = client.VerifyChanBackup(ctx, &lnrpc.ChanBackupSnapshot{
+		MultiChanBackup: &lnrpc.MultiChanBackup{MultiChanBackup: plain}})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.NodeValid = true
+	result.Valid = true
+	return result
+}
+
+// Restore has the node recover the funds of the channels in the backup by closing them with the help of its peers.
+func Restore(ctx context.Context, client node_client.NodeClient, backup Backup) error {
+	plain, err := Decrypt(backup)
+	if err != nil {
+		return err
+	}
+	_, err = client.RestoreChannelBackups(ctx, &lnrpc.RestoreChanBackupRequest{
+		Backup: &lnrpc.RestoreChanBackupRequest_MultiChanBackup{MultiChanBackup: plain}})
+	if err != nil {
+		return errors.Wrap(err, "Restoring channel backup")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package channel_backups
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+func TestEncryptAndVerify(t *testing.T) {
+	defer SetEncryptionKey("")
+	ctx := context.Background()
+	node := node_client.NewFakeNode("alice")
+	channel := node.AddChannel(node_client.NewFakeNode("bob").PublicKey(), 1000000, 500000)
+	snapshot, err := node.ExportAllChannelBackups(ctx, &lnrpc.ChanBackupExportRequest{})
+	if err != nil {
+		t.Fatalf("ExportAllChannelBackups() error: %v", err)
+	}
+	plain := snapshot.MultiChanBackup.MultiChanBackup
+
+	points := channelPoints(snapshot.MultiChanBackup.ChanPoints)
+	if len(points) != 1 || points[0] != channel.ChannelPoint {
+		t.Errorf("channelPoints()\nGot:\n%v\nWant:\n%v\n", points, channel.ChannelPoint)
+	}
+
+	if _, err := encrypt(plain); err == nil {
+		t.Errorf("encrypt() without key\nGot:\n%v\nWant:\n%v\n", err, "an error")
+	}
+	SetEncryptionKey("secret")
+	encrypted, err := encrypt(plain)
+	if err != nil {
+		t.Fatalf("encrypt() error: %v", err)
+	}
+	if bytes.Contains(encrypted, plain) {
+		t.Errorf("encrypt() contains the backup")
+	}
+	// Every backup has its own salt.
+	again, err := encrypt(plain)
+	if err != nil {
+		t.Fatalf("encrypt() error: %v", err)
+	}
+	if bytes.Equal(encrypted[:saltSize], again[:saltSize]) {
+		t.Errorf("encrypt() twice\nGot:\n%v\nWant:\n%v\n", "the same salt", "different salts")
+	}
+	backup := Backup{ChannelBackupId: 1, EncryptedBackup: encrypted, Checksum: checksum(plain)}
+	if response, err := json.Marshal(backup); err != nil || bytes.Contains(response, []byte("keyId")) {
+		t.Errorf("json.Marshal()\nGot:\n%s %v\nWant:\n%v\n", response, err, "no keyId")
+	}
+	decrypted, err := Decrypt(backup)
+	if err != nil || !bytes.Equal(decrypted, plain) {
+		t.Errorf("Decrypt()\nGot:\n%v %v\nWant:\n%v\n", decrypted, err, plain)
+	}
+
+	result := Verify(ctx, node, backup)
+	if !result.Valid || !result.ChecksumValid || !result.NodeValid {
+		t.Errorf("Verify()\nGot:\n%v\nWant:\n%v\n", result, "valid")
+	}
+	result = Verify(ctx, node_client.NewFakeNode("carol"), backup)
+	if result.Valid || !result.ChecksumValid || result.NodeValid {
+		t.Errorf("Verify() other node\nGot:\n%v\nWant:\n%v\n", result, "only the checksum valid")
+	}
+
+	tampered := backup
+	tampered.Checksum = checksum([]byte("other"))
+	if _, err := Decrypt(tampered); err == nil {
+		t.Errorf("Decrypt() wrong checksum\nGot:\n%v\nWant:\n%v\n", err, "an error")
+	}
+	SetEncryptionKey("other secret")
+	result = Verify(ctx, node, backup)
+	if result.Valid || result.ChecksumValid || result.Error == "" {
+		t.Errorf("Verify() other key\nGot:\n%v\nWant:\n%v\n", result, "invalid with an error")
+	}
+}
+
+func TestDecryptLegacyBackup(t *testing.T) {
+	defer SetEncryptionKey("")
+	SetEncryptionKey("secret")
+	plain := []byte("multi channel backup")
+	key, id := legacyKey([]byte("secret"))
+	aead, err := newCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	backup := Backup{EncryptedBackup: aead.Seal(nonce, nonce, plain, nil), KeyId: id, Checksum: checksum(plain)}
+
+	decrypted, err := Decrypt(backup)
+	if err != nil || !bytes.Equal(decrypted, plain) {
+		t.Errorf("Decrypt()\nGot:\n%s %v\nWant:\n%s\n", decrypted, err, plain)
+	}
+	SetEncryptionKey("other secret")
+	if _, err := Decrypt(backup); err == nil {
+		t.Errorf("Decrypt() other key\nGot:\n%v\nWant:\n%v\n", err, "an error")
+	}
+}
+
+func TestDifference(t *testing.T) {
+	previous := []string{"a:0", "b:1", "c:0"}
+	current := []string{"b:1", "c:0", "d:2"}
+	if opened := difference(current, previous); len(opened) != 1 || opened[0] != "d:2" {
+		t.Errorf("difference() opened\nGot:\n%v\nWant:\n%v\n", opened, "[d:2]")
+	}
+	if closed := difference(previous, current); len(closed) != 1 || closed[0] != "a:0" {
+		t.Errorf("difference() closed\nGot:\n%v\nWant:\n%v\n", closed, "[a:0]")
+	}
+}
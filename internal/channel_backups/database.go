@@ -0,0 +1,81 @@
+package channel_backups
+
+import (
+	"database/sql"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+// getLatestBackup returns the latest version of the node, Version is 0 when the node has no backup yet.
+func getLatestBackup(db *sqlx.DB, nodeId int) (Backup, error) {
+	var backup Backup
+	err := db.Get(&backup, `
+		SELECT * FROM channel_backup
+		WHERE node_id=$1
+		ORDER BY version DESC
+		LIMIT 1;`, nodeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Backup{}, nil
+		}
+		return Backup{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return backup, nil
+}
+
+func addBackup(db *sqlx.DB, backup Backup) (Backup, error) {
+	err := db.QueryRowx(`
+		INSERT INTO channel_backup (node_id, version, channel_points, opened_channel_points, closed_channel_points,
+			channel_ids, encrypted_backup, key_id, checksum, size, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING channel_backup_id;`,
+		backup.NodeId, backup.Version, backup.ChannelPoints, backup.OpenedChannelPoints, backup.ClosedChannelPoints,
+		backup.ChannelIds, backup.EncryptedBackup, backup.KeyId, backup.Checksum, backup.Size,
+		backup.CreatedOn).Scan(&backup.ChannelBackupId)
+	if err != nil {
+		return Backup{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return backup, nil
+}
+
+// GetBackup returns the backup with the channel events that triggered it, ChannelBackupId is 0 when the backup
+// doesn't exist.
+func GetBackup(db *sqlx.DB, channelBackupId int) (Backup, error) {
+	var backup Backup
+	err := db.Get(&backup, `SELECT * FROM channel_backup WHERE channel_backup_id=$1;`, channelBackupId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Backup{}, nil
+		}
+		return Backup{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	backup.ChannelEvents = []ChannelEvent{}
+	err = db.Select(&backup.ChannelEvents, `
+		SELECT time, event_type, channel_id FROM channel_event
+		WHERE node_id=$1 AND channel_id=ANY($2) AND event_type IN ($3, $4) AND time <= $5
+		ORDER BY time;`,
+		backup.NodeId, backup.ChannelIds, lnrpc.ChannelEventUpdate_OPEN_CHANNEL,
+		lnrpc.ChannelEventUpdate_CLOSED_CHANNEL, backup.CreatedOn)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Backup{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return backup, nil
+}
+
+// getBackups returns the most recent versions, a nodeId of 0 returns them for all nodes.
+func getBackups(db *sqlx.DB, nodeId int, limit int) ([]Backup, error) {
+	backups := []Backup{}
+	err := db.Select(&backups, `
+		SELECT * FROM channel_backup
+		WHERE $1=0 OR node_id=$1
+		ORDER BY channel_backup_id DESC
+		LIMIT $2;`, nodeId, limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return backups, nil
+}
@@ -0,0 +1,171 @@
+package channel_backups
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultBackupLimit = 50
+	maxBackupLimit     = 500
+)
+
+// RegisterChannelBackupRoutes registers the channel backup routes, the decrypted backup can be used to close every
+// channel of the node so downloading it requires adminRequired and restoring it requires totpRequired.
+func RegisterChannelBackupRoutes(r *gin.RouterGroup, db *sqlx.DB, adminRequired gin.HandlerFunc,
+	totpRequired gin.HandlerFunc) {
+	r.GET("", func(c *gin.Context) { getBackupsHandler(c, db) })
+	r.GET(":channelBackupId", func(c *gin.Context) { getBackupHandler(c, db) })
+	r.GET(":channelBackupId/download", adminRequired, func(c *gin.Context) { downloadBackupHandler(c, db) })
+	r.POST(":channelBackupId/verify", func(c *gin.Context) { verifyBackupHandler(c, db) })
+	r.POST(":channelBackupId/restore", totpRequired, func(c *gin.Context) { restoreBackupHandler(c, db) })
+}
+
+func getBackupsHandler(c *gin.Context, db *sqlx.DB) {
+	limit := defaultBackupLimit
+	if c.Query("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxBackupLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxBackupLimit))
+			return
+		}
+	}
+	nodeId := 0
+	if c.Query("nodeId") != "" {
+		var err error
+		nodeId, err = strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+	}
+	backups, err := getBackups(db, nodeId, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting channel backups.")
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// getBackupFromRequest sends the error response itself, ChannelBackupId is 0 when it did.
+func getBackupFromRequest(c *gin.Context, db *sqlx.DB) Backup {
+	channelBackupId, err := strconv.Atoi(c.Param("channelBackupId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse channelBackupId in the request.")
+		return Backup{}
+	}
+	backup, err := GetBackup(db, channelBackupId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting channelBackupId: %v", channelBackupId))
+		return Backup{}
+	}
+	if backup.ChannelBackupId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Channel backup not found.")
+	}
+	return backup
+}
+
+func getBackupHandler(c *gin.Context, db *sqlx.DB) {
+	backup := getBackupFromRequest(c, db)
+	if backup.ChannelBackupId == 0 {
+		return
+	}
+	c.JSON(http.StatusOK, backup)
+}
+
+func downloadBackupHandler(c *gin.Context, db *sqlx.DB) {
+	backup := getBackupFromRequest(c, db)
+	if backup.ChannelBackupId == 0 {
+		return
+	}
+	plain, err := Decrypt(backup)
+	if err != nil {
+		server_errors.SendUnprocessableEntity(c, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=channel-backup-node-%v-v%v.backup",
+		backup.NodeId, backup.Version))
+	c.Data(http.StatusOK, "application/octet-stream", plain)
+}
+
+func verifyBackupHandler(c *gin.Context, db *sqlx.DB) {
+	backup := getBackupFromRequest(c, db)
+	if backup.ChannelBackupId == 0 {
+		return
+	}
+	client, err := settings.GetNodeClient(db, backup.NodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting client for nodeId: %v", backup.NodeId))
+		return
+	}
+	c.JSON(http.StatusOK, Verify(c, client, backup))
+}
+
+func restoreBackupHandler(c *gin.Context, db *sqlx.DB) {
+	var rr RestoreRequest
+	if err := c.BindJSON(&rr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if commons.GetNodeSettingsByNodeId(rr.NodeId).NodeId == 0 {
+		serverError := &server_errors.ServerError{}
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	backup := getBackupFromRequest(c, db)
+	if backup.ChannelBackupId == 0 {
+		return
+	}
+	client, err := settings.GetNodeClient(db, rr.NodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting client for nodeId: %v", rr.NodeId))
+		return
+	}
+	err = Restore(c, client, backup)
+	audit.Record(db, c, audit.RestoreChannelBackup, rr, backup, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Restoring channelBackupId: %v", backup.ChannelBackupId))
+		return
+	}
+	c.JSON(http.StatusOK, backup)
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the most recent channel backup versions",
+			QueryParameters: []string{"nodeId", "limit"},
+			Response:        []Backup{},
+		},
+		"GET :channelBackupId": {
+			Summary:  "Get a channel backup version with the channel events that triggered it",
+			Response: Backup{},
+		},
+		"GET :channelBackupId/download": {
+			Summary: "Download the decrypted multi channel backup, it can be restored with lncli restorechanbackup",
+		},
+		"POST :channelBackupId/verify": {
+			Summary:  "Decrypt the channel backup and have its node verify it",
+			Response: VerifyResult{},
+		},
+		"POST :channelBackupId/restore": {
+			Summary:     "Restore the channels of the backup on a node that was recovered from the same seed",
+			RequestBody: RestoreRequest{},
+			Response:    Backup{},
+		},
+	}
+}
@@ -22,9 +22,10 @@ type ChannelEvent struct {
 	Outbound *bool `json:"outbound"`
 	// The value, in cases where there is a value change,
 	//like with fee rate etc. Not used by disable/enable and channel open/close
-	Value *uint64 `json:"value"`
+	// Inbound fees are negative for a discount.
+	Value *int64 `json:"value"`
 	// The previous value
-	PreviousValue *uint64 `json:"previousValue"`
+	PreviousValue *int64 `json:"previousValue"`
 }
 
 func getChannelEventHistory(db *sqlx.DB, nodeIds []int, channelIds []int, from time.Time, to time.Time) (r []*ChannelEvent, err error) {
@@ -101,6 +102,54 @@ from (SELECT ts as ts,
 ) as o
 where prev != fee_base
 
+UNION
+-- inbound fee rate changes
+select date(ts)::timestamp AT TIME ZONE ($1) as date,
+       ts::timestamp AT TIME ZONE ($1) as datetime,
+       channel_id,
+       outbound,
+       'inbound_fee_rate' as type,
+       inbound_fee_rate as value,
+       prev
+from (SELECT ts as ts,
+             channel_id,
+             CASE
+                WHEN announcing_node_id = ANY($5) THEN True
+            	ELSE False
+			 END AS outbound,
+             inbound_fee_rate_milli_msat as inbound_fee_rate,
+             lag(inbound_fee_rate_milli_msat, 1, 0) OVER (PARTITION BY channel_id ORDER BY ts) AS prev
+      FROM routing_policy
+      where channel_id = ANY($4)
+        and ts::timestamp AT TIME ZONE ($1) >= ($2)::timestamp
+        and ts::timestamp AT TIME ZONE ($1) <= ($3)::timestamp
+) as o
+where prev != inbound_fee_rate
+
+UNION
+-- inbound base fee changes
+select date(ts)::timestamp AT TIME ZONE ($1) as date,
+       ts::timestamp AT TIME ZONE ($1) as datetime,
+       channel_id,
+       outbound,
+       'inbound_base_fee' as type,
+       round(inbound_fee_base / 1000.0) as value,
+       round(prev / 1000.0) as prev
+from (SELECT ts as ts,
+             channel_id,
+             CASE
+                WHEN announcing_node_id = ANY($5) THEN True
+            	ELSE False
+			 END AS outbound,
+             inbound_fee_base_msat as inbound_fee_base,
+             lag(inbound_fee_base_msat, 1, 0) OVER (PARTITION BY channel_id ORDER BY ts) AS prev
+      FROM routing_policy
+      where channel_id = ANY($4)
+        and ts::timestamp AT TIME ZONE ($1) >= ($2)::timestamp
+        and ts::timestamp AT TIME ZONE ($1) <= ($3)::timestamp
+) as o
+where prev != inbound_fee_base
+
 UNION
 -- max_htlc changes
 select date(ts)::timestamp AT TIME ZONE ($1) as date,
@@ -3,6 +3,8 @@ package channel_history
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/openapi"
 )
 
 func RegisterChannelHistoryRoutes(r *gin.RouterGroup, db *sqlx.DB) {
@@ -12,3 +14,33 @@ func RegisterChannelHistoryRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET(":chanIds/rebalancing", func(c *gin.Context) { getChannelReBalancingHandler(c, db) })
 	r.GET(":chanIds/onchaincost", func(c *gin.Context) { getTotalOnchainCostHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET :chanIds/history": {
+			Summary:         "Get the history of the channels",
+			Response:        ChannelHistory{},
+			QueryParameters: []string{"from", "to", "network", "chain"},
+		},
+		"GET :chanIds/event": {
+			Summary:         "Get the events of the channels",
+			Response:        ChannelEventHistory{},
+			QueryParameters: []string{"from", "to", "network", "chain"},
+		},
+		"GET :chanIds/balance": {
+			Summary:         "Get the balance history of the channels",
+			Response:        ChannelBalanceHistory{},
+			QueryParameters: []string{"from", "to", "network", "chain"},
+		},
+		"GET :chanIds/rebalancing": {
+			Summary:         "Get the rebalancing cost of the channels",
+			Response:        ChannelReBalancing{},
+			QueryParameters: []string{"from", "to", "network", "chain"},
+		},
+		"GET :chanIds/onchaincost": {
+			Summary:         "Get the on-chain cost of the channels",
+			Response:        ChannelOnChainCost{},
+			QueryParameters: []string{"from", "to", "network", "chain"},
+		},
+	}
+}
@@ -10,7 +10,7 @@ type channelTag struct {
 	FromNodeId   int       `json:"fromNodeId" db:"from_node_id"`
 	ToNodeId     int       `json:"toNodeId" db:"to_node_id"`
 	ChannelId    int       `json:"channelId" db:"channel_id"`
-	TagId        int       `json:"tagId" db:"tag_id"`
+	TagId        int       `json:"tagId" db:"tag_id" binding:"required"`
 	CreatedOn    time.Time `json:"createdOn" db:"created_on"`
 	// No UpdateOn as there will never be an update always create/delete.
 }
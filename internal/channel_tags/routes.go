@@ -10,7 +10,9 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
 
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/corridors"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -59,6 +61,7 @@ func addChannelTagHandler(c *gin.Context, db *sqlx.DB) {
 		corridor.ChannelId = &ct.ChannelId
 	}
 	_, err := corridors.AddCorridor(db, corridor)
+	audit.Record(db, c, audit.AddChannelTag, ct, nil, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Adding corridor.")
 		return
@@ -95,6 +98,7 @@ func removeChannelTagHandler(c *gin.Context, db *sqlx.DB) {
 	corridorKey.ChannelId = ct.ChannelId
 	corridor := corridors.GetBestCorridor(corridorKey)
 	_, err = corridors.RemoveCorridor(db, corridor.CorridorId)
+	audit.Record(db, c, audit.RemoveChannelTag, ct, nil, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing corridor with corridorId: %v", corridor.CorridorId))
 		return
@@ -112,3 +116,13 @@ func removeChannelTagHandler(c *gin.Context, db *sqlx.DB) {
 	}()
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Successfully deleted tag(s)."})
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"POST ": {
+			Summary:     "Tag a node, a channel or the channels between two nodes",
+			RequestBody: channelTag{},
+		},
+		"DELETE :channelTagId": {Summary: "Remove a channel tag"},
+	}
+}
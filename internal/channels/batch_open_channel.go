@@ -10,7 +10,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/internal/spending_policies"
 )
 
 func batchOpenChannels(db *sqlx.DB, req BatchOpenRequest) (r BatchOpenResponse, err error) {
@@ -19,21 +19,20 @@ func batchOpenChannels(db *sqlx.DB, req BatchOpenRequest) (r BatchOpenResponse,
 		return BatchOpenResponse{}, err
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	var totalFundingAmount int64
+	for _, channel := range req.Channels {
+		totalFundingAmount += channel.LocalFundingAmount
+	}
+	err = spending_policies.CheckOnChain(db, req.NodeId, totalFundingAmount)
 	if err != nil {
-		return BatchOpenResponse{}, errors.Wrap(err, "Getting node connection details from the db")
+		return BatchOpenResponse{}, err
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return BatchOpenResponse{}, errors.Wrap(err, "Connecting to LND")
+		return BatchOpenResponse{}, errors.Wrap(err, "Connecting to node")
 	}
-	defer conn.Close()
 
-	client := lnrpc.NewLightningClient(conn)
 	ctx := context.Background()
 
 	bocResponse, err := client.BatchOpenChannel(ctx, bOpenChanReq)
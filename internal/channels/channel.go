@@ -109,7 +109,7 @@ func AddChannelOrUpdateChannelStatus(db *sqlx.DB, channel Channel) (int, error)
 				commons.SetChannel(existingChannelId, channel.ShortChannelID,
 					channel.Status, channel.FundingTransactionHash, channel.FundingOutputIndex)
 				if channel.Status >= commons.CooperativeClosed && channel.ClosingTransactionHash != nil {
-					err := updateChannelStatusAndClosingTransactionHash(db, existingChannelId, channel.Status, *channel.ClosingTransactionHash)
+					err := UpdateChannelStatusAndClosingTransactionHash(db, existingChannelId, channel.Status, *channel.ClosingTransactionHash)
 					if err != nil {
 						return 0, errors.Wrapf(err, "Updating channel status and closing transaction hash %v.", existingChannelId)
 					}
@@ -124,7 +124,7 @@ func AddChannelOrUpdateChannelStatus(db *sqlx.DB, channel Channel) (int, error)
 		status := commons.GetChannelStatusFromChannelId(existingChannelId)
 		if status != channel.Status {
 			if channel.Status >= commons.CooperativeClosed && channel.ClosingTransactionHash != nil {
-				err := updateChannelStatusAndClosingTransactionHash(db, existingChannelId, channel.Status, *channel.ClosingTransactionHash)
+				err := UpdateChannelStatusAndClosingTransactionHash(db, existingChannelId, channel.Status, *channel.ClosingTransactionHash)
 				if err != nil {
 					return 0, errors.Wrapf(err, "Updating channel status and closing transaction hash %v.", existingChannelId)
 				}
@@ -150,9 +150,9 @@ func UpdateChannelStatus(db *sqlx.DB, channelId int, status commons.ChannelStatu
 	return nil
 }
 
-func updateChannelStatusAndClosingTransactionHash(db *sqlx.DB, channelId int, status commons.ChannelStatus, closingTransactionHash string) error {
+func UpdateChannelStatusAndClosingTransactionHash(db *sqlx.DB, channelId int, status commons.ChannelStatus, closingTransactionHash string) error {
 	_, err := db.Exec(`
-		UPDATE channel SET status_id=$1, closing_transaction_hash=$2, updated_on=$3 WHERE channel_id=$4 AND (status_id!=$1 OR closing_transaction_hash!=$2)`,
+		UPDATE channel SET status_id=$1, closing_transaction_hash=$2, updated_on=$3 WHERE channel_id=$4 AND (status_id!=$1 OR closing_transaction_hash IS DISTINCT FROM $2)`,
 		status, closingTransactionHash, time.Now().UTC(), channelId)
 	if err != nil {
 		return errors.Wrap(err, database.SqlExecutionError)
@@ -172,7 +172,7 @@ func updateChannelStatusAndLndIds(db *sqlx.DB, channelId int, status commons.Cha
 	_, err := db.Exec(`
 		UPDATE channel
 		SET status_id=$2, short_channel_id=$3, lnd_short_channel_id=$4, updated_on=$5
-		WHERE channel_id=$1 AND (status_id!=$2 OR short_channel_id!=$3 OR lnd_short_channel_id!=$4)`,
+		WHERE channel_id=$1 AND (status_id!=$2 OR short_channel_id IS DISTINCT FROM $3 OR lnd_short_channel_id IS DISTINCT FROM $4)`,
 		channelId, status, shortChannelId, lndShortChannelId, time.Now().UTC())
 	if err != nil {
 		return errors.Wrap(err, database.SqlExecutionError)
@@ -10,16 +10,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-type lndClientCloseChannel interface {
-	CloseChannel(ctx context.Context, in *lnrpc.CloseChannelRequest, opts ...grpc.CallOption) (lnrpc.Lightning_CloseChannelClient, error)
-}
-
 type CloseChannelRequest struct {
 	NodeId          int     `json:"nodeId"`
 	ChannelPoint    string  `json:"channelPoint"`
@@ -46,25 +41,18 @@ type CloseChannelResponse struct {
 	ChanClose    channelCloseUpdate `json:"chanClose"`
 }
 
-func CloseChannel(eventChannel chan interface{}, db *sqlx.DB, c *gin.Context, ccReq CloseChannelRequest, reqId string) (err error) {
-	connectionDetails, err := settings.GetConnectionDetailsById(db, ccReq.NodeId)
-	if err != nil {
-		return errors.New("Getting node connection details from the db")
-	}
+// CloseChannel sends the close updates to the client and returns the last update, it's nil when the node sent none.
+func CloseChannel(eventChannel chan interface{}, db *sqlx.DB, c *gin.Context, ccReq CloseChannelRequest,
+	reqId string) (*CloseChannelResponse, error) {
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	client, err := settings.GetNodeClient(db, ccReq.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Connecting to LND")
+		return nil, errors.Wrap(err, "Connecting to node")
 	}
-	defer conn.Close()
-	client := lnrpc.NewLightningClient(conn)
 
 	closeChanReq, err := prepareCloseRequest(ccReq)
 	if err != nil {
-		return errors.Wrap(err, "Preparing close request")
+		return nil, errors.Wrap(err, "Preparing close request")
 	}
 
 	return closeChannelResp(client, closeChanReq, eventChannel, reqId)
@@ -109,35 +97,37 @@ func prepareCloseRequest(ccReq CloseChannelRequest) (r *lnrpc.CloseChannelReques
 	return closeChanReq, nil
 }
 
-func closeChannelResp(client lndClientCloseChannel, closeChanReq *lnrpc.CloseChannelRequest, eventChannel chan interface{}, reqId string) error {
+func closeChannelResp(client node_client.NodeClient, closeChanReq *lnrpc.CloseChannelRequest,
+	eventChannel chan interface{}, reqId string) (last *CloseChannelResponse, err error) {
 
 	ctx := context.Background()
 	closeChanRes, err := client.CloseChannel(ctx, closeChanReq)
 	if err != nil {
-		return errors.Wrap(err, "Closing channel")
+		return nil, errors.Wrap(err, "Closing channel")
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			//log.Debug().Msgf("%v", ctx.Err())
-			return nil
+			return last, nil
 		default:
 		}
 
 		resp, err := closeChanRes.Recv()
 		if err == io.EOF {
 			//log.Debug().Msgf("Close channel EOF")
-			return nil
+			return last, nil
 		}
 		if err != nil {
-			return errors.Wrap(err, "Close channel request receive")
+			return last, errors.Wrap(err, "Close channel request receive")
 		}
 
 		r, err := processCloseResponse(resp, reqId)
 		if err != nil {
-			return errors.Wrap(err, "Process close response")
+			return last, errors.Wrap(err, "Process close response")
 		}
+		last = r
 		if eventChannel != nil {
 			eventChannel <- r
 		}
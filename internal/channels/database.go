@@ -124,3 +124,74 @@ func addChannel(db *sqlx.DB, channel Channel) (Channel, error) {
 		channel.Status, channel.FundingTransactionHash, channel.FundingOutputIndex)
 	return channel, nil
 }
+
+// GetCapacityByChannelPoint returns the last known capacity of the channel or 0 when it's unknown.
+func GetCapacityByChannelPoint(db *sqlx.DB, channelPoint string) (int64, error) {
+	fundingTransactionHash, fundingOutputIndex := ParseChannelPoint(channelPoint)
+	var capacity int64
+	err := db.Get(&capacity, `
+		SELECT COALESCE((
+			SELECT (ce.event->>'capacity')::BIGINT
+			FROM channel_event ce
+			JOIN channel c ON c.channel_id = ce.channel_id
+			WHERE c.funding_transaction_hash = $1 AND c.funding_output_index = $2 AND ce.event->>'capacity' IS NOT NULL
+			ORDER BY ce.time DESC
+			LIMIT 1
+		), 0);`, fundingTransactionHash, fundingOutputIndex)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return capacity, nil
+}
+
+func getStoredPendingChannels(db *sqlx.DB, nodeId int) ([]PendingChannel, error) {
+	pendingChannels := []PendingChannel{}
+	err := db.Select(&pendingChannels, `SELECT * FROM pending_channel WHERE node_id=$1;`, nodeId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return pendingChannels, nil
+}
+
+// GetPendingChannels returns the pending channels ordered by the time left before they mature, a nodeId of 0 returns
+// them for all nodes.
+func GetPendingChannels(db *sqlx.DB, nodeId int) ([]PendingChannel, error) {
+	pendingChannels := []PendingChannel{}
+	err := db.Select(&pendingChannels, `
+		SELECT * FROM pending_channel
+		WHERE $1=0 OR node_id=$1
+		ORDER BY blocks_til_maturity NULLS LAST, node_id, channel_point;`, nodeId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return pendingChannels, nil
+}
+
+func upsertPendingChannel(db *sqlx.DB, pendingChannel PendingChannel) error {
+	_, err := db.NamedExec(`
+		INSERT INTO pending_channel (node_id, channel_point, channel_id, remote_public_key, state, capacity,
+			local_balance, remote_balance, limbo_balance, recovered_balance, closing_transaction_hash, maturity_height,
+			blocks_til_maturity, pending_htlcs, sweep_state, anchor_state, created_on, updated_on)
+		VALUES (:node_id, :channel_point, :channel_id, :remote_public_key, :state, :capacity, :local_balance,
+			:remote_balance, :limbo_balance, :recovered_balance, :closing_transaction_hash, :maturity_height,
+			:blocks_til_maturity, :pending_htlcs, :sweep_state, :anchor_state, :created_on, :updated_on)
+		ON CONFLICT (node_id, channel_point) DO UPDATE SET channel_id=EXCLUDED.channel_id, state=EXCLUDED.state,
+			capacity=EXCLUDED.capacity, local_balance=EXCLUDED.local_balance, remote_balance=EXCLUDED.remote_balance,
+			limbo_balance=EXCLUDED.limbo_balance, recovered_balance=EXCLUDED.recovered_balance,
+			closing_transaction_hash=EXCLUDED.closing_transaction_hash, maturity_height=EXCLUDED.maturity_height,
+			blocks_til_maturity=EXCLUDED.blocks_til_maturity, pending_htlcs=EXCLUDED.pending_htlcs,
+			sweep_state=EXCLUDED.sweep_state, anchor_state=EXCLUDED.anchor_state, updated_on=EXCLUDED.updated_on;`,
+		pendingChannel)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func removePendingChannel(db *sqlx.DB, nodeId int, channelPoint string) error {
+	_, err := db.Exec(`DELETE FROM pending_channel WHERE node_id=$1 AND channel_point=$2;`, nodeId, channelPoint)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
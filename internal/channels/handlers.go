@@ -12,8 +12,9 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
 
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/node_client"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -34,13 +35,16 @@ type updateResponse struct {
 }
 
 type updateChanRequestBody struct {
-	NodeId        int     `json:"nodeId"`
+	NodeId        int     `json:"nodeId" binding:"required"`
 	ChannelPoint  *string `json:"channelPoint"`
 	FeeRatePpm    *uint32 `json:"feeRatePpm"`
 	BaseFeeMsat   *int64  `json:"baseFeeMsat"`
 	MaxHtlcMsat   *uint64 `json:"maxHtlcMsat"`
 	MinHtlcMsat   *uint64 `json:"minHtlcMsat"`
 	TimeLockDelta uint32  `json:"timeLockDelta"`
+	// Inbound fees are negative for a discount, when only one is given the other is set to 0.
+	InboundBaseFeeMsat *int32 `json:"inboundBaseFeeMsat"`
+	InboundFeeRatePpm  *int32 `json:"inboundFeeRatePpm"`
 }
 type pendingChannel struct {
 	PendingChannelPoint string `json:"pendingChannelPoint"`
@@ -68,6 +72,8 @@ type channelBody struct {
 	MaxHtlcMsat                  uint64               `json:"maxHtlcMsat"`
 	TimeLockDelta                uint32               `json:"timeLockDelta"`
 	FeeRatePpm                   int64                `json:"feeRatePpm"`
+	InboundBaseFeeMsat           int32                `json:"inboundBaseFeeMsat"`
+	InboundFeeRatePpm            int32                `json:"inboundFeeRatePpm"`
 	PendingForwardingHTLCsCount  int                  `json:"pendingForwardingHTLCsCount"`
 	PendingForwardingHTLCsAmount int64                `json:"pendingForwardingHTLCsAmount"`
 	PendingLocalHTLCsCount       int                  `json:"pendingLocalHTLCsCount"`
@@ -113,6 +119,7 @@ func updateChannelsHandler(c *gin.Context, db *sqlx.DB) {
 	}
 
 	response, err := updateChannels(db, requestBody)
+	audit.Record(db, c, audit.UpdateChannels, requestBody, response, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Update channel/s policy")
 		return
@@ -122,16 +129,16 @@ func updateChannelsHandler(c *gin.Context, db *sqlx.DB) {
 }
 
 type batchOpenChannel struct {
-	NodePubkey         string `json:"nodePubkey"`
-	LocalFundingAmount int64  `json:"localFundingAmount"`
+	NodePubkey         string `json:"nodePubkey" binding:"required"`
+	LocalFundingAmount int64  `json:"localFundingAmount" binding:"required"`
 	PushSat            *int64 `json:"pushSat"`
 	Private            *bool  `json:"private"`
 	MinHtlcMsat        *int64 `json:"minHtlcMsat"`
 }
 
 type BatchOpenRequest struct {
-	NodeId      int                `json:"nodeId"`
-	Channels    []batchOpenChannel `json:"channels"`
+	NodeId      int                `json:"nodeId" binding:"required"`
+	Channels    []batchOpenChannel `json:"channels" binding:"required"`
 	TargetConf  *int32             `json:"targetConf"`
 	SatPerVbyte *int64             `json:"satPerVbyte"`
 }
@@ -148,8 +155,9 @@ func batchOpenHandler(c *gin.Context, db *sqlx.DB) {
 	}
 
 	response, err := batchOpenChannels(db, batchOpnReq)
+	audit.Record(db, c, audit.BatchOpenChannels, batchOpnReq, response, err)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "Batch open channels")
+		server_errors.SendServerErrorOrWrap(c, err, "Batch open channels")
 		return
 	}
 
@@ -165,20 +173,13 @@ func getChannelListhandler(c *gin.Context, db *sqlx.DB) {
 	}
 
 	for _, node := range nodes {
-		conn, err := lnd_connect.Connect(
-			node.GRPCAddress,
-			node.TLSFileBytes,
-			node.MacaroonFileBytes)
+		client, err := settings.GetNodeClient(db, node.NodeId)
 		if err != nil {
 			errorMsg := fmt.Sprintf("Connect to node %d\n", node.NodeId)
 			server_errors.WrapLogAndSendServerError(c, err, errorMsg)
 			return
 		}
 
-		defer conn.Close()
-
-		client := lnrpc.NewLightningClient(conn)
-
 		r, err := client.ListChannels(context.Background(), &lnrpc.ListChannelsRequest{})
 		if err != nil {
 			server_errors.WrapLogAndSendServerError(c, err, "List channels")
@@ -195,6 +196,7 @@ func getChannelListhandler(c *gin.Context, db *sqlx.DB) {
 			stringLNDShortChannelId := strconv.FormatUint(channel.ChanId, 10)
 
 			pendingHTLCs := calculateHTLCs(channel.PendingHtlcs)
+			inboundFee := node_client.GetInboundFee(channelFee.Node1Policy)
 
 			gauge := (float64(channel.LocalBalance) / float64(channel.Capacity)) * 100
 			fundingTransactionHash, fundingOutputIndex := ParseChannelPoint(channel.ChannelPoint)
@@ -228,6 +230,8 @@ func getChannelListhandler(c *gin.Context, db *sqlx.DB) {
 				MaxHtlcMsat:                  channelFee.Node1Policy.MaxHtlcMsat,
 				TimeLockDelta:                channelFee.Node1Policy.TimeLockDelta,
 				FeeRatePpm:                   channelFee.Node1Policy.FeeRateMilliMsat,
+				InboundBaseFeeMsat:           inboundFee.BaseFeeMsat,
+				InboundFeeRatePpm:            inboundFee.FeeRatePpm,
 				NumUpdates:                   channel.NumUpdates,
 				Initiator:                    channel.Initiator,
 				ChanStatusFlags:              channel.ChanStatusFlags,
@@ -272,3 +276,21 @@ func calculateHTLCs(htlcs []*lnrpc.HTLC) PendingHtlcs {
 
 	return pendingHTLCs
 }
+
+func getPendingChannelsHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId := 0
+	if c.Query("nodeId") != "" {
+		var err error
+		nodeId, err = strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+	}
+	pendingChannels, err := GetPendingChannels(db, nodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting pending channels.")
+		return
+	}
+	c.JSON(http.StatusOK, pendingChannels)
+}
@@ -0,0 +1,369 @@
+package channels
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type channelPolicy struct {
+	ChannelId        int   `db:"channel_id"`
+	FeeRateMilliMsat int64 `db:"fee_rate_mill_msat"`
+	FeeBaseMsat      int64 `db:"fee_base_msat"`
+	Disabled         bool  `db:"disabled"`
+}
+
+type channelForwards struct {
+	ChannelId          int   `db:"channel_id"`
+	Count              int64 `db:"count"`
+	FeeMsat            int64 `db:"fee_msat"`
+	OutgoingAmountMsat int64 `db:"outgoing_amount_msat"`
+}
+
+// channelMetric holds everything exported for a single open channel.
+type channelMetric struct {
+	NodeId         int
+	ChannelId      int
+	ShortChannelId string
+	RemotePubkey   string
+	Tags           []string
+	Active         bool
+	Capacity       int64
+	LocalBalance   int64
+	RemoteBalance  int64
+	PendingHtlcs   PendingHtlcs
+	Policy         *channelPolicy
+	Forwards       channelForwards
+}
+
+// DefaultMetricsInterval is how often the channel metrics are refreshed from the nodes.
+const DefaultMetricsInterval = time.Minute
+
+// MetricsCollector exports the balances, fee policy, pending HTLCs and forwarding revenue of the open channels
+// of all active nodes. Channels are labelled with the names of their tags.
+// Scrapes are served from the last refresh so scraping never calls the nodes.
+type MetricsCollector struct {
+	db        *sqlx.DB
+	mu        sync.RWMutex
+	channels  []channelMetric
+	refreshed time.Time
+}
+
+func NewMetricsCollector(db *sqlx.DB) *MetricsCollector {
+	return &MetricsCollector{db: db}
+}
+
+// Start refreshes the channel metrics right away and then each interval until the context is done, an interval of 0
+// disables the channel metrics.
+func (c *MetricsCollector) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *MetricsCollector) refresh(ctx context.Context) {
+	nodes, err := settings.GetActiveNodesConnectionDetails(c.db)
+	if err != nil {
+		log.Error().Err(err).Msg("Obtaining the nodes for the channel metrics")
+		return
+	}
+	tags, err := getChannelTagNames(c.db)
+	if err != nil {
+		log.Error().Err(err).Msg("Obtaining the channel tags for the channel metrics")
+		return
+	}
+	var channelMetrics []channelMetric
+	for _, node := range nodes {
+		nodeChannelMetrics, err := getNodeChannelMetrics(ctx, c.db, node, tags)
+		if err != nil {
+			// One unreachable node should not hide the metrics of the others.
+			log.Error().Err(err).Msgf("Collecting channel metrics for node %d", node.NodeId)
+			continue
+		}
+		channelMetrics = append(channelMetrics, nodeChannelMetrics...)
+	}
+	c.set(channelMetrics, time.Now())
+}
+
+func (c *MetricsCollector) set(channelMetrics []channelMetric, refreshed time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels = channelMetrics
+	c.refreshed = refreshed
+}
+
+func getNodeChannelMetrics(ctx context.Context, db *sqlx.DB, node settings.ConnectionDetails,
+	tags map[int][]string) ([]channelMetric, error) {
+
+	client, err := settings.GetNodeClient(db, node.NodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Connect to node")
+	}
+
+	r, err := client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "List channels")
+	}
+	policies, err := getChannelPolicies(db, node.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	forwards, err := getChannelForwards(db, node.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	var channelMetrics []channelMetric
+	for _, channel := range r.Channels {
+		shortChannelId := ConvertLNDShortChannelID(channel.ChanId)
+		channelId := commons.GetChannelIdFromShortChannelId(shortChannelId)
+		channelMetrics = append(channelMetrics, channelMetric{
+			NodeId:         node.NodeId,
+			ChannelId:      channelId,
+			ShortChannelId: shortChannelId,
+			RemotePubkey:   channel.RemotePubkey,
+			Tags:           tags[channelId],
+			Active:         channel.Active,
+			Capacity:       channel.Capacity,
+			LocalBalance:   channel.LocalBalance,
+			RemoteBalance:  channel.RemoteBalance,
+			PendingHtlcs:   calculateHTLCs(channel.PendingHtlcs),
+			Policy:         policies[channelId],
+			Forwards:       forwards[channelId],
+		})
+	}
+	return channelMetrics, nil
+}
+
+func getChannelTagNames(db *sqlx.DB) (map[int][]string, error) {
+	var rows []struct {
+		ChannelId int    `db:"channel_id"`
+		Name      string `db:"name"`
+	}
+	err := db.Select(&rows, `
+		SELECT ct.channel_id, t.name
+		FROM channel_tag ct
+		JOIN tag t ON t.tag_id = ct.tag_id
+		ORDER BY ct.channel_id, t.name;`)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	tags := make(map[int][]string)
+	for _, row := range rows {
+		tags[row.ChannelId] = append(tags[row.ChannelId], row.Name)
+	}
+	return tags, nil
+}
+
+func getChannelPolicies(db *sqlx.DB, nodeId int) (map[int]*channelPolicy, error) {
+	var rows []channelPolicy
+	err := db.Select(&rows, `
+		SELECT DISTINCT ON (channel_id) channel_id,
+			COALESCE(fee_rate_mill_msat, 0) AS fee_rate_mill_msat,
+			COALESCE(fee_base_msat, 0) AS fee_base_msat,
+			COALESCE(disabled, false) AS disabled
+		FROM routing_policy
+		WHERE announcing_node_id = $1
+		ORDER BY channel_id, ts DESC;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	policies := make(map[int]*channelPolicy)
+	for i := range rows {
+		policies[rows[i].ChannelId] = &rows[i]
+	}
+	return policies, nil
+}
+
+func getChannelForwards(db *sqlx.DB, nodeId int) (map[int]channelForwards, error) {
+	var rows []channelForwards
+	err := db.Select(&rows, `
+		SELECT outgoing_channel_id AS channel_id,
+			COUNT(*) AS count,
+			COALESCE(SUM(fee_msat), 0) AS fee_msat,
+			COALESCE(SUM(outgoing_amount_msat), 0) AS outgoing_amount_msat
+		FROM forward
+		WHERE node_id = $1 AND outgoing_channel_id IS NOT NULL
+		GROUP BY outgoing_channel_id;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	forwards := make(map[int]channelForwards)
+	for _, row := range rows {
+		forwards[row.ChannelId] = row
+	}
+	return forwards, nil
+}
+
+// nodeTotal aggregates the channel metrics of a node.
+type nodeTotal struct {
+	activeChannels int
+	capacity       int64
+	localBalance   int64
+	remoteBalance  int64
+	pendingHtlcs   int
+	forwards       channelForwards
+}
+
+//nolint:gochecknoglobals
+var (
+	channelLabels = []string{"node_id", "channel_id", "short_channel_id", "remote_pubkey", "tags"}
+	nodeLabels    = []string{"node_id"}
+
+	channelActiveDesc = prometheus.NewDesc("torq_channel_active", "1 when the channel is active.",
+		channelLabels, nil)
+	channelCapacityDesc = prometheus.NewDesc("torq_channel_capacity_sat", "Capacity of the channel.",
+		channelLabels, nil)
+	channelLocalBalanceDesc = prometheus.NewDesc("torq_channel_local_balance_sat", "Local balance of the channel.",
+		channelLabels, nil)
+	channelRemoteBalanceDesc = prometheus.NewDesc("torq_channel_remote_balance_sat",
+		"Remote balance of the channel.", channelLabels, nil)
+	channelPendingForwardingHtlcsDesc = prometheus.NewDesc("torq_channel_pending_forwarding_htlcs",
+		"Number of pending forwarding HTLCs.", channelLabels, nil)
+	channelPendingLocalHtlcsDesc = prometheus.NewDesc("torq_channel_pending_local_htlcs",
+		"Number of pending HTLCs of payments and invoices.", channelLabels, nil)
+	channelFeeRateDesc = prometheus.NewDesc("torq_channel_fee_rate_ppm",
+		"Outgoing fee rate of the channel in parts per million.", channelLabels, nil)
+	channelBaseFeeDesc = prometheus.NewDesc("torq_channel_base_fee_msat", "Outgoing base fee of the channel.",
+		channelLabels, nil)
+	channelDisabledDesc = prometheus.NewDesc("torq_channel_disabled", "1 when the channel is disabled by our node.",
+		channelLabels, nil)
+	channelForwardsDesc = prometheus.NewDesc("torq_channel_forwards_total",
+		"Number of forwards leaving through the channel.", channelLabels, nil)
+	channelForwardFeeDesc = prometheus.NewDesc("torq_channel_forward_fee_msat_total",
+		"Fees earned by forwards leaving through the channel.", channelLabels, nil)
+	channelForwardAmountDesc = prometheus.NewDesc("torq_channel_forward_amount_msat_total",
+		"Amount forwarded out through the channel.", channelLabels, nil)
+	channelTagDesc = prometheus.NewDesc("torq_channel_tag", "1 for every tag of the channel.",
+		[]string{"node_id", "channel_id", "tag"}, nil)
+
+	nodeActiveChannelsDesc = prometheus.NewDesc("torq_node_active_channels",
+		"Number of active channels of the node.", nodeLabels, nil)
+	nodeCapacityDesc = prometheus.NewDesc("torq_node_capacity_sat",
+		"Total capacity of the open channels of the node.", nodeLabels, nil)
+	nodeLocalBalanceDesc = prometheus.NewDesc("torq_node_local_balance_sat",
+		"Total local balance of the open channels of the node.", nodeLabels, nil)
+	nodeRemoteBalanceDesc = prometheus.NewDesc("torq_node_remote_balance_sat",
+		"Total remote balance of the open channels of the node.", nodeLabels, nil)
+	nodePendingHtlcsDesc = prometheus.NewDesc("torq_node_pending_htlcs", "Number of pending HTLCs of the node.",
+		nodeLabels, nil)
+	nodeForwardsDesc = prometheus.NewDesc("torq_node_forwards_total",
+		"Number of forwards of the open channels of the node.", nodeLabels, nil)
+	nodeForwardFeeDesc = prometheus.NewDesc("torq_node_forward_fee_msat_total",
+		"Fees earned by the open channels of the node.", nodeLabels, nil)
+
+	refreshedDesc = prometheus.NewDesc("torq_channel_metrics_refreshed_timestamp_seconds",
+		"Unix time of the last refresh of the channel metrics.", nil, nil)
+)
+
+func (c *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{channelActiveDesc, channelCapacityDesc, channelLocalBalanceDesc,
+		channelRemoteBalanceDesc, channelPendingForwardingHtlcsDesc, channelPendingLocalHtlcsDesc, channelFeeRateDesc,
+		channelBaseFeeDesc, channelDisabledDesc, channelForwardsDesc, channelForwardFeeDesc, channelForwardAmountDesc,
+		channelTagDesc, nodeActiveChannelsDesc, nodeCapacityDesc, nodeLocalBalanceDesc, nodeRemoteBalanceDesc,
+		nodePendingHtlcsDesc, nodeForwardsDesc, nodeForwardFeeDesc, refreshedDesc} {
+		ch <- desc
+	}
+}
+
+func (c *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.refreshed.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(refreshedDesc, prometheus.GaugeValue, float64(c.refreshed.Unix()))
+
+	gauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	}
+	counter := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labelValues...)
+	}
+
+	nodeTotals := make(map[int]*nodeTotal)
+	var nodeIds []int
+	for _, channel := range c.channels {
+		labels := []string{strconv.Itoa(channel.NodeId), strconv.Itoa(channel.ChannelId), channel.ShortChannelId,
+			channel.RemotePubkey, strings.Join(channel.Tags, ",")}
+
+		gauge(channelActiveDesc, boolValue(channel.Active), labels...)
+		gauge(channelCapacityDesc, float64(channel.Capacity), labels...)
+		gauge(channelLocalBalanceDesc, float64(channel.LocalBalance), labels...)
+		gauge(channelRemoteBalanceDesc, float64(channel.RemoteBalance), labels...)
+		gauge(channelPendingForwardingHtlcsDesc, float64(channel.PendingHtlcs.ForwardingCount), labels...)
+		gauge(channelPendingLocalHtlcsDesc, float64(channel.PendingHtlcs.LocalCount), labels...)
+		if channel.Policy != nil {
+			gauge(channelFeeRateDesc, float64(channel.Policy.FeeRateMilliMsat), labels...)
+			gauge(channelBaseFeeDesc, float64(channel.Policy.FeeBaseMsat), labels...)
+			gauge(channelDisabledDesc, boolValue(channel.Policy.Disabled), labels...)
+		}
+		counter(channelForwardsDesc, float64(channel.Forwards.Count), labels...)
+		counter(channelForwardFeeDesc, float64(channel.Forwards.FeeMsat), labels...)
+		counter(channelForwardAmountDesc, float64(channel.Forwards.OutgoingAmountMsat), labels...)
+
+		// One sample per tag so dashboards can filter and group on a single tag.
+		for _, tag := range channel.Tags {
+			gauge(channelTagDesc, 1, strconv.Itoa(channel.NodeId), strconv.Itoa(channel.ChannelId), tag)
+		}
+
+		total, exists := nodeTotals[channel.NodeId]
+		if !exists {
+			total = &nodeTotal{}
+			nodeTotals[channel.NodeId] = total
+			nodeIds = append(nodeIds, channel.NodeId)
+		}
+		total.capacity += channel.Capacity
+		total.localBalance += channel.LocalBalance
+		total.remoteBalance += channel.RemoteBalance
+		total.pendingHtlcs += channel.PendingHtlcs.ForwardingCount + channel.PendingHtlcs.LocalCount
+		total.forwards.Count += channel.Forwards.Count
+		total.forwards.FeeMsat += channel.Forwards.FeeMsat
+		if channel.Active {
+			total.activeChannels++
+		}
+	}
+
+	sort.Ints(nodeIds)
+	for _, nodeId := range nodeIds {
+		total := nodeTotals[nodeId]
+		nodeIdLabel := strconv.Itoa(nodeId)
+		gauge(nodeActiveChannelsDesc, float64(total.activeChannels), nodeIdLabel)
+		gauge(nodeCapacityDesc, float64(total.capacity), nodeIdLabel)
+		gauge(nodeLocalBalanceDesc, float64(total.localBalance), nodeIdLabel)
+		gauge(nodeRemoteBalanceDesc, float64(total.remoteBalance), nodeIdLabel)
+		gauge(nodePendingHtlcsDesc, float64(total.pendingHtlcs), nodeIdLabel)
+		counter(nodeForwardsDesc, float64(total.forwards.Count), nodeIdLabel)
+		counter(nodeForwardFeeDesc, float64(total.forwards.FeeMsat), nodeIdLabel)
+	}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
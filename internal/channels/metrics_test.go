@@ -0,0 +1,90 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	collector := NewMetricsCollector(nil)
+
+	// Nothing is exported before the first refresh.
+	if count := testutil.CollectAndCount(collector); count != 0 {
+		t.Errorf("CollectAndCount() before refresh\nGot:\n%v\nWant:\n%v\n", count, 0)
+	}
+
+	collector.set([]channelMetric{
+		{
+			NodeId:         1,
+			ChannelId:      10,
+			ShortChannelId: "700000x1x0",
+			RemotePubkey:   "02ab",
+			Tags:           []string{"Routing", "Sink"},
+			Active:         true,
+			Capacity:       1000000,
+			LocalBalance:   600000,
+			RemoteBalance:  390000,
+			PendingHtlcs:   PendingHtlcs{ForwardingCount: 2, LocalCount: 1},
+			Policy:         &channelPolicy{ChannelId: 10, FeeRateMilliMsat: 250, FeeBaseMsat: 1000},
+			Forwards:       channelForwards{ChannelId: 10, Count: 3, FeeMsat: 1500, OutgoingAmountMsat: 6000000},
+		},
+		{
+			NodeId:         1,
+			ChannelId:      11,
+			ShortChannelId: "700001x2x1",
+			RemotePubkey:   "03cd",
+			Capacity:       500000,
+			LocalBalance:   100000,
+			RemoteBalance:  400000,
+		},
+	}, time.Unix(1700000000, 0))
+
+	// The fee policy is unknown for the second channel so it should not be exported as 0.
+	want := `
+# HELP torq_channel_fee_rate_ppm Outgoing fee rate of the channel in parts per million.
+# TYPE torq_channel_fee_rate_ppm gauge
+torq_channel_fee_rate_ppm{channel_id="10",node_id="1",remote_pubkey="02ab",short_channel_id="700000x1x0",tags="Routing,Sink"} 250
+# HELP torq_channel_forward_fee_msat_total Fees earned by forwards leaving through the channel.
+# TYPE torq_channel_forward_fee_msat_total counter
+torq_channel_forward_fee_msat_total{channel_id="10",node_id="1",remote_pubkey="02ab",short_channel_id="700000x1x0",tags="Routing,Sink"} 1500
+torq_channel_forward_fee_msat_total{channel_id="11",node_id="1",remote_pubkey="03cd",short_channel_id="700001x2x1",tags=""} 0
+# HELP torq_channel_local_balance_sat Local balance of the channel.
+# TYPE torq_channel_local_balance_sat gauge
+torq_channel_local_balance_sat{channel_id="10",node_id="1",remote_pubkey="02ab",short_channel_id="700000x1x0",tags="Routing,Sink"} 600000
+torq_channel_local_balance_sat{channel_id="11",node_id="1",remote_pubkey="03cd",short_channel_id="700001x2x1",tags=""} 100000
+# HELP torq_channel_metrics_refreshed_timestamp_seconds Unix time of the last refresh of the channel metrics.
+# TYPE torq_channel_metrics_refreshed_timestamp_seconds gauge
+torq_channel_metrics_refreshed_timestamp_seconds 1.7e+09
+# HELP torq_channel_pending_forwarding_htlcs Number of pending forwarding HTLCs.
+# TYPE torq_channel_pending_forwarding_htlcs gauge
+torq_channel_pending_forwarding_htlcs{channel_id="10",node_id="1",remote_pubkey="02ab",short_channel_id="700000x1x0",tags="Routing,Sink"} 2
+torq_channel_pending_forwarding_htlcs{channel_id="11",node_id="1",remote_pubkey="03cd",short_channel_id="700001x2x1",tags=""} 0
+# HELP torq_channel_tag 1 for every tag of the channel.
+# TYPE torq_channel_tag gauge
+torq_channel_tag{channel_id="10",node_id="1",tag="Routing"} 1
+torq_channel_tag{channel_id="10",node_id="1",tag="Sink"} 1
+# HELP torq_node_active_channels Number of active channels of the node.
+# TYPE torq_node_active_channels gauge
+torq_node_active_channels{node_id="1"} 1
+# HELP torq_node_capacity_sat Total capacity of the open channels of the node.
+# TYPE torq_node_capacity_sat gauge
+torq_node_capacity_sat{node_id="1"} 1.5e+06
+# HELP torq_node_forward_fee_msat_total Fees earned by the open channels of the node.
+# TYPE torq_node_forward_fee_msat_total counter
+torq_node_forward_fee_msat_total{node_id="1"} 1500
+# HELP torq_node_pending_htlcs Number of pending HTLCs of the node.
+# TYPE torq_node_pending_htlcs gauge
+torq_node_pending_htlcs{node_id="1"} 3
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(want),
+		"torq_channel_fee_rate_ppm", "torq_channel_forward_fee_msat_total", "torq_channel_local_balance_sat",
+		"torq_channel_metrics_refreshed_timestamp_seconds", "torq_channel_pending_forwarding_htlcs",
+		"torq_channel_tag", "torq_node_active_channels", "torq_node_capacity_sat", "torq_node_forward_fee_msat_total",
+		"torq_node_pending_htlcs")
+	if err != nil {
+		t.Errorf("CollectAndCompare()\nGot:\n%v\nWant:\n%v\n", err, nil)
+	}
+}
@@ -14,7 +14,8 @@ import (
 
 	"github.com/lncapital/torq/internal/peers"
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/internal/spending_policies"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 type OpenChannelRequest struct {
@@ -46,27 +47,23 @@ type PsbtDetails struct {
 	Psbt           []byte `json:"psbt,omitempty"`
 }
 
-func OpenChannel(db *sqlx.DB, eventChannel chan interface{}, req OpenChannelRequest, reqId string) (err error) {
+// OpenChannel sends the open updates to the client and returns the last update, it's nil when the node sent none.
+func OpenChannel(db *sqlx.DB, eventChannel chan interface{}, req OpenChannelRequest,
+	reqId string) (last *OpenChannelResponse, err error) {
 	openChanReq, err := prepareOpenRequest(req)
 	if err != nil {
-		return errors.Wrap(err, "Preparing open request")
+		return nil, errors.Wrap(err, "Preparing open request")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	err = spending_policies.CheckOnChain(db, req.NodeId, req.LocalFundingAmount)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return nil, err
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Connecting to LND")
+		return nil, errors.Wrap(err, "Connecting to node")
 	}
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
 
 	ctx := context.Background()
 
@@ -74,7 +71,7 @@ func OpenChannel(db *sqlx.DB, eventChannel chan interface{}, req OpenChannelRequ
 	if req.NodePubKey != "" && req.Host != nil {
 		//log.Debug().Msgf("Host provided. connect peer")
 		if err := checkConnectPeer(client, ctx, req.NodeId, req.NodePubKey, *req.Host); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -82,13 +79,13 @@ func OpenChannel(db *sqlx.DB, eventChannel chan interface{}, req OpenChannelRequ
 	openChanRes, err := client.OpenChannel(ctx, openChanReq)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return last, nil
 		default:
 		}
 
@@ -96,17 +93,18 @@ func OpenChannel(db *sqlx.DB, eventChannel chan interface{}, req OpenChannelRequ
 
 		if err == io.EOF {
 			//log.Info().Msgf("Open channel EOF")
-			return nil
+			return last, nil
 		}
 
 		if err != nil {
-			return errors.Wrapf(err, "Opening channel")
+			return last, errors.Wrapf(err, "Opening channel")
 		}
 
 		r, err := processOpenResponse(resp)
 		if err != nil {
-			return errors.Wrap(err, "Processing open response")
+			return last, errors.Wrap(err, "Processing open response")
 		}
+		last = r
 		if eventChannel != nil {
 			eventChannel <- r
 		}
@@ -226,7 +224,7 @@ func translateChanPoint(cb []byte, oi uint32) (string, error) {
 	return fmt.Sprintf("%s:%d", ch.String(), oi), nil
 }
 
-func checkConnectPeer(client lnrpc.LightningClient, ctx context.Context, nodeId int, remotePubkey string, host string) (err error) {
+func checkConnectPeer(client node_client.NodeClient, ctx context.Context, nodeId int, remotePubkey string, host string) (err error) {
 
 	peerList, err := peers.ListPeers(client, ctx, "true")
 	if err != nil {
@@ -0,0 +1,235 @@
+package channels
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type PendingChannelState string
+
+const (
+	PendingOpen       = PendingChannelState("pendingOpen")
+	WaitingClose      = PendingChannelState("waitingClose")
+	PendingForceClose = PendingChannelState("pendingForceClose")
+)
+
+type SweepState string
+
+const (
+	// TimeLocked means the funds of the force close can't be swept before the maturity height.
+	TimeLocked = SweepState("timeLocked")
+	Sweeping   = SweepState("sweeping")
+	Swept      = SweepState("swept")
+)
+
+type PendingChannel struct {
+	NodeId                 int                 `json:"nodeId" db:"node_id"`
+	ChannelPoint           string              `json:"channelPoint" db:"channel_point"`
+	ChannelId              *int                `json:"channelId" db:"channel_id"`
+	RemotePublicKey        string              `json:"remotePublicKey" db:"remote_public_key"`
+	State                  PendingChannelState `json:"state" db:"state"`
+	Capacity               int64               `json:"capacity" db:"capacity"`
+	LocalBalance           int64               `json:"localBalance" db:"local_balance"`
+	RemoteBalance          int64               `json:"remoteBalance" db:"remote_balance"`
+	LimboBalance           int64               `json:"limboBalance" db:"limbo_balance"`
+	RecoveredBalance       int64               `json:"recoveredBalance" db:"recovered_balance"`
+	ClosingTransactionHash *string             `json:"closingTransactionHash" db:"closing_transaction_hash"`
+	MaturityHeight         *uint32             `json:"maturityHeight" db:"maturity_height"`
+	// BlocksTilMaturity counts down to the maturity height, it's negative while the funds are swept.
+	BlocksTilMaturity *int32      `json:"blocksTilMaturity" db:"blocks_til_maturity"`
+	PendingHtlcs      int         `json:"pendingHtlcs" db:"pending_htlcs"`
+	SweepState        *SweepState `json:"sweepState" db:"sweep_state"`
+	AnchorState       *string     `json:"anchorState" db:"anchor_state"`
+	CreatedOn         time.Time   `json:"createdOn" db:"created_on"`
+	UpdatedOn         time.Time   `json:"updatedOn" db:"updated_on"`
+}
+
+// PendingChannelsFromResponse converts the pending channels reported by a node.
+func PendingChannelsFromResponse(nodeId int, resp *lnrpc.PendingChannelsResponse) []PendingChannel {
+	var pendingChannels []PendingChannel
+	for _, pendingOpen := range resp.PendingOpenChannels {
+		pendingChannels = append(pendingChannels, newPendingChannel(nodeId, PendingOpen, pendingOpen.Channel))
+	}
+	for _, waitingClose := range resp.WaitingCloseChannels {
+		pendingChannel := newPendingChannel(nodeId, WaitingClose, waitingClose.Channel)
+		pendingChannel.LimboBalance = waitingClose.LimboBalance
+		if waitingClose.ClosingTxid != "" {
+			pendingChannel.ClosingTransactionHash = &waitingClose.ClosingTxid
+		}
+		pendingChannels = append(pendingChannels, pendingChannel)
+	}
+	for _, forceClose := range resp.PendingForceClosingChannels {
+		pendingChannel := newPendingChannel(nodeId, PendingForceClose, forceClose.Channel)
+		pendingChannel.LimboBalance = forceClose.LimboBalance
+		pendingChannel.RecoveredBalance = forceClose.RecoveredBalance
+		if forceClose.ClosingTxid != "" {
+			pendingChannel.ClosingTransactionHash = &forceClose.ClosingTxid
+		}
+		if forceClose.MaturityHeight != 0 {
+			maturityHeight := forceClose.MaturityHeight
+			blocksTilMaturity := forceClose.BlocksTilMaturity
+			pendingChannel.MaturityHeight = &maturityHeight
+			pendingChannel.BlocksTilMaturity = &blocksTilMaturity
+		}
+		pendingChannel.PendingHtlcs = len(forceClose.PendingHtlcs)
+		sweepState := Swept
+		switch {
+		case pendingChannel.BlocksTilMaturity != nil && *pendingChannel.BlocksTilMaturity > 0:
+			sweepState = TimeLocked
+		case forceClose.LimboBalance > 0:
+			sweepState = Sweeping
+		}
+		pendingChannel.SweepState = &sweepState
+		if forceClose.Anchor != lnrpc.PendingChannelsResponse_ForceClosedChannel_LIMBO ||
+			hasAnchors(forceClose.Channel.GetCommitmentType()) {
+			anchorState := anchorStateName(forceClose.Anchor)
+			pendingChannel.AnchorState = &anchorState
+		}
+		pendingChannels = append(pendingChannels, pendingChannel)
+	}
+	return pendingChannels
+}
+
+func newPendingChannel(nodeId int, state PendingChannelState,
+	channel *lnrpc.PendingChannelsResponse_PendingChannel) PendingChannel {
+
+	pendingChannel := PendingChannel{
+		NodeId:          nodeId,
+		ChannelPoint:    channel.GetChannelPoint(),
+		RemotePublicKey: channel.GetRemoteNodePub(),
+		State:           state,
+		Capacity:        channel.GetCapacity(),
+		LocalBalance:    channel.GetLocalBalance(),
+		RemoteBalance:   channel.GetRemoteBalance(),
+	}
+	channelId := commons.GetChannelIdFromFundingTransaction(ParseChannelPoint(pendingChannel.ChannelPoint))
+	if channelId != 0 {
+		pendingChannel.ChannelId = &channelId
+	}
+	return pendingChannel
+}
+
+func hasAnchors(commitmentType lnrpc.CommitmentType) bool {
+	return commitmentType == lnrpc.CommitmentType_ANCHORS ||
+		commitmentType == lnrpc.CommitmentType_SCRIPT_ENFORCED_LEASE
+}
+
+func anchorStateName(anchor lnrpc.PendingChannelsResponse_ForceClosedChannel_AnchorState) string {
+	switch anchor {
+	case lnrpc.PendingChannelsResponse_ForceClosedChannel_RECOVERED:
+		return "recovered"
+	case lnrpc.PendingChannelsResponse_ForceClosedChannel_LOST:
+		return "lost"
+	}
+	return "limbo"
+}
+
+// changed compares everything the node reports, the timestamps are ignored.
+func (pendingChannel PendingChannel) changed(previous PendingChannel) bool {
+	return pendingChannel.State != previous.State ||
+		!equalIntPointers(pendingChannel.ChannelId, previous.ChannelId) ||
+		pendingChannel.LocalBalance != previous.LocalBalance ||
+		pendingChannel.RemoteBalance != previous.RemoteBalance ||
+		pendingChannel.LimboBalance != previous.LimboBalance ||
+		pendingChannel.RecoveredBalance != previous.RecoveredBalance ||
+		!equalStringPointers(pendingChannel.ClosingTransactionHash, previous.ClosingTransactionHash) ||
+		!equalUint32Pointers(pendingChannel.MaturityHeight, previous.MaturityHeight) ||
+		!equalInt32Pointers(pendingChannel.BlocksTilMaturity, previous.BlocksTilMaturity) ||
+		pendingChannel.PendingHtlcs != previous.PendingHtlcs ||
+		!equalStringPointers((*string)(pendingChannel.SweepState), (*string)(previous.SweepState)) ||
+		!equalStringPointers(pendingChannel.AnchorState, previous.AnchorState)
+}
+
+func equalIntPointers(a *int, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func equalUint32Pointers(a *uint32, b *uint32) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func equalInt32Pointers(a *int32, b *int32) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func equalStringPointers(a *string, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// StorePendingChannels replaces the pending channels of the node, a PendingChannelEvent is sent for every channel that
+// changed or is no longer pending. Open channels that are closing get the Closing status.
+func StorePendingChannels(db *sqlx.DB, nodeId int, pendingChannels []PendingChannel,
+	eventChannel chan interface{}) (int, error) {
+
+	stored, err := getStoredPendingChannels(db, nodeId)
+	if err != nil {
+		return 0, err
+	}
+	previousByChannelPoint := make(map[string]PendingChannel, len(stored))
+	for _, previous := range stored {
+		previousByChannelPoint[previous.ChannelPoint] = previous
+	}
+	now := time.Now().UTC()
+	changes := 0
+	for _, pendingChannel := range pendingChannels {
+		previous, exists := previousByChannelPoint[pendingChannel.ChannelPoint]
+		delete(previousByChannelPoint, pendingChannel.ChannelPoint)
+		if exists && !pendingChannel.changed(previous) {
+			continue
+		}
+		pendingChannel.CreatedOn = now
+		if exists {
+			pendingChannel.CreatedOn = previous.CreatedOn
+		}
+		pendingChannel.UpdatedOn = now
+		if err := upsertPendingChannel(db, pendingChannel); err != nil {
+			return changes, err
+		}
+		if pendingChannel.State != PendingOpen && pendingChannel.ChannelId != nil &&
+			commons.GetChannelStatusFromChannelId(*pendingChannel.ChannelId) == commons.Open {
+			if err := UpdateChannelStatus(db, *pendingChannel.ChannelId, commons.Closing); err != nil {
+				return changes, err
+			}
+		}
+		changes++
+		sendPendingChannelEvent(eventChannel, pendingChannel, false)
+	}
+	for channelPoint, resolved := range previousByChannelPoint {
+		if err := removePendingChannel(db, nodeId, channelPoint); err != nil {
+			return changes, err
+		}
+		changes++
+		sendPendingChannelEvent(eventChannel, resolved, true)
+	}
+	return changes, nil
+}
+
+func sendPendingChannelEvent(eventChannel chan interface{}, pendingChannel PendingChannel, resolved bool) {
+	if eventChannel == nil {
+		return
+	}
+	event := broadcast.PendingChannelEvent{
+		EventData:         broadcast.EventData{EventTime: time.Now().UTC(), NodeId: pendingChannel.NodeId},
+		ChannelPoint:      pendingChannel.ChannelPoint,
+		State:             string(pendingChannel.State),
+		LimboBalance:      pendingChannel.LimboBalance,
+		MaturityHeight:    pendingChannel.MaturityHeight,
+		BlocksTilMaturity: pendingChannel.BlocksTilMaturity,
+		Resolved:          resolved,
+	}
+	if pendingChannel.ChannelId != nil {
+		event.ChannelId = *pendingChannel.ChannelId
+	}
+	if pendingChannel.SweepState != nil {
+		event.SweepState = string(*pendingChannel.SweepState)
+	}
+	if pendingChannel.AnchorState != nil {
+		event.AnchorState = *pendingChannel.AnchorState
+	}
+	eventChannel <- event
+}
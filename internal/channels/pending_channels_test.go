@@ -0,0 +1,81 @@
+package channels
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestPendingChannelsFromResponse(t *testing.T) {
+	go commons.ManagedChannelCache(commons.ManagedChannelChannel, nil)
+
+	channel := func(channelPoint string) *lnrpc.PendingChannelsResponse_PendingChannel {
+		return &lnrpc.PendingChannelsResponse_PendingChannel{ChannelPoint: channelPoint, Capacity: 1000000,
+			LocalBalance: 600000, RemoteBalance: 400000, CommitmentType: lnrpc.CommitmentType_ANCHORS}
+	}
+	pendingChannels := PendingChannelsFromResponse(1, &lnrpc.PendingChannelsResponse{
+		PendingOpenChannels: []*lnrpc.PendingChannelsResponse_PendingOpenChannel{{Channel: channel("open:0")}},
+		WaitingCloseChannels: []*lnrpc.PendingChannelsResponse_WaitingCloseChannel{
+			{Channel: channel("waiting:0"), LimboBalance: 600000}},
+		PendingForceClosingChannels: []*lnrpc.PendingChannelsResponse_ForceClosedChannel{
+			{Channel: channel("locked:0"), LimboBalance: 600000, MaturityHeight: 1144, BlocksTilMaturity: 144,
+				ClosingTxid: "closing"},
+			{Channel: channel("sweeping:0"), LimboBalance: 600000, MaturityHeight: 1000, BlocksTilMaturity: -2,
+				Anchor: lnrpc.PendingChannelsResponse_ForceClosedChannel_RECOVERED},
+			{Channel: channel("swept:0"), RecoveredBalance: 600000, PendingHtlcs: []*lnrpc.PendingHTLC{{}}},
+		},
+	})
+
+	want := []struct {
+		state      PendingChannelState
+		sweepState SweepState
+		anchor     string
+	}{
+		{PendingOpen, "", ""},
+		{WaitingClose, "", ""},
+		{PendingForceClose, TimeLocked, "limbo"},
+		{PendingForceClose, Sweeping, "recovered"},
+		{PendingForceClose, Swept, "limbo"},
+	}
+	if len(pendingChannels) != len(want) {
+		t.Fatalf("PendingChannelsFromResponse()\nGot:\n%v\nWant:\n%v\n", len(pendingChannels), len(want))
+	}
+	for i, pendingChannel := range pendingChannels {
+		var sweepState SweepState
+		if pendingChannel.SweepState != nil {
+			sweepState = *pendingChannel.SweepState
+		}
+		var anchor string
+		if pendingChannel.AnchorState != nil {
+			anchor = *pendingChannel.AnchorState
+		}
+		if pendingChannel.State != want[i].state || sweepState != want[i].sweepState || anchor != want[i].anchor {
+			t.Errorf("PendingChannelsFromResponse() %v\nGot:\n%v %v %v\nWant:\n%v\n", pendingChannel.ChannelPoint,
+				pendingChannel.State, sweepState, anchor, want[i])
+		}
+	}
+	if locked := pendingChannels[2]; *locked.BlocksTilMaturity != 144 || *locked.ClosingTransactionHash != "closing" {
+		t.Errorf("PendingChannelsFromResponse() maturity\nGot:\n%v %v\nWant:\n%v %v\n", *locked.BlocksTilMaturity,
+			*locked.ClosingTransactionHash, 144, "closing")
+	}
+	if pendingChannels[4].PendingHtlcs != 1 {
+		t.Errorf("PendingChannelsFromResponse() pending htlcs\nGot:\n%v\nWant:\n%v\n", pendingChannels[4].PendingHtlcs, 1)
+	}
+}
+
+func TestPendingChannelChanged(t *testing.T) {
+	blocks := int32(10)
+	fewerBlocks := int32(9)
+	previous := PendingChannel{State: PendingForceClose, LimboBalance: 1000, BlocksTilMaturity: &blocks}
+	same := previous
+	countdown := previous
+	countdown.BlocksTilMaturity = &fewerBlocks
+	if same.changed(previous) {
+		t.Errorf("changed() same\nGot:\n%v\nWant:\n%v\n", true, false)
+	}
+	if !countdown.changed(previous) {
+		t.Errorf("changed() count down\nGot:\n%v\nWant:\n%v\n", false, true)
+	}
+}
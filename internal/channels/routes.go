@@ -3,10 +3,37 @@ package channels
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/openapi"
 )
 
 func RegisterChannelRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.PUT("update", func(c *gin.Context) { updateChannelsHandler(c, db) })
 	r.POST("openbatch", func(c *gin.Context) { batchOpenHandler(c, db) })
 	r.GET("", func(c *gin.Context) { getChannelListhandler(c, db) })
+	r.GET("pending", func(c *gin.Context) { getPendingChannelsHandler(c, db) })
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"PUT update": {
+			Summary:     "Update the routing policy of a channel or all channels of a node",
+			RequestBody: updateChanRequestBody{},
+			Response:    updateResponse{},
+		},
+		"POST openbatch": {
+			Summary:     "Open multiple channels in a single transaction",
+			RequestBody: BatchOpenRequest{},
+			Response:    BatchOpenResponse{},
+		},
+		"GET ": {
+			Summary:  "List the open channels",
+			Response: []channelBody{},
+		},
+		"GET pending": {
+			Summary:         "List the opening and closing channels with their limbo balance and maturity",
+			QueryParameters: []string{"nodeId"},
+			Response:        []PendingChannel{},
+		},
+	}
 }
@@ -11,7 +11,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 // UpdateChannel
@@ -23,25 +23,12 @@ func updateChannels(db *sqlx.DB, req updateChanRequestBody) (r updateResponse, e
 		return updateResponse{}, errors.Wrap(err, "Create policy request")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
-
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return updateResponse{}, errors.Wrap(err, "Getting node connection details from the db")
+		return updateResponse{}, errors.Wrap(err, "Connecting to node")
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return updateResponse{}, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	defer conn.Close()
-
 	ctx := context.Background()
-	client := lnrpc.NewLightningClient(conn)
-
 	resp, err := client.UpdateChannelPolicy(ctx, policyReq)
 	if err != nil {
 		return updateResponse{}, errors.Wrap(err, "Updating channel policy")
@@ -92,6 +79,17 @@ func createPolicyRequest(req updateChanRequestBody) (r *lnrpc.PolicyUpdateReques
 	if req.BaseFeeMsat != nil {
 		updChanReq.BaseFeeMsat = *req.BaseFeeMsat
 	}
+
+	if req.InboundBaseFeeMsat != nil || req.InboundFeeRatePpm != nil {
+		inboundFee := node_client.InboundFee{}
+		if req.InboundBaseFeeMsat != nil {
+			inboundFee.BaseFeeMsat = *req.InboundBaseFeeMsat
+		}
+		if req.InboundFeeRatePpm != nil {
+			inboundFee.FeeRatePpm = *req.InboundFeeRatePpm
+		}
+		node_client.SetPolicyUpdateInboundFee(updChanReq, inboundFee)
+	}
 	return updChanReq, nil
 }
 
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 func Test_processChannelPoint(t *testing.T) {
@@ -67,6 +69,13 @@ func Test_createPolicyRequest(t *testing.T) {
 
 	chanPoint := "e43bf0d5f03e179c2d107a1a8e4303bca066e883f4dbc0d9394f0c5b0721c7ce:0"
 
+	var inboundFeeRatePpm int32 = -15
+	withInboundFee := &lnrpc.PolicyUpdateRequest{
+		Scope:         &lnrpc.PolicyUpdateRequest_Global{Global: true},
+		TimeLockDelta: 18,
+	}
+	node_client.SetPolicyUpdateInboundFee(withInboundFee, node_client.InboundFee{FeeRatePpm: -15})
+
 	tests := []struct {
 		name    string
 		input   updateChanRequestBody
@@ -161,6 +170,17 @@ func Test_createPolicyRequest(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"Inbound fee rate provided",
+			updateChanRequestBody{
+				NodeId:            1,
+				ChannelPoint:      noChanPoint,
+				TimeLockDelta:     18,
+				InboundFeeRatePpm: &inboundFeeRatePpm,
+			},
+			withInboundFee,
+			false,
+		},
 	}
 	for i, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
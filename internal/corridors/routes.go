@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 	"net/http"
 	"strconv"
@@ -26,3 +27,9 @@ func getCorridorsHandler(c *gin.Context, db *sqlx.DB) {
 	}
 	c.JSON(http.StatusOK, corridors)
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {Summary: "List the corridors of a corridor type", Response: []*Corridor{}},
+	}
+}
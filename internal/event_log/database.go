@@ -0,0 +1,92 @@
+package event_log
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+type LoggedEvent struct {
+	Seq       int64          `json:"seq" db:"seq"`
+	EventType EventType      `json:"eventType" db:"event_type"`
+	NodeId    *int           `json:"nodeId" db:"node_id"`
+	ChannelId *int           `json:"channelId" db:"channel_id"`
+	Payload   types.JSONText `json:"payload" db:"payload"`
+	CreatedOn time.Time      `json:"createdOn" db:"created_on"`
+}
+
+// Description of the logged event for filtering it like a live event.
+func (e LoggedEvent) Description() Description {
+	description := Description{EventType: e.EventType}
+	if e.NodeId != nil {
+		description.NodeId = *e.NodeId
+	}
+	if e.ChannelId != nil {
+		description.ChannelId = *e.ChannelId
+	}
+	return description
+}
+
+// addEvents stores the events under their seq in one transaction.
+func addEvents(db *sqlx.DB, events []LoggedEvent) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, event := range events {
+		_, err = tx.Exec(`
+			INSERT INTO event_log (seq, event_type, node_id, channel_id, payload, created_on)
+			VALUES ($1, $2, $3, $4, $5, $6);`,
+			event.Seq, event.EventType, event.NodeId, event.ChannelId, event.Payload, event.CreatedOn)
+		if err != nil {
+			return errors.Wrap(err, database.SqlExecutionError)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return nil
+}
+
+// pruneEvents removes the events with a seq up to and including maxSeq.
+func pruneEvents(db *sqlx.DB, maxSeq int64) error {
+	_, err := db.Exec(`DELETE FROM event_log WHERE seq <= $1;`, maxSeq)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// getSeqRange returns the first and last seq in the log, both are 0 when the log is empty.
+func getSeqRange(db *sqlx.DB) (first int64, last int64, err error) {
+	err = db.QueryRowx(`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM event_log;`).Scan(&first, &last)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return first, last, nil
+}
+
+func getEventsAfter(db *sqlx.DB, seq int64, limit int) ([]LoggedEvent, error) {
+	var events []LoggedEvent
+	err := db.Select(&events, `SELECT * FROM event_log WHERE seq > $1 ORDER BY seq LIMIT $2;`, seq, limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []LoggedEvent{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return events, nil
+}
+
+func nullableId(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
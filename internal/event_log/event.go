@@ -0,0 +1,105 @@
+package event_log
+
+import (
+	"github.com/lncapital/torq/internal/approvals"
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/on_chain_tx"
+	"github.com/lncapital/torq/internal/payments"
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+type EventType string
+
+const (
+	TransactionEvent    = EventType("transaction")
+	ChannelEvent        = EventType("channel")
+	InvoiceEvent        = EventType("invoice")
+	PeerEvent           = EventType("peer")
+	ChannelGraphEvent   = EventType("channelGraph")
+	NodeGraphEvent      = EventType("nodeGraph")
+	PaymentEvent        = EventType("payment")
+	OpenChannelEvent    = EventType("openChannel")
+	CloseChannelEvent   = EventType("closeChannel")
+	NewAddressEvent     = EventType("newAddress")
+	ApprovalEvent       = EventType("approval")
+	StreamStatusEvent   = EventType("streamStatus")
+	BackfillEvent       = EventType("backfill")
+	ReconciliationEvent = EventType("reconciliation")
+	ChannelBackupEvent  = EventType("channelBackup")
+	BlockEvent          = EventType("block")
+	PendingChannelEvent = EventType("pendingChannel")
+	AutoFeeEvent        = EventType("autoFee")
+	RebalanceEvent      = EventType("rebalance")
+)
+
+func (eventType EventType) IsValid() bool {
+	switch eventType {
+	case TransactionEvent, ChannelEvent, InvoiceEvent, PeerEvent, ChannelGraphEvent, NodeGraphEvent, PaymentEvent,
+		OpenChannelEvent, CloseChannelEvent, NewAddressEvent, ApprovalEvent, StreamStatusEvent,
+		BackfillEvent, ReconciliationEvent, ChannelBackupEvent, BlockEvent, PendingChannelEvent,
+		AutoFeeEvent, RebalanceEvent:
+		return true
+	}
+	return false
+}
+
+// Description is what's needed to filter an event, 0 means the event has no node or channel.
+type Description struct {
+	EventType EventType
+	NodeId    int
+	ChannelId int
+}
+
+// Describe returns the type, node and channel of a broadcast event, ok is false for unknown events.
+func Describe(event interface{}) (description Description, ok bool) {
+	switch e := event.(type) {
+	case broadcast.Event:
+		if e.Type == "" {
+			return Description{}, false
+		}
+		return Description{EventType: EventType(e.Type), NodeId: e.NodeId, ChannelId: e.ChannelId}, true
+	case broadcast.TransactionEvent:
+		return Description{EventType: TransactionEvent, NodeId: e.NodeId}, true
+	case broadcast.ChannelEvent:
+		return Description{EventType: ChannelEvent, NodeId: e.NodeId, ChannelId: e.ChannelId}, true
+	case broadcast.InvoiceEvent:
+		return Description{EventType: InvoiceEvent, NodeId: e.NodeId}, true
+	case broadcast.PeerEvent:
+		return Description{EventType: PeerEvent, NodeId: e.NodeId}, true
+	case broadcast.ChannelGraphEvent:
+		description = Description{EventType: ChannelGraphEvent, NodeId: e.NodeId}
+		if e.ChannelId != nil {
+			description.ChannelId = *e.ChannelId
+		}
+		return description, true
+	case broadcast.NodeGraphEvent:
+		return Description{EventType: NodeGraphEvent, NodeId: e.NodeId}, true
+	case payments.NewPaymentResponse:
+		return Description{EventType: PaymentEvent, NodeId: e.NodeId}, true
+	case channels.OpenChannelResponse:
+		return Description{EventType: OpenChannelEvent}, true
+	case channels.CloseChannelResponse:
+		return Description{EventType: CloseChannelEvent}, true
+	case on_chain_tx.NewAddressResponse:
+		return Description{EventType: NewAddressEvent}, true
+	case broadcast.StreamStatusEvent:
+		return Description{EventType: StreamStatusEvent, NodeId: e.NodeId}, true
+	case broadcast.BackfillEvent:
+		return Description{EventType: BackfillEvent, NodeId: e.NodeId}, true
+	case broadcast.ReconciliationEvent:
+		return Description{EventType: ReconciliationEvent, NodeId: e.NodeId}, true
+	case broadcast.ChannelBackupEvent:
+		return Description{EventType: ChannelBackupEvent, NodeId: e.NodeId}, true
+	case broadcast.BlockEvent:
+		return Description{EventType: BlockEvent, NodeId: e.NodeId}, true
+	case broadcast.PendingChannelEvent:
+		return Description{EventType: PendingChannelEvent, NodeId: e.NodeId, ChannelId: e.ChannelId}, true
+	case broadcast.AutoFeeEvent:
+		return Description{EventType: AutoFeeEvent, NodeId: e.NodeId, ChannelId: e.ChannelId}, true
+	case broadcast.RebalanceEvent:
+		return Description{EventType: RebalanceEvent, NodeId: e.NodeId}, true
+	case approvals.ApprovalEvent:
+		return Description{EventType: ApprovalEvent, NodeId: e.Approval.NodeId}, true
+	}
+	return Description{}, false
+}
@@ -0,0 +1,143 @@
+package event_log
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+// DefaultSize is the default number of events kept in the log.
+const DefaultSize = 10000
+
+// pruneEvery is the number of stored events between removing the events that no longer fit in the log.
+const pruneEvery = 100
+
+// Gap is returned when events after FromSeq are missing from the log up to FirstAvailableSeq, because they were
+// already removed or because the database couldn't keep up.
+type Gap struct {
+	FromSeq           int64 `json:"fromSeq"`
+	FirstAvailableSeq int64 `json:"firstAvailableSeq"`
+	LastSeq           int64 `json:"lastSeq"`
+}
+
+// Start passes every event from source on as broadcast.Event and stores the known events in the log in the
+// background.
+// A full write buffer drops events from the log instead of stalling the event stream, events that are still waiting
+// to be stored are replayed from memory so a client that subscribes before replaying never misses an event.
+// Unknown events and events received before the last seq could be read from the log have no seq.
+func Start(ctx context.Context, db *sqlx.DB, source <-chan interface{}, size int) <-chan broadcast.Event {
+	if size < 1 {
+		size = DefaultSize
+	}
+	go eventWriter.run(ctx, db, size)
+	sequenced := make(chan broadcast.Event)
+	go func() {
+		defer close(sequenced)
+		var lastSeq int64
+		seqKnown := false
+		for {
+			var event broadcast.Event
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-source:
+				if !ok {
+					return
+				}
+				event = describe(e)
+			}
+
+			if !seqKnown {
+				_, last, err := getSeqRange(db)
+				if err != nil {
+					log.Error().Err(err).Msg("Getting the last seq of the event log")
+				}
+				lastSeq, seqKnown = last, err == nil
+			}
+			if seqKnown && event.Type != "" && sequence(eventWriter, lastSeq+1, event) {
+				event.Seq = lastSeq + 1
+				lastSeq = event.Seq
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case sequenced <- event:
+			}
+		}
+	}()
+	return sequenced
+}
+
+// describe wraps the event with what's needed to filter it, the type is empty for unknown events.
+func describe(event interface{}) broadcast.Event {
+	description, ok := Describe(event)
+	if !ok {
+		return broadcast.Event{Payload: event}
+	}
+	return broadcast.Event{Type: string(description.EventType), NodeId: description.NodeId,
+		ChannelId: description.ChannelId, Payload: event}
+}
+
+// sequence queues the event for the log under seq, when the write buffer is full the event keeps its seq but is
+// missing from the log. It returns false when the event can't be logged.
+func sequence(w *writer, seq int64, event broadcast.Event) bool {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		log.Error().Err(err).Msgf("Marshalling %v event for the event log", event.Type)
+		return false
+	}
+	w.queue(LoggedEvent{
+		Seq:       seq,
+		EventType: EventType(event.Type),
+		NodeId:    nullableId(event.NodeId),
+		ChannelId: nullableId(event.ChannelId),
+		Payload:   payload,
+		CreatedOn: time.Now().UTC(),
+	})
+	return true
+}
+
+// GetEventsAfter returns the logged events after fromSeq in order with the gaps (ordered by FromSeq) where events
+// are missing. The first gap starts at fromSeq when events after it were already removed from the log or when fromSeq
+// is unknown (i.e. higher than the last seq), the events returned then start at the first available seq.
+func GetEventsAfter(db *sqlx.DB, fromSeq int64) ([]LoggedEvent, []Gap, error) {
+	// Taken before reading the log so events stored in the meantime are read from the log.
+	unwritten := eventWriter.getUnwritten()
+	lastSeq := eventWriter.getLastSeq()
+	first, last, err := getSeqRange(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(unwritten) != 0 {
+		if first == 0 {
+			first = unwritten[0].Seq
+		}
+		if unwritten[len(unwritten)-1].Seq > last {
+			last = unwritten[len(unwritten)-1].Seq
+		}
+	}
+	// Dropped events have a seq but are not in the log.
+	if lastSeq > last {
+		last = lastSeq
+	}
+	var gaps []Gap
+	if fromSeq > last || (first != 0 && fromSeq+1 < first) {
+		gaps = append(gaps, Gap{FromSeq: fromSeq, FirstAvailableSeq: first, LastSeq: last})
+		fromSeq = first - 1
+	}
+	if last == 0 || fromSeq >= last {
+		return []LoggedEvent{}, gaps, nil
+	}
+	events, err := getEventsAfter(db, fromSeq, int(last-fromSeq))
+	if err != nil {
+		return nil, nil, err
+	}
+	events = appendUnwritten(events, unwritten, fromSeq)
+	return events, append(gaps, findGaps(events, fromSeq, last)...), nil
+}
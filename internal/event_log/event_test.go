@@ -0,0 +1,164 @@
+package event_log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+func TestDescribe(t *testing.T) {
+	channelId := 3
+	event := describe(broadcast.ChannelGraphEvent{GraphEventData: broadcast.GraphEventData{
+		EventData: broadcast.EventData{NodeId: 1}, ChannelId: &channelId}})
+	event.Seq = 42
+
+	description, ok := Describe(event)
+	want := Description{EventType: ChannelGraphEvent, NodeId: 1, ChannelId: 3}
+	if !ok || description != want {
+		t.Errorf("Describe()\nGot:\n%v %v\nWant:\n%v %v\n", description, ok, want, true)
+	}
+
+	got, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(got, &fields); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if fields["seq"] != float64(42) || fields["nodeId"] != float64(1) || fields["channelId"] != float64(3) {
+		t.Errorf("json.Marshal()\nGot:\n%v\nWant:\n%v\n", string(got), "seq 42 and the fields of the event")
+	}
+
+	if _, ok := Describe("unknown"); ok {
+		t.Errorf("Describe() of an unknown event should not be ok")
+	}
+	if _, ok := Describe(describe("unknown")); ok {
+		t.Errorf("Describe() of an unknown broadcast event should not be ok")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	w := newWriter(2)
+	for seq := int64(1); seq <= 3; seq++ {
+		queued := w.queue(LoggedEvent{Seq: seq, EventType: PeerEvent})
+		if queued != (seq <= 2) {
+			t.Errorf("queue(%v)\nGot:\n%v\nWant:\n%v\n", seq, queued, seq <= 2)
+		}
+	}
+
+	// The dropped event keeps its seq so replaying reports it as a gap.
+	if lastSeq := w.getLastSeq(); lastSeq != 3 {
+		t.Errorf("getLastSeq()\nGot:\n%v\nWant:\n%v\n", lastSeq, 3)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Waiting events are still returned when the context is done.
+	batch, ok := w.nextBatch(ctx)
+	if !ok || len(batch) != 2 || batch[0].Seq != 1 || batch[1].Seq != 2 {
+		t.Fatalf("nextBatch()\nGot:\n%v %v\nWant:\n%v\n", batch, ok, "seq 1 and 2")
+	}
+	if _, ok := w.nextBatch(ctx); ok {
+		t.Errorf("nextBatch() of an empty buffer after the context is done should not be ok")
+	}
+
+	if unwritten := w.getUnwritten(); len(unwritten) != 2 {
+		t.Errorf("getUnwritten()\nGot:\n%v\nWant:\n%v\n", len(unwritten), 2)
+	}
+	if dropped := w.written(1); dropped != 1 {
+		t.Errorf("written() dropped\nGot:\n%v\nWant:\n%v\n", dropped, 1)
+	}
+	unwritten := w.getUnwritten()
+	if len(unwritten) != 1 || unwritten[0].Seq != 2 {
+		t.Errorf("getUnwritten() after written(1)\nGot:\n%v\nWant:\n%v\n", unwritten, "seq 2")
+	}
+}
+
+func TestAppendUnwritten(t *testing.T) {
+	stored := []LoggedEvent{{Seq: 4}, {Seq: 5}}
+	unwritten := []LoggedEvent{{Seq: 5}, {Seq: 6}, {Seq: 7}}
+	tests := []struct {
+		name    string
+		events  []LoggedEvent
+		fromSeq int64
+		want    []int64
+	}{
+		{"Stored and unwritten", stored, 3, []int64{4, 5, 6, 7}},
+		{"Only unwritten", nil, 5, []int64{6, 7}},
+		{"Nothing new", nil, 7, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []int64
+			for _, event := range appendUnwritten(append([]LoggedEvent{}, test.events...), unwritten, test.fromSeq) {
+				got = append(got, event.Seq)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("appendUnwritten()\nGot:\n%v\nWant:\n%v\n", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriterStore(t *testing.T) {
+	w := newWriter(1)
+	w.retryDelay = time.Millisecond
+	batch := []LoggedEvent{{Seq: 1}}
+
+	// A batch that fails is retried until it's stored.
+	calls := 0
+	err := w.store(context.Background(), batch, func([]LoggedEvent) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("store() retried\nGot:\n%v calls, %v\nWant:\n%v calls, %v\n", calls, err, 3, nil)
+	}
+
+	// It's given up after writeAttempts.
+	calls = 0
+	err = w.store(context.Background(), batch, func([]LoggedEvent) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if err == nil || calls != writeAttempts {
+		t.Errorf("store() failing\nGot:\n%v calls, %v\nWant:\n%v calls, %v\n", calls, err, writeAttempts, "error")
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		seqs    []int64
+		fromSeq int64
+		lastSeq int64
+		want    []Gap
+	}{
+		{"No gaps", []int64{4, 5, 6}, 3, 6, nil},
+		{"Hole", []int64{4, 7, 8}, 3, 8, []Gap{{FromSeq: 4, FirstAvailableSeq: 7, LastSeq: 8}}},
+		{"Missing first", []int64{6}, 3, 6, []Gap{{FromSeq: 3, FirstAvailableSeq: 6, LastSeq: 6}}},
+		{"Missing last", []int64{4}, 3, 6, []Gap{{FromSeq: 4, FirstAvailableSeq: 7, LastSeq: 6}}},
+		{"Nothing stored", nil, 3, 5, []Gap{{FromSeq: 3, FirstAvailableSeq: 6, LastSeq: 5}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []LoggedEvent
+			for _, seq := range test.seqs {
+				events = append(events, LoggedEvent{Seq: seq})
+			}
+			got := findGaps(events, test.fromSeq, test.lastSeq)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("findGaps()\nGot:\n%v\nWant:\n%v\n", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package event_log
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/metrics"
+)
+
+const (
+	// writeBufferSize is the number of events waiting to be stored, events that don't fit are not logged.
+	writeBufferSize = 4096
+	// writeBatchSize is the maximum number of events stored in one transaction.
+	writeBatchSize = 500
+	// writeAttempts is how often a batch is stored before its events are given up, replaying them reports a gap.
+	writeAttempts = 5
+	// writeRetryDelay is the wait before the second attempt, every next attempt waits one delay longer.
+	writeRetryDelay = time.Second
+)
+
+//nolint:gochecknoglobals
+var (
+	eventWriter   = newWriter(writeBufferSize)
+	droppedEvents = metrics.NewCounter("torq_event_log_dropped_events_total",
+		"Number of events not stored in the event log because the database couldn't keep up.")
+)
+
+// writer stores the sequenced events in batches so the event stream never waits for the database.
+// Events that are queued but not stored yet are kept in unwritten so they can be replayed already.
+type writer struct {
+	entries    chan LoggedEvent
+	retryDelay time.Duration
+	mu         sync.Mutex
+	unwritten  []LoggedEvent
+	dropped    int
+	// lastSeq is the highest seq given to an event, including events that were dropped.
+	lastSeq int64
+}
+
+func newWriter(bufferSize int) *writer {
+	return &writer{entries: make(chan LoggedEvent, bufferSize), retryDelay: writeRetryDelay}
+}
+
+// queue adds the event to the buffer without waiting, it returns false when the buffer is full.
+func (w *writer) queue(entry LoggedEvent) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if entry.Seq > w.lastSeq {
+		w.lastSeq = entry.Seq
+	}
+	select {
+	case w.entries <- entry:
+		w.unwritten = append(w.unwritten, entry)
+		return true
+	default:
+		w.dropped++
+		droppedEvents.Inc()
+		return false
+	}
+}
+
+// written removes the events up to and including seq from unwritten and returns the number of events dropped
+// since the previous call.
+func (w *writer) written(seq int64) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	i := 0
+	for i < len(w.unwritten) && w.unwritten[i].Seq <= seq {
+		i++
+	}
+	w.unwritten = append([]LoggedEvent{}, w.unwritten[i:]...)
+	dropped := w.dropped
+	w.dropped = 0
+	return dropped
+}
+
+func (w *writer) getUnwritten() []LoggedEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]LoggedEvent{}, w.unwritten...)
+}
+
+func (w *writer) getLastSeq() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastSeq
+}
+
+// nextBatch waits for the next event and returns it with the events that are already waiting.
+// When the context is done the events that are still waiting are returned until none are left.
+func (w *writer) nextBatch(ctx context.Context) ([]LoggedEvent, bool) {
+	var batch []LoggedEvent
+	select {
+	case <-ctx.Done():
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+		default:
+			return nil, false
+		}
+	case entry := <-w.entries:
+		batch = append(batch, entry)
+	}
+	for len(batch) < writeBatchSize {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+		default:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// run stores the queued events and removes the events that no longer fit in the log until the context is done.
+// Events waiting when the context is done are still stored so their seqs aren't used again after a restart.
+func (w *writer) run(ctx context.Context, db *sqlx.DB, size int) {
+	stored := 0
+	for {
+		batch, ok := w.nextBatch(ctx)
+		if !ok {
+			return
+		}
+		lastSeq := batch[len(batch)-1].Seq
+		err := w.store(ctx, batch, func(batch []LoggedEvent) error { return addEvents(db, batch) })
+		if err != nil {
+			log.Error().Err(err).Msgf("Gave up storing seq %v to %v in the event log", batch[0].Seq, lastSeq)
+		}
+		if dropped := w.written(lastSeq); dropped != 0 {
+			log.Warn().Msgf("Dropped %v events from the event log because the database couldn't keep up", dropped)
+		}
+		stored += len(batch)
+		if stored >= pruneEvery {
+			stored = 0
+			if err := pruneEvents(db, lastSeq-int64(size)); err != nil {
+				log.Error().Err(err).Msg("Pruning event log")
+			}
+		}
+	}
+}
+
+// store retries a batch that failed so a short database outage doesn't leave a hole in the log, the events are
+// replayed from unwritten in the meantime. Once the context is done a failed batch is not retried.
+func (w *writer) store(ctx context.Context, batch []LoggedEvent, add func([]LoggedEvent) error) error {
+	for attempt := 1; ; attempt++ {
+		err := add(batch)
+		if err == nil || attempt == writeAttempts {
+			return err
+		}
+		log.Warn().Err(err).Msgf("Storing %v events in the event log, retrying", len(batch))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * w.retryDelay):
+		}
+	}
+}
+
+// findGaps returns a gap for every seq after fromSeq up to and including lastSeq that is missing from the events
+// (ordered by seq), i.e. events dropped because the database couldn't keep up.
+func findGaps(events []LoggedEvent, fromSeq int64, lastSeq int64) []Gap {
+	var gaps []Gap
+	previous := fromSeq
+	for _, event := range events {
+		if event.Seq > previous+1 {
+			gaps = append(gaps, Gap{FromSeq: previous, FirstAvailableSeq: event.Seq, LastSeq: lastSeq})
+		}
+		previous = event.Seq
+	}
+	if previous < lastSeq {
+		gaps = append(gaps, Gap{FromSeq: previous, FirstAvailableSeq: lastSeq + 1, LastSeq: lastSeq})
+	}
+	return gaps
+}
+
+// appendUnwritten adds the unwritten events after fromSeq that are not in events (ordered by seq) yet.
+func appendUnwritten(events []LoggedEvent, unwritten []LoggedEvent, fromSeq int64) []LoggedEvent {
+	after := fromSeq
+	if len(events) != 0 && events[len(events)-1].Seq > after {
+		after = events[len(events)-1].Seq
+	}
+	for _, event := range unwritten {
+		if event.Seq > after {
+			events = append(events, event)
+		}
+	}
+	return events
+}
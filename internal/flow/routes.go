@@ -3,8 +3,20 @@ package flow
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/openapi"
 )
 
 func RegisterFlowRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("", func(c *gin.Context) { getFlowHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "Get the flow through the channels",
+			Response:        []*channelFlowData{},
+			QueryParameters: []string{"from", "to", "chanIds"},
+		},
+	}
+}
@@ -76,6 +76,9 @@ type forwardsTableRow struct {
 	RevenueIn uint64 `json:"revenueIn"`
 	// The total revenue in sats. This is what the channel has directly and indirectly produced.
 	RevenueTotal uint64 `json:"revenueTotal"`
+	// The inbound fees in sats the channel charged on top of the outbound fees of other channels, negative for
+	// discounts. They're included in the inbound revenue and left out of the outbound revenue of the other channels.
+	InboundFees int64 `json:"inboundFees"`
 
 	// Number of outbound forwards.
 	CountOut uint64 `json:"countOut"`
@@ -92,7 +95,26 @@ type forwardsTableRow struct {
 func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 	fromTime time.Time, toTime time.Time) (r []*forwardsTableRow, err error) {
 
+	// The fee of a forward is the outbound fee of the outgoing channel plus the inbound fee of the incoming channel.
+	// The inbound fee is calculated on the outgoing amount plus the outbound fee with the policy of the incoming
+	// channel at the time of the forward.
 	var sqlString = `
+		with forward_fee as (
+			select f.time, f.incoming_channel_id, f.outgoing_channel_id, f.incoming_amount_msat,
+				f.outgoing_amount_msat, f.fee_msat,
+				least(coalesce(f.incoming_amount_msat - (f.incoming_amount_msat - rp.inbound_fee_base_msat) /
+					(1 + rp.inbound_fee_rate_milli_msat / 1000000.0), 0), f.fee_msat) as inbound_fee_msat
+			from forward f
+			left join lateral (
+				select inbound_fee_base_msat, inbound_fee_rate_milli_msat
+				from routing_policy
+				where channel_id = f.incoming_channel_id and announcing_node_id = f.node_id and ts <= f.time
+				order by ts desc
+				limit 1
+			) as rp on true
+			where f.time::timestamp AT TIME ZONE $3 >= $1::timestamp AT TIME ZONE $3
+				and f.time::timestamp AT TIME ZONE $3 <= $2::timestamp AT TIME ZONE $3
+		)
 		select
 			coalesce(scne.node_alias, LEFT(scn.public_key, 20)) as alias,
 			coalesce(ct.tag_ids, '') as tag_ids,
@@ -117,6 +139,7 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 			coalesce(fw.revenue_out, 0) as revenue_out,
 			coalesce(fw.revenue_in, 0) as revenue_in,
 			coalesce((fw.revenue_in + fw.revenue_out), 0) as revenue_total,
+			coalesce(fw.inbound_fees, 0) as inbound_fees,
 
 			coalesce(fw.count_out, 0) as count_out,
 			coalesce(fw.count_in, 0) as count_in,
@@ -163,25 +186,23 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 				coalesce(o.count,0) as count_out,
 				coalesce(i.amount,0) as amount_in,
 				coalesce(i.revenue,0) as revenue_in,
+				coalesce(i.inbound_fees,0) as inbound_fees,
 				coalesce(i.count,0) as count_in
 			from (
 				select outgoing_channel_id channel_id,
 					   floor(sum(outgoing_amount_msat)/1000) as amount,
-					   floor(sum(fee_msat)/1000) as revenue,
+					   floor(sum(fee_msat - inbound_fee_msat)/1000) as revenue,
 					   count(time) as count
-				from forward
-				where time::timestamp AT TIME ZONE $3 >= $1::timestamp AT TIME ZONE $3
-					and time::timestamp AT TIME ZONE $3 <= $2::timestamp AT TIME ZONE $3
+				from forward_fee
 				group by outgoing_channel_id
 			) as o
 			full outer join (
 				select incoming_channel_id as channel_id,
 					   floor(sum(incoming_amount_msat)/1000) as amount,
 					   floor(sum(fee_msat)/1000) as revenue,
+					   floor(sum(inbound_fee_msat)/1000) as inbound_fees,
 					   count(time) as count
-				from forward
-				where time::timestamp AT TIME ZONE $3 >= $1::timestamp AT TIME ZONE $3
-					and time::timestamp AT TIME ZONE $3 <= $2::timestamp AT TIME ZONE $3
+				from forward_fee
 				group by incoming_channel_id
 			) as i
 			on i.channel_id = o.channel_id
@@ -219,6 +240,7 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 			&c.RevenueOut,
 			&c.RevenueIn,
 			&c.RevenueTotal,
+			&c.InboundFees,
 
 			&c.CountOut,
 			&c.CountIn,
@@ -3,8 +3,20 @@ package forwards
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/openapi"
 )
 
 func RegisterForwardsRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("", func(c *gin.Context) { getForwardsTableHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "Get the forwarding statistics per channel",
+			Response:        []*forwardsTableRow{},
+			QueryParameters: []string{"from", "to", "network", "chain"},
+		},
+	}
+}
@@ -27,5 +27,8 @@ type ChannelEventFromGraph struct {
 	MaxHtlcMsat      uint64    `json:"maxHtlcMsat" db:"max_htlc_msat"`
 	FeeBaseMsat      int64     `json:"feeBaseMsat" db:"fee_base_msat"`
 	FeeRateMilliMsat int64     `json:"feeRateMilliMsat" db:"fee_rate_mill_msat"`
-	NodeId           int       `json:"nodeId" db:"node_id"`
+	// Inbound fees are negative for a discount.
+	InboundFeeBaseMsat      int64 `json:"inboundFeeBaseMsat" db:"inbound_fee_base_msat"`
+	InboundFeeRateMilliMsat int64 `json:"inboundFeeRateMilliMsat" db:"inbound_fee_rate_milli_msat"`
+	NodeId                  int   `json:"nodeId" db:"node_id"`
 }
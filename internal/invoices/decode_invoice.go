@@ -15,7 +15,6 @@ import (
 
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -114,22 +113,11 @@ func constructDecodedInvoice(decodedInvoice *lnrpc.PayReq) *DecodedInvoice {
 // decodeInvoice Decode a lightning invoice
 func decodeInvoice(db *sqlx.DB, invoice string, nodeId int) (*DecodedInvoice, error) {
 	//log.Info().Msgf("Decoding invoice: %s", invoice)
-	// Get lnd client
-	connectionDetails, err := settings.GetConnectionDetailsById(db, nodeId)
+	client, err := settings.GetNodeClient(db, nodeId)
 	if err != nil {
-		return nil, errors.Wrap(err, "Getting node connection details from the db")
+		return nil, errors.Wrap(err, "Connecting to node")
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "Connecting to LND")
-	}
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
 	// Decode invoice
 	ctx := context.Background()
 	// TODO: Handle different error types like incorrect checksum etc to explain why the decode failed.
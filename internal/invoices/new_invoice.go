@@ -9,11 +9,10 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 type newInvoiceRequest struct {
-	NodeId          int     `json:"nodeId"`
+	NodeId          int     `json:"nodeId" binding:"required"`
 	Memo            *string `json:"memo"`
 	RPreImage       *string `json:"rPreImage"`
 	ValueMsat       *int64  `json:"valueMsat"`
@@ -36,23 +35,11 @@ func newInvoice(db *sqlx.DB, req newInvoiceRequest) (r newInvoiceResponse, err e
 		return r, err
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return r, errors.Wrap(err, "Getting node connection details from the db")
+		return r, errors.Wrap(err, "Connecting to node")
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return r, errors.Wrap(err, "Connecting to LND")
-	}
-
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
-
 	ctx := context.Background()
 
 	resp, err := client.AddInvoice(ctx, newInvoiceReq)
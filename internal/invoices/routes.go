@@ -3,8 +3,10 @@ package invoices
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-)
 
+	ah "github.com/lncapital/torq/pkg/api_helpers"
+	"github.com/lncapital/torq/pkg/openapi"
+)
 
 func RegisterInvoicesRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("", func(c *gin.Context) { getInvoicesHandler(c, db) })
@@ -12,3 +14,27 @@ func RegisterInvoicesRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET(":identifier", func(c *gin.Context) { getInvoiceHandler(c, db) })
 	r.POST("newinvoice", func(c *gin.Context) { newInvoiceHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List invoices",
+			Response:        ah.ApiResponse{Data: []*Invoice{}},
+			QueryParameters: []string{"filter", "order", "limit", "offset"},
+		},
+		"GET decode/": {
+			Summary:         "Decode a payment request",
+			Response:        DecodedInvoice{},
+			QueryParameters: []string{"invoice", "nodeId"},
+		},
+		"GET :identifier": {
+			Summary:  "Get the details of an invoice by payment hash",
+			Response: InvoiceDetails{},
+		},
+		"POST newinvoice": {
+			Summary:     "Create an invoice",
+			RequestBody: newInvoiceRequest{},
+			Response:    newInvoiceResponse{},
+		},
+	}
+}
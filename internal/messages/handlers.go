@@ -4,20 +4,21 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 	"net/http"
 )
 
 type SignMessageRequest struct {
-	NodeId     int    `json:"nodeId"`
-	Message    string `json:"message"`
+	NodeId     int    `json:"nodeId" binding:"required"`
+	Message    string `json:"message" binding:"required"`
 	SingleHash *bool  `json:"singleHash"`
 }
 
 type VerifyMessageRequest struct {
-	NodeId    int    `json:"nodeId"`
-	Message   string `json:"message"`
-	Signature string `json:"signature"`
+	NodeId    int    `json:"nodeId" binding:"required"`
+	Message   string `json:"message" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
 }
 
 type SignMessageResponse struct {
@@ -67,3 +68,18 @@ func RegisterMessagesRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("sign", func(c *gin.Context) { signMessageHandler(c, db) })
 	r.GET("verify", func(c *gin.Context) { verifyMessageHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET sign": {
+			Summary:     "Sign a message with the key of the node",
+			RequestBody: SignMessageRequest{},
+			Response:    SignMessageResponse{},
+		},
+		"GET verify": {
+			Summary:     "Verify the signature of a message",
+			RequestBody: VerifyMessageRequest{},
+			Response:    VerifyMessageResponse{},
+		},
+	}
+}
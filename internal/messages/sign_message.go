@@ -8,29 +8,17 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func signMessage(db *sqlx.DB, req SignMessageRequest) (r SignMessageResponse, err error) {
 	if req.NodeId == 0 {
 		return SignMessageResponse{}, errors.New("Node Id missing")
 	}
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return SignMessageResponse{}, errors.Wrap(err, "Getting node connection details from the db")
+		return SignMessageResponse{}, errors.Wrap(err, "Connecting to node")
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return SignMessageResponse{}, errors.Wrap(err, "Connecting to LND")
-	}
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
-
 	ctx := context.Background()
 
 	signMsgReq := lnrpc.SignMessageRequest{
@@ -8,7 +8,6 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func verifyMessage(db *sqlx.DB, req VerifyMessageRequest) (r VerifyMessageResponse, err error) {
@@ -16,22 +15,11 @@ func verifyMessage(db *sqlx.DB, req VerifyMessageRequest) (r VerifyMessageRespon
 		return VerifyMessageResponse{}, errors.Newf("Node Id missing")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return VerifyMessageResponse{}, errors.Wrap(err, "Getting node connection details from the db")
+		return VerifyMessageResponse{}, errors.Wrap(err, "Connecting to node")
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return VerifyMessageResponse{}, errors.Wrap(err, "Connecting to LND")
-	}
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
-
 	verifyMsgReq := lnrpc.VerifyMessageRequest{
 		Msg:       []byte(req.Message),
 		Signature: req.Signature,
@@ -12,6 +12,7 @@ import (
 	"github.com/lncapital/torq/internal/corridors"
 	"github.com/lncapital/torq/internal/database"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func GetNodeByPublicKey(db *sqlx.DB, publicKey string) (Node, error) {
@@ -125,6 +126,7 @@ func removeNode(db *sqlx.DB, nodeId int) (int64, error) {
 	if err != nil {
 		return 0, errors.Wrap(err, database.SqlExecutionError)
 	}
+	lnd_connect.CloseConnection(nodeId)
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
@@ -8,6 +8,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -33,6 +35,7 @@ type NodeInformation struct {
 func RegisterNodeRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("", func(c *gin.Context) { getNodesHandler(c, db) })
 	r.DELETE(":nodeId", func(c *gin.Context) { removeNodeHandler(c, db) })
+	r.GET(":nodeId/subscriptions", getSubscriptionsHandler)
 }
 
 func getNodesHandler(c *gin.Context, db *sqlx.DB) {
@@ -51,9 +54,29 @@ func removeNodeHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 	count, err := removeNode(db, nodeId)
+	audit.Record(db, c, audit.RemoveNode, map[string]interface{}{"nodeId": nodeId},
+		map[string]interface{}{"count": count}, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing node for nodeId: %v", nodeId))
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v node(s).", count)})
 }
+
+func getSubscriptionsHandler(c *gin.Context) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	c.JSON(http.StatusOK, GetStreamStatuses(nodeId))
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ":           {Summary: "List the nodes with their latest information", Response: []NodeInformation{}},
+		"DELETE :nodeId": {Summary: "Remove a node"},
+		"GET :nodeId/subscriptions": {Summary: "Get the state of the subscription streams of a node",
+			Response: []StreamStatus{}},
+	}
+}
@@ -0,0 +1,88 @@
+package nodes
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type StreamState string
+
+const (
+	StreamRunning = StreamState("running")
+	// StreamBackoff means the stream failed and waits before it's restarted.
+	StreamBackoff = StreamState("backoff")
+	StreamStopped = StreamState("stopped")
+)
+
+// StreamStatus is the state of a single subscription stream of a node (i.e. htlcEvents).
+type StreamStatus struct {
+	NodeId      int         `json:"nodeId"`
+	Stream      string      `json:"stream"`
+	State       StreamState `json:"state"`
+	Restarts    int         `json:"restarts"`
+	StartedOn   *time.Time  `json:"startedOn"`
+	LastEventOn *time.Time  `json:"lastEventOn"`
+	LastError   *string     `json:"lastError"`
+	LastErrorOn *time.Time  `json:"lastErrorOn"`
+}
+
+//nolint:gochecknoglobals
+var (
+	streamStatuses   = make(map[int]map[string]*StreamStatus)
+	streamStatusesMu sync.RWMutex
+)
+
+func getOrAddStreamStatus(nodeId int, stream string) *StreamStatus {
+	if streamStatuses[nodeId] == nil {
+		streamStatuses[nodeId] = make(map[string]*StreamStatus)
+	}
+	status, exists := streamStatuses[nodeId][stream]
+	if !exists {
+		status = &StreamStatus{NodeId: nodeId, Stream: stream, State: StreamStopped}
+		streamStatuses[nodeId][stream] = status
+	}
+	return status
+}
+
+// SetStreamState updates the state of a stream and returns the new status.
+// The last error is kept when err is nil so the reason of the latest restart stays visible.
+func SetStreamState(nodeId int, stream string, state StreamState, restarts int, err error) StreamStatus {
+	streamStatusesMu.Lock()
+	defer streamStatusesMu.Unlock()
+	status := getOrAddStreamStatus(nodeId, stream)
+	now := time.Now().UTC()
+	if state == StreamRunning && status.State != StreamRunning {
+		status.StartedOn = &now
+	}
+	status.State = state
+	status.Restarts = restarts
+	if err != nil {
+		message := err.Error()
+		status.LastError = &message
+		status.LastErrorOn = &now
+	}
+	return *status
+}
+
+// RecordStreamEvent sets the time of the last event received on a stream.
+func RecordStreamEvent(nodeId int, stream string) {
+	streamStatusesMu.Lock()
+	defer streamStatusesMu.Unlock()
+	now := time.Now().UTC()
+	getOrAddStreamStatus(nodeId, stream).LastEventOn = &now
+}
+
+// GetStreamStatuses returns the status of every stream of a node that was started since Torq started.
+func GetStreamStatuses(nodeId int) []StreamStatus {
+	streamStatusesMu.RLock()
+	defer streamStatusesMu.RUnlock()
+	statuses := make([]StreamStatus, 0, len(streamStatuses[nodeId]))
+	for _, status := range streamStatuses[nodeId] {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Stream < statuses[j].Stream
+	})
+	return statuses
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lncapital/torq/internal/approvals"
+	"github.com/lncapital/torq/internal/audit"
 	qp "github.com/lncapital/torq/internal/query_parser"
 	ah "github.com/lncapital/torq/pkg/api_helpers"
 	"github.com/lncapital/torq/pkg/server_errors"
@@ -22,6 +24,8 @@ func getOnChainTxsHandler(c *gin.Context, db *sqlx.DB) {
 	if filterParam != "" {
 		filter, err = qp.ParseFilterParam(filterParam, []string{
 			"date",
+			"block_height",
+			"num_confirmations",
 			"dest_addresses",
 			"dest_addresses_count",
 			"amount",
@@ -44,6 +48,8 @@ func getOnChainTxsHandler(c *gin.Context, db *sqlx.DB) {
 			sortParam,
 			[]string{
 				"date",
+				"block_height",
+				"num_confirmations",
 				"dest_addresses",
 				"dest_addresses_count",
 				"amount",
@@ -108,9 +114,30 @@ func sendCoinsHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
+	requiresApproval, amountMsat, err := approvals.RequiresApproval(db, approvals.PayOnChain, requestBody.NodeId,
+		func() (int64, error) {
+			amountSat, err := GetPayOnChainAmountSat(db, requestBody)
+			return amountSat * 1000, err
+		})
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Checking approval threshold")
+		return
+	}
+	if requiresApproval {
+		approval, err := approvals.AddApproval(db, c, approvals.PayOnChain, requestBody.NodeId, amountMsat, requestBody, "")
+		audit.Record(db, c, audit.RequestApproval, requestBody, approval, err)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, "Requesting approval")
+			return
+		}
+		c.JSON(http.StatusAccepted, approval)
+		return
+	}
+
 	resp, err := PayOnChain(db, requestBody)
+	audit.Record(db, c, audit.PayOnChain, requestBody, PayOnChainResponse{TxId: resp}, err)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "Sending on-chain payment")
+		server_errors.SendServerErrorOrWrap(c, err, "Sending on-chain payment")
 		return
 	}
 
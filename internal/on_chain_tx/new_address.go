@@ -7,10 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 const (
@@ -34,10 +33,6 @@ type NewAddressResponse struct {
 	Address string `json:"address"`
 }
 
-type rpcClientNewAddress interface {
-	NextAddr(ctx context.Context, in *walletrpc.AddrRequest, opts ...grpc.CallOption) (*walletrpc.AddrResponse, error)
-}
-
 func NewAddress(
 	eventChannel chan interface{},
 	db *sqlx.DB,
@@ -50,19 +45,10 @@ func NewAddress(
 		return errors.New("Node id is missing")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, newAddressRequest.NodeId)
-	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
-	}
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	client, err := settings.GetNodeClient(db, newAddressRequest.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return errors.Wrap(err, "Connecting to node")
 	}
-	defer conn.Close()
-	client := walletrpc.NewWalletKitClient(conn)
 	return newAddress(client, newAddressRequest, eventChannel, reqId)
 }
 
@@ -84,7 +70,7 @@ func createLndAddressRequest(newAddressRequest NewAddressRequest) (r *walletrpc.
 	return lndAddressRequest, nil
 }
 
-func newAddress(client rpcClientNewAddress, newAddressRequest NewAddressRequest, eventChannel chan interface{}, reqId string) (err error) {
+func newAddress(client node_client.NodeClient, newAddressRequest NewAddressRequest, eventChannel chan interface{}, reqId string) (err error) {
 	// Create and validate payment request details
 	lndAddressRequest, err := createLndAddressRequest(newAddressRequest)
 	if err != nil {
@@ -12,6 +12,8 @@ import (
 type Transaction struct {
 	Date               time.Time      `json:"date" db:"date"`
 	TxHash             string         `json:"txHash" db:"tx_hash"`
+	BlockHeight        int64          `json:"blockHeight" db:"block_height"`
+	NumConfirmations   int64          `json:"numConfirmations" db:"num_confirmations"`
 	DestAddresses      pq.StringArray `json:"destAddresses" db:"dest_addresses"`
 	DestAddressesCount string         `json:"destAddressesCount" db:"dest_addresses_count"`
 	AmountMsat         int64          `json:"amount" db:"amount"`
@@ -32,7 +34,8 @@ func getOnChainTxs(db *sqlx.DB, filter sq.Sqlizer, order []string, limit uint64,
 			   timestamp as date,
 			   tx_hash,
 			   --block_hash,
-			   --block_height,
+			   coalesce(block_height, 0) as block_height,
+			   coalesce(num_confirmations, 0) as num_confirmations,
 			   --raw_tx_hex,
 			   dest_addresses,
 			   array_length(dest_addresses, 1) as dest_addresses_count,
@@ -70,6 +73,8 @@ func getOnChainTxs(db *sqlx.DB, filter sq.Sqlizer, order []string, limit uint64,
 		err = rows.Scan(
 			&tx.Date,
 			&tx.TxHash,
+			&tx.BlockHeight,
+			&tx.NumConfirmations,
 			&tx.DestAddresses,
 			&tx.DestAddressesCount,
 			&tx.AmountMsat,
@@ -93,7 +98,8 @@ func getOnChainTxs(db *sqlx.DB, filter sq.Sqlizer, order []string, limit uint64,
 			   timestamp as date,
 			   tx_hash,
 			   --block_hash,
-			   --block_height,
+			   coalesce(block_height, 0) as block_height,
+			   coalesce(num_confirmations, 0) as num_confirmations,
 			   --raw_tx_hex,
 			   dest_addresses,
 			   array_length(dest_addresses, 1) as dest_addresses_count,
@@ -3,10 +3,27 @@ package on_chain_tx
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-)
 
+	ah "github.com/lncapital/torq/pkg/api_helpers"
+	"github.com/lncapital/torq/pkg/openapi"
+)
 
-func RegisterOnChainTxsRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+func RegisterOnChainTxsRoutes(r *gin.RouterGroup, db *sqlx.DB, totpRequired gin.HandlerFunc) {
 	r.GET("", func(c *gin.Context) { getOnChainTxsHandler(c, db) })
-	r.POST("sendcoins", func(c *gin.Context) { sendCoinsHandler(c, db) })
+	r.POST("sendcoins", totpRequired, func(c *gin.Context) { sendCoinsHandler(c, db) })
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List on-chain transactions",
+			Response:        ah.ApiResponse{Data: []*Transaction{}},
+			QueryParameters: []string{"filter", "order", "limit", "offset"},
+		},
+		"POST sendcoins": {
+			Summary:     "Send an on-chain payment, large amounts are queued for approval",
+			RequestBody: PayOnChainRequest{},
+			Response:    PayOnChainResponse{},
+		},
+	}
 }
@@ -9,12 +9,13 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/internal/spending_policies"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 type PayOnChainRequest struct {
-	NodeId           int     `json:"nodeId"`
-	Address          string  `json:"address"`
+	NodeId           int     `json:"nodeId" binding:"required"`
+	Address          string  `json:"address" binding:"required"`
 	AmountSat        int64   `json:"amountSat"`
 	TargetConf       *int32  `json:"targetConf"`
 	SatPerVbyte      *uint64 `json:"satPerVbyte"`
@@ -35,23 +36,21 @@ func PayOnChain(db *sqlx.DB, req PayOnChainRequest) (r string, err error) {
 		return "", errors.Wrap(err, "Process send request")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	client, err := settings.GetNodeClient(db, req.NodeId)
 	if err != nil {
-		return "", errors.New("Error getting node connection details from the db")
+		return "", errors.Wrap(err, "Connecting to node")
 	}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	ctx := context.Background()
+
+	amountSat, err := getPayOnChainAmountSat(ctx, client, req)
 	if err != nil {
-		return "", errors.Wrap(err, "Connecting to LND")
+		return "", err
+	}
+	err = spending_policies.CheckOnChain(db, req.NodeId, amountSat)
+	if err != nil {
+		return "", err
 	}
-
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
-	ctx := context.Background()
 
 	resp, err := client.SendCoins(ctx, sendCoinsReq)
 	if err != nil {
@@ -62,6 +61,30 @@ func PayOnChain(db *sqlx.DB, req PayOnChainRequest) (r string, err error) {
 
 }
 
+// GetPayOnChainAmountSat returns the amount an on-chain send would spend.
+func GetPayOnChainAmountSat(db *sqlx.DB, req PayOnChainRequest) (int64, error) {
+	if req.SendAll == nil || !*req.SendAll {
+		return req.AmountSat, nil
+	}
+	client, err := settings.GetNodeClient(db, req.NodeId)
+	if err != nil {
+		return 0, errors.Wrap(err, "Connecting to node")
+	}
+	return getPayOnChainAmountSat(context.Background(), client, req)
+}
+
+// getPayOnChainAmountSat returns the requested amount or the confirmed wallet balance when sending all.
+func getPayOnChainAmountSat(ctx context.Context, client node_client.NodeClient, req PayOnChainRequest) (int64, error) {
+	if req.SendAll == nil || !*req.SendAll {
+		return req.AmountSat, nil
+	}
+	walletBalance, err := client.WalletBalance(ctx, &lnrpc.WalletBalanceRequest{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Getting wallet balance")
+	}
+	return walletBalance.ConfirmedBalance, nil
+}
+
 func processSendRequest(req PayOnChainRequest) (r *lnrpc.SendCoinsRequest, err error) {
 	r = &lnrpc.SendCoinsRequest{}
 
@@ -13,19 +13,13 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
 	"github.com/rs/zerolog/log"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/internal/spending_policies"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-type rrpcClientSendPayment interface {
-	SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
-		opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client,
-		error)
-}
-
 type NewPaymentRequest struct {
 	NodeId           int     `json:"nodeId"`
 	Invoice          *string `json:"invoice"`
@@ -74,6 +68,7 @@ type attempt struct {
 type NewPaymentResponse struct {
 	ReqId          string    `json:"reqId"`
 	Type           string    `json:"type"`
+	NodeId         int       `json:"nodeId"`
 	Status         string    `json:"status"`
 	FailureReason  string    `json:"failureReason"`
 	Hash           string    `json:"hash"`
@@ -99,28 +94,63 @@ func SendNewPayment(
 	c *gin.Context,
 	npReq NewPaymentRequest,
 	reqId string,
-) (err error) {
+) (r *NewPaymentResponse, err error) {
 
 	if npReq.NodeId == 0 {
-		return errors.New("Node id is missing")
+		return nil, errors.New("Node id is missing")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, npReq.NodeId)
+	client, err := settings.GetNodeClient(db, npReq.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return nil, errors.Wrap(err, "Connecting to node")
 	}
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	err = checkPayment(db, client, npReq)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return nil, err
 	}
-	defer conn.Close()
-	client := routerrpc.NewRouterClient(conn)
+
 	return sendPayment(client, npReq, eventChannel, reqId)
 }
 
+// checkPayment checks the amount and fee limit of the payment against the spending policies of the node.
+func checkPayment(db *sqlx.DB, client node_client.NodeClient, npReq NewPaymentRequest) error {
+	amountMsat, err := getPaymentAmountMsat(client, npReq)
+	if err != nil {
+		return errors.Wrap(err, "Getting payment amount")
+	}
+	var feeLimitMsat int64
+	if npReq.FeeLimitMsat != nil {
+		feeLimitMsat = *npReq.FeeLimitMsat
+	}
+	return spending_policies.CheckPayment(db, npReq.NodeId, amountMsat, feeLimitMsat)
+}
+
+// GetPaymentAmountMsat returns the amount a new payment request would send.
+func GetPaymentAmountMsat(db *sqlx.DB, npReq NewPaymentRequest) (int64, error) {
+	client, err := settings.GetNodeClient(db, npReq.NodeId)
+	if err != nil {
+		return 0, errors.Wrap(err, "Connecting to node")
+	}
+	return getPaymentAmountMsat(client, npReq)
+}
+
+// getPaymentAmountMsat returns the amount of the invoice or when the invoice has no amount the requested amount.
+func getPaymentAmountMsat(client node_client.NodeClient, npReq NewPaymentRequest) (int64, error) {
+	if npReq.Invoice != nil && *npReq.Invoice != "" {
+		payReq, err := client.DecodePayReq(context.Background(), &lnrpc.PayReqString{PayReq: *npReq.Invoice})
+		if err != nil {
+			return 0, errors.Wrap(err, "Decoding invoice")
+		}
+		if payReq.NumMsat != 0 {
+			return payReq.NumMsat, nil
+		}
+	}
+	if npReq.AmtMSat != nil {
+		return *npReq.AmtMSat, nil
+	}
+	return 0, nil
+}
+
 func newSendPaymentRequest(npReq NewPaymentRequest) (r *routerrpc.SendPaymentRequest, err error) {
 	newPayReq := &routerrpc.SendPaymentRequest{
 		TimeoutSeconds: npReq.TimeOutSecs,
@@ -156,24 +186,26 @@ func newSendPaymentRequest(npReq NewPaymentRequest) (r *routerrpc.SendPaymentReq
 	return newPayReq, nil
 }
 
-func sendPayment(client rrpcClientSendPayment, npReq NewPaymentRequest, eventChannel chan interface{}, reqId string) (err error) {
+// sendPayment sends the payment updates to the client and returns the last update, it's nil when the node sent none.
+func sendPayment(client node_client.NodeClient, npReq NewPaymentRequest, eventChannel chan interface{},
+	reqId string) (last *NewPaymentResponse, err error) {
 
 	// Create and validate payment request details
 	newPayReq, err := newSendPaymentRequest(npReq)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	ctx := context.Background()
 	req, err := client.SendPaymentV2(ctx, newPayReq)
 	if err != nil {
-		return errors.Wrap(err, "Sending payment")
+		return nil, errors.Wrap(err, "Sending payment")
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return last, nil
 		default:
 		}
 
@@ -182,27 +214,30 @@ func sendPayment(client rrpcClientSendPayment, npReq NewPaymentRequest, eventCha
 		case err == nil:
 			break
 		case err == io.EOF:
-			return nil
+			return last, nil
 		case err != nil && strings.Contains(err.Error(), "AlreadyExists"):
-			return errors.New("ALREADY_PAID")
+			return last, errors.New("ALREADY_PAID")
 		case err != nil && strings.Contains(err.Error(), "UnknownPaymentHash"):
-			return errors.New("INVALID_HASH")
+			return last, errors.New("INVALID_HASH")
 		case err != nil && strings.Contains(err.Error(), "InvalidPaymentRequest"):
-			return errors.New("INVALID_PAYMENT_REQUEST")
+			return last, errors.New("INVALID_PAYMENT_REQUEST")
 		case err != nil && strings.Contains(err.Error(), "checksum failed"):
-			return errors.New("CHECKSUM_FAILED")
+			return last, errors.New("CHECKSUM_FAILED")
 		case err != nil && strings.Contains(err.Error(), "amount must be specified when paying a zero amount invoice"):
-			return errors.New("AMOUNT_REQUIRED")
+			return last, errors.New("AMOUNT_REQUIRED")
 		case err != nil && strings.Contains(err.Error(), "amount must not be specified when paying a non-zero  amount invoice"):
-			return errors.New("AMOUNT_NOT_ALLOWED")
+			return last, errors.New("AMOUNT_NOT_ALLOWED")
 		default:
 			log.Error().Msgf("Unknown payment error %v", err)
-			return errors.New("UNKNOWN_ERROR")
+			return last, errors.New("UNKNOWN_ERROR")
 		}
 
+		response := processResponse(resp, reqId)
+		response.NodeId = npReq.NodeId
+		last = &response
 		if eventChannel != nil {
 			// Write the payment status to the client
-			eventChannel <- processResponse(resp, reqId)
+			eventChannel <- response
 		}
 	}
 }
@@ -1,11 +1,14 @@
 package payments
 
 import (
+	"context"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 func Test_processResponse(t *testing.T) {
@@ -252,3 +255,19 @@ func Test_newSendPaymentRequest(t *testing.T) {
 		})
 	}
 }
+
+func Test_sendPaymentReturnsLastUpdate(t *testing.T) {
+	alice := node_client.NewFakeNode("alice")
+	bob := node_client.NewFakeNode("bob")
+	alice.AddChannel(bob.PublicKey(), 1000000, 600000)
+	bob.AddChannel(alice.PublicKey(), 1000000, 400000)
+	invoice, err := bob.AddInvoice(context.Background(), &lnrpc.Invoice{Value: 1000})
+	if err != nil {
+		t.Fatalf("AddInvoice() error: %v", err)
+	}
+
+	last, err := sendPayment(alice, NewPaymentRequest{NodeId: 1, Invoice: &invoice.PaymentRequest}, nil, "reqId")
+	if err != nil || last == nil || last.Status != lnrpc.Payment_SUCCEEDED.String() || last.ReqId != "reqId" {
+		t.Errorf("sendPayment()\nGot:\n%v %v\nWant:\n%v\n", last, err, "the SUCCEEDED update")
+	}
+}
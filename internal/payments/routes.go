@@ -3,10 +3,27 @@ package payments
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-)
 
+	ah "github.com/lncapital/torq/pkg/api_helpers"
+	"github.com/lncapital/torq/pkg/openapi"
+)
 
 func RegisterPaymentsRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("", func(c *gin.Context) { getPaymentsHandler(c, db) })
 	r.GET(":identifier", func(c *gin.Context) { getPaymentHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List payments",
+			Response:        ah.ApiResponse{Data: []*Payment{}},
+			QueryParameters: []string{"filter", "order", "limit", "offset", "network", "chain"},
+		},
+		"GET :identifier": {
+			Summary:         "Get the details of a payment by payment hash",
+			Response:        PaymentDetails{},
+			QueryParameters: []string{"network", "chain"},
+		},
+	}
+}
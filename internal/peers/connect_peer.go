@@ -5,9 +5,11 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"strings"
+
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-func ConnectPeer(client lnrpc.LightningClient, ctx context.Context, req ConnectPeerRequest) (r string, err error) {
+func ConnectPeer(client node_client.NodeClient, ctx context.Context, req ConnectPeerRequest) (r string, err error) {
 	connPeerReq, err := processRequest(req)
 
 	if err != nil {
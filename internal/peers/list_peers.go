@@ -5,6 +5,8 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"strconv"
+
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 type timeStampedError struct {
@@ -40,7 +42,7 @@ type peer struct {
 	LastPingPayload []byte             `json:"lastPingPayload"`
 }
 
-func ListPeers(client lnrpc.LightningClient, ctx context.Context, latestErr string) (r []peer, err error) {
+func ListPeers(client node_client.NodeClient, ctx context.Context, latestErr string) (r []peer, err error) {
 
 	listPeerReq := lnrpc.ListPeersRequest{}
 
@@ -8,22 +8,21 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
-	"google.golang.org/grpc"
 
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
 type LndAddress struct {
-	PubKey string `json:"pubKey"`
-	Host   string `json:"host"`
+	PubKey string `json:"pubKey" binding:"required"`
+	Host   string `json:"host" binding:"required"`
 }
 
 type ConnectPeerRequest struct {
-	NodeId     int        `json:"nodeId"`
+	NodeId     int        `json:"nodeId" binding:"required"`
 	LndAddress LndAddress `json:"lndAddress"`
 	Perm       *bool      `json:"perm"`
 	TimeOut    *uint64    `json:"timeOut"`
@@ -49,19 +48,18 @@ func connectPeerHandler(c *gin.Context, db *sqlx.DB) {
 
 	log.Info().Msgf("NODE ID: %v", requestBody.NodeId)
 
-	conn, err := connectLND(db, requestBody.NodeId)
+	client, err := settings.GetNodeClient(db, requestBody.NodeId)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "can't connect to LND")
+		server_errors.WrapLogAndSendServerError(c, err, "can't connect to node")
 		return
 	}
-	defer conn.Close()
 
-	client := lnrpc.NewLightningClient(conn)
 	ctx := context.Background()
 
 	resp, err := ConnectPeer(client, ctx, requestBody)
+	audit.Record(db, c, audit.ConnectPeer, requestBody, resp, err)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "LND")
+		server_errors.WrapLogAndSendServerError(c, err, "Node")
 		return
 	}
 
@@ -80,40 +78,34 @@ func listPeersHandler(c *gin.Context, db *sqlx.DB) {
 	///api/peers?nodeId=1&latestErr=false
 	latestErr := c.Query("latestErr")
 
-	conn, err := connectLND(db, nodeId)
+	client, err := settings.GetNodeClient(db, nodeId)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "Connecting to LND")
+		server_errors.WrapLogAndSendServerError(c, err, "Connecting to node")
 		return
 	}
-	defer conn.Close()
 
-	client := lnrpc.NewLightningClient(conn)
 	ctx := context.Background()
 
 	resp, err := ListPeers(client, ctx, latestErr)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "LND")
+		server_errors.WrapLogAndSendServerError(c, err, "Node")
 		return
 	}
 
 	c.JSON(http.StatusOK, resp)
 }
 
-func connectLND(db *sqlx.DB, nodeId int) (conn *grpc.ClientConn, err error) {
-	connectionDetails, err := settings.GetConnectionDetailsById(db, nodeId)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "Getting node connection details from the db")
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the peers of a node",
+			Response:        []peer{},
+			QueryParameters: []string{"nodeId", "latestErr"},
+		},
+		"POST ": {
+			Summary:     "Connect to a peer",
+			RequestBody: ConnectPeerRequest{},
+			Response:    "",
+		},
 	}
-
-	conn, err = lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "Connecting to LND")
-	}
-
-	return conn, nil
-
 }
@@ -0,0 +1,237 @@
+package rebalances
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func addRebalance(db *sqlx.DB, rebalance Rebalance) (Rebalance, error) {
+	rebalance.CreatedOn = time.Now().UTC()
+	err := db.QueryRowx(`
+		INSERT INTO rebalance (node_id, source_channel_ids, source_tag_ids, target_channel_ids, target_tag_ids,
+			amount_msat, max_fee_ppm, max_attempts, status, rebalanced_msat, fee_paid_msat, attempts, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 0, 0, 0, $10)
+		RETURNING rebalance_id;`,
+		rebalance.NodeId, rebalance.SourceChannelIds, rebalance.SourceTagIds, rebalance.TargetChannelIds,
+		rebalance.TargetTagIds, rebalance.AmountMsat, rebalance.MaxFeePpm, rebalance.MaxAttempts, rebalance.Status,
+		rebalance.CreatedOn).Scan(&rebalance.RebalanceId)
+	if err != nil {
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rebalance, nil
+}
+
+// GetRebalance returns the rebalance with its attempts, RebalanceId is 0 when the rebalance doesn't exist.
+func GetRebalance(db *sqlx.DB, rebalanceId int) (Rebalance, error) {
+	var rebalance Rebalance
+	err := db.Get(&rebalance, `SELECT * FROM rebalance WHERE rebalance_id=$1;`, rebalanceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rebalance{}, nil
+		}
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rebalance.AttemptList = []Attempt{}
+	err = db.Select(&rebalance.AttemptList, `
+		SELECT * FROM rebalance_attempt
+		WHERE rebalance_id=$1
+		ORDER BY rebalance_attempt_id;`, rebalanceId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rebalance, nil
+}
+
+// getRebalances returns the most recent rebalances without their attempts, a nodeId of 0 returns them for all nodes.
+func getRebalances(db *sqlx.DB, nodeId int, limit int) ([]Rebalance, error) {
+	rebalances := []Rebalance{}
+	err := db.Select(&rebalances, `
+		SELECT * FROM rebalance
+		WHERE $1=0 OR node_id=$1
+		ORDER BY rebalance_id DESC
+		LIMIT $2;`, nodeId, limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rebalances, nil
+}
+
+// claimRebalance marks the oldest pending rebalance as running, RebalanceId is 0 when there was nothing to claim.
+func claimRebalance(db *sqlx.DB) (Rebalance, error) {
+	var rebalance Rebalance
+	err := db.Get(&rebalance, `
+		UPDATE rebalance SET status=$1, started_on=$2
+		WHERE rebalance_id = (
+			SELECT rebalance_id FROM rebalance
+			WHERE status=$3
+			ORDER BY rebalance_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING *;`,
+		Running, time.Now().UTC(), Pending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rebalance{}, nil
+		}
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rebalance, nil
+}
+
+// cancelPendingRebalance returns the cancelled rebalance, RebalanceId is 0 when it wasn't pending.
+func cancelPendingRebalance(db *sqlx.DB, rebalanceId int) (Rebalance, error) {
+	var rebalance Rebalance
+	err := db.Get(&rebalance, `
+		UPDATE rebalance SET status=$1, finished_on=$2
+		WHERE rebalance_id=$3 AND status=$4
+		RETURNING *;`,
+		Cancelled, time.Now().UTC(), rebalanceId, Pending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rebalance{}, nil
+		}
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rebalance, nil
+}
+
+func failInterruptedRebalances(db *sqlx.DB) error {
+	_, err := db.Exec(`
+		UPDATE rebalance SET status=$1, error='Interrupted', finished_on=$2 WHERE status=$3;`,
+		Failed, time.Now().UTC(), Running)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func finishRebalance(db *sqlx.DB, rebalance Rebalance) error {
+	_, err := db.Exec(`
+		UPDATE rebalance SET status=$1, error=$2, rebalanced_msat=$3, fee_paid_msat=$4, attempts=$5, finished_on=$6
+		WHERE rebalance_id=$7;`,
+		rebalance.Status, rebalance.Error, rebalance.RebalancedMsat, rebalance.FeePaidMsat, rebalance.Attempts,
+		time.Now().UTC(), rebalance.RebalanceId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// addAttempt stores the attempt together with the progress of its rebalance.
+func addAttempt(db *sqlx.DB, rebalance Rebalance, attempt Attempt) (Attempt, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return Attempt{}, errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() { _ = tx.Rollback() }()
+	err = tx.QueryRowx(`
+		INSERT INTO rebalance_attempt (rebalance_id, outgoing_channel_id, incoming_channel_id, amount_msat,
+			fee_limit_msat, fee_msat, payment_hash, route, status, failure_reason, created_on, finished_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING rebalance_attempt_id;`,
+		attempt.RebalanceId, attempt.OutgoingChannelId, attempt.IncomingChannelId, attempt.AmountMsat,
+		attempt.FeeLimitMsat, attempt.FeeMsat, attempt.PaymentHash, attempt.Route, attempt.Status,
+		attempt.FailureReason, attempt.CreatedOn, attempt.FinishedOn).Scan(&attempt.RebalanceAttemptId)
+	if err != nil {
+		return Attempt{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = tx.Exec(`
+		UPDATE rebalance SET rebalanced_msat=$1, fee_paid_msat=$2, attempts=$3 WHERE rebalance_id=$4;`,
+		rebalance.RebalancedMsat, rebalance.FeePaidMsat, rebalance.Attempts, rebalance.RebalanceId)
+	if err != nil {
+		return Attempt{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	if err = tx.Commit(); err != nil {
+		return Attempt{}, errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return attempt, nil
+}
+
+// resolveChannels returns the channels of the node and the channels of the node with one of the tags.
+func resolveChannels(db *sqlx.DB, nodeId int, channelIds pq.Int64Array, tagIds pq.Int64Array) (map[int]bool, error) {
+	resolved := make(map[int]bool)
+	for _, channelId := range channelIds {
+		resolved[int(channelId)] = true
+	}
+	if len(tagIds) == 0 {
+		return resolved, nil
+	}
+	var taggedChannelIds []int
+	err := db.Select(&taggedChannelIds, `
+		SELECT DISTINCT channel_id FROM channel_tag WHERE from_node_id=$1 AND tag_id=ANY($2);`, nodeId, tagIds)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	for _, channelId := range taggedChannelIds {
+		resolved[channelId] = true
+	}
+	return resolved, nil
+}
+
+// GetBudget returns the budget of the node with what's spent in the current period, NodeId is 0 when the node has
+// no budget.
+func GetBudget(db *sqlx.DB, nodeId int, now time.Time) (Budget, error) {
+	var budget Budget
+	err := db.Get(&budget, `SELECT * FROM rebalance_budget WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Budget{}, nil
+		}
+		return Budget{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	err = db.Get(&budget.SpentMsat, `
+		SELECT COALESCE(SUM(a.fee_msat), 0)
+		FROM rebalance_attempt a
+		JOIN rebalance r ON r.rebalance_id = a.rebalance_id
+		WHERE r.node_id=$1 AND a.status=$2 AND a.finished_on>=$3;`,
+		nodeId, AttemptSucceeded, now.Add(-time.Duration(budget.PeriodSeconds)*time.Second))
+	if err != nil {
+		return Budget{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return budget, nil
+}
+
+// getRemainingBudgetMsat returns what's left of the budget of the node, limited is false when it has no budget.
+func getRemainingBudgetMsat(db *sqlx.DB, nodeId int, now time.Time) (int64, bool, error) {
+	budget, err := GetBudget(db, nodeId, now)
+	if err != nil || budget.NodeId == 0 {
+		return 0, false, err
+	}
+	return budget.BudgetMsat - budget.SpentMsat, true, nil
+}
+
+func setBudget(db *sqlx.DB, budget Budget) (Budget, error) {
+	now := time.Now().UTC()
+	budget.CreatedOn = now
+	budget.UpdatedOn = now
+	err := db.QueryRowx(`
+		INSERT INTO rebalance_budget (node_id, budget_msat, period_seconds, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (node_id) DO UPDATE SET budget_msat=EXCLUDED.budget_msat,
+			period_seconds=EXCLUDED.period_seconds, updated_on=EXCLUDED.updated_on
+		RETURNING created_on;`,
+		budget.NodeId, budget.BudgetMsat, budget.PeriodSeconds, budget.CreatedOn, budget.UpdatedOn).
+		Scan(&budget.CreatedOn)
+	if err != nil {
+		return Budget{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return GetBudget(db, budget.NodeId, now)
+}
+
+func removeBudget(db *sqlx.DB, nodeId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM rebalance_budget WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
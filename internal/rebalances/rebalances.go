@@ -0,0 +1,502 @@
+package rebalances
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/internal/spending_policies"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+type Status string
+
+const (
+	Pending   = Status("pending")
+	Running   = Status("running")
+	Completed = Status("completed")
+	Failed    = Status("failed")
+	Cancelled = Status("cancelled")
+)
+
+type AttemptStatus string
+
+const (
+	AttemptSucceeded = AttemptStatus("succeeded")
+	AttemptFailed    = AttemptStatus("failed")
+)
+
+const (
+	pollInterval       = time.Minute
+	defaultMaxAttempts = 10
+	// minAttemptAmountMsat is the smallest amount a failed attempt is split into.
+	minAttemptAmountMsat  = 10000000
+	attemptTimeoutSeconds = 60
+	invoiceExpirySeconds  = 3600
+	// MaxFeePpm is the highest fee rate a rebalance may pay, a spending policy of the node can lower it.
+	MaxFeePpm = 10000
+)
+
+type Rebalance struct {
+	RebalanceId      int           `json:"rebalanceId" db:"rebalance_id"`
+	NodeId           int           `json:"nodeId" db:"node_id"`
+	SourceChannelIds pq.Int64Array `json:"sourceChannelIds" db:"source_channel_ids"`
+	SourceTagIds     pq.Int64Array `json:"sourceTagIds" db:"source_tag_ids"`
+	TargetChannelIds pq.Int64Array `json:"targetChannelIds" db:"target_channel_ids"`
+	TargetTagIds     pq.Int64Array `json:"targetTagIds" db:"target_tag_ids"`
+	AmountMsat       int64         `json:"amountMsat" db:"amount_msat"`
+	MaxFeePpm        int64         `json:"maxFeePpm" db:"max_fee_ppm"`
+	MaxAttempts      int           `json:"maxAttempts" db:"max_attempts"`
+	Status           Status        `json:"status" db:"status"`
+	RebalancedMsat   int64         `json:"rebalancedMsat" db:"rebalanced_msat"`
+	FeePaidMsat      int64         `json:"feePaidMsat" db:"fee_paid_msat"`
+	Attempts         int           `json:"attempts" db:"attempts"`
+	Error            *string       `json:"error" db:"error"`
+	CreatedOn        time.Time     `json:"createdOn" db:"created_on"`
+	StartedOn        *time.Time    `json:"startedOn" db:"started_on"`
+	FinishedOn       *time.Time    `json:"finishedOn" db:"finished_on"`
+	AttemptList      []Attempt     `json:"attemptList,omitempty" db:"-"`
+}
+
+type Attempt struct {
+	RebalanceAttemptId int             `json:"rebalanceAttemptId" db:"rebalance_attempt_id"`
+	RebalanceId        int             `json:"rebalanceId" db:"rebalance_id"`
+	OutgoingChannelId  int             `json:"outgoingChannelId" db:"outgoing_channel_id"`
+	IncomingChannelId  int             `json:"incomingChannelId" db:"incoming_channel_id"`
+	AmountMsat         int64           `json:"amountMsat" db:"amount_msat"`
+	FeeLimitMsat       int64           `json:"feeLimitMsat" db:"fee_limit_msat"`
+	FeeMsat            int64           `json:"feeMsat" db:"fee_msat"`
+	PaymentHash        *string         `json:"paymentHash" db:"payment_hash"`
+	Route              *types.JSONText `json:"route" db:"route"`
+	Status             AttemptStatus   `json:"status" db:"status"`
+	FailureReason      *string         `json:"failureReason" db:"failure_reason"`
+	CreatedOn          time.Time       `json:"createdOn" db:"created_on"`
+	FinishedOn         time.Time       `json:"finishedOn" db:"finished_on"`
+}
+
+// Hop is a hop of the route of an attempt, ChannelId is 0 for channels Torq doesn't know.
+type Hop struct {
+	ChannelId           int    `json:"channelId"`
+	ShortChannelId      string `json:"shortChannelId"`
+	PublicKey           string `json:"publicKey"`
+	AmountToForwardMsat int64  `json:"amountToForwardMsat"`
+	FeeMsat             int64  `json:"feeMsat"`
+}
+
+// RebalanceRequest moves AmountMsat out of the source channels into the target channels. Sources and targets are
+// channels or tags, tags include the channels tagged through corridors.
+type RebalanceRequest struct {
+	NodeId           int   `json:"nodeId" binding:"required"`
+	SourceChannelIds []int `json:"sourceChannelIds"`
+	SourceTagIds     []int `json:"sourceTagIds"`
+	TargetChannelIds []int `json:"targetChannelIds"`
+	TargetTagIds     []int `json:"targetTagIds"`
+	AmountMsat       int64 `json:"amountMsat" binding:"required"`
+	MaxFeePpm        int64 `json:"maxFeePpm" binding:"required"`
+	// MaxAttempts defaults to 10, failed attempts are retried with half the amount.
+	MaxAttempts int `json:"maxAttempts"`
+}
+
+// Budget limits the fees a node spends on rebalancing within each period, SpentMsat is spent in the current period.
+type Budget struct {
+	NodeId        int       `json:"nodeId" db:"node_id"`
+	BudgetMsat    int64     `json:"budgetMsat" db:"budget_msat"`
+	PeriodSeconds int       `json:"periodSeconds" db:"period_seconds"`
+	SpentMsat     int64     `json:"spentMsat" db:"-"`
+	CreatedOn     time.Time `json:"createdOn" db:"created_on"`
+	UpdatedOn     time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+//nolint:gochecknoglobals
+var rebalanceRequested = make(chan struct{}, 1)
+
+//nolint:gochecknoglobals
+var (
+	runningRebalancesMu sync.Mutex
+	runningRebalances   = make(map[int]context.CancelFunc)
+)
+
+// RequestRebalance queues a rebalance of the node.
+func RequestRebalance(db *sqlx.DB, rr RebalanceRequest) (Rebalance, error) {
+	rebalance := Rebalance{
+		NodeId:           rr.NodeId,
+		SourceChannelIds: int64Array(rr.SourceChannelIds),
+		SourceTagIds:     int64Array(rr.SourceTagIds),
+		TargetChannelIds: int64Array(rr.TargetChannelIds),
+		TargetTagIds:     int64Array(rr.TargetTagIds),
+		AmountMsat:       rr.AmountMsat,
+		MaxFeePpm:        rr.MaxFeePpm,
+		MaxAttempts:      rr.MaxAttempts,
+		Status:           Pending,
+	}
+	if rebalance.MaxAttempts == 0 {
+		rebalance.MaxAttempts = defaultMaxAttempts
+	}
+	rebalance, err := addRebalance(db, rebalance)
+	if err != nil {
+		return Rebalance{}, err
+	}
+	select {
+	case rebalanceRequested <- struct{}{}:
+	default:
+	}
+	return rebalance, nil
+}
+
+// CancelRebalance cancels a pending rebalance, a running rebalance stops before its next attempt. RebalanceId is 0
+// when the rebalance doesn't exist.
+func CancelRebalance(db *sqlx.DB, rebalanceId int) (Rebalance, error) {
+	rebalance, err := cancelPendingRebalance(db, rebalanceId)
+	if err != nil || rebalance.RebalanceId != 0 {
+		return rebalance, err
+	}
+	runningRebalancesMu.Lock()
+	if cancel, exists := runningRebalances[rebalanceId]; exists {
+		cancel()
+	}
+	runningRebalancesMu.Unlock()
+	return GetRebalance(db, rebalanceId)
+}
+
+// Start runs the requested rebalances one at a time until the context is done. Rebalances that were running when
+// Torq stopped are failed.
+func Start(ctx context.Context, db *sqlx.DB, eventChannel chan interface{}) {
+	if err := failInterruptedRebalances(db); err != nil {
+		log.Error().Err(err).Msg("Failing interrupted rebalances")
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			rebalance, err := claimRebalance(db)
+			if err != nil {
+				log.Error().Err(err).Msg("Claiming rebalance")
+				break
+			}
+			if rebalance.RebalanceId == 0 {
+				break
+			}
+			runRebalance(ctx, db, rebalance, eventChannel)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-rebalanceRequested:
+		}
+	}
+}
+
+func runRebalance(ctx context.Context, db *sqlx.DB, rebalance Rebalance, eventChannel chan interface{}) {
+	log.Info().Msgf("Rebalancing node id: %v (rebalance %v)", rebalance.NodeId, rebalance.RebalanceId)
+	stop, cancel := context.WithCancel(ctx)
+	runningRebalancesMu.Lock()
+	runningRebalances[rebalance.RebalanceId] = cancel
+	runningRebalancesMu.Unlock()
+	defer func() {
+		runningRebalancesMu.Lock()
+		delete(runningRebalances, rebalance.RebalanceId)
+		runningRebalancesMu.Unlock()
+		cancel()
+	}()
+
+	client, err := settings.GetNodeClient(db, rebalance.NodeId)
+	if err == nil {
+		err = Execute(ctx, stop, db, client, &rebalance, eventChannel)
+	}
+	rebalance.Status = Completed
+	switch {
+	case ctx.Err() != nil:
+		rebalance.Status = Failed
+		message := "Interrupted"
+		rebalance.Error = &message
+	case stop.Err() != nil && rebalance.RebalancedMsat < rebalance.AmountMsat:
+		rebalance.Status = Cancelled
+	case err != nil:
+		rebalance.Status = Failed
+		message := err.Error()
+		rebalance.Error = &message
+		log.Error().Err(err).Msgf("Rebalance %v failed", rebalance.RebalanceId)
+	}
+	if err := finishRebalance(db, rebalance); err != nil {
+		log.Error().Err(err).Msgf("Storing rebalance %v", rebalance.RebalanceId)
+	}
+	sendRebalanceEvent(eventChannel, rebalance, nil)
+}
+
+// Execute pays self-invoices out of the source channels into the target channels until the amount of the rebalance
+// is moved, the attempts run out, the budget is spent or stop is done. Every attempt is stored.
+func Execute(ctx context.Context, stop context.Context, db *sqlx.DB, client node_client.NodeClient,
+	rebalance *Rebalance, eventChannel chan interface{}) error {
+
+	sources, err := resolveChannels(db, rebalance.NodeId, rebalance.SourceChannelIds, rebalance.SourceTagIds)
+	if err != nil {
+		return err
+	}
+	targets, err := resolveChannels(db, rebalance.NodeId, rebalance.TargetChannelIds, rebalance.TargetTagIds)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 || len(targets) == 0 {
+		return errors.New("The rebalance needs at least one source and one target channel")
+	}
+	attemptAmountMsat := rebalance.AmountMsat
+	for rebalance.RebalancedMsat < rebalance.AmountMsat {
+		if stop.Err() != nil {
+			return nil
+		}
+		if rebalance.Attempts >= rebalance.MaxAttempts {
+			return errors.Newf("Gave up after %v attempts", rebalance.Attempts)
+		}
+		remainingBudgetMsat, limited, err := getRemainingBudgetMsat(db, rebalance.NodeId, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListChannels(ctx, &lnrpc.ListChannelsRequest{ActiveOnly: true})
+		if err != nil {
+			return errors.Wrap(err, "List channels")
+		}
+		source, target, amountMsat := pickChannels(resp.Channels, sources, targets,
+			min(attemptAmountMsat, rebalance.AmountMsat-rebalance.RebalancedMsat))
+		if source == nil {
+			return errors.New("No active source and target channels with enough liquidity")
+		}
+		feeLimitMsat := amountMsat * rebalance.MaxFeePpm / 1000000
+		if limited && remainingBudgetMsat < feeLimitMsat {
+			feeLimitMsat = remainingBudgetMsat
+		}
+		if feeLimitMsat <= 0 {
+			return errors.New("The rebalancing budget is spent")
+		}
+		// The spending policy can be changed while the rebalance runs.
+		if err := spending_policies.CheckFee(db, rebalance.NodeId, amountMsat, feeLimitMsat); err != nil {
+			return err
+		}
+
+		attempt := pay(ctx, client, rebalance.RebalanceId, source, target, amountMsat, feeLimitMsat)
+		attempt.RebalanceId = rebalance.RebalanceId
+		rebalance.Attempts++
+		if attempt.Status == AttemptSucceeded {
+			rebalance.RebalancedMsat += attempt.AmountMsat
+			rebalance.FeePaidMsat += attempt.FeeMsat
+		} else {
+			attemptAmountMsat = amountMsat / 2
+			if attemptAmountMsat < minAttemptAmountMsat {
+				attemptAmountMsat = minAttemptAmountMsat
+			}
+		}
+		attempt, err = addAttempt(db, *rebalance, attempt)
+		if err != nil {
+			return err
+		}
+		rebalance.AttemptList = append(rebalance.AttemptList, attempt)
+		sendRebalanceEvent(eventChannel, *rebalance, &attempt)
+	}
+	return nil
+}
+
+// pickChannels returns the source with the most sendable balance and the target with the most receivable balance
+// of another peer, with the amount both can move. The source is nil when there's nothing to move.
+func pickChannels(candidates []*lnrpc.Channel, sources map[int]bool, targets map[int]bool,
+	amountMsat int64) (*lnrpc.Channel, *lnrpc.Channel, int64) {
+
+	var source, target *lnrpc.Channel
+	for _, channel := range candidates {
+		channelId := channelIdOf(channel)
+		if sources[channelId] && (source == nil || sendableMsat(channel) > sendableMsat(source)) {
+			source = channel
+		}
+	}
+	if source == nil {
+		return nil, nil, 0
+	}
+	for _, channel := range candidates {
+		channelId := channelIdOf(channel)
+		if targets[channelId] && channel.RemotePubkey != source.RemotePubkey &&
+			(target == nil || receivableMsat(channel) > receivableMsat(target)) {
+			target = channel
+		}
+	}
+	if target == nil {
+		return nil, nil, 0
+	}
+	amountMsat = min(amountMsat, min(sendableMsat(source), receivableMsat(target)))
+	if amountMsat <= 0 {
+		return nil, nil, 0
+	}
+	return source, target, amountMsat
+}
+
+func sendableMsat(channel *lnrpc.Channel) int64 {
+	return (channel.LocalBalance - int64(channel.GetLocalConstraints().GetChanReserveSat())) * 1000
+}
+
+func receivableMsat(channel *lnrpc.Channel) int64 {
+	return (channel.RemoteBalance - int64(channel.GetRemoteConstraints().GetChanReserveSat())) * 1000
+}
+
+func channelIdOf(channel *lnrpc.Channel) int {
+	return commons.GetChannelIdFromShortChannelId(channels.ConvertLNDShortChannelID(channel.ChanId))
+}
+
+// pay sends a self-invoice out of the source channel with the peer of the target as the last hop, failures to create
+// or send the payment are stored as failed attempts.
+func pay(ctx context.Context, client node_client.NodeClient, rebalanceId int, source *lnrpc.Channel,
+	target *lnrpc.Channel, amountMsat int64, feeLimitMsat int64) Attempt {
+
+	attempt := Attempt{
+		OutgoingChannelId: channelIdOf(source),
+		IncomingChannelId: channelIdOf(target),
+		AmountMsat:        amountMsat,
+		FeeLimitMsat:      feeLimitMsat,
+		Status:            AttemptFailed,
+		CreatedOn:         time.Now().UTC(),
+	}
+	fail := func(err error) Attempt {
+		message := err.Error()
+		attempt.FailureReason = &message
+		attempt.FinishedOn = time.Now().UTC()
+		return attempt
+	}
+
+	invoice, err := client.AddInvoice(ctx, &lnrpc.Invoice{
+		Memo:      fmt.Sprintf("Torq rebalance %v", rebalanceId),
+		ValueMsat: amountMsat,
+		Expiry:    invoiceExpirySeconds,
+	})
+	if err != nil {
+		return fail(errors.Wrap(err, "Add invoice"))
+	}
+	paymentHash := hex.EncodeToString(invoice.RHash)
+	attempt.PaymentHash = &paymentHash
+	lastHop, err := hex.DecodeString(target.RemotePubkey)
+	if err != nil {
+		return fail(errors.Wrap(err, "Decode the public key of the target peer"))
+	}
+	stream, err := client.SendPaymentV2(ctx, &routerrpc.SendPaymentRequest{
+		PaymentRequest:   invoice.PaymentRequest,
+		OutgoingChanIds:  []uint64{source.ChanId},
+		LastHopPubkey:    lastHop,
+		FeeLimitMsat:     feeLimitMsat,
+		AllowSelfPayment: true,
+		TimeoutSeconds:   attemptTimeoutSeconds,
+	})
+	if err != nil {
+		return fail(errors.Wrap(err, "Send payment"))
+	}
+	var payment *lnrpc.Payment
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(errors.Wrap(err, "Receive payment update"))
+		}
+		payment = update
+		if payment.Status == lnrpc.Payment_SUCCEEDED || payment.Status == lnrpc.Payment_FAILED {
+			break
+		}
+	}
+	if payment == nil {
+		return fail(errors.New("The payment ended without a status"))
+	}
+	attempt.Route = routeOf(payment)
+	attempt.FinishedOn = time.Now().UTC()
+	if payment.Status != lnrpc.Payment_SUCCEEDED {
+		reason := payment.FailureReason.String()
+		attempt.FailureReason = &reason
+		return attempt
+	}
+	attempt.Status = AttemptSucceeded
+	attempt.FeeMsat = payment.FeeMsat
+	return attempt
+}
+
+// routeOf returns the route of the HTLC that settled or else of the last HTLC.
+func routeOf(payment *lnrpc.Payment) *types.JSONText {
+	var route *lnrpc.Route
+	for _, htlc := range payment.Htlcs {
+		route = htlc.Route
+		if htlc.Status == lnrpc.HTLCAttempt_SUCCEEDED {
+			break
+		}
+	}
+	if route == nil {
+		return nil
+	}
+	hops := make([]Hop, 0, len(route.Hops))
+	for _, hop := range route.Hops {
+		shortChannelId := channels.ConvertLNDShortChannelID(hop.ChanId)
+		hops = append(hops, Hop{
+			ChannelId:           commons.GetChannelIdFromShortChannelId(shortChannelId),
+			ShortChannelId:      shortChannelId,
+			PublicKey:           hop.PubKey,
+			AmountToForwardMsat: hop.AmtToForwardMsat,
+			FeeMsat:             hop.FeeMsat,
+		})
+	}
+	routeJson, err := json.Marshal(hops)
+	if err != nil {
+		return nil
+	}
+	text := types.JSONText(routeJson)
+	return &text
+}
+
+func sendRebalanceEvent(eventChannel chan interface{}, rebalance Rebalance, attempt *Attempt) {
+	if eventChannel == nil {
+		return
+	}
+	event := broadcast.RebalanceEvent{
+		EventData:      broadcast.EventData{EventTime: time.Now().UTC(), NodeId: rebalance.NodeId},
+		RebalanceId:    rebalance.RebalanceId,
+		Status:         string(rebalance.Status),
+		AmountMsat:     rebalance.AmountMsat,
+		RebalancedMsat: rebalance.RebalancedMsat,
+		FeePaidMsat:    rebalance.FeePaidMsat,
+		Attempts:       rebalance.Attempts,
+	}
+	if rebalance.Error != nil {
+		event.Error = *rebalance.Error
+	}
+	if attempt != nil {
+		event.AttemptStatus = string(attempt.Status)
+		event.OutgoingChannelId = attempt.OutgoingChannelId
+		event.IncomingChannelId = attempt.IncomingChannelId
+		event.AttemptAmountMsat = attempt.AmountMsat
+		event.AttemptFeeMsat = attempt.FeeMsat
+	}
+	eventChannel <- event
+}
+
+func int64Array(values []int) pq.Int64Array {
+	array := pq.Int64Array{}
+	for _, value := range values {
+		array = append(array, int64(value))
+	}
+	return array
+}
+
+func min(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
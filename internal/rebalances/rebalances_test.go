@@ -0,0 +1,114 @@
+package rebalances
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func TestPickChannels(t *testing.T) {
+	go commons.ManagedChannelCache(commons.ManagedChannelChannel, nil)
+
+	// Channels that aren't in the channel cache have channelId 0.
+	all := map[int]bool{0: true}
+	alice := &lnrpc.Channel{ChanId: 1, RemotePubkey: "alice", LocalBalance: 800000, RemoteBalance: 200000}
+	bob := &lnrpc.Channel{ChanId: 2, RemotePubkey: "bob", LocalBalance: 100000, RemoteBalance: 900000}
+	carol := &lnrpc.Channel{ChanId: 3, RemotePubkey: "carol", LocalBalance: 300000, RemoteBalance: 700000,
+		RemoteConstraints: &lnrpc.ChannelConstraints{ChanReserveSat: 10000}}
+	aliceAgain := &lnrpc.Channel{ChanId: 4, RemotePubkey: "alice", LocalBalance: 0, RemoteBalance: 1000000}
+
+	tests := []struct {
+		name       string
+		candidates []*lnrpc.Channel
+		amountMsat int64
+		source     *lnrpc.Channel
+		target     *lnrpc.Channel
+		pickedMsat int64
+	}{
+		{"Most sendable to most receivable", []*lnrpc.Channel{alice, bob, carol}, 50000000, alice, bob, 50000000},
+		{"Limited by the source", []*lnrpc.Channel{bob, carol}, 500000000, carol, bob, 300000000},
+		{"Limited by the target reserve", []*lnrpc.Channel{alice, carol}, 900000000, alice, carol, 690000000},
+		{"Not to the same peer", []*lnrpc.Channel{alice, aliceAgain}, 50000000, nil, nil, 0},
+		{"Empty channels are targets", []*lnrpc.Channel{aliceAgain, bob}, 50000000, bob, aliceAgain, 50000000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, target, amountMsat := pickChannels(test.candidates, all, all, test.amountMsat)
+			if source != test.source || target != test.target || amountMsat != test.pickedMsat {
+				t.Errorf("pickChannels()\nGot:\n%v %v %v\nWant:\n%v %v %v\n",
+					source.GetChanId(), target.GetChanId(), amountMsat,
+					test.source.GetChanId(), test.target.GetChanId(), test.pickedMsat)
+			}
+		})
+	}
+}
+
+func TestPay(t *testing.T) {
+	go commons.ManagedChannelCache(commons.ManagedChannelChannel, nil)
+
+	ctx := context.Background()
+	node := node_client.NewFakeNode("alice")
+	source := node.AddChannel(node_client.NewFakeNode("bob").PublicKey(), 1000000, 800000)
+	target := node.AddChannel(node_client.NewFakeNode("carol").PublicKey(), 1000000, 200000)
+
+	attempt := pay(ctx, node, 1, source, target, 100000000, 100000)
+	if attempt.Status != AttemptSucceeded || attempt.PaymentHash == nil || attempt.Route == nil {
+		t.Fatalf("pay()\nGot:\n%v %v\nWant:\n%v\n", attempt.Status, attempt.FailureReason, AttemptSucceeded)
+	}
+	var hops []Hop
+	if err := json.Unmarshal(*attempt.Route, &hops); err != nil || len(hops) < 2 {
+		t.Fatalf("pay() route\nGot:\n%v %v\nWant:\n%v\n", hops, err, "at least 2 hops")
+	}
+	if hops[0].PublicKey != source.RemotePubkey || hops[len(hops)-1].PublicKey != node.PublicKey() {
+		t.Errorf("pay() route\nGot:\n%v ... %v\nWant:\n%v ... %v\n",
+			hops[0].PublicKey, hops[len(hops)-1].PublicKey, source.RemotePubkey, node.PublicKey())
+	}
+	channels, err := node.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances := map[uint64]int64{}
+	for _, channel := range channels.Channels {
+		balances[channel.ChanId] = channel.LocalBalance
+	}
+	if balances[source.ChanId] >= 800000 || balances[target.ChanId] != 300000 {
+		t.Errorf("pay() balances\nGot:\n%v %v\nWant:\n%v %v\n",
+			balances[source.ChanId], balances[target.ChanId], "below 800000", 300000)
+	}
+
+	attempt = pay(ctx, node, 1, source, source, 100000000, 100000)
+	if attempt.Status != AttemptFailed || attempt.FailureReason == nil {
+		t.Errorf("pay() through the same channel\nGot:\n%v\nWant:\n%v\n", attempt.Status, AttemptFailed)
+	}
+}
+
+func TestValidateRebalanceRequest(t *testing.T) {
+	serverError := &server_errors.ServerError{}
+	validateRebalanceRequest(RebalanceRequest{NodeId: 1, SourceChannelIds: []int{1}, TargetTagIds: []int{2},
+		AmountMsat: 1000000, MaxFeePpm: 500}, serverError)
+	if serverError.Errors.Fields != nil {
+		t.Errorf("validateRebalanceRequest() valid\nGot:\n%v\nWant:\n%v\n", serverError.Errors.Fields, nil)
+	}
+
+	serverError = &server_errors.ServerError{}
+	validateRebalanceRequest(RebalanceRequest{NodeId: 1, SourceChannelIds: []int{1}, TargetChannelIds: []int{1},
+		MaxFeePpm: 500, MaxAttempts: -1}, serverError)
+	if len(serverError.Errors.Fields) != 3 {
+		t.Errorf("validateRebalanceRequest() invalid\nGot:\n%v\nWant:\n%v\n", serverError.Errors.Fields,
+			"targetChannelIds, amountMsat and maxAttempts")
+	}
+
+	serverError = &server_errors.ServerError{}
+	validateRebalanceRequest(RebalanceRequest{NodeId: 1, SourceChannelIds: []int{1}, TargetTagIds: []int{2},
+		AmountMsat: 1000000, MaxFeePpm: 1000000}, serverError)
+	if serverError.Errors.Fields["maxFeePpm"] == nil {
+		t.Errorf("validateRebalanceRequest() fee above the cap\nGot:\n%v\nWant:\n%v\n", serverError.Errors.Fields,
+			"maxFeePpm")
+	}
+}
@@ -0,0 +1,273 @@
+package rebalances
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/spending_policies"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultRebalanceLimit = 100
+	maxRebalanceLimit     = 1000
+)
+
+type BudgetRequest struct {
+	BudgetMsat    int64 `json:"budgetMsat"`
+	PeriodSeconds int   `json:"periodSeconds" binding:"required"`
+}
+
+func RegisterRebalanceRoutes(r *gin.RouterGroup, db *sqlx.DB, totpRequired gin.HandlerFunc) {
+	r.GET("", func(c *gin.Context) { getRebalancesHandler(c, db) })
+	r.POST("", totpRequired, func(c *gin.Context) { requestRebalanceHandler(c, db) })
+	r.GET(":rebalanceId", func(c *gin.Context) { getRebalanceHandler(c, db) })
+	r.POST(":rebalanceId/cancel", func(c *gin.Context) { cancelRebalanceHandler(c, db) })
+}
+
+func RegisterRebalanceBudgetRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET(":nodeId", func(c *gin.Context) { getBudgetHandler(c, db) })
+	r.PUT(":nodeId", func(c *gin.Context) { setBudgetHandler(c, db) })
+	r.DELETE(":nodeId", func(c *gin.Context) { removeBudgetHandler(c, db) })
+}
+
+func getRebalancesHandler(c *gin.Context, db *sqlx.DB) {
+	limit := defaultRebalanceLimit
+	var err error
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxRebalanceLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxRebalanceLimit))
+			return
+		}
+	}
+	nodeId := 0
+	if c.Query("nodeId") != "" {
+		nodeId, err = strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+	}
+	rebalances, err := getRebalances(db, nodeId, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting rebalances.")
+		return
+	}
+	c.JSON(http.StatusOK, rebalances)
+}
+
+func requestRebalanceHandler(c *gin.Context, db *sqlx.DB) {
+	var rr RebalanceRequest
+	if err := c.BindJSON(&rr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	serverError := &server_errors.ServerError{}
+	if commons.GetNodeSettingsByNodeId(rr.NodeId).NodeId == 0 {
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+	}
+	validateRebalanceRequest(rr, serverError)
+	if serverError.Errors.Fields != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	err := spending_policies.CheckFee(db, rr.NodeId, rr.AmountMsat, rr.AmountMsat*rr.MaxFeePpm/1000000)
+	if err != nil {
+		server_errors.SendServerErrorOrWrap(c, err, "Checking the spending policy")
+		return
+	}
+	rebalance, err := RequestRebalance(db, rr)
+	audit.Record(db, c, audit.RequestRebalance, rr, rebalance, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Requesting rebalance for nodeId: %v", rr.NodeId))
+		return
+	}
+	c.JSON(http.StatusOK, rebalance)
+}
+
+func getRebalanceHandler(c *gin.Context, db *sqlx.DB) {
+	rebalanceId, err := strconv.Atoi(c.Param("rebalanceId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse rebalanceId in the request.")
+		return
+	}
+	rebalance, err := GetRebalance(db, rebalanceId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting rebalance for rebalanceId: %v", rebalanceId))
+		return
+	}
+	if rebalance.RebalanceId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Rebalance not found.")
+		return
+	}
+	c.JSON(http.StatusOK, rebalance)
+}
+
+func cancelRebalanceHandler(c *gin.Context, db *sqlx.DB) {
+	rebalanceId, err := strconv.Atoi(c.Param("rebalanceId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse rebalanceId in the request.")
+		return
+	}
+	rebalance, err := CancelRebalance(db, rebalanceId)
+	audit.Record(db, c, audit.CancelRebalance, rebalanceId, rebalance, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Cancelling rebalanceId: %v", rebalanceId))
+		return
+	}
+	if rebalance.RebalanceId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Rebalance not found.")
+		return
+	}
+	c.JSON(http.StatusOK, rebalance)
+}
+
+func getBudgetHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	budget, err := GetBudget(db, nodeId, time.Now().UTC())
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting rebalance budget for nodeId: %v", nodeId))
+		return
+	}
+	if budget.NodeId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Rebalance budget not found.")
+		return
+	}
+	c.JSON(http.StatusOK, budget)
+}
+
+func setBudgetHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	var br BudgetRequest
+	if err := c.BindJSON(&br); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	serverError := &server_errors.ServerError{}
+	if commons.GetNodeSettingsByNodeId(nodeId).NodeId == 0 {
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+	}
+	if br.BudgetMsat < 0 {
+		serverError.AddFieldError("budgetMsat", "The budget can't be negative.")
+	}
+	if br.PeriodSeconds <= 0 {
+		serverError.AddFieldError("periodSeconds", "The period needs to be positive.")
+	}
+	if serverError.Errors.Fields != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	budget, err := setBudget(db, Budget{NodeId: nodeId, BudgetMsat: br.BudgetMsat, PeriodSeconds: br.PeriodSeconds})
+	audit.Record(db, c, audit.SetRebalanceBudget, br, budget, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting rebalance budget for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, budget)
+}
+
+func removeBudgetHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	count, err := removeBudget(db, nodeId)
+	audit.Record(db, c, audit.RemoveRebalanceBudget, nodeId, count, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing rebalance budget for nodeId: %v", nodeId))
+		return
+	}
+	if count == 0 {
+		server_errors.SendUnprocessableEntity(c, "Rebalance budget not found.")
+		return
+	}
+	c.JSON(http.StatusOK,
+		map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v rebalance budget(s).", count)})
+}
+
+// validateRebalanceRequest adds an error for every field of the request that can't be rebalanced.
+func validateRebalanceRequest(rr RebalanceRequest, serverError *server_errors.ServerError) {
+	if len(rr.SourceChannelIds) == 0 && len(rr.SourceTagIds) == 0 {
+		serverError.AddFieldError("sourceChannelIds", "At least one source channel or tag is required.")
+	}
+	if len(rr.TargetChannelIds) == 0 && len(rr.TargetTagIds) == 0 {
+		serverError.AddFieldError("targetChannelIds", "At least one target channel or tag is required.")
+	}
+	for _, source := range rr.SourceChannelIds {
+		for _, target := range rr.TargetChannelIds {
+			if source == target {
+				serverError.AddFieldError("targetChannelIds",
+					fmt.Sprintf("Channel %v can't be a source and a target.", source))
+			}
+		}
+	}
+	if rr.AmountMsat <= 0 {
+		serverError.AddFieldError("amountMsat", "The amount needs to be positive.")
+	}
+	if rr.MaxFeePpm <= 0 || rr.MaxFeePpm > MaxFeePpm {
+		serverError.AddFieldError("maxFeePpm", fmt.Sprintf("The maximum fee needs to be between 1 and %v ppm.",
+			MaxFeePpm))
+	}
+	if rr.MaxAttempts < 0 {
+		serverError.AddFieldError("maxAttempts", "The maximum attempts can't be negative.")
+	}
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the most recent rebalances",
+			QueryParameters: []string{"nodeId", "limit"},
+			Response:        []Rebalance{},
+		},
+		"POST ": {
+			Summary:     "Queue a circular rebalance from source channels or tags to target channels or tags",
+			RequestBody: RebalanceRequest{},
+			Response:    Rebalance{},
+		},
+		"GET :rebalanceId": {
+			Summary:  "Get a rebalance with the route, fee and outcome of every attempt",
+			Response: Rebalance{},
+		},
+		"POST :rebalanceId/cancel": {
+			Summary:  "Cancel a rebalance, a running rebalance stops before its next attempt",
+			Response: Rebalance{},
+		},
+	}
+}
+
+func BudgetOpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET :nodeId": {
+			Summary:  "Get the rebalancing budget of a node with what's spent in the current period",
+			Response: Budget{},
+		},
+		"PUT :nodeId": {
+			Summary:     "Set the fees a node may spend on rebalancing within each period",
+			RequestBody: BudgetRequest{},
+			Response:    Budget{},
+		},
+		"DELETE :nodeId": {
+			Summary: "Remove the rebalancing budget of a node",
+		},
+	}
+}
@@ -0,0 +1,133 @@
+package reconciliation
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func addReport(db *sqlx.DB, report Report) (Report, error) {
+	report.CreatedOn = time.Now().UTC()
+	err := db.QueryRowx(`
+		INSERT INTO reconciliation_report (node_id, from_time, status, discrepancies, fixed, created_on)
+		VALUES ($1, $2, $3, 0, 0, $4)
+		RETURNING reconciliation_report_id;`,
+		report.NodeId, report.FromTime, report.Status, report.CreatedOn).Scan(&report.ReconciliationReportId)
+	if err != nil {
+		return Report{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return report, nil
+}
+
+// GetReport returns the report with its discrepancies, ReconciliationReportId is 0 when the report doesn't exist.
+func GetReport(db *sqlx.DB, reconciliationReportId int) (Report, error) {
+	var report Report
+	err := db.Get(&report, `SELECT * FROM reconciliation_report WHERE reconciliation_report_id=$1;`,
+		reconciliationReportId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Report{}, nil
+		}
+		return Report{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	report.Discrepancies = []Discrepancy{}
+	err = db.Select(&report.Discrepancies, `
+		SELECT * FROM reconciliation_discrepancy
+		WHERE reconciliation_report_id=$1
+		ORDER BY reconciliation_discrepancy_id;`, reconciliationReportId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Report{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return report, nil
+}
+
+// getReports returns the most recent reports without their discrepancies, a nodeId of 0 returns them for all nodes.
+func getReports(db *sqlx.DB, nodeId int, limit int) ([]Report, error) {
+	reports := []Report{}
+	err := db.Select(&reports, `
+		SELECT * FROM reconciliation_report
+		WHERE $1=0 OR node_id=$1
+		ORDER BY reconciliation_report_id DESC
+		LIMIT $2;`, nodeId, limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return reports, nil
+}
+
+func getLastReportCreatedOn(db *sqlx.DB, nodeId int) (*time.Time, error) {
+	var createdOn *time.Time
+	err := db.Get(&createdOn, `SELECT MAX(created_on) FROM reconciliation_report WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return createdOn, nil
+}
+
+// claimReport marks the oldest pending report as running, ReconciliationReportId is 0 when there was nothing to claim.
+func claimReport(db *sqlx.DB) (Report, error) {
+	var report Report
+	err := db.Get(&report, `
+		UPDATE reconciliation_report SET status=$1, started_on=$2
+		WHERE reconciliation_report_id = (
+			SELECT reconciliation_report_id FROM reconciliation_report
+			WHERE status=$3
+			ORDER BY reconciliation_report_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING *;`,
+		Running, time.Now().UTC(), Pending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Report{}, nil
+		}
+		return Report{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return report, nil
+}
+
+func failInterruptedReports(db *sqlx.DB) error {
+	_, err := db.Exec(`
+		UPDATE reconciliation_report SET status=$1, error='Interrupted', finished_on=$2 WHERE status=$3;`,
+		Failed, time.Now().UTC(), Running)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// finishReport stores the status of the report together with its discrepancies.
+func finishReport(db *sqlx.DB, report Report) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.Exec(`
+		UPDATE reconciliation_report SET status=$1, error=$2, discrepancies=$3, fixed=$4, finished_on=$5
+		WHERE reconciliation_report_id=$6;`,
+		report.Status, report.Error, report.DiscrepancyCount, report.FixedCount, time.Now().UTC(),
+		report.ReconciliationReportId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	for _, discrepancy := range report.Discrepancies {
+		_, err = tx.Exec(`
+			INSERT INTO reconciliation_discrepancy
+				(reconciliation_report_id, kind, reference, description, action, error, created_on)
+			VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+			report.ReconciliationReportId, discrepancy.Kind, discrepancy.Reference, discrepancy.Description,
+			discrepancy.Action, discrepancy.Error, discrepancy.CreatedOn)
+		if err != nil {
+			return errors.Wrap(err, database.SqlExecutionError)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package reconciliation
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+// Kind is the table in which a discrepancy was found.
+type Kind string
+
+const (
+	Channels = Kind("channel")
+	Payments = Kind("payment")
+	Invoices = Kind("invoice")
+	Forwards = Kind("forward")
+)
+
+// Action is what was done about a discrepancy.
+type Action string
+
+const (
+	Added   = Action("added")
+	Updated = Action("updated")
+	// Reported discrepancies can't be fixed automatically, they need to be looked at.
+	Reported  = Action("reported")
+	FixFailed = Action("fixFailed")
+)
+
+type ReportStatus string
+
+const (
+	Pending   = ReportStatus("pending")
+	Running   = ReportStatus("running")
+	Completed = ReportStatus("completed")
+	Failed    = ReportStatus("failed")
+)
+
+const (
+	pollInterval = time.Minute
+	// DefaultWindow is how far back payments, invoices and forwards are compared.
+	DefaultWindow = 7 * 24 * time.Hour
+)
+
+type Report struct {
+	ReconciliationReportId int           `json:"reconciliationReportId" db:"reconciliation_report_id"`
+	NodeId                 int           `json:"nodeId" db:"node_id"`
+	FromTime               time.Time     `json:"fromTime" db:"from_time"`
+	Status                 ReportStatus  `json:"status" db:"status"`
+	Error                  *string       `json:"error" db:"error"`
+	DiscrepancyCount       int           `json:"discrepancies" db:"discrepancies"`
+	FixedCount             int           `json:"fixed" db:"fixed"`
+	CreatedOn              time.Time     `json:"createdOn" db:"created_on"`
+	StartedOn              *time.Time    `json:"startedOn" db:"started_on"`
+	FinishedOn             *time.Time    `json:"finishedOn" db:"finished_on"`
+	Discrepancies          []Discrepancy `json:"discrepancyList,omitempty" db:"-"`
+}
+
+type Discrepancy struct {
+	ReconciliationDiscrepancyId int       `json:"reconciliationDiscrepancyId" db:"reconciliation_discrepancy_id"`
+	ReconciliationReportId      int       `json:"reconciliationReportId" db:"reconciliation_report_id"`
+	Kind                        Kind      `json:"kind" db:"kind"`
+	Reference                   string    `json:"reference" db:"reference"`
+	Description                 string    `json:"description" db:"description"`
+	Action                      Action    `json:"action" db:"action"`
+	Error                       *string   `json:"error" db:"error"`
+	CreatedOn                   time.Time `json:"createdOn" db:"created_on"`
+}
+
+type ReportRequest struct {
+	NodeId int `json:"nodeId" binding:"required"`
+	// FromTime defaults to the start of the default window.
+	FromTime *time.Time `json:"fromTime"`
+}
+
+// Add records a discrepancy, a fix that failed is recorded as FixFailed with its error.
+func (report *Report) Add(kind Kind, reference string, description string, action Action, err error) {
+	discrepancy := Discrepancy{
+		ReconciliationReportId: report.ReconciliationReportId,
+		Kind:                   kind,
+		Reference:              reference,
+		Description:            description,
+		Action:                 action,
+		CreatedOn:              time.Now().UTC(),
+	}
+	if err != nil {
+		discrepancy.Action = FixFailed
+		message := err.Error()
+		discrepancy.Error = &message
+	}
+	report.Discrepancies = append(report.Discrepancies, discrepancy)
+	report.DiscrepancyCount++
+	if discrepancy.Action == Added || discrepancy.Action == Updated {
+		report.FixedCount++
+	}
+}
+
+// Runner compares the database with the node of the report, fixes what it can and adds every discrepancy to the
+// report.
+type Runner func(ctx context.Context, report *Report) error
+
+//nolint:gochecknoglobals
+var reportRequested = make(chan struct{}, 1)
+
+// RequestReport queues a reconciliation of the node.
+func RequestReport(db *sqlx.DB, rr ReportRequest) (Report, error) {
+	fromTime := time.Now().UTC().Add(-DefaultWindow)
+	if rr.FromTime != nil {
+		fromTime = rr.FromTime.UTC()
+	}
+	report, err := addReport(db, Report{NodeId: rr.NodeId, FromTime: fromTime, Status: Pending})
+	if err != nil {
+		return Report{}, err
+	}
+	select {
+	case reportRequested <- struct{}{}:
+	default:
+	}
+	return report, nil
+}
+
+// Start reconciles every active node each interval and runs the requested reports until the context is done.
+// An interval of 0 only runs the requested reports. Reports that were running when Torq stopped are failed.
+func Start(ctx context.Context, db *sqlx.DB, eventChannel chan interface{}, interval time.Duration, window time.Duration,
+	run Runner) {
+
+	if err := failInterruptedReports(db); err != nil {
+		log.Error().Err(err).Msg("Failing interrupted reconciliation reports")
+	}
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if interval > 0 {
+			if err := scheduleReports(db, interval, window); err != nil {
+				log.Error().Err(err).Msg("Scheduling reconciliation reports")
+			}
+		}
+		for ctx.Err() == nil {
+			report, err := claimReport(db)
+			if err != nil {
+				log.Error().Err(err).Msg("Claiming reconciliation report")
+				break
+			}
+			if report.ReconciliationReportId == 0 {
+				break
+			}
+			runReport(ctx, db, report, eventChannel, run)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-reportRequested:
+		}
+	}
+}
+
+// scheduleReports queues a report for each active node that wasn't reconciled within the interval.
+func scheduleReports(db *sqlx.DB, interval time.Duration, window time.Duration) error {
+	nodes, err := settings.GetActiveNodesConnectionDetails(db)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, node := range nodes {
+		last, err := getLastReportCreatedOn(db, node.NodeId)
+		if err != nil {
+			return err
+		}
+		if last != nil && now.Sub(*last) < interval {
+			continue
+		}
+		_, err = addReport(db, Report{NodeId: node.NodeId, FromTime: now.Add(-window), Status: Pending})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runReport(ctx context.Context, db *sqlx.DB, report Report, eventChannel chan interface{}, run Runner) {
+	log.Info().Msgf("Reconciling node id: %v (report %v)", report.NodeId, report.ReconciliationReportId)
+	report.Discrepancies = []Discrepancy{}
+	err := run(ctx, &report)
+	report.Status = Completed
+	if err != nil {
+		report.Status = Failed
+		message := err.Error()
+		report.Error = &message
+		log.Error().Err(err).Msgf("Reconciliation report %v failed", report.ReconciliationReportId)
+	}
+	if ctx.Err() != nil {
+		report.Status = Failed
+		message := "Interrupted"
+		report.Error = &message
+	}
+	if err = finishReport(db, report); err != nil {
+		log.Error().Err(err).Msgf("Storing reconciliation report %v", report.ReconciliationReportId)
+	}
+	if eventChannel == nil {
+		return
+	}
+	event := broadcast.ReconciliationEvent{
+		EventData:              broadcast.EventData{EventTime: time.Now().UTC(), NodeId: report.NodeId},
+		ReconciliationReportId: report.ReconciliationReportId,
+		Status:                 string(report.Status),
+		Discrepancies:          report.DiscrepancyCount,
+		Fixed:                  report.FixedCount,
+	}
+	if report.Error != nil {
+		event.Error = *report.Error
+	}
+	eventChannel <- event
+}
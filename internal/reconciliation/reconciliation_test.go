@@ -0,0 +1,62 @@
+package reconciliation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestReportAdd(t *testing.T) {
+	report := &Report{ReconciliationReportId: 3}
+	report.Add(Channels, "txid:0", "Short channel id missing in Torq", Updated, nil)
+	report.Add(Payments, "12", "Payment missing in Torq", Added, errors.New("db down"))
+	report.Add(Forwards, "1673308800000000000", "Forward is in Torq but unknown to the node", Reported, nil)
+
+	if report.DiscrepancyCount != 3 || report.FixedCount != 1 {
+		t.Errorf("Add() counts\nGot:\n%v %v\nWant:\n%v %v\n", report.DiscrepancyCount, report.FixedCount, 3, 1)
+	}
+	want := []Action{Updated, FixFailed, Reported}
+	for i, discrepancy := range report.Discrepancies {
+		if discrepancy.Action != want[i] || discrepancy.ReconciliationReportId != 3 {
+			t.Errorf("Add() discrepancy %d\nGot:\n%v\nWant:\n%v\n", i, discrepancy, want[i])
+		}
+	}
+	if report.Discrepancies[1].Error == nil || *report.Discrepancies[1].Error != "db down" {
+		t.Errorf("Add() error\nGot:\n%v\nWant:\n%v\n", report.Discrepancies[1].Error, "db down")
+	}
+}
+
+func TestValidateReportRequest(t *testing.T) {
+	go commons.ManagedNodeCache(commons.ManagedNodeChannel, nil)
+	commons.SetTorqNode(1, commons.Active, "PublicKey1", commons.Bitcoin, commons.SigNet)
+
+	now := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -30)
+	future := now.Add(time.Hour)
+	tests := []struct {
+		name   string
+		rr     ReportRequest
+		fields []string
+	}{
+		{"Valid", ReportRequest{NodeId: 1}, nil},
+		{"Valid from time", ReportRequest{NodeId: 1, FromTime: &past}, nil},
+		{"Unknown node", ReportRequest{NodeId: 2}, []string{"nodeId"}},
+		{"From in the future", ReportRequest{NodeId: 1, FromTime: &future}, []string{"fromTime"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serverError := ValidateReportRequest(test.rr, now)
+			var fields []string
+			if serverError != nil {
+				for field := range serverError.Errors.Fields {
+					fields = append(fields, field)
+				}
+			}
+			if len(fields) != len(test.fields) || (len(fields) == 1 && fields[0] != test.fields[0]) {
+				t.Errorf("ValidateReportRequest()\nGot:\n%v\nWant:\n%v\n", fields, test.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package reconciliation
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultReportLimit = 50
+	maxReportLimit     = 500
+)
+
+func RegisterReconciliationRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getReportsHandler(c, db) })
+	r.POST("", func(c *gin.Context) { requestReportHandler(c, db) })
+	r.GET(":reconciliationReportId", func(c *gin.Context) { getReportHandler(c, db) })
+}
+
+func getReportsHandler(c *gin.Context, db *sqlx.DB) {
+	limit := defaultReportLimit
+	if c.Query("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxReportLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxReportLimit))
+			return
+		}
+	}
+	nodeId := 0
+	if c.Query("nodeId") != "" {
+		var err error
+		nodeId, err = strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+	}
+	reports, err := getReports(db, nodeId, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting reconciliation reports.")
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func requestReportHandler(c *gin.Context, db *sqlx.DB) {
+	var rr ReportRequest
+	if err := c.BindJSON(&rr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if serverError := ValidateReportRequest(rr, time.Now()); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	report, err := RequestReport(db, rr)
+	audit.Record(db, c, audit.RequestReconciliation, rr, report, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Requesting reconciliation.")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func getReportHandler(c *gin.Context, db *sqlx.DB) {
+	reconciliationReportId, err := strconv.Atoi(c.Param("reconciliationReportId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse reconciliationReportId in the request.")
+		return
+	}
+	report, err := GetReport(db, reconciliationReportId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Getting reconciliationReportId: %v", reconciliationReportId))
+		return
+	}
+	if report.ReconciliationReportId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Reconciliation report not found.")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ValidateReportRequest checks the node and the from time of a requested reconciliation.
+func ValidateReportRequest(rr ReportRequest, now time.Time) *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	if rr.NodeId == 0 || commons.GetNodeSettingsByNodeId(rr.NodeId).NodeId == 0 {
+		serverError.AddFieldError("nodeId", "An existing node is required.")
+	}
+	if rr.FromTime != nil && !rr.FromTime.Before(now) {
+		serverError.AddFieldError("fromTime", "The from time needs to be in the past.")
+	}
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	return serverError
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the most recent reconciliation reports, without their discrepancies",
+			QueryParameters: []string{"nodeId", "limit"},
+			Response:        []Report{},
+		},
+		"POST ": {
+			Summary:     "Compare the database with a node now, the report is filled in when the run finishes",
+			RequestBody: ReportRequest{},
+			Response:    Report{},
+		},
+		"GET :reconciliationReportId": {
+			Summary:  "Get a reconciliation report with every discrepancy and the action taken",
+			Response: Report{},
+		},
+	}
+}
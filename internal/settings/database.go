@@ -10,7 +10,9 @@ import (
 
 	"github.com/lncapital/torq/internal/database"
 	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/pkg/cln"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func getSettings(db *sqlx.DB) (settings, error) {
@@ -159,6 +161,10 @@ func setNodeConnectionDetailsStatus(db *sqlx.DB, nodeId int, status commons.Stat
 	if err != nil {
 		return errors.Wrap(err, database.SqlExecutionError)
 	}
+	if status != commons.Active {
+		lnd_connect.CloseConnection(nodeId)
+		cln.CloseClient(nodeId)
+	}
 	return nil
 }
 
@@ -168,13 +174,23 @@ func SetNodeConnectionDetails(db *sqlx.DB, ncd nodeConnectionDetails) (nodeConne
 	_, err := db.Exec(`
 		UPDATE node_connection_details
 		SET implementation = $1, name = $2, grpc_address = $3, tls_file_name = $4, tls_data = $5,
-		    macaroon_file_name = $6, macaroon_data = $7, status_id = $8, updated_on = $9
-		WHERE node_id = $10;`,
+		    macaroon_file_name = $6, macaroon_data = $7, status_id = $8, updated_on = $9,
+		    cln_address = $10, cln_ca_certificate_file_name = $11, cln_ca_certificate_data = $12, cln_rune = $13
+		WHERE node_id = $14;`,
 		ncd.Implementation, ncd.Name, ncd.GRPCAddress, ncd.TLSFileName, ncd.TLSDataBytes,
-		ncd.MacaroonFileName, ncd.MacaroonDataBytes, ncd.Status, ncd.UpdatedOn, ncd.NodeId)
+		ncd.MacaroonFileName, ncd.MacaroonDataBytes, ncd.Status, ncd.UpdatedOn,
+		ncd.ClnAddress, ncd.ClnCaCertificateFileName, ncd.ClnCaCertificateDataBytes, ncd.ClnRune, ncd.NodeId)
 	if err != nil {
 		return ncd, errors.Wrap(err, database.SqlExecutionError)
 	}
+	// Requests use the new address and credentials from now on, a rename keeps the pooled connection.
+	if ncd.Status != commons.Active || ncd.Implementation != commons.LND || ncd.GRPCAddress == nil {
+		lnd_connect.CloseConnection(ncd.NodeId)
+	} else {
+		lnd_connect.CloseChangedConnection(ncd.NodeId, *ncd.GRPCAddress, ncd.TLSDataBytes, ncd.MacaroonDataBytes)
+	}
+	// Core Lightning clients hold no stream state, dropping them is cheap.
+	cln.CloseClient(ncd.NodeId)
 	return ncd, nil
 }
 
@@ -206,10 +222,12 @@ func addNodeConnectionDetails(db *sqlx.DB, ncd nodeConnectionDetails) (nodeConne
 	_, err := db.Exec(`
 		INSERT INTO node_connection_details
 		    (node_id, name, implementation, grpc_address, tls_file_name, tls_data, macaroon_file_name, macaroon_data,
-		     status_id, created_on, updated_on)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11);`,
+		     status_id, created_on, updated_on, cln_address, cln_ca_certificate_file_name, cln_ca_certificate_data,
+		     cln_rune)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15);`,
 		ncd.NodeId, ncd.Name, ncd.Implementation, ncd.GRPCAddress, ncd.TLSFileName, ncd.TLSDataBytes,
-		ncd.MacaroonFileName, ncd.MacaroonDataBytes, ncd.Status, ncd.CreateOn, ncd.UpdatedOn)
+		ncd.MacaroonFileName, ncd.MacaroonDataBytes, ncd.Status, ncd.CreateOn, ncd.UpdatedOn,
+		ncd.ClnAddress, ncd.ClnCaCertificateFileName, ncd.ClnCaCertificateDataBytes, ncd.ClnRune)
 	if err != nil {
 		return ncd, errors.Wrap(err, database.SqlExecutionError)
 	}
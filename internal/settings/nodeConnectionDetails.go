@@ -18,14 +18,20 @@ type nodeConnectionDetails struct {
 	Implementation    commons.Implementation `json:"implementation" form:"implementation" db:"implementation"`
 	GRPCAddress       *string                `json:"grpcAddress" form:"grpcAddress" db:"grpc_address"`
 	TLSFileName       *string                `json:"tlsFileName" db:"tls_file_name"`
-	TLSDataBytes      []byte                 `db:"tls_data"`
+	TLSDataBytes      []byte                 `json:"-" db:"tls_data"`
 	TLSFile           *multipart.FileHeader  `form:"tlsFile"`
 	MacaroonFileName  *string                `json:"macaroonFileName" db:"macaroon_file_name"`
-	MacaroonDataBytes []byte                 `db:"macaroon_data"`
+	MacaroonDataBytes []byte                 `json:"-" db:"macaroon_data"`
 	MacaroonFile      *multipart.FileHeader  `form:"macaroonFile"`
 	Status            commons.Status         `json:"status" db:"status_id"`
 	CreateOn          time.Time              `json:"createdOn" db:"created_on"`
 	UpdatedOn         *time.Time             `json:"updatedOn"  db:"updated_on"`
+	// Core Lightning nodes are reached through clnrest instead of the LND gRPC details above.
+	ClnAddress                *string               `json:"clnAddress" form:"clnAddress" db:"cln_address"`
+	ClnCaCertificateFileName  *string               `json:"clnCaCertificateFileName" db:"cln_ca_certificate_file_name"`
+	ClnCaCertificateDataBytes []byte                `json:"-" db:"cln_ca_certificate_data"`
+	ClnCaCertificateFile      *multipart.FileHeader `form:"clnCaCertificateFile"`
+	ClnRune                   *string               `json:"-" form:"clnRune" db:"cln_rune"`
 }
 
 func GetNodeIdByGRPC(db *sqlx.DB, grpcAddress string) (int, error) {
@@ -44,7 +50,12 @@ func GetNodeIdByGRPC(db *sqlx.DB, grpcAddress string) (int, error) {
 
 func AddNodeToDB(db *sqlx.DB, implementation commons.Implementation,
 	grpcAddress string, tlsDataBytes []byte, macaroonDataBytes []byte) (nodeConnectionDetails, error) {
-	publicKey, chain, network, err := getInformationFromLndNode(grpcAddress, tlsDataBytes, macaroonDataBytes)
+	publicKey, chain, network, err := getInformationFromNode(ConnectionDetails{
+		Implementation:    implementation,
+		GRPCAddress:       grpcAddress,
+		TLSFileBytes:      tlsDataBytes,
+		MacaroonFileBytes: macaroonDataBytes,
+	})
 	if err != nil {
 		return nodeConnectionDetails{}, errors.Wrap(err, "Getting public key from node")
 	}
@@ -63,6 +74,7 @@ func AddNodeToDB(db *sqlx.DB, implementation commons.Implementation,
 	}
 
 	if existingNodeConnectionDetails.NodeId == nodeId {
+		existingNodeConnectionDetails.Implementation = implementation
 		existingNodeConnectionDetails.GRPCAddress = &grpcAddress
 		existingNodeConnectionDetails.TLSDataBytes = tlsDataBytes
 		existingNodeConnectionDetails.MacaroonDataBytes = macaroonDataBytes
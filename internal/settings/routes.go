@@ -16,9 +16,13 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/pkg/cln"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -36,10 +40,15 @@ type timeZone struct {
 type ConnectionDetails struct {
 	NodeId            int
 	Name              string
+	Implementation    commons.Implementation
 	GRPCAddress       string
 	TLSFileBytes      []byte
 	MacaroonFileBytes []byte
 	Status            commons.Status
+	// Core Lightning nodes are reached through clnrest with a rune instead of the LND gRPC details above.
+	ClnAddress            string
+	ClnCaCertificateBytes []byte
+	ClnRune               string
 }
 
 func RegisterSettingRoutes(r *gin.RouterGroup, db *sqlx.DB, restartLNDSub func() error) {
@@ -79,6 +88,7 @@ func updateSettingsHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 	err := updateSettings(db, settings)
+	audit.Record(db, c, audit.UpdateSettings, settings, nil, err)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -117,41 +127,61 @@ func addNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB, restartLNDSub
 		return
 	}
 
-	if ncd.TLSFile == nil || ncd.MacaroonFile == nil || ncd.GRPCAddress == nil || *ncd.GRPCAddress == "" {
-		server_errors.SendBadRequest(c, "All node details are required to add new node connection details")
-		return
-	}
-	tlsDataFile, err := ncd.TLSFile.Open()
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	tlsCert, err := io.ReadAll(tlsDataFile)
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	if len(tlsCert) == 0 {
-		server_errors.SendBadRequest(c, "Can't check new GRPC details without TLS Cert")
-		return
-	}
+	if ncd.Implementation == commons.CLN {
+		if ncd.ClnCaCertificateFile == nil || ncd.ClnAddress == nil || strings.TrimSpace(*ncd.ClnAddress) == "" ||
+			ncd.ClnRune == nil || strings.TrimSpace(*ncd.ClnRune) == "" {
+			server_errors.SendBadRequest(c, "All node details are required to add new node connection details")
+			return
+		}
+		var err error
+		ncd, err = processClnCaCertificate(ncd)
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		if len(ncd.ClnCaCertificateDataBytes) == 0 {
+			server_errors.SendBadRequest(c, "Can't check new CLN details without CA Certificate")
+			return
+		}
+	} else {
+		if ncd.TLSFile == nil || ncd.MacaroonFile == nil || ncd.GRPCAddress == nil || *ncd.GRPCAddress == "" {
+			server_errors.SendBadRequest(c, "All node details are required to add new node connection details")
+			return
+		}
+		tlsDataFile, err := ncd.TLSFile.Open()
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		tlsCert, err := io.ReadAll(tlsDataFile)
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		if len(tlsCert) == 0 {
+			server_errors.SendBadRequest(c, "Can't check new GRPC details without TLS Cert")
+			return
+		}
 
-	macaroonDataFile, err := ncd.MacaroonFile.Open()
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	macaroonFile, err := io.ReadAll(macaroonDataFile)
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	if len(macaroonFile) == 0 {
-		server_errors.SendBadRequest(c, "Can't check new GRPC details without Macaroon File")
-		return
+		macaroonDataFile, err := ncd.MacaroonFile.Open()
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		macaroonFile, err := io.ReadAll(macaroonDataFile)
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		if len(macaroonFile) == 0 {
+			server_errors.SendBadRequest(c, "Can't check new GRPC details without Macaroon File")
+			return
+		}
+		ncd.TLSDataBytes = tlsCert
+		ncd.MacaroonDataBytes = macaroonFile
 	}
 
-	publicKey, chain, network, err := getInformationFromLndNode(*ncd.GRPCAddress, tlsCert, macaroonFile)
+	publicKey, chain, network, err := getInformationFromNode(getConnectionDetails(ncd))
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Getting public key from node")
 		return
@@ -194,6 +224,7 @@ func addNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB, restartLNDSub
 		ncd.Name = fmt.Sprintf("Node_%v", ncd.NodeId)
 	}
 	ncd, err = addNodeConnectionDetails(db, ncd)
+	audit.Record(db, c, audit.AddNodeConnectionDetails, ncd, nil, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Adding node connection details")
 		return
@@ -240,10 +271,36 @@ func setNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB, restartLNDSub
 		ncd.MacaroonFileName = existingNcd.MacaroonFileName
 		ncd.TLSDataBytes = existingNcd.TLSDataBytes
 		ncd.TLSFileName = existingNcd.TLSFileName
+		ncd.ClnCaCertificateDataBytes = existingNcd.ClnCaCertificateDataBytes
+		ncd.ClnCaCertificateFileName = existingNcd.ClnCaCertificateFileName
+		if ncd.ClnRune == nil || strings.TrimSpace(*ncd.ClnRune) == "" {
+			ncd.ClnRune = existingNcd.ClnRune
+		}
 	}
 
-	// if GRPC details have changed we need to check that the public keys (if existing) matches
-	if existingNcd.GRPCAddress != ncd.GRPCAddress {
+	if ncd.Implementation == commons.CLN {
+		// The Core Lightning details are always checked, the public key has to match the existing node.
+		ncd, err = processClnCaCertificate(ncd)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, "Processing CA Certificate file")
+			return
+		}
+		if ncd.ClnAddress == nil || strings.TrimSpace(*ncd.ClnAddress) == "" || ncd.ClnRune == nil ||
+			len(ncd.ClnCaCertificateDataBytes) == 0 {
+			server_errors.SendBadRequest(c, "Can't check new CLN details without address, CA Certificate and Rune")
+			return
+		}
+		publicKey, chain, network, err := getInformationFromNode(getConnectionDetails(ncd))
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, "Obtaining publicKey/chain/network from CLN")
+			return
+		}
+		if existingNode.PublicKey != publicKey || existingNode.Chain != chain || existingNode.Network != network {
+			server_errors.SendUnprocessableEntity(c, "PublicKey/chain/network does not match, create a new node instead of updating this one")
+			return
+		}
+	} else if existingNcd.GRPCAddress != ncd.GRPCAddress || existingNcd.Implementation != ncd.Implementation {
+		// if GRPC details have changed we need to check that the public keys (if existing) matches
 		var tlsCert []byte
 		if ncd.TLSFile != nil {
 			tlsDataFile, err := ncd.TLSFile.Open()
@@ -311,6 +368,7 @@ func setNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB, restartLNDSub
 		return
 	}
 	ncd, err = SetNodeConnectionDetails(db, ncd)
+	audit.Record(db, c, audit.SetNodeConnectionDetails, ncd, nil, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Opening Macaroon file")
 		return
@@ -336,6 +394,8 @@ func setNodeConnectionDetailsStatusHandler(c *gin.Context, db *sqlx.DB, restartL
 	}
 
 	err = setNodeConnectionDetailsStatus(db, nodeId, commons.Status(statusId))
+	audit.Record(db, c, audit.SetNodeConnectionDetailsStatus,
+		map[string]interface{}{"nodeId": nodeId, "statusId": statusId}, nil, err)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -356,38 +416,112 @@ func GetActiveNodesConnectionDetails(db *sqlx.DB) ([]ConnectionDetails, error) {
 
 	var activeNodes []ConnectionDetails
 	for _, ncd := range activeNcds {
-		if ncd.GRPCAddress == nil || ncd.TLSDataBytes == nil || ncd.MacaroonDataBytes == nil {
+		switch {
+		case ncd.Implementation == commons.CLN:
+			if ncd.ClnAddress == nil || ncd.ClnCaCertificateDataBytes == nil || ncd.ClnRune == nil {
+				continue
+			}
+		case ncd.GRPCAddress == nil || ncd.TLSDataBytes == nil || ncd.MacaroonDataBytes == nil:
 			continue
 		}
-		activeNodes = append(activeNodes, ConnectionDetails{
-			NodeId:            ncd.NodeId,
-			GRPCAddress:       *ncd.GRPCAddress,
-			TLSFileBytes:      ncd.TLSDataBytes,
-			MacaroonFileBytes: ncd.MacaroonDataBytes,
-			Name:              ncd.Name,
-		})
+		activeNodes = append(activeNodes, getConnectionDetails(ncd))
 	}
 
 	return activeNodes, nil
 }
 
+// GetConnection returns the pooled gRPC connection of the node, the connection is shared and must not be closed.
+// The connection details are only fetched when the node has no usable connection.
+func GetConnection(db *sqlx.DB, nodeId int) (*grpc.ClientConn, error) {
+	return lnd_connect.GetConnection(nodeId, func() (string, []byte, []byte, error) {
+		connectionDetails, err := GetConnectionDetailsById(db, nodeId)
+		if err != nil {
+			return "", nil, nil, errors.Wrap(err, "Getting node connection details from the db")
+		}
+		return connectionDetails.GRPCAddress, connectionDetails.TLSFileBytes, connectionDetails.MacaroonFileBytes, nil
+	})
+}
+
+// GetClnClient returns the pooled clnrest client of a Core Lightning node.
+func GetClnClient(db *sqlx.DB, nodeId int) (*cln.Client, error) {
+	return cln.GetClient(nodeId, func() (string, []byte, string, error) {
+		connectionDetails, err := GetConnectionDetailsById(db, nodeId)
+		if err != nil {
+			return "", nil, "", errors.Wrap(err, "Getting node connection details from the db")
+		}
+		if connectionDetails.Implementation != commons.CLN {
+			return "", nil, "", errors.Newf("Node %v is not a Core Lightning node", nodeId)
+		}
+		return connectionDetails.ClnAddress, connectionDetails.ClnCaCertificateBytes, connectionDetails.ClnRune, nil
+	})
+}
+
+// GetNodeClient returns the client of the node for its implementation, a client registered for the node with
+// node_client.Register takes precedence.
+func GetNodeClient(db *sqlx.DB, nodeId int) (node_client.NodeClient, error) {
+	if client, exists := node_client.Registered(nodeId); exists {
+		return client, nil
+	}
+	connectionDetails, err := GetConnectionDetailsById(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Getting node connection details")
+	}
+	if connectionDetails.Implementation == commons.CLN {
+		client, err := GetClnClient(db, nodeId)
+		if err != nil {
+			return nil, errors.Wrap(err, "Connecting to CLN")
+		}
+		return cln.NewLightning(client), nil
+	}
+	conn, err := GetConnection(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Connecting to LND")
+	}
+	return node_client.NewLndClient(conn), nil
+}
+
 // GetConnectionDetailsById will still fetch details even if node is disabled or deleted
 func GetConnectionDetailsById(db *sqlx.DB, nodeId int) (ConnectionDetails, error) {
 	ncd, err := getNodeConnectionDetails(db, nodeId)
 	if err != nil {
 		return ConnectionDetails{}, errors.Wrapf(err, "Getting node connection details from db for nodeId: %v", nodeId)
 	}
+	return getConnectionDetails(ncd), nil
+}
+
+func getConnectionDetails(ncd nodeConnectionDetails) ConnectionDetails {
 	cd := ConnectionDetails{
-		NodeId:            ncd.NodeId,
-		TLSFileBytes:      ncd.TLSDataBytes,
-		MacaroonFileBytes: ncd.MacaroonDataBytes,
-		Name:              ncd.Name,
-		Status:            ncd.Status,
+		NodeId:                ncd.NodeId,
+		Implementation:        ncd.Implementation,
+		TLSFileBytes:          ncd.TLSDataBytes,
+		MacaroonFileBytes:     ncd.MacaroonDataBytes,
+		Name:                  ncd.Name,
+		Status:                ncd.Status,
+		ClnCaCertificateBytes: ncd.ClnCaCertificateDataBytes,
 	}
 	if ncd.GRPCAddress != nil {
 		cd.GRPCAddress = *ncd.GRPCAddress
 	}
-	return cd, nil
+	if ncd.ClnAddress != nil {
+		cd.ClnAddress = *ncd.ClnAddress
+	}
+	if ncd.ClnRune != nil {
+		cd.ClnRune = *ncd.ClnRune
+	}
+	return cd
+}
+
+// getInformationFromNode connects to the node with the given details to obtain its public key, chain and network.
+func getInformationFromNode(cd ConnectionDetails) (string, commons.Chain, commons.Network, error) {
+	if cd.Implementation == commons.CLN {
+		client, err := cln.NewClient(cd.ClnAddress, cd.ClnCaCertificateBytes, cd.ClnRune)
+		if err != nil {
+			return "", 0, 0, errors.Wrap(err,
+				"Can't connect to node to verify public key, check all details including CA Cert and Rune")
+		}
+		return getInformationFromNodeClient(cln.NewLightning(client))
+	}
+	return getInformationFromLndNode(cd.GRPCAddress, cd.TLSFileBytes, cd.MacaroonFileBytes)
 }
 
 func getInformationFromLndNode(grpcAddress string, tlsCert []byte, macaroonFile []byte) (
@@ -403,15 +537,17 @@ func getInformationFromLndNode(grpcAddress string, tlsCert []byte, macaroonFile
 			log.Debug().Err(err).Msg("Failed to close grpc connection.")
 		}
 	}(conn)
+	return getInformationFromNodeClient(node_client.NewLndClient(conn))
+}
 
-	client := lnrpc.NewLightningClient(conn)
+func getInformationFromNodeClient(client node_client.NodeClient) (string, commons.Chain, commons.Network, error) {
 	ctx := context.Background()
 	info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
 	if err != nil {
-		return "", 0, 0, errors.Wrap(err, "Obtaining information from LND")
+		return "", 0, 0, errors.Wrap(err, "Obtaining information from node")
 	}
 	if len(info.Chains) != 1 {
-		return "", 0, 0, errors.Wrapf(err, "Obtaining chains from LND %v", info.Chains)
+		return "", 0, 0, errors.Newf("Obtaining chains from node %v", info.Chains)
 	}
 
 	var chain commons.Chain
@@ -421,7 +557,7 @@ func getInformationFromLndNode(grpcAddress string, tlsCert []byte, macaroonFile
 	case "litecoin":
 		chain = commons.Litecoin
 	default:
-		return "", 0, 0, errors.Wrapf(err, "Obtaining chain from LND %v", info.Chains[0].Chain)
+		return "", 0, 0, errors.Newf("Obtaining chain from node %v", info.Chains[0].Chain)
 	}
 
 	var network commons.Network
@@ -437,7 +573,7 @@ func getInformationFromLndNode(grpcAddress string, tlsCert []byte, macaroonFile
 	case "regtest":
 		network = commons.RegTest
 	default:
-		return "", 0, 0, errors.Wrapf(err, "Obtaining network from LND %v", info.Chains[0].Network)
+		return "", 0, 0, errors.Newf("Obtaining network from node %v", info.Chains[0].Network)
 	}
 	return info.IdentityPubkey, chain, network, nil
 }
@@ -458,6 +594,22 @@ func processTLS(ncd nodeConnectionDetails) (nodeConnectionDetails, error) {
 	return ncd, nil
 }
 
+func processClnCaCertificate(ncd nodeConnectionDetails) (nodeConnectionDetails, error) {
+	if ncd.ClnCaCertificateFile != nil {
+		ncd.ClnCaCertificateFileName = &ncd.ClnCaCertificateFile.Filename
+		caCertificateFile, err := ncd.ClnCaCertificateFile.Open()
+		if err != nil {
+			return ncd, err
+		}
+		caCertificate, err := io.ReadAll(caCertificateFile)
+		if err != nil {
+			return ncd, err
+		}
+		ncd.ClnCaCertificateDataBytes = caCertificate
+	}
+	return ncd, nil
+}
+
 func processMacaroon(ncd nodeConnectionDetails) (nodeConnectionDetails, error) {
 	if ncd.MacaroonFile != nil {
 		ncd.MacaroonFileName = &ncd.MacaroonFile.Filename
@@ -492,3 +644,35 @@ func restartLND(c *gin.Context, restartLNDSub func() error) bool {
 	}
 	return false
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ":                      {Summary: "Get the settings", Response: settings{}},
+		"PUT ":                      {Summary: "Update the settings", RequestBody: settings{}, Response: settings{}},
+		"GET nodeConnectionDetails": {Summary: "List the node connection details", Response: []nodeConnectionDetails{}},
+		"GET nodeConnectionDetails/:nodeId": {
+			Summary:  "Get the connection details of a node",
+			Response: nodeConnectionDetails{},
+		},
+		"POST nodeConnectionDetails": {
+			Summary:     "Add the connection details of a node",
+			RequestForm: nodeConnectionDetails{},
+			Response:    nodeConnectionDetails{},
+		},
+		"PUT nodeConnectionDetails": {
+			Summary:     "Update the connection details of a node",
+			RequestForm: nodeConnectionDetails{},
+			Response:    nodeConnectionDetails{},
+		},
+		"PUT nodeConnectionDetails/:nodeId/:statusId": {
+			Summary:  "Update the status of the connection details of a node",
+			Response: nodeConnectionDetails{},
+		},
+	}
+}
+
+func UnauthenticatedOpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET timezones": {Summary: "List the time zones", Response: []timeZone{}},
+	}
+}
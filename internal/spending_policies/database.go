@@ -0,0 +1,102 @@
+package spending_policies
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getSpendingPolicy(db *sqlx.DB, nodeId int) (SpendingPolicy, error) {
+	var sp SpendingPolicy
+	err := db.Get(&sp, `SELECT * FROM spending_policy WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SpendingPolicy{}, nil
+		}
+		return SpendingPolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return sp, nil
+}
+
+func getSpendingPolicies(db *sqlx.DB) ([]SpendingPolicy, error) {
+	var sps []SpendingPolicy
+	err := db.Select(&sps, `SELECT * FROM spending_policy;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []SpendingPolicy{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return sps, nil
+}
+
+func setSpendingPolicy(db *sqlx.DB, sp SpendingPolicy) (SpendingPolicy, error) {
+	sp.UpdateOn = time.Now().UTC()
+	err := db.QueryRowx(`
+		INSERT INTO spending_policy (node_id, max_payment_amount_msat, max_daily_payment_amount_msat, max_fee_ppm,
+			max_on_chain_amount_sat, max_daily_on_chain_amount_sat, approval_payment_amount_msat,
+			approval_on_chain_amount_sat, approval_close_capacity_sat, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+		ON CONFLICT (node_id) DO UPDATE SET
+			max_payment_amount_msat=EXCLUDED.max_payment_amount_msat,
+			max_daily_payment_amount_msat=EXCLUDED.max_daily_payment_amount_msat,
+			max_fee_ppm=EXCLUDED.max_fee_ppm,
+			max_on_chain_amount_sat=EXCLUDED.max_on_chain_amount_sat,
+			max_daily_on_chain_amount_sat=EXCLUDED.max_daily_on_chain_amount_sat,
+			approval_payment_amount_msat=EXCLUDED.approval_payment_amount_msat,
+			approval_on_chain_amount_sat=EXCLUDED.approval_on_chain_amount_sat,
+			approval_close_capacity_sat=EXCLUDED.approval_close_capacity_sat,
+			updated_on=EXCLUDED.updated_on
+		RETURNING created_on;`,
+		sp.NodeId, sp.MaxPaymentAmountMsat, sp.MaxDailyPaymentAmountMsat, sp.MaxFeePpm,
+		sp.MaxOnChainAmountSat, sp.MaxDailyOnChainAmountSat, sp.ApprovalPaymentAmountMsat,
+		sp.ApprovalOnChainAmountSat, sp.ApprovalCloseCapacitySat, sp.UpdateOn).Scan(&sp.CreatedOn)
+	if err != nil {
+		return SpendingPolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return sp, nil
+}
+
+func removeSpendingPolicy(db *sqlx.DB, nodeId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM spending_policy WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+// getPaymentTotalMsat returns the amount of the payments that succeeded or are still in flight since the given time.
+func getPaymentTotalMsat(db *sqlx.DB, nodeId int, since time.Time) (int64, error) {
+	var total int64
+	err := db.Get(&total, `
+		SELECT COALESCE(SUM(value_msat), 0)::BIGINT
+		FROM payment
+		WHERE node_id=$1 AND creation_timestamp >= $2 AND status IN ('SUCCEEDED', 'IN_FLIGHT');`,
+		nodeId, since)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return total, nil
+}
+
+// getOnChainTotalSat returns the amount sent on-chain since the given time, outgoing transactions have a negative amount.
+func getOnChainTotalSat(db *sqlx.DB, nodeId int, since time.Time) (int64, error) {
+	var total int64
+	err := db.Get(&total, `
+		SELECT COALESCE(-SUM(amount), 0)::BIGINT
+		FROM tx
+		WHERE node_id=$1 AND timestamp >= $2 AND amount < 0;`,
+		nodeId, since)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return total, nil
+}
@@ -0,0 +1,118 @@
+package spending_policies
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterSpendingPolicyRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getSpendingPoliciesHandler(c, db) })
+	r.GET(":nodeId", func(c *gin.Context) { getSpendingPolicyHandler(c, db) })
+	r.PUT("", func(c *gin.Context) { setSpendingPolicyHandler(c, db) })
+	r.DELETE(":nodeId", func(c *gin.Context) { removeSpendingPolicyHandler(c, db) })
+}
+
+func getSpendingPoliciesHandler(c *gin.Context, db *sqlx.DB) {
+	sps, err := getSpendingPolicies(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting spending policies.")
+		return
+	}
+	c.JSON(http.StatusOK, sps)
+}
+
+func getSpendingPolicyHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	sp, err := getSpendingPolicy(db, nodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting spending policy for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, sp)
+}
+
+func setSpendingPolicyHandler(c *gin.Context, db *sqlx.DB) {
+	var sp SpendingPolicy
+	if err := c.BindJSON(&sp); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if serverError := validateSpendingPolicy(sp); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	storedSp, err := setSpendingPolicy(db, sp)
+	audit.Record(db, c, audit.SetSpendingPolicy, sp, storedSp, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting spending policy for nodeId: %v", sp.NodeId))
+		return
+	}
+	c.JSON(http.StatusOK, storedSp)
+}
+
+func removeSpendingPolicyHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	count, err := removeSpendingPolicy(db, nodeId)
+	audit.Record(db, c, audit.RemoveSpendingPolicy, map[string]interface{}{"nodeId": nodeId}, map[string]interface{}{"count": count}, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing spending policy for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v spending policy(s).", count)})
+}
+
+func validateSpendingPolicy(sp SpendingPolicy) *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	if sp.NodeId == 0 {
+		serverError.AddFieldError("nodeId", "Node id is required")
+	}
+	limits := map[string]*int64{
+		"maxPaymentAmountMsat":      sp.MaxPaymentAmountMsat,
+		"maxDailyPaymentAmountMsat": sp.MaxDailyPaymentAmountMsat,
+		"maxFeePpm":                 sp.MaxFeePpm,
+		"maxOnChainAmountSat":       sp.MaxOnChainAmountSat,
+		"maxDailyOnChainAmountSat":  sp.MaxDailyOnChainAmountSat,
+		"approvalPaymentAmountMsat": sp.ApprovalPaymentAmountMsat,
+		"approvalOnChainAmountSat":  sp.ApprovalOnChainAmountSat,
+		"approvalCloseCapacitySat":  sp.ApprovalCloseCapacitySat,
+	}
+	for field, limit := range limits {
+		if limit != nil && *limit < 0 {
+			serverError.AddFieldError(field, "Limit can't be negative")
+		}
+	}
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	return serverError
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ":        {Summary: "List the spending policies", Response: []SpendingPolicy{}},
+		"GET :nodeId": {Summary: "Get the spending policy of a node", Response: SpendingPolicy{}},
+		"PUT ": {
+			Summary:     "Add or update the spending policy of a node",
+			RequestBody: SpendingPolicy{},
+			Response:    SpendingPolicy{},
+		},
+		"DELETE :nodeId": {Summary: "Remove the spending policy of a node"},
+	}
+}
@@ -0,0 +1,150 @@
+package spending_policies
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+// rollingWindow is the period used for the daily limits.
+const rollingWindow = 24 * time.Hour
+
+// SpendingPolicy holds the limits for a node, a nil limit means there is no limit.
+type SpendingPolicy struct {
+	NodeId                    int    `json:"nodeId" db:"node_id" binding:"required"`
+	MaxPaymentAmountMsat      *int64 `json:"maxPaymentAmountMsat" db:"max_payment_amount_msat"`
+	MaxDailyPaymentAmountMsat *int64 `json:"maxDailyPaymentAmountMsat" db:"max_daily_payment_amount_msat"`
+	MaxFeePpm                 *int64 `json:"maxFeePpm" db:"max_fee_ppm"`
+	MaxOnChainAmountSat       *int64 `json:"maxOnChainAmountSat" db:"max_on_chain_amount_sat"`
+	MaxDailyOnChainAmountSat  *int64 `json:"maxDailyOnChainAmountSat" db:"max_daily_on_chain_amount_sat"`
+	// Requests above the approval thresholds need the approval of a second user.
+	ApprovalPaymentAmountMsat *int64    `json:"approvalPaymentAmountMsat" db:"approval_payment_amount_msat"`
+	ApprovalOnChainAmountSat  *int64    `json:"approvalOnChainAmountSat" db:"approval_on_chain_amount_sat"`
+	ApprovalCloseCapacitySat  *int64    `json:"approvalCloseCapacitySat" db:"approval_close_capacity_sat"`
+	CreatedOn                 time.Time `json:"createdOn" db:"created_on"`
+	UpdateOn                  time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+// GetSpendingPolicy returns the spending policy of the node, NodeId is 0 when the node has no policy.
+func GetSpendingPolicy(db *sqlx.DB, nodeId int) (SpendingPolicy, error) {
+	return getSpendingPolicy(db, nodeId)
+}
+
+// CheckPayment verifies a Lightning payment against the spending policy of the node.
+// When a limit is hit a *server_errors.ServerError is returned explaining which limit.
+func CheckPayment(db *sqlx.DB, nodeId int, amountMsat int64, feeLimitMsat int64) error {
+	policy, err := getSpendingPolicy(db, nodeId)
+	if err != nil {
+		return errors.Wrap(err, "Getting spending policy")
+	}
+	if policy.NodeId == 0 {
+		return nil
+	}
+	var dailyTotalMsat int64
+	if policy.MaxDailyPaymentAmountMsat != nil {
+		dailyTotalMsat, err = getPaymentTotalMsat(db, nodeId, time.Now().Add(-rollingWindow))
+		if err != nil {
+			return errors.Wrap(err, "Getting payment total")
+		}
+	}
+	if serverError := policy.checkPayment(amountMsat, feeLimitMsat, dailyTotalMsat); serverError != nil {
+		return serverError
+	}
+	return nil
+}
+
+// CheckFee verifies the fee limit of a payment that returns its amount to the node (i.e. a rebalance) against the
+// maximum fee rate of the spending policy of the node, only the fee is spent.
+// When the limit is hit a *server_errors.ServerError is returned.
+func CheckFee(db *sqlx.DB, nodeId int, amountMsat int64, feeLimitMsat int64) error {
+	policy, err := getSpendingPolicy(db, nodeId)
+	if err != nil {
+		return errors.Wrap(err, "Getting spending policy")
+	}
+	if policy.NodeId == 0 {
+		return nil
+	}
+	serverError := &server_errors.ServerError{}
+	policy.checkFee(amountMsat, feeLimitMsat, serverError)
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	serverError.AddServerError("Blocked by the spending policy")
+	return serverError
+}
+
+// CheckOnChain verifies an on-chain send (including channel funding) against the spending policy of the node.
+// When a limit is hit a *server_errors.ServerError is returned explaining which limit.
+func CheckOnChain(db *sqlx.DB, nodeId int, amountSat int64) error {
+	policy, err := getSpendingPolicy(db, nodeId)
+	if err != nil {
+		return errors.Wrap(err, "Getting spending policy")
+	}
+	if policy.NodeId == 0 {
+		return nil
+	}
+	var dailyTotalSat int64
+	if policy.MaxDailyOnChainAmountSat != nil {
+		dailyTotalSat, err = getOnChainTotalSat(db, nodeId, time.Now().Add(-rollingWindow))
+		if err != nil {
+			return errors.Wrap(err, "Getting on-chain total")
+		}
+	}
+	if serverError := policy.checkOnChain(amountSat, dailyTotalSat); serverError != nil {
+		return serverError
+	}
+	return nil
+}
+
+func (sp SpendingPolicy) checkPayment(amountMsat int64, feeLimitMsat int64,
+	dailyTotalMsat int64) *server_errors.ServerError {
+
+	serverError := &server_errors.ServerError{}
+	if sp.MaxPaymentAmountMsat != nil && amountMsat > *sp.MaxPaymentAmountMsat {
+		serverError.AddFieldError("maxPaymentAmountMsat", fmt.Sprintf(
+			"Payment of %v msat exceeds the limit of %v msat per payment", amountMsat, *sp.MaxPaymentAmountMsat))
+	}
+	if sp.MaxDailyPaymentAmountMsat != nil && dailyTotalMsat+amountMsat > *sp.MaxDailyPaymentAmountMsat {
+		serverError.AddFieldError("maxDailyPaymentAmountMsat", fmt.Sprintf(
+			"Payment of %v msat on top of the %v msat paid in the last 24 hours exceeds the limit of %v msat",
+			amountMsat, dailyTotalMsat, *sp.MaxDailyPaymentAmountMsat))
+	}
+	sp.checkFee(amountMsat, feeLimitMsat, serverError)
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	serverError.AddServerError("Blocked by the spending policy")
+	return serverError
+}
+
+func (sp SpendingPolicy) checkFee(amountMsat int64, feeLimitMsat int64, serverError *server_errors.ServerError) {
+	if sp.MaxFeePpm != nil && amountMsat > 0 {
+		feePpm := feeLimitMsat * 1_000_000 / amountMsat
+		if feePpm > *sp.MaxFeePpm {
+			serverError.AddFieldError("maxFeePpm", fmt.Sprintf(
+				"Fee limit of %v ppm exceeds the limit of %v ppm", feePpm, *sp.MaxFeePpm))
+		}
+	}
+}
+
+func (sp SpendingPolicy) checkOnChain(amountSat int64, dailyTotalSat int64) *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	if sp.MaxOnChainAmountSat != nil && amountSat > *sp.MaxOnChainAmountSat {
+		serverError.AddFieldError("maxOnChainAmountSat", fmt.Sprintf(
+			"On-chain amount of %v sat exceeds the limit of %v sat per transaction", amountSat, *sp.MaxOnChainAmountSat))
+	}
+	if sp.MaxDailyOnChainAmountSat != nil && dailyTotalSat+amountSat > *sp.MaxDailyOnChainAmountSat {
+		serverError.AddFieldError("maxDailyOnChainAmountSat", fmt.Sprintf(
+			"On-chain amount of %v sat on top of the %v sat sent in the last 24 hours exceeds the limit of %v sat",
+			amountSat, dailyTotalSat, *sp.MaxDailyOnChainAmountSat))
+	}
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	serverError.AddServerError("Blocked by the spending policy")
+	return serverError
+}
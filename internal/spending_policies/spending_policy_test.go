@@ -0,0 +1,96 @@
+package spending_policies
+
+import (
+	"testing"
+)
+
+func limit(value int64) *int64 {
+	return &value
+}
+
+func Test_checkPayment(t *testing.T) {
+	policy := SpendingPolicy{
+		NodeId:                    1,
+		MaxPaymentAmountMsat:      limit(1_000_000),
+		MaxDailyPaymentAmountMsat: limit(5_000_000),
+		MaxFeePpm:                 limit(1000),
+	}
+
+	tests := []struct {
+		name           string
+		policy         SpendingPolicy
+		amountMsat     int64
+		feeLimitMsat   int64
+		dailyTotalMsat int64
+		wantFields     []string
+	}{
+		{"No limits", SpendingPolicy{NodeId: 1}, 10_000_000, 10_000, 0, nil},
+		{"Within limits", policy, 1_000_000, 1000, 4_000_000, nil},
+		{"Payment too large", policy, 1_000_001, 0, 0, []string{"maxPaymentAmountMsat"}},
+		{"Daily total exceeded", policy, 500_000, 0, 4_600_000, []string{"maxDailyPaymentAmountMsat"}},
+		{"Fee too high", policy, 1_000_000, 1001, 0, []string{"maxFeePpm"}},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.policy.checkPayment(test.amountMsat, test.feeLimitMsat, test.dailyTotalMsat)
+			if test.wantFields == nil {
+				if got != nil {
+					t.Errorf("%d: checkPayment()\nGot:\n%v\nWant:\nnil\n", i, got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("%d: checkPayment()\nGot:\nnil\nWant:\n%v\n", i, test.wantFields)
+			}
+			for _, field := range test.wantFields {
+				if _, exists := got.Errors.Fields[field]; !exists {
+					t.Errorf("%d: checkPayment()\nGot:\n%v\nWant field:\n%v\n", i, got, field)
+				}
+			}
+		})
+	}
+}
+
+func Test_checkOnChain(t *testing.T) {
+	policy := SpendingPolicy{
+		NodeId:                   1,
+		MaxOnChainAmountSat:      limit(100_000),
+		MaxDailyOnChainAmountSat: limit(250_000),
+	}
+
+	tests := []struct {
+		name          string
+		amountSat     int64
+		dailyTotalSat int64
+		wantFields    []string
+	}{
+		{"Within limits", 100_000, 150_000, nil},
+		{"Transaction too large", 100_001, 0, []string{"maxOnChainAmountSat"}},
+		{"Daily total exceeded", 100_000, 150_001, []string{"maxDailyOnChainAmountSat"}},
+		{"Both exceeded", 200_000, 200_000, []string{"maxOnChainAmountSat", "maxDailyOnChainAmountSat"}},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := policy.checkOnChain(test.amountSat, test.dailyTotalSat)
+			if test.wantFields == nil {
+				if got != nil {
+					t.Errorf("%d: checkOnChain()\nGot:\n%v\nWant:\nnil\n", i, got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("%d: checkOnChain()\nGot:\nnil\nWant:\n%v\n", i, test.wantFields)
+			}
+			if len(got.Errors.Fields) != len(test.wantFields) {
+				t.Errorf("%d: checkOnChain()\nGot:\n%v\nWant fields:\n%v\n", i, got, test.wantFields)
+			}
+			for _, field := range test.wantFields {
+				if _, exists := got.Errors.Fields[field]; !exists {
+					t.Errorf("%d: checkOnChain()\nGot:\n%v\nWant field:\n%v\n", i, got, field)
+				}
+			}
+		})
+	}
+}
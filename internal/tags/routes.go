@@ -5,6 +5,8 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/openapi"
 	"github.com/lncapital/torq/pkg/server_errors"
 	"net/http"
 	"strconv"
@@ -67,6 +69,7 @@ func addTagHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 	storedTag, err := addTag(db, t)
+	audit.Record(db, c, audit.AddTag, t, storedTag, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Adding tag.")
 		return
@@ -81,6 +84,7 @@ func setTagHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 	storedTag, err := setTag(db, t)
+	audit.Record(db, c, audit.SetTag, t, storedTag, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting tag for tagId: %v", t.TagId))
 		return
@@ -96,9 +100,21 @@ func removeTagHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 	count, err := removeTag(db, tagId)
+	audit.Record(db, c, audit.RemoveTag, map[string]interface{}{"tagId": tagId}, map[string]interface{}{"count": count}, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing tag for tagId: %v", tagId))
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v tag(s).", count)})
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET get/:tagId":            {Summary: "Get a tag", Response: Tag{}},
+		"GET all":                   {Summary: "List all tags", Response: []Tag{}},
+		"GET forChannel/:channelId": {Summary: "List the tags of a channel", Response: []Tag{}},
+		"POST add":                  {Summary: "Add a tag", RequestBody: Tag{}, Response: Tag{}},
+		"PUT set":                   {Summary: "Update a tag", RequestBody: Tag{}, Response: Tag{}},
+		"DELETE :tagId":             {Summary: "Remove a tag"},
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 type Tag struct {
 	TagId     int       `json:"tagId" db:"tag_id"`
-	Name      string    `json:"name" db:"name"`
+	Name      string    `json:"name" db:"name" binding:"required"`
 	Style     string    `json:"style" db:"style"`
 	CreatedOn time.Time `json:"createdOn" db:"created_on"`
 	UpdateOn  time.Time `json:"updatedOn" db:"updated_on"`
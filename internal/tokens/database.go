@@ -0,0 +1,114 @@
+package tokens
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+// lastUsedPrecision limits how often last_used_on is written for a token that is used continuously.
+const lastUsedPrecision = time.Minute
+
+func getApiTokens(db *sqlx.DB) ([]ApiToken, error) {
+	var apiTokens []ApiToken
+	err := db.Select(&apiTokens, `SELECT * FROM api_token ORDER BY api_token_id;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []ApiToken{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return apiTokens, nil
+}
+
+func getApiTokenByToken(db *sqlx.DB, token string) (ApiToken, error) {
+	var apiToken ApiToken
+	err := db.Get(&apiToken, `SELECT * FROM api_token WHERE token_hash=$1;`, hashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ApiToken{}, nil
+		}
+		return ApiToken{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return apiToken, nil
+}
+
+func addApiToken(db *sqlx.DB, atr apiTokenRequest, createdByUserId *int) (newApiTokenResponse, error) {
+	token, err := generateToken()
+	if err != nil {
+		return newApiTokenResponse{}, errors.Wrap(err, "Generating token")
+	}
+	apiToken := ApiToken{
+		Name:            atr.Name,
+		TokenHash:       hashToken(token),
+		ExpiresOn:       atr.ExpiresOn,
+		CreatedOn:       time.Now().UTC(),
+		CreatedByUserId: createdByUserId,
+	}
+	for _, scope := range atr.Scopes {
+		apiToken.Scopes = append(apiToken.Scopes, string(scope))
+	}
+	apiToken.UpdateOn = apiToken.CreatedOn
+	err = db.QueryRowx(`INSERT INTO api_token (name, token_hash, scopes, expires_on, created_on, updated_on,
+			created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING api_token_id;`,
+		apiToken.Name, apiToken.TokenHash, apiToken.Scopes, apiToken.ExpiresOn, apiToken.CreatedOn,
+		apiToken.UpdateOn, apiToken.CreatedByUserId).Scan(&apiToken.ApiTokenId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return newApiTokenResponse{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return newApiTokenResponse{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return newApiTokenResponse{ApiToken: apiToken, Token: token}, nil
+}
+
+func revokeApiToken(db *sqlx.DB, apiTokenId int) (int64, error) {
+	now := time.Now().UTC()
+	res, err := db.Exec(`UPDATE api_token SET revoked_on=$1, updated_on=$1 WHERE api_token_id=$2 AND revoked_on IS NULL;`,
+		now, apiTokenId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+func setApiTokenLastUsed(db *sqlx.DB, apiTokenId int, lastUsedOn time.Time) error {
+	_, err := db.Exec(`UPDATE api_token SET last_used_on=$1 WHERE api_token_id=$2;`, lastUsedOn, apiTokenId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// Authenticate returns the usable api token matching the token and records that it was used.
+// When the token is unknown, revoked or expired an empty ApiToken is returned.
+func Authenticate(db *sqlx.DB, token string) (ApiToken, error) {
+	apiToken, err := getApiTokenByToken(db, token)
+	if err != nil {
+		return ApiToken{}, errors.Wrap(err, "Obtaining api token")
+	}
+	now := time.Now().UTC()
+	if apiToken.ApiTokenId == 0 || !apiToken.IsUsable(now) {
+		return ApiToken{}, nil
+	}
+	if apiToken.LastUsedOn == nil || now.Sub(*apiToken.LastUsedOn) > lastUsedPrecision {
+		err = setApiTokenLastUsed(db, apiToken.ApiTokenId, now)
+		if err != nil {
+			return ApiToken{}, errors.Wrap(err, "Recording api token usage")
+		}
+		apiToken.LastUsedOn = &now
+	}
+	return apiToken, nil
+}
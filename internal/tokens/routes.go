@@ -0,0 +1,88 @@
+package tokens
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+// GetUserId returns the id of the authenticated user, the tokens they create act on their behalf.
+type GetUserId func(c *gin.Context) (int, bool)
+
+func RegisterApiTokenRoutes(r *gin.RouterGroup, db *sqlx.DB, getUserId GetUserId) {
+	r.GET("", func(c *gin.Context) { getApiTokensHandler(c, db) })
+	r.POST("", func(c *gin.Context) { addApiTokenHandler(c, db, getUserId) })
+	r.DELETE(":apiTokenId", func(c *gin.Context) { revokeApiTokenHandler(c, db) })
+}
+
+func getApiTokensHandler(c *gin.Context, db *sqlx.DB) {
+	apiTokens, err := getApiTokens(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting api tokens.")
+		return
+	}
+	c.JSON(http.StatusOK, apiTokens)
+}
+
+func addApiTokenHandler(c *gin.Context, db *sqlx.DB, getUserId GetUserId) {
+	var atr apiTokenRequest
+	if err := c.BindJSON(&atr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	serverError := &server_errors.ServerError{}
+	if strings.TrimSpace(atr.Name) == "" {
+		serverError.AddFieldError("name", "A name is required.")
+	}
+	if len(atr.Scopes) == 0 {
+		serverError.AddFieldError("scopes", "At least one scope is required.")
+	}
+	for _, scope := range atr.Scopes {
+		if !scope.IsValid() {
+			serverError.AddFieldError("scopes", fmt.Sprintf("Unknown scope: %v", scope))
+		}
+	}
+	if atr.ExpiresOn != nil && !atr.ExpiresOn.After(time.Now()) {
+		serverError.AddFieldError("expiresOn", "The expiry needs to be in the future.")
+	}
+	if len(serverError.Errors.Fields) != 0 {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	var createdByUserId *int
+	if userId, ok := getUserId(c); ok {
+		createdByUserId = &userId
+	}
+	apiToken, err := addApiToken(db, atr, createdByUserId)
+	audit.Record(db, c, audit.AddApiToken, atr, apiToken, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding api token.")
+		return
+	}
+	c.JSON(http.StatusOK, apiToken)
+}
+
+func revokeApiTokenHandler(c *gin.Context, db *sqlx.DB) {
+	apiTokenId, err := strconv.Atoi(c.Param("apiTokenId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse apiTokenId in the request.")
+		return
+	}
+	count, err := revokeApiToken(db, apiTokenId)
+	audit.Record(db, c, audit.RevokeApiToken, map[string]interface{}{"apiTokenId": apiTokenId},
+		map[string]interface{}{"count": count}, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Revoking api token for apiTokenId: %v", apiTokenId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully revoked %v api token(s).", count)})
+}
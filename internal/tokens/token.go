@@ -0,0 +1,123 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/users"
+)
+
+const tokenPrefix = "torq_"
+
+type Scope string
+
+const (
+	// ReadScope allows every read only request a viewer can do (i.e. analytics).
+	ReadScope = Scope("read")
+	// FeeUpdateScope allows read only requests and updating channel policies.
+	FeeUpdateScope = Scope("fees")
+	// OperateScope allows everything an operator can do.
+	OperateScope = Scope("operate")
+	// AdminScope allows everything an admin can do.
+	AdminScope = Scope("admin")
+)
+
+// feeUpdateRoutes are the routes that can be written to with the FeeUpdateScope.
+var feeUpdateRoutes = []string{"/api/channels/update"} //nolint:gochecknoglobals
+
+func (scope Scope) IsValid() bool {
+	return scope == ReadScope || scope == FeeUpdateScope || scope == OperateScope || scope == AdminScope
+}
+
+func (scope Scope) role() users.Role {
+	switch scope {
+	case OperateScope:
+		return users.Operator
+	case AdminScope:
+		return users.Admin
+	}
+	return users.Viewer
+}
+
+type ApiToken struct {
+	ApiTokenId int            `json:"apiTokenId" db:"api_token_id"`
+	Name       string         `json:"name" db:"name"`
+	TokenHash  string         `json:"-" db:"token_hash"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	ExpiresOn  *time.Time     `json:"expiresOn" db:"expires_on"`
+	LastUsedOn *time.Time     `json:"lastUsedOn" db:"last_used_on"`
+	RevokedOn  *time.Time     `json:"revokedOn" db:"revoked_on"`
+	CreatedOn  time.Time      `json:"createdOn" db:"created_on"`
+	UpdateOn   time.Time      `json:"updatedOn" db:"updated_on"`
+	// CreatedByUserId is the user requests made with the token act on behalf of.
+	CreatedByUserId *int `json:"createdByUserId" db:"created_by_user_id"`
+}
+
+type apiTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []Scope    `json:"scopes"`
+	ExpiresOn *time.Time `json:"expiresOn"`
+}
+
+// newApiTokenResponse is the only time the token itself is returned.
+type newApiTokenResponse struct {
+	ApiToken
+	Token string `json:"token"`
+}
+
+// IsUsable returns false when the token is revoked or expired.
+func (token ApiToken) IsUsable(now time.Time) bool {
+	if token.RevokedOn != nil {
+		return false
+	}
+	if token.ExpiresOn != nil && !now.Before(*token.ExpiresOn) {
+		return false
+	}
+	return true
+}
+
+// Role returns the most privileged role granted by the scopes of the token.
+func (token ApiToken) Role() users.Role {
+	role := users.Viewer
+	for _, scope := range token.Scopes {
+		if Scope(scope).role() > role {
+			role = Scope(scope).role()
+		}
+	}
+	return role
+}
+
+// IsAllowed checks if the scopes of the token allow a request for the route requiring the role.
+func (token ApiToken) IsAllowed(route string, requiredRole users.Role) bool {
+	if token.Role().HasRole(requiredRole) {
+		return true
+	}
+	for _, scope := range token.Scopes {
+		if Scope(scope) != FeeUpdateScope {
+			continue
+		}
+		for _, feeUpdateRoute := range feeUpdateRoutes {
+			if route == feeUpdateRoute {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return tokenPrefix + hex.EncodeToString(tokenBytes), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
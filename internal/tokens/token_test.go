@@ -0,0 +1,73 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/users"
+)
+
+func Test_IsAllowed(t *testing.T) {
+	tests := []struct {
+		name         string
+		scopes       pq.StringArray
+		route        string
+		requiredRole users.Role
+		want         bool
+	}{
+		{"Read scope can read", pq.StringArray{"read"}, "/api/forwards", users.Viewer, true},
+		{"Read scope can't update fees", pq.StringArray{"read"}, "/api/channels/update", users.Operator, false},
+		{"Fee scope can update fees", pq.StringArray{"fees"}, "/api/channels/update", users.Operator, true},
+		{"Fee scope can't open channels", pq.StringArray{"fees"}, "/api/channels/openbatch", users.Operator, false},
+		{"Operate scope can open channels", pq.StringArray{"operate"}, "/api/channels/openbatch", users.Operator, true},
+		{"Operate scope can't manage tokens", pq.StringArray{"read", "operate"}, "/api/tokens", users.Admin, false},
+		{"Admin scope can manage tokens", pq.StringArray{"admin"}, "/api/tokens", users.Admin, true},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ApiToken{Scopes: test.scopes}.IsAllowed(test.route, test.requiredRole)
+			if got != test.want {
+				t.Errorf("%d: IsAllowed()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_IsUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token ApiToken
+		want  bool
+	}{
+		{"No expiry", ApiToken{}, true},
+		{"Not expired", ApiToken{ExpiresOn: &future}, true},
+		{"Expired", ApiToken{ExpiresOn: &past}, false},
+		{"Revoked", ApiToken{RevokedOn: &past}, false},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.token.IsUsable(now)
+			if got != test.want {
+				t.Errorf("%d: IsUsable()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_hashToken(t *testing.T) {
+	token, err := generateToken()
+	if err != nil {
+		t.Fatalf("generateToken() error: %v", err)
+	}
+	if hashToken(token) == token || hashToken(token) != hashToken(token) {
+		t.Errorf("hashToken() should be a stable hash of the token")
+	}
+}
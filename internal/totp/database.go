@@ -0,0 +1,150 @@
+package totp
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getTotp(db *sqlx.DB, userId int) (Totp, error) {
+	var t Totp
+	err := db.Get(&t, `SELECT * FROM totp WHERE user_id=$1;`, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Totp{}, nil
+		}
+		return Totp{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return t, nil
+}
+
+// IsEnabled returns true when the user has a confirmed TOTP enrollment.
+func IsEnabled(db *sqlx.DB, userId int) (bool, error) {
+	t, err := getTotp(db, userId)
+	if err != nil {
+		return false, err
+	}
+	return t.IsEnabled(), nil
+}
+
+func GetStatus(db *sqlx.DB, userId int) (Status, error) {
+	t, err := getTotp(db, userId)
+	if err != nil {
+		return Status{}, err
+	}
+	return Status{Enabled: t.IsEnabled(), RecoveryCodesCount: len(t.RecoveryCodeHashes)}, nil
+}
+
+// Enroll stores a new unconfirmed secret, an existing enabled enrollment is never overwritten.
+// The username is only used as the account name shown by authenticator apps.
+func Enroll(db *sqlx.DB, userId int, username string) (EnrollResponse, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return EnrollResponse{}, errors.Wrap(err, "Generating secret")
+	}
+	now := time.Now().UTC()
+	res, err := db.Exec(`
+		INSERT INTO totp (user_id, secret, recovery_code_hashes, last_used_step, enabled_on, created_on, updated_on)
+		VALUES ($1, $2, $3, 0, NULL, $4, $4)
+		ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, updated_on=EXCLUDED.updated_on
+		WHERE totp.enabled_on IS NULL;`,
+		userId, secret, pq.StringArray{}, now)
+	if err != nil {
+		return EnrollResponse{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return EnrollResponse{}, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	if rowsAffected == 0 {
+		return EnrollResponse{}, errors.New("TOTP is already enabled")
+	}
+	return EnrollResponse{Secret: secret, ProvisioningUri: provisioningUri(username, secret)}, nil
+}
+
+// Confirm enables the enrollment when the code matches and returns the recovery codes.
+// When the code doesn't match no recovery codes are returned.
+func Confirm(db *sqlx.DB, userId int, code string) ([]string, error) {
+	t, err := getTotp(db, userId)
+	if err != nil {
+		return nil, err
+	}
+	if t.CreatedOn.IsZero() {
+		return nil, errors.New("TOTP enrollment not started")
+	}
+	if t.IsEnabled() {
+		return nil, errors.New("TOTP is already enabled")
+	}
+	now := time.Now().UTC()
+	step := validateCode(t.Secret, code, now, t.LastUsedStep)
+	if step == 0 {
+		return nil, nil
+	}
+	recoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "Generating recovery codes")
+	}
+	var recoveryCodeHashes pq.StringArray
+	for _, recoveryCode := range recoveryCodes {
+		recoveryCodeHashes = append(recoveryCodeHashes, hashRecoveryCode(recoveryCode))
+	}
+	_, err = db.Exec(`
+		UPDATE totp SET recovery_code_hashes=$1, last_used_step=$2, enabled_on=$3, updated_on=$3 WHERE user_id=$4;`,
+		recoveryCodeHashes, step, now, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return recoveryCodes, nil
+}
+
+func Disable(db *sqlx.DB, userId int) error {
+	_, err := db.Exec(`DELETE FROM totp WHERE user_id=$1;`, userId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// Verify checks a TOTP code or a recovery code for a user with TOTP enabled.
+// Each code can only be used once, used recovery codes are removed.
+func Verify(db *sqlx.DB, userId int, code string) (bool, error) {
+	t, err := getTotp(db, userId)
+	if err != nil {
+		return false, err
+	}
+	if !t.IsEnabled() {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	if step := validateCode(t.Secret, code, now, t.LastUsedStep); step != 0 {
+		// Only succeeds when no concurrent request used the same or a later step.
+		res, err := db.Exec(`UPDATE totp SET last_used_step=$1, updated_on=$2 WHERE user_id=$3 AND last_used_step < $1;`,
+			step, now, userId)
+		if err != nil {
+			return false, errors.Wrap(err, database.SqlExecutionError)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return false, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+		}
+		return rowsAffected == 1, nil
+	}
+	recoveryCodeHash := hashRecoveryCode(code)
+	res, err := db.Exec(`
+		UPDATE totp SET recovery_code_hashes=array_remove(recovery_code_hashes, $1), updated_on=$2
+		WHERE user_id=$3 AND $1 = ANY(recovery_code_hashes);`,
+		recoveryCodeHash, now, userId)
+	if err != nil {
+		return false, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the base32 encoded SHA1 secret "12345678901234567890" from RFC 6238 appendix B.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func Test_generateCode(t *testing.T) {
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{"T=59", 59, "287082"},
+		{"T=1111111109", 1111111109, "081804"},
+		{"T=1234567890", 1234567890, "005924"},
+		{"T=2000000000", 2000000000, "279037"},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := generateCode(rfcSecret, timeStep(time.Unix(test.unix, 0)))
+			if err != nil {
+				t.Fatalf("%d: generateCode() error: %v", i, err)
+			}
+			if got != test.want {
+				t.Errorf("%d: generateCode()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_validateCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	currentStep := timeStep(now)
+	previousCode, _ := generateCode(rfcSecret, currentStep-1)
+	currentCode, _ := generateCode(rfcSecret, currentStep)
+	staleCode, _ := generateCode(rfcSecret, currentStep-2)
+
+	tests := []struct {
+		name         string
+		code         string
+		lastUsedStep int64
+		want         int64
+	}{
+		{"Current code", currentCode, 0, currentStep},
+		{"Previous code within skew", previousCode, 0, currentStep - 1},
+		{"Code outside skew", staleCode, 0, 0},
+		{"Replayed code", currentCode, currentStep, 0},
+		{"Wrong length", "12345", 0, 0},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := validateCode(rfcSecret, test.code, now, test.lastUsedStep)
+			if got != test.want {
+				t.Errorf("%d: validateCode()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_hashRecoveryCode(t *testing.T) {
+	recoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("generateRecoveryCodes() error: %v", err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("generateRecoveryCodes() returned %d codes, want %d", len(recoveryCodes), recoveryCodeCount)
+	}
+	if hashRecoveryCode(recoveryCodes[0]) != hashRecoveryCode(" "+recoveryCodes[0]+" ") {
+		t.Errorf("hashRecoveryCode() should ignore surrounding whitespace")
+	}
+}
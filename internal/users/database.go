@@ -0,0 +1,151 @@
+package users
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func GetUser(db *sqlx.DB, userId int) (User, error) {
+	var u User
+	err := db.Get(&u, `SELECT * FROM torq_user WHERE user_id=$1;`, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, nil
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return u, nil
+}
+
+func GetUserByUsername(db *sqlx.DB, username string) (User, error) {
+	var u User
+	err := db.Get(&u, `SELECT * FROM torq_user WHERE username=$1;`, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, nil
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return u, nil
+}
+
+func getUsers(db *sqlx.DB) ([]User, error) {
+	var users []User
+	err := db.Select(&users, `SELECT * FROM torq_user WHERE status_id != $1 ORDER BY username;`, commons.Deleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []User{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return users, nil
+}
+
+func addUser(db *sqlx.DB, user User, password string) (User, error) {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return User{}, errors.Wrap(err, "Hashing password")
+	}
+	user.PasswordHash = passwordHash
+	user.CreatedOn = time.Now().UTC()
+	user.UpdateOn = user.CreatedOn
+	err = db.QueryRowx(`INSERT INTO torq_user (username, password_hash, role_id, status_id, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING user_id;`,
+		user.Username, user.PasswordHash, user.Role, user.Status, user.CreatedOn, user.UpdateOn).Scan(&user.UserId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return User{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return user, nil
+}
+
+// setUser returns an empty User when the user doesn't exist or was removed.
+func setUser(db *sqlx.DB, user User) (User, error) {
+	user.UpdateOn = time.Now().UTC()
+	res, err := db.Exec(`
+		UPDATE torq_user SET username=$1, role_id=$2, status_id=$3, updated_on=$4 WHERE user_id=$5 AND status_id!=$6;`,
+		user.Username, user.Role, user.Status, user.UpdateOn, user.UserId, commons.Deleted)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return User{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return User{}, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	if rowsAffected == 0 {
+		return User{}, nil
+	}
+	return user, nil
+}
+
+func setUserPassword(db *sqlx.DB, userId int, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return errors.Wrap(err, "Hashing password")
+	}
+	_, err = db.Exec(`UPDATE torq_user SET password_hash=$1, updated_on=$2 WHERE user_id=$3;`,
+		passwordHash, time.Now().UTC(), userId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// removeUser only marks the user as deleted so that references to the user (i.e. in the audit trail) stay intact.
+func removeUser(db *sqlx.DB, userId int) (int64, error) {
+	res, err := db.Exec(`UPDATE torq_user SET status_id=$1, updated_on=$2 WHERE user_id=$3;`,
+		commons.Deleted, time.Now().UTC(), userId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+// Authenticate returns the active user matching the username and password.
+// When the credentials don't match an empty User is returned.
+func Authenticate(db *sqlx.DB, username string, password string) (User, error) {
+	user, err := GetUserByUsername(db, username)
+	if err != nil {
+		return User{}, errors.Wrap(err, "Obtaining user by username")
+	}
+	if user.UserId == 0 || user.Status != commons.Active {
+		return User{}, nil
+	}
+	if !checkPassword(user.PasswordHash, password) {
+		return User{}, nil
+	}
+	return user, nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func checkPassword(passwordHash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+}
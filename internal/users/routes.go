@@ -0,0 +1,137 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterUserRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getUsersHandler(c, db) })
+	r.GET(":userId", func(c *gin.Context) { getUserHandler(c, db) })
+	r.POST("", func(c *gin.Context) { addUserHandler(c, db) })
+	r.PUT("", func(c *gin.Context) { setUserHandler(c, db) })
+	r.DELETE(":userId", func(c *gin.Context) { removeUserHandler(c, db) })
+}
+
+func getUsersHandler(c *gin.Context, db *sqlx.DB) {
+	users, err := getUsers(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting users.")
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func getUserHandler(c *gin.Context, db *sqlx.DB) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse userId in the request.")
+		return
+	}
+	user, err := GetUser(db, userId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting user for userId: %v", userId))
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func addUserHandler(c *gin.Context, db *sqlx.DB) {
+	var ur userRequest
+	if err := c.BindJSON(&ur); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if serverError := validateUserRequest(ur); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	if ur.Password == nil || strings.TrimSpace(*ur.Password) == "" {
+		c.JSON(http.StatusBadRequest, server_errors.SingleFieldError("password", "A password is required."))
+		return
+	}
+	storedUser, err := addUser(db, User{Username: ur.Username, Role: ur.Role, Status: ur.Status}, *ur.Password)
+	audit.Record(db, c, audit.AddUser, ur, storedUser, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding user.")
+		return
+	}
+	c.JSON(http.StatusOK, storedUser)
+}
+
+func setUserHandler(c *gin.Context, db *sqlx.DB) {
+	var ur userRequest
+	if err := c.BindJSON(&ur); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if ur.UserId == 0 {
+		server_errors.SendBadRequest(c, "Failed to find userId in the request.")
+		return
+	}
+	if serverError := validateUserRequest(ur); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	storedUser, err := setUser(db, User{UserId: ur.UserId, Username: ur.Username, Role: ur.Role, Status: ur.Status})
+	if err == nil && storedUser.UserId != 0 && ur.Password != nil && strings.TrimSpace(*ur.Password) != "" {
+		err = setUserPassword(db, ur.UserId, *ur.Password)
+	}
+	audit.Record(db, c, audit.SetUser, ur, storedUser, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting user for userId: %v", ur.UserId))
+		return
+	}
+	if storedUser.UserId == 0 {
+		server_errors.SendUnprocessableEntity(c, "User not found.")
+		return
+	}
+	c.JSON(http.StatusOK, storedUser)
+}
+
+func removeUserHandler(c *gin.Context, db *sqlx.DB) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse userId in the request.")
+		return
+	}
+	count, err := removeUser(db, userId)
+	audit.Record(db, c, audit.RemoveUser, map[string]interface{}{"userId": userId},
+		map[string]interface{}{"count": count}, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing user for userId: %v", userId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v user(s).", count)})
+}
+
+func validateUserRequest(ur userRequest) *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	if strings.TrimSpace(ur.Username) == "" {
+		serverError.AddFieldError("username", "A username is required.")
+	}
+	if strings.EqualFold(strings.TrimSpace(ur.Username), ConfigAdminUsername) {
+		serverError.AddFieldError("username", "This username is reserved for the configured administrator.")
+	}
+	if !ur.Role.IsValid() {
+		serverError.AddFieldError("role", "Unknown role.")
+	}
+	// Users are removed with DELETE so that removing a user is always audited as such.
+	if ur.Status != commons.Active && ur.Status != commons.Inactive {
+		serverError.AddFieldError("status", "The status needs to be active or inactive.")
+	}
+	if len(serverError.Errors.Fields) == 0 {
+		return nil
+	}
+	return serverError
+}
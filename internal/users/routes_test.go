@@ -0,0 +1,33 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func Test_validateUserRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		ur   userRequest
+		want bool
+	}{
+		{"Active user", userRequest{Username: "alice", Role: Operator, Status: commons.Active}, true},
+		{"Inactive user", userRequest{Username: "alice", Role: Viewer, Status: commons.Inactive}, true},
+		{"Username is required", userRequest{Username: " ", Role: Viewer, Status: commons.Active}, false},
+		{"Configured administrator is reserved", userRequest{Username: "Admin", Role: Admin, Status: commons.Active}, false},
+		{"Unknown role", userRequest{Username: "alice", Role: Role(9), Status: commons.Active}, false},
+		{"Users are not deleted by setting the status", userRequest{Username: "alice", Role: Viewer,
+			Status: commons.Deleted}, false},
+		{"Pending status", userRequest{Username: "alice", Role: Viewer, Status: commons.Pending}, false},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := validateUserRequest(test.ur) == nil
+			if got != test.want {
+				t.Errorf("%d: validateUserRequest()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package users
+
+import (
+	"time"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type Role int
+
+const (
+	Viewer = Role(iota)
+	Operator
+	Admin
+)
+
+// ConfigAdminUsername is the user that logs in with the password from the torq.password configuration.
+// It is not stored in the database and always has the Admin role.
+const ConfigAdminUsername = "admin"
+
+func (role Role) IsValid() bool {
+	return role == Viewer || role == Operator || role == Admin
+}
+
+// HasRole returns true when the role is equal to or more privileged than the required role.
+func (role Role) HasRole(requiredRole Role) bool {
+	return role >= requiredRole
+}
+
+func (role Role) String() string {
+	switch role {
+	case Viewer:
+		return "viewer"
+	case Operator:
+		return "operator"
+	case Admin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+type User struct {
+	UserId       int            `json:"userId" db:"user_id"`
+	Username     string         `json:"username" db:"username"`
+	PasswordHash string         `json:"-" db:"password_hash"`
+	Role         Role           `json:"role" db:"role_id"`
+	Status       commons.Status `json:"status" db:"status_id"`
+	CreatedOn    time.Time      `json:"createdOn" db:"created_on"`
+	UpdateOn     time.Time      `json:"updatedOn" db:"updated_on"`
+}
+
+type userRequest struct {
+	UserId   int            `json:"userId"`
+	Username string         `json:"username"`
+	Password *string        `json:"password"`
+	Role     Role           `json:"role"`
+	Status   commons.Status `json:"status"`
+}
@@ -3,6 +3,8 @@ package views
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/openapi"
 )
 
 func RegisterTableViewRoutes(r *gin.RouterGroup, db *sqlx.DB) {
@@ -12,3 +14,30 @@ func RegisterTableViewRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.PATCH("/order", func(c *gin.Context) { updateTableViewOrderHandler(c, db) })
 	r.DELETE(":viewId", func(c *gin.Context) { deleteTableViewsHandler(c, db) })
 }
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {
+			Summary:         "List the table views of a page",
+			Response:        []*TableView{},
+			QueryParameters: []string{"page"},
+		},
+		"POST ": {
+			Summary:     "Add a table view",
+			RequestBody: NewTableView{},
+			Response:    TableView{},
+		},
+		"PUT ": {
+			Summary:     "Update a table view",
+			RequestBody: TableView{},
+			Response:    TableView{},
+		},
+		"PATCH /order": {
+			Summary:     "Update the order of the table views",
+			RequestBody: []TableViewOrder{},
+		},
+		"DELETE :viewId": {
+			Summary: "Remove a table view",
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package webhooks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/pkg/errors"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/internal/event_log"
+)
+
+// dueDelivery is a pending delivery together with the destination of its webhook.
+type dueDelivery struct {
+	Delivery
+	Url    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+func getWebhooks(db *sqlx.DB) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := db.Select(&webhooks, `SELECT * FROM webhook ORDER BY webhook_id;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Webhook{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return webhooks, nil
+}
+
+func getWebhook(db *sqlx.DB, webhookId int) (Webhook, error) {
+	var webhook Webhook
+	err := db.Get(&webhook, `SELECT * FROM webhook WHERE webhook_id=$1;`, webhookId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, nil
+		}
+		return Webhook{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return webhook, nil
+}
+
+func addWebhook(db *sqlx.DB, webhook Webhook) (Webhook, error) {
+	webhook.CreatedOn = time.Now().UTC()
+	webhook.UpdatedOn = webhook.CreatedOn
+	err := db.QueryRowx(`
+		INSERT INTO webhook (name, url, secret, event_types, node_ids, enabled, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING webhook_id;`,
+		webhook.Name, webhook.Url, webhook.Secret, webhook.EventTypes, webhook.NodeIds, webhook.Enabled,
+		webhook.CreatedOn, webhook.UpdatedOn).Scan(&webhook.WebhookId)
+	if err != nil {
+		return Webhook{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return webhook, nil
+}
+
+// setWebhook updates the webhook, WebhookId is 0 when the webhook doesn't exist.
+func setWebhook(db *sqlx.DB, webhook Webhook) (Webhook, error) {
+	var stored Webhook
+	err := db.Get(&stored, `
+		UPDATE webhook SET name=$1, url=$2, secret=$3, event_types=$4, node_ids=$5, enabled=$6, updated_on=$7
+		WHERE webhook_id=$8
+		RETURNING *;`,
+		webhook.Name, webhook.Url, webhook.Secret, webhook.EventTypes, webhook.NodeIds, webhook.Enabled,
+		time.Now().UTC(), webhook.WebhookId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, nil
+		}
+		return Webhook{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return stored, nil
+}
+
+func removeWebhook(db *sqlx.DB, webhookId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM webhook WHERE webhook_id=$1;`, webhookId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return count, nil
+}
+
+func addDelivery(db *sqlx.DB, webhookId int, eventType event_log.EventType, nodeId *int, payload types.JSONText) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(`
+		INSERT INTO webhook_delivery (webhook_id, event_type, node_id, payload, status, attempts, next_attempt_on,
+			created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $6, $6);`,
+		webhookId, eventType, nodeId, payload, Pending, now)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func getDueDeliveries(db *sqlx.DB, limit int) ([]dueDelivery, error) {
+	var deliveries []dueDelivery
+	err := db.Select(&deliveries, `
+		SELECT wd.*, w.url, w.secret
+		FROM webhook_delivery wd
+		JOIN webhook w ON w.webhook_id = wd.webhook_id
+		WHERE wd.status=$1 AND wd.next_attempt_on <= $2
+		ORDER BY wd.next_attempt_on, wd.webhook_delivery_id
+		LIMIT $3;`, Pending, time.Now().UTC(), limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []dueDelivery{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return deliveries, nil
+}
+
+func setDeliveryResult(db *sqlx.DB, delivery Delivery) error {
+	_, err := db.Exec(`
+		UPDATE webhook_delivery
+		SET status=$1, attempts=$2, next_attempt_on=$3, response_status=$4, error=$5, delivered_on=$6, updated_on=$7
+		WHERE webhook_delivery_id=$8;`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptOn, delivery.ResponseStatus, delivery.Error,
+		delivery.DeliveredOn, time.Now().UTC(), delivery.WebhookDeliveryId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func getDeliveries(db *sqlx.DB, webhookId int, status *DeliveryStatus, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := db.Select(&deliveries, `
+		SELECT *
+		FROM webhook_delivery
+		WHERE webhook_id=$1 AND ($2::TEXT IS NULL OR status=$2)
+		ORDER BY webhook_delivery_id DESC
+		LIMIT $3;`, webhookId, status, limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Delivery{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return deliveries, nil
+}
+
+// retryDelivery makes a failed delivery pending again with a fresh set of attempts.
+// WebhookDeliveryId is 0 when the delivery doesn't exist or isn't failed.
+func retryDelivery(db *sqlx.DB, webhookId int, deliveryId int64) (Delivery, error) {
+	var delivery Delivery
+	now := time.Now().UTC()
+	err := db.Get(&delivery, `
+		UPDATE webhook_delivery SET status=$1, attempts=0, next_attempt_on=$2, updated_on=$2
+		WHERE webhook_delivery_id=$3 AND webhook_id=$4 AND status=$5
+		RETURNING *;`,
+		Pending, now, deliveryId, webhookId, Failed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Delivery{}, nil
+		}
+		return Delivery{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return delivery, nil
+}
@@ -0,0 +1,282 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/event_log"
+	"github.com/lncapital/torq/pkg/broadcast"
+)
+
+const (
+	deliveryBatchSize      = 20
+	deliveryTimeout        = 10 * time.Second
+	pollInterval           = 5 * time.Second
+	subscriptionBufferSize = 4096
+)
+
+//nolint:gochecknoglobals
+var (
+	// enabledWebhooks avoids a database query for every broadcast event, it's reloaded when a webhook changes.
+	enabledWebhooks   []Webhook
+	enabledWebhooksMu sync.RWMutex
+	// deliveriesQueued wakes up the delivery worker when new deliveries are stored.
+	deliveriesQueued = make(chan struct{}, 1)
+	httpClient       = &http.Client{Timeout: deliveryTimeout}
+	// missedRanges are the seq ranges of events dropped by the subscription, they're queued from the event log.
+	missedRanges   []seqRange
+	missedRangesMu sync.Mutex
+)
+
+// seqRange holds the seqs after FromSeq and before ToSeq.
+type seqRange struct {
+	FromSeq int64
+	ToSeq   int64
+}
+
+// Start queues a delivery for every broadcast event matching a webhook and delivers them until the context is done.
+func Start(ctx context.Context, db *sqlx.DB, broadcaster broadcast.BroadcastServer) {
+	if err := reloadWebhooks(db); err != nil {
+		log.Error().Err(err).Msg("Loading webhooks")
+	}
+
+	// Queueing a delivery is a database insert, a large buffer absorbs bursts without stalling the broadcaster.
+	// Events that are dropped anyway are queued from the event log when the seq of the next event shows the gap.
+	subscription := broadcaster.Subscribe(broadcast.SubscriptionOptions{
+		Name:       "webhooks",
+		BufferSize: subscriptionBufferSize,
+		Policy:     broadcast.DropOldest,
+	})
+	go func() {
+		var lastSeq int64
+		var dropped uint64
+		// The subscription is closed by the broadcaster when the context is done.
+		for event := range subscription.Events() {
+			if subscription.Dropped() != dropped {
+				log.Warn().Msgf("Webhooks dropped %v events because the subscription buffer was full",
+					subscription.Dropped()-dropped)
+				dropped = subscription.Dropped()
+			}
+			if event.Seq != 0 {
+				if lastSeq != 0 && event.Seq > lastSeq+1 {
+					missedRangesMu.Lock()
+					missedRanges = append(missedRanges, seqRange{FromSeq: lastSeq, ToSeq: event.Seq})
+					missedRangesMu.Unlock()
+				}
+				if event.Seq > lastSeq {
+					lastSeq = event.Seq
+				}
+			}
+			queueEventDeliveries(db, event)
+		}
+	}()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-deliveriesQueued:
+		}
+		queueMissedDeliveries(db)
+		deliverDue(db)
+	}
+}
+
+func reloadWebhooks(db *sqlx.DB) error {
+	webhooks, err := getWebhooks(db)
+	if err != nil {
+		return err
+	}
+	var enabled []Webhook
+	for _, webhook := range webhooks {
+		if webhook.Enabled {
+			enabled = append(enabled, webhook)
+		}
+	}
+	enabledWebhooksMu.Lock()
+	enabledWebhooks = enabled
+	enabledWebhooksMu.Unlock()
+	return nil
+}
+
+func queueEventDeliveries(db *sqlx.DB, event broadcast.Event) {
+	description, ok := event_log.Describe(event)
+	if !ok {
+		return
+	}
+	queueDeliveries(db, description, func() ([]byte, error) { return json.Marshal(event) })
+}
+
+// queueMissedDeliveries queues the deliveries of the events the subscription dropped from the event log.
+// Events that are no longer in the log (or were never stored) are lost, they're counted in the log message.
+func queueMissedDeliveries(db *sqlx.DB) {
+	missedRangesMu.Lock()
+	ranges := missedRanges
+	missedRanges = nil
+	missedRangesMu.Unlock()
+	for _, missed := range ranges {
+		events, _, err := event_log.GetEventsAfter(db, missed.FromSeq)
+		if err != nil {
+			log.Error().Err(err).Msgf("Getting events after seq %v for webhooks", missed.FromSeq)
+			continue
+		}
+		events = eventsBefore(events, missed.ToSeq)
+		for _, event := range events {
+			payload := broadcast.WithSeq(event.Seq, event.Payload)
+			queueDeliveries(db, event.Description(), func() ([]byte, error) { return payload, nil })
+		}
+		if lost := missed.ToSeq - missed.FromSeq - 1 - int64(len(events)); lost > 0 {
+			log.Warn().Msgf("Webhooks lost %v events between seq %v and %v, they're no longer in the event log",
+				lost, missed.FromSeq, missed.ToSeq)
+		}
+	}
+}
+
+// eventsBefore returns the events (ordered by seq) with a seq before toSeq.
+func eventsBefore(events []event_log.LoggedEvent, toSeq int64) []event_log.LoggedEvent {
+	for i, event := range events {
+		if event.Seq >= toSeq {
+			return events[:i]
+		}
+	}
+	return events
+}
+
+// queueDeliveries stores a delivery for every webhook matching the event, getPayload is only called when one does.
+func queueDeliveries(db *sqlx.DB, description event_log.Description, getPayload func() ([]byte, error)) {
+	eventType := description.EventType
+	nodeId := description.NodeId
+	enabledWebhooksMu.RLock()
+	var matching []Webhook
+	for _, webhook := range enabledWebhooks {
+		if webhook.matches(eventType, nodeId) {
+			matching = append(matching, webhook)
+		}
+	}
+	enabledWebhooksMu.RUnlock()
+	if len(matching) == 0 {
+		return
+	}
+
+	payload, err := getPayload()
+	if err != nil {
+		log.Error().Err(err).Msgf("Marshalling %v event for webhooks", eventType)
+		return
+	}
+	var deliveryNodeId *int
+	if nodeId != 0 {
+		deliveryNodeId = &nodeId
+	}
+	for _, webhook := range matching {
+		if err := addDelivery(db, webhook.WebhookId, eventType, deliveryNodeId, payload); err != nil {
+			log.Error().Err(err).Msgf("Queueing %v event for webhookId: %v", eventType, webhook.WebhookId)
+		}
+	}
+	select {
+	case deliveriesQueued <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends the pending deliveries that are due, a full batch is followed by the next batch right away.
+func deliverDue(db *sqlx.DB) {
+	for {
+		deliveries, err := getDueDeliveries(db, deliveryBatchSize)
+		if err != nil {
+			log.Error().Err(err).Msg("Getting due webhook deliveries")
+			return
+		}
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery dueDelivery) {
+				defer wg.Done()
+				result := attemptDelivery(delivery, time.Now().UTC())
+				if err := setDeliveryResult(db, result); err != nil {
+					log.Error().Err(err).Msgf("Storing result of webhookDeliveryId: %v", delivery.WebhookDeliveryId)
+				}
+			}(delivery)
+		}
+		wg.Wait()
+		if len(deliveries) < deliveryBatchSize {
+			return
+		}
+	}
+}
+
+// attemptDelivery posts the delivery and returns it with the outcome and the schedule of the next attempt.
+func attemptDelivery(delivery dueDelivery, now time.Time) Delivery {
+	result := delivery.Delivery
+	result.Attempts++
+	result.Error = nil
+	result.ResponseStatus = nil
+
+	statusCode, err := post(delivery, now)
+	if statusCode != 0 {
+		result.ResponseStatus = &statusCode
+	}
+	if err == nil {
+		result.Status = Delivered
+		result.DeliveredOn = &now
+		return result
+	}
+
+	errorMessage := err.Error()
+	result.Error = &errorMessage
+	if result.Attempts >= maxAttempts {
+		result.Status = Failed
+		return result
+	}
+	result.NextAttemptOn = now.Add(backoff(result.Attempts))
+	return result
+}
+
+func post(delivery dueDelivery, now time.Time) (int, error) {
+	body, err := json.Marshal(deliveryBody{
+		WebhookDeliveryId: delivery.WebhookDeliveryId,
+		WebhookId:         delivery.WebhookId,
+		EventType:         delivery.EventType,
+		NodeId:            delivery.NodeId,
+		CreatedOn:         delivery.CreatedOn,
+		Data:              delivery.Payload,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "Marshalling delivery body")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "Creating request")
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryIdHeader, strconv.FormatInt(delivery.WebhookDeliveryId, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "Posting delivery")
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.Errorf("Unexpected response status: %v", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,249 @@
+package webhooks
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/pkg/openapi"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 1000
+)
+
+func RegisterWebhookRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getWebhooksHandler(c, db) })
+	r.POST("", func(c *gin.Context) { addWebhookHandler(c, db) })
+	r.PUT("", func(c *gin.Context) { setWebhookHandler(c, db) })
+	r.DELETE(":webhookId", func(c *gin.Context) { removeWebhookHandler(c, db) })
+	r.GET(":webhookId/deliveries", func(c *gin.Context) { getDeliveriesHandler(c, db) })
+	r.POST(":webhookId/deliveries/:deliveryId/retry", func(c *gin.Context) { retryDeliveryHandler(c, db) })
+}
+
+func getWebhooksHandler(c *gin.Context, db *sqlx.DB) {
+	webhooks, err := getWebhooks(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting webhooks.")
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func addWebhookHandler(c *gin.Context, db *sqlx.DB) {
+	var wr webhookRequest
+	if err := c.BindJSON(&wr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if serverError := validateWebhookRequest(wr); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	webhook := wr.toWebhook()
+	if wr.Secret == nil || *wr.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, "Generating webhook secret.")
+			return
+		}
+		webhook.Secret = secret
+	}
+	storedWebhook, err := addWebhook(db, webhook)
+	audit.Record(db, c, audit.AddWebhook, wr, storedWebhook, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding webhook.")
+		return
+	}
+	reloadWebhooksAfterChange(db)
+	c.JSON(http.StatusOK, webhookSecretResponse{Webhook: storedWebhook, Secret: storedWebhook.Secret})
+}
+
+func setWebhookHandler(c *gin.Context, db *sqlx.DB) {
+	var wr webhookRequest
+	if err := c.BindJSON(&wr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if serverError := validateWebhookRequest(wr); serverError != nil {
+		c.JSON(http.StatusBadRequest, serverError)
+		return
+	}
+	existing, err := getWebhook(db, wr.WebhookId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting webhook for webhookId: %v", wr.WebhookId))
+		return
+	}
+	if existing.WebhookId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Webhook not found.")
+		return
+	}
+	webhook := wr.toWebhook()
+	if wr.Secret == nil || *wr.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+	storedWebhook, err := setWebhook(db, webhook)
+	audit.Record(db, c, audit.SetWebhook, wr, storedWebhook, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting webhook for webhookId: %v", wr.WebhookId))
+		return
+	}
+	reloadWebhooksAfterChange(db)
+	c.JSON(http.StatusOK, storedWebhook)
+}
+
+func removeWebhookHandler(c *gin.Context, db *sqlx.DB) {
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse webhookId in the request.")
+		return
+	}
+	count, err := removeWebhook(db, webhookId)
+	audit.Record(db, c, audit.RemoveWebhook, map[string]interface{}{"webhookId": webhookId},
+		map[string]interface{}{"count": count}, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing webhook for webhookId: %v", webhookId))
+		return
+	}
+	reloadWebhooksAfterChange(db)
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v webhook(s).", count)})
+}
+
+func getDeliveriesHandler(c *gin.Context, db *sqlx.DB) {
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse webhookId in the request.")
+		return
+	}
+	var status *DeliveryStatus
+	if c.Query("status") != "" {
+		s := DeliveryStatus(c.Query("status"))
+		status = &s
+	}
+	limit := defaultDeliveryLimit
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			server_errors.SendBadRequest(c, fmt.Sprintf("The limit needs to be between 1 and %v.", maxDeliveryLimit))
+			return
+		}
+	}
+	deliveries, err := getDeliveries(db, webhookId, status, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting deliveries for webhookId: %v", webhookId))
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func retryDeliveryHandler(c *gin.Context, db *sqlx.DB) {
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse webhookId in the request.")
+		return
+	}
+	deliveryId, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse deliveryId in the request.")
+		return
+	}
+	delivery, err := retryDelivery(db, webhookId, deliveryId)
+	audit.Record(db, c, audit.RetryWebhookDelivery,
+		map[string]interface{}{"webhookId": webhookId, "webhookDeliveryId": deliveryId}, delivery, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Retrying webhookDeliveryId: %v", deliveryId))
+		return
+	}
+	if delivery.WebhookDeliveryId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Only failed deliveries can be retried.")
+		return
+	}
+	select {
+	case deliveriesQueued <- struct{}{}:
+	default:
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+func reloadWebhooksAfterChange(db *sqlx.DB) {
+	if err := reloadWebhooks(db); err != nil {
+		log.Error().Err(err).Msg("Reloading webhooks")
+	}
+}
+
+func validateWebhookRequest(wr webhookRequest) *server_errors.ServerError {
+	serverError := &server_errors.ServerError{}
+	if wr.Name == "" {
+		serverError.AddFieldError("name", "A name is required.")
+	}
+	u, err := url.Parse(wr.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		serverError.AddFieldError("url", "An absolute http(s) url is required.")
+	}
+	for _, eventType := range wr.EventTypes {
+		if !eventType.IsValid() {
+			serverError.AddFieldError("eventTypes", fmt.Sprintf("Unknown event type: %v", eventType))
+		}
+	}
+	if serverError.Errors.Fields == nil {
+		return nil
+	}
+	return serverError
+}
+
+func (wr webhookRequest) toWebhook() Webhook {
+	eventTypes := pq.StringArray{}
+	for _, eventType := range wr.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+	nodeIds := pq.Int64Array{}
+	nodeIds = append(nodeIds, wr.NodeIds...)
+	webhook := Webhook{
+		WebhookId:  wr.WebhookId,
+		Name:       wr.Name,
+		Url:        wr.Url,
+		EventTypes: eventTypes,
+		NodeIds:    nodeIds,
+		Enabled:    wr.Enabled,
+	}
+	if wr.Secret != nil {
+		webhook.Secret = *wr.Secret
+	}
+	return webhook
+}
+
+func OpenApiOperations() openapi.Operations {
+	return openapi.Operations{
+		"GET ": {Summary: "List the webhooks", Response: []Webhook{}},
+		"POST ": {
+			Summary:     "Add a webhook, the response contains the signing secret",
+			RequestBody: webhookRequest{},
+			Response:    webhookSecretResponse{},
+		},
+		"PUT ": {
+			Summary:     "Update a webhook, the secret is kept when none is given",
+			RequestBody: webhookRequest{},
+			Response:    Webhook{},
+		},
+		"DELETE :webhookId": {Summary: "Remove a webhook and its delivery log"},
+		"GET :webhookId/deliveries": {
+			Summary:         "List the most recent deliveries of a webhook",
+			QueryParameters: []string{"status", "limit"},
+			Response:        []Delivery{},
+		},
+		"POST :webhookId/deliveries/:deliveryId/retry": {
+			Summary:  "Queue a failed delivery again",
+			Response: Delivery{},
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/event_log"
+)
+
+type DeliveryStatus string
+
+const (
+	Pending   = DeliveryStatus("pending")
+	Delivered = DeliveryStatus("delivered")
+	// Failed deliveries exhausted all attempts, they can be retried manually.
+	Failed = DeliveryStatus("failed")
+)
+
+const (
+	maxAttempts  = 10
+	firstBackoff = 30 * time.Second
+	maxBackoff   = 6 * time.Hour
+)
+
+// Headers sent with every delivery.
+const (
+	EventTypeHeader  = "X-Torq-Event"
+	DeliveryIdHeader = "X-Torq-Delivery"
+	TimestampHeader  = "X-Torq-Timestamp"
+	// SignatureHeader holds "sha256=" followed by the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+	SignatureHeader = "X-Torq-Signature"
+)
+
+type Webhook struct {
+	WebhookId  int            `json:"webhookId" db:"webhook_id"`
+	Name       string         `json:"name" db:"name"`
+	Url        string         `json:"url" db:"url"`
+	Secret     string         `json:"-" db:"secret"`
+	EventTypes pq.StringArray `json:"eventTypes" db:"event_types"`
+	NodeIds    pq.Int64Array  `json:"nodeIds" db:"node_ids"`
+	Enabled    bool           `json:"enabled" db:"enabled"`
+	CreatedOn  time.Time      `json:"createdOn" db:"created_on"`
+	UpdatedOn  time.Time      `json:"updatedOn" db:"updated_on"`
+}
+
+type webhookRequest struct {
+	WebhookId int    `json:"webhookId"`
+	Name      string `json:"name" binding:"required"`
+	Url       string `json:"url" binding:"required"`
+	// EventTypes are the event log event types sent to the webhook, empty means every event.
+	EventTypes []event_log.EventType `json:"eventTypes"`
+	NodeIds    []int64               `json:"nodeIds"`
+	Enabled    bool                  `json:"enabled"`
+	// Secret is generated when adding a webhook without one and kept when updating a webhook without one.
+	Secret *string `json:"secret"`
+}
+
+// webhookSecretResponse is the only time the secret is returned.
+type webhookSecretResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type Delivery struct {
+	WebhookDeliveryId int64               `json:"webhookDeliveryId" db:"webhook_delivery_id"`
+	WebhookId         int                 `json:"webhookId" db:"webhook_id"`
+	EventType         event_log.EventType `json:"eventType" db:"event_type"`
+	NodeId            *int                `json:"nodeId" db:"node_id"`
+	Payload           types.JSONText      `json:"payload" db:"payload"`
+	Status            DeliveryStatus      `json:"status" db:"status"`
+	Attempts          int                 `json:"attempts" db:"attempts"`
+	NextAttemptOn     time.Time           `json:"nextAttemptOn" db:"next_attempt_on"`
+	ResponseStatus    *int                `json:"responseStatus" db:"response_status"`
+	Error             *string             `json:"error" db:"error"`
+	CreatedOn         time.Time           `json:"createdOn" db:"created_on"`
+	DeliveredOn       *time.Time          `json:"deliveredOn" db:"delivered_on"`
+	UpdatedOn         time.Time           `json:"updatedOn" db:"updated_on"`
+}
+
+// deliveryBody is the JSON body posted to the webhook url.
+type deliveryBody struct {
+	WebhookDeliveryId int64               `json:"webhookDeliveryId"`
+	WebhookId         int                 `json:"webhookId"`
+	EventType         event_log.EventType `json:"eventType"`
+	NodeId            *int                `json:"nodeId"`
+	CreatedOn         time.Time           `json:"createdOn"`
+	Data              types.JSONText      `json:"data"`
+}
+
+// matches checks the event type and node filters of the webhook, empty filters match everything.
+func (webhook Webhook) matches(eventType event_log.EventType, nodeId int) bool {
+	if !webhook.Enabled {
+		return false
+	}
+	if len(webhook.EventTypes) != 0 {
+		found := false
+		for _, et := range webhook.EventTypes {
+			if event_log.EventType(et) == eventType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(webhook.NodeIds) != 0 {
+		for _, id := range webhook.NodeIds {
+			if int(id) == nodeId {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Sign returns the value of the SignatureHeader for a delivery body sent at timestamp (unix seconds).
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := firstBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+func generateSecret() (string, error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secretBytes), nil
+}
@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/event_log"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		webhook   Webhook
+		eventType event_log.EventType
+		nodeId    int
+		want      bool
+	}{
+		{"No filters", Webhook{Enabled: true}, event_log.InvoiceEvent, 1, true},
+		{"Disabled", Webhook{Enabled: false}, event_log.InvoiceEvent, 1, false},
+		{"Event type matches",
+			Webhook{Enabled: true, EventTypes: pq.StringArray{"invoice", "payment"}}, event_log.PaymentEvent, 1, true},
+		{"Event type doesn't match",
+			Webhook{Enabled: true, EventTypes: pq.StringArray{"invoice"}}, event_log.ChannelEvent, 1, false},
+		{"Node matches", Webhook{Enabled: true, NodeIds: pq.Int64Array{1, 2}}, event_log.InvoiceEvent, 2, true},
+		{"Node doesn't match", Webhook{Enabled: true, NodeIds: pq.Int64Array{1}}, event_log.InvoiceEvent, 2, false},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.webhook.matches(test.eventType, test.nodeId)
+			if got != test.want {
+				t.Errorf("%d: matches()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEventsBefore(t *testing.T) {
+	events := []event_log.LoggedEvent{{Seq: 4}, {Seq: 5}, {Seq: 7}}
+	tests := []struct {
+		toSeq int64
+		want  int
+	}{
+		{4, 0},
+		{6, 2},
+		{7, 2},
+		{8, 3},
+	}
+	for i, test := range tests {
+		if got := eventsBefore(events, test.toSeq); len(got) != test.want {
+			t.Errorf("%d: eventsBefore()\nGot:\n%v\nWant:\n%v\n", i, len(got), test.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("secret", 1660000000, []byte(`{"eventType":"invoice"}`))
+	want := "sha256=0e8a8bb990da3156c1f900582a7cb73e0fd0c3f7a4f239b215064e0aa338ce86"
+	if got != want {
+		t.Errorf("Sign()\nGot:\n%v\nWant:\n%v\n", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{9, 128 * time.Minute},
+		{12, 6 * time.Hour},
+	}
+	for i, test := range tests {
+		got := backoff(test.attempts)
+		if got != test.want {
+			t.Errorf("%d: backoff()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+		}
+	}
+}
+
+func TestAttemptDelivery(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	responseStatus := http.StatusOK
+	var gotSignature, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		if r.Header.Get(TimestampHeader) != strconv.FormatInt(now.Unix(), 10) ||
+			r.Header.Get(EventTypeHeader) != string(event_log.InvoiceEvent) || r.Header.Get(DeliveryIdHeader) != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(responseStatus)
+	}))
+	defer server.Close()
+
+	delivery := dueDelivery{
+		Delivery: Delivery{
+			WebhookDeliveryId: 7,
+			WebhookId:         1,
+			EventType:         event_log.InvoiceEvent,
+			Payload:           []byte(`{"addIndex":1}`),
+			Status:            Pending,
+			CreatedOn:         now,
+		},
+		Url:    server.URL,
+		Secret: "secret",
+	}
+
+	result := attemptDelivery(delivery, now)
+	if result.Status != Delivered || result.Attempts != 1 || result.DeliveredOn == nil {
+		t.Errorf("attemptDelivery() didn't deliver: %+v", result)
+	}
+	wantBody := `{"webhookDeliveryId":7,"webhookId":1,"eventType":"invoice","nodeId":null,` +
+		`"createdOn":"2022-08-01T12:00:00Z","data":{"addIndex":1}}`
+	if gotBody != wantBody {
+		t.Errorf("attemptDelivery() body\nGot:\n%v\nWant:\n%v\n", gotBody, wantBody)
+	}
+	if gotSignature != Sign("secret", now.Unix(), []byte(wantBody)) {
+		t.Errorf("attemptDelivery() signature %v doesn't match the body", gotSignature)
+	}
+
+	responseStatus = http.StatusServiceUnavailable
+	delivery.Attempts = 2
+	result = attemptDelivery(delivery, now)
+	if result.Status != Pending || result.Attempts != 3 || result.Error == nil ||
+		result.ResponseStatus == nil || *result.ResponseStatus != http.StatusServiceUnavailable ||
+		!result.NextAttemptOn.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("attemptDelivery() didn't schedule a retry: %+v", result)
+	}
+
+	delivery.Attempts = maxAttempts - 1
+	result = attemptDelivery(delivery, now)
+	if result.Status != Failed || result.Attempts != maxAttempts {
+		t.Errorf("attemptDelivery() didn't fail after the last attempt: %+v", result)
+	}
+}
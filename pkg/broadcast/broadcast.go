@@ -1,37 +1,196 @@
 package broadcast
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lncapital/torq/pkg/metrics"
 )
 
+// OverflowPolicy decides what happens with an event for a listener that has a full buffer.
+type OverflowPolicy string
+
+const (
+	// DropOldest removes the oldest buffered event to make room for the new one.
+	DropOldest = OverflowPolicy("dropOldest")
+	// DropNewest drops the new event.
+	DropNewest = OverflowPolicy("dropNewest")
+	// Disconnect cancels the subscription, the events channel of the subscription is closed.
+	Disconnect = OverflowPolicy("disconnect")
+)
+
+const DefaultBufferSize = 256
+
+//nolint:gochecknoglobals
+var (
+	listenerCount = metrics.NewGauge("torq_broadcast_listeners",
+		"Number of listeners subscribed to the event broadcast.")
+	droppedEvents = metrics.NewCounterVec("torq_broadcast_dropped_events_total",
+		"Number of events dropped because the buffer of a listener was full.", "listener", "policy")
+	disconnectedListeners = metrics.NewCounterVec("torq_broadcast_disconnected_listeners_total",
+		"Number of listeners disconnected because their buffer was full.", "listener")
+)
+
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch OverflowPolicy(policy) {
+	case DropOldest, DropNewest, Disconnect:
+		return OverflowPolicy(policy), nil
+	}
+	return "", errors.Newf("Unknown overflow policy: %v", policy)
+}
+
+type SubscriptionOptions struct {
+	// Name identifies the listener in the metrics (i.e. websocket or webhooks).
+	Name       string
+	BufferSize int
+	Policy     OverflowPolicy
+}
+
+// Event is what subscriptions receive, it holds what's needed to filter the event without inspecting the payload.
+// Seq is the seq of the event in the event log, it's 0 for events that aren't logged.
+// Type, NodeId and ChannelId are empty for events unknown to the event log (0 means no node or channel).
+type Event struct {
+	Seq       int64
+	Type      string
+	NodeId    int
+	ChannelId int
+	// Payload is one of the event structs or a response to a request (i.e. a payment update).
+	Payload interface{}
+}
+
+// MarshalJSON adds the seq to the JSON object of the payload so clients receive the same structure as before.
+func (e Event) MarshalJSON() ([]byte, error) {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil || e.Seq == 0 {
+		return payload, err
+	}
+	return WithSeq(e.Seq, payload), nil
+}
+
+// WithSeq adds the seq as first field of a JSON object, other JSON values are returned as is.
+func WithSeq(seq int64, payload []byte) []byte {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) < 2 || trimmed[0] != '{' {
+		return payload
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"seq":`)
+	b.WriteString(strconv.FormatInt(seq, 10))
+	rest := bytes.TrimSpace(trimmed[1:])
+	if rest[0] != '}' {
+		b.WriteString(",")
+	}
+	b.Write(rest)
+	return b.Bytes()
+}
+
+// Subscription receives the broadcast events, the broadcaster never waits for it.
+type Subscription struct {
+	options      SubscriptionOptions
+	events       chan Event
+	dropped      uint64
+	disconnected int32
+}
+
+// Events is closed when the subscription is cancelled, disconnected or the broadcaster stops.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events that were dropped for this subscription.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Disconnected returns true when the subscription was cancelled because it couldn't keep up.
+func (s *Subscription) Disconnected() bool {
+	return atomic.LoadInt32(&s.disconnected) == 1
+}
+
+func (s *Subscription) drop() {
+	atomic.AddUint64(&s.dropped, 1)
+	droppedEvents.WithLabelValues(s.options.Name, string(s.options.Policy)).Inc()
+}
+
+// offer adds the event to the buffer, it returns false when the subscription has to be disconnected.
+func (s *Subscription) offer(event Event) bool {
+	select {
+	case s.events <- event:
+		return true
+	default:
+	}
+	switch s.options.Policy {
+	case DropNewest:
+		s.drop()
+	case DropOldest:
+		// The listener can empty the buffer concurrently so neither operation is allowed to block.
+		select {
+		case <-s.events:
+			s.drop()
+		default:
+		}
+		select {
+		case s.events <- event:
+		default:
+			s.drop()
+		}
+	default:
+		s.drop()
+		atomic.StoreInt32(&s.disconnected, 1)
+		disconnectedListeners.WithLabelValues(s.options.Name).Inc()
+		return false
+	}
+	return true
+}
+
 type BroadcastServer interface {
-	Subscribe() <-chan interface{}
-	CancelSubscription(<-chan interface{})
+	Subscribe(options SubscriptionOptions) *Subscription
+	CancelSubscription(subscription *Subscription)
 }
 
 type broadcastServer struct {
-	source         <-chan interface{}
-	listeners      []chan interface{}
-	addListener    chan chan interface{}
-	removeListener chan (<-chan interface{})
+	ctx            context.Context
+	source         <-chan Event
+	listeners      []*Subscription
+	addListener    chan *Subscription
+	removeListener chan *Subscription
 }
 
-func (s *broadcastServer) Subscribe() <-chan interface{} {
-	newListener := make(chan interface{})
-	s.addListener <- newListener
+func (s *broadcastServer) Subscribe(options SubscriptionOptions) *Subscription {
+	if options.BufferSize < 1 {
+		options.BufferSize = DefaultBufferSize
+	}
+	if options.Policy == "" {
+		options.Policy = DropOldest
+	}
+	newListener := &Subscription{options: options, events: make(chan Event, options.BufferSize)}
+	select {
+	case s.addListener <- newListener:
+	case <-s.ctx.Done():
+		close(newListener.events)
+	}
 	return newListener
 }
 
-func (s *broadcastServer) CancelSubscription(channel <-chan interface{}) {
-	s.removeListener <- channel
+func (s *broadcastServer) CancelSubscription(subscription *Subscription) {
+	select {
+	case s.removeListener <- subscription:
+	case <-s.ctx.Done():
+	}
 }
 
-func NewBroadcastServer(ctx context.Context, source <-chan interface{}) BroadcastServer {
+func NewBroadcastServer(ctx context.Context, source <-chan Event) BroadcastServer {
 	service := &broadcastServer{
+		ctx:            ctx,
 		source:         source,
-		listeners:      make([]chan interface{}, 0),
-		addListener:    make(chan chan interface{}),
-		removeListener: make(chan (<-chan interface{})),
+		listeners:      make([]*Subscription, 0),
+		addListener:    make(chan *Subscription),
+		removeListener: make(chan *Subscription),
 	}
 	go service.serve(ctx)
 	return service
@@ -40,10 +199,9 @@ func NewBroadcastServer(ctx context.Context, source <-chan interface{}) Broadcas
 func (s *broadcastServer) serve(ctx context.Context) {
 	defer func() {
 		for _, listener := range s.listeners {
-			if listener != nil {
-				close(listener)
-			}
+			close(listener.events)
 		}
+		listenerCount.Set(0)
 	}()
 
 	for {
@@ -52,28 +210,32 @@ func (s *broadcastServer) serve(ctx context.Context) {
 			return
 		case newListener := <-s.addListener:
 			s.listeners = append(s.listeners, newListener)
+			listenerCount.Set(float64(len(s.listeners)))
 		case listenerToRemove := <-s.removeListener:
-			for i, ch := range s.listeners {
-				if ch == listenerToRemove {
-					s.listeners[i] = s.listeners[len(s.listeners)-1]
-					s.listeners = s.listeners[:len(s.listeners)-1]
-					close(ch)
-					break
-				}
-			}
+			s.remove(listenerToRemove)
 		case val, ok := <-s.source:
 			if !ok {
 				return
 			}
-			for _, listener := range s.listeners {
-				if listener != nil {
-					select {
-					case listener <- val:
-					case <-ctx.Done():
-						return
-					}
+			// Iterate over a copy because disconnected listeners are removed from s.listeners.
+			listeners := append([]*Subscription{}, s.listeners...)
+			for _, listener := range listeners {
+				if !listener.offer(val) {
+					s.remove(listener)
 				}
 			}
 		}
 	}
 }
+
+func (s *broadcastServer) remove(listenerToRemove *Subscription) {
+	for i, listener := range s.listeners {
+		if listener == listenerToRemove {
+			s.listeners[i] = s.listeners[len(s.listeners)-1]
+			s.listeners = s.listeners[:len(s.listeners)-1]
+			close(listener.events)
+			listenerCount.Set(float64(len(s.listeners)))
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package broadcast
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func drain(subscription *Subscription) []interface{} {
+	var events []interface{}
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, event.Payload)
+		default:
+			return events
+		}
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy       OverflowPolicy
+		want         []interface{}
+		dropped      uint64
+		disconnected bool
+	}{
+		{DropOldest, []interface{}{3, 4}, 2, false},
+		{DropNewest, []interface{}{1, 2}, 2, false},
+		{Disconnect, []interface{}{1, 2}, 1, true},
+	}
+	for i, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		source := make(chan Event)
+		server := NewBroadcastServer(ctx, source)
+		slow := server.Subscribe(SubscriptionOptions{Name: "test", BufferSize: 2, Policy: test.policy})
+		fast := server.Subscribe(SubscriptionOptions{Name: "test", BufferSize: 4})
+
+		// Nobody reads slow, the source is never blocked by it.
+		for event := 1; event <= 4; event++ {
+			select {
+			case source <- Event{Payload: event}:
+			case <-time.After(time.Second):
+				t.Fatalf("%d: the broadcaster blocked on a full subscription", i)
+			}
+		}
+		// An extra subscription round trip makes sure the last event was handled.
+		server.CancelSubscription(server.Subscribe(SubscriptionOptions{}))
+
+		got := drain(slow)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: %v events\nGot:\n%v\nWant:\n%v\n", i, test.policy, got, test.want)
+		}
+		if slow.Dropped() != test.dropped || slow.Disconnected() != test.disconnected {
+			t.Errorf("%d: %v dropped and disconnected\nGot:\n%v %v\nWant:\n%v %v\n",
+				i, test.policy, slow.Dropped(), slow.Disconnected(), test.dropped, test.disconnected)
+		}
+		if got := drain(fast); len(got) != 4 {
+			t.Errorf("%d: %v other subscription\nGot:\n%v\nWant:\n%v\n", i, test.policy, got, []int{1, 2, 3, 4})
+		}
+		cancel()
+	}
+}
+
+func TestCancelSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := NewBroadcastServer(ctx, make(chan Event))
+	subscription := server.Subscribe(SubscriptionOptions{})
+	server.CancelSubscription(subscription)
+	if _, ok := <-subscription.Events(); ok {
+		t.Errorf("CancelSubscription() should close the events")
+	}
+
+	cancel()
+	// Cancelling after the broadcaster stopped must not block.
+	done := make(chan struct{})
+	go func() {
+		server.CancelSubscription(server.Subscribe(SubscriptionOptions{}))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("CancelSubscription() blocked after the broadcaster stopped")
+	}
+}
+
+func TestWithSeq(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"nodeId":1}`, `{"seq":5,"nodeId":1}`},
+		{` { "nodeId":1}`, `{"seq":5,"nodeId":1}`},
+		{`{}`, `{"seq":5}`},
+		{`[1]`, `[1]`},
+		{`null`, `null`},
+	}
+	for i, test := range tests {
+		got := string(WithSeq(5, []byte(test.payload)))
+		if got != test.want {
+			t.Errorf("%d: WithSeq()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+		}
+	}
+}
@@ -27,12 +27,14 @@ type NodeGraphEventData struct {
 }
 
 type ChannelGraphEventData struct {
-	Disabled         bool   `json:"disabled"`
-	TimeLockDelta    uint32 `json:"timeLockDelta"`
-	MinHtlc          int64  `json:"minHtlc"`
-	MaxHtlcMsat      uint64 `json:"maxHtlcMsat"`
-	FeeBaseMsat      int64  `json:"feeBaseMsat"`
-	FeeRateMilliMsat int64  `json:"feeRateMilliMsat"`
+	Disabled                bool   `json:"disabled"`
+	TimeLockDelta           uint32 `json:"timeLockDelta"`
+	MinHtlc                 int64  `json:"minHtlc"`
+	MaxHtlcMsat             uint64 `json:"maxHtlcMsat"`
+	FeeBaseMsat             int64  `json:"feeBaseMsat"`
+	FeeRateMilliMsat        int64  `json:"feeRateMilliMsat"`
+	InboundFeeBaseMsat      int64  `json:"inboundFeeBaseMsat"`
+	InboundFeeRateMilliMsat int64  `json:"inboundFeeRateMilliMsat"`
 }
 
 type NodeGraphEvent struct {
@@ -76,3 +78,101 @@ type PeerEvent struct {
 	Type           lnrpc.PeerEvent_EventType `json:"type"`
 	EventPublicKey string                    `json:"eventPublicKey"`
 }
+
+// StreamStatusEvent is sent when a subscription stream of a node starts, fails or stops.
+type StreamStatusEvent struct {
+	EventData
+	Stream    string `json:"stream"`
+	State     string `json:"state"`
+	Restarts  int    `json:"restarts"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// BackfillEvent is sent when a backfill job changes status and after each page it stored, Kind is empty for a
+// status change.
+type BackfillEvent struct {
+	EventData
+	BackfillJobId int    `json:"backfillJobId"`
+	Status        string `json:"status"`
+	Kind          string `json:"kind,omitempty"`
+	Stored        int64  `json:"stored"`
+	Done          bool   `json:"done"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ReconciliationEvent is sent when a reconciliation of a node finished.
+type ReconciliationEvent struct {
+	EventData
+	ReconciliationReportId int    `json:"reconciliationReportId"`
+	Status                 string `json:"status"`
+	Discrepancies          int    `json:"discrepancies"`
+	Fixed                  int    `json:"fixed"`
+	Error                  string `json:"error,omitempty"`
+}
+
+// ChannelBackupEvent is sent when a new version of the channel backup of a node was stored.
+type ChannelBackupEvent struct {
+	EventData
+	ChannelBackupId int `json:"channelBackupId"`
+	Version         int `json:"version"`
+	Channels        int `json:"channels"`
+}
+
+// BlockEvent is sent for every new best block of a node, Lag is the number of blocks the node is behind the other
+// nodes on its chain.
+type BlockEvent struct {
+	EventData
+	Height      uint32    `json:"height"`
+	Hash        string    `json:"hash,omitempty"`
+	BlockTime   time.Time `json:"blockTime"`
+	ChainHeight uint32    `json:"chainHeight"`
+	Lag         uint32    `json:"lag"`
+	Behind      bool      `json:"behind"`
+}
+
+// PendingChannelEvent is sent when a pending channel changed, Resolved is true when the channel is no longer pending.
+type PendingChannelEvent struct {
+	EventData
+	ChannelPoint      string  `json:"channelPoint"`
+	ChannelId         int     `json:"channelId,omitempty"`
+	State             string  `json:"state"`
+	LimboBalance      int64   `json:"limboBalance"`
+	MaturityHeight    *uint32 `json:"maturityHeight,omitempty"`
+	BlocksTilMaturity *int32  `json:"blocksTilMaturity,omitempty"`
+	SweepState        string  `json:"sweepState,omitempty"`
+	AnchorState       string  `json:"anchorState,omitempty"`
+	Resolved          bool    `json:"resolved"`
+}
+
+// AutoFeeEvent is sent for every decision of the fee automation that changes or would change the policy of a channel.
+type AutoFeeEvent struct {
+	EventData
+	AutoFeeDecisionId int    `json:"autoFeeDecisionId"`
+	ChannelId         int    `json:"channelId"`
+	Status            string `json:"status"`
+	FeeRatePpm        int64  `json:"feeRatePpm"`
+	NewFeeRatePpm     int64  `json:"newFeeRatePpm"`
+	BaseFeeMsat       int64  `json:"baseFeeMsat"`
+	NewBaseFeeMsat    int64  `json:"newBaseFeeMsat"`
+	MaxHtlcMsat       uint64 `json:"maxHtlcMsat"`
+	NewMaxHtlcMsat    uint64 `json:"newMaxHtlcMsat"`
+	Error             string `json:"error,omitempty"`
+}
+
+// RebalanceEvent is sent after every attempt of a rebalance and when the rebalance finished, the attempt fields are
+// empty for the latter.
+type RebalanceEvent struct {
+	EventData
+	RebalanceId       int    `json:"rebalanceId"`
+	Status            string `json:"status"`
+	AmountMsat        int64  `json:"amountMsat"`
+	RebalancedMsat    int64  `json:"rebalancedMsat"`
+	FeePaidMsat       int64  `json:"feePaidMsat"`
+	Attempts          int    `json:"attempts"`
+	Error             string `json:"error,omitempty"`
+	AttemptStatus     string `json:"attemptStatus,omitempty"`
+	OutgoingChannelId int    `json:"outgoingChannelId,omitempty"`
+	IncomingChannelId int    `json:"incomingChannelId,omitempty"`
+	AttemptAmountMsat int64  `json:"attemptAmountMsat,omitempty"`
+	AttemptFeeMsat    int64  `json:"attemptFeeMsat,omitempty"`
+}
@@ -0,0 +1,523 @@
+package cln
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes of pay, see the lightning-pay documentation.
+const (
+	payRhashAlreadyUsed     = 201
+	payUnparseableOnionFail = 202
+	payDestinationPermFail  = 203
+	payRouteNotFound        = 205
+	payRouteTooExpensive    = 206
+	payInvoiceExpired       = 207
+	payStoppedRetrying      = 210
+)
+
+func (l *Lightning) GetInfo(ctx context.Context, in *lnrpc.GetInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.GetInfoResponse, error) {
+
+	info, err := l.client.GetInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining information from Core Lightning")
+	}
+	network := info.Network
+	if network == "bitcoin" {
+		network = "mainnet"
+	}
+	return &lnrpc.GetInfoResponse{
+		IdentityPubkey:      info.Id,
+		Alias:               info.Alias,
+		Color:               "#" + info.Color,
+		Version:             info.Version,
+		BlockHeight:         info.BlockHeight,
+		NumPeers:            info.NumPeers,
+		NumPendingChannels:  info.NumPendingChannels,
+		NumActiveChannels:   info.NumActiveChannels,
+		NumInactiveChannels: info.NumInactiveChannels,
+		SyncedToChain:       info.WarningBitcoindSync == "" && info.WarningLightningdSync == "",
+		SyncedToGraph:       info.WarningLightningdSync == "",
+		Chains:              []*lnrpc.Chain{{Chain: "bitcoin", Network: network}},
+	}, nil
+}
+
+// WalletBalance sums the unspent wallet outputs, reserved outputs are locked.
+func (l *Lightning) WalletBalance(ctx context.Context, in *lnrpc.WalletBalanceRequest,
+	opts ...grpc.CallOption) (*lnrpc.WalletBalanceResponse, error) {
+
+	outputs, err := l.client.ListFunds(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing funds")
+	}
+	resp := &lnrpc.WalletBalanceResponse{}
+	for _, output := range outputs {
+		switch {
+		case output.Status == "spent":
+			continue
+		case output.Reserved:
+			resp.LockedBalance += output.AmountMsat.Sat()
+		case output.Status == "confirmed":
+			resp.ConfirmedBalance += output.AmountMsat.Sat()
+		default:
+			resp.UnconfirmedBalance += output.AmountMsat.Sat()
+		}
+		resp.TotalBalance += output.AmountMsat.Sat()
+	}
+	return resp, nil
+}
+
+// feeRate converts the LND fee options, a fee rate in sat/vbyte or a confirmation target in blocks.
+func feeRate(satPerVbyte uint64, targetConf int32) string {
+	switch {
+	case satPerVbyte != 0:
+		return fmt.Sprintf("%vperkb", satPerVbyte*1000)
+	case targetConf != 0:
+		return fmt.Sprintf("%vblocks", targetConf)
+	}
+	return ""
+}
+
+func minConf(minConfs int32, spendUnconfirmed bool) *int32 {
+	if spendUnconfirmed {
+		zero := int32(0)
+		return &zero
+	}
+	if minConfs != 0 {
+		return &minConfs
+	}
+	return nil
+}
+
+// OpenChannel broadcasts the funding transaction right away, the stream only sends the pending update because the
+// channel event subscription picks up the channel once it's open.
+// The minimum HTLC and remote CSV delay are node wide settings on Core Lightning and are ignored.
+func (l *Lightning) OpenChannel(ctx context.Context, in *lnrpc.OpenChannelRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_OpenChannelClient, error) {
+
+	announce := !in.Private
+	req := FundChannelRequest{
+		Id:       hex.EncodeToString(in.NodePubkey),
+		Amount:   in.LocalFundingAmount,
+		FeeRate:  feeRate(in.SatPerVbyte, in.TargetConf),
+		Announce: &announce,
+		MinConf:  minConf(in.MinConfs, in.SpendUnconfirmed),
+		CloseTo:  in.CloseAddress,
+	}
+	if in.PushSat != 0 {
+		pushMsat := Msat(in.PushSat * 1000)
+		req.PushMsat = &pushMsat
+	}
+	resp, err := l.client.FundChannel(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Funding channel")
+	}
+	chanPoint, err := lnrpcChannelPoint(resp.Txid, resp.Outnum)
+	if err != nil {
+		return nil, err
+	}
+	return &openChannelStream{sliceStream(ctx, &lnrpc.OpenStatusUpdate{
+		Update: &lnrpc.OpenStatusUpdate_ChanPending{ChanPending: &lnrpc.PendingUpdate{
+			Txid:        chanPoint.GetFundingTxidBytes(),
+			OutputIndex: chanPoint.OutputIndex,
+		}},
+	})}, nil
+}
+
+func (l *Lightning) BatchOpenChannel(ctx context.Context, in *lnrpc.BatchOpenChannelRequest,
+	opts ...grpc.CallOption) (*lnrpc.BatchOpenChannelResponse, error) {
+
+	req := MultiFundChannelRequest{
+		FeeRate: feeRate(uint64(in.SatPerVbyte), in.TargetConf),
+		MinConf: minConf(in.MinConfs, in.SpendUnconfirmed),
+	}
+	for _, channel := range in.Channels {
+		announce := !channel.Private
+		destination := MultiFundChannelDestination{
+			Id:       hex.EncodeToString(channel.NodePubkey),
+			Amount:   channel.LocalFundingAmount,
+			Announce: &announce,
+			CloseTo:  channel.CloseAddress,
+		}
+		if channel.PushSat != 0 {
+			pushMsat := Msat(channel.PushSat * 1000)
+			destination.PushMsat = &pushMsat
+		}
+		req.Destinations = append(req.Destinations, destination)
+	}
+	resp, err := l.client.MultiFundChannel(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Funding channels")
+	}
+	result := &lnrpc.BatchOpenChannelResponse{}
+	for _, channelId := range resp.ChannelIds {
+		chanPoint, err := lnrpcChannelPoint(resp.Txid, channelId.Outnum)
+		if err != nil {
+			return nil, err
+		}
+		result.PendingChannels = append(result.PendingChannels, &lnrpc.PendingUpdate{
+			Txid:        chanPoint.GetFundingTxidBytes(),
+			OutputIndex: chanPoint.OutputIndex,
+		})
+	}
+	return result, nil
+}
+
+// channelPointString returns the txid:index notation of an LND channel point.
+func channelPointString(chanPoint *lnrpc.ChannelPoint) (string, error) {
+	if chanPoint == nil {
+		return "", errors.New("Channel point is missing")
+	}
+	txid := chanPoint.GetFundingTxidStr()
+	if txid == "" {
+		hash, err := chainhash.NewHash(chanPoint.GetFundingTxidBytes())
+		if err != nil {
+			return "", errors.Wrap(err, "Parsing funding txid")
+		}
+		txid = hash.String()
+	}
+	return channelPoint(txid, chanPoint.OutputIndex), nil
+}
+
+// CloseChannel returns once the closing transaction is broadcast, the stream only sends the pending update.
+// A force close is a mutual close that turns unilateral when the peer doesn't respond within a second.
+func (l *Lightning) CloseChannel(ctx context.Context, in *lnrpc.CloseChannelRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_CloseChannelClient, error) {
+
+	chanPoint, err := channelPointString(in.ChannelPoint)
+	if err != nil {
+		return nil, err
+	}
+	channelId, err := l.client.ChannelIdByChannelPoint(ctx, chanPoint)
+	if err != nil {
+		return nil, err
+	}
+	req := CloseRequest{Id: channelId, Destination: in.DeliveryAddress}
+	if in.Force {
+		unilateralTimeout := uint32(1)
+		req.UnilateralTimeout = &unilateralTimeout
+	}
+	if rate := feeRate(in.SatPerVbyte, in.TargetConf); rate != "" {
+		req.FeeRange = []string{rate, rate}
+	}
+	resp, err := l.client.Close(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Closing channel")
+	}
+	pending := &lnrpc.PendingUpdate{}
+	if resp.Txid != "" {
+		txid, err := chainhash.NewHashFromStr(resp.Txid)
+		if err != nil {
+			return nil, errors.Wrap(err, "Parsing closing txid")
+		}
+		pending.Txid = txid.CloneBytes()
+	}
+	return &closeChannelStream{sliceStream(ctx, &lnrpc.CloseStatusUpdate{
+		Update: &lnrpc.CloseStatusUpdate_ClosePending{ClosePending: pending},
+	})}, nil
+}
+
+// UpdateChannelPolicy sets the fees and HTLC limits like LND does, the base fee and fee rate are always set.
+// The time lock delta is a node wide setting (cltv-delta) on Core Lightning and is left untouched.
+func (l *Lightning) UpdateChannelPolicy(ctx context.Context, in *lnrpc.PolicyUpdateRequest,
+	opts ...grpc.CallOption) (*lnrpc.PolicyUpdateResponse, error) {
+
+	baseFee := Msat(in.BaseFeeMsat)
+	feePpm := in.FeeRatePpm
+	req := SetChannelRequest{Id: "all", FeeBase: &baseFee, FeePpm: &feePpm}
+	if in.GetChanPoint() != nil {
+		chanPoint, err := channelPointString(in.GetChanPoint())
+		if err != nil {
+			return nil, err
+		}
+		req.Id, err = l.client.ChannelIdByChannelPoint(ctx, chanPoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if in.MinHtlcMsatSpecified {
+		minHtlc := Msat(in.MinHtlcMsat)
+		req.HtlcMinimum = &minHtlc
+	}
+	if in.MaxHtlcMsat != 0 {
+		maxHtlc := Msat(in.MaxHtlcMsat)
+		req.HtlcMaximum = &maxHtlc
+	}
+	if _, err := l.client.SetChannel(ctx, req); err != nil {
+		return nil, errors.Wrap(err, "Setting channel")
+	}
+	return &lnrpc.PolicyUpdateResponse{}, nil
+}
+
+func (l *Lightning) ListPeers(ctx context.Context, in *lnrpc.ListPeersRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPeersResponse, error) {
+
+	peers, err := l.client.ListPeers(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing peers")
+	}
+	resp := &lnrpc.ListPeersResponse{}
+	for _, peer := range peers {
+		if !peer.Connected {
+			continue
+		}
+		p := &lnrpc.Peer{PubKey: peer.Id}
+		if len(peer.NetAddr) != 0 {
+			p.Address = peer.NetAddr[0]
+		}
+		resp.Peers = append(resp.Peers, p)
+	}
+	return resp, nil
+}
+
+func (l *Lightning) ConnectPeer(ctx context.Context, in *lnrpc.ConnectPeerRequest,
+	opts ...grpc.CallOption) (*lnrpc.ConnectPeerResponse, error) {
+
+	if in.Addr == nil {
+		return nil, errors.New("Peer address is missing")
+	}
+	if err := l.client.Connect(ctx, ConnectRequest{Id: in.Addr.Pubkey + "@" + in.Addr.Host}); err != nil {
+		return nil, errors.Wrap(err, "Connecting peer")
+	}
+	return &lnrpc.ConnectPeerResponse{}, nil
+}
+
+// AddInvoice creates an invoice, Core Lightning needs a unique label and adds route hints for private channels by
+// itself. AMP invoices don't exist on Core Lightning.
+func (l *Lightning) AddInvoice(ctx context.Context, in *lnrpc.Invoice,
+	opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error) {
+
+	if in.IsAmp {
+		return nil, status.Error(codes.Unimplemented, "AMP invoices are not supported by Core Lightning")
+	}
+	req := InvoiceRequest{
+		AmountMsat:  "any",
+		Label:       fmt.Sprintf("torq-%v", time.Now().UnixNano()),
+		Description: in.Memo,
+		Preimage:    hex.EncodeToString(in.RPreimage),
+	}
+	switch {
+	case in.ValueMsat != 0:
+		req.AmountMsat = in.ValueMsat
+	case in.Value != 0:
+		req.AmountMsat = in.Value * 1000
+	}
+	if in.Expiry != 0 {
+		req.Expiry = &in.Expiry
+	}
+	if in.FallbackAddr != "" {
+		req.Fallbacks = []string{in.FallbackAddr}
+	}
+	resp, err := l.client.Invoice(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating invoice")
+	}
+	return &lnrpc.AddInvoiceResponse{
+		RHash:          decodeHex(resp.PaymentHash),
+		PaymentRequest: resp.Bolt11,
+		AddIndex:       resp.CreatedIndex,
+		PaymentAddr:    decodeHex(resp.PaymentSecret),
+	}, nil
+}
+
+// SendPaymentV2 pays a bolt11 invoice. pay only returns once the payment succeeded or failed for good so the stream
+// sends the in flight status and then blocks until pay returns.
+func (l *Lightning) SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client, error) {
+
+	if in.PaymentRequest == "" {
+		return nil, status.Error(codes.Unimplemented, "InvalidPaymentRequest: Core Lightning only pays invoices")
+	}
+	req := PayRequest{Bolt11: in.PaymentRequest}
+	if in.TimeoutSeconds != 0 {
+		req.RetryFor = &in.TimeoutSeconds
+	}
+	switch {
+	case in.AmtMsat != 0:
+		amount := Msat(in.AmtMsat)
+		req.AmountMsat = &amount
+	case in.Amt != 0:
+		amount := Msat(in.Amt * 1000)
+		req.AmountMsat = &amount
+	}
+	switch {
+	case in.FeeLimitMsat != 0:
+		maxFee := Msat(in.FeeLimitMsat)
+		req.MaxFee = &maxFee
+	case in.FeeLimitSat != 0:
+		maxFee := Msat(in.FeeLimitSat * 1000)
+		req.MaxFee = &maxFee
+	}
+
+	creationTime := time.Now()
+	inFlight := &lnrpc.Payment{
+		PaymentRequest: in.PaymentRequest,
+		Status:         lnrpc.Payment_IN_FLIGHT,
+		CreationDate:   creationTime.Unix(),
+		CreationTimeNs: creationTime.UnixNano(),
+	}
+	if req.AmountMsat != nil {
+		inFlight.ValueMsat = req.AmountMsat.Int64()
+	}
+	stream := &paymentStream{pollStream{ctx: ctx}}
+	paid := false
+	stream.poll = func(ctx context.Context) ([]interface{}, error) {
+		if paid {
+			return nil, io.EOF
+		}
+		paid = true
+		// Leave pay enough time to give up on its own.
+		payCtx, cancel := context.WithTimeout(ctx, time.Duration(in.TimeoutSeconds)*time.Second+time.Minute)
+		defer cancel()
+		resp, err := l.client.Pay(payCtx, req)
+		payment, err := lnrpcPayResult(&lnrpc.Payment{
+			PaymentRequest: inFlight.PaymentRequest,
+			CreationDate:   inFlight.CreationDate,
+			CreationTimeNs: inFlight.CreationTimeNs,
+			ValueMsat:      inFlight.ValueMsat,
+		}, resp, err)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{payment}, nil
+	}
+	stream.queue = []interface{}{inFlight}
+	return stream, nil
+}
+
+// lnrpcPayResult converts the result of pay, payment failures are a failed payment and not an error like with LND.
+func lnrpcPayResult(payment *lnrpc.Payment, resp PayResponse, err error) (*lnrpc.Payment, error) {
+	if err != nil {
+		var clnErr *Error
+		if !errors.As(err, &clnErr) {
+			return nil, errors.Wrap(err, "Paying invoice")
+		}
+		payment.Status = lnrpc.Payment_FAILED
+		switch clnErr.Code {
+		case payRhashAlreadyUsed:
+			return nil, status.Error(codes.AlreadyExists, clnErr.Message)
+		case payUnparseableOnionFail, payInvoiceExpired:
+			return nil, status.Error(codes.InvalidArgument, "InvalidPaymentRequest: "+clnErr.Message)
+		case payDestinationPermFail:
+			payment.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_INCORRECT_PAYMENT_DETAILS
+		case payRouteNotFound, payRouteTooExpensive:
+			payment.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE
+		case payStoppedRetrying:
+			payment.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_TIMEOUT
+		default:
+			payment.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR
+		}
+		return payment, nil
+	}
+	payment.Status = lnrpc.Payment_SUCCEEDED
+	payment.PaymentHash = resp.PaymentHash
+	payment.PaymentPreimage = resp.PaymentPreimage
+	payment.ValueMsat = resp.AmountMsat.Int64()
+	payment.ValueSat = resp.AmountMsat.Sat()
+	payment.Value = resp.AmountMsat.Sat()
+	payment.FeeMsat = resp.AmountSentMsat.Int64() - resp.AmountMsat.Int64()
+	payment.FeeSat = payment.FeeMsat / 1000
+	payment.Fee = payment.FeeSat
+	return payment, nil
+}
+
+func (l *Lightning) SendCoins(ctx context.Context, in *lnrpc.SendCoinsRequest,
+	opts ...grpc.CallOption) (*lnrpc.SendCoinsResponse, error) {
+
+	req := WithdrawRequest{
+		Destination: in.Addr,
+		Satoshi:     in.Amount,
+		FeeRate:     feeRate(in.SatPerVbyte, in.TargetConf),
+		MinConf:     minConf(in.MinConfs, in.SpendUnconfirmed),
+	}
+	if in.SendAll {
+		req.Satoshi = "all"
+	}
+	resp, err := l.client.Withdraw(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Withdrawing")
+	}
+	return &lnrpc.SendCoinsResponse{Txid: resp.Txid}, nil
+}
+
+// NextAddr creates a new address, Core Lightning only creates native segwit and taproot addresses.
+func (l *Lightning) NextAddr(ctx context.Context, in *walletrpc.AddrRequest,
+	opts ...grpc.CallOption) (*walletrpc.AddrResponse, error) {
+
+	switch in.Type {
+	case walletrpc.AddressType_UNKNOWN, walletrpc.AddressType_WITNESS_PUBKEY_HASH:
+		resp, err := l.client.NewAddr(ctx, "bech32")
+		if err != nil {
+			return nil, errors.Wrap(err, "Creating address")
+		}
+		return &walletrpc.AddrResponse{Addr: resp.Bech32}, nil
+	case walletrpc.AddressType_TAPROOT_PUBKEY:
+		resp, err := l.client.NewAddr(ctx, "p2tr")
+		if err != nil {
+			return nil, errors.Wrap(err, "Creating address")
+		}
+		return &walletrpc.AddrResponse{Addr: resp.P2tr}, nil
+	}
+	return nil, status.Errorf(codes.Unimplemented, "Address type %v is not supported by Core Lightning", in.Type)
+}
+
+// SignMessage signs with the node key, the zbase32 signature of Core Lightning is the same as the one of LND.
+func (l *Lightning) SignMessage(ctx context.Context, in *lnrpc.SignMessageRequest,
+	opts ...grpc.CallOption) (*lnrpc.SignMessageResponse, error) {
+
+	if in.SingleHash {
+		return nil, status.Error(codes.Unimplemented, "Single hash signatures are not supported by Core Lightning")
+	}
+	resp, err := l.client.SignMessage(ctx, string(in.Msg))
+	if err != nil {
+		return nil, errors.Wrap(err, "Signing message")
+	}
+	return &lnrpc.SignMessageResponse{Signature: resp.Zbase}, nil
+}
+
+func (l *Lightning) VerifyMessage(ctx context.Context, in *lnrpc.VerifyMessageRequest,
+	opts ...grpc.CallOption) (*lnrpc.VerifyMessageResponse, error) {
+
+	resp, err := l.client.CheckMessage(ctx, string(in.Msg), in.Signature)
+	if err != nil {
+		var clnErr *Error
+		if errors.As(err, &clnErr) {
+			// the public key of the signature is unknown, so it's not from a node in the graph
+			return &lnrpc.VerifyMessageResponse{}, nil
+		}
+		return nil, errors.Wrap(err, "Verifying message")
+	}
+	return &lnrpc.VerifyMessageResponse{Valid: resp.Verified, Pubkey: resp.Pubkey}, nil
+}
+
+// Core Lightning keeps its own emergency recovery file, it has no static channel backups in the LND format.
+func (l *Lightning) ExportAllChannelBackups(ctx context.Context, in *lnrpc.ChanBackupExportRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChanBackupSnapshot, error) {
+
+	return nil, status.Error(codes.Unimplemented, "Static channel backups are not supported by Core Lightning")
+}
+
+func (l *Lightning) VerifyChanBackup(ctx context.Context, in *lnrpc.ChanBackupSnapshot,
+	opts ...grpc.CallOption) (*lnrpc.VerifyChanBackupResponse, error) {
+
+	return nil, status.Error(codes.Unimplemented, "Static channel backups are not supported by Core Lightning")
+}
+
+func (l *Lightning) RestoreChannelBackups(ctx context.Context, in *lnrpc.RestoreChanBackupRequest,
+	opts ...grpc.CallOption) (*lnrpc.RestoreBackupResponse, error) {
+
+	return nil, status.Error(codes.Unimplemented, "Static channel backups are not supported by Core Lightning")
+}
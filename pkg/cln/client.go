@@ -0,0 +1,146 @@
+package cln
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Core Lightning is reached through its REST plugin (clnrest). Every RPC method is exposed as POST /v1/<method>
+// with the same parameters and results as the JSON-RPC and gRPC interfaces, authenticated with a rune.
+// The created_index and updated_index pagination used for ingestion requires Core Lightning 23.11 or newer.
+//
+// The CLN backend was requested over cln-grpc (mTLS with the client certificate and key of the node). Only Call
+// depends on the transport, the methods and their results are shared with cln-grpc, so it can be replaced by a
+// cln-grpc client generated from node.proto without changing the Lightning implementation. Until then nodes need
+// clnrest enabled and a rune.
+
+const requestTimeout = 2 * time.Minute
+
+// Error is an error returned by Core Lightning itself (i.e. an unknown invoice), not a transport error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Core Lightning error %v: %v", e.Code, e.Message)
+}
+
+// Client calls the RPC methods of a single Core Lightning node.
+type Client struct {
+	baseUrl    string
+	rune       string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the clnrest address (host:port), the rune is sent with every request.
+// The certificate of clnrest is verified against the given PEM CA certificate (i.e. the ca.pem of the node).
+func NewClient(address string, caCertificate []byte, rune string) (*Client, error) {
+	if strings.TrimSpace(address) == "" {
+		return nil, errors.New("Core Lightning address is missing")
+	}
+	if strings.TrimSpace(rune) == "" {
+		return nil, errors.New("Core Lightning rune is missing")
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCertificate) {
+		return nil, errors.New("Parsing the Core Lightning CA certificate")
+	}
+	baseUrl := address
+	if !strings.HasPrefix(baseUrl, "http://") && !strings.HasPrefix(baseUrl, "https://") {
+		baseUrl = "https://" + baseUrl
+	}
+	return newClient(strings.TrimSuffix(baseUrl, "/"), strings.TrimSpace(rune), &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS12},
+		},
+	}), nil
+}
+
+func newClient(baseUrl string, rune string, httpClient *http.Client) *Client {
+	return &Client{baseUrl: baseUrl, rune: rune, httpClient: httpClient}
+}
+
+// Call invokes an RPC method, params is marshalled as the JSON request and the JSON response is decoded in result.
+// Without a deadline on the context the request times out after requestTimeout.
+func (client *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return errors.Wrapf(err, "Marshalling %v request", method)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.baseUrl+"/v1/"+method, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "Creating %v request", method)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Rune", client.rune)
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "Calling %v", method)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "Reading %v response", method)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		clnErr := &Error{}
+		if json.Unmarshal(respBody, clnErr) == nil && clnErr.Message != "" {
+			return errors.WithStack(clnErr)
+		}
+		return errors.Newf("Calling %v: %v %v", method, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err = json.Unmarshal(respBody, result); err != nil {
+		return errors.Wrapf(err, "Decoding %v response", method)
+	}
+	return nil
+}
+
+// Msat is an amount in millisatoshis, older Core Lightning versions encode it as a "1000msat" string.
+type Msat uint64
+
+func (m *Msat) UnmarshalJSON(data []byte) error {
+	value := strings.TrimSuffix(strings.Trim(string(data), `"`), "msat")
+	if value == "" || value == "null" {
+		*m = 0
+		return nil
+	}
+	amount, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "Parsing msat amount %v", string(data))
+	}
+	*m = Msat(amount)
+	return nil
+}
+
+func (m Msat) Int64() int64 {
+	return int64(m)
+}
+
+func (m Msat) Sat() int64 {
+	return int64(m / 1000)
+}
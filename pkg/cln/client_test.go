@@ -0,0 +1,136 @@
+package cln
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+)
+
+// recordedNode stands in for clnrest, it replays recorded responses per method in the order they were recorded.
+// The last response of a method is repeated once the recording runs out.
+type recordedNode struct {
+	t         *testing.T
+	mu        sync.Mutex
+	responses map[string][]string
+	requests  map[string][]string
+}
+
+func newRecordedNode(t *testing.T, responses map[string][]string) (*recordedNode, *Client) {
+	node := &recordedNode{t: t, responses: responses, requests: make(map[string][]string)}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	return node, newClient(server.URL, "test-rune", server.Client())
+}
+
+func (n *recordedNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if r.Header.Get("Rune") != "test-rune" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":1501,"message":"Not authorized: Not a valid rune"}`))
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/v1/")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		n.t.Fatalf("Reading request body: %v", err)
+	}
+	n.requests[method] = append(n.requests[method], string(body))
+	recorded := n.responses[method]
+	if len(recorded) == 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"code":-32601,"message":"Unknown command '` + method + `'"}`))
+		return
+	}
+	response := recorded[0]
+	if len(recorded) > 1 {
+		n.responses[method] = recorded[1:]
+	}
+	if strings.Contains(response, `"code"`) && strings.Contains(response, `"message"`) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_, _ = w.Write([]byte(response))
+}
+
+func (n *recordedNode) requestsOf(method string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests[method]
+}
+
+func TestMsatUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Msat
+	}{
+		{`1000`, 1000},
+		{`"1000msat"`, 1000},
+		{`"0msat"`, 0},
+		{`null`, 0},
+	}
+	for i, test := range tests {
+		var got Msat
+		if err := json.Unmarshal([]byte(test.input), &got); err != nil {
+			t.Fatalf("%d: json.Unmarshal(%v) error: %v", i, test.input, err)
+		}
+		if got != test.want {
+			t.Errorf("%d: json.Unmarshal(%v)\nGot:\n%v\nWant:\n%v\n", i, test.input, got, test.want)
+		}
+	}
+	if got := Msat(1999).Sat(); got != 1 {
+		t.Errorf("Msat(1999).Sat()\nGot:\n%v\nWant:\n%v\n", got, 1)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	_, client := newRecordedNode(t, map[string][]string{
+		"pay": {`{"code":210,"message":"Ran out of routes to try after 3 attempts"}`},
+	})
+	_, err := client.Pay(context.Background(), PayRequest{Bolt11: "lnbc1"})
+	var clnErr *Error
+	if !errors.As(err, &clnErr) {
+		t.Fatalf("Pay() error is not a Core Lightning error: %v", err)
+	}
+	if clnErr.Code != 210 {
+		t.Errorf("Pay() error code\nGot:\n%v\nWant:\n%v\n", clnErr.Code, 210)
+	}
+
+	_, err = client.GetInfo(context.Background())
+	if !errors.As(err, &clnErr) || clnErr.Code != -32601 {
+		t.Errorf("GetInfo() on unknown method\nGot:\n%v\nWant:\n%v\n", err, "Unknown command")
+	}
+}
+
+func TestShortChannelId(t *testing.T) {
+	tests := []struct {
+		shortChannelId string
+		lnd            uint64
+	}{
+		{"", 0},
+		{"735012x1623x1", 808154240661258241},
+		{"103x1x0", 113249697726464},
+	}
+	for i, test := range tests {
+		got, err := ShortChannelIdToLND(test.shortChannelId)
+		if err != nil {
+			t.Fatalf("%d: ShortChannelIdToLND(%v) error: %v", i, test.shortChannelId, err)
+		}
+		if got != test.lnd {
+			t.Errorf("%d: ShortChannelIdToLND(%v)\nGot:\n%v\nWant:\n%v\n", i, test.shortChannelId, got, test.lnd)
+		}
+		if test.shortChannelId != "" && ShortChannelIdFromLND(got) != test.shortChannelId {
+			t.Errorf("%d: ShortChannelIdFromLND(%v)\nGot:\n%v\nWant:\n%v\n", i, got,
+				ShortChannelIdFromLND(got), test.shortChannelId)
+		}
+	}
+	if _, err := ShortChannelIdToLND("735012x1623"); err == nil {
+		t.Errorf("ShortChannelIdToLND(735012x1623) expected an error")
+	}
+}
@@ -0,0 +1,278 @@
+package cln
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// ShortChannelIdToLND converts a short channel id (i.e. 735xDSx1) to the uint64 representation used by LND.
+// An empty short channel id (the channel isn't confirmed yet) is 0.
+func ShortChannelIdToLND(shortChannelId string) (uint64, error) {
+	if shortChannelId == "" {
+		return 0, nil
+	}
+	parts := strings.Split(shortChannelId, "x")
+	if len(parts) != 3 {
+		return 0, errors.Newf("Invalid short channel id: %v", shortChannelId)
+	}
+	blockHeight, err := strconv.ParseUint(parts[0], 10, 24)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Parsing block height of short channel id: %v", shortChannelId)
+	}
+	txIndex, err := strconv.ParseUint(parts[1], 10, 24)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Parsing transaction index of short channel id: %v", shortChannelId)
+	}
+	outputIndex, err := strconv.ParseUint(parts[2], 10, 16)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Parsing output index of short channel id: %v", shortChannelId)
+	}
+	return blockHeight<<40 | txIndex<<16 | outputIndex, nil
+}
+
+// ShortChannelIdFromLND converts the uint64 representation used by LND to a short channel id (i.e. 735xDSx1).
+func ShortChannelIdFromLND(lndShortChannelId uint64) string {
+	return strconv.FormatUint(lndShortChannelId>>40, 10) +
+		"x" + strconv.FormatUint(lndShortChannelId>>16&0xFFFFFF, 10) +
+		"x" + strconv.FormatUint(lndShortChannelId&0xFFFF, 10)
+}
+
+func channelPoint(fundingTxid string, fundingOutnum uint32) string {
+	return fundingTxid + ":" + strconv.FormatUint(uint64(fundingOutnum), 10)
+}
+
+// lnrpcChannelPoint creates the channel point of LND, the funding txid bytes are in the reversed (internal) order.
+func lnrpcChannelPoint(fundingTxid string, fundingOutnum uint32) (*lnrpc.ChannelPoint, error) {
+	hash, err := chainhash.NewHashFromStr(fundingTxid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Parsing funding txid: %v", fundingTxid)
+	}
+	return &lnrpc.ChannelPoint{
+		FundingTxid: &lnrpc.ChannelPoint_FundingTxidBytes{FundingTxidBytes: hash.CloneBytes()},
+		OutputIndex: fundingOutnum,
+	}, nil
+}
+
+func decodeHex(value string) []byte {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// secondsToNanoseconds converts the fractional timestamps of Core Lightning, they have microsecond precision and a
+// float64 can't hold the nanoseconds since the epoch exactly.
+func secondsToNanoseconds(seconds float64) uint64 {
+	whole := math.Floor(seconds)
+	microseconds := math.Round((seconds - whole) * float64(time.Second/time.Microsecond))
+	return uint64(whole)*uint64(time.Second) + uint64(microseconds)*uint64(time.Microsecond)
+}
+
+// Channel states, the states before CHANNELD_NORMAL are pending opens and the states after it are closing.
+const (
+	stateNormal             = "CHANNELD_NORMAL"
+	stateAwaitingLockin     = "CHANNELD_AWAITING_LOCKIN"
+	stateDualopendAwaiting  = "DUALOPEND_AWAITING_LOCKIN"
+	stateDualopendInit      = "DUALOPEND_OPEN_INIT"
+	stateOpeningd           = "OPENINGD"
+	stateShuttingDown       = "CHANNELD_SHUTTING_DOWN"
+	stateClosingdSigexchg   = "CLOSINGD_SIGEXCHANGE"
+	stateClosingdComplete   = "CLOSINGD_COMPLETE"
+	stateAwaitingUnilateral = "AWAITING_UNILATERAL"
+	stateFundingSpendSeen   = "FUNDING_SPEND_SEEN"
+	stateOnchain            = "ONCHAIN"
+	stateClosed             = "CLOSED"
+)
+
+func isPendingOpen(state string) bool {
+	switch state {
+	case stateAwaitingLockin, stateDualopendAwaiting, stateDualopendInit, stateOpeningd:
+		return true
+	}
+	return false
+}
+
+// isWaitingClose is true until the closing transaction is seen on chain.
+func isWaitingClose(state string) bool {
+	switch state {
+	case stateShuttingDown, stateClosingdSigexchg, stateClosingdComplete, stateAwaitingUnilateral,
+		stateFundingSpendSeen:
+		return true
+	}
+	return false
+}
+
+// isUnilateral is true when the status of an on chain channel mentions a unilateral (force) close.
+func isUnilateral(status []string) bool {
+	for _, message := range status {
+		if strings.Contains(strings.ToLower(message), "unilateral") {
+			return true
+		}
+	}
+	return false
+}
+
+func isClosed(state string) bool {
+	switch state {
+	case stateClosingdComplete, stateAwaitingUnilateral, stateFundingSpendSeen, stateOnchain, stateClosed:
+		return true
+	}
+	return false
+}
+
+// closeType derives the LND closure type from the closing state of a channel that is still listed.
+// A channel that went through the closing daemon was closed cooperatively.
+func closeType(previousState string, state string, closer string) lnrpc.ChannelCloseSummary_ClosureType {
+	switch {
+	case state == stateClosingdComplete || previousState == stateClosingdSigexchg ||
+		previousState == stateClosingdComplete || previousState == stateShuttingDown:
+		return lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE
+	case state == stateAwaitingUnilateral || closer == "local":
+		return lnrpc.ChannelCloseSummary_LOCAL_FORCE_CLOSE
+	default:
+		return lnrpc.ChannelCloseSummary_REMOTE_FORCE_CLOSE
+	}
+}
+
+// closeCauseType derives the LND closure type from the close cause of listclosedchannels.
+// Core Lightning doesn't record if a close was cooperative, a close requested by a peer or the user is assumed to be
+// cooperative, a close because of a protocol error is a force close.
+func closeCauseType(closeCause string, closer string) lnrpc.ChannelCloseSummary_ClosureType {
+	switch closeCause {
+	case "user", "remote":
+		return lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE
+	case "local", "protocol":
+		if closer == "remote" {
+			return lnrpc.ChannelCloseSummary_REMOTE_FORCE_CLOSE
+		}
+		return lnrpc.ChannelCloseSummary_LOCAL_FORCE_CLOSE
+	case "onchain":
+		return lnrpc.ChannelCloseSummary_REMOTE_FORCE_CLOSE
+	}
+	return lnrpc.ChannelCloseSummary_FUNDING_CANCELED
+}
+
+func lnrpcInitiator(opener string) lnrpc.Initiator {
+	switch opener {
+	case "local":
+		return lnrpc.Initiator_INITIATOR_LOCAL
+	case "remote":
+		return lnrpc.Initiator_INITIATOR_REMOTE
+	}
+	return lnrpc.Initiator_INITIATOR_UNKNOWN
+}
+
+func lnrpcChannel(channel PeerChannel) (*lnrpc.Channel, error) {
+	chanId, err := ShortChannelIdToLND(channel.ShortChannelId)
+	if err != nil {
+		return nil, err
+	}
+	return &lnrpc.Channel{
+		Active:        channel.PeerConnected && channel.State == stateNormal,
+		RemotePubkey:  channel.PeerId,
+		ChannelPoint:  channelPoint(channel.FundingTxid, channel.FundingOutnum),
+		ChanId:        chanId,
+		Capacity:      channel.TotalMsat.Sat(),
+		LocalBalance:  channel.ToUsMsat.Sat(),
+		RemoteBalance: channel.TotalMsat.Sat() - channel.ToUsMsat.Sat(),
+		Private:       channel.Private,
+		Initiator:     channel.Opener == "local",
+	}, nil
+}
+
+func lnrpcClosedChannel(channel ClosedChannel) (*lnrpc.ChannelCloseSummary, error) {
+	chanId, err := ShortChannelIdToLND(channel.ShortChannelId)
+	if err != nil {
+		return nil, err
+	}
+	return &lnrpc.ChannelCloseSummary{
+		ChannelPoint:   channelPoint(channel.FundingTxid, channel.FundingOutnum),
+		ChanId:         chanId,
+		RemotePubkey:   channel.PeerId,
+		Capacity:       channel.TotalMsat.Sat(),
+		SettledBalance: channel.FinalToUsMsat.Sat(),
+		CloseType:      closeCauseType(channel.CloseCause, channel.Closer),
+		OpenInitiator:  lnrpcInitiator(channel.Opener),
+		CloseInitiator: lnrpcInitiator(channel.Closer),
+	}, nil
+}
+
+func lnrpcRoutingPolicy(channel GossipChannel) *lnrpc.RoutingPolicy {
+	return &lnrpc.RoutingPolicy{
+		TimeLockDelta:    channel.Delay,
+		MinHtlc:          channel.HtlcMinimumMsat.Int64(),
+		FeeBaseMsat:      int64(channel.BaseFeeMillisatoshi),
+		FeeRateMilliMsat: int64(channel.FeePerMillionth),
+		Disabled:         !channel.Active,
+		MaxHtlcMsat:      uint64(channel.HtlcMaximumMsat),
+		LastUpdate:       channel.LastUpdate,
+	}
+}
+
+func lnrpcChannelEdgeUpdate(channel GossipChannel, chanPoint *lnrpc.ChannelPoint) (*lnrpc.ChannelEdgeUpdate, error) {
+	chanId, err := ShortChannelIdToLND(channel.ShortChannelId)
+	if err != nil {
+		return nil, err
+	}
+	return &lnrpc.ChannelEdgeUpdate{
+		ChanId:          chanId,
+		ChanPoint:       chanPoint,
+		Capacity:        channel.AmountMsat.Sat(),
+		RoutingPolicy:   lnrpcRoutingPolicy(channel),
+		AdvertisingNode: channel.Source,
+		ConnectingNode:  channel.Destination,
+	}, nil
+}
+
+func lnrpcNodeAddresses(addresses []NodeAddress) []*lnrpc.NodeAddress {
+	var nodeAddresses []*lnrpc.NodeAddress
+	for _, address := range addresses {
+		nodeAddresses = append(nodeAddresses, &lnrpc.NodeAddress{
+			Network: "tcp",
+			Addr:    address.Address + ":" + strconv.FormatUint(uint64(address.Port), 10),
+		})
+	}
+	return nodeAddresses
+}
+
+func lnrpcForwardingEvent(forward Forward) (*lnrpc.ForwardingEvent, error) {
+	chanIdIn, err := ShortChannelIdToLND(forward.InChannel)
+	if err != nil {
+		return nil, err
+	}
+	chanIdOut, err := ShortChannelIdToLND(forward.OutChannel)
+	if err != nil {
+		return nil, err
+	}
+	timestampNs := secondsToNanoseconds(forward.ResolvedTime)
+	return &lnrpc.ForwardingEvent{
+		Timestamp:   timestampNs / uint64(time.Second),
+		TimestampNs: timestampNs,
+		ChanIdIn:    chanIdIn,
+		ChanIdOut:   chanIdOut,
+		AmtIn:       uint64(forward.InMsat.Sat()),
+		AmtOut:      uint64(forward.OutMsat.Sat()),
+		AmtInMsat:   uint64(forward.InMsat),
+		AmtOutMsat:  uint64(forward.OutMsat),
+		Fee:         uint64(forward.FeeMsat.Sat()),
+		FeeMsat:     uint64(forward.FeeMsat),
+	}, nil
+}
+
+func lnrpcInvoiceState(status string) lnrpc.Invoice_InvoiceState {
+	switch status {
+	case InvoicePaid:
+		return lnrpc.Invoice_SETTLED
+	case InvoiceExpired:
+		return lnrpc.Invoice_CANCELED
+	}
+	return lnrpc.Invoice_OPEN
+}
@@ -0,0 +1,546 @@
+package cln
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Lightning exposes a Core Lightning node through the LND client methods used by the ingestion in pkg/lnd so the
+// same code fills the same tables for both implementations. Streams are emulated by polling the node.
+type Lightning struct {
+	client *Client
+	// PollInterval is the time between two requests of an emulated stream.
+	PollInterval time.Duration
+	// GraphPollInterval is the time between two requests of the emulated channel graph stream.
+	GraphPollInterval time.Duration
+
+	infoMu sync.Mutex
+	info   *GetInfoResponse
+}
+
+func NewLightning(client *Client) *Lightning {
+	return &Lightning{
+		client:            client,
+		PollInterval:      10 * time.Second,
+		GraphPollInterval: time.Minute,
+	}
+}
+
+// Client returns the underlying Core Lightning client.
+func (l *Lightning) Client() *Client {
+	return l.client
+}
+
+// getInfo is cached, the identity and network of a node don't change.
+func (l *Lightning) getInfo(ctx context.Context) (GetInfoResponse, error) {
+	l.infoMu.Lock()
+	defer l.infoMu.Unlock()
+	if l.info != nil {
+		return *l.info, nil
+	}
+	info, err := l.client.GetInfo(ctx)
+	if err != nil {
+		return GetInfoResponse{}, errors.Wrap(err, "Obtaining information from Core Lightning")
+	}
+	l.info = &info
+	return info, nil
+}
+
+// ChainParams returns the parameters of the network as reported by getinfo.
+func ChainParams(network string) *chaincfg.Params {
+	switch network {
+	case "testnet":
+		return &chaincfg.TestNet3Params
+	case "signet":
+		return &chaincfg.SigNetParams
+	case "regtest":
+		return &chaincfg.RegressionNetParams
+	}
+	return &chaincfg.MainNetParams
+}
+
+func (l *Lightning) ListChannels(ctx context.Context, in *lnrpc.ListChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListChannelsResponse, error) {
+
+	peerChannels, err := l.client.ListPeerChannels(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing peer channels")
+	}
+	resp := &lnrpc.ListChannelsResponse{}
+	for _, peerChannel := range peerChannels {
+		if peerChannel.State != stateNormal {
+			continue
+		}
+		channel, err := lnrpcChannel(peerChannel)
+		if err != nil {
+			return nil, err
+		}
+		resp.Channels = append(resp.Channels, channel)
+	}
+	return resp, nil
+}
+
+func (l *Lightning) ClosedChannels(ctx context.Context, in *lnrpc.ClosedChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ClosedChannelsResponse, error) {
+
+	closedChannels, err := l.client.ListClosedChannels(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing closed channels")
+	}
+	resp := &lnrpc.ClosedChannelsResponse{}
+	for _, closedChannel := range closedChannels {
+		// channels that never had a peer can't be stored (i.e. a failed funding attempt)
+		if closedChannel.PeerId == "" {
+			continue
+		}
+		channel, err := lnrpcClosedChannel(closedChannel)
+		if err != nil {
+			return nil, err
+		}
+		resp.Channels = append(resp.Channels, channel)
+	}
+	return resp, nil
+}
+
+// PendingChannels lists the channels that are opening or closing, Core Lightning doesn't report when the funds of a
+// force close mature so MaturityHeight stays 0.
+func (l *Lightning) PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error) {
+
+	peerChannels, err := l.client.ListPeerChannels(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing peer channels")
+	}
+	resp := &lnrpc.PendingChannelsResponse{}
+	for _, peerChannel := range peerChannels {
+		channel := &lnrpc.PendingChannelsResponse_PendingChannel{
+			RemoteNodePub: peerChannel.PeerId,
+			ChannelPoint:  channelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum),
+			Capacity:      peerChannel.TotalMsat.Sat(),
+			LocalBalance:  peerChannel.ToUsMsat.Sat(),
+			RemoteBalance: peerChannel.TotalMsat.Sat() - peerChannel.ToUsMsat.Sat(),
+			Initiator:     lnrpcInitiator(peerChannel.Opener),
+			Private:       peerChannel.Private,
+		}
+		switch {
+		case isPendingOpen(peerChannel.State):
+			resp.PendingOpenChannels = append(resp.PendingOpenChannels,
+				&lnrpc.PendingChannelsResponse_PendingOpenChannel{Channel: channel})
+		case isWaitingClose(peerChannel.State):
+			resp.WaitingCloseChannels = append(resp.WaitingCloseChannels,
+				&lnrpc.PendingChannelsResponse_WaitingCloseChannel{Channel: channel,
+					LimboBalance: peerChannel.ToUsMsat.Sat()})
+			resp.TotalLimboBalance += peerChannel.ToUsMsat.Sat()
+		case peerChannel.State == stateOnchain && isUnilateral(peerChannel.Status):
+			resp.PendingForceClosingChannels = append(resp.PendingForceClosingChannels,
+				&lnrpc.PendingChannelsResponse_ForceClosedChannel{Channel: channel,
+					LimboBalance: peerChannel.ToUsMsat.Sat()})
+			resp.TotalLimboBalance += peerChannel.ToUsMsat.Sat()
+		}
+	}
+	return resp, nil
+}
+
+// GetChanInfo returns the gossip of one of the channels of the node, other channels are not found.
+func (l *Lightning) GetChanInfo(ctx context.Context, in *lnrpc.ChanInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChannelEdge, error) {
+
+	shortChannelId := ShortChannelIdFromLND(in.ChanId)
+	peerChannels, err := l.client.ListPeerChannels(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing peer channels")
+	}
+	var chanPoint string
+	for _, peerChannel := range peerChannels {
+		if peerChannel.ShortChannelId == shortChannelId {
+			chanPoint = channelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum)
+		}
+	}
+	gossipChannels, err := l.client.ListChannels(ctx, ListChannelsRequest{ShortChannelId: shortChannelId})
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing channels")
+	}
+	if chanPoint == "" || len(gossipChannels) == 0 {
+		return nil, status.Errorf(codes.NotFound, "edge not found: %v", shortChannelId)
+	}
+	edge := &lnrpc.ChannelEdge{
+		ChannelId: in.ChanId,
+		ChanPoint: chanPoint,
+	}
+	for _, gossipChannel := range gossipChannels {
+		edge.Capacity = gossipChannel.AmountMsat.Sat()
+		// direction 0 is announced by the node with the lowest id (node 1)
+		if gossipChannel.Direction == 0 {
+			edge.Node1Pub = gossipChannel.Source
+			edge.Node2Pub = gossipChannel.Destination
+			edge.Node1Policy = lnrpcRoutingPolicy(gossipChannel)
+		} else {
+			edge.Node1Pub = gossipChannel.Destination
+			edge.Node2Pub = gossipChannel.Source
+			edge.Node2Policy = lnrpcRoutingPolicy(gossipChannel)
+		}
+	}
+	return edge, nil
+}
+
+func (l *Lightning) GetNodeInfo(ctx context.Context, in *lnrpc.NodeInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.NodeInfo, error) {
+
+	gossipNodes, err := l.client.ListNodes(ctx, in.PubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing nodes")
+	}
+	if len(gossipNodes) == 0 {
+		return nil, status.Errorf(codes.NotFound, "unable to find node: %v", in.PubKey)
+	}
+	return &lnrpc.NodeInfo{Node: lnrpcNode(gossipNodes[0])}, nil
+}
+
+func lnrpcNode(gossipNode GossipNode) *lnrpc.LightningNode {
+	return &lnrpc.LightningNode{
+		LastUpdate: gossipNode.LastTimestamp,
+		PubKey:     gossipNode.NodeId,
+		Alias:      gossipNode.Alias,
+		Addresses:  lnrpcNodeAddresses(gossipNode.Addresses),
+		Color:      "#" + gossipNode.Color,
+		Features:   lnrpcFeatures(gossipNode.Features),
+	}
+}
+
+// lnrpcFeatures converts the hex encoded feature bits, even bits are required and odd bits are optional.
+func lnrpcFeatures(features string) map[uint32]*lnrpc.Feature {
+	bits := new(big.Int).SetBytes(decodeHex(features))
+	result := make(map[uint32]*lnrpc.Feature)
+	for bit := 0; bit < bits.BitLen(); bit++ {
+		if bits.Bit(bit) == 1 {
+			result[uint32(bit)] = &lnrpc.Feature{IsRequired: bit%2 == 0}
+		}
+	}
+	return result
+}
+
+// ForwardingHistory returns the settled forwards resolved since the start time ordered by the time they resolved.
+func (l *Lightning) ForwardingHistory(ctx context.Context, in *lnrpc.ForwardingHistoryRequest,
+	opts ...grpc.CallOption) (*lnrpc.ForwardingHistoryResponse, error) {
+
+	forwards, err := l.client.ListForwards(ctx, ListForwardsRequest{Status: ForwardSettled})
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing forwards")
+	}
+	sort.SliceStable(forwards, func(i, j int) bool {
+		return forwards[i].ResolvedTime < forwards[j].ResolvedTime
+	})
+	resp := &lnrpc.ForwardingHistoryResponse{}
+	var skipped uint32
+	for _, forward := range forwards {
+		resolved := uint64(forward.ResolvedTime)
+		if resolved < in.StartTime || (in.EndTime != 0 && resolved >= in.EndTime) {
+			continue
+		}
+		if skipped < in.IndexOffset {
+			skipped++
+			continue
+		}
+		if in.NumMaxEvents != 0 && uint32(len(resp.ForwardingEvents)) >= in.NumMaxEvents {
+			break
+		}
+		event, err := lnrpcForwardingEvent(forward)
+		if err != nil {
+			return nil, err
+		}
+		resp.ForwardingEvents = append(resp.ForwardingEvents, event)
+	}
+	resp.LastOffsetIndex = in.IndexOffset + uint32(len(resp.ForwardingEvents))
+	return resp, nil
+}
+
+// ListPayments returns the payments with a payment index after the index offset. Core Lightning stores a part
+// (HTLC) per attempt, the payment index of a payment is the created index of its first part.
+func (l *Lightning) ListPayments(ctx context.Context, in *lnrpc.ListPaymentsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPaymentsResponse, error) {
+
+	resp := &lnrpc.ListPaymentsResponse{LastIndexOffset: in.IndexOffset}
+	next := in.IndexOffset + 1
+	for in.MaxPayments == 0 || uint64(len(resp.Payments)) < in.MaxPayments {
+		sendPays, err := l.client.ListSendPays(ctx, ListSendPaysRequest{Index: "created", Start: next, Limit: 1})
+		if err != nil {
+			return nil, errors.Wrap(err, "Listing send pays")
+		}
+		if len(sendPays) == 0 {
+			break
+		}
+		first := sendPays[0]
+		next = first.CreatedIndex + 1
+		parts, err := l.client.ListSendPays(ctx, ListSendPaysRequest{PaymentHash: first.PaymentHash})
+		if err != nil {
+			return nil, errors.Wrap(err, "Listing send pays of payment")
+		}
+		payment := lnrpcPayment(first.GroupId, parts)
+		// a later part of a payment that was already returned
+		if payment == nil || payment.PaymentIndex != first.CreatedIndex {
+			continue
+		}
+		if payment.Status != lnrpc.Payment_SUCCEEDED && !in.IncludeIncomplete {
+			continue
+		}
+		resp.Payments = append(resp.Payments, payment)
+		resp.LastIndexOffset = payment.PaymentIndex
+	}
+	return resp, nil
+}
+
+// ListInvoices returns the invoices with a created index after the index offset.
+func (l *Lightning) ListInvoices(ctx context.Context, in *lnrpc.ListInvoiceRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListInvoiceResponse, error) {
+
+	info, err := l.getInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := ChainParams(info.Network)
+	invoices, err := l.client.ListInvoices(ctx, ListInvoicesRequest{Index: "created", Start: in.IndexOffset + 1,
+		Limit: uint32(in.NumMaxInvoices)})
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing invoices")
+	}
+	resp := &lnrpc.ListInvoiceResponse{LastIndexOffset: in.IndexOffset}
+	for _, invoice := range invoices {
+		if invoice.CreatedIndex <= in.IndexOffset || (in.PendingOnly && invoice.Status != InvoiceUnpaid) {
+			continue
+		}
+		resp.Invoices = append(resp.Invoices, lnrpcInvoice(invoice, params))
+		resp.LastIndexOffset = invoice.CreatedIndex
+	}
+	if len(resp.Invoices) != 0 {
+		resp.FirstIndexOffset = resp.Invoices[0].AddIndex
+	}
+	return resp, nil
+}
+
+// lnrpcPayment combines the parts of a payment attempt (group), a payment succeeded when any part completed.
+func lnrpcPayment(groupId uint64, parts []SendPay) *lnrpc.Payment {
+	var payment *lnrpc.Payment
+	pending := false
+	completed := false
+	var amount, amountSent Msat
+	for _, part := range parts {
+		if part.GroupId != groupId {
+			continue
+		}
+		if payment == nil {
+			payment = &lnrpc.Payment{
+				PaymentHash:    part.PaymentHash,
+				PaymentRequest: part.Bolt11,
+				CreationDate:   part.CreatedAt,
+				CreationTimeNs: part.CreatedAt * int64(time.Second),
+				PaymentIndex:   part.CreatedIndex,
+			}
+		}
+		if part.CreatedIndex < payment.PaymentIndex {
+			payment.PaymentIndex = part.CreatedIndex
+		}
+		htlc := &lnrpc.HTLCAttempt{
+			AttemptId:     part.Id,
+			AttemptTimeNs: part.CreatedAt * int64(time.Second),
+			ResolveTimeNs: part.CompletedAt * int64(time.Second),
+		}
+		switch part.Status {
+		case SendPayComplete:
+			completed = true
+			payment.PaymentPreimage = part.PaymentPreimage
+			htlc.Status = lnrpc.HTLCAttempt_SUCCEEDED
+			htlc.Preimage = decodeHex(part.PaymentPreimage)
+			amount += part.AmountMsat
+			amountSent += part.AmountSentMsat
+		case SendPayPending:
+			pending = true
+			htlc.Status = lnrpc.HTLCAttempt_IN_FLIGHT
+			amount += part.AmountMsat
+			amountSent += part.AmountSentMsat
+		default:
+			htlc.Status = lnrpc.HTLCAttempt_FAILED
+		}
+		payment.Htlcs = append(payment.Htlcs, htlc)
+	}
+	if payment == nil {
+		return nil
+	}
+	switch {
+	case completed:
+		payment.Status = lnrpc.Payment_SUCCEEDED
+	case pending:
+		payment.Status = lnrpc.Payment_IN_FLIGHT
+	default:
+		payment.Status = lnrpc.Payment_FAILED
+		payment.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR
+	}
+	payment.ValueMsat = amount.Int64()
+	payment.Value = amount.Sat()
+	payment.ValueSat = amount.Sat()
+	payment.FeeMsat = int64(amountSent) - int64(amount)
+	payment.FeeSat = payment.FeeMsat / 1000
+	payment.Fee = payment.FeeSat
+	return payment
+}
+
+// GetTransactions returns the confirmed transactions of the wallet from the start height onwards.
+func (l *Lightning) GetTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (*lnrpc.TransactionDetails, error) {
+
+	transactions, err := l.transactions(ctx, uint32(in.StartHeight))
+	if err != nil {
+		return nil, err
+	}
+	return &lnrpc.TransactionDetails{Transactions: transactions}, nil
+}
+
+// transactions returns the confirmed wallet transactions. The amount, fees and time of a transaction come from the
+// bookkeeper, a transaction the bookkeeper has no events for is skipped.
+func (l *Lightning) transactions(ctx context.Context, startHeight uint32) ([]*lnrpc.Transaction, error) {
+	info, err := l.client.GetInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining block height")
+	}
+	transactions, err := l.client.ListTransactions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing transactions")
+	}
+	events, err := l.client.ListAccountEvents(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing bookkeeper account events")
+	}
+
+	type walletChange struct {
+		amountMsat int64
+		feesMsat   int64
+		timestamp  int64
+	}
+	changes := make(map[string]*walletChange)
+	change := func(txid string, timestamp int64) *walletChange {
+		c, exists := changes[txid]
+		if !exists {
+			c = &walletChange{timestamp: timestamp}
+			changes[txid] = c
+		}
+		if timestamp != 0 && (c.timestamp == 0 || timestamp < c.timestamp) {
+			c.timestamp = timestamp
+		}
+		return c
+	}
+	for _, event := range events {
+		switch {
+		case event.Type == "onchain_fee" && event.Txid != "":
+			change(event.Txid, event.Timestamp).feesMsat += int64(event.CreditMsat) - int64(event.DebitMsat)
+		case event.Type == "chain" && event.Account == "wallet":
+			// deposits credit the outpoint created by the transaction, withdrawals debit the spending transaction
+			if event.CreditMsat > 0 && len(event.Outpoint) > 64 {
+				change(event.Outpoint[:64], event.Timestamp).amountMsat += int64(event.CreditMsat)
+			}
+			if event.DebitMsat > 0 && event.Txid != "" {
+				change(event.Txid, event.Timestamp).amountMsat -= int64(event.DebitMsat)
+			}
+		}
+	}
+
+	var result []*lnrpc.Transaction
+	for _, transaction := range transactions {
+		if transaction.BlockHeight == 0 || transaction.BlockHeight < startHeight {
+			continue
+		}
+		c, exists := changes[transaction.Hash]
+		if !exists {
+			continue
+		}
+		result = append(result, &lnrpc.Transaction{
+			TxHash:           transaction.Hash,
+			Amount:           c.amountMsat / 1000,
+			NumConfirmations: int32(info.BlockHeight - transaction.BlockHeight + 1),
+			BlockHeight:      int32(transaction.BlockHeight),
+			TimeStamp:        c.timestamp,
+			TotalFees:        c.feesMsat / 1000,
+			RawTxHex:         transaction.RawTx,
+		})
+	}
+	return result, nil
+}
+
+// DecodePayReq decodes a bolt11 invoice locally for the network of the node.
+func (l *Lightning) DecodePayReq(ctx context.Context, in *lnrpc.PayReqString,
+	opts ...grpc.CallOption) (*lnrpc.PayReq, error) {
+	info, err := l.getInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := zpay32.Decode(in.PayReq, ChainParams(info.Network))
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding invoice")
+	}
+	result := &lnrpc.PayReq{
+		Timestamp:  decoded.Timestamp.Unix(),
+		Expiry:     int64(decoded.Expiry().Seconds()),
+		CltvExpiry: int64(decoded.MinFinalCLTVExpiry()),
+	}
+	if decoded.Destination != nil {
+		result.Destination = hex.EncodeToString(decoded.Destination.SerializeCompressed())
+	}
+	if decoded.PaymentHash != nil {
+		result.PaymentHash = hex.EncodeToString(decoded.PaymentHash[:])
+	}
+	if decoded.PaymentAddr != nil {
+		result.PaymentAddr = decoded.PaymentAddr[:]
+	}
+	if decoded.Description != nil {
+		result.Description = *decoded.Description
+	}
+	if decoded.MilliSat != nil {
+		result.NumMsat = int64(*decoded.MilliSat)
+		result.NumSatoshis = int64(decoded.MilliSat.ToSatoshis())
+	}
+	return result, nil
+}
+
+// lnrpcInvoice converts an invoice, the creation date and payment address are only known from the bolt11.
+func lnrpcInvoice(invoice Invoice, params *chaincfg.Params) *lnrpc.Invoice {
+	result := &lnrpc.Invoice{
+		Memo:           invoice.Description,
+		RPreimage:      decodeHex(invoice.PaymentPreimage),
+		RHash:          decodeHex(invoice.PaymentHash),
+		Value:          invoice.AmountMsat.Sat(),
+		ValueMsat:      invoice.AmountMsat.Int64(),
+		SettleDate:     invoice.PaidAt,
+		PaymentRequest: invoice.Bolt11,
+		AddIndex:       invoice.CreatedIndex,
+		SettleIndex:    invoice.PayIndex,
+		AmtPaid:        invoice.AmountReceivedMsat.Int64(),
+		AmtPaidSat:     invoice.AmountReceivedMsat.Sat(),
+		AmtPaidMsat:    invoice.AmountReceivedMsat.Int64(),
+		State:          lnrpcInvoiceState(invoice.Status),
+	}
+	if invoice.Bolt11 != "" {
+		decoded, err := zpay32.Decode(invoice.Bolt11, params)
+		if err == nil {
+			result.CreationDate = decoded.Timestamp.Unix()
+			result.Expiry = int64(decoded.Expiry().Seconds())
+			result.CltvExpiry = decoded.MinFinalCLTVExpiry()
+			if decoded.PaymentAddr != nil {
+				result.PaymentAddr = decoded.PaymentAddr[:]
+			}
+			if decoded.DescriptionHash != nil {
+				result.DescriptionHash = decoded.DescriptionHash[:]
+			}
+		}
+	}
+	return result
+}
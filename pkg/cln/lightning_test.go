@@ -0,0 +1,232 @@
+package cln
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+)
+
+const (
+	testPeer    = "02a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	testTxid    = "6f2c2d5c4b2b9c3f1e0a9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a392817161514"
+	testPayHash = "a1a2a3a4a5a6a7a8a9b0b1b2b3b4b5b6b7b8b9c0c1c2c3c4c5c6c7c8c9d0d1d2"
+)
+
+func TestListPayments(t *testing.T) {
+	node, client := newRecordedNode(t, map[string][]string{
+		"listsendpays": {
+			// first part of the first payment
+			`{"payments":[{"created_index":1,"id":1,"groupid":1,"partid":1,"payment_hash":"` + testPayHash + `",
+				"status":"failed","amount_msat":100000,"amount_sent_msat":100100,"created_at":1700000000}]}`,
+			// all parts of the first payment, the first attempt failed and the retry completed
+			`{"payments":[
+				{"created_index":1,"id":1,"groupid":1,"partid":1,"payment_hash":"` + testPayHash + `",
+					"status":"failed","amount_msat":100000,"amount_sent_msat":100100,"created_at":1700000000},
+				{"created_index":2,"id":2,"groupid":1,"partid":2,"payment_hash":"` + testPayHash + `",
+					"status":"complete","amount_msat":100000,"amount_sent_msat":100050,"created_at":1700000001,
+					"completed_at":1700000002,"payment_preimage":"` + testTxid + `"}]}`,
+			// the second part of the first payment was already returned
+			`{"payments":[{"created_index":2,"id":2,"groupid":1,"partid":2,"payment_hash":"` + testPayHash + `",
+				"status":"complete","amount_msat":100000,"amount_sent_msat":100050,"created_at":1700000001}]}`,
+			`{"payments":[
+				{"created_index":1,"id":1,"groupid":1,"partid":1,"payment_hash":"` + testPayHash + `",
+					"status":"failed","amount_msat":100000,"amount_sent_msat":100100,"created_at":1700000000},
+				{"created_index":2,"id":2,"groupid":1,"partid":2,"payment_hash":"` + testPayHash + `",
+					"status":"complete","amount_msat":100000,"amount_sent_msat":100050,"created_at":1700000001,
+					"completed_at":1700000002,"payment_preimage":"` + testTxid + `"}]}`,
+			`{"payments":[]}`,
+		},
+	})
+
+	resp, err := NewLightning(client).ListPayments(context.Background(), &lnrpc.ListPaymentsRequest{})
+	if err != nil {
+		t.Fatalf("ListPayments() error: %v", err)
+	}
+	if len(resp.Payments) != 1 {
+		t.Fatalf("ListPayments() payments\nGot:\n%v\nWant:\n%v\n", len(resp.Payments), 1)
+	}
+	payment := resp.Payments[0]
+	if payment.Status != lnrpc.Payment_SUCCEEDED || payment.PaymentIndex != 1 || payment.ValueMsat != 100000 ||
+		payment.FeeMsat != 50 || len(payment.Htlcs) != 2 || payment.PaymentPreimage != testTxid {
+		t.Errorf("ListPayments() payment\nGot:\n%v\nWant:\n%v\n", payment,
+			"SUCCEEDED, index 1, 100000 msat, 50 msat fee, 2 htlcs")
+	}
+	if resp.LastIndexOffset != 1 {
+		t.Errorf("ListPayments() last index offset\nGot:\n%v\nWant:\n%v\n", resp.LastIndexOffset, 1)
+	}
+	if got := len(node.requestsOf("listsendpays")); got != 5 {
+		t.Errorf("ListPayments() listsendpays calls\nGot:\n%v\nWant:\n%v\n", got, 5)
+	}
+}
+
+func TestForwardingHistory(t *testing.T) {
+	_, client := newRecordedNode(t, map[string][]string{
+		"listforwards": {`{"forwards":[
+			{"created_index":2,"in_channel":"103x1x0","in_msat":100100,"status":"settled","received_time":1700000100.5,
+				"out_channel":"104x2x1","fee_msat":100,"out_msat":100000,"resolved_time":1700000101.25},
+			{"created_index":1,"in_channel":"104x2x1","in_msat":"50010msat","status":"settled","received_time":1700000000,
+				"out_channel":"103x1x0","fee_msat":"10msat","out_msat":"50000msat","resolved_time":1700000001}]}`},
+	})
+
+	resp, err := NewLightning(client).ForwardingHistory(context.Background(),
+		&lnrpc.ForwardingHistoryRequest{StartTime: 1700000050})
+	if err != nil {
+		t.Fatalf("ForwardingHistory() error: %v", err)
+	}
+	want := &lnrpc.ForwardingEvent{
+		Timestamp:   1700000101,
+		TimestampNs: 1700000101250000000,
+		ChanIdIn:    113249697726464,
+		ChanIdOut:   114349209419777,
+		AmtIn:       100,
+		AmtOut:      100,
+		AmtInMsat:   100100,
+		AmtOutMsat:  100000,
+		FeeMsat:     100,
+	}
+	if len(resp.ForwardingEvents) != 1 {
+		t.Fatalf("ForwardingHistory() events\nGot:\n%v\nWant:\n%v\n", len(resp.ForwardingEvents), 1)
+	}
+	got := resp.ForwardingEvents[0]
+	if got.Timestamp != want.Timestamp || got.TimestampNs != want.TimestampNs || got.ChanIdIn != want.ChanIdIn ||
+		got.ChanIdOut != want.ChanIdOut || got.AmtInMsat != want.AmtInMsat || got.AmtOutMsat != want.AmtOutMsat ||
+		got.FeeMsat != want.FeeMsat {
+		t.Errorf("ForwardingHistory() event\nGot:\n%v\nWant:\n%v\n", got, want)
+	}
+}
+
+func TestChannelEventUpdates(t *testing.T) {
+	pending := PeerChannel{PeerId: testPeer, State: stateAwaitingLockin, FundingTxid: testTxid, FundingOutnum: 1,
+		TotalMsat: 1000000000, ToUsMsat: 1000000000, Opener: "local"}
+	open := pending
+	open.State = stateNormal
+	open.ShortChannelId = "103x1x0"
+	open.PeerConnected = true
+	offline := open
+	offline.PeerConnected = false
+	closing := open
+	closing.State = stateClosingdComplete
+
+	tests := []struct {
+		previous []PeerChannel
+		current  []PeerChannel
+		want     []lnrpc.ChannelEventUpdate_UpdateType
+	}{
+		{nil, []PeerChannel{pending}, []lnrpc.ChannelEventUpdate_UpdateType{
+			lnrpc.ChannelEventUpdate_PENDING_OPEN_CHANNEL}},
+		{[]PeerChannel{pending}, []PeerChannel{open}, []lnrpc.ChannelEventUpdate_UpdateType{
+			lnrpc.ChannelEventUpdate_OPEN_CHANNEL}},
+		{[]PeerChannel{open}, []PeerChannel{offline}, []lnrpc.ChannelEventUpdate_UpdateType{
+			lnrpc.ChannelEventUpdate_INACTIVE_CHANNEL}},
+		{[]PeerChannel{offline}, []PeerChannel{open}, []lnrpc.ChannelEventUpdate_UpdateType{
+			lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL}},
+		{[]PeerChannel{open}, []PeerChannel{closing}, []lnrpc.ChannelEventUpdate_UpdateType{
+			lnrpc.ChannelEventUpdate_CLOSED_CHANNEL}},
+		{[]PeerChannel{closing}, []PeerChannel{closing}, nil},
+		{[]PeerChannel{pending}, nil, []lnrpc.ChannelEventUpdate_UpdateType{
+			lnrpc.ChannelEventUpdate_CLOSED_CHANNEL}},
+	}
+	for i, test := range tests {
+		previous := make(map[string]PeerChannel)
+		for _, peerChannel := range test.previous {
+			previous[channelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum)] = peerChannel
+		}
+		updates, err := channelEventUpdates(previous, test.current)
+		if err != nil {
+			t.Fatalf("%d: channelEventUpdates() error: %v", i, err)
+		}
+		var got []lnrpc.ChannelEventUpdate_UpdateType
+		for _, update := range updates {
+			got = append(got, update.(*lnrpc.ChannelEventUpdate).Type)
+		}
+		if len(got) != len(test.want) || (len(got) != 0 && got[0] != test.want[0]) {
+			t.Errorf("%d: channelEventUpdates()\nGot:\n%v\nWant:\n%v\n", i, got, test.want)
+		}
+	}
+
+	updates, err := channelEventUpdates(map[string]PeerChannel{channelPoint(testTxid, 1): open},
+		[]PeerChannel{closing})
+	if err != nil {
+		t.Fatalf("channelEventUpdates() error: %v", err)
+	}
+	closed := updates[0].(*lnrpc.ChannelEventUpdate).GetClosedChannel()
+	if closed.CloseType != lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE || closed.ChanId != 113249697726464 {
+		t.Errorf("channelEventUpdates() closed channel\nGot:\n%v\nWant:\n%v\n", closed, "cooperative close of 103x1x0")
+	}
+}
+
+func TestSubscribeHtlcEvents(t *testing.T) {
+	_, client := newRecordedNode(t, map[string][]string{
+		"listforwards": {
+			// existing forwards when subscribing
+			`{"forwards":[{"created_index":1,"updated_index":1,"in_channel":"103x1x0","in_htlc_id":1,
+				"status":"settled","out_channel":"104x2x1","out_htlc_id":3}]}`,
+			// created since
+			`{"forwards":[{"created_index":2,"in_channel":"103x1x0","in_htlc_id":2,"in_msat":100100,
+				"status":"offered","received_time":1700000100,"out_channel":"104x2x1","out_htlc_id":4,"out_msat":100000}]}`,
+			// updated since
+			`{"forwards":[{"created_index":2,"updated_index":2,"in_channel":"103x1x0","in_htlc_id":2,"in_msat":100100,
+				"status":"settled","received_time":1700000100,"out_channel":"104x2x1","out_htlc_id":4,
+				"out_msat":100000,"resolved_time":1700000101}]}`,
+		},
+	})
+	lightning := NewLightning(client)
+	lightning.PollInterval = 0
+
+	stream, err := lightning.SubscribeHtlcEvents(context.Background(), &routerrpc.SubscribeHtlcEventsRequest{})
+	if err != nil {
+		t.Fatalf("SubscribeHtlcEvents() error: %v", err)
+	}
+	offer, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error: %v", err)
+	}
+	if offer.GetForwardEvent() == nil || offer.IncomingHtlcId != 2 || offer.OutgoingHtlcId != 4 ||
+		offer.IncomingChannelId != 113249697726464 {
+		t.Errorf("Recv() offer\nGot:\n%v\nWant:\n%v\n", offer, "forward event of htlc 2 to htlc 4")
+	}
+	settle, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error: %v", err)
+	}
+	if settle.GetSettleEvent() == nil || settle.IncomingHtlcId != 2 {
+		t.Errorf("Recv() settle\nGot:\n%v\nWant:\n%v\n", settle, "settle event of htlc 2")
+	}
+}
+
+func TestPendingChannels(t *testing.T) {
+	_, client := newRecordedNode(t, map[string][]string{
+		"listpeerchannels": {`{"channels":[
+			{"peer_id":"` + testPeer + `","state":"CHANNELD_AWAITING_LOCKIN","funding_txid":"` + testTxid + `",
+				"funding_outnum":0,"total_msat":1000000000,"to_us_msat":1000000000},
+			{"peer_id":"` + testPeer + `","state":"CHANNELD_NORMAL","funding_txid":"` + testTxid + `",
+				"funding_outnum":1,"total_msat":1000000000,"to_us_msat":500000000},
+			{"peer_id":"` + testPeer + `","state":"CHANNELD_SHUTTING_DOWN","funding_txid":"` + testTxid + `",
+				"funding_outnum":2,"total_msat":1000000000,"to_us_msat":400000000},
+			{"peer_id":"` + testPeer + `","state":"ONCHAIN","funding_txid":"` + testTxid + `",
+				"funding_outnum":3,"total_msat":1000000000,"to_us_msat":300000000,
+				"status":["ONCHAIN:Tracking our own unilateral close"]},
+			{"peer_id":"` + testPeer + `","state":"ONCHAIN","funding_txid":"` + testTxid + `",
+				"funding_outnum":4,"total_msat":1000000000,"to_us_msat":200000000,
+				"status":["ONCHAIN:Tracking mutual close transaction"]}]}`},
+	})
+
+	resp, err := NewLightning(client).PendingChannels(context.Background(), &lnrpc.PendingChannelsRequest{})
+	if err != nil {
+		t.Fatalf("PendingChannels() error: %v", err)
+	}
+	if len(resp.PendingOpenChannels) != 1 || len(resp.WaitingCloseChannels) != 1 ||
+		len(resp.PendingForceClosingChannels) != 1 {
+		t.Fatalf("PendingChannels()\nGot:\n%v\nWant:\n%v\n", resp, "one opening, waiting close and force closing")
+	}
+	if resp.PendingForceClosingChannels[0].Channel.ChannelPoint != testTxid+":3" ||
+		resp.PendingForceClosingChannels[0].LimboBalance != 300000 {
+		t.Errorf("PendingChannels() force closing\nGot:\n%v\nWant:\n%v\n", resp.PendingForceClosingChannels[0],
+			testTxid+":3 with 300000 sat in limbo")
+	}
+	if resp.TotalLimboBalance != 700000 {
+		t.Errorf("PendingChannels() limbo balance\nGot:\n%v\nWant:\n%v\n", resp.TotalLimboBalance, 700000)
+	}
+}
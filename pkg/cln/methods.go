@@ -0,0 +1,579 @@
+package cln
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+)
+
+// The structs below only contain the fields Torq uses, Core Lightning returns more.
+
+type GetInfoResponse struct {
+	Id          string `json:"id"`
+	Alias       string `json:"alias"`
+	Color       string `json:"color"`
+	Network     string `json:"network"`
+	BlockHeight uint32 `json:"blockheight"`
+	Version     string `json:"version"`
+
+	NumPeers              uint32 `json:"num_peers"`
+	NumPendingChannels    uint32 `json:"num_pending_channels"`
+	NumActiveChannels     uint32 `json:"num_active_channels"`
+	NumInactiveChannels   uint32 `json:"num_inactive_channels"`
+	WarningBitcoindSync   string `json:"warning_bitcoind_sync"`
+	WarningLightningdSync string `json:"warning_lightningd_sync"`
+}
+
+func (client *Client) GetInfo(ctx context.Context) (GetInfoResponse, error) {
+	var resp GetInfoResponse
+	err := client.Call(ctx, "getinfo", nil, &resp)
+	return resp, err
+}
+
+type PeerChannel struct {
+	PeerId                   string   `json:"peer_id"`
+	PeerConnected            bool     `json:"peer_connected"`
+	State                    string   `json:"state"`
+	ShortChannelId           string   `json:"short_channel_id"`
+	ChannelId                string   `json:"channel_id"`
+	FundingTxid              string   `json:"funding_txid"`
+	FundingOutnum            uint32   `json:"funding_outnum"`
+	Private                  bool     `json:"private"`
+	Opener                   string   `json:"opener"`
+	Closer                   string   `json:"closer"`
+	TotalMsat                Msat     `json:"total_msat"`
+	ToUsMsat                 Msat     `json:"to_us_msat"`
+	FeeBaseMsat              Msat     `json:"fee_base_msat"`
+	FeeProportionalMillionth uint32   `json:"fee_proportional_millionths"`
+	Status                   []string `json:"status"`
+}
+
+func (client *Client) ListPeerChannels(ctx context.Context) ([]PeerChannel, error) {
+	var resp struct {
+		Channels []PeerChannel `json:"channels"`
+	}
+	err := client.Call(ctx, "listpeerchannels", nil, &resp)
+	return resp.Channels, err
+}
+
+// ChannelIdByChannelPoint looks up the channel id of a channel point (i.e. txid:0), most commands don't accept
+// channel points.
+func (client *Client) ChannelIdByChannelPoint(ctx context.Context, chanPoint string) (string, error) {
+	channels, err := client.ListPeerChannels(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, channel := range channels {
+		if channelPoint(channel.FundingTxid, channel.FundingOutnum) == chanPoint {
+			return channel.ChannelId, nil
+		}
+	}
+	return "", errors.Newf("Channel not found for channel point: %v", chanPoint)
+}
+
+type ClosedChannel struct {
+	PeerId             string `json:"peer_id"`
+	ChannelId          string `json:"channel_id"`
+	ShortChannelId     string `json:"short_channel_id"`
+	Opener             string `json:"opener"`
+	Closer             string `json:"closer"`
+	Private            bool   `json:"private"`
+	FundingTxid        string `json:"funding_txid"`
+	FundingOutnum      uint32 `json:"funding_outnum"`
+	TotalMsat          Msat   `json:"total_msat"`
+	FinalToUsMsat      Msat   `json:"final_to_us_msat"`
+	CloseCause         string `json:"close_cause"`
+	LastCommitmentTxid string `json:"last_commitment_txid"`
+}
+
+func (client *Client) ListClosedChannels(ctx context.Context) ([]ClosedChannel, error) {
+	var resp struct {
+		ClosedChannels []ClosedChannel `json:"closedchannels"`
+	}
+	err := client.Call(ctx, "listclosedchannels", nil, &resp)
+	return resp.ClosedChannels, err
+}
+
+type Forward struct {
+	CreatedIndex uint64  `json:"created_index"`
+	UpdatedIndex uint64  `json:"updated_index"`
+	InChannel    string  `json:"in_channel"`
+	InHtlcId     uint64  `json:"in_htlc_id"`
+	InMsat       Msat    `json:"in_msat"`
+	Status       string  `json:"status"`
+	ReceivedTime float64 `json:"received_time"`
+	OutChannel   string  `json:"out_channel"`
+	OutHtlcId    *uint64 `json:"out_htlc_id"`
+	FeeMsat      Msat    `json:"fee_msat"`
+	OutMsat      Msat    `json:"out_msat"`
+	ResolvedTime float64 `json:"resolved_time"`
+	FailCode     uint32  `json:"failcode"`
+	FailReason   string  `json:"failreason"`
+}
+
+const (
+	ForwardOffered     = "offered"
+	ForwardSettled     = "settled"
+	ForwardFailed      = "failed"
+	ForwardLocalFailed = "local_failed"
+)
+
+type ListForwardsRequest struct {
+	Status string `json:"status,omitempty"`
+	// Index is "created" or "updated", Start and Limit page through that index.
+	Index string `json:"index,omitempty"`
+	Start uint64 `json:"start,omitempty"`
+	Limit uint32 `json:"limit,omitempty"`
+}
+
+func (client *Client) ListForwards(ctx context.Context, req ListForwardsRequest) ([]Forward, error) {
+	var resp struct {
+		Forwards []Forward `json:"forwards"`
+	}
+	err := client.Call(ctx, "listforwards", req, &resp)
+	return resp.Forwards, err
+}
+
+// SendPay is a single part (HTLC) of a payment, all parts of a payment share the payment hash and group id.
+type SendPay struct {
+	CreatedIndex    uint64 `json:"created_index"`
+	Id              uint64 `json:"id"`
+	GroupId         uint64 `json:"groupid"`
+	PartId          uint64 `json:"partid"`
+	PaymentHash     string `json:"payment_hash"`
+	Status          string `json:"status"`
+	AmountMsat      Msat   `json:"amount_msat"`
+	AmountSentMsat  Msat   `json:"amount_sent_msat"`
+	Destination     string `json:"destination"`
+	CreatedAt       int64  `json:"created_at"`
+	CompletedAt     int64  `json:"completed_at"`
+	Bolt11          string `json:"bolt11"`
+	Bolt12          string `json:"bolt12"`
+	PaymentPreimage string `json:"payment_preimage"`
+}
+
+const (
+	SendPayPending  = "pending"
+	SendPayFailed   = "failed"
+	SendPayComplete = "complete"
+)
+
+type ListSendPaysRequest struct {
+	PaymentHash string `json:"payment_hash,omitempty"`
+	Index       string `json:"index,omitempty"`
+	Start       uint64 `json:"start,omitempty"`
+	Limit       uint32 `json:"limit,omitempty"`
+}
+
+func (client *Client) ListSendPays(ctx context.Context, req ListSendPaysRequest) ([]SendPay, error) {
+	var resp struct {
+		Payments []SendPay `json:"payments"`
+	}
+	err := client.Call(ctx, "listsendpays", req, &resp)
+	return resp.Payments, err
+}
+
+type Invoice struct {
+	Label              string `json:"label"`
+	Description        string `json:"description"`
+	PaymentHash        string `json:"payment_hash"`
+	Status             string `json:"status"`
+	ExpiresAt          int64  `json:"expires_at"`
+	AmountMsat         Msat   `json:"amount_msat"`
+	Bolt11             string `json:"bolt11"`
+	Bolt12             string `json:"bolt12"`
+	CreatedIndex       uint64 `json:"created_index"`
+	PayIndex           uint64 `json:"pay_index"`
+	AmountReceivedMsat Msat   `json:"amount_received_msat"`
+	PaidAt             int64  `json:"paid_at"`
+	PaymentPreimage    string `json:"payment_preimage"`
+}
+
+const (
+	InvoiceUnpaid  = "unpaid"
+	InvoicePaid    = "paid"
+	InvoiceExpired = "expired"
+)
+
+type ListInvoicesRequest struct {
+	Index string `json:"index,omitempty"`
+	Start uint64 `json:"start,omitempty"`
+	Limit uint32 `json:"limit,omitempty"`
+}
+
+func (client *Client) ListInvoices(ctx context.Context, req ListInvoicesRequest) ([]Invoice, error) {
+	var resp struct {
+		Invoices []Invoice `json:"invoices"`
+	}
+	err := client.Call(ctx, "listinvoices", req, &resp)
+	return resp.Invoices, err
+}
+
+// waitAnyInvoiceTimeout is the error code of waitanyinvoice when no invoice was paid within the timeout.
+const waitAnyInvoiceTimeout = 904
+
+// WaitAnyInvoice returns the first invoice paid after lastPayIndex, nil when none was paid within timeoutSeconds.
+func (client *Client) WaitAnyInvoice(ctx context.Context, lastPayIndex uint64, timeoutSeconds uint64) (*Invoice, error) {
+	var resp Invoice
+	err := client.Call(ctx, "waitanyinvoice", map[string]interface{}{
+		"lastpay_index": lastPayIndex,
+		"timeout":       timeoutSeconds,
+	}, &resp)
+	if err != nil {
+		var clnErr *Error
+		if errors.As(err, &clnErr) && clnErr.Code == waitAnyInvoiceTimeout {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GossipChannel is one direction of a channel as known in the gossip of the node.
+type GossipChannel struct {
+	Source              string `json:"source"`
+	Destination         string `json:"destination"`
+	ShortChannelId      string `json:"short_channel_id"`
+	Direction           uint32 `json:"direction"`
+	Public              bool   `json:"public"`
+	AmountMsat          Msat   `json:"amount_msat"`
+	Active              bool   `json:"active"`
+	LastUpdate          uint32 `json:"last_update"`
+	BaseFeeMillisatoshi uint32 `json:"base_fee_millisatoshi"`
+	FeePerMillionth     uint32 `json:"fee_per_millionth"`
+	Delay               uint32 `json:"delay"`
+	HtlcMinimumMsat     Msat   `json:"htlc_minimum_msat"`
+	HtlcMaximumMsat     Msat   `json:"htlc_maximum_msat"`
+}
+
+type ListChannelsRequest struct {
+	ShortChannelId string `json:"short_channel_id,omitempty"`
+	Source         string `json:"source,omitempty"`
+	Destination    string `json:"destination,omitempty"`
+}
+
+func (client *Client) ListChannels(ctx context.Context, req ListChannelsRequest) ([]GossipChannel, error) {
+	var resp struct {
+		Channels []GossipChannel `json:"channels"`
+	}
+	err := client.Call(ctx, "listchannels", req, &resp)
+	return resp.Channels, err
+}
+
+type NodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+	Port    uint32 `json:"port"`
+}
+
+type GossipNode struct {
+	NodeId        string        `json:"nodeid"`
+	LastTimestamp uint32        `json:"last_timestamp"`
+	Alias         string        `json:"alias"`
+	Color         string        `json:"color"`
+	Features      string        `json:"features"`
+	Addresses     []NodeAddress `json:"addresses"`
+}
+
+// ListNodes returns the gossip of a single node, the result is empty when the node is unknown.
+func (client *Client) ListNodes(ctx context.Context, id string) ([]GossipNode, error) {
+	var resp struct {
+		Nodes []GossipNode `json:"nodes"`
+	}
+	err := client.Call(ctx, "listnodes", map[string]interface{}{"id": id}, &resp)
+	return resp.Nodes, err
+}
+
+type Peer struct {
+	Id        string   `json:"id"`
+	Connected bool     `json:"connected"`
+	NetAddr   []string `json:"netaddr"`
+}
+
+func (client *Client) ListPeers(ctx context.Context) ([]Peer, error) {
+	var resp struct {
+		Peers []Peer `json:"peers"`
+	}
+	err := client.Call(ctx, "listpeers", nil, &resp)
+	return resp.Peers, err
+}
+
+type TransactionInput struct {
+	Txid  string `json:"txid"`
+	Index uint32 `json:"index"`
+}
+
+type TransactionOutput struct {
+	Index        uint32 `json:"index"`
+	AmountMsat   Msat   `json:"amount_msat"`
+	ScriptPubKey string `json:"scriptPubKey"`
+}
+
+type Transaction struct {
+	Hash        string              `json:"hash"`
+	RawTx       string              `json:"rawtx"`
+	BlockHeight uint32              `json:"blockheight"`
+	Inputs      []TransactionInput  `json:"inputs"`
+	Outputs     []TransactionOutput `json:"outputs"`
+}
+
+func (client *Client) ListTransactions(ctx context.Context) ([]Transaction, error) {
+	var resp struct {
+		Transactions []Transaction `json:"transactions"`
+	}
+	err := client.Call(ctx, "listtransactions", nil, &resp)
+	return resp.Transactions, err
+}
+
+// AccountEvent is a balance change recorded by the bookkeeper plugin (enabled by default).
+type AccountEvent struct {
+	Account     string `json:"account"`
+	Type        string `json:"type"`
+	Tag         string `json:"tag"`
+	CreditMsat  Msat   `json:"credit_msat"`
+	DebitMsat   Msat   `json:"debit_msat"`
+	Timestamp   int64  `json:"timestamp"`
+	Outpoint    string `json:"outpoint"`
+	Txid        string `json:"txid"`
+	BlockHeight uint32 `json:"blockheight"`
+}
+
+func (client *Client) ListAccountEvents(ctx context.Context) ([]AccountEvent, error) {
+	var resp struct {
+		Events []AccountEvent `json:"events"`
+	}
+	err := client.Call(ctx, "bkpr-listaccountevents", nil, &resp)
+	return resp.Events, err
+}
+
+type SetChannelRequest struct {
+	// Id is a peer id, a channel id, a short channel id or "all"
+	Id          string  `json:"id"`
+	FeeBase     *Msat   `json:"feebase,omitempty"`
+	FeePpm      *uint32 `json:"feeppm,omitempty"`
+	HtlcMinimum *Msat   `json:"htlcmin,omitempty"`
+	HtlcMaximum *Msat   `json:"htlcmax,omitempty"`
+}
+
+type SetChannelResult struct {
+	PeerId         string `json:"peer_id"`
+	ChannelId      string `json:"channel_id"`
+	ShortChannelId string `json:"short_channel_id"`
+	Warning        string `json:"warning_htlcmin_too_low"`
+}
+
+func (client *Client) SetChannel(ctx context.Context, req SetChannelRequest) ([]SetChannelResult, error) {
+	var resp struct {
+		Channels []SetChannelResult `json:"channels"`
+	}
+	err := client.Call(ctx, "setchannel", req, &resp)
+	return resp.Channels, err
+}
+
+type InvoiceRequest struct {
+	// AmountMsat is a number of millisatoshis or "any"
+	AmountMsat  interface{} `json:"amount_msat"`
+	Label       string      `json:"label"`
+	Description string      `json:"description"`
+	Expiry      *int64      `json:"expiry,omitempty"`
+	Fallbacks   []string    `json:"fallbacks,omitempty"`
+	Preimage    string      `json:"preimage,omitempty"`
+}
+
+type InvoiceResponse struct {
+	Bolt11        string `json:"bolt11"`
+	PaymentHash   string `json:"payment_hash"`
+	PaymentSecret string `json:"payment_secret"`
+	ExpiresAt     int64  `json:"expires_at"`
+	CreatedIndex  uint64 `json:"created_index"`
+}
+
+func (client *Client) Invoice(ctx context.Context, req InvoiceRequest) (InvoiceResponse, error) {
+	var resp InvoiceResponse
+	err := client.Call(ctx, "invoice", req, &resp)
+	return resp, err
+}
+
+type PayRequest struct {
+	Bolt11     string `json:"bolt11"`
+	AmountMsat *Msat  `json:"amount_msat,omitempty"`
+	MaxFee     *Msat  `json:"maxfee,omitempty"`
+	RetryFor   *int32 `json:"retry_for,omitempty"`
+}
+
+type PayResponse struct {
+	PaymentPreimage string  `json:"payment_preimage"`
+	PaymentHash     string  `json:"payment_hash"`
+	Destination     string  `json:"destination"`
+	CreatedAt       float64 `json:"created_at"`
+	Parts           uint32  `json:"parts"`
+	AmountMsat      Msat    `json:"amount_msat"`
+	AmountSentMsat  Msat    `json:"amount_sent_msat"`
+	Status          string  `json:"status"`
+}
+
+// Pay pays a bolt11 invoice and returns once the payment succeeded or failed for good.
+func (client *Client) Pay(ctx context.Context, req PayRequest) (PayResponse, error) {
+	var resp PayResponse
+	err := client.Call(ctx, "pay", req, &resp)
+	return resp, err
+}
+
+type ConnectRequest struct {
+	Id   string `json:"id"`
+	Host string `json:"host,omitempty"`
+}
+
+func (client *Client) Connect(ctx context.Context, req ConnectRequest) error {
+	return client.Call(ctx, "connect", req, nil)
+}
+
+type FundChannelRequest struct {
+	Id       string `json:"id"`
+	Amount   int64  `json:"amount"`
+	FeeRate  string `json:"feerate,omitempty"`
+	Announce *bool  `json:"announce,omitempty"`
+	MinConf  *int32 `json:"minconf,omitempty"`
+	PushMsat *Msat  `json:"push_msat,omitempty"`
+	CloseTo  string `json:"close_to,omitempty"`
+}
+
+type FundChannelResponse struct {
+	Tx        string `json:"tx"`
+	Txid      string `json:"txid"`
+	Outnum    uint32 `json:"outnum"`
+	ChannelId string `json:"channel_id"`
+}
+
+func (client *Client) FundChannel(ctx context.Context, req FundChannelRequest) (FundChannelResponse, error) {
+	var resp FundChannelResponse
+	err := client.Call(ctx, "fundchannel", req, &resp)
+	return resp, err
+}
+
+type CloseRequest struct {
+	// Id is a peer id, a channel id or a short channel id
+	Id string `json:"id"`
+	// UnilateralTimeout is the number of seconds before a mutual close turns into a force close, 0 waits forever.
+	UnilateralTimeout *uint32  `json:"unilateraltimeout,omitempty"`
+	Destination       string   `json:"destination,omitempty"`
+	FeeRange          []string `json:"feerange,omitempty"`
+}
+
+type CloseResponse struct {
+	// Type is "mutual", "unilateral" or "unopened"
+	Type string `json:"type"`
+	Tx   string `json:"tx"`
+	Txid string `json:"txid"`
+}
+
+func (client *Client) Close(ctx context.Context, req CloseRequest) (CloseResponse, error) {
+	var resp CloseResponse
+	err := client.Call(ctx, "close", req, &resp)
+	return resp, err
+}
+
+type MultiFundChannelDestination struct {
+	Id       string `json:"id"`
+	Amount   int64  `json:"amount"`
+	Announce *bool  `json:"announce,omitempty"`
+	PushMsat *Msat  `json:"push_msat,omitempty"`
+	CloseTo  string `json:"close_to,omitempty"`
+}
+
+type MultiFundChannelRequest struct {
+	Destinations []MultiFundChannelDestination `json:"destinations"`
+	FeeRate      string                        `json:"feerate,omitempty"`
+	MinConf      *int32                        `json:"minconf,omitempty"`
+}
+
+type MultiFundChannelResponse struct {
+	Tx         string `json:"tx"`
+	Txid       string `json:"txid"`
+	ChannelIds []struct {
+		Id        string `json:"id"`
+		Outnum    uint32 `json:"outnum"`
+		ChannelId string `json:"channel_id"`
+	} `json:"channel_ids"`
+}
+
+// MultiFundChannel opens channels to several peers with a single funding transaction.
+func (client *Client) MultiFundChannel(ctx context.Context,
+	req MultiFundChannelRequest) (MultiFundChannelResponse, error) {
+
+	var resp MultiFundChannelResponse
+	err := client.Call(ctx, "multifundchannel", req, &resp)
+	return resp, err
+}
+
+type FundsOutput struct {
+	Txid       string `json:"txid"`
+	Output     uint32 `json:"output"`
+	AmountMsat Msat   `json:"amount_msat"`
+	// Status is "unconfirmed", "confirmed" or "spent"
+	Status   string `json:"status"`
+	Reserved bool   `json:"reserved"`
+}
+
+func (client *Client) ListFunds(ctx context.Context) ([]FundsOutput, error) {
+	var resp struct {
+		Outputs []FundsOutput `json:"outputs"`
+	}
+	err := client.Call(ctx, "listfunds", nil, &resp)
+	return resp.Outputs, err
+}
+
+type WithdrawRequest struct {
+	Destination string `json:"destination"`
+	// Satoshi is a number of satoshis or "all"
+	Satoshi interface{} `json:"satoshi"`
+	FeeRate string      `json:"feerate,omitempty"`
+	MinConf *int32      `json:"minconf,omitempty"`
+}
+
+type WithdrawResponse struct {
+	Tx   string `json:"tx"`
+	Txid string `json:"txid"`
+}
+
+func (client *Client) Withdraw(ctx context.Context, req WithdrawRequest) (WithdrawResponse, error) {
+	var resp WithdrawResponse
+	err := client.Call(ctx, "withdraw", req, &resp)
+	return resp, err
+}
+
+type NewAddrResponse struct {
+	Bech32 string `json:"bech32"`
+	P2tr   string `json:"p2tr"`
+}
+
+// NewAddr creates a new wallet address, the address type is "bech32" or "p2tr".
+func (client *Client) NewAddr(ctx context.Context, addressType string) (NewAddrResponse, error) {
+	var resp NewAddrResponse
+	err := client.Call(ctx, "newaddr", map[string]string{"addresstype": addressType}, &resp)
+	return resp, err
+}
+
+type SignMessageResponse struct {
+	Signature string `json:"signature"`
+	RecId     string `json:"recid"`
+	// Zbase is the signature in the zbase32 format that LND uses as well.
+	Zbase string `json:"zbase"`
+}
+
+func (client *Client) SignMessage(ctx context.Context, message string) (SignMessageResponse, error) {
+	var resp SignMessageResponse
+	err := client.Call(ctx, "signmessage", map[string]string{"message": message}, &resp)
+	return resp, err
+}
+
+type CheckMessageResponse struct {
+	Pubkey   string `json:"pubkey"`
+	Verified bool   `json:"verified"`
+}
+
+func (client *Client) CheckMessage(ctx context.Context, message string, zbase string) (CheckMessageResponse, error) {
+	var resp CheckMessageResponse
+	err := client.Call(ctx, "checkmessage", map[string]string{"message": message, "zbase": zbase}, &resp)
+	return resp, err
+}
@@ -0,0 +1,48 @@
+package cln
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ConnectionDetailsFunc returns what's needed to reach a node, it's only called when the node has no client yet.
+type ConnectionDetailsFunc func() (address string, caCertificate []byte, rune string, err error)
+
+//nolint:gochecknoglobals
+var (
+	clients   = make(map[int]*Client)
+	clientsMu sync.Mutex
+)
+
+// GetClient returns the shared client of a node, the underlying HTTP connections are reused between requests.
+func GetClient(nodeId int, connectionDetails ConnectionDetailsFunc) (*Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if client, exists := clients[nodeId]; exists {
+		return client, nil
+	}
+	address, caCertificate, rune, err := connectionDetails()
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(address, caCertificate, rune)
+	if err != nil {
+		return nil, err
+	}
+	clients[nodeId] = client
+	return client, nil
+}
+
+// CloseClient drops the shared client of a node (i.e. when its connection details changed or it was removed).
+func CloseClient(nodeId int) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	client, exists := clients[nodeId]
+	if !exists {
+		return
+	}
+	if transport, ok := client.httpClient.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+	delete(clients, nodeId)
+}
@@ -0,0 +1,50 @@
+package cln
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetClient(t *testing.T) {
+	nodeId := 2001
+	defer CloseClient(nodeId)
+	node := &recordedNode{t: t, requests: make(map[string][]string), responses: map[string][]string{
+		"getinfo": {`{"id":"02ab","alias":"cln","color":"ff9900","network":"regtest","blockheight":101}`},
+	}}
+	server := httptest.NewTLSServer(node)
+	defer server.Close()
+	caCertificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	dials := 0
+	details := func() (string, []byte, string, error) {
+		dials++
+		// A rune pasted with a trailing newline is still accepted.
+		return server.Listener.Addr().String(), caCertificate, "test-rune\n", nil
+	}
+	client, err := GetClient(nodeId, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.GetInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetInfo() error: %v", err)
+	}
+	if info.Id != "02ab" {
+		t.Errorf("GetInfo()\nGot:\n%v\nWant:\n%v\n", info.Id, "02ab")
+	}
+	if _, err = GetClient(nodeId, details); err != nil {
+		t.Fatal(err)
+	}
+	if dials != 1 {
+		t.Errorf("GetClient() twice\nGot:\n%v dials\nWant:\n%v dials\n", dials, 1)
+	}
+
+	if _, err = NewClient(server.Listener.Addr().String(), []byte("not a certificate"), "test-rune"); err == nil {
+		t.Errorf("NewClient() without CA certificate\nGot:\n%v\nWant:\n%v\n", nil, "error")
+	}
+	if _, err = NewClient(server.Listener.Addr().String(), caCertificate, " "); err == nil {
+		t.Errorf("NewClient() without rune\nGot:\n%v\nWant:\n%v\n", nil, "error")
+	}
+}
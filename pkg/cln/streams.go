@@ -0,0 +1,675 @@
+package cln
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// pollStream emulates a server stream, it polls the node and hands out the resulting updates one by one.
+// The first poll happens immediately, later polls wait for the interval.
+type pollStream struct {
+	ctx      context.Context
+	interval time.Duration
+	poll     func(ctx context.Context) ([]interface{}, error)
+	polled   bool
+	queue    []interface{}
+}
+
+func (s *pollStream) recv() (interface{}, error) {
+	for len(s.queue) == 0 {
+		if s.polled {
+			select {
+			case <-s.ctx.Done():
+				return nil, s.ctx.Err()
+			case <-time.After(s.interval):
+			}
+		}
+		s.polled = true
+		updates, err := s.poll(s.ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.queue = updates
+	}
+	update := s.queue[0]
+	s.queue = s.queue[1:]
+	return update, nil
+}
+
+func (s *pollStream) Header() (metadata.MD, error) { return nil, nil }
+func (s *pollStream) Trailer() metadata.MD         { return nil }
+func (s *pollStream) CloseSend() error             { return nil }
+func (s *pollStream) Context() context.Context     { return s.ctx }
+func (s *pollStream) SendMsg(m interface{}) error  { return errors.New("Sending is not supported") }
+func (s *pollStream) RecvMsg(m interface{}) error  { return errors.New("Use Recv instead") }
+
+// sliceStream sends the given updates and ends.
+func sliceStream(ctx context.Context, updates ...interface{}) pollStream {
+	return pollStream{ctx: ctx, polled: true, queue: updates,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			return nil, io.EOF
+		}}
+}
+
+type openChannelStream struct{ pollStream }
+
+func (s *openChannelStream) Recv() (*lnrpc.OpenStatusUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.OpenStatusUpdate), nil
+}
+
+type closeChannelStream struct{ pollStream }
+
+func (s *closeChannelStream) Recv() (*lnrpc.CloseStatusUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.CloseStatusUpdate), nil
+}
+
+type paymentStream struct{ pollStream }
+
+func (s *paymentStream) Recv() (*lnrpc.Payment, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.Payment), nil
+}
+
+type channelEventStream struct{ pollStream }
+
+func (s *channelEventStream) Recv() (*lnrpc.ChannelEventUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.ChannelEventUpdate), nil
+}
+
+// SubscribeChannelEvents compares the channels of the node on every poll with the previous poll.
+// Only changes are sent, the channels that exist when subscribing are imported separately.
+func (l *Lightning) SubscribeChannelEvents(ctx context.Context, in *lnrpc.ChannelEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelEventsClient, error) {
+
+	var previous map[string]PeerChannel
+	return &channelEventStream{pollStream{ctx: ctx, interval: l.PollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			peerChannels, err := l.client.ListPeerChannels(ctx)
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing peer channels")
+			}
+			current := make(map[string]PeerChannel)
+			for _, peerChannel := range peerChannels {
+				current[channelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum)] = peerChannel
+			}
+			if previous == nil {
+				previous = current
+				return nil, nil
+			}
+			updates, err := channelEventUpdates(previous, peerChannels)
+			previous = current
+			return updates, err
+		}}}, nil
+}
+
+func channelEventUpdates(previous map[string]PeerChannel, peerChannels []PeerChannel) ([]interface{}, error) {
+	var updates []interface{}
+	seen := make(map[string]bool)
+	for _, peerChannel := range peerChannels {
+		key := channelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum)
+		seen[key] = true
+		old, known := previous[key]
+		chanPoint, err := lnrpcChannelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !known && isPendingOpen(peerChannel.State):
+			updates = append(updates, &lnrpc.ChannelEventUpdate{
+				Type: lnrpc.ChannelEventUpdate_PENDING_OPEN_CHANNEL,
+				Channel: &lnrpc.ChannelEventUpdate_PendingOpenChannel{PendingOpenChannel: &lnrpc.PendingUpdate{
+					Txid:        chanPoint.GetFundingTxidBytes(),
+					OutputIndex: chanPoint.OutputIndex,
+				}},
+			})
+		case peerChannel.State == stateNormal && (!known || old.State != stateNormal):
+			channel, err := lnrpcChannel(peerChannel)
+			if err != nil {
+				return nil, err
+			}
+			updates = append(updates, &lnrpc.ChannelEventUpdate{
+				Type:    lnrpc.ChannelEventUpdate_OPEN_CHANNEL,
+				Channel: &lnrpc.ChannelEventUpdate_OpenChannel{OpenChannel: channel},
+			})
+		case known && isClosed(peerChannel.State) && !isClosed(old.State):
+			closed, err := closedChannelSummary(peerChannel, closeType(old.State, peerChannel.State, peerChannel.Closer))
+			if err != nil {
+				return nil, err
+			}
+			updates = append(updates, closed)
+		case known && peerChannel.State == stateNormal && old.PeerConnected != peerChannel.PeerConnected:
+			if peerChannel.PeerConnected {
+				updates = append(updates, &lnrpc.ChannelEventUpdate{
+					Type:    lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL,
+					Channel: &lnrpc.ChannelEventUpdate_ActiveChannel{ActiveChannel: chanPoint},
+				})
+			} else {
+				updates = append(updates, &lnrpc.ChannelEventUpdate{
+					Type:    lnrpc.ChannelEventUpdate_INACTIVE_CHANNEL,
+					Channel: &lnrpc.ChannelEventUpdate_InactiveChannel{InactiveChannel: chanPoint},
+				})
+			}
+		}
+	}
+
+	// A channel that is no longer listed was forgotten, i.e. a pending open that never confirmed.
+	var forgotten []string
+	for key := range previous {
+		if !seen[key] {
+			forgotten = append(forgotten, key)
+		}
+	}
+	sort.Strings(forgotten)
+	for _, key := range forgotten {
+		old := previous[key]
+		if isClosed(old.State) {
+			continue
+		}
+		ct := lnrpc.ChannelCloseSummary_FUNDING_CANCELED
+		if old.State == stateNormal {
+			ct = closeType(old.State, stateOnchain, old.Closer)
+		}
+		closed, err := closedChannelSummary(old, ct)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, closed)
+	}
+	return updates, nil
+}
+
+func closedChannelSummary(peerChannel PeerChannel,
+	ct lnrpc.ChannelCloseSummary_ClosureType) (*lnrpc.ChannelEventUpdate, error) {
+
+	chanId, err := ShortChannelIdToLND(peerChannel.ShortChannelId)
+	if err != nil {
+		return nil, err
+	}
+	return &lnrpc.ChannelEventUpdate{
+		Type: lnrpc.ChannelEventUpdate_CLOSED_CHANNEL,
+		Channel: &lnrpc.ChannelEventUpdate_ClosedChannel{ClosedChannel: &lnrpc.ChannelCloseSummary{
+			ChannelPoint:   channelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum),
+			ChanId:         chanId,
+			RemotePubkey:   peerChannel.PeerId,
+			Capacity:       peerChannel.TotalMsat.Sat(),
+			SettledBalance: peerChannel.ToUsMsat.Sat(),
+			CloseType:      ct,
+			OpenInitiator:  lnrpcInitiator(peerChannel.Opener),
+			CloseInitiator: lnrpcInitiator(peerChannel.Closer),
+		}},
+	}, nil
+}
+
+type channelGraphStream struct{ pollStream }
+
+func (s *channelGraphStream) Recv() (*lnrpc.GraphTopologyUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.GraphTopologyUpdate), nil
+}
+
+// SubscribeChannelGraph sends the gossip of the channels of the node and of its peers when it changed.
+// The first poll sends everything, the graph updates are only stored when they differ from the stored ones.
+func (l *Lightning) SubscribeChannelGraph(ctx context.Context, in *lnrpc.GraphTopologySubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelGraphClient, error) {
+
+	channelUpdates := make(map[string]uint32)
+	nodeUpdates := make(map[string]uint32)
+	return &channelGraphStream{pollStream{ctx: ctx, interval: l.GraphPollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			info, err := l.getInfo(ctx)
+			if err != nil {
+				return nil, err
+			}
+			peerChannels, err := l.client.ListPeerChannels(ctx)
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing peer channels")
+			}
+			chanPoints := make(map[string]*lnrpc.ChannelPoint)
+			var peers []string
+			for _, peerChannel := range peerChannels {
+				if peerChannel.ShortChannelId == "" {
+					continue
+				}
+				chanPoint, err := lnrpcChannelPoint(peerChannel.FundingTxid, peerChannel.FundingOutnum)
+				if err != nil {
+					return nil, err
+				}
+				if _, exists := chanPoints[peerChannel.ShortChannelId]; !exists && !containsPeer(peers, peerChannel.PeerId) {
+					peers = append(peers, peerChannel.PeerId)
+				}
+				chanPoints[peerChannel.ShortChannelId] = chanPoint
+			}
+
+			update := &lnrpc.GraphTopologyUpdate{}
+			outgoing, err := l.client.ListChannels(ctx, ListChannelsRequest{Source: info.Id})
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing outgoing channels")
+			}
+			incoming, err := l.client.ListChannels(ctx, ListChannelsRequest{Destination: info.Id})
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing incoming channels")
+			}
+			for _, gossipChannel := range append(outgoing, incoming...) {
+				chanPoint, exists := chanPoints[gossipChannel.ShortChannelId]
+				key := gossipChannel.ShortChannelId + "/" + gossipChannel.Source
+				if !exists || channelUpdates[key] == gossipChannel.LastUpdate {
+					continue
+				}
+				channelUpdates[key] = gossipChannel.LastUpdate
+				edgeUpdate, err := lnrpcChannelEdgeUpdate(gossipChannel, chanPoint)
+				if err != nil {
+					return nil, err
+				}
+				update.ChannelUpdates = append(update.ChannelUpdates, edgeUpdate)
+			}
+
+			for _, peer := range peers {
+				gossipNodes, err := l.client.ListNodes(ctx, peer)
+				if err != nil {
+					return nil, errors.Wrap(err, "Listing nodes")
+				}
+				if len(gossipNodes) == 0 || nodeUpdates[peer] == gossipNodes[0].LastTimestamp {
+					continue
+				}
+				nodeUpdates[peer] = gossipNodes[0].LastTimestamp
+				node := lnrpcNode(gossipNodes[0])
+				update.NodeUpdates = append(update.NodeUpdates, &lnrpc.NodeUpdate{
+					IdentityKey:   node.PubKey,
+					Alias:         node.Alias,
+					Color:         node.Color,
+					NodeAddresses: node.Addresses,
+					Features:      node.Features,
+				})
+			}
+			if len(update.ChannelUpdates) == 0 && len(update.NodeUpdates) == 0 {
+				return nil, nil
+			}
+			return []interface{}{update}, nil
+		}}}, nil
+}
+
+type invoiceStream struct{ pollStream }
+
+func (s *invoiceStream) Recv() (*lnrpc.Invoice, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.Invoice), nil
+}
+
+// SubscribeInvoices sends the invoices created after the add index and the invoices paid after the settle index,
+// like LND an invoice that is created and paid is sent twice.
+func (l *Lightning) SubscribeInvoices(ctx context.Context, in *lnrpc.InvoiceSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeInvoicesClient, error) {
+
+	addIndex := in.AddIndex
+	settleIndex := in.SettleIndex
+	return &invoiceStream{pollStream{ctx: ctx, interval: l.PollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			info, err := l.getInfo(ctx)
+			if err != nil {
+				return nil, err
+			}
+			params := ChainParams(info.Network)
+			var updates []interface{}
+			created, err := l.client.ListInvoices(ctx, ListInvoicesRequest{Index: "created", Start: addIndex + 1})
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing invoices")
+			}
+			for _, invoice := range created {
+				if invoice.CreatedIndex <= addIndex {
+					continue
+				}
+				addIndex = invoice.CreatedIndex
+				updates = append(updates, lnrpcInvoice(invoice, params))
+			}
+			for {
+				paid, err := l.client.WaitAnyInvoice(ctx, settleIndex, 0)
+				if err != nil {
+					return nil, errors.Wrap(err, "Waiting for paid invoices")
+				}
+				if paid == nil {
+					break
+				}
+				settleIndex = paid.PayIndex
+				updates = append(updates, lnrpcInvoice(*paid, params))
+			}
+			return updates, nil
+		}}}, nil
+}
+
+type peerEventStream struct{ pollStream }
+
+func (s *peerEventStream) Recv() (*lnrpc.PeerEvent, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.PeerEvent), nil
+}
+
+// SubscribePeerEvents sends the peers that connected or disconnected since the previous poll.
+func (l *Lightning) SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error) {
+
+	var previous map[string]bool
+	return &peerEventStream{pollStream{ctx: ctx, interval: l.PollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			peers, err := l.client.ListPeers(ctx)
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing peers")
+			}
+			current := make(map[string]bool)
+			for _, peer := range peers {
+				current[peer.Id] = peer.Connected
+			}
+			var updates []interface{}
+			if previous != nil {
+				for _, peer := range peers {
+					if peer.Connected != previous[peer.Id] {
+						updates = append(updates, peerEvent(peer.Id, peer.Connected))
+					}
+				}
+				var gone []string
+				for id, connected := range previous {
+					if _, exists := current[id]; !exists && connected {
+						gone = append(gone, id)
+					}
+				}
+				sort.Strings(gone)
+				for _, id := range gone {
+					updates = append(updates, peerEvent(id, false))
+				}
+			}
+			previous = current
+			return updates, nil
+		}}}, nil
+}
+
+func peerEvent(id string, connected bool) *lnrpc.PeerEvent {
+	if connected {
+		return &lnrpc.PeerEvent{PubKey: id, Type: lnrpc.PeerEvent_PEER_ONLINE}
+	}
+	return &lnrpc.PeerEvent{PubKey: id, Type: lnrpc.PeerEvent_PEER_OFFLINE}
+}
+
+type transactionStream struct{ pollStream }
+
+func (s *transactionStream) Recv() (*lnrpc.Transaction, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.Transaction), nil
+}
+
+// SubscribeTransactions sends wallet transactions once they confirm, the transactions that are confirmed when
+// subscribing are imported separately.
+func (l *Lightning) SubscribeTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeTransactionsClient, error) {
+
+	var seen map[string]bool
+	return &transactionStream{pollStream{ctx: ctx, interval: l.PollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			transactions, err := l.transactions(ctx, 0)
+			if err != nil {
+				return nil, err
+			}
+			var updates []interface{}
+			if seen != nil {
+				for _, transaction := range transactions {
+					if !seen[transaction.TxHash] {
+						updates = append(updates, transaction)
+					}
+				}
+			} else {
+				seen = make(map[string]bool)
+			}
+			for _, transaction := range transactions {
+				seen[transaction.TxHash] = true
+			}
+			return updates, nil
+		}}}, nil
+}
+
+type htlcEventStream struct{ pollStream }
+
+func (s *htlcEventStream) Recv() (*routerrpc.HtlcEvent, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*routerrpc.HtlcEvent), nil
+}
+
+// SubscribeHtlcEvents sends the forwards that were offered or resolved since subscribing.
+// Core Lightning only records forwards, HTLCs of payments and invoices are not sent.
+func (l *Lightning) SubscribeHtlcEvents(ctx context.Context, in *routerrpc.SubscribeHtlcEventsRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SubscribeHtlcEventsClient, error) {
+
+	initialized := false
+	var lastCreated, lastUpdated uint64
+	return &htlcEventStream{pollStream{ctx: ctx, interval: l.PollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			if !initialized {
+				forwards, err := l.client.ListForwards(ctx, ListForwardsRequest{})
+				if err != nil {
+					return nil, errors.Wrap(err, "Listing forwards")
+				}
+				for _, forward := range forwards {
+					lastCreated = maxUint64(lastCreated, forward.CreatedIndex)
+					lastUpdated = maxUint64(lastUpdated, forward.UpdatedIndex)
+				}
+				initialized = true
+				return nil, nil
+			}
+
+			var updates []interface{}
+			created, err := l.client.ListForwards(ctx, ListForwardsRequest{Index: "created", Start: lastCreated + 1})
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing created forwards")
+			}
+			for _, forward := range created {
+				lastCreated = maxUint64(lastCreated, forward.CreatedIndex)
+				// a forward that failed before it was offered to the outgoing channel is never updated
+				offered := forward.Status != ForwardLocalFailed || forward.UpdatedIndex != 0
+				event, err := htlcEvent(forward, offered)
+				if err != nil {
+					return nil, err
+				}
+				updates = append(updates, event)
+			}
+			updated, err := l.client.ListForwards(ctx, ListForwardsRequest{Index: "updated", Start: lastUpdated + 1})
+			if err != nil {
+				return nil, errors.Wrap(err, "Listing updated forwards")
+			}
+			for _, forward := range updated {
+				lastUpdated = maxUint64(lastUpdated, forward.UpdatedIndex)
+				if forward.Status == ForwardOffered {
+					continue
+				}
+				event, err := htlcEvent(forward, false)
+				if err != nil {
+					return nil, err
+				}
+				updates = append(updates, event)
+			}
+			return updates, nil
+		}}}, nil
+}
+
+func containsPeer(peers []string, peer string) bool {
+	for _, p := range peers {
+		if p == peer {
+			return true
+		}
+	}
+	return false
+}
+
+func maxUint64(a uint64, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// htlcEvent converts a forward to the offer (ForwardEvent) or to its resolution.
+func htlcEvent(forward Forward, offered bool) (*routerrpc.HtlcEvent, error) {
+	incomingChannelId, err := ShortChannelIdToLND(forward.InChannel)
+	if err != nil {
+		return nil, err
+	}
+	outgoingChannelId, err := ShortChannelIdToLND(forward.OutChannel)
+	if err != nil {
+		return nil, err
+	}
+	event := &routerrpc.HtlcEvent{
+		IncomingChannelId: incomingChannelId,
+		OutgoingChannelId: outgoingChannelId,
+		IncomingHtlcId:    forward.InHtlcId,
+		TimestampNs:       secondsToNanoseconds(forward.ResolvedTime),
+		EventType:         routerrpc.HtlcEvent_FORWARD,
+	}
+	if forward.OutHtlcId != nil {
+		event.OutgoingHtlcId = *forward.OutHtlcId
+	}
+	info := &routerrpc.HtlcInfo{
+		IncomingAmtMsat: uint64(forward.InMsat),
+		OutgoingAmtMsat: uint64(forward.OutMsat),
+	}
+	switch {
+	case offered:
+		event.TimestampNs = secondsToNanoseconds(forward.ReceivedTime)
+		event.Event = &routerrpc.HtlcEvent_ForwardEvent{ForwardEvent: &routerrpc.ForwardEvent{Info: info}}
+	case forward.Status == ForwardSettled:
+		event.Event = &routerrpc.HtlcEvent_SettleEvent{SettleEvent: &routerrpc.SettleEvent{}}
+	case forward.Status == ForwardFailed:
+		event.Event = &routerrpc.HtlcEvent_ForwardFailEvent{ForwardFailEvent: &routerrpc.ForwardFailEvent{}}
+	default:
+		if event.TimestampNs == 0 {
+			event.TimestampNs = secondsToNanoseconds(forward.ReceivedTime)
+		}
+		event.Event = &routerrpc.HtlcEvent_LinkFailEvent{LinkFailEvent: &routerrpc.LinkFailEvent{
+			Info:          info,
+			WireFailure:   lnrpcFailureCode(forward.FailCode),
+			FailureDetail: routerrpc.FailureDetail_UNKNOWN,
+			FailureString: forward.FailReason,
+		}}
+	}
+	return event, nil
+}
+
+// BOLT 4 failure code flags
+const (
+	failureBadOnion = 0x8000
+	failurePerm     = 0x4000
+	failureNode     = 0x2000
+	failureUpdate   = 0x1000
+)
+
+//nolint:gochecknoglobals
+var failureCodes = map[uint32]lnrpc.Failure_FailureCode{
+	failurePerm | 1:                   lnrpc.Failure_INVALID_REALM,
+	failureNode | 2:                   lnrpc.Failure_TEMPORARY_NODE_FAILURE,
+	failurePerm | failureNode | 2:     lnrpc.Failure_PERMANENT_NODE_FAILURE,
+	failurePerm | failureNode | 3:     lnrpc.Failure_REQUIRED_NODE_FEATURE_MISSING,
+	failureBadOnion | failurePerm | 4: lnrpc.Failure_INVALID_ONION_VERSION,
+	failureBadOnion | failurePerm | 5: lnrpc.Failure_INVALID_ONION_HMAC,
+	failureBadOnion | failurePerm | 6: lnrpc.Failure_INVALID_ONION_KEY,
+	failureUpdate | 7:                 lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE,
+	failurePerm | 8:                   lnrpc.Failure_PERMANENT_CHANNEL_FAILURE,
+	failurePerm | 9:                   lnrpc.Failure_REQUIRED_CHANNEL_FEATURE_MISSING,
+	failurePerm | 10:                  lnrpc.Failure_UNKNOWN_NEXT_PEER,
+	failureUpdate | 11:                lnrpc.Failure_AMOUNT_BELOW_MINIMUM,
+	failureUpdate | 12:                lnrpc.Failure_FEE_INSUFFICIENT,
+	failureUpdate | 13:                lnrpc.Failure_INCORRECT_CLTV_EXPIRY,
+	failureUpdate | 14:                lnrpc.Failure_EXPIRY_TOO_SOON,
+	failurePerm | 15:                  lnrpc.Failure_INCORRECT_OR_UNKNOWN_PAYMENT_DETAILS,
+	18:                                lnrpc.Failure_FINAL_INCORRECT_CLTV_EXPIRY,
+	19:                                lnrpc.Failure_FINAL_INCORRECT_HTLC_AMOUNT,
+	failureUpdate | 20:                lnrpc.Failure_CHANNEL_DISABLED,
+	21:                                lnrpc.Failure_EXPIRY_TOO_FAR,
+	failurePerm | 22:                  lnrpc.Failure_INVALID_ONION_PAYLOAD,
+	23:                                lnrpc.Failure_MPP_TIMEOUT,
+}
+
+// lnrpcFailureCode converts a BOLT 4 failure code, LND numbers the failures differently.
+func lnrpcFailureCode(failCode uint32) lnrpc.Failure_FailureCode {
+	if failCode == 0 {
+		return lnrpc.Failure_RESERVED
+	}
+	if code, exists := failureCodes[failCode]; exists {
+		return code
+	}
+	return lnrpc.Failure_UNKNOWN_FAILURE
+}
+
+func (l *Lightning) SubscribeChannelBackups(ctx context.Context, in *lnrpc.ChannelBackupSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelBackupsClient, error) {
+
+	return nil, status.Error(codes.Unimplemented, "Static channel backups are not supported by Core Lightning")
+}
+
+type blockEpochStream struct{ pollStream }
+
+func (s *blockEpochStream) Recv() (*chainrpc.BlockEpoch, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*chainrpc.BlockEpoch), nil
+}
+
+// RegisterBlockEpochNtfn polls the block height, the blocks come without hash because Core Lightning doesn't report
+// it.
+func (l *Lightning) RegisterBlockEpochNtfn(ctx context.Context, in *chainrpc.BlockEpoch,
+	opts ...grpc.CallOption) (chainrpc.ChainNotifier_RegisterBlockEpochNtfnClient, error) {
+
+	var height uint32
+	return &blockEpochStream{pollStream{ctx: ctx, interval: l.PollInterval,
+		poll: func(ctx context.Context) ([]interface{}, error) {
+			info, err := l.client.GetInfo(ctx)
+			if err != nil {
+				return nil, errors.Wrap(err, "Obtaining the block height from Core Lightning")
+			}
+			var updates []interface{}
+			if height == 0 && info.BlockHeight > 0 {
+				height = info.BlockHeight - 1
+			}
+			for ; height < info.BlockHeight; height++ {
+				updates = append(updates, &chainrpc.BlockEpoch{Height: height + 1})
+			}
+			return updates, nil
+		}}}, nil
+}
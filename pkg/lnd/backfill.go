@@ -0,0 +1,214 @@
+package lnd
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"go.uber.org/ratelimit"
+
+	"github.com/lncapital/torq/internal/backfill"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+const (
+	DefaultBackfillPagesPerSecond = 2
+	backfillForwardsPageSize      = 1000
+	// Failed payments contain all their attempts, a page of them can be large.
+	backfillPaymentsPageSize = 50
+	backfillInvoicesPageSize = 200
+)
+
+// BackfillOptions throttles a backfill so it doesn't starve the live ingestion of the node.
+type BackfillOptions struct {
+	// PagesPerSecond is the maximum number of requests to the node per second.
+	PagesPerSecond int
+}
+
+// backfillPage is the result of storing a page, done is true when the kind is imported completely.
+type backfillPage struct {
+	indexOffset uint64
+	stored      int
+	done        bool
+}
+
+// Backfill imports the forwards, payments, invoices and on-chain transactions of the date range of the job with the
+// same storage code as the subscriptions. A checkpoint is saved after every page so an interrupted job continues
+// where it stopped.
+func Backfill(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, job backfill.Job,
+	eventChannel chan interface{}, opt BackfillOptions) error {
+
+	pagesPerSecond := opt.PagesPerSecond
+	if pagesPerSecond <= 0 {
+		pagesPerSecond = DefaultBackfillPagesPerSecond
+	}
+	rl := ratelimit.New(pagesPerSecond)
+
+	for _, checkpoint := range job.Checkpoints {
+		for !checkpoint.Done {
+			rl.Take()
+			if ctx.Err() != nil {
+				return nil
+			}
+			var page backfillPage
+			var err error
+			switch checkpoint.Kind {
+			case backfill.Forwards:
+				page, err = backfillForwards(ctx, client, db, job, checkpoint.IndexOffset)
+			case backfill.Payments:
+				page, err = backfillPayments(ctx, client, db, job, checkpoint.IndexOffset)
+			case backfill.Invoices:
+				page, err = backfillInvoices(ctx, client, db, job, checkpoint.IndexOffset)
+			case backfill.Transactions:
+				page, err = backfillTransactions(ctx, client, db, job)
+			default:
+				return errors.Newf("Unknown backfill kind: %v", checkpoint.Kind)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.Wrapf(err, "Backfilling %v of node id: %v", checkpoint.Kind, job.NodeId)
+			}
+			checkpoint.IndexOffset = page.indexOffset
+			checkpoint.Stored += int64(page.stored)
+			checkpoint.Done = page.done
+			if err = backfill.SaveCheckpoint(db, job, checkpoint, eventChannel); err != nil {
+				return errors.Wrapf(err, "Saving %v checkpoint of backfill job %v", checkpoint.Kind, job.BackfillJobId)
+			}
+		}
+	}
+	return nil
+}
+
+func backfillForwards(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, job backfill.Job,
+	indexOffset uint64) (backfillPage, error) {
+
+	fwh, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+		StartTime:    uint64(job.FromTime.Unix()),
+		EndTime:      uint64(job.ToTime.Unix()),
+		IndexOffset:  uint32(indexOffset),
+		NumMaxEvents: backfillForwardsPageSize,
+	})
+	if err != nil {
+		return backfillPage{}, errors.Wrap(err, "Fetching forwarding history")
+	}
+	if err = storeForwardingHistory(db, fwh.ForwardingEvents, job.NodeId); err != nil {
+		return backfillPage{}, err
+	}
+	return backfillPage{
+		indexOffset: uint64(fwh.LastOffsetIndex),
+		stored:      len(fwh.ForwardingEvents),
+		done:        len(fwh.ForwardingEvents) < backfillForwardsPageSize,
+	}, nil
+}
+
+// backfillPayments pages through the payments from the first one, the payment index grows with the creation time so
+// the first payment after the date range ends the import.
+func backfillPayments(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, job backfill.Job,
+	indexOffset uint64) (backfillPage, error) {
+
+	resp, err := client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{
+		IncludeIncomplete: true,
+		IndexOffset:       indexOffset,
+		MaxPayments:       backfillPaymentsPageSize,
+	})
+	if err != nil {
+		return backfillPage{}, errors.Wrap(err, "Fetching payments")
+	}
+	page := backfillPage{indexOffset: indexOffset, done: len(resp.Payments) < backfillPaymentsPageSize}
+	var inRange []*lnrpc.Payment
+	for _, payment := range resp.Payments {
+		created := time.Unix(0, payment.CreationTimeNs)
+		if !created.Before(job.ToTime) {
+			page.done = true
+			break
+		}
+		page.indexOffset = payment.PaymentIndex
+		if !created.Before(job.FromTime) {
+			inRange = append(inRange, payment)
+		}
+	}
+	if err = storePayments(db, inRange, job.NodeId); err != nil {
+		return backfillPage{}, err
+	}
+	page.stored = len(inRange)
+	return page, nil
+}
+
+// backfillInvoices pages through the invoices from the first one, an invoice that is stored already in the same
+// state by the invoice subscription is skipped.
+func backfillInvoices(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, job backfill.Job,
+	indexOffset uint64) (backfillPage, error) {
+
+	resp, err := client.ListInvoices(ctx, &lnrpc.ListInvoiceRequest{
+		IndexOffset:    indexOffset,
+		NumMaxInvoices: backfillInvoicesPageSize,
+	})
+	if err != nil {
+		return backfillPage{}, errors.Wrap(err, "Fetching invoices")
+	}
+	page := backfillPage{indexOffset: indexOffset, done: len(resp.Invoices) < backfillInvoicesPageSize}
+	for _, invoice := range resp.Invoices {
+		created := time.Unix(invoice.CreationDate, 0)
+		if !created.Before(job.ToTime) {
+			page.done = true
+			break
+		}
+		page.indexOffset = invoice.AddIndex
+		if created.Before(job.FromTime) {
+			continue
+		}
+		stored, err := isInvoiceStored(db, job.NodeId, invoice)
+		if err != nil {
+			return backfillPage{}, err
+		}
+		if stored {
+			continue
+		}
+		err = insertInvoice(db, invoice, invoiceDestination(invoice.PaymentRequest), job.NodeId,
+			broadcast.InvoiceEvent{}, nil)
+		if err != nil {
+			return backfillPage{}, err
+		}
+		page.stored++
+	}
+	return page, nil
+}
+
+func isInvoiceStored(db *sqlx.DB, nodeId int, invoice *lnrpc.Invoice) (bool, error) {
+	var stored bool
+	err := db.Get(&stored, `
+		SELECT EXISTS(SELECT 1 FROM invoice WHERE node_id=$1 AND r_hash=$2 AND invoice_state=$3);`,
+		nodeId, hex.EncodeToString(invoice.RHash), invoice.State.String())
+	if err != nil {
+		return false, errors.Wrap(err, "Checking if the invoice is stored")
+	}
+	return stored, nil
+}
+
+// backfillTransactions stores the on-chain transactions of the date range, the node returns them all at once.
+func backfillTransactions(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	job backfill.Job) (backfillPage, error) {
+
+	resp, err := client.GetTransactions(ctx, &lnrpc.GetTransactionsRequest{})
+	if err != nil {
+		return backfillPage{}, errors.Wrap(err, "Fetching transactions")
+	}
+	page := backfillPage{indexOffset: uint64(len(resp.Transactions)), done: true}
+	for _, tx := range resp.Transactions {
+		timestamp := time.Unix(tx.TimeStamp, 0)
+		if timestamp.Before(job.FromTime) || !timestamp.Before(job.ToTime) {
+			continue
+		}
+		if err = storeTransaction(db, tx, job.NodeId); err != nil {
+			return backfillPage{}, err
+		}
+		page.stored++
+	}
+	return page, nil
+}
@@ -0,0 +1,94 @@
+package lnd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/backfill"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestBackfill(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		panic(err)
+	}
+
+	db, cancel, err := srv.NewTestDatabase(true)
+	defer cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer ctxCancel()
+	node := node_client.NewFakeNode("router")
+	out := node_client.NewFakeNode("out")
+	incoming := node.AddChannel(node_client.NewFakeNode("in").PublicKey(), 1000000, 0)
+	outgoing := node.AddChannel(out.PublicKey(), 1000000, 1000000)
+	if err := node.Forward(incoming.ChanId, outgoing.ChanId, 100000, 1000); err != nil {
+		t.Fatalf("Forward() error: %v", err)
+	}
+	invoice, err := out.AddInvoice(ctx, &lnrpc.Invoice{ValueMsat: 50000})
+	if err != nil {
+		t.Fatalf("AddInvoice() error: %v", err)
+	}
+	if err := node.PayInvoice(invoice.PaymentRequest, 0); err != nil {
+		t.Fatalf("PayInvoice() error: %v", err)
+	}
+	if _, err := node.AddInvoice(ctx, &lnrpc.Invoice{Memo: "backfilled", ValueMsat: 10000}); err != nil {
+		t.Fatalf("AddInvoice() error: %v", err)
+	}
+
+	nodeId := commons.GetNodeIdFromPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet)
+	job, err := backfill.AddJob(db, backfill.JobRequest{NodeId: nodeId, FromTime: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("AddJob() error: %v", err)
+	}
+	count := func(table string) int {
+		var c int
+		if err := db.Get(&c, `SELECT count(*) FROM `+table+` WHERE node_id=$1;`, nodeId); err != nil {
+			t.Fatalf("Counting %v: %v", table, err)
+		}
+		return c
+	}
+	want := map[string]int{"forward": 1, "payment": 1, "invoice": 1, "tx": 2}
+	// The second run continues from the checkpoints of the first, which are all done.
+	for run := 1; run <= 2; run++ {
+		if err := Backfill(ctx, node, db, job, nil, BackfillOptions{PagesPerSecond: 100}); err != nil {
+			t.Fatalf("Backfill() run %d error: %v", run, err)
+		}
+		for table, wantCount := range want {
+			if got := count(table); got != wantCount {
+				t.Errorf("Backfill() run %d stored %v\nGot:\n%v\nWant:\n%v\n", run, table, got, wantCount)
+			}
+		}
+		job, err = backfill.GetJob(db, job.BackfillJobId)
+		if err != nil {
+			t.Fatalf("GetJob() error: %v", err)
+		}
+		for _, checkpoint := range job.Checkpoints {
+			if !checkpoint.Done || checkpoint.Stored != int64(want[backfillTable(checkpoint.Kind)]) {
+				t.Errorf("Checkpoint after run %d\nGot:\n%v\nWant:\n%v\n", run, checkpoint, "done")
+			}
+		}
+	}
+}
+
+func backfillTable(kind backfill.Kind) string {
+	switch kind {
+	case backfill.Forwards:
+		return "forward"
+	case backfill.Payments:
+		return "payment"
+	case backfill.Invoices:
+		return "invoice"
+	}
+	return "tx"
+}
@@ -0,0 +1,70 @@
+package lnd
+
+import (
+	"context"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/blocks"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+// SubscribeAndStoreBlocks stores every new best block of the node, the first block is the best block when
+// subscribing.
+func SubscribeAndStoreBlocks(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
+
+	stream, err := client.RegisterBlockEpochNtfn(ctx, &chainrpc.BlockEpoch{})
+	if err != nil {
+		return errors.Wrap(err, "Register block epoch notifications")
+	}
+	for {
+		epoch, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "Receive block epoch")
+		}
+		recordStreamEvents(nodeSettings.NodeId, StreamBlocks, 1)
+
+		block := blocks.Block{NodeId: nodeSettings.NodeId, Height: epoch.Height, Time: time.Now().UTC()}
+		if len(epoch.Hash) != 0 {
+			hash, err := chainhash.NewHash(epoch.Hash)
+			if err != nil {
+				return errors.Wrap(err, "Parse block hash")
+			}
+			hashString := hash.String()
+			block.Hash = &hashString
+		}
+		// The node only reports the time of its best block and whether it's synced.
+		synced := true
+		info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to obtain the best block of node %v", nodeSettings.NodeId)
+		} else {
+			synced = info.SyncedToChain
+			if info.BlockHeight == epoch.Height && info.BestHeaderTimestamp != 0 {
+				block.Time = time.Unix(info.BestHeaderTimestamp, 0).UTC()
+			}
+		}
+
+		writeStart := time.Now()
+		status, err := blocks.StoreBlock(db, block, synced, eventChannel)
+		observeDbWrite(StreamBlocks, writeStart)
+		if err != nil {
+			return errors.Wrap(err, "Store block")
+		}
+		if status.Behind {
+			log.Warn().Msgf("Node %v is behind the chain at block %v, %v blocks behind (synced to chain: %v)",
+				nodeSettings.NodeId, status.Height, status.Lag, status.SyncedToChain)
+		}
+	}
+}
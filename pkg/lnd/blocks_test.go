@@ -0,0 +1,91 @@
+package lnd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/blocks"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestSubscribeAndStoreBlocks(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		panic(err)
+	}
+
+	db, cancel, err := srv.NewTestDatabase(true)
+	defer cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer ctxCancel()
+	node := node_client.NewFakeNode("router")
+	nodeSettings := commons.GetNodeSettingsByNodeId(
+		commons.GetNodeIdFromPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet))
+
+	info, err := node.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		t.Fatalf("GetInfo() error: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO tx (timestamp, tx_hash, amount, num_confirmations, block_height, node_id)
+		VALUES ($1, 'confirmed', 1000, 1, $2, $3);`, time.Now().UTC(), info.BlockHeight, nodeSettings.NodeId)
+	if err != nil {
+		t.Fatalf("Inserting transaction: %v", err)
+	}
+
+	eventChannel := make(chan interface{}, 10)
+	errs := make(chan error, 1)
+	go func() { errs <- SubscribeAndStoreBlocks(ctx, node, db, nodeSettings, eventChannel) }()
+
+	var statuses []blocks.Status
+	waitForHeight := func(height uint32) {
+		for len(statuses) == 0 || statuses[0].Height != height {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("GetStatuses()\nGot:\n%v\nWant:\n%v\n", statuses, height)
+			case <-time.After(10 * time.Millisecond):
+			}
+			statuses, err = blocks.GetStatuses(db)
+			if err != nil {
+				t.Fatalf("GetStatuses() error: %v", err)
+			}
+		}
+	}
+	// The best block is stored when subscribing.
+	waitForHeight(info.BlockHeight)
+	node.MineBlocks(2)
+	waitForHeight(info.BlockHeight + 2)
+	if statuses[0].Behind || statuses[0].Hash == nil {
+		t.Errorf("GetStatuses()\nGot:\n%v\nWant:\n%v\n", statuses[0], "not behind with a hash")
+	}
+	stored, err := blocks.GetBlocks(db, nodeSettings.NodeId, 10)
+	if err != nil {
+		t.Fatalf("GetBlocks() error: %v", err)
+	}
+	if len(stored) != 3 {
+		t.Errorf("GetBlocks()\nGot:\n%v\nWant:\n%v\n", len(stored), 3)
+	}
+	var numConfirmations int64
+	err = db.Get(&numConfirmations, `SELECT num_confirmations FROM tx WHERE tx_hash='confirmed';`)
+	if err != nil {
+		t.Fatalf("Getting transaction: %v", err)
+	}
+	if numConfirmations != 3 {
+		t.Errorf("num_confirmations\nGot:\n%v\nWant:\n%v\n", numConfirmations, 3)
+	}
+
+	ctxCancel()
+	if err := <-errs; err != nil {
+		t.Errorf("SubscribeAndStoreBlocks() error: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package lnd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lncapital/torq/internal/channel_backups"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+// SubscribeAndStoreChannelBackups stores a new version of the multi channel backup every time the node sends one,
+// the node sends one after every channel open or close.
+func SubscribeAndStoreChannelBackups(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
+
+	if !channel_backups.EncryptionEnabled() {
+		log.Warn().Msgf("Channel backups of node %v are not stored, no backup key is configured",
+			nodeSettings.NodeId)
+	}
+
+	snapshot, err := client.ExportAllChannelBackups(ctx, &lnrpc.ChanBackupExportRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			log.Info().Msgf("Channel backups are not supported by node %v", nodeSettings.NodeId)
+			<-ctx.Done()
+			return nil
+		}
+		log.Error().Err(err).Msgf("Failed to export the channel backup of node %v", nodeSettings.NodeId)
+	} else {
+		storeChannelBackup(db, nodeSettings, snapshot, eventChannel)
+	}
+
+	stream, err := client.SubscribeChannelBackups(ctx, &lnrpc.ChannelBackupSubscription{})
+	if err != nil {
+		return errors.Wrap(err, "Subscribe channel backups")
+	}
+	for {
+		snapshot, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "Receive channel backup")
+		}
+		recordStreamEvents(nodeSettings.NodeId, StreamChannelBackups, 1)
+		// The stream is read even without a key, so no snapshot piles up in the stream.
+		storeChannelBackup(db, nodeSettings, snapshot, eventChannel)
+	}
+}
+
+func storeChannelBackup(db *sqlx.DB, nodeSettings commons.ManagedNodeSettings, snapshot *lnrpc.ChanBackupSnapshot,
+	eventChannel chan interface{}) {
+
+	if !channel_backups.EncryptionEnabled() || snapshot == nil {
+		return
+	}
+	start := time.Now()
+	backup, err := channel_backups.StoreSnapshot(db, nodeSettings.NodeId, snapshot.MultiChanBackup, eventChannel)
+	observeDbWrite(StreamChannelBackups, start)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to store the channel backup of node %v", nodeSettings.NodeId)
+		return
+	}
+	if backup.ChannelBackupId != 0 {
+		log.Info().Msgf("Stored channel backup version %v of node %v", backup.Version, nodeSettings.NodeId)
+	}
+}
@@ -15,10 +15,10 @@ import (
 	"github.com/lncapital/torq/internal/nodes"
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 
 	"github.com/rs/zerolog/log"
 	"go.uber.org/ratelimit"
-	"google.golang.org/grpc"
 )
 
 func chanPointFromByte(cb []byte, oi uint32) (string, error) {
@@ -32,7 +32,7 @@ func chanPointFromByte(cb []byte, oi uint32) (string, error) {
 // storeChannelEvent extracts the timestamp, channel ID and PubKey from the
 // ChannelEvent and converts the original struct to json.
 // Then it's stored in the database in the channel_event table.
-func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscribeChannelEvent,
+func storeChannelEvent(ctx context.Context, db *sqlx.DB, client node_client.NodeClient,
 	ce *lnrpc.ChannelEventUpdate, nodeSettings commons.ManagedNodeSettings,
 	eventChannel chan interface{}) error {
 
@@ -233,7 +233,7 @@ func addNodeWhenNew(remotePublicKey string, nodeSettings commons.ManagedNodeSett
 	return remoteNodeId, nil
 }
 
-func processPendingOpenChannel(ctx context.Context, db *sqlx.DB, client lndClientSubscribeChannelEvent,
+func processPendingOpenChannel(ctx context.Context, db *sqlx.DB, client node_client.NodeClient,
 	txId []byte, outputIndex uint32, nodeSettings commons.ManagedNodeSettings) (int, error) {
 
 	channelPoint, err := chanPointFromByte(txId, outputIndex)
@@ -280,16 +280,9 @@ func processPendingOpenChannel(ctx context.Context, db *sqlx.DB, client lndClien
 	return channelId, nil
 }
 
-type lndClientSubscribeChannelEvent interface {
-	SubscribeChannelEvents(ctx context.Context, in *lnrpc.ChannelEventSubscription,
-		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelEventsClient, error)
-	PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
-		opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error)
-}
-
 // SubscribeAndStoreChannelEvents Subscribes to channel events from LND and stores them in the
 // database as a time series
-func SubscribeAndStoreChannelEvents(ctx context.Context, client lndClientSubscribeChannelEvent, db *sqlx.DB,
+func SubscribeAndStoreChannelEvents(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
 
 	cesr := lnrpc.ChannelEventSubscription{}
@@ -328,7 +321,10 @@ func SubscribeAndStoreChannelEvents(ctx context.Context, client lndClientSubscri
 			continue
 		}
 
+		recordStreamEvents(nodeSettings.NodeId, StreamChannelEvents, 1)
+		writeStart := time.Now()
 		err = storeChannelEvent(ctx, db, client, chanEvent, nodeSettings, eventChannel)
+		observeDbWrite(StreamChannelEvents, writeStart)
 		if err != nil {
 			log.Error().Err(err).Msg("Subscribe channel events store event error")
 			// rate limit for caution but hopefully not needed
@@ -341,7 +337,7 @@ func SubscribeAndStoreChannelEvents(ctx context.Context, client lndClientSubscri
 	return nil
 }
 
-func ImportChannelList(t lnrpc.ChannelEventUpdate_UpdateType, db *sqlx.DB, client lnrpc.LightningClient,
+func ImportChannelList(t lnrpc.ChannelEventUpdate_UpdateType, db *sqlx.DB, client node_client.NodeClient,
 	nodeSettings commons.ManagedNodeSettings) error {
 	ctx := context.Background()
 	switch t {
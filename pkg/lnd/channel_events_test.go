@@ -13,6 +13,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 	"github.com/lncapital/torq/testutil"
 )
 
@@ -37,6 +38,7 @@ func (s *stubLNDSubscribeChannelEventRPC) Recv() (*lnrpc.ChannelEventUpdate, err
 }
 
 type stubLNDSubscribeChannelEvent struct {
+	node_client.NodeClient
 	ChannelEvents []interface{}
 	CancelFunc    func()
 }
@@ -11,22 +11,17 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
 	"go.uber.org/ratelimit"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/graph_events"
 	"github.com/lncapital/torq/internal/nodes"
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-type subscribeChannelGrpahClient interface {
-	SubscribeChannelGraph(ctx context.Context, in *lnrpc.GraphTopologySubscription,
-		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelGraphClient, error)
-}
-
 // SubscribeAndStoreChannelGraph Subscribes to channel updates
-func SubscribeAndStoreChannelGraph(ctx context.Context, client subscribeChannelGrpahClient, db *sqlx.DB,
+func SubscribeAndStoreChannelGraph(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
 
 	req := lnrpc.GraphTopologySubscription{}
@@ -66,6 +61,8 @@ func SubscribeAndStoreChannelGraph(ctx context.Context, client subscribeChannelG
 			continue
 		}
 
+		recordStreamEvents(nodeSettings.NodeId, StreamChannelGraph, len(gpu.NodeUpdates)+len(gpu.ChannelUpdates))
+		writeStart := time.Now()
 		err = processNodeUpdates(gpu.NodeUpdates, db, nodeSettings, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Process node updates")
@@ -75,6 +72,7 @@ func SubscribeAndStoreChannelGraph(ctx context.Context, client subscribeChannelG
 		if err != nil {
 			return errors.Wrap(err, "Process channel updates")
 		}
+		observeDbWrite(StreamChannelGraph, writeStart)
 
 	}
 
@@ -155,6 +153,7 @@ func insertRoutingPolicy(
 		}
 	}
 
+	inboundFee := node_client.GetInboundFee(cu.RoutingPolicy)
 	channelEvent := graph_events.ChannelEventFromGraph{}
 	err = db.Get(&channelEvent, `
 				SELECT *
@@ -176,15 +175,19 @@ func insertRoutingPolicy(
 		cu.RoutingPolicy.FeeRateMilliMsat != channelEvent.FeeRateMilliMsat ||
 		cu.RoutingPolicy.MaxHtlcMsat != channelEvent.MaxHtlcMsat ||
 		cu.RoutingPolicy.MinHtlc != channelEvent.MinHtlc ||
-		cu.RoutingPolicy.TimeLockDelta != channelEvent.TimeLockDelta {
+		cu.RoutingPolicy.TimeLockDelta != channelEvent.TimeLockDelta ||
+		int64(inboundFee.BaseFeeMsat) != channelEvent.InboundFeeBaseMsat ||
+		int64(inboundFee.FeeRatePpm) != channelEvent.InboundFeeRateMilliMsat {
 
 		_, err := db.Exec(`
 		INSERT INTO routing_policy
 			(ts,disabled,time_lock_delta,min_htlc,max_htlc_msat,fee_base_msat,fee_rate_mill_msat,
+			 inbound_fee_base_msat,inbound_fee_rate_milli_msat,
 			 channel_id,announcing_node_id,connecting_node_id,node_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);`, eventTime,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);`, eventTime,
 			cu.RoutingPolicy.Disabled, cu.RoutingPolicy.TimeLockDelta, cu.RoutingPolicy.MinHtlc,
 			cu.RoutingPolicy.MaxHtlcMsat, cu.RoutingPolicy.FeeBaseMsat, cu.RoutingPolicy.FeeRateMilliMsat,
+			inboundFee.BaseFeeMsat, inboundFee.FeeRatePpm,
 			channelId, announcingNodeId, connectingNodeId, nodeSettings.NodeId)
 		if err != nil {
 			return errors.Wrapf(err, "insertRoutingPolicy")
@@ -202,23 +205,27 @@ func insertRoutingPolicy(
 					ChannelId:        &channelId,
 				},
 				ChannelGraphEventData: broadcast.ChannelGraphEventData{
-					TimeLockDelta:    cu.RoutingPolicy.TimeLockDelta,
-					FeeRateMilliMsat: cu.RoutingPolicy.FeeRateMilliMsat,
-					FeeBaseMsat:      cu.RoutingPolicy.FeeBaseMsat,
-					MaxHtlcMsat:      cu.RoutingPolicy.MaxHtlcMsat,
-					Disabled:         cu.RoutingPolicy.Disabled,
-					MinHtlc:          cu.RoutingPolicy.MinHtlc,
+					TimeLockDelta:           cu.RoutingPolicy.TimeLockDelta,
+					FeeRateMilliMsat:        cu.RoutingPolicy.FeeRateMilliMsat,
+					FeeBaseMsat:             cu.RoutingPolicy.FeeBaseMsat,
+					MaxHtlcMsat:             cu.RoutingPolicy.MaxHtlcMsat,
+					Disabled:                cu.RoutingPolicy.Disabled,
+					MinHtlc:                 cu.RoutingPolicy.MinHtlc,
+					InboundFeeBaseMsat:      int64(inboundFee.BaseFeeMsat),
+					InboundFeeRateMilliMsat: int64(inboundFee.FeeRatePpm),
 				},
 			}
 			if channelEvent.ChannelId != 0 {
 				channelGraphEvent.PreviousEventTime = channelEvent.EventTime
 				channelGraphEvent.PreviousEventData = broadcast.ChannelGraphEventData{
-					TimeLockDelta:    channelEvent.TimeLockDelta,
-					FeeRateMilliMsat: channelEvent.FeeRateMilliMsat,
-					FeeBaseMsat:      channelEvent.FeeBaseMsat,
-					MaxHtlcMsat:      channelEvent.MaxHtlcMsat,
-					Disabled:         channelEvent.Disabled,
-					MinHtlc:          channelEvent.MinHtlc,
+					TimeLockDelta:           channelEvent.TimeLockDelta,
+					FeeRateMilliMsat:        channelEvent.FeeRateMilliMsat,
+					FeeBaseMsat:             channelEvent.FeeBaseMsat,
+					MaxHtlcMsat:             channelEvent.MaxHtlcMsat,
+					Disabled:                channelEvent.Disabled,
+					MinHtlc:                 channelEvent.MinHtlc,
+					InboundFeeBaseMsat:      channelEvent.InboundFeeBaseMsat,
+					InboundFeeRateMilliMsat: channelEvent.InboundFeeRateMilliMsat,
 				}
 			}
 			eventChannel <- channelGraphEvent
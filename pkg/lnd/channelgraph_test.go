@@ -15,11 +15,13 @@ import (
 
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 	"github.com/lncapital/torq/testutil"
 )
 
 type stubLNDSubscribeChannelGraphRPC struct {
 	grpc.ClientStream
+	node_client.NodeClient
 	GraphTopologyUpdate []*lnrpc.GraphTopologyUpdate
 	CancelFunc          func()
 }
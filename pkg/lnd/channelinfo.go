@@ -17,12 +17,13 @@ import (
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/nodes"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 // For importing the latest routing policy at startup.
 
 // Fetches the channel id form all open channels from LND
-func getOpenChanIds(client lnrpc.LightningClient) ([]uint64, error) {
+func getOpenChanIds(client node_client.NodeClient) ([]uint64, error) {
 
 	resp, err := client.ListChannels(context.Background(), &lnrpc.ListChannelsRequest{})
 	if err != nil {
@@ -94,7 +95,7 @@ func constructChannelEdgeUpdates(chanEdge *lnrpc.ChannelEdge) ([2]*lnrpc.Channel
 }
 
 // ImportRoutingPolicies imports routing policy information about all channels if they don't already have
-func ImportRoutingPolicies(client lnrpc.LightningClient, db *sqlx.DB, nodeSettings commons.ManagedNodeSettings) error {
+func ImportRoutingPolicies(client node_client.NodeClient, db *sqlx.DB, nodeSettings commons.ManagedNodeSettings) error {
 
 	// Get all open channels from LND
 	chanIdList, err := getOpenChanIds(client)
@@ -11,10 +11,10 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
 	"go.uber.org/ratelimit"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 func convMicro(ns uint64) time.Time {
@@ -92,13 +92,8 @@ func fetchLastForwardTime(db *sqlx.DB) (uint64, error) {
 	return lastNs, nil
 }
 
-type lightningClientForwardingHistory interface {
-	ForwardingHistory(ctx context.Context, in *lnrpc.ForwardingHistoryRequest,
-		opts ...grpc.CallOption) (*lnrpc.ForwardingHistoryResponse, error)
-}
-
 // fetchForwardingHistory fetches the forwarding history from LND.
-func fetchForwardingHistory(ctx context.Context, client lightningClientForwardingHistory,
+func fetchForwardingHistory(ctx context.Context, client node_client.NodeClient,
 	lastTimestamp uint64,
 	maxEvents int) (
 	*lnrpc.ForwardingHistoryResponse, error) {
@@ -125,7 +120,7 @@ type FwhOptions struct {
 
 // SubscribeForwardingEvents repeatedly requests forwarding history starting after the last
 // forwarding stored in the database and stores new forwards.
-func SubscribeForwardingEvents(ctx context.Context, client lightningClientForwardingHistory, db *sqlx.DB,
+func SubscribeForwardingEvents(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}, opt *FwhOptions) error {
 
 	me := MAXEVENTS
@@ -174,7 +169,10 @@ func SubscribeForwardingEvents(ctx context.Context, client lightningClientForwar
 				}
 
 				// Store the forwarding history
+				recordStreamEvents(nodeSettings.NodeId, StreamForwards, len(fwh.ForwardingEvents))
+				writeStart := time.Now()
 				err = storeForwardingHistory(db, fwh.ForwardingEvents, nodeSettings.NodeId)
+				observeDbWrite(StreamForwards, writeStart)
 				if err != nil {
 					log.Printf("Subscribe forwarding events: %v\n", err)
 				}
@@ -13,11 +13,13 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 	"github.com/lncapital/torq/testutil"
 )
 
 // mockLightningClientForwardingHistory is used to moc responses from GetNodeInfo
 type mockLightningClientForwardingHistory struct {
+	node_client.NodeClient
 	CustomMaxEvents  int32
 	ForwardingEvents []*lnrpc.ForwardingEvent
 	LastOffsetIndex  uint32
@@ -12,6 +12,7 @@ import (
 
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 
 	"github.com/rs/zerolog/log"
 	"go.uber.org/ratelimit"
@@ -279,7 +280,7 @@ func storeForwardEvent(db *sqlx.DB, h *routerrpc.HtlcEvent, nodeId int) error {
 // SubscribeAndStoreHtlcEvents subscribes to HTLC events from LND and stores them in the database as time series.
 // NB: LND has marked HTLC event streaming as experimental. Delivery is not guaranteed, so dataset might not be complete
 // HTLC events is primarily used to diagnose how good a channel / node is. And if the channel allocation should change.
-func SubscribeAndStoreHtlcEvents(ctx context.Context, router routerrpc.RouterClient, db *sqlx.DB,
+func SubscribeAndStoreHtlcEvents(ctx context.Context, router node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
 
 	htlcStream, err := router.SubscribeHtlcEvents(ctx, &routerrpc.SubscribeHtlcEventsRequest{})
@@ -320,6 +321,8 @@ func SubscribeAndStoreHtlcEvents(ctx context.Context, router routerrpc.RouterCli
 			continue
 		}
 
+		recordStreamEvents(nodeSettings.NodeId, StreamHtlcEvents, 1)
+		writeStart := time.Now()
 		switch htlcEvent.Event.(type) {
 		case *routerrpc.HtlcEvent_ForwardEvent:
 			err = storeForwardEvent(db, htlcEvent, nodeSettings.NodeId)
@@ -350,6 +353,7 @@ func SubscribeAndStoreHtlcEvents(ctx context.Context, router routerrpc.RouterCli
 				rl.Take()
 			}
 		}
+		observeDbWrite(StreamHtlcEvents, writeStart)
 	}
 	return nil
 }
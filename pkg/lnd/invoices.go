@@ -14,17 +14,12 @@ import (
 	"github.com/lightningnetwork/lnd/zpay32"
 	"github.com/rs/zerolog/log"
 	"go.uber.org/ratelimit"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-type invoicesClient interface {
-	SubscribeInvoices(ctx context.Context, in *lnrpc.InvoiceSubscription,
-		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeInvoicesClient, error)
-}
-
 type Invoice struct {
 
 	/*
@@ -193,7 +188,7 @@ func fetchLastInvoiceIndexes(db *sqlx.DB) (addIndex uint64, settleIndex uint64,
 	return addIndex, settleIndex, nil
 }
 
-func SubscribeAndStoreInvoices(ctx context.Context, client invoicesClient, db *sqlx.DB,
+func SubscribeAndStoreInvoices(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
 
 	// Get the latest settle and add index to prevent duplicate entries.
@@ -246,27 +241,17 @@ func SubscribeAndStoreInvoices(ctx context.Context, client invoicesClient, db *s
 			continue
 		}
 
-		var destinationPublicKey = ""
-		// if empty payment request invoice is likely keysend
-		if invoice.PaymentRequest != "" {
-			// Check the running nodes network. Currently we assume we are running on Bitcoin mainnet
-			nodeNetwork := getNodeNetwork(invoice.PaymentRequest)
-
-			inva, err := zpay32.Decode(invoice.PaymentRequest, nodeNetwork)
-			if err != nil {
-				log.Error().Msgf("Subscribe and store invoices - decode payment request: %v", err)
-			} else {
-				destinationPublicKey = fmt.Sprintf("%x", inva.Destination.SerializeCompressed())
-			}
-		}
-
+		destinationPublicKey := invoiceDestination(invoice.PaymentRequest)
 		invoiceEvent := broadcast.InvoiceEvent{
 			EventData: broadcast.EventData{
 				EventTime: time.Now().UTC(),
 				NodeId:    nodeSettings.NodeId,
 			},
 		}
+		recordStreamEvents(nodeSettings.NodeId, StreamInvoices, 1)
+		writeStart := time.Now()
 		err = insertInvoice(db, invoice, destinationPublicKey, nodeSettings.NodeId, invoiceEvent, eventChannel)
+		observeDbWrite(StreamInvoices, writeStart)
 		if err != nil {
 			log.Error().Msgf("Subscribe and store invoices: %v", err)
 			// rate limit for caution but hopefully not needed
@@ -277,6 +262,22 @@ func SubscribeAndStoreInvoices(ctx context.Context, client invoicesClient, db *s
 	return nil
 }
 
+// invoiceDestination returns the public key that the payment request pays to, empty for keysend invoices.
+func invoiceDestination(paymentRequest string) string {
+	if paymentRequest == "" {
+		return ""
+	}
+	// Check the running nodes network. Currently we assume we are running on Bitcoin mainnet
+	nodeNetwork := getNodeNetwork(paymentRequest)
+
+	inva, err := zpay32.Decode(paymentRequest, nodeNetwork)
+	if err != nil {
+		log.Error().Msgf("Subscribe and store invoices - decode payment request: %v", err)
+		return ""
+	}
+	return fmt.Sprintf("%x", inva.Destination.SerializeCompressed())
+}
+
 // getNodeNetwork
 // Obtained from invoice.PaymentRequest
 // MainNetParams           bc
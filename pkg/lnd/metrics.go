@@ -0,0 +1,51 @@
+package lnd
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/pkg/metrics"
+)
+
+// Names of the streams started by subscribe.Start, used as stream label of the metrics and the stream status.
+const (
+	StreamTransactions     = "transactions"
+	StreamHtlcEvents       = "htlcEvents"
+	StreamChannelEvents    = "channelEvents"
+	StreamChannelGraph     = "channelGraph"
+	StreamForwards         = "forwards"
+	StreamInvoices         = "invoices"
+	StreamPayments         = "payments"
+	StreamInFlightPayments = "inFlightPayments"
+	StreamPeerEvents       = "peerEvents"
+	StreamChannelBackups   = "channelBackups"
+	StreamBlocks           = "blocks"
+	StreamPendingChannels  = "pendingChannels"
+)
+
+//nolint:gochecknoglobals
+var (
+	streamEvents = metrics.NewCounterVec("torq_stream_events_total",
+		"Number of events processed per subscription stream.", "node_id", "stream")
+	streamLastEvent = metrics.NewGaugeVec("torq_stream_last_event_timestamp_seconds",
+		"Unix time of the last event processed per subscription stream.", "node_id", "stream")
+	dbWriteDuration = metrics.NewHistogramVec("torq_db_write_duration_seconds",
+		"Duration of storing the events of a subscription stream in the database.", metrics.DefaultBuckets, "stream")
+)
+
+// recordStreamEvents counts the events received on a stream and updates the last event time.
+func recordStreamEvents(nodeId int, stream string, count int) {
+	if count == 0 {
+		return
+	}
+	nodeIdLabel := strconv.Itoa(nodeId)
+	streamEvents.WithLabelValues(nodeIdLabel, stream).Add(float64(count))
+	streamLastEvent.WithLabelValues(nodeIdLabel, stream).Set(float64(time.Now().Unix()))
+	nodes.RecordStreamEvent(nodeId, stream)
+}
+
+// observeDbWrite records the time it took to store the events of a stream since start.
+func observeDbWrite(stream string, start time.Time) {
+	dbWriteDuration.WithLabelValues(stream).Observe(time.Since(start).Seconds())
+}
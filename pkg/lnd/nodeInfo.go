@@ -12,12 +12,13 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 // For importing the latest node info at startup.
 
 // ImportNodeInfo imports node information about all channels if they don't already have
-func ImportNodeInfo(client lnrpc.LightningClient, db *sqlx.DB, nodeSettings commons.ManagedNodeSettings) error {
+func ImportNodeInfo(client node_client.NodeClient, db *sqlx.DB, nodeSettings commons.ManagedNodeSettings) error {
 	// Get all node public keys with channels
 	publicKeys := commons.GetAllChannelPublicKeys(nodeSettings.Chain, nodeSettings.Network)
 
@@ -12,24 +12,18 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/zpay32"
 	"github.com/rs/zerolog/log"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-type lightningClient_ListPayments interface {
-	ListPayments(ctx context.Context, in *lnrpc.ListPaymentsRequest,
-		opts ...grpc.CallOption) (*lnrpc.ListPaymentsResponse,
-		error)
-}
-
 // PayOptions allows the caller to adjust the number of payments can be requested at a time
 // and set a custom time interval between requests.
 type PayOptions struct {
 	Tick <-chan time.Time
 }
 
-func SubscribeAndStorePayments(ctx context.Context, client lightningClient_ListPayments, db *sqlx.DB,
+func SubscribeAndStorePayments(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}, opt *PayOptions) error {
 
 	// Create the default ticker used to fetch forwards at a set interval
@@ -72,7 +66,10 @@ func SubscribeAndStorePayments(ctx context.Context, client lightningClient_ListP
 				last = p.LastIndexOffset
 
 				// Store the payments
+				recordStreamEvents(nodeSettings.NodeId, StreamPayments, len(p.Payments))
+				writeStart := time.Now()
 				err = storePayments(db, p.Payments, nodeSettings.NodeId)
+				observeDbWrite(StreamPayments, writeStart)
 				if err != nil {
 					log.Printf("Store payments: %v\n", err)
 					break
@@ -102,7 +99,7 @@ func fetchLastPaymentIndex(db *sqlx.DB) (uint64, error) {
 }
 
 // fetchPayments fetches completed payments from LND.
-func fetchPayments(ctx context.Context, client lightningClient_ListPayments, last uint64) (
+func fetchPayments(ctx context.Context, client node_client.NodeClient, last uint64) (
 	r *lnrpc.ListPaymentsResponse, err error) {
 
 	//retry:
@@ -175,7 +172,7 @@ func storePayments(db *sqlx.DB, p []*lnrpc.Payment, nodeId int) error {
 	return nil
 }
 
-func UpdateInFlightPayments(ctx context.Context, client lightningClient_ListPayments, db *sqlx.DB,
+func UpdateInFlightPayments(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}, opt *PayOptions) error {
 
 	// Create the default ticker used to fetch forwards at a set interval
@@ -231,7 +228,10 @@ func UpdateInFlightPayments(ctx context.Context, client lightningClient_ListPaym
 					continue
 				}
 				// Store the payments
+				recordStreamEvents(nodeSettings.NodeId, StreamInFlightPayments, len(p.Payments))
+				writeStart := time.Now()
 				err = updatePayments(db, p.Payments, nodeSettings.NodeId)
+				observeDbWrite(StreamInFlightPayments, writeStart)
 				if err != nil {
 					log.Printf("Subscribe and update payments: %v\n", err)
 					continue
@@ -11,6 +11,7 @@ import (
 	"github.com/mixer/clock"
 
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 	"github.com/lncapital/torq/testutil"
 	// "github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
@@ -18,6 +19,7 @@ import (
 )
 
 type mockLightningClient_ListPayments struct {
+	node_client.NodeClient
 	Payments        []*lnrpc.Payment
 	LastIndexOffset uint64
 	CancelFunc      func()
@@ -8,18 +8,13 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
 	"go.uber.org/ratelimit"
-	"google.golang.org/grpc"
 
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
-type peerEventsClient interface {
-	SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
-		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error)
-}
-
-func SubscribePeerEvents(ctx context.Context, client peerEventsClient,
+func SubscribePeerEvents(ctx context.Context, client node_client.NodeClient,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
 
 	peerEventStream, err := client.SubscribePeerEvents(ctx, &lnrpc.PeerEventSubscription{})
@@ -58,6 +53,8 @@ func SubscribePeerEvents(ctx context.Context, client peerEventsClient,
 			continue
 		}
 
+		recordStreamEvents(nodeSettings.NodeId, StreamPeerEvents, 1)
+
 		if eventChannel != nil {
 			eventChannel <- broadcast.PeerEvent{
 				EventData: broadcast.EventData{
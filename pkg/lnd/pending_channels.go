@@ -0,0 +1,58 @@
+package lnd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+// pendingChannelsInterval is how often the pending channels are requested, nodes have no subscription for them.
+const pendingChannelsInterval = time.Minute
+
+// UpdatePendingChannels keeps the pending channels of the node up to date, including the count down to the maturity
+// of force closed channels.
+func UpdatePendingChannels(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
+
+	ticker := time.NewTicker(pendingChannelsInterval)
+	defer ticker.Stop()
+	for {
+		if err := updatePendingChannels(ctx, client, db, nodeSettings, eventChannel); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Error().Err(err).Msgf("Failed to update the pending channels of node %v", nodeSettings.NodeId)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func updatePendingChannels(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
+
+	resp, err := client.PendingChannels(ctx, &lnrpc.PendingChannelsRequest{})
+	if err != nil {
+		return errors.Wrap(err, "Get pending channels")
+	}
+	writeStart := time.Now()
+	changes, err := channels.StorePendingChannels(db, nodeSettings.NodeId,
+		channels.PendingChannelsFromResponse(nodeSettings.NodeId, resp), eventChannel)
+	observeDbWrite(StreamPendingChannels, writeStart)
+	recordStreamEvents(nodeSettings.NodeId, StreamPendingChannels, changes)
+	if err != nil {
+		return errors.Wrap(err, "Store pending channels")
+	}
+	return nil
+}
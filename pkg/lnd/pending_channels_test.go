@@ -0,0 +1,93 @@
+package lnd
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestUpdatePendingChannels(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		panic(err)
+	}
+
+	db, cancel, err := srv.NewTestDatabase(true)
+	defer cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer ctxCancel()
+	node := node_client.NewFakeNode("router")
+	channel := node.AddChannel(node_client.NewFakeNode("peer").PublicKey(), 1000000, 500000)
+	nodeSettings := commons.GetNodeSettingsByNodeId(
+		commons.GetNodeIdFromPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet))
+
+	fundingTransactionHash, fundingOutputIndex := channels.ParseChannelPoint(channel.ChannelPoint)
+	closeStream, err := node.CloseChannel(ctx, &lnrpc.CloseChannelRequest{Force: true,
+		ChannelPoint: &lnrpc.ChannelPoint{FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{
+			FundingTxidStr: fundingTransactionHash}, OutputIndex: uint32(fundingOutputIndex)}})
+	if err != nil {
+		t.Fatalf("CloseChannel() error: %v", err)
+	}
+	for {
+		if _, err := closeStream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+	}
+
+	eventChannel := make(chan interface{}, 10)
+	if err := updatePendingChannels(ctx, node, db, nodeSettings, eventChannel); err != nil {
+		t.Fatalf("updatePendingChannels() error: %v", err)
+	}
+	pendingChannels, err := channels.GetPendingChannels(db, nodeSettings.NodeId)
+	if err != nil {
+		t.Fatalf("GetPendingChannels() error: %v", err)
+	}
+	if len(pendingChannels) != 1 || pendingChannels[0].State != channels.PendingForceClose ||
+		pendingChannels[0].LimboBalance != 500000 || *pendingChannels[0].SweepState != channels.TimeLocked {
+		t.Fatalf("GetPendingChannels()\nGot:\n%v\nWant:\n%v\n", pendingChannels,
+			"one time locked force close with 500000 sat in limbo")
+	}
+	if event := (<-eventChannel).(broadcast.PendingChannelEvent); event.Resolved ||
+		event.ChannelPoint != channel.ChannelPoint {
+		t.Errorf("PendingChannelEvent\nGot:\n%v\nWant:\n%v\n", event, channel.ChannelPoint)
+	}
+
+	// Nothing changed, nothing is sent.
+	if err := updatePendingChannels(ctx, node, db, nodeSettings, eventChannel); err != nil {
+		t.Fatalf("updatePendingChannels() error: %v", err)
+	}
+	if len(eventChannel) != 0 {
+		t.Errorf("PendingChannelEvent without changes\nGot:\n%v\nWant:\n%v\n", len(eventChannel), 0)
+	}
+
+	node.MineBlocks(1000)
+	if err := updatePendingChannels(ctx, node, db, nodeSettings, eventChannel); err != nil {
+		t.Fatalf("updatePendingChannels() error: %v", err)
+	}
+	pendingChannels, err = channels.GetPendingChannels(db, nodeSettings.NodeId)
+	if err != nil {
+		t.Fatalf("GetPendingChannels() error: %v", err)
+	}
+	if len(pendingChannels) != 0 {
+		t.Errorf("GetPendingChannels() after maturity\nGot:\n%v\nWant:\n%v\n", pendingChannels, "none")
+	}
+	if event := (<-eventChannel).(broadcast.PendingChannelEvent); !event.Resolved {
+		t.Errorf("PendingChannelEvent after maturity\nGot:\n%v\nWant:\n%v\n", event.Resolved, true)
+	}
+}
@@ -0,0 +1,468 @@
+package lnd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/reconciliation"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+)
+
+const (
+	DefaultReconcileInterval = 6 * time.Hour
+	// reconcileDelay leaves the most recent history to the subscriptions that are still storing it.
+	reconcileDelay = time.Minute
+)
+
+// nodeChannel is the state of a channel according to the node.
+type nodeChannel struct {
+	status            commons.ChannelStatus
+	lndShortChannelId uint64
+	closeType         *lnrpc.ChannelCloseSummary_ClosureType
+	closingTxHash     *string
+	remotePubkey      string
+}
+
+type storedPayment struct {
+	PaymentIndex  uint64 `db:"payment_index"`
+	Status        string `db:"status"`
+	FailureReason string `db:"failure_reason"`
+}
+
+type storedInvoice struct {
+	RHash        string `db:"r_hash"`
+	InvoiceState string `db:"invoice_state"`
+}
+
+// Reconcile compares the channels, payments, invoices and forwards in the database with the node of the report.
+// Whatever is missing or outdated in the database is stored again the way the subscriptions store it, what can't be
+// fixed is only reported.
+func Reconcile(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, report *reconciliation.Report) error {
+	nodeSettings := commons.GetNodeSettingsByNodeId(report.NodeId)
+	if nodeSettings.NodeId == 0 {
+		return errors.Newf("Node id %v is not a Torq node", report.NodeId)
+	}
+	until := time.Now().UTC().Add(-reconcileDelay)
+	if err := reconcileChannels(ctx, client, db, nodeSettings, report); err != nil {
+		return errors.Wrap(err, "Reconciling channels")
+	}
+	if err := reconcilePayments(ctx, client, db, report, until); err != nil {
+		return errors.Wrap(err, "Reconciling payments")
+	}
+	if err := reconcileInvoices(ctx, client, db, report, until); err != nil {
+		return errors.Wrap(err, "Reconciling invoices")
+	}
+	if err := reconcileForwards(ctx, client, db, report, until); err != nil {
+		return errors.Wrap(err, "Reconciling forwards")
+	}
+	return nil
+}
+
+func reconcileChannels(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, report *reconciliation.Report) error {
+
+	nodeChannels, err := getNodeChannels(ctx, client)
+	if err != nil {
+		return err
+	}
+	var storedChannels []channels.Channel
+	err = db.Select(&storedChannels, `
+		SELECT channel_id, short_channel_id, lnd_short_channel_id, funding_transaction_hash, funding_output_index,
+			closing_transaction_hash, first_node_id, second_node_id, status_id
+		FROM channel
+		WHERE first_node_id=$1 OR second_node_id=$1;`, nodeSettings.NodeId)
+	if err != nil {
+		return errors.Wrap(err, "Getting the channels of the node")
+	}
+	stored := make(map[string]channels.Channel)
+	for _, channel := range storedChannels {
+		stored[channels.CreateChannelPoint(channel.FundingTransactionHash, channel.FundingOutputIndex)] = channel
+	}
+
+	channelPoints := make([]string, 0, len(nodeChannels))
+	for channelPoint := range nodeChannels {
+		channelPoints = append(channelPoints, channelPoint)
+	}
+	sort.Strings(channelPoints)
+	for _, channelPoint := range channelPoints {
+		nc := nodeChannels[channelPoint]
+		channel, exists := stored[channelPoint]
+		if !exists {
+			remoteNodeId, err := addNodeWhenNew(nc.remotePubkey, nodeSettings, db)
+			if err == nil {
+				_, err = addChannelOrUpdateStatus(channelPoint, nc.lndShortChannelId, &nc.status, nc.closeType,
+					nc.closingTxHash, nodeSettings, remoteNodeId, db)
+			}
+			report.Add(reconciliation.Channels, channelPoint,
+				fmt.Sprintf("Channel missing in Torq, it is %v on the node", channelStatusName(nc.status)),
+				reconciliation.Added, err)
+			continue
+		}
+		differences := channelDifferences(channel, nc)
+		if len(differences) == 0 {
+			continue
+		}
+		remoteNodeId := channel.SecondNodeId
+		if remoteNodeId == nodeSettings.NodeId {
+			remoteNodeId = channel.FirstNodeId
+		}
+		if nc.lndShortChannelId == 0 && channel.LNDShortChannelID != nil {
+			nc.lndShortChannelId = *channel.LNDShortChannelID
+		}
+		_, err := addChannelOrUpdateStatus(channelPoint, nc.lndShortChannelId, &nc.status, nc.closeType,
+			nc.closingTxHash, nodeSettings, remoteNodeId, db)
+		// The status isn't updated when only the closing transaction is missing.
+		if err == nil && nc.closingTxHash != nil {
+			err = channels.UpdateChannelStatusAndClosingTransactionHash(db, channel.ChannelID, nc.status,
+				*nc.closingTxHash)
+		}
+		for _, difference := range differences {
+			report.Add(reconciliation.Channels, channelPoint, difference, reconciliation.Updated, err)
+		}
+	}
+
+	storedPoints := make([]string, 0, len(stored))
+	for channelPoint := range stored {
+		storedPoints = append(storedPoints, channelPoint)
+	}
+	sort.Strings(storedPoints)
+	for _, channelPoint := range storedPoints {
+		channel := stored[channelPoint]
+		if _, exists := nodeChannels[channelPoint]; exists || channel.Status >= commons.CooperativeClosed {
+			continue
+		}
+		report.Add(reconciliation.Channels, channelPoint,
+			fmt.Sprintf("Channel is %v in Torq but unknown to the node", channelStatusName(channel.Status)),
+			reconciliation.Reported, nil)
+	}
+	return nil
+}
+
+// getNodeChannels returns the open, pending and closed channels of the node by channel point. A channel that is
+// closing or closed is reported by the later calls, those override the earlier state.
+func getNodeChannels(ctx context.Context, client node_client.NodeClient) (map[string]nodeChannel, error) {
+	nodeChannels := make(map[string]nodeChannel)
+	open, err := client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing channels")
+	}
+	for _, channel := range open.Channels {
+		nodeChannels[channel.ChannelPoint] = nodeChannel{status: commons.Open, lndShortChannelId: channel.ChanId,
+			remotePubkey: channel.RemotePubkey}
+	}
+	pending, err := client.PendingChannels(ctx, &lnrpc.PendingChannelsRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing pending channels")
+	}
+	for _, channel := range pending.PendingOpenChannels {
+		nodeChannels[channel.Channel.ChannelPoint] = nodeChannel{status: commons.Opening,
+			remotePubkey: channel.Channel.RemoteNodePub}
+	}
+	var closing []*lnrpc.PendingChannelsResponse_PendingChannel
+	for _, channel := range pending.WaitingCloseChannels {
+		closing = append(closing, channel.Channel)
+	}
+	for _, channel := range pending.PendingForceClosingChannels {
+		closing = append(closing, channel.Channel)
+	}
+	for _, channel := range closing {
+		nodeChannels[channel.ChannelPoint] = nodeChannel{status: commons.Closing,
+			lndShortChannelId: nodeChannels[channel.ChannelPoint].lndShortChannelId, remotePubkey: channel.RemoteNodePub}
+	}
+	closed, err := client.ClosedChannels(ctx, &lnrpc.ClosedChannelsRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Listing closed channels")
+	}
+	for _, channel := range closed.Channels {
+		nc := nodeChannel{status: channels.GetClosureStatus(channel.CloseType),
+			lndShortChannelId: channel.ChanId, remotePubkey: channel.RemotePubkey}
+		if channel.ClosingTxHash != "" {
+			closeType := channel.CloseType
+			closingTxHash := channel.ClosingTxHash
+			nc.closeType = &closeType
+			nc.closingTxHash = &closingTxHash
+		}
+		nodeChannels[channel.ChannelPoint] = nc
+	}
+	return nodeChannels, nil
+}
+
+func channelDifferences(channel channels.Channel, nc nodeChannel) []string {
+	var differences []string
+	if channel.Status != nc.status {
+		differences = append(differences, fmt.Sprintf("Channel is %v in Torq, %v on the node",
+			channelStatusName(channel.Status), channelStatusName(nc.status)))
+	}
+	if nc.lndShortChannelId != 0 &&
+		(channel.LNDShortChannelID == nil || *channel.LNDShortChannelID != nc.lndShortChannelId) {
+		differences = append(differences, fmt.Sprintf("Short channel id missing in Torq, it is %v on the node",
+			channels.ConvertLNDShortChannelID(nc.lndShortChannelId)))
+	}
+	if nc.closingTxHash != nil &&
+		(channel.ClosingTransactionHash == nil || *channel.ClosingTransactionHash != *nc.closingTxHash) {
+		differences = append(differences, fmt.Sprintf("Closing transaction missing in Torq, it is %v on the node",
+			*nc.closingTxHash))
+	}
+	return differences
+}
+
+func channelStatusName(status commons.ChannelStatus) string {
+	switch status {
+	case commons.Opening:
+		return "opening"
+	case commons.Open:
+		return "open"
+	case commons.Closing:
+		return "closing"
+	case commons.CooperativeClosed:
+		return "cooperatively closed"
+	case commons.LocalForceClosed:
+		return "force closed locally"
+	case commons.RemoteForceClosed:
+		return "force closed remotely"
+	case commons.BreachClosed:
+		return "closed by a breach"
+	case commons.FundingCancelledClosed:
+		return "closed before funding"
+	case commons.AbandonedClosed:
+		return "abandoned"
+	}
+	return strconv.Itoa(int(status))
+}
+
+// reconcilePayments pages through the payments after the last one Torq stored before the from time of the report,
+// the payment index grows with the creation time.
+func reconcilePayments(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	report *reconciliation.Report, until time.Time) error {
+
+	var indexOffset uint64
+	err := db.Get(&indexOffset, `
+		SELECT COALESCE(MAX(payment_index), 0) FROM payment WHERE node_id=$1 AND creation_timestamp < $2;`,
+		report.NodeId, report.FromTime)
+	if err != nil {
+		return errors.Wrap(err, "Getting the payment index offset")
+	}
+	var storedPayments []storedPayment
+	err = db.Select(&storedPayments, `
+		SELECT payment_index, status, failure_reason
+		FROM payment
+		WHERE node_id=$1 AND creation_timestamp >= $2 AND creation_timestamp < $3;`,
+		report.NodeId, report.FromTime, until)
+	if err != nil {
+		return errors.Wrap(err, "Getting the stored payments")
+	}
+	stored := make(map[uint64]storedPayment)
+	for _, payment := range storedPayments {
+		stored[payment.PaymentIndex] = payment
+	}
+
+	seen := make(map[uint64]bool)
+	for done := false; !done; {
+		resp, err := client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{
+			IncludeIncomplete: true,
+			IndexOffset:       indexOffset,
+			MaxPayments:       backfillPaymentsPageSize,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Fetching payments")
+		}
+		done = len(resp.Payments) < backfillPaymentsPageSize
+		for _, payment := range resp.Payments {
+			indexOffset = payment.PaymentIndex
+			created := time.Unix(0, payment.CreationTimeNs)
+			if created.Before(report.FromTime) {
+				continue
+			}
+			if !created.Before(until) {
+				done = true
+				break
+			}
+			seen[payment.PaymentIndex] = true
+			reference := strconv.FormatUint(payment.PaymentIndex, 10)
+			storedPayment, exists := stored[payment.PaymentIndex]
+			switch {
+			case !exists:
+				err = storePayments(db, []*lnrpc.Payment{payment}, report.NodeId)
+				report.Add(reconciliation.Payments, reference,
+					fmt.Sprintf("Payment missing in Torq, it is %v on the node", payment.Status),
+					reconciliation.Added, err)
+			// Expired in flight payments are failed by Torq and followed by the in flight payment updates.
+			case payment.Status == lnrpc.Payment_IN_FLIGHT:
+			case storedPayment.Status != payment.Status.String() ||
+				storedPayment.FailureReason != payment.FailureReason.String():
+				err = updatePayments(db, []*lnrpc.Payment{payment}, report.NodeId)
+				report.Add(reconciliation.Payments, reference,
+					fmt.Sprintf("Payment is %v (%v) in Torq, %v (%v) on the node", storedPayment.Status,
+						storedPayment.FailureReason, payment.Status, payment.FailureReason),
+					reconciliation.Updated, err)
+			}
+		}
+	}
+
+	indexes := make([]uint64, 0, len(stored))
+	for index, payment := range stored {
+		// Torq marks the payments that the node lost this way already.
+		if seen[index] || (payment.Status == "FAILED" && payment.FailureReason == "DETAILS_UNAVAILABLE") {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	for _, index := range indexes {
+		report.Add(reconciliation.Payments, strconv.FormatUint(index, 10),
+			fmt.Sprintf("Payment is %v in Torq but unknown to the node", stored[index].Status),
+			reconciliation.Reported, nil)
+	}
+	return nil
+}
+
+// reconcileInvoices pages through the invoices after the last one Torq stored before the from time of the report
+// and compares the state of each invoice with the last state Torq stored.
+func reconcileInvoices(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	report *reconciliation.Report, until time.Time) error {
+
+	var indexOffset uint64
+	err := db.Get(&indexOffset, `
+		SELECT COALESCE(MAX(add_index), 0) FROM invoice WHERE node_id=$1 AND creation_date < $2;`,
+		report.NodeId, report.FromTime)
+	if err != nil {
+		return errors.Wrap(err, "Getting the invoice index offset")
+	}
+	var storedInvoices []storedInvoice
+	err = db.Select(&storedInvoices, `
+		SELECT DISTINCT ON (r_hash) r_hash, invoice_state
+		FROM invoice
+		WHERE node_id=$1 AND creation_date >= $2 AND creation_date < $3
+		ORDER BY r_hash, created_on DESC;`,
+		report.NodeId, report.FromTime, until)
+	if err != nil {
+		return errors.Wrap(err, "Getting the stored invoices")
+	}
+	stored := make(map[string]string)
+	for _, invoice := range storedInvoices {
+		stored[invoice.RHash] = invoice.InvoiceState
+	}
+
+	seen := make(map[string]bool)
+	for done := false; !done; {
+		resp, err := client.ListInvoices(ctx, &lnrpc.ListInvoiceRequest{
+			IndexOffset:    indexOffset,
+			NumMaxInvoices: backfillInvoicesPageSize,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Fetching invoices")
+		}
+		done = len(resp.Invoices) < backfillInvoicesPageSize
+		for _, invoice := range resp.Invoices {
+			indexOffset = invoice.AddIndex
+			created := time.Unix(invoice.CreationDate, 0)
+			if created.Before(report.FromTime) {
+				continue
+			}
+			if !created.Before(until) {
+				done = true
+				break
+			}
+			rHash := hex.EncodeToString(invoice.RHash)
+			seen[rHash] = true
+			state, exists := stored[rHash]
+			if exists && state == invoice.State.String() {
+				continue
+			}
+			err = insertInvoice(db, invoice, invoiceDestination(invoice.PaymentRequest), report.NodeId,
+				broadcast.InvoiceEvent{}, nil)
+			if !exists {
+				report.Add(reconciliation.Invoices, rHash,
+					fmt.Sprintf("Invoice missing in Torq, it is %v on the node", invoice.State),
+					reconciliation.Added, err)
+				continue
+			}
+			report.Add(reconciliation.Invoices, rHash,
+				fmt.Sprintf("Invoice is %v in Torq, %v on the node", state, invoice.State),
+				reconciliation.Updated, err)
+		}
+	}
+
+	rHashes := make([]string, 0, len(stored))
+	for rHash := range stored {
+		if !seen[rHash] {
+			rHashes = append(rHashes, rHash)
+		}
+	}
+	sort.Strings(rHashes)
+	for _, rHash := range rHashes {
+		report.Add(reconciliation.Invoices, rHash,
+			fmt.Sprintf("Invoice is %v in Torq but unknown to the node", stored[rHash]),
+			reconciliation.Reported, nil)
+	}
+	return nil
+}
+
+// reconcileForwards compares the forwards by their timestamp, the node has one forward per nanosecond at most.
+func reconcileForwards(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
+	report *reconciliation.Report, until time.Time) error {
+
+	var storedTimes []uint64
+	err := db.Select(&storedTimes, `
+		SELECT time_ns FROM forward WHERE node_id=$1 AND time >= $2 AND time_ns < $3;`,
+		report.NodeId, report.FromTime, until.UnixNano())
+	if err != nil {
+		return errors.Wrap(err, "Getting the stored forwards")
+	}
+	stored := make(map[uint64]bool)
+	for _, timestampNs := range storedTimes {
+		stored[timestampNs] = true
+	}
+
+	seen := make(map[uint64]bool)
+	var indexOffset uint32
+	for done := false; !done; {
+		fwh, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+			StartTime:    uint64(report.FromTime.Unix()),
+			EndTime:      uint64(until.Unix()) + 1,
+			IndexOffset:  indexOffset,
+			NumMaxEvents: backfillForwardsPageSize,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Fetching forwarding history")
+		}
+		done = len(fwh.ForwardingEvents) < backfillForwardsPageSize
+		indexOffset = fwh.LastOffsetIndex
+		for _, forward := range fwh.ForwardingEvents {
+			if forward.TimestampNs >= uint64(until.UnixNano()) {
+				continue
+			}
+			seen[forward.TimestampNs] = true
+			if stored[forward.TimestampNs] {
+				continue
+			}
+			err = storeForwardingHistory(db, []*lnrpc.ForwardingEvent{forward}, report.NodeId)
+			report.Add(reconciliation.Forwards, strconv.FormatUint(forward.TimestampNs, 10),
+				fmt.Sprintf("Forward of %v msat from %v to %v missing in Torq", forward.AmtOutMsat,
+					channels.ConvertLNDShortChannelID(forward.ChanIdIn),
+					channels.ConvertLNDShortChannelID(forward.ChanIdOut)),
+				reconciliation.Added, err)
+		}
+	}
+
+	sort.Slice(storedTimes, func(i, j int) bool { return storedTimes[i] < storedTimes[j] })
+	for _, timestampNs := range storedTimes {
+		if seen[timestampNs] {
+			continue
+		}
+		report.Add(reconciliation.Forwards, strconv.FormatUint(timestampNs, 10),
+			"Forward is in Torq but unknown to the node", reconciliation.Reported, nil)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package lnd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/internal/reconciliation"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestReconcile(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		panic(err)
+	}
+
+	db, cancel, err := srv.NewTestDatabase(true)
+	defer cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer ctxCancel()
+	node := node_client.NewFakeNode("router")
+	stored := node.AddChannel(node_client.NewFakeNode("stored").PublicKey(), 1000000, 500000)
+	missing := node.AddChannel(node_client.NewFakeNode("missing").PublicKey(), 1000000, 500000)
+
+	nodeId := commons.GetNodeIdFromPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet)
+	remoteNodeId, err := nodes.AddNodeWhenNew(db, nodes.Node{PublicKey: stored.RemotePubkey, Chain: commons.Bitcoin,
+		Network: commons.SigNet})
+	if err != nil {
+		t.Fatalf("AddNodeWhenNew() error: %v", err)
+	}
+	// An open channel without short channel id, the subscription never fixes those.
+	fundingTransactionHash, fundingOutputIndex := channels.ParseChannelPoint(stored.ChannelPoint)
+	channelId, err := channels.AddChannelOrUpdateChannelStatus(db, channels.Channel{
+		FundingTransactionHash: fundingTransactionHash,
+		FundingOutputIndex:     fundingOutputIndex,
+		FirstNodeId:            nodeId,
+		SecondNodeId:           remoteNodeId,
+		Status:                 commons.Open,
+	})
+	if err != nil {
+		t.Fatalf("AddChannelOrUpdateChannelStatus() error: %v", err)
+	}
+	forwardTime := time.Now().Add(-10 * time.Minute).Round(time.Microsecond).UTC()
+	_, err = db.Exec(`
+		INSERT INTO forward (time, time_ns, fee_msat, incoming_amount_msat, outgoing_amount_msat, node_id)
+		VALUES ($1, $2, 1000, 101000, 100000, $3);`, forwardTime, forwardTime.UnixNano(), nodeId)
+	if err != nil {
+		t.Fatalf("Inserting forward: %v", err)
+	}
+
+	report := &reconciliation.Report{NodeId: nodeId, FromTime: time.Now().Add(-time.Hour)}
+	if err := Reconcile(ctx, node, db, report); err != nil {
+		t.Fatalf("Reconcile() error: %v", err)
+	}
+	want := map[string]reconciliation.Action{
+		stored.ChannelPoint:  reconciliation.Updated,
+		missing.ChannelPoint: reconciliation.Added,
+		"forward":            reconciliation.Reported,
+	}
+	if report.DiscrepancyCount != len(want) || report.FixedCount != 2 {
+		t.Errorf("Reconcile() counts\nGot:\n%v %v\nWant:\n%v %v\n", report.DiscrepancyCount, report.FixedCount,
+			len(want), 2)
+	}
+	for _, discrepancy := range report.Discrepancies {
+		reference := discrepancy.Reference
+		if discrepancy.Kind == reconciliation.Forwards {
+			reference = "forward"
+		}
+		if want[reference] != discrepancy.Action || discrepancy.Error != nil {
+			t.Errorf("Reconcile() discrepancy\nGot:\n%v\nWant:\n%v\n", discrepancy, want[reference])
+		}
+	}
+
+	var lndShortChannelId *uint64
+	err = db.Get(&lndShortChannelId, `SELECT lnd_short_channel_id FROM channel WHERE channel_id=$1;`, channelId)
+	if err != nil {
+		t.Fatalf("Getting channel: %v", err)
+	}
+	if lndShortChannelId == nil || *lndShortChannelId != stored.ChanId {
+		t.Errorf("Reconcile() short channel id\nGot:\n%v\nWant:\n%v\n", lndShortChannelId, stored.ChanId)
+	}
+
+	// Everything that could be fixed was, a second run only reports the forward again.
+	report = &reconciliation.Report{NodeId: nodeId, FromTime: time.Now().Add(-time.Hour)}
+	if err := Reconcile(ctx, node, db, report); err != nil {
+		t.Fatalf("Reconcile() second run error: %v", err)
+	}
+	if report.DiscrepancyCount != 1 || report.FixedCount != 0 {
+		t.Errorf("Reconcile() second run counts\nGot:\n%v %v\nWant:\n%v %v\n", report.DiscrepancyCount,
+			report.FixedCount, 1, 0)
+	}
+}
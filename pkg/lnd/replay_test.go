@@ -0,0 +1,70 @@
+package lnd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
+	"github.com/lncapital/torq/testutil"
+)
+
+// replayRecording replays a recording from testdata through run and stops it once every subscription message is
+// received.
+func replayRecording(t *testing.T, name string, run func(ctx context.Context, client node_client.NodeClient) error) {
+	t.Helper()
+	file, err := node_client.OpenRecordingFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("OpenRecordingFile() error: %v", err)
+	}
+	defer file.Close()
+	replayer, err := node_client.NewReplayer(file, 0)
+	if err != nil {
+		t.Fatalf("NewReplayer() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- run(ctx, replayer) }()
+	select {
+	case <-replayer.Done():
+	case <-ctx.Done():
+		t.Fatalf("Replaying %v: %d messages not received", name, replayer.Remaining())
+	}
+	cancel()
+	<-errs
+}
+
+func TestReplayHtlcEvents(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		panic(err)
+	}
+
+	db, cancel, err := srv.NewTestDatabase(true)
+	defer cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	nodeSettings := commons.GetNodeSettingsByNodeId(
+		commons.GetNodeIdFromPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet))
+	replayRecording(t, "htlc_events.jsonl", func(ctx context.Context, client node_client.NodeClient) error {
+		return SubscribeAndStoreHtlcEvents(ctx, client, db, nodeSettings, nil)
+	})
+
+	var eventTypes []string
+	err = db.Select(&eventTypes, `SELECT event_type FROM htlc_event ORDER BY timestamp_ns;`)
+	if err != nil {
+		t.Fatalf("Selecting htlc events: %v", err)
+	}
+	// The link fail event was received after the stream was lost and resubscribed.
+	want := []string{"ForwardEvent", "SettleEvent", "LinkFailEvent"}
+	if !reflect.DeepEqual(eventTypes, want) {
+		t.Errorf("Stored htlc events\nGot:\n%v\nWant:\n%v\n", eventTypes, want)
+	}
+}
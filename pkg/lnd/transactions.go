@@ -14,6 +14,7 @@ import (
 
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/node_client"
 )
 
 func fetchLastTxHeight(db *sqlx.DB) (txHeight int32, err error) {
@@ -30,7 +31,7 @@ func fetchLastTxHeight(db *sqlx.DB) (txHeight int32, err error) {
 	return txHeight, nil
 }
 
-func ImportTransactions(ctx context.Context, client lnrpc.LightningClient, db *sqlx.DB, nodeId int) error {
+func ImportTransactions(ctx context.Context, client node_client.NodeClient, db *sqlx.DB, nodeId int) error {
 
 	txheight, err := fetchLastTxHeight(db)
 	if err != nil {
@@ -57,7 +58,7 @@ func ImportTransactions(ctx context.Context, client lnrpc.LightningClient, db *s
 
 // SubscribeAndStoreTransactions Subscribes to on-chain transaction events from LND and stores them in the
 // database as a time series. It will also import unregistered transactions on startup.
-func SubscribeAndStoreTransactions(ctx context.Context, client lnrpc.LightningClient, db *sqlx.DB,
+func SubscribeAndStoreTransactions(ctx context.Context, client node_client.NodeClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) error {
 
 	// Imports transactions not captured on the stream
@@ -101,7 +102,10 @@ func SubscribeAndStoreTransactions(ctx context.Context, client lnrpc.LightningCl
 				continue
 			}
 
+			recordStreamEvents(nodeSettings.NodeId, StreamTransactions, 1)
+			writeStart := time.Now()
 			err = storeTransaction(db, tx, nodeSettings.NodeId)
+			observeDbWrite(StreamTransactions, writeStart)
 			if err != nil {
 				fmt.Printf("Subscribe transaction events store transaction error: %v", err)
 				// rate limit for caution but hopefully not needed
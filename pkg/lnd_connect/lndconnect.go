@@ -11,13 +11,25 @@ import (
 	"gopkg.in/macaroon.v2"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
+// grpcLoggerOnce sets the gRPC logger once, replacing it while other connections log is a data race.
+//
+//nolint:gochecknoglobals
+var grpcLoggerOnce sync.Once
+
 // Connect connects to LND using gRPC.
 func Connect(host string, tlsCert []byte, macaroonBytes []byte) (*grpc.ClientConn, error) {
+	return connect(host, tlsCert, macaroonBytes)
+}
+
+func connect(host string, tlsCert []byte, macaroonBytes []byte, extraOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
 
-	grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, os.Stderr, os.Stderr))
+	grpcLoggerOnce.Do(func() {
+		grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, os.Stderr, os.Stderr))
+	})
 
 	cp := x509.NewCertPool()
 	if !cp.AppendCertsFromPEM(tlsCert) {
@@ -42,6 +54,7 @@ func Connect(host string, tlsCert []byte, macaroonBytes []byte) (*grpc.ClientCon
 		grpc.WithTransportCredentials(tlsCreds),
 		grpc.WithPerRPCCredentials(macCred),
 	}
+	opts = append(opts, extraOpts...)
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
@@ -0,0 +1,113 @@
+package lnd_connect
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/keepalive"
+)
+
+// ConnectionDetailsFunc returns what's needed to dial a node, it's only called when there is no usable connection.
+type ConnectionDetailsFunc func() (host string, tlsCert []byte, macaroonBytes []byte, err error)
+
+// pooledConnection is the shared connection of a single node.
+// The mutex makes concurrent requests wait for a single dial instead of each dialing the node.
+type pooledConnection struct {
+	mu            sync.Mutex
+	host          string
+	tlsCert       []byte
+	macaroonBytes []byte
+	conn          *grpc.ClientConn
+}
+
+//nolint:gochecknoglobals
+var (
+	pool   = make(map[int]*pooledConnection)
+	poolMu sync.Mutex
+	// keepaliveParams detect broken connections while no request is running so the next request doesn't hang.
+	keepaliveParams = keepalive.ClientParameters{
+		Time:                30 * time.Second,
+		Timeout:             10 * time.Second,
+		PermitWithoutStream: true,
+	}
+)
+
+func getPooledConnection(nodeId int) *pooledConnection {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	pooled, exists := pool[nodeId]
+	if !exists {
+		pooled = &pooledConnection{}
+		pool[nodeId] = pooled
+	}
+	return pooled
+}
+
+// GetConnection returns the long-lived connection of a node, gRPC multiplexes concurrent requests over it.
+// The connection is shared so callers must not close it, CloseConnection closes it when the node changes.
+// A connection that was shut down is dialed again and a connection that is failing retries immediately.
+func GetConnection(nodeId int, connectionDetails ConnectionDetailsFunc) (*grpc.ClientConn, error) {
+	pooled := getPooledConnection(nodeId)
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+
+	if pooled.conn != nil {
+		switch pooled.conn.GetState() {
+		case connectivity.Shutdown:
+			pooled.conn = nil
+		case connectivity.TransientFailure:
+			pooled.conn.ResetConnectBackoff()
+			return pooled.conn, nil
+		default:
+			return pooled.conn, nil
+		}
+	}
+
+	host, tlsCert, macaroonBytes, err := connectionDetails()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := connect(host, tlsCert, macaroonBytes, grpc.WithKeepaliveParams(keepaliveParams))
+	if err != nil {
+		return nil, err
+	}
+	pooled.host = host
+	pooled.tlsCert = tlsCert
+	pooled.macaroonBytes = macaroonBytes
+	pooled.conn = conn
+	return conn, nil
+}
+
+// CloseChangedConnection closes the pooled connection of a node when the host or credentials are no longer the
+// ones it was dialed with. Requests still running on it fail, the next GetConnection dials the node again.
+func CloseChangedConnection(nodeId int, host string, tlsCert []byte, macaroonBytes []byte) {
+	pooled := getPooledConnection(nodeId)
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+	if host == pooled.host && bytes.Equal(tlsCert, pooled.tlsCert) && bytes.Equal(macaroonBytes, pooled.macaroonBytes) {
+		return
+	}
+	pooled.close(nodeId)
+}
+
+// CloseConnection closes the pooled connection of a node (i.e. when the node is disabled or removed).
+func CloseConnection(nodeId int) {
+	pooled := getPooledConnection(nodeId)
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+	pooled.close(nodeId)
+}
+
+func (pooled *pooledConnection) close(nodeId int) {
+	if pooled.conn == nil {
+		return
+	}
+	if err := pooled.conn.Close(); err != nil {
+		log.Debug().Err(err).Msgf("Closing pooled gRPC connection for node id: %v", nodeId)
+	}
+	pooled.conn = nil
+}
@@ -0,0 +1,194 @@
+package lnd_connect
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/macaroon.v2"
+)
+
+// startNode starts a gRPC server with a self-signed certificate like LND uses and returns its host and certificate.
+func startNode(t *testing.T) (string, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	certificate, err := tls.X509KeyPair(certPem, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(&certificate)))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), certPem
+}
+
+func testMacaroon(t *testing.T, id string) []byte {
+	t.Helper()
+	mac, err := macaroon.New([]byte("root key"), []byte(id), "lnd", macaroon.LatestVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	macaroonBytes, err := mac.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return macaroonBytes
+}
+
+// countingDetails returns the connection details and counts how often they were needed to dial.
+func countingDetails(host string, tlsCert []byte, macaroonBytes []byte, dials *int32) ConnectionDetailsFunc {
+	return func() (string, []byte, []byte, error) {
+		atomic.AddInt32(dials, 1)
+		return host, tlsCert, macaroonBytes, nil
+	}
+}
+
+func TestGetConnectionReusesConnection(t *testing.T) {
+	nodeId := 1001
+	defer CloseConnection(nodeId)
+	host, tlsCert := startNode(t)
+	var dials int32
+	details := countingDetails(host, tlsCert, testMacaroon(t, "admin"), &dials)
+
+	first, err := GetConnection(nodeId, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GetConnection(nodeId, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || dials != 1 {
+		t.Errorf("GetConnection() twice\nGot:\n%v dials, same connection %v\nWant:\n%v dials, same connection %v\n",
+			dials, first == second, 1, true)
+	}
+
+	// A connection that was shut down elsewhere is dialed again.
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	third, err := GetConnection(nodeId, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || dials != 2 {
+		t.Errorf("GetConnection() after shutdown\nGot:\n%v dials, same connection %v\nWant:\n%v dials, same connection %v\n",
+			dials, third == first, 2, false)
+	}
+}
+
+func TestCloseChangedConnection(t *testing.T) {
+	nodeId := 1002
+	defer CloseConnection(nodeId)
+	host, tlsCert := startNode(t)
+	macaroonBytes := testMacaroon(t, "admin")
+	var dials int32
+
+	first, err := GetConnection(nodeId, countingDetails(host, tlsCert, macaroonBytes, &dials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged details keep the connection.
+	CloseChangedConnection(nodeId, host, tlsCert, testMacaroon(t, "admin"))
+	if first.GetState() == connectivity.Shutdown {
+		t.Fatalf("CloseChangedConnection() unchanged\nGot:\n%v\nWant:\n%v\n", first.GetState(), "not shut down")
+	}
+
+	// A new macaroon replaces the connection.
+	changedMacaroon := testMacaroon(t, "readonly")
+	CloseChangedConnection(nodeId, host, tlsCert, changedMacaroon)
+	if first.GetState() != connectivity.Shutdown {
+		t.Errorf("CloseChangedConnection() changed\nGot:\n%v\nWant:\n%v\n", first.GetState(), connectivity.Shutdown)
+	}
+	second, err := GetConnection(nodeId, countingDetails(host, tlsCert, changedMacaroon, &dials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || dials != 2 {
+		t.Errorf("GetConnection() after change\nGot:\n%v dials, same connection %v\nWant:\n%v dials, same connection %v\n",
+			dials, second == first, 2, false)
+	}
+
+	// A new host replaces the connection too.
+	otherHost, otherTlsCert := startNode(t)
+	CloseChangedConnection(nodeId, otherHost, otherTlsCert, changedMacaroon)
+	if second.GetState() != connectivity.Shutdown {
+		t.Errorf("CloseChangedConnection() host changed\nGot:\n%v\nWant:\n%v\n", second.GetState(), connectivity.Shutdown)
+	}
+}
+
+func TestGetConnectionConcurrently(t *testing.T) {
+	nodeId := 1003
+	defer CloseConnection(nodeId)
+	host, tlsCert := startNode(t)
+	var dials int32
+	details := countingDetails(host, tlsCert, testMacaroon(t, "admin"), &dials)
+
+	const requests = 20
+	connections := make([]*grpc.ClientConn, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			connections[i], errs[i] = GetConnection(nodeId, details)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < requests; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if connections[i] != connections[0] {
+			t.Fatalf("GetConnection() concurrently\nGot:\n%v\nWant:\n%v\n", "different connections", "one connection")
+		}
+	}
+	if dials != 1 {
+		t.Errorf("GetConnection() concurrently dials\nGot:\n%v\nWant:\n%v\n", dials, 1)
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are the upper bounds in seconds used for latency histograms.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5} //nolint:gochecknoglobals
+
+// Default is the registry served on /metrics, the constructors of this package register their metrics with it.
+var Default = prometheus.NewRegistry() //nolint:gochecknoglobals
+
+// NewCounter creates a counter without labels and registers it with the default registry.
+func NewCounter(name string, help string) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	Default.MustRegister(counter)
+	return counter
+}
+
+// NewGauge creates a gauge without labels and registers it with the default registry.
+func NewGauge(name string, help string) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	Default.MustRegister(gauge)
+	return gauge
+}
+
+// NewCounterVec creates a counter with the given labels and registers it with the default registry.
+func NewCounterVec(name string, help string, labelNames ...string) *prometheus.CounterVec {
+	counterVec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labelNames)
+	Default.MustRegister(counterVec)
+	return counterVec
+}
+
+// NewGaugeVec creates a gauge with the given labels and registers it with the default registry.
+func NewGaugeVec(name string, help string, labelNames ...string) *prometheus.GaugeVec {
+	gaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labelNames)
+	Default.MustRegister(gaugeVec)
+	return gaugeVec
+}
+
+// NewHistogramVec creates a histogram with the given buckets and labels and registers it with the default registry.
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *prometheus.HistogramVec {
+	histogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets},
+		labelNames)
+	Default.MustRegister(histogramVec)
+	return histogramVec
+}
+
+// Handler serves the metrics of the default registry in the Prometheus exposition format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Default, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}))
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler(t *testing.T) {
+	counter := NewCounterVec("torq_test_events_total", "Events processed.", "node_id", "stream")
+	counter.WithLabelValues("1", "invoices").Add(3)
+	histogram := NewHistogramVec("torq_test_write_seconds", "Write duration.", []float64{0.01, 0.1}, "stream")
+	histogram.WithLabelValues("invoices").Observe(0.05)
+	NewCounter("torq_test_dropped_total", "Events dropped.").Inc()
+	NewGauge("torq_test_listeners", "Listeners.").Set(2)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", Handler())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status\nGot:\n%v\nWant:\n%v\n", w.Code, http.StatusOK)
+	}
+	tests := []string{
+		"# TYPE torq_test_events_total counter",
+		`torq_test_events_total{node_id="1",stream="invoices"} 3`,
+		`torq_test_write_seconds_bucket{stream="invoices",le="0.01"} 0`,
+		`torq_test_write_seconds_bucket{stream="invoices",le="0.1"} 1`,
+		`torq_test_write_seconds_count{stream="invoices"} 1`,
+		"# TYPE torq_test_dropped_total counter",
+		"torq_test_dropped_total 1",
+		"# TYPE torq_test_listeners gauge",
+		"torq_test_listeners 2",
+	}
+	for i, want := range tests {
+		if !strings.Contains(w.Body.String(), want+"\n") {
+			t.Errorf("%d: GET /metrics\nGot:\n%v\nWant:\n%v\n", i, w.Body.String(), want)
+		}
+	}
+}
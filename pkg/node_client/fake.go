@@ -0,0 +1,1387 @@
+package node_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	fakeStartHeight         = 100000
+	fakeWalletBalance       = 100000000
+	fakeFeeBaseMsat         = 1000
+	fakeFeeRateMilliMsat    = 100
+	fakeTimeLockDelta       = 40
+	fakeMinHtlcMsat         = 1000
+	fakeConfirmations       = 3
+	fakeCsvDelay            = 144
+	fakeSignedMessagePrefix = "Lightning Signed Message:"
+)
+
+//nolint:gochecknoglobals
+var (
+	fakeNodes   = make(map[string]*FakeNode)
+	fakeNodesMu sync.RWMutex
+)
+
+// FakeNode simulates a signet node in memory. Channels, invoices, payments and forwards are created through the
+// NodeClient methods or the simulation methods (AddPeer, AddChannel, PayInvoice, Forward, MineBlocks) and are sent to
+// the subscriptions like a node would.
+// Payments succeed when a channel has enough local balance and the destination is another FakeNode, the invoice of the
+// destination is settled. Signatures of messages are hex encoded instead of zbase32.
+type FakeNode struct {
+	mu         sync.Mutex
+	privateKey *btcec.PrivateKey
+	publicKey  string
+	alias      string
+	params     *chaincfg.Params
+
+	blockHeight      uint32
+	blockTime        time.Time
+	confirmedBalance int64
+	nextTxIndex      uint32
+	nextHtlcId       uint64
+
+	channels       []*lnrpc.Channel
+	pendingOpen    []*lnrpc.PendingChannelsResponse_PendingOpenChannel
+	pendingClose   []*lnrpc.PendingChannelsResponse_ForceClosedChannel
+	closedChannels []*lnrpc.ChannelCloseSummary
+	policies       map[uint64]*lnrpc.RoutingPolicy
+	nodes          map[string]*lnrpc.LightningNode
+	peers          []*lnrpc.Peer
+	invoices       []*lnrpc.Invoice
+	payments       []*lnrpc.Payment
+	forwards       []*lnrpc.ForwardingEvent
+	transactions   []*lnrpc.Transaction
+
+	subscriptions map[string][]*fakeStream
+}
+
+// NewFakeNode returns a FakeNode with a new identity and an on-chain balance of one bitcoin.
+func NewFakeNode(alias string) *FakeNode {
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		panic(err)
+	}
+	node := &FakeNode{
+		privateKey:       privateKey,
+		publicKey:        hex.EncodeToString(privateKey.PubKey().SerializeCompressed()),
+		alias:            alias,
+		params:           &chaincfg.SigNetParams,
+		blockHeight:      fakeStartHeight,
+		blockTime:        time.Now(),
+		confirmedBalance: fakeWalletBalance,
+		policies:         make(map[uint64]*lnrpc.RoutingPolicy),
+		nodes:            make(map[string]*lnrpc.LightningNode),
+		subscriptions:    make(map[string][]*fakeStream),
+	}
+	node.nodes[node.publicKey] = &lnrpc.LightningNode{PubKey: node.publicKey, Alias: alias,
+		LastUpdate: uint32(time.Now().Unix())}
+
+	fakeNodesMu.Lock()
+	defer fakeNodesMu.Unlock()
+	fakeNodes[node.publicKey] = node
+	return node
+}
+
+var _ NodeClient = (*FakeNode)(nil) //nolint:gochecknoglobals
+
+func (n *FakeNode) PublicKey() string {
+	return n.publicKey
+}
+
+// AddPeer connects a peer, the alias is used for the node announcement.
+func (n *FakeNode) AddPeer(publicKey string, alias string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.addPeer(publicKey, alias)
+}
+
+func (n *FakeNode) addPeer(publicKey string, alias string) {
+	if _, exists := n.nodes[publicKey]; !exists || alias != "" {
+		n.nodes[publicKey] = &lnrpc.LightningNode{PubKey: publicKey, Alias: alias,
+			LastUpdate: uint32(time.Now().Unix())}
+	}
+	if n.peer(publicKey) != nil {
+		return
+	}
+	n.peers = append(n.peers, &lnrpc.Peer{PubKey: publicKey, Address: "127.0.0.1:9735", Inbound: false,
+		SyncType: lnrpc.Peer_ACTIVE_SYNC})
+	n.publish(fakePeerEvents, &lnrpc.PeerEvent{PubKey: publicKey, Type: lnrpc.PeerEvent_PEER_ONLINE})
+}
+
+func (n *FakeNode) peer(publicKey string) *lnrpc.Peer {
+	for _, peer := range n.peers {
+		if peer.PubKey == publicKey {
+			return peer
+		}
+	}
+	return nil
+}
+
+// AddChannel adds an open and active channel with the peer, the peer is connected when it isn't already.
+func (n *FakeNode) AddChannel(remotePublicKey string, capacity int64, localBalance int64) *lnrpc.Channel {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.addPeer(remotePublicKey, "")
+	txid := n.fundingTransaction(capacity)
+	return n.openChannel(remotePublicKey, txid, 0, capacity, localBalance, false)
+}
+
+// fundingTransaction records an on-chain transaction of the amount and returns its hash.
+func (n *FakeNode) fundingTransaction(amount int64) chainhash.Hash {
+	var txid chainhash.Hash
+	_, _ = rand.Read(txid[:])
+	n.nextTxIndex++
+	transaction := &lnrpc.Transaction{
+		TxHash:    txid.String(),
+		Amount:    -amount,
+		TimeStamp: time.Now().Unix(),
+		Label:     "fake",
+	}
+	n.transactions = append(n.transactions, transaction)
+	n.publish(fakeTransactions, transaction)
+	return txid
+}
+
+func (n *FakeNode) openChannel(remotePublicKey string, txid chainhash.Hash, outputIndex uint32, capacity int64,
+	localBalance int64, private bool) *lnrpc.Channel {
+
+	n.nextTxIndex++
+	chanId := lnwire.ShortChannelID{BlockHeight: n.blockHeight, TxIndex: n.nextTxIndex,
+		TxPosition: uint16(outputIndex)}.ToUint64()
+	channel := &lnrpc.Channel{
+		Active:        true,
+		RemotePubkey:  remotePublicKey,
+		ChannelPoint:  txid.String() + ":" + itoa(uint64(outputIndex)),
+		ChanId:        chanId,
+		Capacity:      capacity,
+		LocalBalance:  localBalance,
+		RemoteBalance: capacity - localBalance,
+		Private:       private,
+		Initiator:     true,
+		CsvDelay:      144,
+		LocalConstraints: &lnrpc.ChannelConstraints{
+			CsvDelay: 144, MinHtlcMsat: fakeMinHtlcMsat, MaxPendingAmtMsat: uint64(capacity) * 1000},
+		RemoteConstraints: &lnrpc.ChannelConstraints{
+			CsvDelay: 144, MinHtlcMsat: fakeMinHtlcMsat, MaxPendingAmtMsat: uint64(capacity) * 1000},
+	}
+	n.channels = append(n.channels, channel)
+	n.policies[chanId] = &lnrpc.RoutingPolicy{
+		TimeLockDelta:    fakeTimeLockDelta,
+		MinHtlc:          fakeMinHtlcMsat,
+		FeeBaseMsat:      fakeFeeBaseMsat,
+		FeeRateMilliMsat: fakeFeeRateMilliMsat,
+		MaxHtlcMsat:      uint64(capacity) * 1000,
+		LastUpdate:       uint32(time.Now().Unix()),
+	}
+
+	n.publish(fakeChannelEvents, &lnrpc.ChannelEventUpdate{
+		Type:    lnrpc.ChannelEventUpdate_OPEN_CHANNEL,
+		Channel: &lnrpc.ChannelEventUpdate_OpenChannel{OpenChannel: channel},
+	})
+	n.publish(fakeChannelEvents, &lnrpc.ChannelEventUpdate{
+		Type: lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL,
+		Channel: &lnrpc.ChannelEventUpdate_ActiveChannel{ActiveChannel: &lnrpc.ChannelPoint{
+			FundingTxid: &lnrpc.ChannelPoint_FundingTxidBytes{FundingTxidBytes: txid[:]}, OutputIndex: outputIndex}},
+	})
+	n.publishPolicy(channel)
+	n.publish(fakeChannelBackups, n.channelBackups())
+	return channel
+}
+
+func (n *FakeNode) publishPolicy(channel *lnrpc.Channel) {
+	chanPoint, _ := channelPoint(channel.ChannelPoint)
+	n.publish(fakeChannelGraph, &lnrpc.GraphTopologyUpdate{ChannelUpdates: []*lnrpc.ChannelEdgeUpdate{{
+		ChanId:          channel.ChanId,
+		ChanPoint:       chanPoint,
+		Capacity:        channel.Capacity,
+		RoutingPolicy:   n.policies[channel.ChanId],
+		AdvertisingNode: n.publicKey,
+		ConnectingNode:  channel.RemotePubkey,
+	}}})
+}
+
+func (n *FakeNode) channel(chanId uint64) *lnrpc.Channel {
+	for _, channel := range n.channels {
+		if channel.ChanId == chanId {
+			return channel
+		}
+	}
+	return nil
+}
+
+// MineBlocks confirms pending channels and transactions.
+func (n *FakeNode) MineBlocks(blocks uint32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.blockHeight += blocks
+	n.blockTime = time.Now()
+	for _, transaction := range n.transactions {
+		if transaction.NumConfirmations == 0 {
+			transaction.BlockHeight = int32(n.blockHeight)
+			transaction.BlockHash = n.blockHash(n.blockHeight).String()
+		}
+		transaction.NumConfirmations = int32(n.blockHeight) - transaction.BlockHeight + 1
+	}
+	for height := n.blockHeight - blocks + 1; height <= n.blockHeight; height++ {
+		n.publish(fakeBlocks, n.blockEpoch(height))
+	}
+	pendingClose := n.pendingClose
+	n.pendingClose = nil
+	for _, pending := range pendingClose {
+		pending.BlocksTilMaturity = int32(pending.MaturityHeight) - int32(n.blockHeight)
+		if pending.BlocksTilMaturity > 0 {
+			n.pendingClose = append(n.pendingClose, pending)
+			continue
+		}
+		// The funds are swept as soon as they mature.
+		n.confirmedBalance += pending.LimboBalance
+	}
+	if n.blockHeight-fakeStartHeight < fakeConfirmations {
+		return
+	}
+	pendingOpen := n.pendingOpen
+	n.pendingOpen = nil
+	for _, pending := range pendingOpen {
+		txid, _ := chainhash.NewHashFromStr(pending.Channel.ChannelPoint[:64])
+		n.openChannel(pending.Channel.RemoteNodePub, *txid, 0, pending.Channel.Capacity,
+			pending.Channel.LocalBalance, pending.Channel.Private)
+	}
+}
+
+// PayInvoice simulates a peer paying an invoice of the node, the amount is received over the first channel with enough
+// remote balance.
+func (n *FakeNode) PayInvoice(paymentRequest string, amountMsat int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	invoice := n.invoiceByPaymentRequest(paymentRequest)
+	if invoice == nil {
+		return errors.New("Invoice not found")
+	}
+	if amountMsat == 0 {
+		amountMsat = invoice.ValueMsat
+	}
+	_, err := n.settleInvoice(invoice.RHash, amountMsat)
+	return err
+}
+
+func (n *FakeNode) invoiceByPaymentRequest(paymentRequest string) *lnrpc.Invoice {
+	for _, invoice := range n.invoices {
+		if invoice.PaymentRequest == paymentRequest {
+			return invoice
+		}
+	}
+	return nil
+}
+
+// settleInvoice settles the invoice with the hash and returns its preimage.
+func (n *FakeNode) settleInvoice(paymentHash []byte, amountMsat int64) ([]byte, error) {
+	var invoice *lnrpc.Invoice
+	for _, existing := range n.invoices {
+		if bytes.Equal(existing.RHash, paymentHash) {
+			invoice = existing
+		}
+	}
+	if invoice == nil || invoice.State != lnrpc.Invoice_OPEN {
+		return nil, status.Error(codes.NotFound, "UnknownPaymentHash")
+	}
+	if amountMsat < invoice.ValueMsat {
+		return nil, status.Error(codes.InvalidArgument, "IncorrectPaymentAmount")
+	}
+	var channel *lnrpc.Channel
+	for _, candidate := range n.channels {
+		if candidate.Active && candidate.RemoteBalance*1000 >= amountMsat {
+			channel = candidate
+			break
+		}
+	}
+	if channel == nil {
+		return nil, status.Error(codes.Unavailable, "No channel with enough inbound liquidity")
+	}
+	channel.LocalBalance += amountMsat / 1000
+	channel.RemoteBalance -= amountMsat / 1000
+	channel.TotalSatoshisReceived += amountMsat / 1000
+	channel.NumUpdates++
+
+	now := time.Now()
+	var settleIndex uint64
+	for _, existing := range n.invoices {
+		if existing.SettleIndex > settleIndex {
+			settleIndex = existing.SettleIndex
+		}
+	}
+	invoice.State = lnrpc.Invoice_SETTLED
+	invoice.Settled = true //nolint:staticcheck
+	invoice.SettleDate = now.Unix()
+	invoice.SettleIndex = settleIndex + 1
+	invoice.AmtPaidMsat = amountMsat
+	invoice.AmtPaidSat = amountMsat / 1000
+	invoice.AmtPaid = amountMsat
+	invoice.Htlcs = []*lnrpc.InvoiceHTLC{{ChanId: channel.ChanId, HtlcIndex: n.nextHtlcId, AmtMsat: uint64(amountMsat),
+		AcceptHeight: int32(n.blockHeight), AcceptTime: now.Unix(), ResolveTime: now.Unix(),
+		State: lnrpc.InvoiceHTLCState_SETTLED}}
+	n.publish(fakeInvoices, invoice)
+
+	n.nextHtlcId++
+	n.publish(fakeHtlcEvents, &routerrpc.HtlcEvent{
+		IncomingChannelId: channel.ChanId,
+		IncomingHtlcId:    n.nextHtlcId,
+		TimestampNs:       uint64(now.UnixNano()),
+		EventType:         routerrpc.HtlcEvent_RECEIVE,
+		Event: &routerrpc.HtlcEvent_SettleEvent{SettleEvent: &routerrpc.SettleEvent{
+			Preimage: invoice.RPreimage}},
+	})
+	return invoice.RPreimage, nil
+}
+
+// Forward simulates a forward from the incoming to the outgoing channel, the fee is kept in the incoming channel.
+func (n *FakeNode) Forward(chanIdIn uint64, chanIdOut uint64, amountOutMsat int64, feeMsat int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	incoming := n.channel(chanIdIn)
+	outgoing := n.channel(chanIdOut)
+	if incoming == nil || outgoing == nil {
+		return errors.New("Channel not found")
+	}
+	amountInMsat := amountOutMsat + feeMsat
+	if incoming.RemoteBalance*1000 < amountInMsat || outgoing.LocalBalance*1000 < amountOutMsat {
+		return errors.New("Insufficient balance")
+	}
+	incoming.RemoteBalance -= amountInMsat / 1000
+	incoming.LocalBalance += amountInMsat / 1000
+	incoming.NumUpdates++
+	outgoing.LocalBalance -= amountOutMsat / 1000
+	outgoing.RemoteBalance += amountOutMsat / 1000
+	outgoing.NumUpdates++
+
+	now := time.Now()
+	n.nextHtlcId++
+	forward := &routerrpc.HtlcEvent{
+		IncomingChannelId: chanIdIn,
+		OutgoingChannelId: chanIdOut,
+		IncomingHtlcId:    n.nextHtlcId,
+		OutgoingHtlcId:    n.nextHtlcId,
+		TimestampNs:       uint64(now.UnixNano()),
+		EventType:         routerrpc.HtlcEvent_FORWARD,
+		Event: &routerrpc.HtlcEvent_ForwardEvent{ForwardEvent: &routerrpc.ForwardEvent{Info: &routerrpc.HtlcInfo{
+			IncomingTimelock: n.blockHeight + 2*fakeTimeLockDelta,
+			OutgoingTimelock: n.blockHeight + fakeTimeLockDelta,
+			IncomingAmtMsat:  uint64(amountInMsat),
+			OutgoingAmtMsat:  uint64(amountOutMsat),
+		}}},
+	}
+	n.publish(fakeHtlcEvents, forward)
+	settle := &routerrpc.HtlcEvent{
+		IncomingChannelId: chanIdIn,
+		OutgoingChannelId: chanIdOut,
+		IncomingHtlcId:    n.nextHtlcId,
+		OutgoingHtlcId:    n.nextHtlcId,
+		TimestampNs:       uint64(now.UnixNano()),
+		EventType:         routerrpc.HtlcEvent_FORWARD,
+		Event:             &routerrpc.HtlcEvent_SettleEvent{SettleEvent: &routerrpc.SettleEvent{}},
+	}
+	n.publish(fakeHtlcEvents, settle)
+
+	n.forwards = append(n.forwards, &lnrpc.ForwardingEvent{
+		Timestamp:   uint64(now.Unix()),
+		TimestampNs: uint64(now.UnixNano()),
+		ChanIdIn:    chanIdIn,
+		ChanIdOut:   chanIdOut,
+		AmtIn:       uint64(amountInMsat / 1000),
+		AmtOut:      uint64(amountOutMsat / 1000),
+		Fee:         uint64(feeMsat / 1000),
+		FeeMsat:     uint64(feeMsat),
+		AmtInMsat:   uint64(amountInMsat),
+		AmtOutMsat:  uint64(amountOutMsat),
+	})
+	return nil
+}
+
+func (n *FakeNode) GetInfo(ctx context.Context, in *lnrpc.GetInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.GetInfoResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var active, inactive uint32
+	for _, channel := range n.channels {
+		if channel.Active {
+			active++
+		} else {
+			inactive++
+		}
+	}
+	return &lnrpc.GetInfoResponse{
+		IdentityPubkey:      n.publicKey,
+		Alias:               n.alias,
+		Version:             "fake",
+		NumActiveChannels:   active,
+		NumInactiveChannels: inactive,
+		NumPendingChannels:  uint32(len(n.pendingOpen)),
+		NumPeers:            uint32(len(n.peers)),
+		BlockHeight:         n.blockHeight,
+		BlockHash:           n.blockHash(n.blockHeight).String(),
+		BestHeaderTimestamp: n.blockTime.Unix(),
+		SyncedToChain:       true,
+		SyncedToGraph:       true,
+		Chains:              []*lnrpc.Chain{{Chain: "bitcoin", Network: "signet"}},
+	}, nil
+}
+
+func (n *FakeNode) WalletBalance(ctx context.Context, in *lnrpc.WalletBalanceRequest,
+	opts ...grpc.CallOption) (*lnrpc.WalletBalanceResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return &lnrpc.WalletBalanceResponse{
+		TotalBalance:     n.confirmedBalance,
+		ConfirmedBalance: n.confirmedBalance,
+	}, nil
+}
+
+func (n *FakeNode) ListChannels(ctx context.Context, in *lnrpc.ListChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListChannelsResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	resp := &lnrpc.ListChannelsResponse{}
+	for _, channel := range n.channels {
+		switch {
+		case in.ActiveOnly && !channel.Active, in.InactiveOnly && channel.Active,
+			in.PublicOnly && channel.Private, in.PrivateOnly && !channel.Private,
+			len(in.Peer) != 0 && hex.EncodeToString(in.Peer) != channel.RemotePubkey:
+			continue
+		}
+		resp.Channels = append(resp.Channels, channel)
+	}
+	return proto.Clone(resp).(*lnrpc.ListChannelsResponse), nil
+}
+
+func (n *FakeNode) ClosedChannels(ctx context.Context, in *lnrpc.ClosedChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ClosedChannelsResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return proto.Clone(&lnrpc.ClosedChannelsResponse{Channels: n.closedChannels}).(*lnrpc.ClosedChannelsResponse), nil
+}
+
+func (n *FakeNode) PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var limboBalance int64
+	for _, pending := range n.pendingClose {
+		limboBalance += pending.LimboBalance
+	}
+	return proto.Clone(&lnrpc.PendingChannelsResponse{
+		TotalLimboBalance:           limboBalance,
+		PendingOpenChannels:         n.pendingOpen,
+		PendingForceClosingChannels: n.pendingClose,
+	}).(*lnrpc.PendingChannelsResponse), nil
+}
+
+func (n *FakeNode) GetChanInfo(ctx context.Context, in *lnrpc.ChanInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChannelEdge, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	channel := n.channel(in.ChanId)
+	if channel == nil {
+		return nil, status.Error(codes.NotFound, "edge not found")
+	}
+	edge := &lnrpc.ChannelEdge{
+		ChannelId:  channel.ChanId,
+		ChanPoint:  channel.ChannelPoint,
+		LastUpdate: n.policies[channel.ChanId].LastUpdate,
+		Capacity:   channel.Capacity,
+	}
+	remotePolicy := &lnrpc.RoutingPolicy{TimeLockDelta: fakeTimeLockDelta, MinHtlc: fakeMinHtlcMsat,
+		FeeBaseMsat: fakeFeeBaseMsat, FeeRateMilliMsat: fakeFeeRateMilliMsat, MaxHtlcMsat: uint64(channel.Capacity) * 1000}
+	if n.publicKey < channel.RemotePubkey {
+		edge.Node1Pub, edge.Node2Pub = n.publicKey, channel.RemotePubkey
+		edge.Node1Policy, edge.Node2Policy = n.policies[channel.ChanId], remotePolicy
+	} else {
+		edge.Node1Pub, edge.Node2Pub = channel.RemotePubkey, n.publicKey
+		edge.Node1Policy, edge.Node2Policy = remotePolicy, n.policies[channel.ChanId]
+	}
+	return proto.Clone(edge).(*lnrpc.ChannelEdge), nil
+}
+
+// OpenChannel opens a pending channel with a connected peer, MineBlocks confirms it.
+func (n *FakeNode) OpenChannel(ctx context.Context, in *lnrpc.OpenChannelRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_OpenChannelClient, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	pending, err := n.openPendingChannel(in)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeOpenChannelStream{newFakeStream(ctx, &lnrpc.OpenStatusUpdate{
+		Update: &lnrpc.OpenStatusUpdate_ChanPending{ChanPending: pending},
+	})}, nil
+}
+
+func (n *FakeNode) openPendingChannel(in *lnrpc.OpenChannelRequest) (*lnrpc.PendingUpdate, error) {
+	remotePublicKey := hex.EncodeToString(in.NodePubkey)
+	if n.peer(remotePublicKey) == nil {
+		return nil, status.Errorf(codes.Unavailable, "peer %v is not online", remotePublicKey)
+	}
+	if in.LocalFundingAmount > n.confirmedBalance {
+		return nil, status.Error(codes.Unknown, "not enough witness outputs to create funding transaction")
+	}
+	n.confirmedBalance -= in.LocalFundingAmount
+	txid := n.fundingTransaction(in.LocalFundingAmount)
+	n.pendingOpen = append(n.pendingOpen, &lnrpc.PendingChannelsResponse_PendingOpenChannel{
+		Channel: &lnrpc.PendingChannelsResponse_PendingChannel{
+			RemoteNodePub: remotePublicKey,
+			ChannelPoint:  txid.String() + ":0",
+			Capacity:      in.LocalFundingAmount,
+			LocalBalance:  in.LocalFundingAmount - in.PushSat,
+			RemoteBalance: in.PushSat,
+			Initiator:     lnrpc.Initiator_INITIATOR_LOCAL,
+			Private:       in.Private,
+		},
+	})
+	pending := &lnrpc.PendingUpdate{Txid: txid[:], OutputIndex: 0}
+	n.publish(fakeChannelEvents, &lnrpc.ChannelEventUpdate{
+		Type:    lnrpc.ChannelEventUpdate_PENDING_OPEN_CHANNEL,
+		Channel: &lnrpc.ChannelEventUpdate_PendingOpenChannel{PendingOpenChannel: pending},
+	})
+	return pending, nil
+}
+
+func (n *FakeNode) BatchOpenChannel(ctx context.Context, in *lnrpc.BatchOpenChannelRequest,
+	opts ...grpc.CallOption) (*lnrpc.BatchOpenChannelResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	resp := &lnrpc.BatchOpenChannelResponse{}
+	for _, channel := range in.Channels {
+		pending, err := n.openPendingChannel(&lnrpc.OpenChannelRequest{
+			NodePubkey:         channel.NodePubkey,
+			LocalFundingAmount: channel.LocalFundingAmount,
+			PushSat:            channel.PushSat,
+			Private:            channel.Private,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.PendingChannels = append(resp.PendingChannels, pending)
+	}
+	return resp, nil
+}
+
+// CloseChannel closes the channel immediately, the local balance returns to the wallet.
+func (n *FakeNode) CloseChannel(ctx context.Context, in *lnrpc.CloseChannelRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_CloseChannelClient, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var channel *lnrpc.Channel
+	for i, candidate := range n.channels {
+		chanPoint, err := channelPoint(candidate.ChannelPoint)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(chanPoint.GetFundingTxidBytes(), fundingTxidBytes(in.ChannelPoint)) &&
+			chanPoint.OutputIndex == in.ChannelPoint.OutputIndex {
+			channel = candidate
+			n.channels = append(n.channels[:i], n.channels[i+1:]...)
+			break
+		}
+	}
+	if channel == nil {
+		return nil, status.Error(codes.NotFound, "unable to find channel")
+	}
+	closingTxid := n.fundingTransaction(0)
+	closeType := lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE
+	if in.Force {
+		closeType = lnrpc.ChannelCloseSummary_LOCAL_FORCE_CLOSE
+		// The local balance is time locked until the CSV delay passed.
+		n.pendingClose = append(n.pendingClose, &lnrpc.PendingChannelsResponse_ForceClosedChannel{
+			Channel: &lnrpc.PendingChannelsResponse_PendingChannel{
+				RemoteNodePub: channel.RemotePubkey,
+				ChannelPoint:  channel.ChannelPoint,
+				Capacity:      channel.Capacity,
+				LocalBalance:  channel.LocalBalance,
+				RemoteBalance: channel.RemoteBalance,
+				Initiator:     lnrpc.Initiator_INITIATOR_LOCAL,
+			},
+			ClosingTxid:       closingTxid.String(),
+			LimboBalance:      channel.LocalBalance,
+			MaturityHeight:    n.blockHeight + fakeCsvDelay,
+			BlocksTilMaturity: fakeCsvDelay,
+			Anchor:            lnrpc.PendingChannelsResponse_ForceClosedChannel_LIMBO,
+		})
+	} else {
+		n.confirmedBalance += channel.LocalBalance
+	}
+	summary := &lnrpc.ChannelCloseSummary{
+		ChannelPoint:      channel.ChannelPoint,
+		ChanId:            channel.ChanId,
+		ClosingTxHash:     closingTxid.String(),
+		RemotePubkey:      channel.RemotePubkey,
+		Capacity:          channel.Capacity,
+		CloseHeight:       n.blockHeight,
+		SettledBalance:    channel.LocalBalance,
+		CloseType:         closeType,
+		OpenInitiator:     lnrpc.Initiator_INITIATOR_LOCAL,
+		CloseInitiator:    lnrpc.Initiator_INITIATOR_LOCAL,
+		TimeLockedBalance: 0,
+	}
+	n.closedChannels = append(n.closedChannels, summary)
+	delete(n.policies, channel.ChanId)
+	n.publish(fakeChannelEvents, &lnrpc.ChannelEventUpdate{
+		Type:    lnrpc.ChannelEventUpdate_CLOSED_CHANNEL,
+		Channel: &lnrpc.ChannelEventUpdate_ClosedChannel{ClosedChannel: summary},
+	})
+	n.publish(fakeChannelGraph, &lnrpc.GraphTopologyUpdate{ClosedChans: []*lnrpc.ClosedChannelUpdate{{
+		ChanId: channel.ChanId, Capacity: channel.Capacity, ClosedHeight: n.blockHeight, ChanPoint: in.ChannelPoint,
+	}}})
+	n.publish(fakeChannelBackups, n.channelBackups())
+	return &fakeCloseChannelStream{newFakeStream(ctx,
+		&lnrpc.CloseStatusUpdate{Update: &lnrpc.CloseStatusUpdate_ClosePending{
+			ClosePending: &lnrpc.PendingUpdate{Txid: closingTxid[:]}}},
+		&lnrpc.CloseStatusUpdate{Update: &lnrpc.CloseStatusUpdate_ChanClose{
+			ChanClose: &lnrpc.ChannelCloseUpdate{ClosingTxid: closingTxid[:], Success: true}}},
+	)}, nil
+}
+
+func (n *FakeNode) UpdateChannelPolicy(ctx context.Context, in *lnrpc.PolicyUpdateRequest,
+	opts ...grpc.CallOption) (*lnrpc.PolicyUpdateResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, channel := range n.channels {
+		if chanPoint := in.GetChanPoint(); chanPoint != nil {
+			candidate, err := channelPoint(channel.ChannelPoint)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(candidate.GetFundingTxidBytes(), fundingTxidBytes(chanPoint)) ||
+				candidate.OutputIndex != chanPoint.OutputIndex {
+				continue
+			}
+		}
+		policy := n.policies[channel.ChanId]
+		policy.FeeBaseMsat = in.BaseFeeMsat
+		policy.FeeRateMilliMsat = int64(in.FeeRatePpm)
+		if in.FeeRatePpm == 0 {
+			policy.FeeRateMilliMsat = int64(in.FeeRate * 1e6)
+		}
+		policy.TimeLockDelta = in.TimeLockDelta
+		if in.MinHtlcMsatSpecified {
+			policy.MinHtlc = int64(in.MinHtlcMsat)
+		}
+		if in.MaxHtlcMsat != 0 {
+			policy.MaxHtlcMsat = in.MaxHtlcMsat
+		}
+		if inboundFee := GetPolicyUpdateInboundFee(in); inboundFee != nil {
+			SetInboundFee(policy, *inboundFee)
+		}
+		policy.LastUpdate = uint32(time.Now().Unix())
+		n.publishPolicy(channel)
+	}
+	return &lnrpc.PolicyUpdateResponse{}, nil
+}
+
+func (n *FakeNode) GetNodeInfo(ctx context.Context, in *lnrpc.NodeInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.NodeInfo, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	node, exists := n.nodes[in.PubKey]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "unable to find node")
+	}
+	info := &lnrpc.NodeInfo{Node: node}
+	for _, channel := range n.channels {
+		if in.PubKey == n.publicKey || channel.RemotePubkey == in.PubKey {
+			info.NumChannels++
+			info.TotalCapacity += channel.Capacity
+		}
+	}
+	return proto.Clone(info).(*lnrpc.NodeInfo), nil
+}
+
+func (n *FakeNode) ListPeers(ctx context.Context, in *lnrpc.ListPeersRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPeersResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return proto.Clone(&lnrpc.ListPeersResponse{Peers: n.peers}).(*lnrpc.ListPeersResponse), nil
+}
+
+func (n *FakeNode) ConnectPeer(ctx context.Context, in *lnrpc.ConnectPeerRequest,
+	opts ...grpc.CallOption) (*lnrpc.ConnectPeerResponse, error) {
+
+	if in.Addr == nil || in.Addr.Pubkey == "" {
+		return nil, status.Error(codes.InvalidArgument, "need: lnc pubkeyhash@hostname")
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.peer(in.Addr.Pubkey) != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "already connected to peer: %v", in.Addr.Pubkey)
+	}
+	n.addPeer(in.Addr.Pubkey, "")
+	return &lnrpc.ConnectPeerResponse{}, nil
+}
+
+func (n *FakeNode) ForwardingHistory(ctx context.Context, in *lnrpc.ForwardingHistoryRequest,
+	opts ...grpc.CallOption) (*lnrpc.ForwardingHistoryResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	maxEvents := in.NumMaxEvents
+	if maxEvents == 0 {
+		maxEvents = 100
+	}
+	resp := &lnrpc.ForwardingHistoryResponse{LastOffsetIndex: in.IndexOffset}
+	var index uint32
+	for _, forward := range n.forwards {
+		if forward.Timestamp < in.StartTime || (in.EndTime != 0 && forward.Timestamp > in.EndTime) {
+			continue
+		}
+		index++
+		if index <= in.IndexOffset {
+			continue
+		}
+		if uint32(len(resp.ForwardingEvents)) == maxEvents {
+			break
+		}
+		resp.ForwardingEvents = append(resp.ForwardingEvents, forward)
+		resp.LastOffsetIndex = index
+	}
+	return proto.Clone(resp).(*lnrpc.ForwardingHistoryResponse), nil
+}
+
+func (n *FakeNode) ListPayments(ctx context.Context, in *lnrpc.ListPaymentsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPaymentsResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	resp := &lnrpc.ListPaymentsResponse{}
+	for _, payment := range n.payments {
+		if payment.PaymentIndex <= in.IndexOffset ||
+			(!in.IncludeIncomplete && payment.Status != lnrpc.Payment_SUCCEEDED) {
+			continue
+		}
+		if in.MaxPayments != 0 && uint64(len(resp.Payments)) == in.MaxPayments {
+			break
+		}
+		resp.Payments = append(resp.Payments, payment)
+	}
+	if len(resp.Payments) != 0 {
+		resp.FirstIndexOffset = resp.Payments[0].PaymentIndex
+		resp.LastIndexOffset = resp.Payments[len(resp.Payments)-1].PaymentIndex
+	}
+	return proto.Clone(resp).(*lnrpc.ListPaymentsResponse), nil
+}
+
+func (n *FakeNode) ListInvoices(ctx context.Context, in *lnrpc.ListInvoiceRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListInvoiceResponse, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	resp := &lnrpc.ListInvoiceResponse{}
+	for _, invoice := range n.invoices {
+		if invoice.AddIndex <= in.IndexOffset || (in.PendingOnly && invoice.State != lnrpc.Invoice_OPEN) {
+			continue
+		}
+		if in.NumMaxInvoices != 0 && uint64(len(resp.Invoices)) == in.NumMaxInvoices {
+			break
+		}
+		resp.Invoices = append(resp.Invoices, invoice)
+	}
+	if len(resp.Invoices) != 0 {
+		resp.FirstIndexOffset = resp.Invoices[0].AddIndex
+		resp.LastIndexOffset = resp.Invoices[len(resp.Invoices)-1].AddIndex
+	}
+	return proto.Clone(resp).(*lnrpc.ListInvoiceResponse), nil
+}
+
+func (n *FakeNode) DecodePayReq(ctx context.Context, in *lnrpc.PayReqString,
+	opts ...grpc.CallOption) (*lnrpc.PayReq, error) {
+
+	decoded, err := zpay32.Decode(in.PayReq, n.params)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "invalid payment request: %v", err)
+	}
+	result := &lnrpc.PayReq{
+		Destination: hex.EncodeToString(decoded.Destination.SerializeCompressed()),
+		PaymentHash: hex.EncodeToString(decoded.PaymentHash[:]),
+		Timestamp:   decoded.Timestamp.Unix(),
+		Expiry:      int64(decoded.Expiry().Seconds()),
+		CltvExpiry:  int64(decoded.MinFinalCLTVExpiry()),
+	}
+	if decoded.PaymentAddr != nil {
+		result.PaymentAddr = decoded.PaymentAddr[:]
+	}
+	if decoded.Description != nil {
+		result.Description = *decoded.Description
+	}
+	if decoded.MilliSat != nil {
+		result.NumMsat = int64(*decoded.MilliSat)
+		result.NumSatoshis = int64(decoded.MilliSat.ToSatoshis())
+	}
+	return result, nil
+}
+
+func (n *FakeNode) AddInvoice(ctx context.Context, in *lnrpc.Invoice,
+	opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error) {
+
+	preimage := in.RPreimage
+	if len(preimage) == 0 {
+		preimage = make([]byte, 32)
+		_, _ = rand.Read(preimage)
+	}
+	paymentHash := sha256.Sum256(preimage)
+	var paymentAddr [32]byte
+	_, _ = rand.Read(paymentAddr[:])
+	valueMsat := in.ValueMsat
+	if valueMsat == 0 {
+		valueMsat = in.Value * 1000
+	}
+	expiry := in.Expiry
+	if expiry == 0 {
+		expiry = 86400
+	}
+	now := time.Now()
+	options := []func(*zpay32.Invoice){
+		zpay32.Description(in.Memo),
+		zpay32.Expiry(time.Duration(expiry) * time.Second),
+		zpay32.PaymentAddr(paymentAddr),
+	}
+	if valueMsat != 0 {
+		options = append(options, zpay32.Amount(lnwire.MilliSatoshi(valueMsat)))
+	}
+	if in.FallbackAddr != "" {
+		address, err := btcutil.DecodeAddress(in.FallbackAddr, n.params)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid fallback address: %v", err)
+		}
+		options = append(options, zpay32.FallbackAddr(address))
+	}
+	decoded, err := zpay32.NewInvoice(n.params, paymentHash, now, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating invoice")
+	}
+	paymentRequest, err := decoded.Encode(zpay32.MessageSigner{SignCompact: func(msg []byte) ([]byte, error) {
+		hash := sha256.Sum256(msg)
+		return ecdsa.SignCompact(n.privateKey, hash[:], true)
+	}})
+	if err != nil {
+		return nil, errors.Wrap(err, "Signing invoice")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	invoice := &lnrpc.Invoice{
+		Memo:           in.Memo,
+		RPreimage:      preimage,
+		RHash:          paymentHash[:],
+		Value:          valueMsat / 1000,
+		ValueMsat:      valueMsat,
+		CreationDate:   now.Unix(),
+		PaymentRequest: paymentRequest,
+		Expiry:         expiry,
+		FallbackAddr:   in.FallbackAddr,
+		CltvExpiry:     uint64(decoded.MinFinalCLTVExpiry()),
+		Private:        in.Private,
+		AddIndex:       uint64(len(n.invoices) + 1),
+		State:          lnrpc.Invoice_OPEN,
+		PaymentAddr:    paymentAddr[:],
+	}
+	n.invoices = append(n.invoices, invoice)
+	n.publish(fakeInvoices, invoice)
+	return &lnrpc.AddInvoiceResponse{
+		RHash:          invoice.RHash,
+		PaymentRequest: paymentRequest,
+		AddIndex:       invoice.AddIndex,
+		PaymentAddr:    invoice.PaymentAddr,
+	}, nil
+}
+
+// SendPaymentV2 pays an invoice of another FakeNode over the first channel with enough local balance, the destination
+// is reached directly when there is a channel with it and otherwise through an imaginary route for the base fee.
+func (n *FakeNode) SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client, error) {
+
+	decoded, err := n.DecodePayReq(ctx, &lnrpc.PayReqString{PayReq: in.PaymentRequest})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "InvalidPaymentRequest: %v", err)
+	}
+	amountMsat := decoded.NumMsat
+	switch {
+	case amountMsat == 0 && in.AmtMsat == 0 && in.Amt == 0:
+		return nil, status.Error(codes.InvalidArgument, "amount must be specified when paying a zero amount invoice")
+	case amountMsat != 0 && (in.AmtMsat != 0 || in.Amt != 0):
+		return nil, status.Error(codes.InvalidArgument,
+			"amount must not be specified when paying a non-zero  amount invoice")
+	case in.AmtMsat != 0:
+		amountMsat = in.AmtMsat
+	case in.Amt != 0:
+		amountMsat = in.Amt * 1000
+	}
+	feeLimitMsat := in.FeeLimitMsat
+	if feeLimitMsat == 0 {
+		feeLimitMsat = in.FeeLimitSat * 1000
+	}
+	paymentHash, err := hex.DecodeString(decoded.PaymentHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding payment hash")
+	}
+
+	n.mu.Lock()
+	for _, payment := range n.payments {
+		if payment.PaymentHash == decoded.PaymentHash && payment.Status == lnrpc.Payment_SUCCEEDED {
+			n.mu.Unlock()
+			return &fakePaymentStream{newFakeStream(ctx)},
+				status.Error(codes.AlreadyExists, "AlreadyExists: invoice is already paid")
+		}
+	}
+	now := time.Now()
+	payment := &lnrpc.Payment{
+		PaymentHash:    decoded.PaymentHash,
+		Value:          amountMsat / 1000,
+		CreationDate:   now.Unix(),
+		ValueSat:       amountMsat / 1000,
+		ValueMsat:      amountMsat,
+		PaymentRequest: in.PaymentRequest,
+		Status:         lnrpc.Payment_IN_FLIGHT,
+		CreationTimeNs: now.UnixNano(),
+		PaymentIndex:   uint64(len(n.payments) + 1),
+	}
+	n.payments = append(n.payments, payment)
+	inFlight := proto.Clone(payment).(*lnrpc.Payment)
+	var feeMsat int64
+	var channel *lnrpc.Channel
+	for _, candidate := range n.channels {
+		if candidate.RemotePubkey == decoded.Destination {
+			channel = candidate
+			break
+		}
+	}
+	if channel == nil {
+		feeMsat = fakeFeeBaseMsat
+	}
+	if channel == nil || channel.LocalBalance*1000 < amountMsat+feeMsat {
+		channel = nil
+		for _, candidate := range n.channels {
+			if candidate.Active && candidate.LocalBalance*1000 >= amountMsat+feeMsat {
+				channel = candidate
+				break
+			}
+		}
+	}
+	// A circular payment leaves through the pinned channel and comes back through the channel with the last hop.
+	if len(in.OutgoingChanIds) != 0 {
+		channel = n.channel(in.OutgoingChanIds[0])
+		if channel != nil && channel.LocalBalance*1000 < amountMsat+feeMsat {
+			channel = nil
+		}
+	}
+	var lastHop *lnrpc.Channel
+	for _, candidate := range n.channels {
+		if len(in.LastHopPubkey) != 0 && candidate.RemotePubkey == hex.EncodeToString(in.LastHopPubkey) {
+			lastHop = candidate
+			break
+		}
+	}
+	failureReason := lnrpc.PaymentFailureReason_FAILURE_REASON_NONE
+	switch {
+	case decoded.Destination == n.publicKey && !in.AllowSelfPayment:
+		failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR
+	case len(n.channels) == 0:
+		failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE
+	case channel == nil:
+		failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_INSUFFICIENT_BALANCE
+	case len(in.LastHopPubkey) != 0 && (lastHop == nil || lastHop == channel):
+		failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE
+	case feeLimitMsat != 0 && feeMsat > feeLimitMsat:
+		failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE
+	}
+	n.mu.Unlock()
+
+	var preimage []byte
+	if failureReason == lnrpc.PaymentFailureReason_FAILURE_REASON_NONE {
+		fakeNodesMu.RLock()
+		destination, exists := fakeNodes[decoded.Destination]
+		fakeNodesMu.RUnlock()
+		if !exists {
+			failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_INCORRECT_PAYMENT_DETAILS
+		} else {
+			destination.mu.Lock()
+			if destination != n {
+				destination.addPeer(n.publicKey, n.alias)
+			}
+			preimage, err = destination.settleInvoice(paymentHash, amountMsat)
+			destination.mu.Unlock()
+			if err != nil {
+				failureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_INCORRECT_PAYMENT_DETAILS
+			}
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	resolved := time.Now()
+	if failureReason != lnrpc.PaymentFailureReason_FAILURE_REASON_NONE {
+		payment.Status = lnrpc.Payment_FAILED
+		payment.FailureReason = failureReason
+	} else {
+		channel.LocalBalance -= (amountMsat + feeMsat) / 1000
+		channel.RemoteBalance += (amountMsat + feeMsat) / 1000
+		channel.TotalSatoshisSent += (amountMsat + feeMsat) / 1000
+		channel.NumUpdates++
+		payment.Status = lnrpc.Payment_SUCCEEDED
+		payment.PaymentPreimage = hex.EncodeToString(preimage)
+		payment.Fee = feeMsat / 1000
+		payment.FeeSat = feeMsat / 1000
+		payment.FeeMsat = feeMsat
+		payment.Htlcs = []*lnrpc.HTLCAttempt{{
+			AttemptId:     payment.PaymentIndex,
+			Status:        lnrpc.HTLCAttempt_SUCCEEDED,
+			AttemptTimeNs: now.UnixNano(),
+			ResolveTimeNs: resolved.UnixNano(),
+			Preimage:      preimage,
+			Route: &lnrpc.Route{
+				TotalTimeLock: n.blockHeight + fakeTimeLockDelta,
+				TotalFeesMsat: feeMsat,
+				TotalAmtMsat:  amountMsat + feeMsat,
+				Hops: []*lnrpc.Hop{{ChanId: channel.ChanId, ChanCapacity: channel.Capacity,
+					AmtToForwardMsat: amountMsat, FeeMsat: feeMsat, Expiry: n.blockHeight + fakeTimeLockDelta,
+					PubKey: decoded.Destination}},
+			},
+		}}
+
+		if lastHop != nil {
+			lastHop.LocalBalance += amountMsat / 1000
+			lastHop.RemoteBalance -= amountMsat / 1000
+			lastHop.TotalSatoshisReceived += amountMsat / 1000
+			lastHop.NumUpdates++
+			route := payment.Htlcs[0].Route
+			route.Hops[0].PubKey = channel.RemotePubkey
+			route.Hops = append(route.Hops, &lnrpc.Hop{ChanId: lastHop.ChanId, ChanCapacity: lastHop.Capacity,
+				AmtToForwardMsat: amountMsat, Expiry: n.blockHeight + fakeTimeLockDelta, PubKey: n.publicKey})
+		}
+
+		n.nextHtlcId++
+		n.publish(fakeHtlcEvents, &routerrpc.HtlcEvent{
+			OutgoingChannelId: channel.ChanId,
+			OutgoingHtlcId:    n.nextHtlcId,
+			TimestampNs:       uint64(resolved.UnixNano()),
+			EventType:         routerrpc.HtlcEvent_SEND,
+			Event: &routerrpc.HtlcEvent_SettleEvent{SettleEvent: &routerrpc.SettleEvent{
+				Preimage: preimage}},
+		})
+	}
+	final := proto.Clone(payment).(*lnrpc.Payment)
+	return &fakePaymentStream{newFakeStream(ctx, inFlight, final)}, nil
+}
+
+func (n *FakeNode) GetTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (*lnrpc.TransactionDetails, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return proto.Clone(&lnrpc.TransactionDetails{Transactions: n.transactions}).(*lnrpc.TransactionDetails), nil
+}
+
+func (n *FakeNode) SendCoins(ctx context.Context, in *lnrpc.SendCoinsRequest,
+	opts ...grpc.CallOption) (*lnrpc.SendCoinsResponse, error) {
+
+	if _, err := btcutil.DecodeAddress(in.Addr, n.params); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid address: %v", err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	amount := in.Amount
+	if in.SendAll {
+		amount = n.confirmedBalance
+	}
+	if amount > n.confirmedBalance {
+		return nil, status.Error(codes.Unknown, "insufficient funds available to construct transaction")
+	}
+	n.confirmedBalance -= amount
+	txid := n.fundingTransaction(amount)
+	return &lnrpc.SendCoinsResponse{Txid: txid.String()}, nil
+}
+
+func (n *FakeNode) NextAddr(ctx context.Context, in *walletrpc.AddrRequest,
+	opts ...grpc.CallOption) (*walletrpc.AddrResponse, error) {
+
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating key")
+	}
+	publicKey := privateKey.PubKey().SerializeCompressed()
+	var address btcutil.Address
+	switch in.Type {
+	case walletrpc.AddressType_TAPROOT_PUBKEY:
+		address, err = btcutil.NewAddressTaproot(publicKey[1:], n.params)
+	default:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(publicKey), n.params)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating address")
+	}
+	return &walletrpc.AddrResponse{Addr: address.EncodeAddress()}, nil
+}
+
+func (n *FakeNode) SignMessage(ctx context.Context, in *lnrpc.SignMessageRequest,
+	opts ...grpc.CallOption) (*lnrpc.SignMessageResponse, error) {
+
+	signature, err := ecdsa.SignCompact(n.privateKey, messageHash(in.Msg, in.SingleHash), true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Signing message")
+	}
+	return &lnrpc.SignMessageResponse{Signature: hex.EncodeToString(signature)}, nil
+}
+
+// VerifyMessage recovers the public key of the signer, the signature is valid when the signer is a known node.
+func (n *FakeNode) VerifyMessage(ctx context.Context, in *lnrpc.VerifyMessageRequest,
+	opts ...grpc.CallOption) (*lnrpc.VerifyMessageResponse, error) {
+
+	signature, err := hex.DecodeString(in.Signature)
+	if err != nil {
+		return &lnrpc.VerifyMessageResponse{}, nil
+	}
+	publicKey, _, err := ecdsa.RecoverCompact(signature, messageHash(in.Msg, false))
+	if err != nil {
+		return &lnrpc.VerifyMessageResponse{}, nil
+	}
+	pubkey := hex.EncodeToString(publicKey.SerializeCompressed())
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, known := n.nodes[pubkey]
+	return &lnrpc.VerifyMessageResponse{Valid: known, Pubkey: pubkey}, nil
+}
+
+func messageHash(msg []byte, singleHash bool) []byte {
+	if singleHash {
+		hash := sha256.Sum256(append([]byte(fakeSignedMessagePrefix), msg...))
+		return hash[:]
+	}
+	return chainhash.DoubleHashB(append([]byte(fakeSignedMessagePrefix), msg...))
+}
+
+// ExportAllChannelBackups returns a backup of the open channels, the backup is the list of channel points prefixed by
+// the public key of the node instead of an encrypted static channel backup.
+func (n *FakeNode) ExportAllChannelBackups(ctx context.Context, in *lnrpc.ChanBackupExportRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChanBackupSnapshot, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.channelBackups(), nil
+}
+
+func (n *FakeNode) channelBackups() *lnrpc.ChanBackupSnapshot {
+	multi := &lnrpc.MultiChanBackup{MultiChanBackup: []byte(n.publicKey)}
+	singles := &lnrpc.ChannelBackups{}
+	for _, channel := range n.channels {
+		chanPoint, err := channelPoint(channel.ChannelPoint)
+		if err != nil {
+			continue
+		}
+		multi.ChanPoints = append(multi.ChanPoints, chanPoint)
+		multi.MultiChanBackup = append(multi.MultiChanBackup, []byte(","+channel.ChannelPoint)...)
+		singles.ChanBackups = append(singles.ChanBackups, &lnrpc.ChannelBackup{ChanPoint: chanPoint,
+			ChanBackup: []byte(n.publicKey + "," + channel.ChannelPoint)})
+	}
+	return &lnrpc.ChanBackupSnapshot{SingleChanBackups: singles, MultiChanBackup: multi}
+}
+
+// VerifyChanBackup accepts the multi channel backups made by this node.
+func (n *FakeNode) VerifyChanBackup(ctx context.Context, in *lnrpc.ChanBackupSnapshot,
+	opts ...grpc.CallOption) (*lnrpc.VerifyChanBackupResponse, error) {
+
+	if in.MultiChanBackup == nil || !bytes.HasPrefix(in.MultiChanBackup.MultiChanBackup, []byte(n.publicKey)) {
+		return nil, status.Error(codes.Unknown, "unable to unpack multi backup: message authentication failed")
+	}
+	return &lnrpc.VerifyChanBackupResponse{}, nil
+}
+
+// RestoreChannelBackups accepts the multi channel backups made by this node, nothing is restored.
+func (n *FakeNode) RestoreChannelBackups(ctx context.Context, in *lnrpc.RestoreChanBackupRequest,
+	opts ...grpc.CallOption) (*lnrpc.RestoreBackupResponse, error) {
+
+	if !bytes.HasPrefix(in.GetMultiChanBackup(), []byte(n.publicKey)) {
+		return nil, status.Error(codes.Unknown, "unable to unpack chan backups: message authentication failed")
+	}
+	return &lnrpc.RestoreBackupResponse{}, nil
+}
+
+func (n *FakeNode) SubscribeChannelEvents(ctx context.Context, in *lnrpc.ChannelEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelEventsClient, error) {
+
+	return &fakeChannelEventStream{n.subscribe(ctx, fakeChannelEvents)}, nil
+}
+
+func (n *FakeNode) SubscribeChannelGraph(ctx context.Context, in *lnrpc.GraphTopologySubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelGraphClient, error) {
+
+	return &fakeChannelGraphStream{n.subscribe(ctx, fakeChannelGraph)}, nil
+}
+
+// SubscribeInvoices sends the invoices added or settled after the indexes first like LND does.
+func (n *FakeNode) SubscribeInvoices(ctx context.Context, in *lnrpc.InvoiceSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeInvoicesClient, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var backlog []interface{}
+	if in.AddIndex != 0 || in.SettleIndex != 0 {
+		invoices := append([]*lnrpc.Invoice{}, n.invoices...)
+		sort.Slice(invoices, func(i, j int) bool { return invoices[i].AddIndex < invoices[j].AddIndex })
+		for _, invoice := range invoices {
+			if invoice.AddIndex > in.AddIndex || (invoice.SettleIndex != 0 && invoice.SettleIndex > in.SettleIndex) {
+				backlog = append(backlog, proto.Clone(invoice))
+			}
+		}
+	}
+	stream := n.subscribeLocked(ctx, fakeInvoices)
+	stream.queue = append(backlog, stream.queue...)
+	return &fakeInvoiceStream{stream}, nil
+}
+
+func (n *FakeNode) SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error) {
+
+	return &fakePeerEventStream{n.subscribe(ctx, fakePeerEvents)}, nil
+}
+
+func (n *FakeNode) SubscribeTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeTransactionsClient, error) {
+
+	return &fakeTransactionStream{n.subscribe(ctx, fakeTransactions)}, nil
+}
+
+func (n *FakeNode) SubscribeHtlcEvents(ctx context.Context, in *routerrpc.SubscribeHtlcEventsRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SubscribeHtlcEventsClient, error) {
+
+	return &fakeHtlcEventStream{n.subscribe(ctx, fakeHtlcEvents)}, nil
+}
+
+func (n *FakeNode) SubscribeChannelBackups(ctx context.Context, in *lnrpc.ChannelBackupSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelBackupsClient, error) {
+
+	return &fakeChannelBackupStream{n.subscribe(ctx, fakeChannelBackups)}, nil
+}
+
+// RegisterBlockEpochNtfn sends the best block first like LND does.
+func (n *FakeNode) RegisterBlockEpochNtfn(ctx context.Context, in *chainrpc.BlockEpoch,
+	opts ...grpc.CallOption) (chainrpc.ChainNotifier_RegisterBlockEpochNtfnClient, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	stream := n.subscribeLocked(ctx, fakeBlocks)
+	stream.send(n.blockEpoch(n.blockHeight))
+	return &fakeBlockEpochStream{stream}, nil
+}
+
+// blockHash is derived from the node and the height so a block is the same on every call.
+func (n *FakeNode) blockHash(height uint32) chainhash.Hash {
+	return sha256.Sum256([]byte(fmt.Sprintf("%v:%v", n.publicKey, height)))
+}
+
+func (n *FakeNode) blockEpoch(height uint32) *chainrpc.BlockEpoch {
+	hash := n.blockHash(height)
+	return &chainrpc.BlockEpoch{Hash: hash[:], Height: height}
+}
+
+func (n *FakeNode) subscribe(ctx context.Context, kind string) *fakeStream {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.subscribeLocked(ctx, kind)
+}
+
+func (n *FakeNode) subscribeLocked(ctx context.Context, kind string) *fakeStream {
+	stream := newFakeStream(ctx)
+	stream.closed = false
+	n.subscriptions[kind] = append(n.subscriptions[kind], stream)
+	return stream
+}
+
+// publish sends a copy of the update to the subscriptions of the kind and drops the subscriptions that ended.
+func (n *FakeNode) publish(kind string, update proto.Message) {
+	var subscriptions []*fakeStream
+	for _, stream := range n.subscriptions[kind] {
+		if stream.ctx.Err() != nil {
+			continue
+		}
+		stream.send(proto.Clone(update))
+		subscriptions = append(subscriptions, stream)
+	}
+	n.subscriptions[kind] = subscriptions
+}
+
+func channelPoint(chanPoint string) (*lnrpc.ChannelPoint, error) {
+	if len(chanPoint) < 66 || chanPoint[64] != ':' {
+		return nil, errors.Newf("Invalid channel point %v", chanPoint)
+	}
+	txid, err := chainhash.NewHashFromStr(chanPoint[:64])
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing channel point")
+	}
+	var outputIndex uint32
+	for _, digit := range chanPoint[65:] {
+		if digit < '0' || digit > '9' {
+			return nil, errors.Newf("Invalid channel point %v", chanPoint)
+		}
+		outputIndex = outputIndex*10 + uint32(digit-'0')
+	}
+	return &lnrpc.ChannelPoint{FundingTxid: &lnrpc.ChannelPoint_FundingTxidBytes{FundingTxidBytes: txid[:]},
+		OutputIndex: outputIndex}, nil
+}
+
+// fundingTxidBytes returns the funding transaction id of the channel point as bytes in the internal order.
+func fundingTxidBytes(chanPoint *lnrpc.ChannelPoint) []byte {
+	if chanPoint == nil {
+		return nil
+	}
+	if txidStr := chanPoint.GetFundingTxidStr(); txidStr != "" {
+		txid, err := chainhash.NewHashFromStr(txidStr)
+		if err != nil {
+			return nil
+		}
+		return txid[:]
+	}
+	return chanPoint.GetFundingTxidBytes()
+}
+
+func itoa(i uint64) string {
+	if i == 0 {
+		return "0"
+	}
+	var digits []byte
+	for ; i > 0; i /= 10 {
+		digits = append([]byte{byte('0' + i%10)}, digits...)
+	}
+	return string(digits)
+}
@@ -0,0 +1,209 @@
+package node_client
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	fakeChannelEvents  = "channelEvents"
+	fakeChannelGraph   = "channelGraph"
+	fakeInvoices       = "invoices"
+	fakePeerEvents     = "peerEvents"
+	fakeTransactions   = "transactions"
+	fakeHtlcEvents     = "htlcEvents"
+	fakeChannelBackups = "channelBackups"
+	fakeBlocks         = "blocks"
+)
+
+// fakeStream is an unbounded queue of updates. Streams created with updates are closed and end with io.EOF once the
+// updates are received, subscriptions stay open until their context is done.
+type fakeStream struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	queue  []interface{}
+	notify chan struct{}
+	closed bool
+}
+
+func newFakeStream(ctx context.Context, updates ...interface{}) *fakeStream {
+	return &fakeStream{
+		ctx:    ctx,
+		queue:  updates,
+		notify: make(chan struct{}, 1),
+		closed: true,
+	}
+}
+
+func (s *fakeStream) send(update interface{}) {
+	s.mu.Lock()
+	s.queue = append(s.queue, update)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *fakeStream) recv() (interface{}, error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) != 0 {
+			update := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			return update, nil
+		}
+		closed := s.closed
+		s.mu.Unlock()
+		if closed {
+			return nil, io.EOF
+		}
+		select {
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		case <-s.notify:
+		}
+	}
+}
+
+func (s *fakeStream) Header() (metadata.MD, error) {
+	return metadata.MD{}, nil
+}
+
+func (s *fakeStream) Trailer() metadata.MD {
+	return metadata.MD{}
+}
+
+func (s *fakeStream) CloseSend() error {
+	return nil
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	_, err := s.recv()
+	return err
+}
+
+type fakeChannelEventStream struct{ *fakeStream }
+
+func (s *fakeChannelEventStream) Recv() (*lnrpc.ChannelEventUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.ChannelEventUpdate), nil
+}
+
+type fakeChannelGraphStream struct{ *fakeStream }
+
+func (s *fakeChannelGraphStream) Recv() (*lnrpc.GraphTopologyUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.GraphTopologyUpdate), nil
+}
+
+type fakeInvoiceStream struct{ *fakeStream }
+
+func (s *fakeInvoiceStream) Recv() (*lnrpc.Invoice, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.Invoice), nil
+}
+
+type fakePeerEventStream struct{ *fakeStream }
+
+func (s *fakePeerEventStream) Recv() (*lnrpc.PeerEvent, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.PeerEvent), nil
+}
+
+type fakeTransactionStream struct{ *fakeStream }
+
+func (s *fakeTransactionStream) Recv() (*lnrpc.Transaction, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.Transaction), nil
+}
+
+type fakeHtlcEventStream struct{ *fakeStream }
+
+func (s *fakeHtlcEventStream) Recv() (*routerrpc.HtlcEvent, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*routerrpc.HtlcEvent), nil
+}
+
+type fakeOpenChannelStream struct{ *fakeStream }
+
+func (s *fakeOpenChannelStream) Recv() (*lnrpc.OpenStatusUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.OpenStatusUpdate), nil
+}
+
+type fakeCloseChannelStream struct{ *fakeStream }
+
+func (s *fakeCloseChannelStream) Recv() (*lnrpc.CloseStatusUpdate, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.CloseStatusUpdate), nil
+}
+
+type fakePaymentStream struct{ *fakeStream }
+
+func (s *fakePaymentStream) Recv() (*lnrpc.Payment, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.Payment), nil
+}
+
+type fakeChannelBackupStream struct{ *fakeStream }
+
+func (s *fakeChannelBackupStream) Recv() (*lnrpc.ChanBackupSnapshot, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*lnrpc.ChanBackupSnapshot), nil
+}
+
+type fakeBlockEpochStream struct{ *fakeStream }
+
+func (s *fakeBlockEpochStream) Recv() (*chainrpc.BlockEpoch, error) {
+	update, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return update.(*chainrpc.BlockEpoch), nil
+}
@@ -0,0 +1,296 @@
+package node_client
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFakeNodeInvoice(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	node := NewFakeNode("alice")
+
+	invoices, err := node.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribeInvoices() error: %v", err)
+	}
+	added, err := node.AddInvoice(ctx, &lnrpc.Invoice{Memo: "coffee", Value: 1500})
+	if err != nil {
+		t.Fatalf("AddInvoice() error: %v", err)
+	}
+	decoded, err := node.DecodePayReq(ctx, &lnrpc.PayReqString{PayReq: added.PaymentRequest})
+	if err != nil {
+		t.Fatalf("DecodePayReq() error: %v", err)
+	}
+	if decoded.Destination != node.PublicKey() || decoded.NumSatoshis != 1500 || decoded.Description != "coffee" {
+		t.Errorf("DecodePayReq()\nGot:\n%v\nWant:\n%v\n", decoded, "alice, 1500 sat, coffee")
+	}
+
+	node.AddChannel(NewFakeNode("bob").PublicKey(), 1000000, 500000)
+	if err := node.PayInvoice(added.PaymentRequest, 0); err != nil {
+		t.Fatalf("PayInvoice() error: %v", err)
+	}
+	for _, want := range []lnrpc.Invoice_InvoiceState{lnrpc.Invoice_OPEN, lnrpc.Invoice_SETTLED} {
+		invoice, err := invoices.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		if invoice.State != want {
+			t.Errorf("Recv() state\nGot:\n%v\nWant:\n%v\n", invoice.State, want)
+		}
+	}
+
+	// Resuming from the add index only sends the invoices that were added or settled after it.
+	replay, err := node.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{AddIndex: 1})
+	if err != nil {
+		t.Fatalf("SubscribeInvoices() error: %v", err)
+	}
+	invoice, err := replay.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error: %v", err)
+	}
+	if invoice.SettleIndex != 1 {
+		t.Errorf("Recv() settle index\nGot:\n%v\nWant:\n%v\n", invoice.SettleIndex, 1)
+	}
+}
+
+func TestFakeNodePayment(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	alice := NewFakeNode("alice")
+	bob := NewFakeNode("bob")
+	carol := NewFakeNode("carol")
+	channel := alice.AddChannel(bob.PublicKey(), 1000000, 600000)
+	bob.AddChannel(alice.PublicKey(), 1000000, 400000)
+	carol.AddChannel(bob.PublicKey(), 1000000, 0)
+
+	tests := []struct {
+		name       string
+		payee      *FakeNode
+		amountSat  int64
+		wantStatus lnrpc.Payment_PaymentStatus
+		wantReason lnrpc.PaymentFailureReason
+		wantFee    int64
+	}{
+		{"Direct channel", bob, 1000, lnrpc.Payment_SUCCEEDED, lnrpc.PaymentFailureReason_FAILURE_REASON_NONE, 0},
+		{"Routed payment", carol, 1000, lnrpc.Payment_SUCCEEDED, lnrpc.PaymentFailureReason_FAILURE_REASON_NONE,
+			fakeFeeBaseMsat},
+		{"Insufficient balance", bob, 700000, lnrpc.Payment_FAILED,
+			lnrpc.PaymentFailureReason_FAILURE_REASON_INSUFFICIENT_BALANCE, 0},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			invoice, err := test.payee.AddInvoice(ctx, &lnrpc.Invoice{Value: test.amountSat})
+			if err != nil {
+				t.Fatalf("AddInvoice() error: %v", err)
+			}
+			stream, err := alice.SendPaymentV2(ctx, &routerrpc.SendPaymentRequest{PaymentRequest: invoice.PaymentRequest})
+			if err != nil {
+				t.Fatalf("SendPaymentV2() error: %v", err)
+			}
+			var payment *lnrpc.Payment
+			for {
+				update, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Recv() error: %v", err)
+				}
+				payment = update
+			}
+			if payment.Status != test.wantStatus || payment.FailureReason != test.wantReason ||
+				payment.FeeMsat != test.wantFee {
+				t.Errorf("%d: SendPaymentV2()\nGot:\n%v\nWant:\n%v %v %v\n", i, payment, test.wantStatus,
+					test.wantReason, test.wantFee)
+			}
+		})
+	}
+
+	if channel.LocalBalance != 600000-1000-1001 {
+		t.Errorf("SendPaymentV2() local balance\nGot:\n%v\nWant:\n%v\n", channel.LocalBalance, 600000-1000-1001)
+	}
+	resp, err := alice.ListPayments(ctx, &lnrpc.ListPaymentsRequest{IncludeIncomplete: true})
+	if err != nil {
+		t.Fatalf("ListPayments() error: %v", err)
+	}
+	if len(resp.Payments) != 3 {
+		t.Errorf("ListPayments()\nGot:\n%v\nWant:\n%v\n", len(resp.Payments), 3)
+	}
+
+	// Paying a settled invoice again is refused before anything is sent.
+	_, err = alice.SendPaymentV2(ctx, &routerrpc.SendPaymentRequest{PaymentRequest: resp.Payments[0].PaymentRequest})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("SendPaymentV2() paid invoice\nGot:\n%v\nWant:\n%v\n", err, codes.AlreadyExists)
+	}
+}
+
+func TestFakeNodeForward(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	node := NewFakeNode("router")
+	incoming := node.AddChannel(NewFakeNode("in").PublicKey(), 1000000, 0)
+	outgoing := node.AddChannel(NewFakeNode("out").PublicKey(), 1000000, 1000000)
+
+	htlcEvents, err := node.SubscribeHtlcEvents(ctx, &routerrpc.SubscribeHtlcEventsRequest{})
+	if err != nil {
+		t.Fatalf("SubscribeHtlcEvents() error: %v", err)
+	}
+	if err := node.Forward(incoming.ChanId, outgoing.ChanId, 100000000, 10000); err != nil {
+		t.Fatalf("Forward() error: %v", err)
+	}
+	for _, want := range []string{"forward", "settle"} {
+		event, err := htlcEvents.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		got := "settle"
+		if event.GetForwardEvent() != nil {
+			got = "forward"
+		}
+		if got != want || event.IncomingChannelId != incoming.ChanId || event.OutgoingChannelId != outgoing.ChanId {
+			t.Errorf("Recv()\nGot:\n%v\nWant:\n%v\n", event, want)
+		}
+	}
+
+	history, err := node.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{})
+	if err != nil {
+		t.Fatalf("ForwardingHistory() error: %v", err)
+	}
+	if len(history.ForwardingEvents) != 1 || history.ForwardingEvents[0].FeeMsat != 10000 {
+		t.Errorf("ForwardingHistory()\nGot:\n%v\nWant:\n%v\n", history.ForwardingEvents, "one forward with 10000 msat fee")
+	}
+	if incoming.LocalBalance != 100010 || outgoing.LocalBalance != 900000 {
+		t.Errorf("Forward() balances\nGot:\n%v %v\nWant:\n%v %v\n", incoming.LocalBalance, outgoing.LocalBalance,
+			100010, 900000)
+	}
+}
+
+func TestFakeNodeOpenAndCloseChannel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	node := NewFakeNode("alice")
+	peer := NewFakeNode("bob")
+
+	channelEvents, err := node.SubscribeChannelEvents(ctx, &lnrpc.ChannelEventSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribeChannelEvents() error: %v", err)
+	}
+	pubkey := []byte(peer.PublicKey())
+	if _, err := node.OpenChannel(ctx, &lnrpc.OpenChannelRequest{NodePubkey: pubkey}); status.Code(err) !=
+		codes.Unavailable {
+		t.Errorf("OpenChannel() without peer\nGot:\n%v\nWant:\n%v\n", err, codes.Unavailable)
+	}
+	node.AddPeer(peer.PublicKey(), "bob")
+	pubkey, err = hex.DecodeString(peer.PublicKey())
+	if err != nil {
+		t.Fatalf("DecodeString() error: %v", err)
+	}
+	stream, err := node.OpenChannel(ctx, &lnrpc.OpenChannelRequest{NodePubkey: pubkey, LocalFundingAmount: 500000})
+	if err != nil {
+		t.Fatalf("OpenChannel() error: %v", err)
+	}
+	update, err := stream.Recv()
+	if err != nil || update.GetChanPending() == nil {
+		t.Fatalf("OpenChannel() update\nGot:\n%v %v\nWant:\n%v\n", update, err, "pending channel")
+	}
+	node.MineBlocks(fakeConfirmations)
+
+	for _, want := range []lnrpc.ChannelEventUpdate_UpdateType{lnrpc.ChannelEventUpdate_PENDING_OPEN_CHANNEL,
+		lnrpc.ChannelEventUpdate_OPEN_CHANNEL, lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL} {
+		event, err := channelEvents.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		if event.Type != want {
+			t.Errorf("Recv() type\nGot:\n%v\nWant:\n%v\n", event.Type, want)
+		}
+	}
+
+	chanPoint := &lnrpc.ChannelPoint{
+		FundingTxid: &lnrpc.ChannelPoint_FundingTxidBytes{FundingTxidBytes: update.GetChanPending().Txid}}
+	closeStream, err := node.CloseChannel(ctx, &lnrpc.CloseChannelRequest{ChannelPoint: chanPoint})
+	if err != nil {
+		t.Fatalf("CloseChannel() error: %v", err)
+	}
+	for {
+		_, err := closeStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+	}
+	closed, err := node.ClosedChannels(ctx, &lnrpc.ClosedChannelsRequest{})
+	if err != nil {
+		t.Fatalf("ClosedChannels() error: %v", err)
+	}
+	if len(closed.Channels) != 1 || closed.Channels[0].SettledBalance != 500000 {
+		t.Errorf("ClosedChannels()\nGot:\n%v\nWant:\n%v\n", closed.Channels, "one channel settling 500000 sat")
+	}
+}
+
+func TestFakeNodeSignMessage(t *testing.T) {
+	ctx := context.Background()
+	alice := NewFakeNode("alice")
+	bob := NewFakeNode("bob")
+	signed, err := alice.SignMessage(ctx, &lnrpc.SignMessageRequest{Msg: []byte("hello")})
+	if err != nil {
+		t.Fatalf("SignMessage() error: %v", err)
+	}
+
+	verified, err := bob.VerifyMessage(ctx, &lnrpc.VerifyMessageRequest{Msg: []byte("hello"), Signature: signed.Signature})
+	if err != nil {
+		t.Fatalf("VerifyMessage() error: %v", err)
+	}
+	if verified.Valid || verified.Pubkey != alice.PublicKey() {
+		t.Errorf("VerifyMessage() unknown node\nGot:\n%v\nWant:\n%v\n", verified, "invalid, alice")
+	}
+	bob.AddPeer(alice.PublicKey(), "alice")
+	verified, err = bob.VerifyMessage(ctx, &lnrpc.VerifyMessageRequest{Msg: []byte("hello"), Signature: signed.Signature})
+	if err != nil {
+		t.Fatalf("VerifyMessage() error: %v", err)
+	}
+	if !verified.Valid {
+		t.Errorf("VerifyMessage() known node\nGot:\n%v\nWant:\n%v\n", verified.Valid, true)
+	}
+}
+
+func TestFakeNodeBlockEpochs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	node := NewFakeNode("alice")
+
+	epochs, err := node.RegisterBlockEpochNtfn(ctx, &chainrpc.BlockEpoch{})
+	if err != nil {
+		t.Fatalf("RegisterBlockEpochNtfn() error: %v", err)
+	}
+	node.MineBlocks(2)
+	for _, want := range []uint32{fakeStartHeight, fakeStartHeight + 1, fakeStartHeight + 2} {
+		epoch, err := epochs.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		if epoch.Height != want {
+			t.Errorf("Recv() height\nGot:\n%v\nWant:\n%v\n", epoch.Height, want)
+		}
+	}
+
+	info, err := node.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		t.Fatalf("GetInfo() error: %v", err)
+	}
+	if hash := node.blockHash(fakeStartHeight + 2); info.BlockHash != hash.String() {
+		t.Errorf("GetInfo() block hash\nGot:\n%v\nWant:\n%v\n", info.BlockHash, hash.String())
+	}
+}
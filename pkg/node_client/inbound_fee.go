@@ -0,0 +1,144 @@
+package node_client
+
+import (
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// LND supports inbound fees from v0.18 on, the lnrpc version Torq is built with predates them. The fields are read
+// from and written to the unknown fields of the messages, which are kept when messages are (un)marshalled.
+const (
+	routingPolicyInboundFeeBaseMsat      = protowire.Number(9)
+	routingPolicyInboundFeeRateMilliMsat = protowire.Number(10)
+	policyUpdateRequestInboundFee        = protowire.Number(10)
+	inboundFeeBaseFeeMsat                = protowire.Number(1)
+	inboundFeeFeeRatePpm                 = protowire.Number(2)
+)
+
+// InboundFee is charged on top of the outbound fee of the outgoing channel by the incoming channel of a forward,
+// a negative inbound fee is a discount.
+type InboundFee struct {
+	BaseFeeMsat int32 `json:"baseFeeMsat"`
+	FeeRatePpm  int32 `json:"feeRatePpm"`
+}
+
+// GetInboundFee returns the inbound fee of the policy, it's zero for nodes that don't announce inbound fees.
+func GetInboundFee(policy *lnrpc.RoutingPolicy) InboundFee {
+	if policy == nil {
+		return InboundFee{}
+	}
+	fields := int32Fields(policy.ProtoReflect().GetUnknown())
+	return InboundFee{
+		BaseFeeMsat: fields[routingPolicyInboundFeeBaseMsat],
+		FeeRatePpm:  fields[routingPolicyInboundFeeRateMilliMsat],
+	}
+}
+
+// SetInboundFee sets the inbound fee of the policy.
+func SetInboundFee(policy *lnrpc.RoutingPolicy, inboundFee InboundFee) {
+	message := policy.ProtoReflect()
+	unknown := withoutFields(message.GetUnknown(),
+		routingPolicyInboundFeeBaseMsat, routingPolicyInboundFeeRateMilliMsat)
+	unknown = appendInt32Field(unknown, routingPolicyInboundFeeBaseMsat, inboundFee.BaseFeeMsat)
+	unknown = appendInt32Field(unknown, routingPolicyInboundFeeRateMilliMsat, inboundFee.FeeRatePpm)
+	message.SetUnknown(unknown)
+}
+
+// GetPolicyUpdateInboundFee returns the inbound fee of the request, it's nil when the request keeps the inbound fee.
+func GetPolicyUpdateInboundFee(request *lnrpc.PolicyUpdateRequest) *InboundFee {
+	unknown := request.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		number, wireType, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return nil
+		}
+		unknown = unknown[n:]
+		if number == policyUpdateRequestInboundFee && wireType == protowire.BytesType {
+			value, m := protowire.ConsumeBytes(unknown)
+			if m < 0 {
+				return nil
+			}
+			fields := int32Fields(value)
+			return &InboundFee{BaseFeeMsat: fields[inboundFeeBaseFeeMsat], FeeRatePpm: fields[inboundFeeFeeRatePpm]}
+		}
+		m := protowire.ConsumeFieldValue(number, wireType, unknown)
+		if m < 0 {
+			return nil
+		}
+		unknown = unknown[m:]
+	}
+	return nil
+}
+
+// SetPolicyUpdateInboundFee makes the request update the inbound fee, nodes before LND v0.18 ignore it.
+func SetPolicyUpdateInboundFee(request *lnrpc.PolicyUpdateRequest, inboundFee InboundFee) {
+	message := request.ProtoReflect()
+	var value []byte
+	value = appendInt32Field(value, inboundFeeBaseFeeMsat, inboundFee.BaseFeeMsat)
+	value = appendInt32Field(value, inboundFeeFeeRatePpm, inboundFee.FeeRatePpm)
+	unknown := withoutFields(message.GetUnknown(), policyUpdateRequestInboundFee)
+	unknown = protowire.AppendTag(unknown, policyUpdateRequestInboundFee, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, value)
+	message.SetUnknown(unknown)
+}
+
+// int32Fields returns the varint fields of the encoded message as int32 values.
+func int32Fields(encoded []byte) map[protowire.Number]int32 {
+	fields := make(map[protowire.Number]int32)
+	for len(encoded) > 0 {
+		number, wireType, n := protowire.ConsumeTag(encoded)
+		if n < 0 {
+			return fields
+		}
+		encoded = encoded[n:]
+		if wireType == protowire.VarintType {
+			value, m := protowire.ConsumeVarint(encoded)
+			if m < 0 {
+				return fields
+			}
+			fields[number] = int32(value)
+			encoded = encoded[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(number, wireType, encoded)
+		if m < 0 {
+			return fields
+		}
+		encoded = encoded[m:]
+	}
+	return fields
+}
+
+// appendInt32Field appends the value like proto3 does, zero values are left out and negative values are sign
+// extended.
+func appendInt32Field(encoded []byte, number protowire.Number, value int32) []byte {
+	if value == 0 {
+		return encoded
+	}
+	encoded = protowire.AppendTag(encoded, number, protowire.VarintType)
+	return protowire.AppendVarint(encoded, uint64(int64(value)))
+}
+
+func withoutFields(encoded protoreflect.RawFields, numbers ...protowire.Number) protoreflect.RawFields {
+	var kept protoreflect.RawFields
+	for len(encoded) > 0 {
+		number, wireType, n := protowire.ConsumeTag(encoded)
+		if n < 0 {
+			return kept
+		}
+		m := protowire.ConsumeFieldValue(number, wireType, encoded[n:])
+		if m < 0 {
+			return kept
+		}
+		removed := false
+		for _, removedNumber := range numbers {
+			removed = removed || number == removedNumber
+		}
+		if !removed {
+			kept = append(kept, encoded[:n+m]...)
+		}
+		encoded = encoded[n+m:]
+	}
+	return kept
+}
@@ -0,0 +1,85 @@
+package node_client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInboundFeeWireFormat(t *testing.T) {
+	policy := &lnrpc.RoutingPolicy{FeeBaseMsat: 1000, FeeRateMilliMsat: 500}
+	SetInboundFee(policy, InboundFee{BaseFeeMsat: -100, FeeRatePpm: -25})
+	SetInboundFee(policy, InboundFee{BaseFeeMsat: -200, FeeRatePpm: -50})
+	encoded, err := proto.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fields as LND v0.18 encodes inbound_fee_base_msat and inbound_fee_rate_milli_msat.
+	if fields := int32Fields(encoded); fields[9] != -200 || fields[10] != -50 || len(fields) != 4 {
+		t.Errorf("SetInboundFee() fields\nGot:\n%v\nWant:\n%v\n", fields, "9: -200, 10: -50 and the outbound fees")
+	}
+	decoded := &lnrpc.RoutingPolicy{}
+	if err := proto.Unmarshal(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if inboundFee := GetInboundFee(decoded); inboundFee != (InboundFee{BaseFeeMsat: -200, FeeRatePpm: -50}) {
+		t.Errorf("GetInboundFee()\nGot:\n%v\nWant:\n%v\n", inboundFee, InboundFee{BaseFeeMsat: -200, FeeRatePpm: -50})
+	}
+
+	request := &lnrpc.PolicyUpdateRequest{BaseFeeMsat: 1000}
+	if GetPolicyUpdateInboundFee(request) != nil {
+		t.Errorf("GetPolicyUpdateInboundFee() unset\nGot:\n%v\nWant:\n%v\n", GetPolicyUpdateInboundFee(request), nil)
+	}
+	SetPolicyUpdateInboundFee(request, InboundFee{FeeRatePpm: -25})
+	encoded, err = proto.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(encoded) > 0 {
+		number, wireType, n := protowire.ConsumeTag(encoded)
+		m := protowire.ConsumeFieldValue(number, wireType, encoded[n:])
+		if number == 10 {
+			value, _ := protowire.ConsumeBytes(encoded[n:])
+			if fields := int32Fields(value); wireType != protowire.BytesType || fields[2] != -25 || len(fields) != 1 {
+				t.Errorf("SetPolicyUpdateInboundFee() inbound_fee\nGot:\n%v\nWant:\n%v\n", fields, "2: -25")
+			}
+		}
+		encoded = encoded[n+m:]
+	}
+}
+
+func TestFakeNodeInboundFee(t *testing.T) {
+	ctx := context.Background()
+	alice := NewFakeNode("alice")
+	channel := alice.AddChannel(NewFakeNode("bob").PublicKey(), 1000000, 500000)
+
+	request := &lnrpc.PolicyUpdateRequest{Scope: &lnrpc.PolicyUpdateRequest_Global{Global: true},
+		BaseFeeMsat: 1000, FeeRatePpm: 100, TimeLockDelta: 40}
+	SetPolicyUpdateInboundFee(request, InboundFee{BaseFeeMsat: -500, FeeRatePpm: -20})
+	if _, err := alice.UpdateChannelPolicy(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+	// Updates without an inbound fee keep the inbound fee.
+	if _, err := alice.UpdateChannelPolicy(ctx, &lnrpc.PolicyUpdateRequest{
+		Scope: &lnrpc.PolicyUpdateRequest_Global{Global: true}, BaseFeeMsat: 2000, TimeLockDelta: 40}); err != nil {
+		t.Fatal(err)
+	}
+
+	edge, err := alice.GetChanInfo(ctx, &lnrpc.ChanInfoRequest{ChanId: channel.ChanId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := edge.Node1Policy
+	if edge.Node2Pub == alice.PublicKey() {
+		policy = edge.Node2Policy
+	}
+	if inboundFee := GetInboundFee(policy); inboundFee != (InboundFee{BaseFeeMsat: -500, FeeRatePpm: -20}) ||
+		policy.FeeBaseMsat != 2000 {
+		t.Errorf("GetChanInfo() policy\nGot:\n%v %v\nWant:\n%v %v\n", policy.FeeBaseMsat, inboundFee,
+			2000, InboundFee{BaseFeeMsat: -500, FeeRatePpm: -20})
+	}
+}
@@ -0,0 +1,145 @@
+// Package node_client defines NodeClient, everything Torq does against a node regardless of its implementation.
+// The LND implementation wraps the gRPC clients, Core Lightning is implemented by pkg/cln and FakeNode simulates a
+// node in memory for tests.
+package node_client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"google.golang.org/grpc"
+)
+
+// NodeClient uses the LND request and response types, other implementations convert from and to them.
+type NodeClient interface {
+	GetInfo(ctx context.Context, in *lnrpc.GetInfoRequest, opts ...grpc.CallOption) (*lnrpc.GetInfoResponse, error)
+	WalletBalance(ctx context.Context, in *lnrpc.WalletBalanceRequest,
+		opts ...grpc.CallOption) (*lnrpc.WalletBalanceResponse, error)
+
+	// Channels
+	ListChannels(ctx context.Context, in *lnrpc.ListChannelsRequest,
+		opts ...grpc.CallOption) (*lnrpc.ListChannelsResponse, error)
+	ClosedChannels(ctx context.Context, in *lnrpc.ClosedChannelsRequest,
+		opts ...grpc.CallOption) (*lnrpc.ClosedChannelsResponse, error)
+	PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
+		opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error)
+	GetChanInfo(ctx context.Context, in *lnrpc.ChanInfoRequest, opts ...grpc.CallOption) (*lnrpc.ChannelEdge, error)
+	OpenChannel(ctx context.Context, in *lnrpc.OpenChannelRequest,
+		opts ...grpc.CallOption) (lnrpc.Lightning_OpenChannelClient, error)
+	BatchOpenChannel(ctx context.Context, in *lnrpc.BatchOpenChannelRequest,
+		opts ...grpc.CallOption) (*lnrpc.BatchOpenChannelResponse, error)
+	CloseChannel(ctx context.Context, in *lnrpc.CloseChannelRequest,
+		opts ...grpc.CallOption) (lnrpc.Lightning_CloseChannelClient, error)
+	UpdateChannelPolicy(ctx context.Context, in *lnrpc.PolicyUpdateRequest,
+		opts ...grpc.CallOption) (*lnrpc.PolicyUpdateResponse, error)
+
+	// Peers and gossip
+	GetNodeInfo(ctx context.Context, in *lnrpc.NodeInfoRequest, opts ...grpc.CallOption) (*lnrpc.NodeInfo, error)
+	ListPeers(ctx context.Context, in *lnrpc.ListPeersRequest,
+		opts ...grpc.CallOption) (*lnrpc.ListPeersResponse, error)
+	ConnectPeer(ctx context.Context, in *lnrpc.ConnectPeerRequest,
+		opts ...grpc.CallOption) (*lnrpc.ConnectPeerResponse, error)
+
+	// Payments and invoices
+	ForwardingHistory(ctx context.Context, in *lnrpc.ForwardingHistoryRequest,
+		opts ...grpc.CallOption) (*lnrpc.ForwardingHistoryResponse, error)
+	ListPayments(ctx context.Context, in *lnrpc.ListPaymentsRequest,
+		opts ...grpc.CallOption) (*lnrpc.ListPaymentsResponse, error)
+	ListInvoices(ctx context.Context, in *lnrpc.ListInvoiceRequest,
+		opts ...grpc.CallOption) (*lnrpc.ListInvoiceResponse, error)
+	DecodePayReq(ctx context.Context, in *lnrpc.PayReqString, opts ...grpc.CallOption) (*lnrpc.PayReq, error)
+	AddInvoice(ctx context.Context, in *lnrpc.Invoice, opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error)
+	SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
+		opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client, error)
+
+	// On-chain
+	GetTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+		opts ...grpc.CallOption) (*lnrpc.TransactionDetails, error)
+	SendCoins(ctx context.Context, in *lnrpc.SendCoinsRequest,
+		opts ...grpc.CallOption) (*lnrpc.SendCoinsResponse, error)
+	NextAddr(ctx context.Context, in *walletrpc.AddrRequest, opts ...grpc.CallOption) (*walletrpc.AddrResponse, error)
+
+	// Messages
+	SignMessage(ctx context.Context, in *lnrpc.SignMessageRequest,
+		opts ...grpc.CallOption) (*lnrpc.SignMessageResponse, error)
+	VerifyMessage(ctx context.Context, in *lnrpc.VerifyMessageRequest,
+		opts ...grpc.CallOption) (*lnrpc.VerifyMessageResponse, error)
+
+	// Channel backups
+	ExportAllChannelBackups(ctx context.Context, in *lnrpc.ChanBackupExportRequest,
+		opts ...grpc.CallOption) (*lnrpc.ChanBackupSnapshot, error)
+	VerifyChanBackup(ctx context.Context, in *lnrpc.ChanBackupSnapshot,
+		opts ...grpc.CallOption) (*lnrpc.VerifyChanBackupResponse, error)
+	RestoreChannelBackups(ctx context.Context, in *lnrpc.RestoreChanBackupRequest,
+		opts ...grpc.CallOption) (*lnrpc.RestoreBackupResponse, error)
+
+	// Subscriptions
+	SubscribeChannelEvents(ctx context.Context, in *lnrpc.ChannelEventSubscription,
+		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelEventsClient, error)
+	SubscribeChannelGraph(ctx context.Context, in *lnrpc.GraphTopologySubscription,
+		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelGraphClient, error)
+	SubscribeInvoices(ctx context.Context, in *lnrpc.InvoiceSubscription,
+		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeInvoicesClient, error)
+	SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
+		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error)
+	SubscribeTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeTransactionsClient, error)
+	SubscribeHtlcEvents(ctx context.Context, in *routerrpc.SubscribeHtlcEventsRequest,
+		opts ...grpc.CallOption) (routerrpc.Router_SubscribeHtlcEventsClient, error)
+	SubscribeChannelBackups(ctx context.Context, in *lnrpc.ChannelBackupSubscription,
+		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelBackupsClient, error)
+	// RegisterBlockEpochNtfn sends the best block first and then every new block.
+	RegisterBlockEpochNtfn(ctx context.Context, in *chainrpc.BlockEpoch,
+		opts ...grpc.CallOption) (chainrpc.ChainNotifier_RegisterBlockEpochNtfnClient, error)
+}
+
+// lndClient combines the gRPC clients of LND, the methods that exist on more than one of them (i.e. SendToRoute) are
+// ambiguous and not part of NodeClient.
+type lndClient struct {
+	lnrpc.LightningClient
+	routerrpc.RouterClient
+	walletrpc.WalletKitClient
+	chainrpc.ChainNotifierClient
+}
+
+// NewLndClient returns the NodeClient of an LND node on the given connection.
+func NewLndClient(conn *grpc.ClientConn) NodeClient {
+	return lndClient{
+		LightningClient:     lnrpc.NewLightningClient(conn),
+		RouterClient:        routerrpc.NewRouterClient(conn),
+		WalletKitClient:     walletrpc.NewWalletKitClient(conn),
+		ChainNotifierClient: chainrpc.NewChainNotifierClient(conn),
+	}
+}
+
+//nolint:gochecknoglobals
+var (
+	registered   = make(map[int]NodeClient)
+	registeredMu sync.RWMutex
+)
+
+// Register makes Registered return the client for the node instead of connecting to it, i.e. a FakeNode in tests.
+func Register(nodeId int, client NodeClient) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered[nodeId] = client
+}
+
+// Unregister removes a client added with Register.
+func Unregister(nodeId int) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	delete(registered, nodeId)
+}
+
+// Registered returns the client registered for the node, if any.
+func Registered(nodeId int) (NodeClient, bool) {
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	client, exists := registered[nodeId]
+	return client, exists
+}
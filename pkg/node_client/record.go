@@ -0,0 +1,503 @@
+package node_client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordedResponse is a line of a recording. Unary calls have no stream, every subscription gets its own stream
+// number per method and starts with an open line.
+type recordedResponse struct {
+	Time     int64           `json:"t"`
+	Method   string          `json:"m"`
+	Stream   int             `json:"s,omitempty"`
+	Open     bool            `json:"o,omitempty"`
+	Response json.RawMessage `json:"r,omitempty"`
+	Code     codes.Code      `json:"c,omitempty"`
+	Error    string          `json:"e,omitempty"`
+}
+
+// Recorder is a NodeClient that writes every response it reads from the node to a recording, the read-only calls and
+// the subscriptions are recorded and everything else is passed on without recording.
+type Recorder struct {
+	NodeClient
+	mu      sync.Mutex
+	w       io.Writer
+	encoder *json.Encoder
+	streams map[string]int
+	err     error
+}
+
+// NewRecorder records the responses of the client as JSON lines to w.
+func NewRecorder(client NodeClient, w io.Writer) *Recorder {
+	return &Recorder{
+		NodeClient: client,
+		w:          w,
+		encoder:    json.NewEncoder(w),
+		streams:    make(map[string]int),
+	}
+}
+
+// Err returns the first error writing the recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(line recordedResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	line.Time = time.Now().UnixNano()
+	r.err = r.encoder.Encode(line)
+	if flusher, ok := r.w.(interface{ Flush() error }); ok && r.err == nil {
+		r.err = flusher.Flush()
+	}
+}
+
+func (r *Recorder) record(method string, stream int, response proto.Message, err error) {
+	line := recordedResponse{Method: method, Stream: stream}
+	if err != nil {
+		line.Code = status.Code(err)
+		line.Error = status.Convert(err).Message()
+	} else {
+		b, err := protojson.Marshal(response)
+		if err != nil {
+			line.Code = codes.Internal
+			line.Error = "Marshalling response: " + err.Error()
+		}
+		line.Response = b
+	}
+	r.write(line)
+}
+
+// openStream records the start of a subscription and returns its stream number.
+func (r *Recorder) openStream(method string, err error) int {
+	r.mu.Lock()
+	r.streams[method]++
+	stream := r.streams[method]
+	r.mu.Unlock()
+	line := recordedResponse{Method: method, Stream: stream, Open: true}
+	if err != nil {
+		line.Code = status.Code(err)
+		line.Error = status.Convert(err).Message()
+	}
+	r.write(line)
+	return stream
+}
+
+// recordRecv records a received stream message, errors caused by the end of the subscription itself are left out
+// so a replay doesn't stop the stream.
+func (r *Recorder) recordRecv(ctx context.Context, method string, stream int, response proto.Message, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	r.record(method, stream, response, err)
+}
+
+func (r *Recorder) GetInfo(ctx context.Context, in *lnrpc.GetInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.GetInfoResponse, error) {
+
+	resp, err := r.NodeClient.GetInfo(ctx, in, opts...)
+	r.record("GetInfo", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) WalletBalance(ctx context.Context, in *lnrpc.WalletBalanceRequest,
+	opts ...grpc.CallOption) (*lnrpc.WalletBalanceResponse, error) {
+
+	resp, err := r.NodeClient.WalletBalance(ctx, in, opts...)
+	r.record("WalletBalance", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ListChannels(ctx context.Context, in *lnrpc.ListChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListChannelsResponse, error) {
+
+	resp, err := r.NodeClient.ListChannels(ctx, in, opts...)
+	r.record("ListChannels", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ClosedChannels(ctx context.Context, in *lnrpc.ClosedChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ClosedChannelsResponse, error) {
+
+	resp, err := r.NodeClient.ClosedChannels(ctx, in, opts...)
+	r.record("ClosedChannels", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error) {
+
+	resp, err := r.NodeClient.PendingChannels(ctx, in, opts...)
+	r.record("PendingChannels", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) GetChanInfo(ctx context.Context, in *lnrpc.ChanInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChannelEdge, error) {
+
+	resp, err := r.NodeClient.GetChanInfo(ctx, in, opts...)
+	r.record("GetChanInfo", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) GetNodeInfo(ctx context.Context, in *lnrpc.NodeInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.NodeInfo, error) {
+
+	resp, err := r.NodeClient.GetNodeInfo(ctx, in, opts...)
+	r.record("GetNodeInfo", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ListPeers(ctx context.Context, in *lnrpc.ListPeersRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPeersResponse, error) {
+
+	resp, err := r.NodeClient.ListPeers(ctx, in, opts...)
+	r.record("ListPeers", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ForwardingHistory(ctx context.Context, in *lnrpc.ForwardingHistoryRequest,
+	opts ...grpc.CallOption) (*lnrpc.ForwardingHistoryResponse, error) {
+
+	resp, err := r.NodeClient.ForwardingHistory(ctx, in, opts...)
+	r.record("ForwardingHistory", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ListPayments(ctx context.Context, in *lnrpc.ListPaymentsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPaymentsResponse, error) {
+
+	resp, err := r.NodeClient.ListPayments(ctx, in, opts...)
+	r.record("ListPayments", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ListInvoices(ctx context.Context, in *lnrpc.ListInvoiceRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListInvoiceResponse, error) {
+
+	resp, err := r.NodeClient.ListInvoices(ctx, in, opts...)
+	r.record("ListInvoices", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) DecodePayReq(ctx context.Context, in *lnrpc.PayReqString,
+	opts ...grpc.CallOption) (*lnrpc.PayReq, error) {
+
+	resp, err := r.NodeClient.DecodePayReq(ctx, in, opts...)
+	r.record("DecodePayReq", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) GetTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (*lnrpc.TransactionDetails, error) {
+
+	resp, err := r.NodeClient.GetTransactions(ctx, in, opts...)
+	r.record("GetTransactions", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) ExportAllChannelBackups(ctx context.Context, in *lnrpc.ChanBackupExportRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChanBackupSnapshot, error) {
+
+	resp, err := r.NodeClient.ExportAllChannelBackups(ctx, in, opts...)
+	r.record("ExportAllChannelBackups", 0, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribeChannelEvents(ctx context.Context, in *lnrpc.ChannelEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelEventsClient, error) {
+
+	client, err := r.NodeClient.SubscribeChannelEvents(ctx, in, opts...)
+	stream := r.openStream("SubscribeChannelEvents", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedChannelEventStream{client, ctx, r, stream}, nil
+}
+
+type recordedChannelEventStream struct {
+	lnrpc.Lightning_SubscribeChannelEventsClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedChannelEventStream) Recv() (*lnrpc.ChannelEventUpdate, error) {
+	resp, err := s.Lightning_SubscribeChannelEventsClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribeChannelEvents", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribeChannelGraph(ctx context.Context, in *lnrpc.GraphTopologySubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelGraphClient, error) {
+
+	client, err := r.NodeClient.SubscribeChannelGraph(ctx, in, opts...)
+	stream := r.openStream("SubscribeChannelGraph", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedChannelGraphStream{client, ctx, r, stream}, nil
+}
+
+type recordedChannelGraphStream struct {
+	lnrpc.Lightning_SubscribeChannelGraphClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedChannelGraphStream) Recv() (*lnrpc.GraphTopologyUpdate, error) {
+	resp, err := s.Lightning_SubscribeChannelGraphClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribeChannelGraph", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribeInvoices(ctx context.Context, in *lnrpc.InvoiceSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeInvoicesClient, error) {
+
+	client, err := r.NodeClient.SubscribeInvoices(ctx, in, opts...)
+	stream := r.openStream("SubscribeInvoices", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedInvoiceStream{client, ctx, r, stream}, nil
+}
+
+type recordedInvoiceStream struct {
+	lnrpc.Lightning_SubscribeInvoicesClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedInvoiceStream) Recv() (*lnrpc.Invoice, error) {
+	resp, err := s.Lightning_SubscribeInvoicesClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribeInvoices", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error) {
+
+	client, err := r.NodeClient.SubscribePeerEvents(ctx, in, opts...)
+	stream := r.openStream("SubscribePeerEvents", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedPeerEventStream{client, ctx, r, stream}, nil
+}
+
+type recordedPeerEventStream struct {
+	lnrpc.Lightning_SubscribePeerEventsClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedPeerEventStream) Recv() (*lnrpc.PeerEvent, error) {
+	resp, err := s.Lightning_SubscribePeerEventsClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribePeerEvents", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribeTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeTransactionsClient, error) {
+
+	client, err := r.NodeClient.SubscribeTransactions(ctx, in, opts...)
+	stream := r.openStream("SubscribeTransactions", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedTransactionStream{client, ctx, r, stream}, nil
+}
+
+type recordedTransactionStream struct {
+	lnrpc.Lightning_SubscribeTransactionsClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedTransactionStream) Recv() (*lnrpc.Transaction, error) {
+	resp, err := s.Lightning_SubscribeTransactionsClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribeTransactions", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribeHtlcEvents(ctx context.Context, in *routerrpc.SubscribeHtlcEventsRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SubscribeHtlcEventsClient, error) {
+
+	client, err := r.NodeClient.SubscribeHtlcEvents(ctx, in, opts...)
+	stream := r.openStream("SubscribeHtlcEvents", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedHtlcEventStream{client, ctx, r, stream}, nil
+}
+
+type recordedHtlcEventStream struct {
+	routerrpc.Router_SubscribeHtlcEventsClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedHtlcEventStream) Recv() (*routerrpc.HtlcEvent, error) {
+	resp, err := s.Router_SubscribeHtlcEventsClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribeHtlcEvents", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) SubscribeChannelBackups(ctx context.Context, in *lnrpc.ChannelBackupSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelBackupsClient, error) {
+
+	client, err := r.NodeClient.SubscribeChannelBackups(ctx, in, opts...)
+	stream := r.openStream("SubscribeChannelBackups", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedChannelBackupStream{client, ctx, r, stream}, nil
+}
+
+type recordedChannelBackupStream struct {
+	lnrpc.Lightning_SubscribeChannelBackupsClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedChannelBackupStream) Recv() (*lnrpc.ChanBackupSnapshot, error) {
+	resp, err := s.Lightning_SubscribeChannelBackupsClient.Recv()
+	s.recorder.recordRecv(s.ctx, "SubscribeChannelBackups", s.stream, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) RegisterBlockEpochNtfn(ctx context.Context, in *chainrpc.BlockEpoch,
+	opts ...grpc.CallOption) (chainrpc.ChainNotifier_RegisterBlockEpochNtfnClient, error) {
+
+	client, err := r.NodeClient.RegisterBlockEpochNtfn(ctx, in, opts...)
+	stream := r.openStream("RegisterBlockEpochNtfn", err)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedBlockEpochStream{client, ctx, r, stream}, nil
+}
+
+type recordedBlockEpochStream struct {
+	chainrpc.ChainNotifier_RegisterBlockEpochNtfnClient
+	ctx      context.Context
+	recorder *Recorder
+	stream   int
+}
+
+func (s *recordedBlockEpochStream) Recv() (*chainrpc.BlockEpoch, error) {
+	resp, err := s.ChainNotifier_RegisterBlockEpochNtfnClient.Recv()
+	s.recorder.recordRecv(s.ctx, "RegisterBlockEpochNtfn", s.stream, resp, err)
+	return resp, err
+}
+
+// recordingFile writes a recording to disk, compressed with gzip when the name ends with .gz.
+type recordingFile struct {
+	file   *os.File
+	gzip   *gzip.Writer
+	buffer *bufio.Writer
+}
+
+// CreateRecordingFile creates the file for a recording, every line is flushed to disk when it's written so a
+// recording of a crashed Torq is complete up to the crash.
+func CreateRecordingFile(path string) (io.WriteCloser, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating recording file")
+	}
+	recording := &recordingFile{file: file}
+	if strings.HasSuffix(path, ".gz") {
+		recording.gzip = gzip.NewWriter(file)
+		recording.buffer = bufio.NewWriter(recording.gzip)
+	} else {
+		recording.buffer = bufio.NewWriter(file)
+	}
+	return recording, nil
+}
+
+func (f *recordingFile) Write(p []byte) (int, error) {
+	return f.buffer.Write(p)
+}
+
+func (f *recordingFile) Flush() error {
+	if err := f.buffer.Flush(); err != nil {
+		return errors.Wrap(err, "Writing recording")
+	}
+	if f.gzip != nil {
+		if err := f.gzip.Flush(); err != nil {
+			return errors.Wrap(err, "Compressing recording")
+		}
+	}
+	return nil
+}
+
+func (f *recordingFile) Close() error {
+	err := f.Flush()
+	if f.gzip != nil {
+		if gzipErr := f.gzip.Close(); err == nil && gzipErr != nil {
+			err = errors.Wrap(gzipErr, "Compressing recording")
+		}
+	}
+	if closeErr := f.file.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "Closing recording")
+	}
+	return err
+}
+
+// OpenRecordingFile opens a recording created by CreateRecordingFile.
+func OpenRecordingFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Opening recording file")
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "Decompressing recording")
+	}
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f *gzipFile) Close() error {
+	err := f.Reader.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
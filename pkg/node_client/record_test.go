@@ -0,0 +1,244 @@
+package node_client
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// receiveAll receives the channel and HTLC events of the client until n messages are received and returns them per
+// subscription.
+func receiveAll(t *testing.T, ctx context.Context, client NodeClient, n int) map[string][]string {
+	t.Helper()
+	channelEvents, err := client.SubscribeChannelEvents(ctx, &lnrpc.ChannelEventSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribeChannelEvents() error: %v", err)
+	}
+	htlcEvents, err := client.SubscribeHtlcEvents(ctx, &routerrpc.SubscribeHtlcEventsRequest{})
+	if err != nil {
+		t.Fatalf("SubscribeHtlcEvents() error: %v", err)
+	}
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	count := 0
+	receive := func(subscription string, recv func() (string, error)) {
+		for {
+			message, err := recv()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			received[subscription] = append(received[subscription], message)
+			count++
+			mu.Unlock()
+		}
+	}
+	go receive("channelEvents", func() (string, error) {
+		event, err := channelEvents.Recv()
+		if err != nil {
+			return "", err
+		}
+		return event.Type.String(), nil
+	})
+	go receive("htlcEvents", func() (string, error) {
+		event, err := htlcEvents.Recv()
+		if err != nil {
+			return "", err
+		}
+		return event.EventType.String(), nil
+	})
+	for {
+		mu.Lock()
+		if count >= n {
+			defer mu.Unlock()
+			result := make(map[string][]string)
+			for subscription, messages := range received {
+				result[subscription] = append([]string{}, messages...)
+			}
+			return result
+		}
+		mu.Unlock()
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Received %v\nWant %d messages", received, n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	node := NewFakeNode("alice")
+	var recording bytes.Buffer
+	recorder := NewRecorder(node, &recording)
+
+	recordCtx, recordCancel := context.WithCancel(ctx)
+	done := make(chan map[string][]string)
+	go func() { done <- receiveAll(t, recordCtx, recorder, 6) }()
+	time.Sleep(10 * time.Millisecond)
+	incoming := node.AddChannel(NewFakeNode("bob").PublicKey(), 1000000, 0)
+	outgoing := node.AddChannel(NewFakeNode("carol").PublicKey(), 1000000, 1000000)
+	if err := node.Forward(incoming.ChanId, outgoing.ChanId, 1000000, 1000); err != nil {
+		t.Fatalf("Forward() error: %v", err)
+	}
+	recorded := <-done
+	if _, err := recorder.ListChannels(ctx, &lnrpc.ListChannelsRequest{}); err != nil {
+		t.Fatalf("ListChannels() error: %v", err)
+	}
+	if _, err := recorder.GetChanInfo(ctx, &lnrpc.ChanInfoRequest{ChanId: 1}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetChanInfo() error\nGot:\n%v\nWant:\n%v\n", err, codes.NotFound)
+	}
+	recordCancel()
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Recorder error: %v", err)
+	}
+
+	replayer, err := NewReplayer(&recording, 0)
+	if err != nil {
+		t.Fatalf("NewReplayer() error: %v", err)
+	}
+	replayed := receiveAll(t, ctx, replayer, 6)
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("Replayed messages\nGot:\n%v\nWant:\n%v\n", replayed, recorded)
+	}
+	if remaining := replayer.Remaining(); remaining != 2 {
+		t.Errorf("Remaining() before the unary calls\nGot:\n%v\nWant:\n%v\n", remaining, 2)
+	}
+	select {
+	case <-replayer.Done():
+		t.Fatalf("Replayer done before the unary responses are consumed")
+	default:
+	}
+
+	channels, err := replayer.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		t.Fatalf("ListChannels() error: %v", err)
+	}
+	if len(channels.Channels) != 2 || channels.Channels[1].ChanId != outgoing.ChanId {
+		t.Errorf("ListChannels()\nGot:\n%v\nWant:\n%v\n", channels.Channels, "the recorded channels")
+	}
+	if _, err := replayer.GetChanInfo(ctx, &lnrpc.ChanInfoRequest{ChanId: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetChanInfo() error\nGot:\n%v\nWant:\n%v\n", err, codes.NotFound)
+	}
+	select {
+	case <-replayer.Done():
+	case <-ctx.Done():
+		t.Fatalf("Replayer not done, %v remaining", replayer.Unconsumed())
+	}
+	if _, err := replayer.ListChannels(ctx, &lnrpc.ListChannelsRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("ListChannels() without recording\nGot:\n%v\nWant:\n%v\n", err, codes.Unavailable)
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	recording := `{"t":1,"m":"SubscribeInvoices","s":1,"o":true}
+{"t":1,"m":"SubscribePeerEvents","s":1,"o":true}
+{"t":2,"m":"SubscribePeerEvents","s":1,"r":{"pubKey":"first"}}
+{"t":3,"m":"SubscribeInvoices","s":1,"r":{"memo":"second"}}
+{"t":4,"m":"SubscribeInvoices","s":1,"c":14,"e":"connection lost"}
+`
+	replayer, err := NewReplayer(bytes.NewBufferString(recording), 0)
+	if err != nil {
+		t.Fatalf("NewReplayer() error: %v", err)
+	}
+	invoices, err := replayer.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribeInvoices() error: %v", err)
+	}
+	peerEvents, err := replayer.SubscribePeerEvents(ctx, &lnrpc.PeerEventSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribePeerEvents() error: %v", err)
+	}
+
+	// The invoice was recorded after the peer event so it waits for the peer event to be received.
+	invoice := make(chan *lnrpc.Invoice)
+	go func() {
+		received, _ := invoices.Recv()
+		invoice <- received
+	}()
+	select {
+	case received := <-invoice:
+		t.Fatalf("Recv() invoice before the peer event\nGot:\n%v\n", received)
+	case <-time.After(50 * time.Millisecond):
+	}
+	peerEvent, err := peerEvents.Recv()
+	if err != nil || peerEvent.PubKey != "first" {
+		t.Fatalf("Recv() peer event\nGot:\n%v %v\nWant:\n%v\n", peerEvent, err, "first")
+	}
+	if received := <-invoice; received == nil || received.Memo != "second" {
+		t.Errorf("Recv() invoice\nGot:\n%v\nWant:\n%v\n", received, "second")
+	}
+	if _, err := invoices.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Recv() recorded error\nGot:\n%v\nWant:\n%v\n", err, codes.Unavailable)
+	}
+	<-replayer.Done()
+}
+
+func TestReplaySpeed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now().UnixNano()
+	recording := `{"t":` + itoa(uint64(start)) + `,"m":"SubscribeInvoices","s":1,"o":true}
+{"t":` + itoa(uint64(start+int64(200*time.Millisecond))) + `,"m":"SubscribeInvoices","s":1,"r":{"memo":"late"}}
+`
+	replayer, err := NewReplayer(bytes.NewBufferString(recording), 1)
+	if err != nil {
+		t.Fatalf("NewReplayer() error: %v", err)
+	}
+	invoices, err := replayer.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribeInvoices() error: %v", err)
+	}
+	began := time.Now()
+	invoice, err := invoices.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error: %v", err)
+	}
+	if invoice.Memo != "late" || time.Since(began) < 150*time.Millisecond {
+		t.Errorf("Recv()\nGot:\n%v after %v\nWant:\n%v\n", invoice.Memo, time.Since(began), "late after 200ms")
+	}
+}
+
+func TestRecordingFile(t *testing.T) {
+	for _, name := range []string{"recording.jsonl", "recording.jsonl.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		file, err := CreateRecordingFile(path)
+		if err != nil {
+			t.Fatalf("CreateRecordingFile() error: %v", err)
+		}
+		recorder := NewRecorder(NewFakeNode("alice"), file)
+		if _, err := recorder.GetInfo(context.Background(), &lnrpc.GetInfoRequest{}); err != nil {
+			t.Fatalf("GetInfo() error: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+
+		reader, err := OpenRecordingFile(path)
+		if err != nil {
+			t.Fatalf("OpenRecordingFile() error: %v", err)
+		}
+		replayer, err := NewReplayer(reader, 0)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("NewReplayer() error: %v", err)
+		}
+		info, err := replayer.GetInfo(context.Background(), &lnrpc.GetInfoRequest{})
+		if err != nil || info.Alias != "alice" {
+			t.Errorf("%v: GetInfo()\nGot:\n%v %v\nWant:\n%v\n", name, info, err, "alice")
+		}
+	}
+}
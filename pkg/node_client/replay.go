@@ -0,0 +1,644 @@
+package node_client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+type replayStreamKey struct {
+	method string
+	stream int
+}
+
+// Replayer is a NodeClient that answers with the responses of a recording made by Recorder.
+// Unary calls get the recorded responses of their method in order, polled methods (i.e. ForwardingHistory) are
+// requested at the interval of their polling loop so they take as long as they took when recording.
+// The messages of all subscriptions are received in the order they were recorded, a subscription waits until the
+// messages recorded before its next message are received by the other subscriptions. Calls that change the node are
+// not recorded and fail.
+type Replayer struct {
+	mu         sync.Mutex
+	speed      float64
+	start      time.Time
+	firstTime  int64
+	unary      map[string][]recordedResponse
+	unaryLeft  int
+	opens      map[string][]recordedResponse
+	subscribed map[string]int
+	messages   []recordedResponse
+	streams    map[replayStreamKey][]int
+	next       int
+	advanced   chan struct{}
+	done       chan struct{}
+}
+
+// NewReplayer reads a recording. A speed of 1 receives the subscription messages with the delays they were
+// recorded with, 2 twice as fast and 0 as fast as possible.
+func NewReplayer(r io.Reader, speed float64) (*Replayer, error) {
+	replayer := &Replayer{
+		speed:      speed,
+		unary:      make(map[string][]recordedResponse),
+		opens:      make(map[string][]recordedResponse),
+		subscribed: make(map[string]int),
+		streams:    make(map[replayStreamKey][]int),
+		advanced:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var response recordedResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, errors.Wrapf(err, "Parsing recording line %d", line)
+		}
+		if replayer.firstTime == 0 {
+			replayer.firstTime = response.Time
+		}
+		switch {
+		case response.Stream == 0:
+			replayer.unary[response.Method] = append(replayer.unary[response.Method], response)
+			replayer.unaryLeft++
+		case response.Open:
+			replayer.opens[response.Method] = append(replayer.opens[response.Method], response)
+		default:
+			key := replayStreamKey{method: response.Method, stream: response.Stream}
+			replayer.streams[key] = append(replayer.streams[key], len(replayer.messages))
+			replayer.messages = append(replayer.messages, response)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "Reading recording")
+	}
+	replayer.checkDone()
+	replayer.start = time.Now()
+	return replayer, nil
+}
+
+// Done is closed when every recorded subscription message has been received and every recorded unary response has
+// been returned.
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// Remaining returns the number of subscription messages and unary responses that haven't been consumed yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messages) - r.next + r.unaryLeft
+}
+
+// Unconsumed returns the number of subscription messages and unary responses that haven't been consumed yet by
+// method, methods without any left are omitted.
+func (r *Replayer) Unconsumed() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unconsumed := make(map[string]int)
+	for method, responses := range r.unary {
+		if len(responses) != 0 {
+			unconsumed[method] = len(responses)
+		}
+	}
+	for _, message := range r.messages[r.next:] {
+		unconsumed[message.Method]++
+	}
+	return unconsumed
+}
+
+// checkDone closes done once everything is consumed, the caller needs to hold the lock.
+func (r *Replayer) checkDone() {
+	if r.next == len(r.messages) && r.unaryLeft == 0 {
+		close(r.done)
+	}
+}
+
+func recordedError(response recordedResponse) error {
+	if response.Code == codes.OK {
+		return nil
+	}
+	return status.Error(response.Code, response.Error)
+}
+
+func (r *Replayer) call(method string, resp proto.Message) error {
+	r.mu.Lock()
+	responses := r.unary[method]
+	if len(responses) == 0 {
+		r.mu.Unlock()
+		return status.Errorf(codes.Unavailable, "No recorded %v response left", method)
+	}
+	response := responses[0]
+	r.unary[method] = responses[1:]
+	r.unaryLeft--
+	r.checkDone()
+	r.mu.Unlock()
+	if err := recordedError(response); err != nil {
+		return err
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(response.Response, resp); err != nil {
+		return errors.Wrapf(err, "Parsing recorded %v response", method)
+	}
+	return nil
+}
+
+// subscribe returns the stream for the next subscription of the method like it was recorded.
+func (r *Replayer) subscribe(ctx context.Context, method string) (*replayStream, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribed[method]++
+	stream := &replayStream{ctx: ctx, replayer: r,
+		key: replayStreamKey{method: method, stream: r.subscribed[method]}}
+	if opens := r.opens[method]; len(opens) != 0 {
+		r.opens[method] = opens[1:]
+		stream.key.stream = opens[0].Stream
+		if err := recordedError(opens[0]); err != nil {
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
+// recv returns the next message of the stream once it's its turn, streams without messages left block until the
+// context is done like an idle subscription.
+func (r *Replayer) recv(ctx context.Context, key replayStreamKey, resp proto.Message) error {
+	r.mu.Lock()
+	positions := r.streams[key]
+	if len(positions) == 0 {
+		r.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	position := positions[0]
+	for r.next != position {
+		advanced := r.advanced
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-advanced:
+		}
+		r.mu.Lock()
+	}
+	response := r.messages[position]
+	r.mu.Unlock()
+
+	if r.speed > 0 {
+		delay := time.Until(r.start.Add(time.Duration(float64(response.Time-r.firstTime) / r.speed)))
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.streams[key] = positions[1:]
+	r.next++
+	close(r.advanced)
+	r.advanced = make(chan struct{})
+	r.checkDone()
+	r.mu.Unlock()
+
+	if err := recordedError(response); err != nil {
+		return err
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(response.Response, resp); err != nil {
+		return errors.Wrapf(err, "Parsing recorded %v message", key.method)
+	}
+	return nil
+}
+
+func notRecorded(method string) error {
+	return status.Errorf(codes.Unimplemented, "%v is not available when replaying a recording", method)
+}
+
+func (r *Replayer) GetInfo(ctx context.Context, in *lnrpc.GetInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.GetInfoResponse, error) {
+
+	resp := &lnrpc.GetInfoResponse{}
+	if err := r.call("GetInfo", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) WalletBalance(ctx context.Context, in *lnrpc.WalletBalanceRequest,
+	opts ...grpc.CallOption) (*lnrpc.WalletBalanceResponse, error) {
+
+	resp := &lnrpc.WalletBalanceResponse{}
+	if err := r.call("WalletBalance", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) ListChannels(ctx context.Context, in *lnrpc.ListChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListChannelsResponse, error) {
+
+	resp := &lnrpc.ListChannelsResponse{}
+	if err := r.call("ListChannels", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) ClosedChannels(ctx context.Context, in *lnrpc.ClosedChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ClosedChannelsResponse, error) {
+
+	resp := &lnrpc.ClosedChannelsResponse{}
+	if err := r.call("ClosedChannels", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
+	opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error) {
+
+	resp := &lnrpc.PendingChannelsResponse{}
+	if err := r.call("PendingChannels", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) GetChanInfo(ctx context.Context, in *lnrpc.ChanInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChannelEdge, error) {
+
+	resp := &lnrpc.ChannelEdge{}
+	if err := r.call("GetChanInfo", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) OpenChannel(ctx context.Context, in *lnrpc.OpenChannelRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_OpenChannelClient, error) {
+
+	return nil, notRecorded("OpenChannel")
+}
+
+func (r *Replayer) BatchOpenChannel(ctx context.Context, in *lnrpc.BatchOpenChannelRequest,
+	opts ...grpc.CallOption) (*lnrpc.BatchOpenChannelResponse, error) {
+
+	return nil, notRecorded("BatchOpenChannel")
+}
+
+func (r *Replayer) CloseChannel(ctx context.Context, in *lnrpc.CloseChannelRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_CloseChannelClient, error) {
+
+	return nil, notRecorded("CloseChannel")
+}
+
+func (r *Replayer) UpdateChannelPolicy(ctx context.Context, in *lnrpc.PolicyUpdateRequest,
+	opts ...grpc.CallOption) (*lnrpc.PolicyUpdateResponse, error) {
+
+	return nil, notRecorded("UpdateChannelPolicy")
+}
+
+func (r *Replayer) GetNodeInfo(ctx context.Context, in *lnrpc.NodeInfoRequest,
+	opts ...grpc.CallOption) (*lnrpc.NodeInfo, error) {
+
+	resp := &lnrpc.NodeInfo{}
+	if err := r.call("GetNodeInfo", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) ListPeers(ctx context.Context, in *lnrpc.ListPeersRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPeersResponse, error) {
+
+	resp := &lnrpc.ListPeersResponse{}
+	if err := r.call("ListPeers", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) ConnectPeer(ctx context.Context, in *lnrpc.ConnectPeerRequest,
+	opts ...grpc.CallOption) (*lnrpc.ConnectPeerResponse, error) {
+
+	return nil, notRecorded("ConnectPeer")
+}
+
+func (r *Replayer) ForwardingHistory(ctx context.Context, in *lnrpc.ForwardingHistoryRequest,
+	opts ...grpc.CallOption) (*lnrpc.ForwardingHistoryResponse, error) {
+
+	resp := &lnrpc.ForwardingHistoryResponse{}
+	if err := r.call("ForwardingHistory", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) ListPayments(ctx context.Context, in *lnrpc.ListPaymentsRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListPaymentsResponse, error) {
+
+	resp := &lnrpc.ListPaymentsResponse{}
+	if err := r.call("ListPayments", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) ListInvoices(ctx context.Context, in *lnrpc.ListInvoiceRequest,
+	opts ...grpc.CallOption) (*lnrpc.ListInvoiceResponse, error) {
+
+	resp := &lnrpc.ListInvoiceResponse{}
+	if err := r.call("ListInvoices", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) DecodePayReq(ctx context.Context, in *lnrpc.PayReqString,
+	opts ...grpc.CallOption) (*lnrpc.PayReq, error) {
+
+	resp := &lnrpc.PayReq{}
+	if err := r.call("DecodePayReq", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) AddInvoice(ctx context.Context, in *lnrpc.Invoice,
+	opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error) {
+
+	return nil, notRecorded("AddInvoice")
+}
+
+func (r *Replayer) SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client, error) {
+
+	return nil, notRecorded("SendPaymentV2")
+}
+
+func (r *Replayer) GetTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (*lnrpc.TransactionDetails, error) {
+
+	resp := &lnrpc.TransactionDetails{}
+	if err := r.call("GetTransactions", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) SendCoins(ctx context.Context, in *lnrpc.SendCoinsRequest,
+	opts ...grpc.CallOption) (*lnrpc.SendCoinsResponse, error) {
+
+	return nil, notRecorded("SendCoins")
+}
+
+func (r *Replayer) NextAddr(ctx context.Context, in *walletrpc.AddrRequest,
+	opts ...grpc.CallOption) (*walletrpc.AddrResponse, error) {
+
+	return nil, notRecorded("NextAddr")
+}
+
+func (r *Replayer) SignMessage(ctx context.Context, in *lnrpc.SignMessageRequest,
+	opts ...grpc.CallOption) (*lnrpc.SignMessageResponse, error) {
+
+	return nil, notRecorded("SignMessage")
+}
+
+func (r *Replayer) VerifyMessage(ctx context.Context, in *lnrpc.VerifyMessageRequest,
+	opts ...grpc.CallOption) (*lnrpc.VerifyMessageResponse, error) {
+
+	return nil, notRecorded("VerifyMessage")
+}
+
+func (r *Replayer) ExportAllChannelBackups(ctx context.Context, in *lnrpc.ChanBackupExportRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChanBackupSnapshot, error) {
+
+	resp := &lnrpc.ChanBackupSnapshot{}
+	if err := r.call("ExportAllChannelBackups", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Replayer) VerifyChanBackup(ctx context.Context, in *lnrpc.ChanBackupSnapshot,
+	opts ...grpc.CallOption) (*lnrpc.VerifyChanBackupResponse, error) {
+
+	return nil, notRecorded("VerifyChanBackup")
+}
+
+func (r *Replayer) RestoreChannelBackups(ctx context.Context, in *lnrpc.RestoreChanBackupRequest,
+	opts ...grpc.CallOption) (*lnrpc.RestoreBackupResponse, error) {
+
+	return nil, notRecorded("RestoreChannelBackups")
+}
+
+func (r *Replayer) SubscribeChannelEvents(ctx context.Context, in *lnrpc.ChannelEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelEventsClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribeChannelEvents")
+	if err != nil {
+		return nil, err
+	}
+	return &replayChannelEventStream{stream}, nil
+}
+
+func (r *Replayer) SubscribeChannelGraph(ctx context.Context, in *lnrpc.GraphTopologySubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelGraphClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribeChannelGraph")
+	if err != nil {
+		return nil, err
+	}
+	return &replayChannelGraphStream{stream}, nil
+}
+
+func (r *Replayer) SubscribeInvoices(ctx context.Context, in *lnrpc.InvoiceSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeInvoicesClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribeInvoices")
+	if err != nil {
+		return nil, err
+	}
+	return &replayInvoiceStream{stream}, nil
+}
+
+func (r *Replayer) SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribePeerEvents")
+	if err != nil {
+		return nil, err
+	}
+	return &replayPeerEventStream{stream}, nil
+}
+
+func (r *Replayer) SubscribeTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeTransactionsClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribeTransactions")
+	if err != nil {
+		return nil, err
+	}
+	return &replayTransactionStream{stream}, nil
+}
+
+func (r *Replayer) SubscribeHtlcEvents(ctx context.Context, in *routerrpc.SubscribeHtlcEventsRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SubscribeHtlcEventsClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribeHtlcEvents")
+	if err != nil {
+		return nil, err
+	}
+	return &replayHtlcEventStream{stream}, nil
+}
+
+func (r *Replayer) SubscribeChannelBackups(ctx context.Context, in *lnrpc.ChannelBackupSubscription,
+	opts ...grpc.CallOption) (lnrpc.Lightning_SubscribeChannelBackupsClient, error) {
+
+	stream, err := r.subscribe(ctx, "SubscribeChannelBackups")
+	if err != nil {
+		return nil, err
+	}
+	return &replayChannelBackupStream{stream}, nil
+}
+
+func (r *Replayer) RegisterBlockEpochNtfn(ctx context.Context, in *chainrpc.BlockEpoch,
+	opts ...grpc.CallOption) (chainrpc.ChainNotifier_RegisterBlockEpochNtfnClient, error) {
+
+	stream, err := r.subscribe(ctx, "RegisterBlockEpochNtfn")
+	if err != nil {
+		return nil, err
+	}
+	return &replayBlockEpochStream{stream}, nil
+}
+
+type replayStream struct {
+	ctx      context.Context
+	replayer *Replayer
+	key      replayStreamKey
+}
+
+func (s *replayStream) Header() (metadata.MD, error) {
+	return metadata.MD{}, nil
+}
+
+func (s *replayStream) Trailer() metadata.MD {
+	return metadata.MD{}
+}
+
+func (s *replayStream) CloseSend() error {
+	return nil
+}
+
+func (s *replayStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *replayStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *replayStream) RecvMsg(m interface{}) error {
+	message, ok := m.(proto.Message)
+	if !ok {
+		return errors.Newf("Can't receive %T", m)
+	}
+	return s.replayer.recv(s.ctx, s.key, message)
+}
+
+type replayChannelEventStream struct{ *replayStream }
+
+func (s *replayChannelEventStream) Recv() (*lnrpc.ChannelEventUpdate, error) {
+	resp := &lnrpc.ChannelEventUpdate{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayChannelGraphStream struct{ *replayStream }
+
+func (s *replayChannelGraphStream) Recv() (*lnrpc.GraphTopologyUpdate, error) {
+	resp := &lnrpc.GraphTopologyUpdate{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayInvoiceStream struct{ *replayStream }
+
+func (s *replayInvoiceStream) Recv() (*lnrpc.Invoice, error) {
+	resp := &lnrpc.Invoice{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayPeerEventStream struct{ *replayStream }
+
+func (s *replayPeerEventStream) Recv() (*lnrpc.PeerEvent, error) {
+	resp := &lnrpc.PeerEvent{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayTransactionStream struct{ *replayStream }
+
+func (s *replayTransactionStream) Recv() (*lnrpc.Transaction, error) {
+	resp := &lnrpc.Transaction{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayHtlcEventStream struct{ *replayStream }
+
+func (s *replayHtlcEventStream) Recv() (*routerrpc.HtlcEvent, error) {
+	resp := &routerrpc.HtlcEvent{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayChannelBackupStream struct{ *replayStream }
+
+func (s *replayChannelBackupStream) Recv() (*lnrpc.ChanBackupSnapshot, error) {
+	resp := &lnrpc.ChanBackupSnapshot{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayBlockEpochStream struct{ *replayStream }
+
+func (s *replayBlockEpochStream) Recv() (*chainrpc.BlockEpoch, error) {
+	resp := &chainrpc.BlockEpoch{}
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testChannel struct {
+	NodePubkey         string `json:"nodePubkey" binding:"required"`
+	LocalFundingAmount int64  `json:"localFundingAmount" binding:"required"`
+	PushSat            *int64 `json:"pushSat"`
+}
+
+type testBase struct {
+	NodeId int `json:"nodeId" binding:"required"`
+}
+
+type testRequest struct {
+	testBase
+	Channels    []testChannel `json:"channels" binding:"required"`
+	TargetConf  *uint32       `json:"targetConf"`
+	Label       string        `json:"label,omitempty"`
+	RequestedOn *time.Time    `json:"requestedOn"`
+	Internal    string        `json:"-"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	generator := newSchemaGenerator()
+	schema := generator.schemaFor(reflect.TypeOf(testRequest{}))
+
+	got, _ := json.Marshal(schema)
+	want := `{"$ref":"#/components/schemas/openapi.testRequest"}`
+	if string(got) != want {
+		t.Errorf("schemaFor()\nGot:\n%v\nWant:\n%v\n", string(got), want)
+	}
+
+	got, _ = json.Marshal(generator.schemas["openapi.testRequest"])
+	want = `{"type":"object","properties":{` +
+		`"channels":{"type":"array","nullable":true,"items":{"$ref":"#/components/schemas/openapi.testChannel"}},` +
+		`"label":{"type":"string"},` +
+		`"nodeId":{"type":"integer","format":"int64"},` +
+		`"requestedOn":{"type":"string","format":"date-time","nullable":true},` +
+		`"targetConf":{"type":"integer","format":"int32","nullable":true,"minimum":0}},` +
+		`"required":["nodeId","channels"]}`
+	if string(got) != want {
+		t.Errorf("schemaFor()\nGot:\n%v\nWant:\n%v\n", string(got), want)
+	}
+}
+
+func TestToOpenApiPath(t *testing.T) {
+	tests := []struct {
+		ginPath        string
+		wantPath       string
+		wantParameters []string
+	}{
+		{"/api/channels", "/api/channels", nil},
+		{"/api/tags/get/:tagId", "/api/tags/get/{tagId}", []string{"tagId"}},
+		{"/api/settings/nodeConnectionDetails/:nodeId/:statusId",
+			"/api/settings/nodeConnectionDetails/{nodeId}/{statusId}", []string{"nodeId", "statusId"}},
+	}
+	for i, test := range tests {
+		gotPath, gotParameters := toOpenApiPath(test.ginPath)
+		if gotPath != test.wantPath || !reflect.DeepEqual(gotParameters, test.wantParameters) {
+			t.Errorf("%d: toOpenApiPath()\nGot:\n%v %v\nWant:\n%v %v\n",
+				i, gotPath, gotParameters, test.wantPath, test.wantParameters)
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec := NewSpec("Test", "1.0")
+	r := gin.New()
+	group := r.Group("/api/channels", spec.ValidateRequest())
+	group.POST("openbatch", func(c *gin.Context) {
+		var request testRequest
+		if err := c.BindJSON(&request); err != nil {
+			return
+		}
+		c.JSON(http.StatusOK, request.NodeId)
+	})
+	spec.AddOperations(group.BasePath(), "channels", Operations{
+		"POST openbatch": {RequestBody: testRequest{}},
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"Valid request",
+			`{"nodeId":1,"channels":[{"nodePubkey":"02ab","localFundingAmount":100000}],"targetConf":6}`,
+			http.StatusOK,
+			`1`,
+		},
+		{
+			"Missing required fields",
+			`{"channels":[{"localFundingAmount":100000}]}`,
+			http.StatusBadRequest,
+			`{"errors":{"fields":{"channels[0].nodePubkey":["This field is required"],` +
+				`"nodeId":["This field is required"]},"server":null}}`,
+		},
+		{
+			"Wrong types",
+			`{"nodeId":"1","channels":[{"nodePubkey":"02ab","localFundingAmount":1.5}],"targetConf":-1,` +
+				`"requestedOn":"yesterday"}`,
+			http.StatusBadRequest,
+			`{"errors":{"fields":{"channels[0].localFundingAmount":["Must be an integer of 64 bits"],` +
+				`"nodeId":["Must be an integer of 64 bits"],"requestedOn":["Must be a date-time (RFC 3339)"],` +
+				`"targetConf":["Must be a non-negative integer of 32 bits"]},"server":null}}`,
+		},
+		{
+			"Not an object",
+			`[]`,
+			http.StatusBadRequest,
+			`{"errors":{"fields":null,"server":["Request body: Must be an object"]}}`,
+		},
+		{
+			"Invalid JSON",
+			`{"nodeId":`,
+			http.StatusBadRequest,
+			`{"errors":{"fields":null,"server":["Parsing JSON: unexpected EOF"]}}`,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/channels/openbatch", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			r.ServeHTTP(w, req)
+			if w.Code != test.wantStatus || w.Body.String() != test.wantBody {
+				t.Errorf("%d: ValidateRequest()\nGot:\n%v %v\nWant:\n%v %v\n",
+					i, w.Code, w.Body.String(), test.wantStatus, test.wantBody)
+			}
+		})
+	}
+}
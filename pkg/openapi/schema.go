@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const componentPrefix = "#/components/schemas/"
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+//nolint:gochecknoglobals
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	fileHeaderType    = reflect.TypeOf(multipart.FileHeader{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{schemas: make(map[string]*Schema)}
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		nullable := *schema
+		nullable.Nullable = true
+		return &nullable
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom JSON (i.e. null types or raw JSON) can be anything.
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: new(int64)}
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(int64)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, "json")
+		}
+		name := componentName(t)
+		if _, exists := g.schemas[name]; !exists {
+			// Registered before the fields are generated to support recursive types.
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t, "json")
+		}
+		return &Schema{Ref: componentPrefix + name}
+	}
+	return &Schema{}
+}
+
+// formSchemaFor generates the schema of a multipart form based on the form tags.
+func (g *schemaGenerator) formSchemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return g.structSchema(t, "form")
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type, tagKey string) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t, tagKey)
+	return schema
+}
+
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type, tagKey string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, tagged := parseTag(field.Tag.Get(tagKey))
+		if name == "-" && options == "" {
+			continue
+		}
+		fieldType := field.Type
+		if field.Anonymous && !tagged {
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				g.addFields(schema, fieldType, tagKey)
+				continue
+			}
+		}
+		// Only the tagged fields of a form are bound by the handlers.
+		if !field.IsExported() || (tagKey == "form" && !tagged) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldSchema := g.schemaFor(fieldType)
+		if hasOption(options, "string") {
+			fieldSchema = &Schema{Type: "string", Nullable: fieldSchema.Nullable}
+		}
+		schema.Properties[name] = fieldSchema
+		if hasOption(field.Tag.Get("binding"), "required") && !hasOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func parseTag(tag string) (string, string, bool) {
+	if tag == "" {
+		return "", "", false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	return name, options, true
+}
+
+func hasOption(options string, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// componentName is the last element of the package path followed by the type name (i.e. channels.Channel).
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if t.PkgPath() == "" {
+		return name
+	}
+	return path.Base(t.PkgPath()) + "." + name
+}
@@ -0,0 +1,225 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const openApiVersion = "3.0.3"
+
+// Operation documents a single route, the request and response values are only used for their type.
+type Operation struct {
+	Summary string
+	// RequestBody is a value of the JSON request body type, requests are validated against it.
+	RequestBody interface{}
+	// RequestForm is a value of the multipart form request type, fields are taken from the form tags.
+	RequestForm interface{}
+	// Response is a value of the JSON response type.
+	Response interface{}
+	// QueryParameters are the names of the (optional) query parameters.
+	QueryParameters []string
+}
+
+// Operations maps "METHOD relativePath" (i.e. "POST openbatch") to the documentation of the route.
+type Operations map[string]Operation
+
+type Spec struct {
+	document       document
+	generator      *schemaGenerator
+	requestSchemas map[string]*Schema
+}
+
+type document struct {
+	OpenApi    string                                `json:"openapi"`
+	Info       info                                  `json:"info"`
+	Tags       []tag                                 `json:"tags,omitempty"`
+	Paths      map[string]map[string]*operationEntry `json:"paths"`
+	Components components                            `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type tag struct {
+	Name string `json:"name"`
+}
+
+type components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type operationEntry struct {
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	OperationId string              `json:"operationId"`
+	Parameters  []parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+func NewSpec(title string, version string) *Spec {
+	generator := newSchemaGenerator()
+	return &Spec{
+		document: document{
+			OpenApi:    openApiVersion,
+			Info:       info{Title: title, Version: version},
+			Paths:      make(map[string]map[string]*operationEntry),
+			Components: components{Schemas: generator.schemas},
+		},
+		generator:      generator,
+		requestSchemas: make(map[string]*Schema),
+	}
+}
+
+// AddOperations documents the routes of a route group, basePath is the BasePath() of the group.
+func (s *Spec) AddOperations(basePath string, tagName string, operations Operations) {
+	s.addTag(tagName)
+	for key, operation := range operations {
+		method, relativePath, _ := strings.Cut(key, " ")
+		ginPath := joinPaths(basePath, relativePath)
+		entry := s.getOrAddEntry(method, ginPath)
+		entry.Summary = operation.Summary
+		entry.Tags = []string{tagName}
+		for _, queryParameter := range operation.QueryParameters {
+			entry.Parameters = append(entry.Parameters, parameter{
+				Name:   queryParameter,
+				In:     "query",
+				Schema: &Schema{Type: "string"},
+			})
+		}
+		if operation.RequestBody != nil {
+			schema := s.generator.schemaFor(reflect.TypeOf(operation.RequestBody))
+			entry.RequestBody = &requestBody{
+				Required: true,
+				Content:  map[string]mediaType{gin.MIMEJSON: {Schema: schema}},
+			}
+			s.requestSchemas[method+" "+ginPath] = schema
+		}
+		if operation.RequestForm != nil {
+			entry.RequestBody = &requestBody{
+				Required: true,
+				Content: map[string]mediaType{
+					gin.MIMEMultipartPOSTForm: {Schema: s.generator.formSchemaFor(reflect.TypeOf(operation.RequestForm))},
+				},
+			}
+		}
+		if operation.Response != nil {
+			entry.Responses["200"] = response{
+				Description: "OK",
+				Content: map[string]mediaType{
+					gin.MIMEJSON: {Schema: s.generator.schemaFor(reflect.TypeOf(operation.Response))},
+				},
+			}
+		}
+	}
+}
+
+func (s *Spec) addTag(tagName string) {
+	for _, t := range s.document.Tags {
+		if t.Name == tagName {
+			return
+		}
+	}
+	s.document.Tags = append(s.document.Tags, tag{Name: tagName})
+}
+
+// AddRoutes lists the routes under /api that are not documented with AddOperations yet.
+func (s *Spec) AddRoutes(routes gin.RoutesInfo) {
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		s.getOrAddEntry(route.Method, route.Path)
+	}
+}
+
+// Handler serves the document as JSON.
+func (s *Spec) Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.document)
+}
+
+func (s *Spec) getOrAddEntry(method string, ginPath string) *operationEntry {
+	path, pathParameters := toOpenApiPath(ginPath)
+	if _, exists := s.document.Paths[path]; !exists {
+		s.document.Paths[path] = make(map[string]*operationEntry)
+	}
+	lowerMethod := strings.ToLower(method)
+	entry, exists := s.document.Paths[path][lowerMethod]
+	if exists {
+		return entry
+	}
+	entry = &operationEntry{
+		OperationId: operationId(lowerMethod, path),
+		Responses:   map[string]response{"200": {Description: "OK"}},
+	}
+	for _, pathParameter := range pathParameters {
+		entry.Parameters = append(entry.Parameters, parameter{
+			Name:     pathParameter,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	s.document.Paths[path][lowerMethod] = entry
+	return entry
+}
+
+func joinPaths(basePath string, relativePath string) string {
+	if relativePath == "" {
+		return basePath
+	}
+	return strings.TrimSuffix(basePath, "/") + "/" + strings.TrimPrefix(relativePath, "/")
+}
+
+// toOpenApiPath converts the gin parameters (:nodeId and *path) to OpenAPI parameters ({nodeId}).
+func toOpenApiPath(ginPath string) (string, []string) {
+	var pathParameters []string
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			pathParameters = append(pathParameters, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), pathParameters
+}
+
+func operationId(method string, path string) string {
+	var id strings.Builder
+	id.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '{' || r == '}'
+	}) {
+		if part == "api" && id.Len() == len(method) {
+			continue
+		}
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
@@ -0,0 +1,223 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+// ValidateRequest validates JSON request bodies against the documented request type of the route.
+// Invalid requests are aborted with field errors keyed on the JSON path (i.e. channels[0].nodeId).
+// Routes without a documented request body are passed through.
+func (s *Spec) ValidateRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schema, exists := s.requestSchemas[c.Request.Method+" "+c.FullPath()]
+		if !exists || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		contentType := c.ContentType()
+		if contentType != "" && contentType != gin.MIMEJSON {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, server_errors.SingleServerError("Reading request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		serverError := s.Validate(schema, body)
+		if serverError != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, serverError)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Validate checks the JSON document against the schema, nil is returned when the document is valid.
+func (s *Spec) Validate(schema *Schema, body []byte) *server_errors.ServerError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return server_errors.SingleServerError("Request body is required")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return server_errors.SingleServerError(server_errors.JsonParseError + ": " + err.Error())
+	}
+	serverError := &server_errors.ServerError{}
+	s.validateValue(schema, value, "", serverError)
+	if serverError.Errors.Fields == nil && serverError.Errors.Server == nil {
+		return nil
+	}
+	return serverError
+}
+
+func (s *Spec) validateValue(schema *Schema, value interface{}, path string, serverError *server_errors.ServerError) {
+	schema = s.resolve(schema)
+	if value == nil {
+		// Like encoding/json null leaves the value untouched, required fields are checked by the parent.
+		return
+	}
+	var valid bool
+	switch schema.Type {
+	case "":
+		return
+	case "boolean":
+		_, valid = value.(bool)
+	case "integer":
+		valid = isInteger(value, schema.Format, schema.Minimum)
+	case "number":
+		_, valid = value.(json.Number)
+	case "string":
+		valid = isString(value, schema.Format)
+	case "array":
+		var items []interface{}
+		items, valid = value.([]interface{})
+		for i, item := range items {
+			s.validateValue(schema.Items, item, fmt.Sprintf("%v[%d]", path, i), serverError)
+		}
+	case "object":
+		var properties map[string]interface{}
+		properties, valid = value.(map[string]interface{})
+		if valid {
+			s.validateObject(schema, properties, path, serverError)
+		}
+	}
+	if !valid {
+		addError(serverError, path, "Must be "+describe(schema))
+	}
+}
+
+func (s *Spec) validateObject(schema *Schema, properties map[string]interface{}, path string,
+	serverError *server_errors.ServerError) {
+
+	for _, required := range schema.Required {
+		if isEmpty(properties[required]) {
+			addError(serverError, joinField(path, required), "This field is required")
+		}
+	}
+	for name, property := range properties {
+		propertySchema := findProperty(schema, name)
+		if propertySchema == nil {
+			propertySchema = schema.AdditionalProperties
+		}
+		if propertySchema != nil {
+			s.validateValue(propertySchema, property, joinField(path, name), serverError)
+		}
+	}
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for {
+		switch {
+		case schema.Ref != "":
+			resolved, exists := s.generator.schemas[strings.TrimPrefix(schema.Ref, componentPrefix)]
+			if !exists {
+				return &Schema{}
+			}
+			schema = resolved
+		case len(schema.AllOf) == 1:
+			schema = schema.AllOf[0]
+		default:
+			return schema
+		}
+	}
+}
+
+// findProperty matches the name like encoding/json does, preferring an exact match over a case-insensitive one.
+func findProperty(schema *Schema, name string) *Schema {
+	if property, exists := schema.Properties[name]; exists {
+		return property
+	}
+	for propertyName, property := range schema.Properties {
+		if strings.EqualFold(propertyName, name) {
+			return property
+		}
+	}
+	return nil
+}
+
+// isEmpty matches the required validation of gin, zero values are treated as missing.
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case json.Number:
+		number, err := v.Float64()
+		return err == nil && number == 0
+	}
+	return false
+}
+
+func isInteger(value interface{}, format string, minimum *int64) bool {
+	number, ok := value.(json.Number)
+	if !ok {
+		return false
+	}
+	bitSize := 64
+	if format == "int32" {
+		bitSize = 32
+	}
+	if minimum != nil && *minimum >= 0 {
+		_, err := strconv.ParseUint(number.String(), 10, bitSize)
+		return err == nil
+	}
+	integer, err := strconv.ParseInt(number.String(), 10, bitSize)
+	return err == nil && (minimum == nil || integer >= *minimum)
+}
+
+func isString(value interface{}, format string) bool {
+	text, ok := value.(string)
+	if !ok {
+		return false
+	}
+	if format == "date-time" {
+		_, err := time.Parse(time.RFC3339, text)
+		return err == nil
+	}
+	return true
+}
+
+func describe(schema *Schema) string {
+	switch {
+	case schema.Format == "date-time":
+		return "a date-time (RFC 3339)"
+	case schema.Type == "integer" && schema.Minimum != nil && *schema.Minimum == 0:
+		return "a non-negative integer of " + strings.TrimPrefix(schema.Format, "int") + " bits"
+	case schema.Type == "integer":
+		return "an integer of " + strings.TrimPrefix(schema.Format, "int") + " bits"
+	case schema.Type == "array" || schema.Type == "object":
+		return "an " + schema.Type
+	}
+	return "a " + schema.Type
+}
+
+func joinField(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func addError(serverError *server_errors.ServerError, path string, message string) {
+	if path == "" {
+		serverError.AddServerError("Request body: " + message)
+		return
+	}
+	serverError.AddFieldError(path, message)
+}
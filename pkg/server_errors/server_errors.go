@@ -2,6 +2,8 @@ package server_errors
 
 import (
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
@@ -50,6 +52,24 @@ func (se *ServerError) AddServerError(serverErrorDescription string) {
 	se.Errors.Server = append(se.Errors.Server, serverErrorDescription)
 }
 
+// Error allows a ServerError to be returned as an error so the structure can be sent to the front end
+// by the handler that receives it.
+func (se *ServerError) Error() string {
+	var messages []string
+	var fields []string
+	for field := range se.Errors.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		for _, fieldError := range se.Errors.Fields[field] {
+			messages = append(messages, field+": "+fieldError)
+		}
+	}
+	messages = append(messages, se.Errors.Server...)
+	return strings.Join(messages, ", ")
+}
+
 func SingleServerError(serverErrorDescription string) *ServerError {
 	serverError := &ServerError{}
 	serverError.AddServerError(serverErrorDescription)
@@ -85,6 +105,17 @@ func SendBadRequestFromError(c *gin.Context, err error) {
 	c.JSON(http.StatusBadRequest, SingleServerError(err.Error()))
 }
 
+// SendServerErrorOrWrap sends a ServerError in the error chain as unprocessable entity
+// any other error is handled like WrapLogAndSendServerError.
+func SendServerErrorOrWrap(c *gin.Context, err error, message string) {
+	var serverError *ServerError
+	if errors.As(err, &serverError) {
+		c.JSON(http.StatusUnprocessableEntity, serverError)
+		return
+	}
+	WrapLogAndSendServerError(c, err, message)
+}
+
 func SendUnprocessableEntityFromError(c *gin.Context, err error) {
 	c.JSON(http.StatusUnprocessableEntity, SingleServerError(err.Error()))
 }